@@ -1,5 +1,7 @@
 package acme
 
+import "encoding/json"
+
 // acme.Resource values identify different types of ACME resources
 type Resource string
 
@@ -12,11 +14,20 @@ const (
 	StatusReady       = "ready"
 	StatusDeactivated = "deactivated"
 
-	IdentifierDNS = "dns"
+	IdentifierDNS         = "dns"
+	IdentifierPermanentID = "permanent-identifier"
+	IdentifierTNAuthList  = "TNAuthList"
+
+	ChallengeHTTP01         = "http-01"
+	ChallengeTLSALPN01      = "tls-alpn-01"
+	ChallengeDNS01          = "dns-01"
+	ChallengeDeviceAttest01 = "device-attest-01"
+	ChallengeTKAuth01       = "tkauth-01"
+	ChallengeOnionCSR01     = "onion-csr-01"
 
-	ChallengeHTTP01    = "http-01"
-	ChallengeTLSALPN01 = "tls-alpn-01"
-	ChallengeDNS01     = "dns-01"
+	// TKAuthTypeATC is the tkauth-type of tkauth-01 challenges satisfied with
+	// an Authority Token for a TNAuthList identifier (RFC 9448).
+	TKAuthTypeATC = "atc"
 
 	HTTP01BaseURL = ".well-known/acme-challenge/"
 
@@ -26,12 +37,17 @@ const (
 type Identifier struct {
 	Type  string `json:"type"`
 	Value string `json:"value"`
+	// AncestorDomain asks for the identifier to be authorized by an
+	// authorization for the given ancestor domain (RFC 9444).
+	AncestorDomain string `json:"ancestorDomain,omitempty"`
 }
 
 type Account struct {
 	Status  string   `json:"status"`
 	Contact []string `json:"contact"`
 	Orders  string   `json:"orders,omitempty"`
+	// Delegations is the URL of the account's delegations list (RFC 9115).
+	Delegations string `json:"delegations,omitempty"`
 }
 
 // An Order is created to request issuance for a CSR
@@ -45,6 +61,33 @@ type Order struct {
 	NotAfter       string          `json:"notAfter,omitempty"`
 	Authorizations []string        `json:"authorizations"`
 	Certificate    string          `json:"certificate,omitempty"`
+	// Delegation is the URL of the delegation used for the order and
+	// AllowCertificateGet allows the certificate to be fetched with an
+	// unauthenticated GET (RFC 9115).
+	Delegation          string `json:"delegation,omitempty"`
+	AllowCertificateGet bool   `json:"allow-certificate-get,omitempty"`
+	// Replaces is the ARI certificate identifier of the certificate the order
+	// replaces (RFC 9773).
+	Replaces string `json:"replaces,omitempty"`
+	// TestMeta is a Pebble extension holding any JSON value a client sends
+	// with a new order, echoed back so that test frameworks can correlate
+	// their test cases with the orders they create.
+	TestMeta json.RawMessage `json:"test-meta,omitempty"`
+	// FinalizeDryRun is a Pebble extension: the URL a finalize request can be
+	// posted to in order to check it without issuing a certificate.
+	FinalizeDryRun string `json:"finalizeDryRun,omitempty"`
+}
+
+// A FinalizeDryRunResult is the response to a finalize request posted to an
+// order's FinalizeDryRun URL. Problems are those finalizing the order with the
+// request would run into, in the order they would be detected, so a real
+// finalize request would fail with the first one. Valid is true if there are
+// none. Warnings are the lint warnings of the certificate that would be
+// issued.
+type FinalizeDryRunResult struct {
+	Valid    bool              `json:"valid"`
+	Problems []*ProblemDetails `json:"problems"`
+	Warnings []string          `json:"warnings,omitempty"`
 }
 
 // An Authorization is created for each identifier in an order
@@ -59,6 +102,9 @@ type Authorization struct {
 	// Authorization with the identifier `example.com` and one DNS-01 challenge
 	// corresponds to a name `*.example.com` from an associated order.
 	Wildcard bool `json:"wildcard,omitempty"`
+	// SubdomainAuthAllowed indicates that the authorization also authorizes
+	// subdomains of its identifier (RFC 9444).
+	SubdomainAuthAllowed bool `json:"subdomainAuthAllowed,omitempty"`
 }
 
 // A Challenge is used to validate an Authorization
@@ -69,4 +115,56 @@ type Challenge struct {
 	Status    string          `json:"status"`
 	Validated string          `json:"validated,omitempty"`
 	Error     *ProblemDetails `json:"error,omitempty"`
+	// TKAuthType and TokenAuthority are only set for tkauth-01 challenges
+	// (RFC 9447).
+	TKAuthType     string `json:"tkauth-type,omitempty"`
+	TokenAuthority string `json:"token-authority,omitempty"`
+	// Nonce is only set for onion-csr-01 challenges (RFC 9799).
+	Nonce string `json:"nonce,omitempty"`
+	// Attempts is the history of validation attempts made for the challenge.
+	// It is a Pebble extension.
+	Attempts []ValidationAttempt `json:"attempts,omitempty"`
+	// ValidationStarted and ValidationCompleted are when the VA started and
+	// finished validating the challenge, in RFC 3339 format with nanosecond
+	// precision. They are Pebble extensions.
+	ValidationStarted   string `json:"validationStarted,omitempty"`
+	ValidationCompleted string `json:"validationCompleted,omitempty"`
+}
+
+// A ValidationAttempt records the result of one attempt to validate a
+// challenge.
+type ValidationAttempt struct {
+	Attempt int `json:"attempt"`
+	// Time is when the attempt finished, to the second.
+	Time string `json:"time"`
+	// Started and Completed are when the attempt started and finished, with
+	// nanosecond precision.
+	Started   string          `json:"started,omitempty"`
+	Completed string          `json:"completed,omitempty"`
+	Error     *ProblemDetails `json:"error,omitempty"`
+}
+
+// A Delegation configures the certificates a Name Delegation Client may
+// request for an Identifier Owner's names (RFC 9115).
+type Delegation struct {
+	CSRTemplate *CSRTemplate      `json:"csr-template"`
+	CNAMEMap    map[string]string `json:"cname-map,omitempty"`
+}
+
+// A CSRTemplate constrains the CSRs that can be used with a delegation. A
+// value of "**" allows any value chosen by the client.
+type CSRTemplate struct {
+	KeyTypes   []CSRTemplateKeyType `json:"keyTypes,omitempty"`
+	Subject    map[string]string    `json:"subject,omitempty"`
+	Extensions struct {
+		SubjectAltName struct {
+			DNSName []string `json:"DNS,omitempty"`
+		} `json:"subjectAltName"`
+	} `json:"extensions"`
+}
+
+// A CSRTemplateKeyType is one of the key types allowed by a CSRTemplate.
+type CSRTemplateKeyType struct {
+	PublicKeyType string `json:"PublicKeyType"`
+	NamedCurve    string `json:"namedCurve,omitempty"`
 }
@@ -0,0 +1,351 @@
+package acme
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The types below are the JSON objects Pebble's management interface responds
+// with, so that tools parsing its output can decode it with Pebble's own
+// types. They are versioned by ManagementAPIVersion, which every management
+// response carries in the ManagementVersionHeader header. Within a version
+// fields are only added, and only as optional fields, so a tool built against
+// one release keeps decoding the output of later releases with the same
+// version. Removing, renaming or changing the type of a field increments the
+// version.
+//
+// Fields that aren't part of the versioned schema yet are sent in the
+// Extensions of an object, keyed by field name, until a later version
+// promotes them.
+const (
+	ManagementAPIVersion    = 1
+	ManagementVersionHeader = "Pebble-Management-Version"
+	// ManagementTotalCountHeader carries the number of items of a management
+	// list response that passed its filters, before pagination.
+	ManagementTotalCountHeader = "Pebble-Total-Count"
+)
+
+// Extensions holds the fields of a management API object that aren't part of
+// its versioned schema.
+type Extensions map[string]json.RawMessage
+
+// CACertificate describes one of the CA's root or intermediate certificates
+// in a certificate listing.
+type CACertificate struct {
+	Index      int        `json:"index"`
+	Default    bool       `json:"default"`
+	Subject    string     `json:"subject"`
+	Issuer     string     `json:"issuer"`
+	NotAfter   string     `json:"notAfter"`
+	URL        string     `json:"url"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// RootInfo describes one of the CA's root certificates in the runtime info.
+type RootInfo struct {
+	Index      int        `json:"index"`
+	Default    bool       `json:"default"`
+	Subject    string     `json:"subject"`
+	SHA256     string     `json:"sha256"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// CertificateSummary describes an issued certificate in the results of a
+// certificate search or bulk revocation. Dates are in RFC 3339 format.
+type CertificateSummary struct {
+	Serial     string     `json:"serial"`
+	AccountID  string     `json:"accountID"`
+	Names      []string   `json:"names"`
+	NotBefore  string     `json:"notBefore"`
+	NotAfter   string     `json:"notAfter"`
+	URL        string     `json:"url"`
+	PEM        string     `json:"pem,omitempty"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// RevocationResult lists the certificates revoked by a bulk revocation and
+// the serials given that didn't match an unrevoked certificate.
+type RevocationResult struct {
+	Revoked    []CertificateSummary `json:"revoked"`
+	NotFound   []string             `json:"notFound,omitempty"`
+	Extensions Extensions           `json:"extensions,omitempty"`
+}
+
+// CTLog describes one of the simulated CT logs whose SCTs are embedded in
+// issued certificates.
+type CTLog struct {
+	Description string     `json:"description"`
+	URL         string     `json:"url"`
+	LogID       string     `json:"logID"`
+	Key         string     `json:"key"`
+	Extensions  Extensions `json:"extensions,omitempty"`
+}
+
+// ChallengeFailure describes a challenge of a failed authorization.
+type ChallengeFailure struct {
+	Type       string              `json:"type"`
+	Status     string              `json:"status"`
+	Error      *ProblemDetails     `json:"error,omitempty"`
+	Attempts   []ValidationAttempt `json:"attempts,omitempty"`
+	Extensions Extensions          `json:"extensions,omitempty"`
+}
+
+// AuthorizationFailure describes an authorization of a failure.
+type AuthorizationFailure struct {
+	ID         string             `json:"id"`
+	Identifier Identifier         `json:"identifier"`
+	Status     string             `json:"status"`
+	Challenges []ChallengeFailure `json:"challenges"`
+	Extensions Extensions         `json:"extensions,omitempty"`
+}
+
+// FailureReport describes a failed order or authorization.
+type FailureReport struct {
+	Time    time.Time `json:"time"`
+	Type    string    `json:"type"`
+	ID      string    `json:"id"`
+	Account string    `json:"account,omitempty"`
+	// Order is the ID of the order of a failed authorization.
+	Order string `json:"order,omitempty"`
+	// Error is the error of a failed order.
+	Error          *ProblemDetails        `json:"error,omitempty"`
+	Identifiers    []Identifier           `json:"identifiers,omitempty"`
+	Authorizations []AuthorizationFailure `json:"authorizations"`
+	Extensions     Extensions             `json:"extensions,omitempty"`
+}
+
+// ChallengeTiming describes when the VA validated a challenge.
+type ChallengeTiming struct {
+	ID                  string              `json:"id"`
+	Type                string              `json:"type"`
+	Status              string              `json:"status"`
+	ValidationStarted   string              `json:"validationStarted,omitempty"`
+	ValidationCompleted string              `json:"validationCompleted,omitempty"`
+	DurationMs          float64             `json:"durationMs,omitempty"`
+	Attempts            []ValidationAttempt `json:"attempts"`
+	Extensions          Extensions          `json:"extensions,omitempty"`
+}
+
+// TestMetaOrder describes an order with a test-meta value.
+type TestMetaOrder struct {
+	ID          string          `json:"id"`
+	Account     string          `json:"account"`
+	Status      string          `json:"status"`
+	Identifiers []Identifier    `json:"identifiers"`
+	TestMeta    json.RawMessage `json:"test-meta"`
+	Extensions  Extensions      `json:"extensions,omitempty"`
+}
+
+// ExpectedKeyAuthorization is the key authorization the VA expects for a
+// challenge. Overridden is true if it was set through the management
+// interface rather than computed from the account key.
+type ExpectedKeyAuthorization struct {
+	ID               string     `json:"id"`
+	Type             string     `json:"type"`
+	KeyAuthorization string     `json:"keyAuthorization"`
+	Overridden       bool       `json:"overridden"`
+	Extensions       Extensions `json:"extensions,omitempty"`
+}
+
+// AccountAttestation is the attestation object submitted with a new-account
+// request, base64url encoded in AttObj, and its attestation statement format.
+type AccountAttestation struct {
+	ID         string     `json:"id"`
+	Format     string     `json:"fmt"`
+	AttObj     string     `json:"attObj"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// ResponseSigningKey is the public key of the experimental response signing.
+// Header is the response header carrying the detached JWS over the body of
+// directory and certificate responses.
+type ResponseSigningKey struct {
+	Algorithm  string          `json:"alg"`
+	KeyID      string          `json:"kid"`
+	Key        json.RawMessage `json:"jwk"`
+	Header     string          `json:"header"`
+	Extensions Extensions      `json:"extensions,omitempty"`
+}
+
+// AnnotatedObject describes an account, order or certificate annotated
+// through the management interface.
+type AnnotatedObject struct {
+	// Collection is the store collection of the object: accounts, orders or
+	// certificates.
+	Collection  string            `json:"collection"`
+	ID          string            `json:"id"`
+	Annotations map[string]string `json:"annotations"`
+	Extensions  Extensions        `json:"extensions,omitempty"`
+}
+
+// AutoFinalizedOrder describes an auto-finalized order.
+type AutoFinalizedOrder struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Key is the PEM encoded PKCS #8 private key of the certificate.
+	Key string `json:"key"`
+	// Certificate is the PEM encoded certificate chain, once it is issued.
+	Certificate string     `json:"certificate,omitempty"`
+	Extensions  Extensions `json:"extensions,omitempty"`
+}
+
+// ExternalAccountKey describes an external account binding MAC key.
+type ExternalAccountKey struct {
+	KeyID string `json:"keyID"`
+	// HMACKey is the base64url encoded MAC key.
+	HMACKey string `json:"hmacKey"`
+	// Status is "valid" or "revoked".
+	Status string `json:"status"`
+	// Accounts are the IDs of the accounts created with the key.
+	Accounts   []string   `json:"accounts"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// ExternalAccountBindings lists the external account binding MAC keys and the
+// key ID each account was created with, keyed by account ID.
+type ExternalAccountBindings struct {
+	Keys       []ExternalAccountKey `json:"keys"`
+	Accounts   map[string]string    `json:"accounts"`
+	Extensions Extensions           `json:"extensions,omitempty"`
+}
+
+// The statuses of a MassRevocation.
+const (
+	MassRevocationInProgress = "in-progress"
+	MassRevocationComplete   = "complete"
+)
+
+// MassRevocation describes a simulated CA mass revocation incident: the
+// serials of the certificates selected for revocation and how many of them
+// have been revoked so far.
+type MassRevocation struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	// Reason is the RFC 5280 reason code the certificates are revoked with.
+	Reason uint `json:"reason"`
+	// Started and Ends bound the window the revocations are spread over, as
+	// RFC 3339 times.
+	Started    string     `json:"started"`
+	Ends       string     `json:"ends"`
+	Serials    []string   `json:"serials"`
+	Revoked    int        `json:"revoked"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// Namespace lists the IDs of the accounts of a namespace and of their orders
+// and certificates. The default namespace has an empty name.
+type Namespace struct {
+	Name         string     `json:"name"`
+	Accounts     []string   `json:"accounts"`
+	Orders       []string   `json:"orders"`
+	Certificates []string   `json:"certificates"`
+	Extensions   Extensions `json:"extensions,omitempty"`
+}
+
+// Interaction describes a request about an order and the response to it in an
+// order report. Request and response bodies are truncated to the configured
+// size.
+type Interaction struct {
+	Time       string  `json:"time"`
+	Method     string  `json:"method"`
+	Endpoint   string  `json:"endpoint"`
+	Path       string  `json:"path"`
+	Status     int     `json:"status"`
+	DurationMs float64 `json:"durationMs"`
+	// ProblemType is the type of the problem document of an error response.
+	ProblemType string `json:"problemType,omitempty"`
+	// Request is the decoded payload of a JWS request body, or the body
+	// itself if it isn't a JWS. POST-as-GET requests have none.
+	Request  string `json:"request,omitempty"`
+	Response string `json:"response,omitempty"`
+	// Location is the Location header of the response.
+	Location   string     `json:"location,omitempty"`
+	Truncated  bool       `json:"truncated,omitempty"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// AuthorizationReport describes an authorization of an order and the
+// validation of its challenges in an order report.
+type AuthorizationReport struct {
+	ID         string            `json:"id"`
+	Identifier Identifier        `json:"identifier"`
+	Status     string            `json:"status"`
+	Challenges []ChallengeTiming `json:"challenges"`
+	Extensions Extensions        `json:"extensions,omitempty"`
+}
+
+// OrderReport describes an order with every recorded request about it, the
+// validation of its authorizations and the certificate issued for it.
+type OrderReport struct {
+	Generated      string                `json:"generated"`
+	ID             string                `json:"id"`
+	Account        string                `json:"account"`
+	Status         string                `json:"status"`
+	Identifiers    []Identifier          `json:"identifiers"`
+	Expires        string                `json:"expires"`
+	Error          *ProblemDetails       `json:"error,omitempty"`
+	Interactions   []Interaction         `json:"interactions"`
+	Authorizations []AuthorizationReport `json:"authorizations"`
+	// Certificate is the PEM encoded certificate chain issued for the order.
+	Certificate string     `json:"certificate,omitempty"`
+	Extensions  Extensions `json:"extensions,omitempty"`
+}
+
+// DashboardAccount describes an account on the management dashboard.
+type DashboardAccount struct {
+	ID         string     `json:"id"`
+	Status     string     `json:"status"`
+	Contact    []string   `json:"contact,omitempty"`
+	Namespace  string     `json:"namespace,omitempty"`
+	Orders     int        `json:"orders"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// DashboardOrder describes an order on the management dashboard.
+type DashboardOrder struct {
+	ID          string          `json:"id"`
+	Account     string          `json:"account"`
+	Status      string          `json:"status"`
+	Identifiers []Identifier    `json:"identifiers"`
+	Expires     string          `json:"expires"`
+	Error       *ProblemDetails `json:"error,omitempty"`
+	// Certificate is the serial of the certificate issued for the order.
+	Certificate string     `json:"certificate,omitempty"`
+	Extensions  Extensions `json:"extensions,omitempty"`
+}
+
+// DashboardValidation describes a validated challenge on the management
+// dashboard.
+type DashboardValidation struct {
+	Challenge  string          `json:"challenge"`
+	Type       string          `json:"type"`
+	Identifier Identifier      `json:"identifier"`
+	Status     string          `json:"status"`
+	Completed  string          `json:"completed"`
+	Attempts   int             `json:"attempts"`
+	Error      *ProblemDetails `json:"error,omitempty"`
+	Extensions Extensions      `json:"extensions,omitempty"`
+}
+
+// DashboardCertificate describes an issued certificate on the management
+// dashboard.
+type DashboardCertificate struct {
+	Serial     string     `json:"serial"`
+	Account    string     `json:"account"`
+	Names      []string   `json:"names"`
+	NotBefore  string     `json:"notBefore"`
+	NotAfter   string     `json:"notAfter"`
+	Extensions Extensions `json:"extensions,omitempty"`
+}
+
+// Dashboard is a snapshot of the store shown by the management dashboard:
+// the accounts, and the most recent orders, validations and unrevoked
+// certificates, newest first.
+type Dashboard struct {
+	Generated    string                 `json:"generated"`
+	Accounts     []DashboardAccount     `json:"accounts"`
+	Orders       []DashboardOrder       `json:"orders"`
+	Validations  []DashboardValidation  `json:"validations"`
+	Certificates []DashboardCertificate `json:"certificates"`
+	Extensions   Extensions             `json:"extensions,omitempty"`
+}
@@ -0,0 +1,121 @@
+package acme
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// defaultLanguage is the language key of the detail templates used when a
+// request's Accept-Language header matches no other language, or when
+// there's no request, as for the problems embedded in orders and challenges.
+const defaultLanguage = "*"
+
+// detailTemplates are the parsed ProblemConfig.Details.
+var detailTemplates map[string]map[string]*template.Template
+
+// DetailTemplateData is what detail templates are executed with.
+type DetailTemplateData struct {
+	// Type is the problem type without any namespace customisation.
+	Type string
+	// Detail is the detail Pebble would have sent.
+	Detail string
+	Status int
+}
+
+func parseDetailTemplates(details map[string]map[string]string) (map[string]map[string]*template.Template, error) {
+	parsed := make(map[string]map[string]*template.Template)
+	for name, languages := range details {
+		parsed[name] = make(map[string]*template.Template)
+		for language, text := range languages {
+			tmpl, err := template.New(name + "/" + language).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("detail template for %q in %q: %s", name, language, err)
+			}
+			parsed[name][strings.ToLower(language)] = tmpl
+		}
+	}
+	return parsed, nil
+}
+
+// LocalizeProblem returns a copy of the problem with its detail rendered by
+// the detail template of the language the Accept-Language header prefers,
+// along with that language. The problem is returned unchanged, with an empty
+// language, if no template applies.
+func LocalizeProblem(prob *ProblemDetails, acceptLanguage string) (*ProblemDetails, string) {
+	problemConfigMu.RLock()
+	templates := detailTemplates
+	problemConfigMu.RUnlock()
+	if len(templates) == 0 {
+		return prob, ""
+	}
+
+	name := strings.TrimPrefix(prob.Type, errNS)
+	for _, language := range append(acceptedLanguages(acceptLanguage), defaultLanguage) {
+		for _, key := range []string{name, "*"} {
+			tmpl := templates[key][language]
+			if tmpl == nil {
+				continue
+			}
+			var detail bytes.Buffer
+			err := tmpl.Execute(&detail, DetailTemplateData{
+				Type:   prob.Type,
+				Detail: prob.Detail,
+				Status: prob.HTTPStatus,
+			})
+			if err != nil {
+				return prob, ""
+			}
+			localized := *prob
+			localized.Detail = detail.String()
+			localized.localized = true
+			if language == defaultLanguage {
+				language = ""
+			}
+			return &localized, language
+		}
+	}
+	return prob, ""
+}
+
+// acceptedLanguages returns the lowercased language ranges of an
+// Accept-Language header from most to least preferred, each followed by its
+// primary subtag if it has more than one, e.g. "fr-ca" then "fr". Ranges with
+// a zero weight and "*" are left out.
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		language string
+		q        float64
+	}
+	var ranges []weighted
+	for _, accepted := range strings.Split(header, ",") {
+		params := strings.Split(accepted, ";")
+		language := strings.ToLower(strings.TrimSpace(params[0]))
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if language == "" || language == "*" || q <= 0 {
+			continue
+		}
+		ranges = append(ranges, weighted{language, q})
+	}
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+
+	var languages []string
+	for _, r := range ranges {
+		languages = append(languages, r.language)
+		if i := strings.Index(r.language, "-"); i > 0 {
+			languages = append(languages, r.language[:i])
+		}
+	}
+	return languages
+}
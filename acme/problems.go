@@ -1,8 +1,11 @@
 package acme
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 )
 
 const (
@@ -17,18 +20,125 @@ const (
 	unsupportedContactErr  = errNS + "unsupportedContact"
 	accountDoesNotExistErr = errNS + "accountDoesNotExist"
 	badRevocationReasonErr = errNS + "badRevocationReason"
+	badPublicKeyErr        = errNS + "badPublicKey"
+	badCSRErr              = errNS + "badCSR"
+	dnsErr                 = errNS + "dns"
+	externalAccountReqErr  = errNS + "externalAccountRequired"
+	alreadyReplacedErr     = errNS + "alreadyReplaced"
+	orderNotReadyErr       = errNS + "orderNotReady"
+	rateLimitedErr         = errNS + "rateLimited"
+	unsupportedIdentErr    = errNS + "unsupportedIdentifier"
+	rejectedIdentErr       = errNS + "rejectedIdentifier"
+	badAttestationStmtErr  = errNS + "badAttestationStatement"
+
+	// csrReplayedErr isn't an ACME error type, so it isn't in the ACME error
+	// namespace.
+	csrReplayedErr = "urn:pebble:error:csrReplayed"
+	jwsReplayedErr = "urn:pebble:error:jwsReplayed"
+	maintenanceErr = "urn:pebble:error:maintenance"
 )
 
 type ProblemDetails struct {
 	Type       string `json:"type,omitempty"`
 	Detail     string `json:"detail,omitempty"`
 	HTTPStatus int    `json:"status,omitempty"`
+
+	// localized is set by LocalizeProblem so that the default language detail
+	// template isn't applied again when the problem is marshalled.
+	localized bool
 }
 
 func (pd *ProblemDetails) Error() string {
 	return fmt.Sprintf("%s :: %s", pd.Type, pd.Detail)
 }
 
+// ProblemConfig customises how problem documents are rendered so that clients
+// can be tested against vendor specific problem types and extensions.
+type ProblemConfig struct {
+	// Namespace replaces the "urn:ietf:params:acme:error:" prefix of problem
+	// types when it is not empty.
+	Namespace string
+	// Extensions holds extra fields to add to problem documents, keyed by the
+	// problem type without its namespace (e.g. "badPublicKey"). The fields for
+	// the "*" key are added to every problem document. Extensions can't
+	// replace the type, detail or status fields.
+	Extensions map[string]map[string]interface{}
+	// Details holds text/template templates replacing the detail of problem
+	// documents, keyed by the problem type without its namespace, or "*" for
+	// every problem, and then by language. The templates for the "*"
+	// language are used when the Accept-Language of the request matches no
+	// other, and for the problems embedded in orders and challenges. The
+	// templates are executed with a DetailTemplateData.
+	Details map[string]map[string]string
+}
+
+var (
+	problemConfigMu sync.RWMutex
+	problemConfig   ProblemConfig
+)
+
+// SetProblemConfig changes how all problem documents are rendered. An error
+// is returned if a detail template can't be parsed.
+func SetProblemConfig(config ProblemConfig) error {
+	templates, err := parseDetailTemplates(config.Details)
+	if err != nil {
+		return err
+	}
+	problemConfigMu.Lock()
+	defer problemConfigMu.Unlock()
+	problemConfig = config
+	detailTemplates = templates
+	return nil
+}
+
+// MarshalJSON renders the problem document using the configured namespace and
+// extension fields, see SetProblemConfig.
+func (pd ProblemDetails) MarshalJSON() ([]byte, error) {
+	// problemJSON has the same fields as ProblemDetails without its methods,
+	// avoiding infinite recursion when marshalling.
+	type problemJSON ProblemDetails
+	doc := problemJSON(pd)
+
+	if !pd.localized {
+		if localized, _ := LocalizeProblem(&pd, ""); localized.localized {
+			doc.Detail = localized.Detail
+		}
+	}
+
+	problemConfigMu.RLock()
+	config := problemConfig
+	problemConfigMu.RUnlock()
+
+	name := strings.TrimPrefix(doc.Type, errNS)
+	if config.Namespace != "" && strings.HasPrefix(doc.Type, errNS) {
+		doc.Type = config.Namespace + name
+	}
+
+	if len(config.Extensions["*"]) == 0 && len(config.Extensions[name]) == 0 {
+		return json.Marshal(doc)
+	}
+
+	fields := make(map[string]interface{})
+	for _, extensions := range []map[string]interface{}{config.Extensions["*"], config.Extensions[name]} {
+		for k, v := range extensions {
+			fields[k] = v
+		}
+	}
+	delete(fields, "type")
+	delete(fields, "detail")
+	delete(fields, "status")
+	if doc.Type != "" {
+		fields["type"] = doc.Type
+	}
+	if doc.Detail != "" {
+		fields["detail"] = doc.Detail
+	}
+	if doc.HTTPStatus != 0 {
+		fields["status"] = doc.HTTPStatus
+	}
+	return json.Marshal(fields)
+}
+
 func InternalErrorProblem(detail string) *ProblemDetails {
 	return &ProblemDetails{
 		Type:       serverInternalErr,
@@ -117,6 +227,22 @@ func UnsupportedContactProblem(detail string) *ProblemDetails {
 	}
 }
 
+func UnsupportedIdentifierProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       unsupportedIdentErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
+func RejectedIdentifierProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       rejectedIdentErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
 func AccountDoesNotExistProblem(detail string) *ProblemDetails {
 	return &ProblemDetails{
 		Type:       accountDoesNotExistErr,
@@ -133,6 +259,22 @@ func UnsupportedMediaTypeProblem(detail string) *ProblemDetails {
 	}
 }
 
+func PayloadTooLargeProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       malformedErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusRequestEntityTooLarge,
+	}
+}
+
+func ServiceUnavailableProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       serverInternalErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
+
 func BadRevocationReasonProblem(detail string) *ProblemDetails {
 	return &ProblemDetails{
 		Type:       badRevocationReasonErr,
@@ -140,3 +282,94 @@ func BadRevocationReasonProblem(detail string) *ProblemDetails {
 		HTTPStatus: http.StatusBadRequest,
 	}
 }
+
+func BadPublicKeyProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       badPublicKeyErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
+func BadCSRProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       badCSRErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
+func ExternalAccountRequiredProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       externalAccountReqErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusUnauthorized,
+	}
+}
+
+func CSRReplayedProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       csrReplayedErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
+func JWSReplayedProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       jwsReplayedErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
+func MaintenanceProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       maintenanceErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusServiceUnavailable,
+	}
+}
+
+func AlreadyReplacedProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       alreadyReplacedErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusConflict,
+	}
+}
+
+func OrderNotReadyProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       orderNotReadyErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusForbidden,
+	}
+}
+
+func RateLimitedProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       rateLimitedErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusTooManyRequests,
+	}
+}
+
+// BadAttestationStatementProblem is the error type of
+// draft-ietf-acme-device-attest for attestation statements that can't be
+// verified.
+func BadAttestationStatementProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       badAttestationStmtErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
+
+func DNSProblem(detail string) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       dnsErr,
+		Detail:     detail,
+		HTTPStatus: http.StatusBadRequest,
+	}
+}
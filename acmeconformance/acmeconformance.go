@@ -0,0 +1,278 @@
+// Package acmeconformance checks how an ACME server responds to a suite of
+// requests against the responses Pebble's WFE gives, so that the behaviour
+// clients are tested against with Pebble can be compared with other ACME
+// servers. Each Case sends one kind of request and checks the response against
+// its Expectation, a golden description of Pebble's response.
+//
+// The suite creates an account and an order on the server but never responds
+// to challenges, so it can be run against servers whose validations can't
+// succeed.
+package acmeconformance
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// maxBadNonceRetries is how many times a request that isn't testing nonces is
+// retried with a fresh nonce after a badNonce problem, since Pebble rejects a
+// share of good nonces by default.
+const maxBadNonceRetries = 10
+
+// Config configures a run of the suite.
+type Config struct {
+	// DirectoryURL is the URL of the ACME server's directory.
+	DirectoryURL string
+	// HTTPClient sends the suite's requests. http.DefaultClient is used if it
+	// is nil.
+	HTTPClient *http.Client
+	// Contact is the contact URLs of the account the suite creates.
+	Contact []string
+}
+
+// A Result is the outcome of a Case.
+type Result struct {
+	Case      string
+	Reference string
+	// Failures describe how the response differed from the expectation. The
+	// case passed if there are none and Err is nil.
+	Failures []string
+	// Err is set if the case couldn't be run, for example because a case it
+	// depends on failed.
+	Err error
+}
+
+// Passed returns true if the response matched the expectation.
+func (r Result) Passed() bool {
+	return r.Err == nil && len(r.Failures) == 0
+}
+
+func (r Result) String() string {
+	switch {
+	case r.Err != nil:
+		return fmt.Sprintf("ERROR %s (%s): %s", r.Case, r.Reference, r.Err)
+	case len(r.Failures) > 0:
+		return fmt.Sprintf("FAIL  %s (%s): %s", r.Case, r.Reference, strings.Join(r.Failures, "; "))
+	}
+	return fmt.Sprintf("PASS  %s (%s)", r.Case, r.Reference)
+}
+
+// Run runs every case of the suite in order against the server and returns
+// their results. An error is returned if the directory can't be fetched.
+func Run(config Config) ([]Result, error) {
+	s, err := newSession(config)
+	if err != nil {
+		return nil, err
+	}
+	var results []Result
+	for _, c := range Cases() {
+		result, _ := c.run(s)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// run sends the case's request in the session and checks the response,
+// keeping the objects it created if it passed.
+func (c Case) run(s *session) (Result, *response) {
+	result := Result{Case: c.Name, Reference: c.Reference}
+	resp, err := c.send(s)
+	if err != nil {
+		result.Err = err
+		return result, nil
+	}
+	result.Failures = c.Expect.check(resp)
+	if c.record != nil && len(result.Failures) == 0 {
+		result.Err = c.record(s, resp)
+	}
+	return result, resp
+}
+
+// response is a response received by the suite.
+type response struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// problemType returns the type of a problem document, without its namespace.
+func (r *response) problemType() string {
+	var prob struct {
+		Type string `json:"type"`
+	}
+	_ = json.Unmarshal(r.body, &prob)
+	return prob.Type[strings.LastIndex(prob.Type, ":")+1:]
+}
+
+// session holds the state shared by the cases of a run.
+type session struct {
+	http         *http.Client
+	directoryURL string
+	directory    map[string]string
+	contact      []string
+
+	key        *ecdsa.PrivateKey
+	accountURL string
+	orderURL   string
+	authzURL   string
+	finalize   string
+}
+
+func newSession(config Config) (*session, error) {
+	s := &session{
+		http:         config.HTTPClient,
+		directoryURL: config.DirectoryURL,
+		contact:      config.Contact,
+	}
+	if s.http == nil {
+		s.http = http.DefaultClient
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	s.key = key
+
+	resp, err := s.http.Get(config.DirectoryURL)
+	if err != nil {
+		return nil, fmt.Errorf("fetching directory: %s", err)
+	}
+	defer resp.Body.Close()
+	var directory map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&directory); err != nil {
+		return nil, fmt.Errorf("decoding directory: %s", err)
+	}
+	s.directory = make(map[string]string)
+	for name, value := range directory {
+		var url string
+		if json.Unmarshal(value, &url) == nil {
+			s.directory[name] = url
+		}
+	}
+	return s, nil
+}
+
+func (s *session) do(req *http.Request) (*response, error) {
+	resp, err := s.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &response{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+func (s *session) get(method, url string) (*response, error) {
+	if url == "" {
+		return nil, errors.New("the server provided no URL to request")
+	}
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.do(req)
+}
+
+// nonce fetches a fresh nonce.
+func (s *session) nonce() (string, error) {
+	resp, err := s.get("HEAD", s.directory["newNonce"])
+	if err != nil {
+		return "", err
+	}
+	nonce := resp.header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", errors.New("no Replay-Nonce in newNonce response")
+	}
+	return nonce, nil
+}
+
+// jws describes a JWS sent by a case. The zero value is a valid JWS for the
+// URL, signed by the session's account or with the JWK embedded if there is
+// no account yet.
+type jws struct {
+	url         string
+	payload     string
+	embedJWK    bool
+	nonce       string
+	headerURL   string
+	contentType string
+}
+
+// post sends a JWS. Unless the JWS has a fixed nonce, requests rejected with a
+// badNonce problem are retried.
+func (s *session) post(j jws) (*response, error) {
+	if j.url == "" {
+		return nil, errors.New("the server provided no URL to request")
+	}
+	if j.headerURL == "" {
+		j.headerURL = j.url
+	}
+	if j.contentType == "" {
+		j.contentType = "application/jose+json"
+	}
+	for attempt := 0; ; attempt++ {
+		nonce := j.nonce
+		if nonce == "" {
+			var err error
+			if nonce, err = s.nonce(); err != nil {
+				return nil, err
+			}
+		}
+		body, err := s.sign(j, nonce)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequest("POST", j.url, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", j.contentType)
+		resp, err := s.do(req)
+		if err != nil {
+			return nil, err
+		}
+		if j.nonce != "" || resp.problemType() != "badNonce" || attempt >= maxBadNonceRetries {
+			return resp, nil
+		}
+	}
+}
+
+func (s *session) sign(j jws, nonce string) (string, error) {
+	var key interface{} = s.key
+	embed := j.embedJWK || s.accountURL == ""
+	if !embed {
+		key = &jose.JSONWebKey{Key: s.key, KeyID: s.accountURL}
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, &jose.SignerOptions{
+		NonceSource:  staticNonce(nonce),
+		EmbedJWK:     embed,
+		ExtraHeaders: map[jose.HeaderKey]interface{}{"url": j.headerURL},
+	})
+	if err != nil {
+		return "", err
+	}
+	signed, err := signer.Sign([]byte(j.payload))
+	if err != nil {
+		return "", err
+	}
+	return signed.FullSerialize(), nil
+}
+
+// staticNonce is a jose.NonceSource returning a fixed nonce.
+type staticNonce string
+
+func (n staticNonce) Nonce() (string, error) {
+	return string(n), nil
+}
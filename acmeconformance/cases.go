@@ -0,0 +1,421 @@
+package acmeconformance
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	mrand "math/rand"
+	"net/http"
+	"strings"
+)
+
+// A Case is a request the suite sends and the response expected for it.
+type Case struct {
+	Name string
+	// Reference is the section of RFC 8555 describing the behaviour.
+	Reference string
+	Expect    Expectation
+
+	send func(*session) (*response, error)
+	// record keeps the objects created by a passing case for later cases.
+	record func(*session, *response) error
+}
+
+// An Expectation describes the response Pebble gives to a Case. Where RFC
+// 8555 allows more than one response, or Pebble's response differs from the
+// RFC's recommendation, every acceptable response is listed.
+type Expectation struct {
+	// Status is the acceptable HTTP status codes.
+	Status []int
+	// Problems is the acceptable problem types, without the
+	// "urn:ietf:params:acme:error:" namespace. If it is set the response
+	// must be a problem document.
+	Problems []string
+	// Headers is the headers the response must have.
+	Headers []string
+	// Fields is the fields the JSON object in the response body must have.
+	Fields []string
+}
+
+// check returns how the response differs from the expectation.
+func (e Expectation) check(r *response) []string {
+	var failures []string
+	statusOK := false
+	for _, s := range e.Status {
+		statusOK = statusOK || s == r.status
+	}
+	if !statusOK {
+		failures = append(failures, fmt.Sprintf("status %d, expected one of %v", r.status, e.Status))
+	}
+	if len(e.Problems) > 0 {
+		if ct := r.header.Get("Content-Type"); !strings.HasPrefix(ct, "application/problem+json") {
+			failures = append(failures, fmt.Sprintf(
+				"Content-Type %q, expected application/problem+json", ct))
+		}
+		problemOK := false
+		for _, p := range e.Problems {
+			problemOK = problemOK || p == r.problemType()
+		}
+		if !problemOK {
+			failures = append(failures, fmt.Sprintf(
+				"problem type %q, expected one of %v", r.problemType(), e.Problems))
+		}
+	}
+	for _, h := range e.Headers {
+		if r.header.Get(h) == "" {
+			failures = append(failures, fmt.Sprintf("missing %s header", h))
+		}
+	}
+	if len(e.Fields) > 0 {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(r.body, &fields); err != nil {
+			failures = append(failures, fmt.Sprintf("body isn't a JSON object: %s", err))
+		}
+		for _, f := range e.Fields {
+			if _, present := fields[f]; fields != nil && !present {
+				failures = append(failures, fmt.Sprintf("missing %q field", f))
+			}
+		}
+	}
+	return failures
+}
+
+// requireAccount returns an error if the new-account case didn't pass.
+func (s *session) requireAccount() error {
+	if s.accountURL == "" {
+		return errors.New("skipped, no account was created")
+	}
+	return nil
+}
+
+// requireOrder returns an error if the new-order case didn't pass.
+func (s *session) requireOrder() error {
+	if s.orderURL == "" {
+		return errors.New("skipped, no order was created")
+	}
+	return nil
+}
+
+// Cases returns the cases of the suite, in the order they are run. Later
+// cases use the account and order created by earlier ones.
+func Cases() []Case {
+	name := fmt.Sprintf("%x.conformance.example.com", mrand.Int63())
+	return []Case{
+		{
+			Name:      "directory",
+			Reference: "Section 7.1.1",
+			Expect: Expectation{
+				Status: []int{http.StatusOK},
+				Fields: []string{"newNonce", "newAccount", "newOrder", "revokeCert", "keyChange"},
+			},
+			send: func(s *session) (*response, error) {
+				return s.get("GET", s.directoryURL)
+			},
+		},
+		{
+			Name:      "new-nonce-head",
+			Reference: "Section 7.2",
+			Expect: Expectation{
+				Status:  []int{http.StatusOK, http.StatusNoContent},
+				Headers: []string{"Replay-Nonce", "Cache-Control"},
+			},
+			send: func(s *session) (*response, error) {
+				return s.get("HEAD", s.directory["newNonce"])
+			},
+		},
+		{
+			Name:      "new-nonce-get",
+			Reference: "Section 7.2",
+			Expect: Expectation{
+				Status:  []int{http.StatusNoContent},
+				Headers: []string{"Replay-Nonce", "Cache-Control"},
+			},
+			send: func(s *session) (*response, error) {
+				return s.get("GET", s.directory["newNonce"])
+			},
+		},
+		{
+			Name:      "account-does-not-exist",
+			Reference: "Section 7.3.1",
+			Expect: Expectation{
+				Status:   []int{http.StatusBadRequest},
+				Problems: []string{"accountDoesNotExist"},
+			},
+			send: func(s *session) (*response, error) {
+				return s.post(jws{url: s.directory["newAccount"], payload: `{"onlyReturnExisting":true}`})
+			},
+		},
+		{
+			Name:      "unsupported-content-type",
+			Reference: "Section 6.2",
+			Expect: Expectation{
+				Status:   []int{http.StatusUnsupportedMediaType},
+				Problems: []string{"malformed"},
+			},
+			send: func(s *session) (*response, error) {
+				return s.post(jws{
+					url:         s.directory["newAccount"],
+					payload:     `{"termsOfServiceAgreed":true}`,
+					contentType: "application/json",
+				})
+			},
+		},
+		{
+			Name:      "bad-nonce",
+			Reference: "Section 6.5",
+			Expect: Expectation{
+				Status:   []int{http.StatusBadRequest},
+				Problems: []string{"badNonce"},
+				Headers:  []string{"Replay-Nonce"},
+			},
+			send: func(s *session) (*response, error) {
+				return s.post(jws{
+					url:     s.directory["newAccount"],
+					payload: `{"termsOfServiceAgreed":true}`,
+					nonce:   base64.RawURLEncoding.EncodeToString([]byte("not a nonce")),
+				})
+			},
+		},
+		{
+			Name:      "url-mismatch",
+			Reference: "Section 6.4",
+			Expect: Expectation{
+				Status:   []int{http.StatusBadRequest, http.StatusUnauthorized},
+				Problems: []string{"malformed", "unauthorized"},
+			},
+			send: func(s *session) (*response, error) {
+				return s.post(jws{
+					url:       s.directory["newAccount"],
+					payload:   `{"termsOfServiceAgreed":true}`,
+					headerURL: s.directory["newOrder"],
+				})
+			},
+		},
+		{
+			Name:      "new-account",
+			Reference: "Section 7.3",
+			Expect: Expectation{
+				Status:  []int{http.StatusCreated},
+				Headers: []string{"Location", "Replay-Nonce"},
+				Fields:  []string{"status"},
+			},
+			send: func(s *session) (*response, error) {
+				req := map[string]interface{}{"termsOfServiceAgreed": true}
+				if len(s.contact) > 0 {
+					req["contact"] = s.contact
+				}
+				payload, _ := json.Marshal(req)
+				return s.post(jws{url: s.directory["newAccount"], payload: string(payload)})
+			},
+			record: func(s *session, r *response) error {
+				s.accountURL = r.header.Get("Location")
+				return nil
+			},
+		},
+		{
+			Name:      "existing-account",
+			Reference: "Section 7.3.1",
+			Expect: Expectation{
+				Status:  []int{http.StatusOK},
+				Headers: []string{"Location"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireAccount(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{
+					url:      s.directory["newAccount"],
+					payload:  `{"onlyReturnExisting":true}`,
+					embedJWK: true,
+				})
+			},
+			record: func(s *session, r *response) error {
+				if location := r.header.Get("Location"); location != s.accountURL {
+					return fmt.Errorf("Location %q isn't the account's URL %q", location, s.accountURL)
+				}
+				return nil
+			},
+		},
+		{
+			Name:      "get-account",
+			Reference: "Section 6.3",
+			Expect: Expectation{
+				Status:   []int{http.StatusMethodNotAllowed},
+				Problems: []string{"malformed"},
+				Headers:  []string{"Allow"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireAccount(); err != nil {
+					return nil, err
+				}
+				return s.get("GET", s.accountURL)
+			},
+		},
+		{
+			Name:      "post-as-get-account",
+			Reference: "Section 7.3",
+			Expect: Expectation{
+				Status: []int{http.StatusOK},
+				Fields: []string{"status"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireAccount(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{url: s.accountURL})
+			},
+		},
+		{
+			Name:      "new-order-no-identifiers",
+			Reference: "Section 7.4",
+			Expect: Expectation{
+				Status:   []int{http.StatusBadRequest},
+				Problems: []string{"malformed"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireAccount(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{url: s.directory["newOrder"], payload: `{"identifiers":[]}`})
+			},
+		},
+		{
+			Name:      "new-order-unsupported-identifier",
+			Reference: "Section 7.4",
+			Expect: Expectation{
+				Status:   []int{http.StatusBadRequest},
+				Problems: []string{"malformed", "unsupportedIdentifier", "rejectedIdentifier"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireAccount(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{
+					url:     s.directory["newOrder"],
+					payload: `{"identifiers":[{"type":"conformance","value":"example"}]}`,
+				})
+			},
+		},
+		{
+			Name:      "new-order",
+			Reference: "Section 7.4",
+			Expect: Expectation{
+				Status:  []int{http.StatusCreated},
+				Headers: []string{"Location"},
+				Fields:  []string{"status", "expires", "identifiers", "authorizations", "finalize"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireAccount(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{
+					url:     s.directory["newOrder"],
+					payload: fmt.Sprintf(`{"identifiers":[{"type":"dns","value":%q}]}`, name),
+				})
+			},
+			record: func(s *session, r *response) error {
+				var order struct {
+					Authorizations []string
+					Finalize       string
+				}
+				if err := json.Unmarshal(r.body, &order); err != nil {
+					return err
+				}
+				if len(order.Authorizations) == 0 {
+					return errors.New("order has no authorizations")
+				}
+				s.orderURL = r.header.Get("Location")
+				s.authzURL = order.Authorizations[0]
+				s.finalize = order.Finalize
+				return nil
+			},
+		},
+		{
+			Name:      "post-as-get-order",
+			Reference: "Section 7.1.3",
+			Expect: Expectation{
+				Status: []int{http.StatusOK},
+				Fields: []string{"status", "expires", "identifiers", "authorizations", "finalize"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireOrder(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{url: s.orderURL})
+			},
+		},
+		{
+			Name:      "post-as-get-authorization",
+			Reference: "Section 7.5",
+			Expect: Expectation{
+				Status: []int{http.StatusOK},
+				Fields: []string{"status", "identifier", "challenges"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireOrder(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{url: s.authzURL})
+			},
+		},
+		{
+			Name:      "finalize-not-ready",
+			Reference: "Section 7.4",
+			Expect: Expectation{
+				Status:   []int{http.StatusBadRequest, http.StatusForbidden},
+				Problems: []string{"malformed", "orderNotReady"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireOrder(); err != nil {
+					return nil, err
+				}
+				key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+				if err != nil {
+					return nil, err
+				}
+				csr, err := x509.CreateCertificateRequest(rand.Reader,
+					&x509.CertificateRequest{DNSNames: []string{name}}, key)
+				if err != nil {
+					return nil, err
+				}
+				return s.post(jws{
+					url:     s.finalize,
+					payload: fmt.Sprintf(`{"csr":%q}`, base64.RawURLEncoding.EncodeToString(csr)),
+				})
+			},
+		},
+		{
+			Name:      "deactivate-account",
+			Reference: "Section 7.3.6",
+			Expect: Expectation{
+				Status: []int{http.StatusOK},
+				Fields: []string{"status"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireAccount(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{url: s.accountURL, payload: `{"status":"deactivated"}`})
+			},
+		},
+		{
+			Name:      "deactivated-account",
+			Reference: "Section 7.3.6",
+			Expect: Expectation{
+				Status:   []int{http.StatusUnauthorized, http.StatusForbidden},
+				Problems: []string{"unauthorized"},
+			},
+			send: func(s *session) (*response, error) {
+				if err := s.requireAccount(); err != nil {
+					return nil, err
+				}
+				return s.post(jws{url: s.accountURL})
+			},
+		},
+	}
+}
@@ -0,0 +1,127 @@
+package acmeconformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// comparedHeaders are the response headers whose presence is compared. Their
+// values are server specific.
+var comparedHeaders = []string{"Location", "Replay-Nonce", "Link", "Retry-After", "Cache-Control"}
+
+// A Comparison is how the responses of two servers to a Case differ.
+type Comparison struct {
+	Case      string
+	Reference string
+	// Differences describe how the secondary server's response differed
+	// from the primary server's.
+	Differences []string
+	// Err is set if the case couldn't be run against one of the servers.
+	Err error
+}
+
+// Diverged returns true if the servers' responses differed.
+func (c Comparison) Diverged() bool {
+	return c.Err != nil || len(c.Differences) > 0
+}
+
+func (c Comparison) String() string {
+	switch {
+	case c.Err != nil:
+		return fmt.Sprintf("ERROR %s (%s): %s", c.Case, c.Reference, c.Err)
+	case len(c.Differences) > 0:
+		return fmt.Sprintf("DIFF  %s (%s): %s", c.Case, c.Reference, strings.Join(c.Differences, "; "))
+	}
+	return fmt.Sprintf("SAME  %s (%s)", c.Case, c.Reference)
+}
+
+// Compare runs every case of the suite against two servers, sending each
+// request to both, and returns how the secondary server's responses differed
+// from the primary's in status code, problem type, the presence of headers
+// and the fields of the JSON objects returned. Field values, such as URLs and
+// identifiers, are expected to differ between servers and aren't compared.
+// An error is returned if either directory can't be fetched.
+func Compare(primary, secondary Config) ([]Comparison, error) {
+	p, err := newSession(primary)
+	if err != nil {
+		return nil, fmt.Errorf("primary server: %s", err)
+	}
+	s, err := newSession(secondary)
+	if err != nil {
+		return nil, fmt.Errorf("secondary server: %s", err)
+	}
+	var comparisons []Comparison
+	for _, c := range Cases() {
+		comparison := Comparison{Case: c.Name, Reference: c.Reference}
+		primaryResult, primaryResp := c.run(p)
+		secondaryResult, secondaryResp := c.run(s)
+		switch {
+		case primaryResp == nil:
+			comparison.Err = fmt.Errorf("primary server: %s", primaryResult.Err)
+		case secondaryResp == nil:
+			comparison.Err = fmt.Errorf("secondary server: %s", secondaryResult.Err)
+		default:
+			comparison.Differences = compareResponses(primaryResp, secondaryResp)
+		}
+		comparisons = append(comparisons, comparison)
+	}
+	return comparisons, nil
+}
+
+// compareResponses describes how the secondary response differs from the
+// primary response.
+func compareResponses(primary, secondary *response) []string {
+	var differences []string
+	if primary.status != secondary.status {
+		differences = append(differences, fmt.Sprintf(
+			"status %d, primary gave %d", secondary.status, primary.status))
+	}
+	if p, s := primary.problemType(), secondary.problemType(); p != s {
+		differences = append(differences, fmt.Sprintf(
+			"problem type %q, primary gave %q", s, p))
+	}
+	for _, h := range comparedHeaders {
+		p, s := primary.header.Get(h) != "", secondary.header.Get(h) != ""
+		if p && !s {
+			differences = append(differences, fmt.Sprintf("missing %s header", h))
+		} else if s && !p {
+			differences = append(differences, fmt.Sprintf("extra %s header", h))
+		}
+	}
+	primaryFields, secondaryFields := objectFields(primary), objectFields(secondary)
+	if missing := difference(primaryFields, secondaryFields); len(missing) > 0 {
+		differences = append(differences, fmt.Sprintf("missing fields %q", missing))
+	}
+	if extra := difference(secondaryFields, primaryFields); len(extra) > 0 {
+		differences = append(differences, fmt.Sprintf("extra fields %q", extra))
+	}
+	return differences
+}
+
+// objectFields returns the names of the fields of the JSON object in the
+// response body, or nil if the body isn't a JSON object.
+func objectFields(r *response) map[string]bool {
+	var object map[string]json.RawMessage
+	if json.Unmarshal(r.body, &object) != nil {
+		return nil
+	}
+	fields := make(map[string]bool, len(object))
+	for name := range object {
+		fields[name] = true
+	}
+	return fields
+}
+
+// difference returns the sorted names in a but not in b.
+func difference(a, b map[string]bool) []string {
+	var names []string
+	for name := range a {
+		if !b[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
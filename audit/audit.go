@@ -0,0 +1,171 @@
+// Package audit keeps an append-only log of security relevant events, like
+// account key changes and revocations, for threat-model testing. Each entry
+// includes the hash of the entry before it, so that changes to the log can be
+// detected.
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/logging"
+)
+
+const (
+	// TypeAccountCreated is recorded when an account is created.
+	TypeAccountCreated = "account-created"
+	// TypeAccountKeyChanged is recorded when an account's key is rolled
+	// over.
+	TypeAccountKeyChanged = "account-key-changed"
+	// TypeAccountDeactivated is recorded when an account is deactivated.
+	TypeAccountDeactivated = "account-deactivated"
+	// TypeCertificateRevoked is recorded when a certificate is revoked.
+	TypeCertificateRevoked = "certificate-revoked"
+	// TypePolicyRejection is recorded when an order or CSR is rejected by
+	// policy.
+	TypePolicyRejection = "policy-rejection"
+	// TypeManagement is recorded for management API requests that change
+	// Pebble's state.
+	TypeManagement = "management"
+)
+
+// Entry is an entry of the audit log. Its hash is the hex encoded SHA-256 of
+// its JSON encoding with an empty hash, and its previous hash is the hash of
+// the entry before it, or empty for the first entry.
+type Entry struct {
+	Seq  int    `json:"seq"`
+	Time string `json:"time"`
+	Type string `json:"type"`
+	// Actor is the account ID or management principal that caused the
+	// event, if known.
+	Actor    string            `json:"actor,omitempty"`
+	Details  map[string]string `json:"details,omitempty"`
+	PrevHash string            `json:"prevHash"`
+	Hash     string            `json:"hash"`
+}
+
+func (e Entry) computeHash() string {
+	e.Hash = ""
+	// Map keys are marshalled in sorted order, so the encoding of an entry
+	// is deterministic
+	data, _ := json.Marshal(e)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify checks that the entries form an unbroken hash chain, returning an
+// error describing the first entry that doesn't.
+func Verify(entries []Entry) error {
+	prevHash := ""
+	for i, e := range entries {
+		if i > 0 && e.Seq != entries[i-1].Seq+1 {
+			return fmt.Errorf("entry %d follows entry %d", e.Seq, entries[i-1].Seq)
+		}
+		if e.PrevHash != prevHash {
+			return fmt.Errorf("entry %d doesn't follow the entry before it", e.Seq)
+		}
+		if e.Hash != e.computeHash() {
+			return fmt.Errorf("entry %d has been changed", e.Seq)
+		}
+		prevHash = e.Hash
+	}
+	return nil
+}
+
+// Log is an append-only audit log, kept in memory and optionally in a file
+// with one JSON entry per line. A nil *Log records nothing, so callers don't
+// need to check whether auditing is enabled.
+type Log struct {
+	log logging.Logger
+	clk clock.Clock
+
+	sync.Mutex
+	file    *os.File
+	entries []Entry
+}
+
+// New returns an audit log kept in memory only.
+func New(log logging.Logger, clk clock.Clock) *Log {
+	return &Log{log: log, clk: clk}
+}
+
+// Open returns an audit log that is also appended to the file at path. If the
+// file already has entries they are verified and new entries continue their
+// chain.
+func Open(log logging.Logger, clk clock.Clock, path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("reading %s: %s", path, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("reading %s: %s", path, err)
+	}
+	if err := Verify(entries); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("verifying %s: %s", path, err)
+	}
+	log.Printf("Appending audit log entries to %s after %d existing entries", path, len(entries))
+	return &Log{log: log, clk: clk, file: file, entries: entries}, nil
+}
+
+// Record appends an entry to the log.
+func (l *Log) Record(eventType, actor string, details map[string]string) {
+	if l == nil {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+
+	e := Entry{
+		Seq:     len(l.entries) + 1,
+		Time:    l.clk.Now().UTC().Format(time.RFC3339Nano),
+		Type:    eventType,
+		Actor:   actor,
+		Details: details,
+	}
+	if len(l.entries) > 0 {
+		e.PrevHash = l.entries[len(l.entries)-1].Hash
+	}
+	e.Hash = e.computeHash()
+	l.entries = append(l.entries, e)
+
+	if l.file == nil {
+		return
+	}
+	line, err := json.Marshal(e)
+	if err == nil {
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		l.log.Printf("audit: error writing entry %d: %s\n", e.Seq, err)
+	}
+}
+
+// Entries returns the entries recorded so far.
+func (l *Log) Entries() []Entry {
+	if l == nil {
+		return nil
+	}
+	l.Lock()
+	defer l.Unlock()
+	return append([]Entry(nil), l.entries...)
+}
@@ -0,0 +1,53 @@
+package ca
+
+import (
+	"crypto/x509"
+	"strings"
+
+	"github.com/letsencrypt/pebble/core"
+)
+
+// aiaIssuerPlaceholder is replaced in AIA URLs with the ID of the issuer of
+// the certificate, the hex encoded serial of its certificate.
+const aiaIssuerPlaceholder = "{issuer}"
+
+// AIAConfig configures the Authority Information Access extension of issued
+// certificates. The URLs are used as-is, so they can be deliberately broken,
+// except that {issuer} is replaced with the ID of the issuing certificate.
+// Certificates have no AIA extension when both URLs are empty.
+type AIAConfig struct {
+	// IssuerURL is the CA Issuers URL, where the issuer certificate can be
+	// fetched from.
+	IssuerURL string
+	// OCSPURL is the OCSP responder URL.
+	OCSPURL string
+}
+
+// setAIA sets the AIA URLs of a certificate template issued by issuer.
+func (ca *CAImpl) setAIA(template *x509.Certificate, issuer *issuer) {
+	if ca.aia.IssuerURL != "" {
+		template.IssuingCertificateURL = []string{
+			strings.Replace(ca.aia.IssuerURL, aiaIssuerPlaceholder, issuer.cert.ID, -1),
+		}
+	}
+	if ca.aia.OCSPURL != "" {
+		template.OCSPServer = []string{
+			strings.Replace(ca.aia.OCSPURL, aiaIssuerPlaceholder, issuer.cert.ID, -1),
+		}
+	}
+}
+
+// recordIssuer remembers an issuer's certificate so that it can be served at
+// its CA Issuers URL after it has been rotated. The caller must hold the CA's
+// write lock.
+func (ca *CAImpl) recordIssuer(issuer *issuer) {
+	ca.issuersByID[issuer.cert.ID] = issuer.cert
+}
+
+// IssuerCertificate returns the certificate of the current or a past root or
+// intermediate with the given ID, or nil if there is none.
+func (ca *CAImpl) IssuerCertificate(id string) *core.Certificate {
+	ca.RLock()
+	defer ca.RUnlock()
+	return ca.issuersByID[id]
+}
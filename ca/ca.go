@@ -8,14 +8,20 @@ import (
 	"crypto/x509/pkix"
 	"encoding/hex"
 	"fmt"
-	"log"
 	"math"
 	"math/big"
+	"sync"
 	"time"
 
 	"github.com/letsencrypt/pebble/acme"
 	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/cryptomode"
 	"github.com/letsencrypt/pebble/db"
+	"github.com/letsencrypt/pebble/events"
+	"github.com/letsencrypt/pebble/logging"
+	"github.com/letsencrypt/pebble/publish"
+	"github.com/letsencrypt/pebble/timeouts"
+	"github.com/letsencrypt/pebble/webhook"
 )
 
 const (
@@ -23,12 +29,105 @@ const (
 	intermediateCAPrefix = "Pebble Intermediate CA "
 )
 
+// Config holds the optional CA behaviours that can be tuned from the Pebble
+// configuration file. The zero value is a CA with a single root and
+// intermediate.
+type Config struct {
+	// AlternateRoots is the number of additional root CAs to generate. The
+	// issuing intermediate is cross-signed by every root so each issued
+	// certificate can chain to any of them.
+	AlternateRoots int
+	// DefaultChain is the index of the root whose chain is served by default.
+	// Chains to the other roots are served as alternates.
+	DefaultChain int
+	// Notifier is sent an event for every issued certificate. It may be nil.
+	Notifier *webhook.Notifier
+	// Serials configures the serial numbers of issued certificates.
+	Serials SerialConfig
+	// CT configures the SCTs embedded in issued certificates.
+	CT CTConfig
+	// IssuanceDelays delay signing the certificates of matching orders.
+	IssuanceDelays []IssuanceDelay
+	// Redactions replace labels of the matching DNS names of issued
+	// certificates.
+	Redactions []Redaction
+	// MaxCSRExtensionBytes enables copying the extensions requested in CSRs
+	// into certificates, except for those the CA sets itself, as long as
+	// their values are no larger than this altogether.
+	MaxCSRExtensionBytes int
+	// AIA configures the Authority Information Access URLs of issued
+	// certificates.
+	AIA AIAConfig
+	// NotBeforeSkew and NotAfterSkew are added to the validity period of
+	// issued certificates to simulate clock drift between the CA and its
+	// clients. They may be negative.
+	NotBeforeSkew time.Duration
+	NotAfterSkew  time.Duration
+	// Events receives the valid status of orders once their certificate is
+	// issued. It may be nil.
+	Events *events.Broker
+	// Signer selects how the intermediates sign certificates.
+	Signer SignerConfig
+	// Lint configures the linting of certificates before they are issued.
+	Lint LintConfig
+	// Fixtures, if set, makes issuance deterministic.
+	Fixtures *FixtureConfig
+	// TimeoutCounter counts signatures that miss the signer's deadline and
+	// remote signer requests that time out. It may be nil.
+	TimeoutCounter *timeouts.Counter
+	// Publisher publishes issued certificates. It may be nil.
+	Publisher *publish.Publisher
+	// ConstrainedIntermediates are intermediates with name constraints that
+	// issue the certificates of the names they permit in place of the
+	// unconstrained intermediate.
+	ConstrainedIntermediates []ConstrainedIntermediate
+}
+
 type CAImpl struct {
-	log *log.Logger
+	log logging.Logger
 	db  *db.MemoryStore
 
-	root         *issuer
-	intermediate *issuer
+	// The chains are locked for writing while they are rotated and for reading
+	// while they are used for issuance.
+	sync.RWMutex
+	// chains holds one root and a cross-signed intermediate per root. All of
+	// the intermediates share the same subject and key, so a certificate issued
+	// by one of them chains to every root.
+	chains       []*chain
+	defaultChain int
+
+	notifier  *webhook.Notifier
+	publisher *publish.Publisher
+	events    *events.Broker
+	serials   *serialGenerator
+
+	ctLogs      []*CTLog
+	sctCount    int
+	sctStaleAge time.Duration
+
+	issuanceDelays []IssuanceDelay
+	redactions     []Redaction
+
+	maxCSRExtensionBytes int
+
+	aia AIAConfig
+
+	notBeforeSkew time.Duration
+	notAfterSkew  time.Duration
+	// issuersByID holds the certificates of every issuer the CA has had,
+	// keyed by their ID.
+	issuersByID map[string]*core.Certificate
+
+	signer         SignerConfig
+	signerStats    *signerStats
+	timeoutCounter *timeouts.Counter
+
+	lint LintConfig
+
+	// fixtures is the loaded Config.Fixtures, nil unless set.
+	fixtures *fixtures
+
+	constrained []ConstrainedIntermediate
 }
 
 type issuer struct {
@@ -36,8 +135,16 @@ type issuer struct {
 	cert *core.Certificate
 }
 
+type chain struct {
+	root         *issuer
+	intermediate *issuer
+	// constrained holds an intermediate signed by the root for each of the
+	// CA's constrained intermediates, in the same order.
+	constrained []*issuer
+}
+
 func makeSerial() *big.Int {
-	serial, err := rand.Int(rand.Reader, big.NewInt(math.MaxInt64))
+	serial, err := rand.Int(cryptomode.Reader(), big.NewInt(math.MaxInt64))
 	if err != nil {
 		panic(fmt.Sprintf("unable to create random serial number: %s", err.Error()))
 	}
@@ -49,7 +156,7 @@ func makeSerial() *big.Int {
 
 // makeKey creates a new 2048 bit RSA private key
 func makeKey() (*rsa.PrivateKey, error) {
-	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	key, err := rsa.GenerateKey(cryptomode.Reader(), 2048)
 	if err != nil {
 		return nil, err
 	}
@@ -58,32 +165,34 @@ func makeKey() (*rsa.PrivateKey, error) {
 
 func (ca *CAImpl) makeRootCert(
 	subjectKey crypto.Signer,
-	subjCNPrefix string,
-	signer *issuer) (*core.Certificate, error) {
+	subject pkix.Name,
+	signer *issuer,
+	constraints *ConstrainedIntermediate) (*core.Certificate, error) {
 
-	serial := makeSerial()
+	serial := ca.fixtures.caSerial(subject)
+	notBefore, notAfter := ca.fixtures.caValidity()
 	template := &x509.Certificate{
-		Subject: pkix.Name{
-			CommonName: subjCNPrefix + hex.EncodeToString(serial.Bytes()[:3]),
-		},
+		Subject:      subject,
 		SerialNumber: serial,
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().AddDate(30, 0, 0),
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
 
 		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
 		IsCA: true,
 	}
+	constraints.constrain(template)
 
-	var signerKey crypto.Signer
-	if signer != nil && signer.key != nil {
+	// Self-signed certificates are their own parent
+	parent := template
+	signerKey := subjectKey
+	if signer != nil && signer.key != nil && signer.cert != nil {
+		parent = signer.cert.Cert
 		signerKey = signer.key
-	} else {
-		signerKey = subjectKey
 	}
 
-	der, err := x509.CreateCertificate(rand.Reader, template, template, subjectKey.Public(), signerKey)
+	der, err := x509.CreateCertificate(cryptomode.Reader(), template, parent, subjectKey.Public(), signerKey)
 	if err != nil {
 		return nil, err
 	}
@@ -109,79 +218,281 @@ func (ca *CAImpl) makeRootCert(
 	return newCert, nil
 }
 
-func (ca *CAImpl) newRootIssuer() error {
-	// Make a root private key
-	rk, err := makeKey()
-	if err != nil {
-		return err
+// caName returns a subject name for a CA certificate made up of the given
+// prefix and a few random hex bytes.
+func caName(prefix string) pkix.Name {
+	return pkix.Name{
+		CommonName: prefix + hex.EncodeToString(makeSerial().Bytes()[:3]),
+	}
+}
+
+func (ca *CAImpl) newRootIssuer() (*issuer, error) {
+	// Make a root private key, unless the fixtures provide one
+	var rk crypto.Signer
+	if ca.fixtures != nil && ca.fixtures.rootKey != nil {
+		rk = ca.fixtures.rootKey
+	} else {
+		key, err := makeKey()
+		if err != nil {
+			return nil, err
+		}
+		rk = key
 	}
 	// Make a self-signed root certificate
-	rc, err := ca.makeRootCert(rk, rootCAPrefix, nil)
+	rc, err := ca.makeRootCert(rk, ca.fixtures.caName(rootCAPrefix), nil, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	ca.root = &issuer{
+	ca.log.Printf("Generated new root issuer with serial %s\n", rc.ID)
+	root := &issuer{
 		key:  rk,
 		cert: rc,
 	}
-	ca.log.Printf("Generated new root issuer with serial %s\n", rc.ID)
-	return nil
+	ca.recordIssuer(root)
+	return root, nil
 }
 
-func (ca *CAImpl) newIntermediateIssuer() error {
-	if ca.root == nil {
-		return fmt.Errorf("newIntermediateIssuer() called before newRootIssuer()")
+func (ca *CAImpl) newIntermediateIssuer(
+	root *issuer,
+	ik crypto.Signer,
+	subject pkix.Name,
+	constraints *ConstrainedIntermediate) (*issuer, error) {
+	if root == nil {
+		return nil, fmt.Errorf("newIntermediateIssuer() called with a nil root")
 	}
 
-	// Make an intermediate private key
-	ik, err := makeKey()
+	// Make an intermediate certificate with the root issuer
+	ic, err := ca.makeRootCert(ik, subject, root, constraints)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	ca.log.Printf("Generated new intermediate issuer with serial %s signed by root %s\n",
+		ic.ID, root.cert.ID)
+	intermediate := &issuer{
+		key:  ik,
+		cert: ic,
 	}
+	ca.recordIssuer(intermediate)
+	return intermediate, nil
+}
 
-	// Make an intermediate certificate with the root issuer
-	ic, err := ca.makeRootCert(ik, intermediateCAPrefix, ca.root)
+// newChains creates the configured number of roots along with an intermediate
+// cross-signed by each of them. The caller must hold the CA's write lock.
+func (ca *CAImpl) newChains(numRoots int) error {
+	var roots []*issuer
+	for i := 0; i < numRoots; i++ {
+		root, err := ca.newRootIssuer()
+		if err != nil {
+			return err
+		}
+		roots = append(roots, root)
+	}
+	return ca.newIntermediates(roots)
+}
+
+// newIntermediates creates a new intermediate key and subject and an
+// intermediate certificate for it signed by each of the given roots, and the
+// same for each constrained intermediate. The caller must hold the CA's write
+// lock.
+func (ca *CAImpl) newIntermediates(roots []*issuer) error {
+	// Make an intermediate private key and subject shared by every chain
+	ik, err := ca.newIntermediateKey()
 	if err != nil {
 		return err
 	}
-	ca.intermediate = &issuer{
-		key:  ik,
-		cert: ic,
+	subject := ca.fixtures.caName(intermediateCAPrefix)
+
+	var chains []*chain
+	for _, root := range roots {
+		intermediate, err := ca.newIntermediateIssuer(root, ik, subject, nil)
+		if err != nil {
+			return err
+		}
+		chains = append(chains, &chain{
+			root:         root,
+			intermediate: intermediate,
+		})
 	}
-	ca.log.Printf("Generated new intermediate issuer with serial %s\n", ic.ID)
+
+	for i := range ca.constrained {
+		constraints := &ca.constrained[i]
+		ck, err := ca.newIntermediateKey()
+		if err != nil {
+			return err
+		}
+		subject := ca.fixtures.caName(intermediateCAPrefix + constraints.Name + " ")
+		for _, c := range chains {
+			intermediate, err := ca.newIntermediateIssuer(c.root, ck, subject, constraints)
+			if err != nil {
+				return err
+			}
+			c.constrained = append(c.constrained, intermediate)
+		}
+	}
+	ca.chains = chains
 	return nil
 }
 
-func (ca *CAImpl) newCertificate(domains []string, key crypto.PublicKey, accountID string) (*core.Certificate, error) {
-	var cn string
-	if len(domains) > 0 {
-		cn = domains[0]
-	} else {
-		return nil, fmt.Errorf("must specify at least one domain name")
+// RotateIssuers replaces the issuing intermediate, and the roots too if
+// includeRoots is true, with newly generated ones. Certificates issued before
+// the rotation keep chaining to the issuers that signed them.
+func (ca *CAImpl) RotateIssuers(includeRoots bool) error {
+	ca.Lock()
+	defer ca.Unlock()
+
+	if includeRoots {
+		ca.log.Printf("Rotating root and intermediate issuers\n")
+		return ca.newChains(len(ca.chains))
 	}
 
-	issuer := ca.intermediate
-	if issuer == nil || issuer.cert == nil {
-		return nil, fmt.Errorf("cannot sign certificate - nil issuer")
+	ca.log.Printf("Rotating intermediate issuers\n")
+	var roots []*issuer
+	for _, c := range ca.chains {
+		roots = append(roots, c.root)
 	}
+	return ca.newIntermediates(roots)
+}
+
+// issuers returns the default chain's intermediate followed by the
+// intermediates of the alternate chains. If there are constrained
+// intermediates, those of the first one permitting all the names are returned
+// instead.
+func (ca *CAImpl) issuers(names []string) (*issuer, []*core.Certificate, error) {
+	constrained, err := ca.constrainedIssuer(names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ca.RLock()
+	defer ca.RUnlock()
+
+	intermediate := func(c *chain) *issuer {
+		if constrained >= 0 {
+			return c.constrained[constrained]
+		}
+		return c.intermediate
+	}
+	var alternates []*core.Certificate
+	for i, c := range ca.chains {
+		if i != ca.defaultChain {
+			alternates = append(alternates, intermediate(c).cert)
+		}
+	}
+	return intermediate(ca.chains[ca.defaultChain]), alternates, nil
+}
+
+// certificateTemplate returns the template of a certificate for the names,
+// with cn as its common name, signed by issuer.
+func (ca *CAImpl) certificateTemplate(
+	cn string,
+	domains []string,
+	permanentIDs []string,
+	tnAuthList []byte,
+	extensions []pkix.Extension,
+	key crypto.PublicKey,
+	serial *big.Int,
+	issuer *issuer) (*x509.Certificate, error) {
+	// Key encipherment only makes sense for RSA subscriber keys. ECDSA and
+	// Ed25519 keys are only used for signatures.
+	keyUsage := x509.KeyUsageDigitalSignature
+	if _, isRSA := key.(*rsa.PublicKey); isRSA {
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	notBefore, notAfter := ca.fixtures.certValidity()
 
-	serial := makeSerial()
 	template := &x509.Certificate{
 		DNSNames: domains,
 		Subject: pkix.Name{
 			CommonName: cn,
 		},
 		SerialNumber: serial,
-		NotBefore:    time.Now(),
-		NotAfter:     time.Now().AddDate(5, 0, 0),
+		NotBefore:    notBefore.Add(ca.notBeforeSkew),
+		NotAfter:     notAfter.Add(ca.notAfterSkew),
 
-		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		KeyUsage:              keyUsage,
 		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
 		BasicConstraintsValid: true,
 		IsCA: false,
 	}
-	der, err := x509.CreateCertificate(rand.Reader, template, issuer.cert.Cert, key, issuer.key)
+	// The x509 package can't encode permanent identifiers so the subject
+	// alternative name extension is built by hand when there are any.
+	if len(permanentIDs) > 0 {
+		sanExt, err := subjectAltNameExtension(domains, permanentIDs)
+		if err != nil {
+			return nil, err
+		}
+		template.DNSNames = nil
+		template.ExtraExtensions = append(template.ExtraExtensions, sanExt)
+	}
+	// STIR/SHAKEN certificates carry the TNAuthList they were authorized for
+	// (RFC 8226)
+	if tnAuthList != nil {
+		template.ExtraExtensions = append(template.ExtraExtensions, pkix.Extension{
+			Id:    oidTNAuthList,
+			Value: tnAuthList,
+		})
+	}
+	template.ExtraExtensions = append(template.ExtraExtensions, extensions...)
+	ca.setAIA(template, issuer)
+	ca.injectLintViolations(template)
+	return template, nil
+}
+
+func (ca *CAImpl) newCertificate(
+	domains []string,
+	permanentIDs []string,
+	tnAuthList []byte,
+	extensions []pkix.Extension,
+	key crypto.PublicKey,
+	accountID string) (*core.Certificate, error) {
+	domains = ca.redactNames(domains)
+	var cn string
+	if len(domains) > 0 {
+		cn = domains[0]
+	} else if len(permanentIDs) == 0 && tnAuthList == nil {
+		return nil, fmt.Errorf("must specify at least one domain name, permanent identifier or TNAuthList")
+	}
+
+	issuer, alternates, err := ca.issuers(domains)
+	if err != nil {
+		return nil, err
+	}
+	if issuer == nil || issuer.cert == nil {
+		return nil, fmt.Errorf("cannot sign certificate - nil issuer")
+	}
+
+	serial := ca.serials.next()
+	if ca.fixtures != nil && ca.fixtures.serial != nil {
+		serial = new(big.Int).Set(ca.fixtures.serial)
+	}
+	template, err := ca.certificateTemplate(
+		cn, domains, permanentIDs, tnAuthList, extensions, key, serial, issuer)
+	if err != nil {
+		return nil, err
+	}
+	// Embedded SCTs sign the precertificate TBSCertificate, which is the
+	// certificate's TBSCertificate without the SCT list extension. Since the
+	// extension is appended last, that is the TBSCertificate of the same
+	// template without it.
+	if ca.sctCount > 0 {
+		precert, err := x509.CreateCertificate(cryptomode.Reader(), template, issuer.cert.Cert, key, issuer.key)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := x509.ParseCertificate(precert)
+		if err != nil {
+			return nil, err
+		}
+		sctExt, err := sctListExtension(ca.ctLogs[:ca.sctCount], parsed.RawTBSCertificate,
+			issuer.cert.Cert, time.Now().Add(-ca.sctStaleAge))
+		if err != nil {
+			return nil, err
+		}
+		template.ExtraExtensions = append(template.ExtraExtensions, sctExt)
+	}
+	der, err := x509.CreateCertificate(cryptomode.Reader(), template, issuer.cert.Cert, key, issuer.key)
 	if err != nil {
 		return nil, err
 	}
@@ -189,6 +500,9 @@ func (ca *CAImpl) newCertificate(domains []string, key crypto.PublicKey, account
 	if err != nil {
 		return nil, err
 	}
+	if err := ca.lintCertificate(cert); err != nil {
+		return nil, err
+	}
 
 	hexSerial := hex.EncodeToString(cert.SerialNumber.Bytes())
 	newCert := &core.Certificate{
@@ -197,30 +511,158 @@ func (ca *CAImpl) newCertificate(domains []string, key crypto.PublicKey, account
 		Cert:      cert,
 		DER:       der,
 		Issuer:    issuer.cert,
-	}
-	_, err = ca.db.AddCertificate(newCert)
-	if err != nil {
-		return nil, err
+
+		AlternateIssuers: alternates,
 	}
 	return newCert, nil
 }
 
-func New(log *log.Logger, db *db.MemoryStore) *CAImpl {
+func New(log logging.Logger, db *db.MemoryStore, config Config) *CAImpl {
 	ca := &CAImpl{
-		log: log,
-		db:  db,
+		log:       log,
+		db:        db,
+		notifier:  config.Notifier,
+		publisher: config.Publisher,
+		events:    config.Events,
+	}
+
+	numRoots := 1 + config.AlternateRoots
+	if config.DefaultChain < 0 || config.DefaultChain >= numRoots {
+		panic(fmt.Sprintf("Default chain %d out of range for %d roots", config.DefaultChain, numRoots))
+	}
+	ca.defaultChain = config.DefaultChain
+
+	fixtures, err := newFixtures(config.Fixtures)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid certificate fixtures: %s", err.Error()))
+	}
+	ca.fixtures = fixtures
+	if ca.fixtures != nil && ca.fixtures.intermediateKey != nil && config.Signer.Type == SignerRemote {
+		panic("Certificate fixtures can't provide an intermediate key for a remote signer")
 	}
-	err := ca.newRootIssuer()
+	if ca.fixtures != nil && config.Serials.Scheme == "" {
+		config.Serials.Scheme = SerialSequential
+	}
+	if ca.fixtures != nil {
+		log.Printf("Issuing deterministic certificates from fixtures")
+	}
+
+	serials, err := newSerialGenerator(config.Serials)
 	if err != nil {
-		panic(fmt.Sprintf("Error creating new root issuer: %s", err.Error()))
+		panic(fmt.Sprintf("Invalid serial config: %s", err.Error()))
 	}
-	err = ca.newIntermediateIssuer()
+	ca.serials = serials
+	if config.Serials.Scheme == SerialColliding {
+		db.AllowSerialCollisions()
+		log.Printf("Issuing certificates with deliberately colliding serials")
+	}
+	if ca.fixtures != nil && ca.fixtures.serial != nil {
+		db.AllowSerialCollisions()
+	}
+
+	ca.ctLogs, err = newCTLogs(config.CT)
 	if err != nil {
-		panic(fmt.Sprintf("Error creating new intermediate issuer: %s", err.Error()))
+		panic(fmt.Sprintf("Invalid CT config: %s", err.Error()))
+	}
+	ca.sctCount = config.CT.SCTs
+	ca.sctStaleAge = config.CT.StaleAge
+
+	for _, d := range config.IssuanceDelays {
+		if err := d.check(); err != nil {
+			panic(fmt.Sprintf("Invalid issuance delay: %s", err.Error()))
+		}
+	}
+	ca.issuanceDelays = config.IssuanceDelays
+	for _, r := range config.Redactions {
+		if err := r.check(); err != nil {
+			panic(fmt.Sprintf("Invalid redaction: %s", err.Error()))
+		}
+	}
+	ca.redactions = config.Redactions
+	ca.maxCSRExtensionBytes = config.MaxCSRExtensionBytes
+	ca.aia = config.AIA
+	ca.notBeforeSkew = config.NotBeforeSkew
+	ca.notAfterSkew = config.NotAfterSkew
+	ca.issuersByID = make(map[string]*core.Certificate)
+
+	if err := config.Signer.check(); err != nil {
+		panic(fmt.Sprintf("Invalid signer config: %s", err.Error()))
+	}
+	ca.signer = config.Signer
+	ca.signerStats = new(signerStats)
+	ca.timeoutCounter = config.TimeoutCounter
+
+	if err := config.Lint.check(); err != nil {
+		panic(fmt.Sprintf("Invalid lint config: %s", err.Error()))
+	}
+	ca.lint = config.Lint
+
+	for _, c := range config.ConstrainedIntermediates {
+		if err := c.check(); err != nil {
+			panic(fmt.Sprintf("Invalid constrained intermediate: %s", err.Error()))
+		}
+	}
+	ca.constrained = config.ConstrainedIntermediates
+
+	err = ca.newChains(numRoots)
+	if err != nil {
+		panic(fmt.Sprintf("Error creating new root and intermediate issuers: %s", err.Error()))
 	}
 	return ca
 }
 
+// CTLogs returns the simulated CT logs whose SCTs are embedded in issued
+// certificates.
+func (ca *CAImpl) CTLogs() []*CTLog {
+	return ca.ctLogs
+}
+
+// NumberOfChains returns the number of root/intermediate chains the CA has.
+func (ca *CAImpl) NumberOfChains() int {
+	ca.RLock()
+	defer ca.RUnlock()
+	return len(ca.chains)
+}
+
+// GetRootCert returns the root certificate of the chain with the given index,
+// or nil if there is no such chain.
+func (ca *CAImpl) GetRootCert(no int) *core.Certificate {
+	ca.RLock()
+	defer ca.RUnlock()
+	if no < 0 || no >= len(ca.chains) {
+		return nil
+	}
+	return ca.chains[no].root.cert
+}
+
+// GetIntermediateCert returns the intermediate certificate of the chain with
+// the given index, or nil if there is no such chain.
+func (ca *CAImpl) GetIntermediateCert(no int) *core.Certificate {
+	ca.RLock()
+	defer ca.RUnlock()
+	if no < 0 || no >= len(ca.chains) {
+		return nil
+	}
+	return ca.chains[no].intermediate.cert
+}
+
+// GetChain returns the intermediate and root certificates of the chain with
+// the given index, or nil if there is no such chain.
+func (ca *CAImpl) GetChain(no int) []*core.Certificate {
+	ca.RLock()
+	defer ca.RUnlock()
+	if no < 0 || no >= len(ca.chains) {
+		return nil
+	}
+	return []*core.Certificate{ca.chains[no].intermediate.cert, ca.chains[no].root.cert}
+}
+
+// DefaultChain returns the index of the chain served by default for issued
+// certificates.
+func (ca *CAImpl) DefaultChain() int {
+	return ca.defaultChain
+}
+
 func (ca *CAImpl) CompleteOrder(order *core.Order) {
 	// Lock the order for reading
 	order.RLock()
@@ -231,6 +673,13 @@ func (ca *CAImpl) CompleteOrder(order *core.Order) {
 		order.RUnlock()
 		return
 	}
+	// If the order already has a certificate don't issue another one
+	if order.CertificateObject != nil {
+		ca.log.Printf("Error: Asked to complete order %s which already has a certificate.",
+			order.ID)
+		order.RUnlock()
+		return
+	}
 	// Unlock the order again
 	order.RUnlock()
 
@@ -239,23 +688,79 @@ func (ca *CAImpl) CompleteOrder(order *core.Order) {
 	for _, authz := range order.AuthorizationObjects {
 		// Lock the authorization for reading
 		authz.RLock()
-		if authz.Status != acme.StatusValid {
+		authzStatus := authz.Status
+		authz.RUnlock()
+		if authzStatus != acme.StatusValid {
 			return
 		}
-		authz.RUnlock()
 	}
 
 	// issue a certificate for the csr
 	csr := order.ParsedCSR
-	cert, err := ca.newCertificate(csr.DNSNames, csr.PublicKey, order.AccountID)
+	order.RLock()
+	permanentIDs := order.PermanentIDs
+	tnAuthList := order.TNAuthList
+	var identValues []string
+	for _, ident := range order.Identifiers {
+		identValues = append(identValues, ident.Value)
+	}
+	order.RUnlock()
+	if delay := ca.issuanceDelay(identValues); delay > 0 {
+		ca.log.Printf("Delaying issuance for order %s by %s", order.ID, delay)
+		time.Sleep(delay)
+	}
+	// Orders cancelled or made invalid while they were processing don't get
+	// a certificate
+	order.RLock()
+	orderErr := order.Error
+	order.RUnlock()
+	if orderErr != nil {
+		ca.log.Printf("Not issuing a certificate for invalid order %s", order.ID)
+		return
+	}
+	extensions, err := ca.CSRExtensions(csr)
+	if err != nil {
+		ca.log.Printf("Error: unable to issue order: %s", err.Error())
+		return
+	}
+	cert, err := ca.newCertificate(
+		csr.DNSNames, permanentIDs, tnAuthList, extensions, csr.PublicKey, order.AccountID)
+	_, lintFailed := err.(*lintError)
+	_, missedDeadline := err.(signingDeadlineError)
+	_, unconstrained := err.(constrainedNamesError)
+	if lintFailed || missedDeadline || unconstrained {
+		// Orders whose certificate fails linting, misses the signing deadline
+		// or has names no constrained intermediate permits become invalid
+		// rather than staying in processing
+		ca.log.Printf("Error: unable to issue order %s: %s", order.ID, err.Error())
+		order.Lock()
+		order.Error = acme.InternalErrorProblem(err.Error())
+		order.Unlock()
+		ca.events.Publish(events.TypeOrder, order.ID, acme.StatusInvalid, "")
+		return
+	}
 	if err != nil {
 		ca.log.Printf("Error: unable to issue order: %s", err.Error())
 		return
 	}
-	ca.log.Printf("Issued certificate serial %s for order %s\n", cert.ID, order.ID)
 
-	// Lock and update the order to store the issued certificate
-	order.Lock()
-	order.CertificateObject = cert
-	order.Unlock()
+	// Store the certificate and update the order together, so the order is
+	// never seen valid without its certificate or the other way around
+	err = ca.db.Batch(func(tx db.Tx) error {
+		if _, err := tx.AddCertificate(cert); err != nil {
+			return err
+		}
+		order.Lock()
+		order.CertificateObject = cert
+		order.Unlock()
+		return nil
+	})
+	if err != nil {
+		ca.log.Printf("Error: unable to store certificate for order %s: %s", order.ID, err.Error())
+		return
+	}
+	ca.log.Printf("Issued certificate serial %s for order %s\n", cert.ID, order.ID)
+	ca.notifier.Notify(webhook.EventIssued, cert)
+	ca.publisher.Publish(cert)
+	ca.events.Publish(events.TypeOrder, order.ID, acme.StatusValid, "")
 }
@@ -0,0 +1,110 @@
+package ca
+
+import (
+	"crypto/x509"
+	"fmt"
+	"strings"
+)
+
+// A ConstrainedIntermediate is an additional intermediate whose certificate
+// carries X.509 name constraints. When any are configured, each certificate
+// is issued by the first constrained intermediate that permits all of its DNS
+// names, and orders with names none of them permits are rejected. One with no
+// constraints permits every name.
+type ConstrainedIntermediate struct {
+	// Name is added to the intermediate's subject to tell them apart.
+	Name string
+	// PermittedDNSDomains and ExcludedDNSDomains are the dNSName constraints
+	// of the intermediate. A domain matches itself and its subdomains, or
+	// only its subdomains if it starts with a ".".
+	PermittedDNSDomains []string
+	ExcludedDNSDomains  []string
+}
+
+func (c ConstrainedIntermediate) check() error {
+	if c.Name == "" {
+		return fmt.Errorf("constrained intermediate has no name")
+	}
+	for _, domains := range [][]string{c.PermittedDNSDomains, c.ExcludedDNSDomains} {
+		for _, d := range domains {
+			if strings.Trim(d, ".") == "" || strings.Contains(d, "*") {
+				return fmt.Errorf("constrained intermediate %q has invalid domain %q", c.Name, d)
+			}
+		}
+	}
+	return nil
+}
+
+// constrain adds the name constraints to an intermediate's certificate
+// template. Name constraints must be critical (RFC 5280 section 4.2.1.10).
+func (c *ConstrainedIntermediate) constrain(template *x509.Certificate) {
+	if c == nil || (len(c.PermittedDNSDomains) == 0 && len(c.ExcludedDNSDomains) == 0) {
+		return
+	}
+	template.PermittedDNSDomainsCritical = true
+	template.PermittedDNSDomains = c.PermittedDNSDomains
+	template.ExcludedDNSDomains = c.ExcludedDNSDomains
+}
+
+// permits returns true if every name is within the permitted domains, if
+// there are any, and outside the excluded domains. Wildcard names are matched
+// like any other name, so "*.example.com" is within ".example.com".
+func (c ConstrainedIntermediate) permits(names []string) bool {
+	for _, name := range names {
+		if len(c.PermittedDNSDomains) > 0 && !matchesDomainConstraint(name, c.PermittedDNSDomains) {
+			return false
+		}
+		if matchesDomainConstraint(name, c.ExcludedDNSDomains) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchesDomainConstraint(name string, domains []string) bool {
+	name = strings.ToLower(name)
+	for _, d := range domains {
+		d = strings.ToLower(d)
+		if strings.HasPrefix(d, ".") {
+			if strings.HasSuffix(name, d) {
+				return true
+			}
+		} else if name == d || strings.HasSuffix(name, "."+d) {
+			return true
+		}
+	}
+	return false
+}
+
+// constrainedNamesError is returned when no constrained intermediate permits
+// the names of a certificate.
+type constrainedNamesError struct {
+	names []string
+}
+
+func (e constrainedNamesError) Error() string {
+	return fmt.Sprintf("no constrained intermediate permits the names %s",
+		strings.Join(e.names, ", "))
+}
+
+// constrainedIssuer returns the index of the first constrained intermediate
+// that permits all the names, or -1 if there are no constrained intermediates.
+func (ca *CAImpl) constrainedIssuer(names []string) (int, error) {
+	if len(ca.constrained) == 0 {
+		return -1, nil
+	}
+	for i, c := range ca.constrained {
+		if c.permits(names) {
+			return i, nil
+		}
+	}
+	return -1, constrainedNamesError{names}
+}
+
+// CheckConstrainedNames returns an error if there are constrained
+// intermediates and none of them permits all of the DNS names, as they will
+// appear in the certificate.
+func (ca *CAImpl) CheckConstrainedNames(names []string) error {
+	_, err := ca.constrainedIssuer(ca.redactNames(names))
+	return err
+}
@@ -0,0 +1,50 @@
+package ca
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+// Extensions under these arcs are set by the CA and never copied from CSRs:
+// the id-ce certificate extensions (RFC 5280), the id-pe private extensions
+// (RFC 5280 Section 4.2.2) and Certificate Transparency's extensions.
+var reservedExtensionArcs = []asn1.ObjectIdentifier{
+	{2, 5, 29},
+	{1, 3, 6, 1, 5, 5, 7, 1},
+	{1, 3, 6, 1, 4, 1, 11129, 2, 4},
+}
+
+// CSRExtensions returns the extensions requested in the CSR that are copied
+// into its certificate: those outside the reserved arcs, when MaxCSRExtensionBytes
+// is set. An error is returned if their values are larger than
+// MaxCSRExtensionBytes altogether.
+func (ca *CAImpl) CSRExtensions(csr *x509.CertificateRequest) ([]pkix.Extension, error) {
+	if ca.maxCSRExtensionBytes == 0 {
+		return nil, nil
+	}
+	var copied []pkix.Extension
+	var size int
+	for _, ext := range csr.Extensions {
+		if reservedExtension(ext.Id) {
+			continue
+		}
+		size += len(ext.Value)
+		if size > ca.maxCSRExtensionBytes {
+			return nil, fmt.Errorf("CSR extensions are larger than the maximum of %d bytes",
+				ca.maxCSRExtensionBytes)
+		}
+		copied = append(copied, ext)
+	}
+	return copied, nil
+}
+
+func reservedExtension(id asn1.ObjectIdentifier) bool {
+	for _, arc := range reservedExtensionArcs {
+		if len(id) > len(arc) && id[:len(arc)].Equal(arc) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/letsencrypt/pebble/cryptomode"
+)
+
+// oidSCTList is the OID of the embedded SCT list extension (RFC 6962 Section
+// 3.3).
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// CTConfig configures the Signed Certificate Timestamps (RFC 6962) embedded
+// in issued certificates. The logs are simulated: Pebble signs the SCTs itself
+// and nothing is submitted anywhere. The zero value embeds no SCTs.
+type CTConfig struct {
+	// Logs are the simulated logs. If empty but SCTs is set, SCTs logs are
+	// made up.
+	Logs []CTLogConfig
+	// SCTs is the number of SCTs embedded in each certificate, each from a
+	// different log. Zero embeds no SCTs.
+	SCTs int
+	// StaleAge makes the SCTs stale by dating them this long before the
+	// certificate was issued.
+	StaleAge time.Duration
+}
+
+// CTLogConfig describes a simulated log.
+type CTLogConfig struct {
+	Description string
+	URL         string
+}
+
+// CTLog is a simulated log with its signing key.
+type CTLog struct {
+	CTLogConfig
+	// ID is the SHA-256 hash of the log's DER encoded public key.
+	ID  [sha256.Size]byte
+	key *ecdsa.PrivateKey
+}
+
+// PublicKey returns the DER encoded public key of the log.
+func (l *CTLog) PublicKey() []byte {
+	der, _ := x509.MarshalPKIXPublicKey(l.key.Public())
+	return der
+}
+
+func newCTLogs(config CTConfig) ([]*CTLog, error) {
+	logConfigs := config.Logs
+	if len(logConfigs) == 0 {
+		for i := 0; i < config.SCTs; i++ {
+			logConfigs = append(logConfigs, CTLogConfig{
+				Description: fmt.Sprintf("Pebble simulated log %d", i),
+				URL:         fmt.Sprintf("https://ct.pebble.invalid/log%d/", i),
+			})
+		}
+	}
+	if config.SCTs > len(logConfigs) {
+		return nil, fmt.Errorf("%d SCTs need at least as many logs, there are %d",
+			config.SCTs, len(logConfigs))
+	}
+
+	var logs []*CTLog
+	for _, lc := range logConfigs {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), cryptomode.Reader())
+		if err != nil {
+			return nil, err
+		}
+		log := &CTLog{CTLogConfig: lc, key: key}
+		log.ID = sha256.Sum256(log.PublicKey())
+		logs = append(logs, log)
+	}
+	return logs, nil
+}
+
+// sctListExtension returns the embedded SCT list extension for a certificate
+// whose precertificate TBSCertificate is tbs, with an SCT from each of the
+// logs.
+func sctListExtension(
+	logs []*CTLog,
+	tbs []byte,
+	issuer *x509.Certificate,
+	timestamp time.Time) (pkix.Extension, error) {
+	issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	millis := uint64(timestamp.UnixNano() / int64(time.Millisecond))
+
+	var list bytes.Buffer
+	for _, log := range logs {
+		sct, err := log.sign(millis, issuerKeyHash, tbs)
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		writeUint16Prefixed(&list, sct)
+	}
+	var sctList bytes.Buffer
+	writeUint16Prefixed(&sctList, list.Bytes())
+
+	value, err := asn1.Marshal(sctList.Bytes())
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidSCTList, Value: value}, nil
+}
+
+// sign returns a serialized v1 SCT for a precertificate entry.
+func (l *CTLog) sign(millis uint64, issuerKeyHash [sha256.Size]byte, tbs []byte) ([]byte, error) {
+	const (
+		sctVersion          = 0
+		signatureTypeSCT    = 0
+		entryTypePrecert    = 1
+		hashAlgorithmSHA256 = 4
+		sigAlgorithmECDSA   = 3
+	)
+
+	// The signed data of RFC 6962 Section 3.2 with no extensions
+	var signed bytes.Buffer
+	signed.WriteByte(sctVersion)
+	signed.WriteByte(signatureTypeSCT)
+	_ = binary.Write(&signed, binary.BigEndian, millis)
+	_ = binary.Write(&signed, binary.BigEndian, uint16(entryTypePrecert))
+	signed.Write(issuerKeyHash[:])
+	signed.Write([]byte{byte(len(tbs) >> 16), byte(len(tbs) >> 8), byte(len(tbs))})
+	signed.Write(tbs)
+	_ = binary.Write(&signed, binary.BigEndian, uint16(0))
+
+	digest := sha256.Sum256(signed.Bytes())
+	sig, err := l.key.Sign(cryptomode.Reader(), digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	var sct bytes.Buffer
+	sct.WriteByte(sctVersion)
+	sct.Write(l.ID[:])
+	_ = binary.Write(&sct, binary.BigEndian, millis)
+	_ = binary.Write(&sct, binary.BigEndian, uint16(0))
+	sct.WriteByte(hashAlgorithmSHA256)
+	sct.WriteByte(sigAlgorithmECDSA)
+	writeUint16Prefixed(&sct, sig)
+	return sct.Bytes(), nil
+}
+
+func writeUint16Prefixed(buf *bytes.Buffer, data []byte) {
+	_ = binary.Write(buf, binary.BigEndian, uint16(len(data)))
+	buf.Write(data)
+}
@@ -0,0 +1,42 @@
+package ca
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// An IssuanceDelay delays signing the certificates of orders with an
+// identifier matching Pattern, simulating a slow CA for particular names.
+type IssuanceDelay struct {
+	// Pattern is a pattern (see the pattern package) matched against the
+	// values of the order's identifiers.
+	Pattern string
+	// Delay is how long signing is delayed for.
+	Delay time.Duration
+}
+
+func (d IssuanceDelay) check() error {
+	if err := pattern.Check(d.Pattern); err != nil {
+		return fmt.Errorf("issuance delay: %s", err)
+	}
+	if d.Delay < 0 {
+		return fmt.Errorf("issuance delay for %q must not be negative", d.Pattern)
+	}
+	return nil
+}
+
+// issuanceDelay returns the longest delay matching any of the identifier
+// values, or zero if none match.
+func (ca *CAImpl) issuanceDelay(values []string) time.Duration {
+	var longest time.Duration
+	for _, d := range ca.issuanceDelays {
+		for _, v := range values {
+			if pattern.Match(d.Pattern, v) && d.Delay > longest {
+				longest = d.Delay
+			}
+		}
+	}
+	return longest
+}
@@ -0,0 +1,166 @@
+package ca
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fixtureEpoch is the default notBefore of certificates made from fixtures.
+var fixtureEpoch = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// FixtureConfig makes issuance deterministic, so that the same CSRs give
+// byte-identical certificates in every run of Pebble, for golden certificates
+// in snapshot tests. Root and intermediate names and serials are derived from
+// the order they are created in, every certificate has the same validity
+// period, and issued certificates get sequential serials unless another
+// scheme or a fixed serial is configured.
+type FixtureConfig struct {
+	// RootKeyFile and IntermediateKeyFile are PEM files holding the private
+	// keys of the roots and the intermediates, in PKCS #8, PKCS #1 or SEC 1
+	// form. Keys are generated if they are empty, so the signatures of
+	// certificates differ between runs. RSA and Ed25519 keys sign
+	// deterministically but ECDSA signatures differ every time.
+	RootKeyFile         string
+	IntermediateKeyFile string
+	// NotBefore is the notBefore of every certificate, including roots and
+	// intermediates. It defaults to 2020-01-01T00:00:00Z.
+	NotBefore time.Time
+	// Validity is how long issued certificates are valid for. It defaults to
+	// the 30 years of the roots and intermediates, so that certificates with
+	// the default NotBefore don't expire.
+	Validity time.Duration
+	// Serial, if not empty, is the hex encoded serial of every issued
+	// certificate.
+	Serial string
+}
+
+// fixtures is a loaded FixtureConfig.
+type fixtures struct {
+	rootKey         crypto.Signer
+	intermediateKey crypto.Signer
+	notBefore       time.Time
+	validity        time.Duration
+	serial          *big.Int
+
+	sync.Mutex
+	// issuers counts the root and intermediate names created.
+	issuers int
+}
+
+func newFixtures(config *FixtureConfig) (*fixtures, error) {
+	if config == nil {
+		return nil, nil
+	}
+	f := &fixtures{
+		notBefore: config.NotBefore,
+		validity:  config.Validity,
+	}
+	if f.notBefore.IsZero() {
+		f.notBefore = fixtureEpoch
+	}
+	if f.validity < 0 {
+		return nil, fmt.Errorf("validity must be >= 0")
+	}
+	if config.Serial != "" {
+		serial, ok := new(big.Int).SetString(strings.TrimPrefix(config.Serial, "0x"), 16)
+		if !ok || serial.Sign() <= 0 || len(serial.Bytes()) > maxSerialLength {
+			return nil, fmt.Errorf("serial %q isn't a positive hex number of at most %d bytes",
+				config.Serial, maxSerialLength)
+		}
+		f.serial = serial
+	}
+	var err error
+	if config.RootKeyFile != "" {
+		if f.rootKey, err = loadSigner(config.RootKeyFile); err != nil {
+			return nil, fmt.Errorf("root key: %s", err)
+		}
+	}
+	if config.IntermediateKeyFile != "" {
+		if f.intermediateKey, err = loadSigner(config.IntermediateKeyFile); err != nil {
+			return nil, fmt.Errorf("intermediate key: %s", err)
+		}
+	}
+	return f, nil
+}
+
+// loadSigner reads a PEM encoded private key.
+func loadSigner(path string) (crypto.Signer, error) {
+	pemBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("unsupported key type %T in %s", key, path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unable to parse private key in " + path)
+}
+
+// caName returns the subject name of a new root or intermediate: the prefix
+// followed by a few random hex bytes, or by a count of the names created so
+// far when issuing from fixtures.
+func (f *fixtures) caName(prefix string) pkix.Name {
+	if f == nil {
+		return caName(prefix)
+	}
+	f.Lock()
+	defer f.Unlock()
+	f.issuers++
+	return pkix.Name{CommonName: fmt.Sprintf("%s%06x", prefix, f.issuers)}
+}
+
+// caSerial returns the serial of a root or intermediate certificate: random,
+// or derived from its subject name when issuing from fixtures.
+func (f *fixtures) caSerial(subject pkix.Name) *big.Int {
+	if f == nil {
+		return makeSerial()
+	}
+	digest := sha256.Sum256([]byte(subject.CommonName))
+	digest[0] &= 0x7f
+	return new(big.Int).SetBytes(digest[:8])
+}
+
+// caValidity returns the validity period of a root or intermediate
+// certificate.
+func (f *fixtures) caValidity() (time.Time, time.Time) {
+	notBefore := time.Now()
+	if f != nil {
+		notBefore = f.notBefore
+	}
+	return notBefore, notBefore.AddDate(30, 0, 0)
+}
+
+// certValidity returns the validity period of an issued certificate, before
+// any clock skew is applied.
+func (f *fixtures) certValidity() (time.Time, time.Time) {
+	if f == nil {
+		now := time.Now()
+		return now, now.AddDate(5, 0, 0)
+	}
+	if f.validity == 0 {
+		return f.notBefore, f.notBefore.AddDate(30, 0, 0)
+	}
+	return f.notBefore, f.notBefore.Add(f.validity)
+}
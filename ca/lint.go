@@ -0,0 +1,244 @@
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// The lint modes of issued certificates.
+const (
+	// LintFail refuses to issue certificates with lint errors. Lint warnings
+	// are logged.
+	LintFail = "fail"
+	// LintWarn logs the lint errors and warnings of issued certificates.
+	LintWarn = "warn"
+
+	// maxLintValidity is the longest validity period of TLS server
+	// certificates allowed by the CA/Browser Forum Baseline Requirements.
+	maxLintValidity = 398 * 24 * time.Hour
+)
+
+// LintConfig configures the linting of certificates before they are issued.
+type LintConfig struct {
+	// Mode is LintFail, LintWarn or "" to disable linting.
+	Mode string
+	// Inject names lints that every issued certificate deliberately violates.
+	// The violations are treated like any other, so with LintFail no
+	// certificate is issued if they include a lint error.
+	Inject []string
+}
+
+func (c LintConfig) check() error {
+	switch c.Mode {
+	case "", LintFail, LintWarn:
+	default:
+		return fmt.Errorf("unknown lint mode %q", c.Mode)
+	}
+	for _, name := range c.Inject {
+		l := findLint(name)
+		if l == nil {
+			return fmt.Errorf("unknown lint %q", name)
+		}
+		if l.inject == nil {
+			return fmt.Errorf("lint %q can't be injected", name)
+		}
+	}
+	return nil
+}
+
+// A lint checks one property of a certificate.
+type lint struct {
+	name string
+	// warning lints never fail issuance.
+	warning bool
+	// check returns a description of the certificate's violation of the lint,
+	// or "" if it passes.
+	check func(cert *x509.Certificate) string
+	// inject changes a certificate template so that it violates the lint. It
+	// is nil for lints that can't be injected.
+	inject func(template *x509.Certificate)
+}
+
+// lints are the built-in lints, a subset of the checks made by zlint.
+var lints = []lint{
+	{
+		name: "cn_not_in_san",
+		check: func(cert *x509.Certificate) string {
+			cn := cert.Subject.CommonName
+			if cn == "" {
+				return ""
+			}
+			for _, name := range cert.DNSNames {
+				if strings.EqualFold(name, cn) {
+					return ""
+				}
+			}
+			return fmt.Sprintf("common name %q is not a subject alternative name", cn)
+		},
+		inject: func(template *x509.Certificate) {
+			template.Subject.CommonName = "lint-violation.pebble.invalid"
+		},
+	},
+	{
+		name: "dns_name_underscore",
+		check: func(cert *x509.Certificate) string {
+			for _, name := range cert.DNSNames {
+				if strings.Contains(name, "_") {
+					return fmt.Sprintf("DNS name %q contains an underscore", name)
+				}
+			}
+			return ""
+		},
+		inject: func(template *x509.Certificate) {
+			template.DNSNames = append(template.DNSNames, "lint_violation.pebble.invalid")
+		},
+	},
+	{
+		name: "missing_server_auth_eku",
+		check: func(cert *x509.Certificate) string {
+			for _, eku := range cert.ExtKeyUsage {
+				if eku == x509.ExtKeyUsageServerAuth {
+					return ""
+				}
+			}
+			return "extended key usage doesn't include serverAuth"
+		},
+		inject: func(template *x509.Certificate) {
+			template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}
+		},
+	},
+	{
+		name: "key_encipherment_without_rsa",
+		check: func(cert *x509.Certificate) string {
+			if _, isRSA := cert.PublicKey.(*rsa.PublicKey); isRSA {
+				return ""
+			}
+			if cert.KeyUsage&x509.KeyUsageKeyEncipherment != 0 {
+				return "key usage includes keyEncipherment for a non-RSA key"
+			}
+			return ""
+		},
+		// Only violated by certificates for ECDSA and Ed25519 keys
+		inject: func(template *x509.Certificate) {
+			template.KeyUsage |= x509.KeyUsageKeyEncipherment
+		},
+	},
+	{
+		name: "weak_subscriber_key",
+		check: func(cert *x509.Certificate) string {
+			switch k := cert.PublicKey.(type) {
+			case *rsa.PublicKey:
+				if k.N.BitLen() < 2048 {
+					return fmt.Sprintf("RSA key is only %d bits", k.N.BitLen())
+				}
+			case *ecdsa.PublicKey:
+				if k.Curve.Params().BitSize < 256 {
+					return fmt.Sprintf("ECDSA key uses curve %s", k.Curve.Params().Name)
+				}
+			}
+			return ""
+		},
+	},
+	{
+		name: "ca_subscriber_cert",
+		check: func(cert *x509.Certificate) string {
+			if cert.IsCA {
+				return "basic constraints mark the certificate as a CA"
+			}
+			return ""
+		},
+	},
+	{
+		name: "serial_not_positive",
+		check: func(cert *x509.Certificate) string {
+			if cert.SerialNumber.Sign() <= 0 {
+				return "serial number is not positive"
+			}
+			return ""
+		},
+	},
+	{
+		name:    "validity_too_long",
+		warning: true,
+		check: func(cert *x509.Certificate) string {
+			validity := cert.NotAfter.Sub(cert.NotBefore)
+			if validity > maxLintValidity {
+				return fmt.Sprintf("validity period of %d days is longer than 398 days",
+					validity/(24*time.Hour))
+			}
+			return ""
+		},
+		inject: func(template *x509.Certificate) {
+			template.NotAfter = template.NotBefore.AddDate(10, 0, 0)
+		},
+	},
+}
+
+func findLint(name string) *lint {
+	for i := range lints {
+		if lints[i].name == name {
+			return &lints[i]
+		}
+	}
+	return nil
+}
+
+// lintError is returned when a certificate isn't issued because it fails
+// linting.
+type lintError struct {
+	failures []string
+}
+
+func (e *lintError) Error() string {
+	return "certificate failed linting: " + strings.Join(e.failures, "; ")
+}
+
+// injectLintViolations changes the template of a certificate to violate the
+// lints configured to be injected.
+func (ca *CAImpl) injectLintViolations(template *x509.Certificate) {
+	for _, name := range ca.lint.Inject {
+		findLint(name).inject(template)
+	}
+}
+
+// runLints returns the lint errors and warnings of a certificate, each as
+// "<lint>: <description>".
+func runLints(cert *x509.Certificate) (errors, warnings []string) {
+	for _, l := range lints {
+		detail := l.check(cert)
+		if detail == "" {
+			continue
+		}
+		if l.warning {
+			warnings = append(warnings, l.name+": "+detail)
+		} else {
+			errors = append(errors, l.name+": "+detail)
+		}
+	}
+	return errors, warnings
+}
+
+// lintCertificate runs the lints on a certificate and logs the violations. It
+// returns a *lintError if the lint mode is LintFail and the certificate has
+// lint errors.
+func (ca *CAImpl) lintCertificate(cert *x509.Certificate) error {
+	if ca.lint.Mode == "" {
+		return nil
+	}
+
+	failures, warnings := runLints(cert)
+	for _, f := range failures {
+		ca.log.Printf("Lint error for certificate serial %x: %s", cert.SerialNumber, f)
+	}
+	for _, w := range warnings {
+		ca.log.Printf("Lint warning for certificate serial %x: %s", cert.SerialNumber, w)
+	}
+	if ca.lint.Mode == LintFail && len(failures) > 0 {
+		return &lintError{failures: failures}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package ca
+
+import (
+	"crypto/x509"
+	"math/big"
+)
+
+// PreflightCertificate makes the checks CompleteOrder would make issuing a
+// certificate for the CSR, with the order's permanent identifiers and
+// TNAuthList, without issuing it. It returns the error issuance would fail
+// with, if any, and the lint warnings of the certificate. Linting is only
+// done when the lint mode is set, and lint errors only fail issuance with
+// LintFail, otherwise they are returned as warnings.
+//
+// Errors in the CSR's extensions aren't returned, since finalization checks
+// them before an order is processed: the rest of the checks are made without
+// them.
+func (ca *CAImpl) PreflightCertificate(
+	csr *x509.CertificateRequest,
+	permanentIDs []string,
+	tnAuthList []byte) ([]string, error) {
+	domains := ca.redactNames(csr.DNSNames)
+	var cn string
+	if len(domains) > 0 {
+		cn = domains[0]
+	}
+	issuer, _, err := ca.issuers(domains)
+	if err != nil {
+		return nil, err
+	}
+	if ca.lint.Mode == "" {
+		return nil, nil
+	}
+
+	extensions, _ := ca.CSRExtensions(csr)
+	// The serial isn't used up since nothing is issued
+	serial := big.NewInt(1)
+	if ca.fixtures != nil && ca.fixtures.serial != nil {
+		serial = new(big.Int).Set(ca.fixtures.serial)
+	}
+	template, err := ca.certificateTemplate(
+		cn, domains, permanentIDs, tnAuthList, extensions, csr.PublicKey, serial, issuer)
+	if err != nil {
+		return nil, err
+	}
+	// Fill in what the template only gets from signing, as it would be
+	// parsed from the certificate
+	template.PublicKey = csr.PublicKey
+	if template.DNSNames == nil {
+		template.DNSNames = domains
+	}
+
+	failures, warnings := runLints(template)
+	if ca.lint.Mode == LintWarn {
+		return append(failures, warnings...), nil
+	}
+	if len(failures) > 0 {
+		return warnings, &lintError{failures: failures}
+	}
+	return warnings, nil
+}
@@ -0,0 +1,87 @@
+package ca
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// defaultRedactionReplacement replaces redacted labels if a Redaction has no
+// Replacement, as in the "?.example.com" names of the CT redaction drafts.
+const defaultRedactionReplacement = "?"
+
+// A Redaction replaces the leftmost labels of the DNS names of issued
+// certificates matching Pattern, so that CT monitors and certificate parsers
+// can be tested with the unusual but syntactically valid names that CT
+// redaction proposals and misbehaving CAs have produced, e.g.
+// "?.example.com". The names are replaced in the subject alternative names
+// and the common name.
+type Redaction struct {
+	// Pattern is a pattern (see the pattern package) matched against the DNS
+	// names of certificates.
+	Pattern string
+	// Labels is how many of the leftmost labels are replaced. The wildcard
+	// label of a wildcard name isn't counted, and the rightmost label is never
+	// replaced. Defaults to 1.
+	Labels int
+	// Replacement replaces each redacted label. It must be printable ASCII
+	// without dots. Defaults to "?".
+	Replacement string
+}
+
+func (r Redaction) check() error {
+	if err := pattern.Check(r.Pattern); err != nil {
+		return fmt.Errorf("redaction: %s", err)
+	}
+	if r.Labels < 0 {
+		return fmt.Errorf("redaction of %q must not have a negative number of labels", r.Pattern)
+	}
+	for _, c := range r.Replacement {
+		if c < ' ' || c > '~' || c == '.' {
+			return fmt.Errorf("redaction of %q has an invalid replacement %q", r.Pattern, r.Replacement)
+		}
+	}
+	return nil
+}
+
+// redact returns the name with its leftmost labels replaced.
+func (r Redaction) redact(name string) string {
+	n := r.Labels
+	if n == 0 {
+		n = 1
+	}
+	replacement := r.Replacement
+	if replacement == "" {
+		replacement = defaultRedactionReplacement
+	}
+
+	labels := strings.Split(name, ".")
+	first := 0
+	if labels[0] == "*" {
+		first = 1
+	}
+	for i := first; i < first+n && i < len(labels)-1; i++ {
+		labels[i] = replacement
+	}
+	return strings.Join(labels, ".")
+}
+
+// redactNames returns the names with the first matching redaction applied to
+// each. The names are returned as-is if there are no redactions.
+func (ca *CAImpl) redactNames(names []string) []string {
+	if len(ca.redactions) == 0 {
+		return names
+	}
+	redacted := make([]string, 0, len(names))
+	for _, name := range names {
+		for _, r := range ca.redactions {
+			if pattern.Match(r.Pattern, name) {
+				name = r.redact(name)
+				break
+			}
+		}
+		redacted = append(redacted, name)
+	}
+	return redacted
+}
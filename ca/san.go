@@ -0,0 +1,72 @@
+package ca
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+)
+
+var (
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	// oidPermanentIdentifier is id-on-permanentIdentifier from RFC 4043.
+	oidPermanentIdentifier = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 3}
+	// oidTNAuthList is id-pe-TNAuthList from RFC 8226.
+	oidTNAuthList = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 26}
+)
+
+const (
+	sanOtherNameTag = 0
+	sanDNSNameTag   = 2
+)
+
+// permanentIdentifier is the PermanentIdentifier ASN.1 structure from RFC
+// 4043. The optional assigner is never included.
+type permanentIdentifier struct {
+	IdentifierValue string `asn1:"utf8"`
+}
+
+// subjectAltNameExtension builds a subject alternative name extension with a
+// dNSName for each domain and an otherName holding a PermanentIdentifier for
+// each permanent identifier.
+func subjectAltNameExtension(domains, permanentIDs []string) (pkix.Extension, error) {
+	var names []asn1.RawValue
+	for _, domain := range domains {
+		names = append(names, asn1.RawValue{
+			Class: asn1.ClassContextSpecific,
+			Tag:   sanDNSNameTag,
+			Bytes: []byte(domain),
+		})
+	}
+
+	for _, id := range permanentIDs {
+		typeID, err := asn1.Marshal(oidPermanentIdentifier)
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		value, err := asn1.Marshal(permanentIdentifier{IdentifierValue: id})
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		// The value of an otherName is explicitly tagged
+		explicitValue, err := asn1.Marshal(asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      value,
+		})
+		if err != nil {
+			return pkix.Extension{}, err
+		}
+		names = append(names, asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        sanOtherNameTag,
+			IsCompound: true,
+			Bytes:      append(typeID, explicitValue...),
+		})
+	}
+
+	der, err := asn1.Marshal(names)
+	if err != nil {
+		return pkix.Extension{}, err
+	}
+	return pkix.Extension{Id: oidSubjectAltName, Value: der}, nil
+}
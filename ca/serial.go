@@ -0,0 +1,114 @@
+package ca
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/pebble/cryptomode"
+)
+
+// The serial number schemes for issued certificates.
+const (
+	// SerialRandom serials are random numbers below 2^63. This is the default.
+	SerialRandom = "random"
+	// SerialFixedLength serials are random numbers of exactly Length bytes.
+	SerialFixedLength = "fixed-length"
+	// SerialSequential serials increase by one with every certificate,
+	// starting at one.
+	SerialSequential = "sequential"
+	// SerialTimestamp serials are the Unix time of issuance in seconds
+	// followed by eight random bytes.
+	SerialTimestamp = "timestamp"
+	// SerialColliding serials are random numbers between one and
+	// CollisionSpace, so that certificates deliberately share serials.
+	SerialColliding = "colliding"
+
+	defaultSerialLength = 16
+	// maxSerialLength is the longest serial number allowed by RFC 5280.
+	maxSerialLength = 20
+)
+
+// SerialConfig configures the serial numbers of issued certificates. The
+// serials of root and intermediate certificates are random unless they are
+// made from fixtures.
+type SerialConfig struct {
+	// Scheme is one of SerialRandom (the default), SerialFixedLength,
+	// SerialSequential, SerialTimestamp or SerialColliding.
+	Scheme string
+	// Length is the length in bytes of SerialFixedLength serials. Defaults to
+	// 16.
+	Length int
+	// CollisionSpace is the number of different SerialColliding serials.
+	// Defaults to one, giving every certificate the same serial.
+	CollisionSpace int
+}
+
+// serialGenerator makes serial numbers for issued certificates.
+type serialGenerator struct {
+	config SerialConfig
+
+	sync.Mutex
+	last *big.Int
+}
+
+func newSerialGenerator(config SerialConfig) (*serialGenerator, error) {
+	switch config.Scheme {
+	case "":
+		config.Scheme = SerialRandom
+	case SerialRandom, SerialSequential, SerialTimestamp:
+	case SerialFixedLength:
+		if config.Length == 0 {
+			config.Length = defaultSerialLength
+		}
+		if config.Length < 1 || config.Length > maxSerialLength {
+			return nil, fmt.Errorf("serial length %d is not between 1 and %d bytes",
+				config.Length, maxSerialLength)
+		}
+	case SerialColliding:
+		if config.CollisionSpace < 1 {
+			config.CollisionSpace = 1
+		}
+	default:
+		return nil, fmt.Errorf("unknown serial scheme %q", config.Scheme)
+	}
+	return &serialGenerator{config: config, last: big.NewInt(0)}, nil
+}
+
+func (g *serialGenerator) next() *big.Int {
+	switch g.config.Scheme {
+	case SerialFixedLength:
+		b := randomBytes(g.config.Length)
+		// Clear the top bit to keep the serial positive and set the next one
+		// so that it has no leading zero bytes
+		b[0] = b[0]&0x7f | 0x40
+		return new(big.Int).SetBytes(b)
+	case SerialSequential:
+		g.Lock()
+		defer g.Unlock()
+		g.last = new(big.Int).Add(g.last, big.NewInt(1))
+		return g.last
+	case SerialTimestamp:
+		serial := big.NewInt(time.Now().Unix())
+		serial.Lsh(serial, 64)
+		return serial.Or(serial, new(big.Int).SetBytes(randomBytes(8)))
+	case SerialColliding:
+		n, err := rand.Int(cryptomode.Reader(), big.NewInt(int64(g.config.CollisionSpace)))
+		if err != nil {
+			panic(fmt.Sprintf("unable to create random serial number: %s", err.Error()))
+		}
+		return n.Add(n, big.NewInt(1))
+	}
+	return makeSerial()
+}
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := io.ReadFull(cryptomode.Reader(), b); err != nil {
+		panic(fmt.Sprintf("unable to create random serial number: %s", err.Error()))
+	}
+	return b
+}
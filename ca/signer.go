@@ -0,0 +1,293 @@
+package ca
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/pebble/timeouts"
+)
+
+const (
+	// SignerMemory keeps the intermediates' key in memory.
+	SignerMemory = "memory"
+	// SignerRemote signs with a key held by a remote HTTP signer.
+	SignerRemote = "remote"
+
+	// defaultRemoteSignerTimeout bounds each request to a remote signer by
+	// default.
+	defaultRemoteSignerTimeout = 10 * time.Second
+)
+
+// SignerConfig selects how the intermediates sign the certificates they
+// issue. The roots always keep their keys in memory.
+type SignerConfig struct {
+	// Type is SignerMemory, the default, or SignerRemote.
+	Type string
+	// URL is the remote signer's URL for SignerRemote. A GET request to it
+	// returns the signer's public key and a POST request signs a digest (see
+	// remoteSigner).
+	URL string
+	// Delay is added to every signature to simulate a slow HSM.
+	Delay time.Duration
+	// Timeout bounds each request to the remote signer. Zero means ten
+	// seconds.
+	Timeout time.Duration
+	// Deadline bounds each signature, including Delay. Signatures that take
+	// longer fail. Zero means no deadline.
+	Deadline time.Duration
+}
+
+func (c SignerConfig) check() error {
+	switch c.Type {
+	case "", SignerMemory:
+	case SignerRemote:
+		if c.URL == "" {
+			return fmt.Errorf("remote signer has no URL")
+		}
+	default:
+		return fmt.Errorf("unknown signer type %q", c.Type)
+	}
+	if c.Delay < 0 {
+		return fmt.Errorf("signer delay must be >= 0")
+	}
+	if c.Timeout < 0 || c.Deadline < 0 {
+		return fmt.Errorf("signer timeout and deadline must be >= 0")
+	}
+	return nil
+}
+
+// SignerStats describes the signatures made with the intermediates' key.
+type SignerStats struct {
+	Signatures   int64
+	Errors       int64
+	TotalSeconds float64
+	MaxSeconds   float64
+}
+
+// signerStats accumulates the SignerStats of metered signers.
+type signerStats struct {
+	sync.Mutex
+	stats SignerStats
+}
+
+func (s *signerStats) record(elapsed float64, err error) {
+	s.Lock()
+	defer s.Unlock()
+	s.stats.Signatures++
+	if err != nil {
+		s.stats.Errors++
+	}
+	s.stats.TotalSeconds += elapsed
+	if elapsed > s.stats.MaxSeconds {
+		s.stats.MaxSeconds = elapsed
+	}
+}
+
+func (s *signerStats) get() SignerStats {
+	s.Lock()
+	defer s.Unlock()
+	return s.stats
+}
+
+// meteredSigner records the latency of the signatures of a crypto.Signer,
+// optionally adding a delay to each and failing those that take longer than
+// a deadline.
+type meteredSigner struct {
+	crypto.Signer
+	delay    time.Duration
+	deadline time.Duration
+	stats    *signerStats
+	timeouts *timeouts.Counter
+}
+
+func (s *meteredSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	start := time.Now()
+	sig, err := s.sign(rand, digest, opts)
+	s.stats.record(time.Since(start).Seconds(), err)
+	return sig, err
+}
+
+func (s *meteredSigner) sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if s.deadline <= 0 {
+		if s.delay > 0 {
+			time.Sleep(s.delay)
+		}
+		return s.Signer.Sign(rand, digest, opts)
+	}
+
+	type result struct {
+		sig []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		if s.delay > 0 {
+			time.Sleep(s.delay)
+		}
+		sig, err := s.Signer.Sign(rand, digest, opts)
+		done <- result{sig, err}
+	}()
+	select {
+	case r := <-done:
+		return r.sig, r.err
+	case <-time.After(s.deadline):
+		s.timeouts.Record("ca", "signing")
+		return nil, signingDeadlineError{deadline: s.deadline}
+	}
+}
+
+// signingDeadlineError is the error of a signature that missed the signer's
+// deadline.
+type signingDeadlineError struct {
+	deadline time.Duration
+}
+
+func (e signingDeadlineError) Error() string {
+	return fmt.Sprintf("signing took longer than the %s deadline", e.deadline)
+}
+
+// remoteSigner is a crypto.Signer whose key is held by an HTTP service. A GET
+// request to the service's URL returns its public key as a JSON object with
+// the base64 encoded DER SubjectPublicKeyInfo in the "publicKey" field. A POST
+// request with a JSON object holding the base64 encoded "digest" and the name
+// of its "hash" (e.g. "SHA-256") returns a JSON object with the base64 encoded
+// "signature". RSA signatures use PKCS #1 v1.5.
+type remoteSigner struct {
+	url      string
+	client   *http.Client
+	public   crypto.PublicKey
+	timeouts *timeouts.Counter
+}
+
+// hashNames are the names of the hashes sent to remote signers.
+var hashNames = map[crypto.Hash]string{
+	crypto.SHA256: "SHA-256",
+	crypto.SHA384: "SHA-384",
+	crypto.SHA512: "SHA-512",
+}
+
+// newRemoteSigner fetches the public key of the remote signer at the URL.
+// Requests to it that time out are counted by counter, which may be nil.
+func newRemoteSigner(url string, timeout time.Duration, counter *timeouts.Counter) (*remoteSigner, error) {
+	if timeout <= 0 {
+		timeout = defaultRemoteSignerTimeout
+	}
+	s := &remoteSigner{
+		url:      url,
+		client:   &http.Client{Timeout: timeout},
+		timeouts: counter,
+	}
+	var key struct {
+		PublicKey string `json:"publicKey"`
+	}
+	if err := s.call("GET", nil, &key); err != nil {
+		return nil, fmt.Errorf("fetching remote signer public key: %s", err)
+	}
+	der, err := base64.StdEncoding.DecodeString(key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("decoding remote signer public key: %s", err)
+	}
+	s.public, err = x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing remote signer public key: %s", err)
+	}
+	return s, nil
+}
+
+func (s *remoteSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+func (s *remoteSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if _, ok := opts.(*rsa.PSSOptions); ok {
+		return nil, fmt.Errorf("remote signer doesn't support RSA-PSS")
+	}
+	hash, ok := hashNames[opts.HashFunc()]
+	if !ok {
+		return nil, fmt.Errorf("remote signer doesn't support hash %d", opts.HashFunc())
+	}
+	request := struct {
+		Digest string `json:"digest"`
+		Hash   string `json:"hash"`
+	}{base64.StdEncoding.EncodeToString(digest), hash}
+	var result struct {
+		Signature string `json:"signature"`
+	}
+	if err := s.call("POST", request, &result); err != nil {
+		return nil, fmt.Errorf("remote signer: %s", err)
+	}
+	return base64.StdEncoding.DecodeString(result.Signature)
+}
+
+// call sends a request to the remote signer and decodes its JSON response.
+func (s *remoteSigner) call(method string, request, result interface{}) error {
+	var body io.Reader
+	if request != nil {
+		data, err := json.Marshal(request)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, s.url, body)
+	if err != nil {
+		return err
+	}
+	if request != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		s.timeouts.Check(err, "ca", "remoteSigner")
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s %s returned status %d", method, s.url, resp.StatusCode)
+	}
+	return json.Unmarshal(data, result)
+}
+
+// newIntermediateKey returns the key shared by the intermediates: a new key,
+// or the remote signer's key. Its signatures are metered for SignerStats.
+func (ca *CAImpl) newIntermediateKey() (crypto.Signer, error) {
+	var key crypto.Signer
+	var err error
+	if ca.signer.Type == SignerRemote {
+		key, err = newRemoteSigner(ca.signer.URL, ca.signer.Timeout, ca.timeoutCounter)
+	} else if ca.fixtures != nil && ca.fixtures.intermediateKey != nil {
+		key = ca.fixtures.intermediateKey
+	} else {
+		key, err = makeKey()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &meteredSigner{
+		Signer:   key,
+		delay:    ca.signer.Delay,
+		deadline: ca.signer.Deadline,
+		stats:    ca.signerStats,
+		timeouts: ca.timeoutCounter,
+	}, nil
+}
+
+// SignerStats returns the totals of the signatures made with the keys of
+// every intermediate the CA has had.
+func (ca *CAImpl) SignerStats() SignerStats {
+	return ca.signerStats.get()
+}
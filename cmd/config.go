@@ -3,22 +3,22 @@ package cmd
 import (
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 )
 
 // ReadConfigFile takes a file path as an argument and attempts to
 // unmarshal the content of the file into a struct containing a
-// configuration of a Pebble component.
+// configuration of a Pebble component. The file may be JSON, YAML or TOML
+// and may include other config files.
 //
 // Lifted from
 //   https://raw.githubusercontent.com/letsencrypt/boulder/master/cmd/shell.go
 func ReadConfigFile(filename string, out interface{}) error {
-	configData, err := ioutil.ReadFile(filename)
+	configData, err := loadConfigData(filename)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal(configData, out)
+	return describeJSONError(configData, json.Unmarshal(configData, out))
 }
 
 // FailOnError exits and prints an error message if we encountered a problem
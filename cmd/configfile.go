@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// includeField is the top-level config field listing the files a config file
+// includes.
+const includeField = "include"
+
+// loadConfigData returns the JSON config data of a JSON, YAML (.yaml or .yml)
+// or TOML (.toml) config file with its includes resolved. The data of a JSON
+// file without includes is returned unchanged so that errors can refer to its
+// lines.
+func loadConfigData(filename string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	if configFormat(filename) == "json" && !bytes.Contains(data, []byte(`"`+includeField+`"`)) {
+		return data, nil
+	}
+	merged, err := loadConfigObject(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(merged, "", "  ")
+}
+
+func configFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".toml":
+		return "toml"
+	}
+	return "json"
+}
+
+// loadConfigObject decodes a config file into a JSON object and merges it on
+// top of the files it includes. Included files are resolved relative to the
+// including file. chain holds the files including this one to detect cycles.
+func loadConfigObject(filename string, chain []string) (map[string]interface{}, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range chain {
+		if f == abs {
+			return nil, fmt.Errorf("include cycle: %s -> %s", strings.Join(chain, " -> "), abs)
+		}
+	}
+	chain = append(chain, abs)
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	object, err := decodeConfigObject(filename, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+
+	includes, err := includedFiles(object[includeField])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", filename, err)
+	}
+	delete(object, includeField)
+
+	merged := make(map[string]interface{})
+	for _, include := range includes {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+		included, err := loadConfigObject(include, chain)
+		if err != nil {
+			return nil, err
+		}
+		mergeConfigObjects(merged, included)
+	}
+	mergeConfigObjects(merged, object)
+	return merged, nil
+}
+
+func decodeConfigObject(filename string, data []byte) (map[string]interface{}, error) {
+	object := make(map[string]interface{})
+	switch configFormat(filename) {
+	case "yaml":
+		var decoded interface{}
+		if err := yaml.Unmarshal(data, &decoded); err != nil {
+			return nil, err
+		}
+		if decoded == nil {
+			return object, nil
+		}
+		converted, ok := stringKeys(decoded).(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("config is not a YAML mapping")
+		}
+		return converted, nil
+	case "toml":
+		if err := toml.Unmarshal(data, &object); err != nil {
+			return nil, err
+		}
+		return object, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return nil, describeJSONError(data, err)
+	}
+	return object, nil
+}
+
+// stringKeys converts the map[interface{}]interface{} values YAML decodes
+// mappings into to map[string]interface{} so they can be marshalled as JSON.
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			converted[fmt.Sprint(k)] = stringKeys(elem)
+		}
+		return converted
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = stringKeys(elem)
+		}
+	}
+	return value
+}
+
+// includedFiles returns the value of the include field, which is either one
+// file name or a list of them.
+func includedFiles(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		var files []string
+		for _, elem := range v {
+			f, ok := elem.(string)
+			if !ok {
+				return nil, fmt.Errorf("%q must list file names", includeField)
+			}
+			files = append(files, f)
+		}
+		return files, nil
+	}
+	return nil, fmt.Errorf("%q must be a file name or a list of them", includeField)
+}
+
+// mergeConfigObjects merges src into dst. Objects are merged recursively and
+// any other value in src, including lists, replaces the value in dst.
+func mergeConfigObjects(dst, src map[string]interface{}) {
+	for k, v := range src {
+		srcObject, srcIsObject := v.(map[string]interface{})
+		dstObject, dstIsObject := dst[k].(map[string]interface{})
+		if srcIsObject && dstIsObject {
+			mergeConfigObjects(dstObject, srcObject)
+			continue
+		}
+		dst[k] = v
+	}
+}
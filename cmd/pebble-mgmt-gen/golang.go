@@ -0,0 +1,360 @@
+package main
+
+import (
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+)
+
+// goInitialisms are the words written in upper case in Go names.
+var goInitialisms = map[string]bool{
+	"ca": true, "csr": true, "ct": true, "dns": true, "eab": true, "hmac": true,
+	"id": true, "ip": true, "json": true, "pem": true, "sha256": true,
+	"uri": true, "uris": true, "url": true,
+}
+
+// goName returns the exported Go name of a JSON field or operation name,
+// e.g. "test-meta" is TestMeta and "url" is URL.
+func goName(name string) string {
+	var sb strings.Builder
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		// Only a leading lower case word can be an initialism, the rest
+		// are already capitalized as they should be
+		i := strings.IndexFunc(part, unicode.IsUpper)
+		if i < 0 {
+			i = len(part)
+		}
+		if goInitialisms[part[:i]] {
+			sb.WriteString(strings.ToUpper(part[:i]) + part[i:])
+			continue
+		}
+		r := []rune(part)
+		r[0] = unicode.ToUpper(r[0])
+		sb.WriteString(string(r))
+	}
+	return sb.String()
+}
+
+// goType returns the Go type of a schema.
+func goType(s *schema) string {
+	if s.Ref != "" {
+		return s.refName()
+	}
+	switch s.Type {
+	case "string":
+		switch s.Format {
+		case "date-time":
+			return "time.Time"
+		case "byte":
+			return "[]byte"
+		}
+		return "string"
+	case "boolean":
+		return "bool"
+	case "integer":
+		return "int"
+	case "number":
+		return "float64"
+	case "array":
+		return "[]" + goType(s.Items)
+	case "object":
+		if s.Properties == nil && s.AdditionalProperties != nil {
+			return "map[string]" + goType(s.AdditionalProperties)
+		}
+		return goStruct(s)
+	}
+	return "json.RawMessage"
+}
+
+// goStruct returns a Go struct type with the properties of an object schema.
+// Optional properties are omitted when empty and optional objects are
+// pointers.
+func goStruct(s *schema) string {
+	var sb strings.Builder
+	sb.WriteString("struct {\n")
+	for _, name := range s.propertyNames() {
+		prop := s.Properties[name]
+		typ, tag := goType(prop), name
+		if !s.isRequired(name) {
+			tag += ",omitempty"
+			if prop.Ref != "" {
+				typ = "*" + typ
+			}
+		}
+		fmt.Fprintf(&sb, "%s %s `json:%q`\n", goName(name), typ, tag)
+	}
+	sb.WriteString("}")
+	return sb.String()
+}
+
+const goPreamble = `// Client calls the management interface of a Pebble instance.
+type Client struct {
+	// BaseURL is the URL of the management interface, e.g.
+	// "https://localhost:15000".
+	BaseURL string
+	// HTTPClient sends the requests. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// Token, if set, is sent as a bearer token.
+	Token string
+}
+
+// ListOptions paginate, filter and sort the results of list operations.
+type ListOptions struct {
+	// Limit is the most results returned. Zero returns every result.
+	Limit  int
+	Offset int
+	// Sort are the fields the results are sorted by, descending if prefixed
+	// with "-".
+	Sort []string
+	// Filter are field:value pairs the results must match.
+	Filter []string
+	// Fields are the fields each result is returned with. Empty returns
+	// every field.
+	Fields []string
+}
+
+func (o *ListOptions) encode(query url.Values) {
+	if o == nil {
+		return
+	}
+	if o.Limit > 0 {
+		query.Set("limit", strconv.Itoa(o.Limit))
+	}
+	if o.Offset > 0 {
+		query.Set("offset", strconv.Itoa(o.Offset))
+	}
+	if len(o.Sort) > 0 {
+		query.Set("sort", strings.Join(o.Sort, ","))
+	}
+	for _, f := range o.Filter {
+		query.Add("filter", f)
+	}
+	if len(o.Fields) > 0 {
+		query.Set("fields", strings.Join(o.Fields, ","))
+	}
+}
+
+// Error is a problem document the management interface responded with.
+type Error struct {
+	StatusCode int
+	Problem    ProblemDetails
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("pebble management API: %d %s: %s", e.StatusCode, e.Problem.Type, e.Problem.Detail)
+}
+
+func (c *Client) do(
+	ctx context.Context,
+	method, path string,
+	query url.Values,
+	body, result interface{}) (*http.Response, []byte, error) {
+	u := strings.TrimSuffix(c.BaseURL, "/") + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, nil, err
+		}
+		reqBody = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u, reqBody)
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode >= 300 {
+		e := &Error{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(data, &e.Problem)
+		return nil, nil, e
+	}
+	if result != nil {
+		if err := json.Unmarshal(data, result); err != nil {
+			return nil, nil, err
+		}
+	}
+	return resp, data, nil
+}
+`
+
+// generateGo returns the source of the Go client.
+func generateGo(doc *document, endpoints []endpoint) ([]byte, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// APIVersion is the version of the management API the client was generated\n"+
+		"// from.\nconst APIVersion = %s\n\n", doc.Info.Version)
+	sb.WriteString(goPreamble)
+
+	for _, name := range doc.schemaNames() {
+		switch name {
+		case "Client", "ListOptions", "Error":
+			return nil, fmt.Errorf("schema %s has the name of a client type", name)
+		}
+		fmt.Fprintf(&sb, "\n// %s is a type of the management API.\ntype %s %s\n",
+			name, name, goStruct(doc.Components.Schemas[name]))
+	}
+
+	for _, e := range endpoints {
+		writeGoMethod(&sb, e)
+	}
+
+	imports := []string{"bytes", "context", "encoding/json", "fmt", "io", "io/ioutil",
+		"net/http", "net/url", "strconv", "strings"}
+	if strings.Contains(sb.String(), "time.Time") {
+		imports = append(imports, "time")
+	}
+	header := fmt.Sprintf("// %s\n\npackage mgmtclient\n\nimport (\n", generatedNotice)
+	for _, imp := range imports {
+		header += fmt.Sprintf("%q\n", imp)
+	}
+	header += ")\n\n"
+
+	src, err := format.Source([]byte(header + sb.String()))
+	if err != nil {
+		return nil, fmt.Errorf("formatting the generated source: %s", err)
+	}
+	return src, nil
+}
+
+func writeGoMethod(sb *strings.Builder, e endpoint) {
+	name := goName(e.OperationID)
+	paramsType := name + "Params"
+	if len(e.query) > 0 {
+		fmt.Fprintf(sb, "\n// %s are the query parameters of %s.\ntype %s struct {\n", paramsType, name, paramsType)
+		for _, p := range e.query {
+			fmt.Fprintf(sb, "// %s\n%s %s\n", p.Description, goName(p.Name), goType(p.Schema))
+		}
+		sb.WriteString("}\n")
+	}
+
+	args := []string{"ctx context.Context"}
+	if e.pathParam != "" {
+		args = append(args, e.pathParam+" string")
+	}
+	if e.request != nil {
+		if e.requestOptional {
+			args = append(args, "body *"+goType(e.request))
+		} else {
+			args = append(args, "body "+goType(e.request))
+		}
+	}
+	if len(e.query) > 0 {
+		args = append(args, "params *"+paramsType)
+	}
+	if e.List {
+		args = append(args, "list *ListOptions")
+	}
+
+	var results []string
+	switch {
+	case e.result != nil:
+		results = append(results, goType(e.result))
+	case e.raw:
+		results = append(results, "[]byte")
+	}
+	if e.List {
+		results = append(results, "int")
+	}
+	results = append(results, "error")
+
+	summary := strings.TrimSuffix(e.Summary, ".")
+	fmt.Fprintf(sb, "\n// %s sends a %s request to %s%s: %s.",
+		name, e.method, e.path, braces(e.pathParam), strings.ToLower(summary[:1])+summary[1:])
+	if e.List {
+		sb.WriteString(" It also returns the number of results that passed the list's filters.")
+	}
+	fmt.Fprintf(sb, "\nfunc (c *Client) %s(%s) (%s) {\n", name, strings.Join(args, ", "), strings.Join(results, ", "))
+
+	sb.WriteString("query := url.Values{}\n")
+	if len(e.query) > 0 {
+		sb.WriteString("if params != nil {\n")
+		for _, p := range e.query {
+			field := "params." + goName(p.Name)
+			switch goType(p.Schema) {
+			case "bool":
+				fmt.Fprintf(sb, "if %s {\nquery.Set(%q, \"true\")\n}\n", field, p.Name)
+			case "int":
+				fmt.Fprintf(sb, "if %s != 0 {\nquery.Set(%q, strconv.Itoa(%s))\n}\n", field, p.Name, field)
+			default:
+				fmt.Fprintf(sb, "if %s != \"\" {\nquery.Set(%q, %s)\n}\n", field, p.Name, field)
+			}
+		}
+		sb.WriteString("}\n")
+	}
+	if e.List {
+		sb.WriteString("list.encode(query)\n")
+	}
+
+	path := fmt.Sprintf("%q", e.path)
+	if e.pathParam != "" {
+		path += "+url.PathEscape(" + e.pathParam + ")"
+	}
+	reqBody := "nil"
+	if e.request != nil {
+		reqBody = "body"
+		if e.requestOptional {
+			sb.WriteString("var reqBody interface{}\nif body != nil {\nreqBody = body\n}\n")
+			reqBody = "reqBody"
+		}
+	}
+	resultArg := "nil"
+	if e.result != nil {
+		fmt.Fprintf(sb, "var result %s\n", goType(e.result))
+		resultArg = "&result"
+	}
+	respVar, dataVar := "_", "_"
+	var returns []string
+	switch {
+	case e.result != nil:
+		returns = append(returns, "result")
+	case e.raw:
+		dataVar = "data"
+		returns = append(returns, "data")
+	}
+	if e.List {
+		respVar = "resp"
+	}
+	fmt.Fprintf(sb, "%s, %s, err := c.do(ctx, %q, %s, query, %s, %s)\n",
+		respVar, dataVar, e.method, path, reqBody, resultArg)
+	if e.List {
+		sb.WriteString("if err != nil {\nreturn result, 0, err\n}\n")
+		sb.WriteString("total, _ := strconv.Atoi(resp.Header.Get(\"Pebble-Total-Count\"))\n")
+		returns = append(returns, "total", "nil")
+	} else {
+		returns = append(returns, "err")
+	}
+	fmt.Fprintf(sb, "return %s\n}\n", strings.Join(returns, ", "))
+}
+
+// braces returns the path parameter in braces, or "" if there is none.
+func braces(param string) string {
+	if param == "" {
+		return ""
+	}
+	return "{" + param + "}"
+}
@@ -0,0 +1,220 @@
+// Command pebble-mgmt-gen writes the OpenAPI document of Pebble's management
+// interface along with Go and TypeScript clients generated from it. It is run
+// with go generate in the mgmtclient package, whose files it writes:
+//
+//	pebble-mgmt-gen -out mgmtclient
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/letsencrypt/pebble/wfe"
+)
+
+// The files written to the output directory.
+const (
+	openAPIFile     = "openapi.json"
+	goClientFile    = "client.go"
+	tsClientFile    = "management.ts"
+	generatedNotice = "Code generated by pebble-mgmt-gen. DO NOT EDIT."
+)
+
+// The subset of the OpenAPI document the clients are generated from.
+type document struct {
+	Info struct {
+		Version string `json:"version"`
+	} `json:"info"`
+	Paths      map[string]map[string]*operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*schema `json:"schemas"`
+	} `json:"components"`
+}
+
+type operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary"`
+	Parameters  []parameter          `json:"parameters"`
+	RequestBody *body                `json:"requestBody"`
+	Responses   map[string]*response `json:"responses"`
+	List        bool                 `json:"x-pebble-list"`
+
+	method string
+	path   string
+}
+
+type parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description"`
+	Schema      *schema `json:"schema"`
+}
+
+type body struct {
+	Required bool             `json:"required"`
+	Content  map[string]media `json:"content"`
+}
+
+type response struct {
+	Content map[string]media `json:"content"`
+}
+
+type media struct {
+	Schema *schema `json:"schema"`
+}
+
+type schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Items                *schema            `json:"items"`
+	Properties           map[string]*schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *schema            `json:"additionalProperties"`
+}
+
+// refName returns the name of the component schema a schema refers to.
+func (s *schema) refName() string {
+	return s.Ref[strings.LastIndex(s.Ref, "/")+1:]
+}
+
+// empty returns true if the schema matches any value.
+func (s *schema) empty() bool {
+	return s.Ref == "" && s.Type == ""
+}
+
+// isRequired returns true if the object schema requires the property.
+func (s *schema) isRequired(name string) bool {
+	for _, r := range s.Required {
+		if r == name {
+			return true
+		}
+	}
+	return false
+}
+
+// propertyNames returns the names of the schema's properties in order.
+func (s *schema) propertyNames() []string {
+	var names []string
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// An endpoint is an operation as the clients call it.
+type endpoint struct {
+	*operation
+	pathParam string
+	query     []parameter
+	// request is the schema of the JSON request body, if any.
+	request         *schema
+	requestOptional bool
+	// result is the schema of the JSON response, if any. Other responses
+	// with a body are returned raw.
+	result *schema
+	raw    bool
+}
+
+// listParamNames are the parameters of list operations that the clients set
+// from their list options.
+var listParamNames = map[string]bool{
+	"limit": true, "offset": true, "sort": true, "filter": true, "fields": true,
+}
+
+// endpoints returns the document's operations sorted by ID.
+func (d *document) endpoints() ([]endpoint, error) {
+	var endpoints []endpoint
+	for path, methods := range d.Paths {
+		for method, op := range methods {
+			op.method = strings.ToUpper(method)
+			op.path = path
+			e := endpoint{operation: op}
+			for _, p := range op.Parameters {
+				switch {
+				case p.In == "path":
+					if e.pathParam != "" || !strings.HasSuffix(path, "{"+p.Name+"}") {
+						return nil, fmt.Errorf("%s: unsupported path parameters", op.OperationID)
+					}
+					e.pathParam = p.Name
+					op.path = strings.TrimSuffix(path, "{"+p.Name+"}")
+				case op.List && listParamNames[p.Name]:
+				default:
+					e.query = append(e.query, p)
+				}
+			}
+			if op.RequestBody != nil {
+				e.request = op.RequestBody.Content["application/json"].Schema
+				e.requestOptional = !op.RequestBody.Required
+			}
+			for status, resp := range op.Responses {
+				if status == "default" {
+					continue
+				}
+				for contentType, m := range resp.Content {
+					if contentType == "application/json" && !m.Schema.empty() {
+						e.result = m.Schema
+					} else {
+						e.raw = true
+					}
+				}
+			}
+			endpoints = append(endpoints, e)
+		}
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		return endpoints[i].OperationID < endpoints[j].OperationID
+	})
+	return endpoints, nil
+}
+
+// schemaNames returns the names of the document's component schemas in
+// order.
+func (d *document) schemaNames() []string {
+	var names []string
+	for name := range d.Components.Schemas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func main() {
+	out := flag.String("out", ".", "Directory the OpenAPI document and clients are written to")
+	flag.Parse()
+
+	spec := wfe.ManagementOpenAPI()
+	var doc document
+	if err := json.Unmarshal(spec, &doc); err != nil {
+		log.Fatalf("Error parsing the OpenAPI document: %s", err)
+	}
+	endpoints, err := doc.endpoints()
+	if err != nil {
+		log.Fatalf("Error reading the OpenAPI document: %s", err)
+	}
+
+	goClient, err := generateGo(&doc, endpoints)
+	if err != nil {
+		log.Fatalf("Error generating the Go client: %s", err)
+	}
+	files := map[string][]byte{
+		openAPIFile:  append(spec, '\n'),
+		goClientFile: goClient,
+		tsClientFile: generateTypeScript(&doc, endpoints),
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(*out, name), data, 0644); err != nil {
+			log.Fatalf("Error writing %s: %s", name, err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d operations and %d types to %s\n",
+		len(endpoints), len(doc.Components.Schemas), *out)
+}
@@ -0,0 +1,224 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var tsIdentifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// tsProperty returns a property name, quoted if it isn't an identifier.
+func tsProperty(name string) string {
+	if tsIdentifier.MatchString(name) {
+		return name
+	}
+	return fmt.Sprintf("%q", name)
+}
+
+// tsType returns the TypeScript type of a schema.
+func tsType(s *schema) string {
+	if s.Ref != "" {
+		return s.refName()
+	}
+	switch s.Type {
+	case "string":
+		return "string"
+	case "boolean":
+		return "boolean"
+	case "integer", "number":
+		return "number"
+	case "array":
+		return tsType(s.Items) + "[]"
+	case "object":
+		if s.Properties == nil && s.AdditionalProperties != nil {
+			return "{ [key: string]: " + tsType(s.AdditionalProperties) + " }"
+		}
+		return tsObject(s, "")
+	}
+	return "unknown"
+}
+
+// tsObject returns a TypeScript object type with the properties of an object
+// schema, indented by indent.
+func tsObject(s *schema, indent string) string {
+	var sb strings.Builder
+	sb.WriteString("{\n")
+	for _, name := range s.propertyNames() {
+		optional := ""
+		if !s.isRequired(name) {
+			optional = "?"
+		}
+		fmt.Fprintf(&sb, "%s  %s%s: %s;\n", indent, tsProperty(name), optional, tsType(s.Properties[name]))
+	}
+	if s.AdditionalProperties != nil {
+		fmt.Fprintf(&sb, "%s  [key: string]: unknown;\n", indent)
+	}
+	sb.WriteString(indent + "}")
+	return sb.String()
+}
+
+const tsPreamble = `/** Paginates, filters and sorts the results of list operations. */
+export interface ListOptions {
+  /** The most results returned. Zero or undefined returns every result. */
+  limit?: number;
+  offset?: number;
+  /** The fields the results are sorted by, descending if prefixed with "-". */
+  sort?: string[];
+  /** field:value pairs the results must match. */
+  filter?: string[];
+  /** The fields each result is returned with. */
+  fields?: string[];
+}
+
+/** A page of the results of a list operation. */
+export interface ListResult<T> {
+  items: T[];
+  /** The number of results that passed the list's filters. */
+  total: number;
+}
+
+/** A problem document the management interface responded with. */
+export class ManagementError extends Error {
+  constructor(readonly status: number, readonly problem?: ProblemDetails) {
+    super(` + "`pebble management API: ${status} ${problem?.type ?? \"\"}: ${problem?.detail ?? \"\"}`" + `);
+  }
+}
+
+export interface ClientOptions {
+  /** Sent as a bearer token if set. */
+  token?: string;
+  /** Sends the requests. Defaults to the global fetch. */
+  fetch?: typeof fetch;
+}
+
+function addListOptions(query: URLSearchParams, list: ListOptions): void {
+  if (list.limit) query.set("limit", String(list.limit));
+  if (list.offset) query.set("offset", String(list.offset));
+  if (list.sort?.length) query.set("sort", list.sort.join(","));
+  for (const f of list.filter ?? []) query.append("filter", f);
+  if (list.fields?.length) query.set("fields", list.fields.join(","));
+}
+
+/** Calls the management interface of a Pebble instance. */
+export class ManagementClient {
+  /** @param baseURL The URL of the management interface, e.g. "https://localhost:15000". */
+  constructor(readonly baseURL: string, readonly options: ClientOptions = {}) {}
+
+  private async request(method: string, path: string, query: URLSearchParams, body?: unknown): Promise<Response> {
+    const qs = query.toString();
+    const url = this.baseURL.replace(/\/$/, "") + path + (qs ? "?" + qs : "");
+    const headers: Record<string, string> = {};
+    if (body !== undefined) headers["Content-Type"] = "application/json";
+    if (this.options.token) headers["Authorization"] = "Bearer " + this.options.token;
+    const resp = await (this.options.fetch ?? fetch)(url, {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (resp.status >= 300) {
+      let problem: ProblemDetails | undefined;
+      try {
+        problem = await resp.json();
+      } catch {
+        problem = undefined;
+      }
+      throw new ManagementError(resp.status, problem);
+    }
+    return resp;
+  }
+`
+
+// generateTypeScript returns the source of the TypeScript client.
+func generateTypeScript(doc *document, endpoints []endpoint) []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "// %s\n\n", generatedNotice)
+	fmt.Fprintf(&sb, "/** The version of the management API the client was generated from. */\n"+
+		"export const API_VERSION = %s;\n", doc.Info.Version)
+
+	for _, name := range doc.schemaNames() {
+		fmt.Fprintf(&sb, "\nexport interface %s %s\n", name, tsObject(doc.Components.Schemas[name], ""))
+	}
+	for _, e := range endpoints {
+		if len(e.query) == 0 {
+			continue
+		}
+		fmt.Fprintf(&sb, "\n/** The query parameters of %s. */\nexport interface %sParams {\n",
+			e.OperationID, goName(e.OperationID))
+		for _, p := range e.query {
+			fmt.Fprintf(&sb, "  /** %s */\n  %s?: %s;\n", p.Description, tsProperty(p.Name), tsType(p.Schema))
+		}
+		sb.WriteString("}\n")
+	}
+
+	sb.WriteString("\n" + tsPreamble)
+	for _, e := range endpoints {
+		writeTSMethod(&sb, e)
+	}
+	sb.WriteString("}\n")
+	return []byte(sb.String())
+}
+
+func writeTSMethod(sb *strings.Builder, e endpoint) {
+	var args []string
+	if e.pathParam != "" {
+		args = append(args, e.pathParam+": string")
+	}
+	if e.request != nil {
+		if e.requestOptional {
+			args = append(args, "body?: "+tsType(e.request))
+		} else {
+			args = append(args, "body: "+tsType(e.request))
+		}
+	}
+	if len(e.query) > 0 {
+		args = append(args, "params: "+goName(e.OperationID)+"Params = {}")
+	}
+	if e.List {
+		args = append(args, "list: ListOptions = {}")
+	}
+
+	result := "void"
+	switch {
+	case e.result != nil && e.List:
+		result = "ListResult<" + tsType(e.result.Items) + ">"
+	case e.result != nil:
+		result = tsType(e.result)
+	case e.raw:
+		result = "string"
+	}
+
+	fmt.Fprintf(sb, "\n  /** %s */\n  async %s(%s): Promise<%s> {\n",
+		e.Summary, e.OperationID, strings.Join(args, ", "), result)
+	sb.WriteString("    const query = new URLSearchParams();\n")
+	for _, p := range e.query {
+		fmt.Fprintf(sb, "    if (params.%s !== undefined) query.set(%q, String(params.%s));\n",
+			p.Name, p.Name, p.Name)
+	}
+	if e.List {
+		sb.WriteString("    addListOptions(query, list);\n")
+	}
+
+	path := fmt.Sprintf("%q", e.path)
+	if e.pathParam != "" {
+		path += " + encodeURIComponent(" + e.pathParam + ")"
+	}
+	bodyArg := ""
+	if e.request != nil {
+		bodyArg = ", body"
+	}
+	call := fmt.Sprintf("this.request(%q, %s, query%s)", e.method, path, bodyArg)
+
+	switch {
+	case e.result != nil && e.List:
+		fmt.Fprintf(sb, "    const resp = await %s;\n", call)
+		sb.WriteString("    return { items: await resp.json(), total: Number(resp.headers.get(\"Pebble-Total-Count\") ?? 0) };\n")
+	case e.result != nil:
+		fmt.Fprintf(sb, "    return (await %s).json();\n", call)
+	case e.raw:
+		fmt.Fprintf(sb, "    return (await %s).text();\n", call)
+	default:
+		fmt.Fprintf(sb, "    await %s;\n", call)
+	}
+	sb.WriteString("  }\n")
+}
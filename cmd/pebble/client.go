@@ -0,0 +1,277 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/cmd"
+)
+
+// challtestsrv configures the challenge responses of a pebble-challtestsrv
+// instance through its management API.
+type challtestsrv struct {
+	http *http.Client
+	url  string
+}
+
+func (s challtestsrv) post(path string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	resp, err := s.http.Post(s.url+path, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("challtestsrv %s: %d %s", path, resp.StatusCode, bytes.TrimSpace(respBody))
+	}
+	return nil
+}
+
+// respond provisions the response to a challenge for the identifier value and
+// returns a function that removes it.
+func (s challtestsrv) respond(chal acme.Challenge, value, keyAuth string) (func(), error) {
+	var add, del string
+	var addReq, delReq interface{}
+	switch chal.Type {
+	case acme.ChallengeHTTP01:
+		add, addReq = "/add-http01", map[string]string{"token": chal.Token, "content": keyAuth}
+		del, delReq = "/del-http01", map[string]string{"token": chal.Token}
+	case acme.ChallengeDNS01:
+		digest := sha256.Sum256([]byte(keyAuth))
+		host := "_acme-challenge." + strings.TrimPrefix(value, "*.") + "."
+		add, addReq = "/set-txt", map[string]string{
+			"host": host, "value": base64.RawURLEncoding.EncodeToString(digest[:])}
+		del, delReq = "/clear-txt", map[string]string{"host": host}
+	case acme.ChallengeTLSALPN01:
+		add, addReq = "/add-tlsalpn01", map[string]string{"host": value, "content": keyAuth}
+		del, delReq = "/del-tlsalpn01", map[string]string{"host": value}
+	default:
+		return nil, fmt.Errorf("unsupported challenge type %q", chal.Type)
+	}
+	if err := s.post(add, addReq); err != nil {
+		return nil, err
+	}
+	return func() { _ = s.post(del, delReq) }, nil
+}
+
+// smokeClient runs an issuance from start to finish, printing each step.
+type smokeClient struct {
+	*loadtestClient
+	acct *loadtestAccount
+	// respond provisions the response to a challenge and returns a function
+	// that removes it.
+	respond func(chal acme.Challenge, value, keyAuth string) (func(), error)
+	// chalType is the type of challenge answered, or empty for the first
+	// one offered.
+	chalType string
+	interval time.Duration
+	timeout  time.Duration
+	// logf prints the steps, to standard error if it is nil.
+	logf func(format string, args ...interface{})
+}
+
+func (c *smokeClient) step(format string, args ...interface{}) {
+	if c.logf != nil {
+		c.logf(format, args...)
+		return
+	}
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+}
+
+// authorize answers the challenge of the client's type of the authorization
+// and waits for the authorization to become valid.
+func (c *smokeClient) authorize(authzURL string) error {
+	var authz struct {
+		Status     string
+		Identifier acme.Identifier
+		Challenges []acme.Challenge
+	}
+	if _, err := c.post(c.acct, authzURL, []byte{}, &authz); err != nil {
+		return err
+	}
+	if authz.Status == acme.StatusValid {
+		c.step("Authorization for %s is already valid", authz.Identifier.Value)
+		return nil
+	}
+	var chal *acme.Challenge
+	for i := range authz.Challenges {
+		if c.chalType == "" || authz.Challenges[i].Type == c.chalType {
+			chal = &authz.Challenges[i]
+			break
+		}
+	}
+	if chal == nil {
+		return fmt.Errorf("no %s challenge offered for %s", c.chalType, authz.Identifier.Value)
+	}
+
+	cleanup, err := c.respond(*chal, authz.Identifier.Value, chal.Token+"."+c.acct.thumbprint)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	c.step("Answering the %s challenge for %s", chal.Type, authz.Identifier.Value)
+	if _, err := c.post(c.acct, chal.URL, []byte("{}"), nil); err != nil {
+		return err
+	}
+	result, err := c.poll(c.acct, authzURL, c.interval, c.timeout,
+		acme.StatusPending, acme.StatusProcessing)
+	if err != nil {
+		return err
+	}
+	if result["status"] != acme.StatusValid {
+		detail, _ := json.Marshal(result["challenges"])
+		return fmt.Errorf("authorization for %s is %s: %s", authz.Identifier.Value, result["status"], detail)
+	}
+	c.step("Authorization for %s is valid", authz.Identifier.Value)
+	return nil
+}
+
+// issue orders a certificate for the names and returns its PEM chain.
+func (c *smokeClient) issue(names []string, certKey *ecdsa.PrivateKey) ([]byte, error) {
+	var identifiers []acme.Identifier
+	for _, name := range names {
+		identifiers = append(identifiers, acme.Identifier{Type: acme.IdentifierDNS, Value: name})
+	}
+	payload, err := json.Marshal(map[string]interface{}{"identifiers": identifiers})
+	if err != nil {
+		return nil, err
+	}
+	var order struct {
+		Authorizations []string
+		Finalize       string
+	}
+	resp, err := c.post(c.acct, c.directory["newOrder"], payload, &order)
+	if err != nil {
+		return nil, err
+	}
+	orderURL := resp.Header.Get("Location")
+	c.step("Created order %s for %s", orderURL, strings.Join(names, ", "))
+
+	for _, authzURL := range order.Authorizations {
+		if err := c.authorize(authzURL); err != nil {
+			return nil, err
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader,
+		&x509.CertificateRequest{DNSNames: names}, certKey)
+	if err != nil {
+		return nil, err
+	}
+	payload = []byte(fmt.Sprintf(`{"csr":%q}`, base64.RawURLEncoding.EncodeToString(csr)))
+	if _, err := c.post(c.acct, order.Finalize, payload, nil); err != nil {
+		return nil, err
+	}
+	c.step("Finalized order %s", orderURL)
+	result, err := c.poll(c.acct, orderURL, c.interval, c.timeout,
+		acme.StatusReady, acme.StatusProcessing)
+	if err != nil {
+		return nil, err
+	}
+	if result["status"] != acme.StatusValid {
+		detail, _ := json.Marshal(result["error"])
+		return nil, fmt.Errorf("order is %s: %s", result["status"], detail)
+	}
+
+	certURL, _ := result["certificate"].(string)
+	_, chain, err := c.postRaw(c.acct, certURL, []byte{})
+	if err != nil {
+		return nil, err
+	}
+	c.step("Downloaded certificate %s", certURL)
+	return chain, nil
+}
+
+// client implements the client subcommand, a minimal ACME client that obtains
+// a certificate for the names given as arguments, answering challenges through
+// pebble-challtestsrv. It prints each step of the issuance and exits with
+// status 1 if any fails.
+func client(args []string) {
+	flags := flag.NewFlagSet("client", flag.ExitOnError)
+	server := flags.String("server", "https://localhost:14000/dir", "Directory URL of the ACME server")
+	caCert := flags.String("ca", "test/certs/pebble.minica.pem", "CA certificate that verifies the ACME server's HTTPS certificate")
+	responder := flags.String("challtestsrv", "http://localhost:8055", "Management URL of the pebble-challtestsrv that answers challenges")
+	chalType := flags.String("challenge", acme.ChallengeHTTP01, "Type of challenge to answer: http-01, dns-01 or tls-alpn-01")
+	email := flags.String("email", "", "Contact email address of the account")
+	certOut := flags.String("cert", "", "File the certificate chain is written to (default standard output)")
+	keyOut := flags.String("key", "", "File the certificate's private key is written to")
+	interval := flags.Duration("poll", 250*time.Millisecond, "Interval between polls of authorizations and orders")
+	timeout := flags.Duration("timeout", 2*time.Minute, "Maximum time to wait for an authorization or order to leave its pending states")
+	_ = flags.Parse(args)
+	names := flags.Args()
+	if len(names) == 0 {
+		cmd.FailOnError(errors.New("no names given"), "Usage: pebble client [flags] name...")
+	}
+
+	roots := x509.NewCertPool()
+	pemBytes, err := ioutil.ReadFile(*caCert)
+	cmd.FailOnError(err, "Reading CA certificate")
+	roots.AppendCertsFromPEM(pemBytes)
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cmd.FailOnError(err, "Generating certificate key")
+	c := &smokeClient{
+		loadtestClient: &loadtestClient{
+			http: &http.Client{
+				Timeout: 30 * time.Second,
+				Transport: &http.Transport{
+					TLSClientConfig: &tls.Config{RootCAs: roots},
+				},
+			},
+		},
+		respond: challtestsrv{
+			http: &http.Client{Timeout: 30 * time.Second},
+			url:  strings.TrimSuffix(*responder, "/"),
+		}.respond,
+		chalType: *chalType,
+		interval: *interval,
+		timeout:  *timeout,
+	}
+	c.directory, err = fetchDirectory(c.http, *server)
+	cmd.FailOnError(err, "Fetching directory")
+	c.step("Fetched the directory from %s", *server)
+
+	var contact []string
+	if *email != "" {
+		contact = []string{"mailto:" + *email}
+	}
+	c.acct, err = c.newAccount(contact...)
+	cmd.FailOnError(err, "Creating account")
+	c.step("Created account %s", c.acct.url)
+
+	chain, err := c.issue(names, certKey)
+	cmd.FailOnError(err, "Obtaining certificate")
+
+	if *keyOut != "" {
+		der, err := x509.MarshalPKCS8PrivateKey(certKey)
+		cmd.FailOnError(err, "Encoding certificate key")
+		err = ioutil.WriteFile(*keyOut, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+		cmd.FailOnError(err, "Writing certificate key")
+	}
+	if *certOut == "" {
+		_, _ = os.Stdout.Write(chain)
+		return
+	}
+	err = ioutil.WriteFile(*certOut, chain, 0644)
+	cmd.FailOnError(err, "Writing certificate chain")
+}
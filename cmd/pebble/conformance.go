@@ -0,0 +1,84 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	"github.com/letsencrypt/pebble/acmeconformance"
+	"github.com/letsencrypt/pebble/cmd"
+)
+
+// conformance implements the conformance subcommand, which runs the
+// acmeconformance suite against an ACME server and exits with status 1 if any
+// case doesn't pass. With -compare it instead sends each case to a second
+// server too, and exits with status 1 if their responses diverge.
+func conformance(args []string) {
+	flags := flag.NewFlagSet("conformance", flag.ExitOnError)
+	server := flags.String("server", "https://localhost:14000/dir", "Directory URL of the ACME server")
+	caCert := flags.String("ca", "test/certs/pebble.minica.pem", "CA certificate that verifies the ACME server's HTTPS certificate")
+	compare := flags.String("compare", "", "Directory URL of a second ACME server to compare the server's responses with")
+	compareCA := flags.String("compare-ca", "", "CA certificate that verifies the second server's HTTPS certificate (default the system roots)")
+	_ = flags.Parse(args)
+
+	config := conformanceConfig(*server, *caCert)
+	if *compare != "" {
+		compareConformance(config, conformanceConfig(*compare, *compareCA))
+		return
+	}
+
+	results, err := acmeconformance.Run(config)
+	cmd.FailOnError(err, "Running conformance suite")
+	failed := 0
+	for _, r := range results {
+		fmt.Println(r)
+		if !r.Passed() {
+			failed++
+		}
+	}
+	fmt.Printf("\n%d of %d cases passed\n", len(results)-failed, len(results))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// compareConformance runs the suite against two servers and prints how the
+// second server's responses diverge from the first's.
+func compareConformance(primary, secondary acmeconformance.Config) {
+	comparisons, err := acmeconformance.Compare(primary, secondary)
+	cmd.FailOnError(err, "Comparing servers")
+	diverged := 0
+	for _, c := range comparisons {
+		fmt.Println(c)
+		if c.Diverged() {
+			diverged++
+		}
+	}
+	fmt.Printf("\n%d of %d cases diverged\n", diverged, len(comparisons))
+	if diverged > 0 {
+		os.Exit(1)
+	}
+}
+
+// conformanceConfig configures the suite to run against the server, verifying
+// its HTTPS certificate with the CA certificate, or the system roots if caCert
+// is empty.
+func conformanceConfig(server, caCert string) acmeconformance.Config {
+	var roots *x509.CertPool
+	if caCert != "" {
+		roots = x509.NewCertPool()
+		pemBytes, err := ioutil.ReadFile(caCert)
+		cmd.FailOnError(err, "Reading CA certificate")
+		roots.AppendCertsFromPEM(pemBytes)
+	}
+	return acmeconformance.Config{
+		DirectoryURL: server,
+		HTTPClient: &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		}},
+	}
+}
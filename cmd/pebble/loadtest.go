@@ -0,0 +1,547 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	mrand "math/rand"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/cmd"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// The operations whose latencies the load test reports, in the order they
+// happen.
+const (
+	opNewAccount    = "new-account"
+	opNewOrder      = "new-order"
+	opAuthorization = "authorization"
+	opChallenge     = "challenge"
+	opValidation    = "validation"
+	opFinalize      = "finalize"
+	opIssuance      = "issuance"
+	opCertificate   = "certificate"
+	opOrder         = "order"
+
+	// maxBadNonceRetries is how many times a request rejected with a
+	// badNonce problem is retried with a fresh nonce.
+	maxBadNonceRetries = 5
+)
+
+var loadtestOps = []string{opNewAccount, opNewOrder, opAuthorization,
+	opChallenge, opValidation, opFinalize, opIssuance, opCertificate, opOrder}
+
+// loadtestResults collects the latencies and errors of each operation.
+type loadtestResults struct {
+	sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	samples   []string
+	dropped   int
+}
+
+func (r *loadtestResults) record(op string, start time.Time, err error) error {
+	r.Lock()
+	defer r.Unlock()
+	if err != nil {
+		r.errors[op]++
+		if len(r.samples) < 10 {
+			r.samples = append(r.samples, fmt.Sprintf("%s: %s", op, err))
+		}
+		return err
+	}
+	r.latencies[op] = append(r.latencies[op], time.Since(start))
+	return nil
+}
+
+func (r *loadtestResults) drop() {
+	r.Lock()
+	defer r.Unlock()
+	r.dropped++
+}
+
+// percentile returns the p-th percentile of sorted latencies.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+func (r *loadtestResults) report(elapsed time.Duration) {
+	r.Lock()
+	defer r.Unlock()
+	fmt.Printf("%-14s %8s %8s %8s %10s %10s %10s %10s\n",
+		"operation", "count", "errors", "rate/s", "p50", "p90", "p99", "max")
+	for _, op := range loadtestOps {
+		latencies := r.latencies[op]
+		if len(latencies) == 0 && r.errors[op] == 0 {
+			continue
+		}
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		row := []string{"-", "-", "-", "-"}
+		if len(latencies) > 0 {
+			row = []string{
+				percentile(latencies, 0.5).Round(time.Millisecond / 10).String(),
+				percentile(latencies, 0.9).Round(time.Millisecond / 10).String(),
+				percentile(latencies, 0.99).Round(time.Millisecond / 10).String(),
+				latencies[len(latencies)-1].Round(time.Millisecond / 10).String(),
+			}
+		}
+		fmt.Printf("%-14s %8d %8d %8.2f %10s %10s %10s %10s\n",
+			op, len(latencies), r.errors[op], float64(len(latencies))/elapsed.Seconds(),
+			row[0], row[1], row[2], row[3])
+	}
+	if r.dropped > 0 {
+		fmt.Printf("\n%d orders were not started because -concurrency orders were in progress\n", r.dropped)
+	}
+	if len(r.samples) > 0 {
+		fmt.Println("\nFirst errors:")
+		for _, s := range r.samples {
+			fmt.Printf("  %s\n", s)
+		}
+	}
+}
+
+// loadtestClient is a minimal ACME client shared by the load test's accounts,
+// also used by the client subcommand.
+type loadtestClient struct {
+	http      *http.Client
+	directory map[string]string
+	certKey   *ecdsa.PrivateKey
+
+	sync.Mutex
+	nonces []string
+}
+
+// Nonce implements jose.NonceSource, using a nonce from a previous response
+// if there is one and fetching a new nonce otherwise.
+func (c *loadtestClient) Nonce() (string, error) {
+	c.Lock()
+	if n := len(c.nonces); n > 0 {
+		nonce := c.nonces[n-1]
+		c.nonces = c.nonces[:n-1]
+		c.Unlock()
+		return nonce, nil
+	}
+	c.Unlock()
+
+	resp, err := c.http.Head(c.directory["newNonce"])
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	nonce := resp.Header.Get("Replay-Nonce")
+	if nonce == "" {
+		return "", errors.New("no Replay-Nonce in newNonce response")
+	}
+	return nonce, nil
+}
+
+// fetchDirectory returns the URLs of the ACME server's directory, keyed by
+// their names.
+func fetchDirectory(client *http.Client, server string) (map[string]string, error) {
+	resp, err := client.Get(server)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var directory map[string]json.RawMessage
+	if err := json.NewDecoder(resp.Body).Decode(&directory); err != nil {
+		return nil, fmt.Errorf("decoding directory: %s", err)
+	}
+	urls := make(map[string]string)
+	for name, value := range directory {
+		var url string
+		if json.Unmarshal(value, &url) == nil {
+			urls[name] = url
+		}
+	}
+	return urls, nil
+}
+
+// loadtestAccount is an account created by the load test.
+type loadtestAccount struct {
+	key        *ecdsa.PrivateKey
+	url        string
+	thumbprint string
+}
+
+// post sends a JWS signed by the account's key to the URL and decodes the
+// JSON response into result, if it isn't nil.
+func (c *loadtestClient) post(acct *loadtestAccount, url string, payload []byte, result interface{}) (*http.Response, error) {
+	resp, body, err := c.postRaw(acct, url, payload)
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		if err := json.Unmarshal(body, result); err != nil {
+			return nil, fmt.Errorf("decoding response from %s: %s", url, err)
+		}
+	}
+	return resp, nil
+}
+
+// postRaw sends a JWS signed by the account's key to the URL and returns the
+// response and its body. The JWS has the account's JWK embedded if it has no
+// URL yet. Requests rejected with a badNonce problem are retried.
+func (c *loadtestClient) postRaw(acct *loadtestAccount, url string, payload []byte) (*http.Response, []byte, error) {
+	var signingKey jose.SigningKey
+	if acct.url == "" {
+		signingKey = jose.SigningKey{Algorithm: jose.ES256, Key: acct.key}
+	} else {
+		signingKey = jose.SigningKey{Algorithm: jose.ES256,
+			Key: &jose.JSONWebKey{Key: acct.key, KeyID: acct.url}}
+	}
+	signer, err := jose.NewSigner(signingKey, &jose.SignerOptions{
+		NonceSource:  c,
+		EmbedJWK:     acct.url == "",
+		ExtraHeaders: map[jose.HeaderKey]interface{}{"url": url},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		jws, err := signer.Sign(payload)
+		if err != nil {
+			return nil, nil, err
+		}
+		resp, err := c.http.Post(url, "application/jose+json", strings.NewReader(jws.FullSerialize()))
+		if err != nil {
+			return nil, nil, err
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if nonce := resp.Header.Get("Replay-Nonce"); nonce != "" {
+			c.Lock()
+			c.nonces = append(c.nonces, nonce)
+			c.Unlock()
+		}
+		if resp.StatusCode/100 == 2 {
+			return resp, body, nil
+		}
+
+		var prob acme.ProblemDetails
+		_ = json.Unmarshal(body, &prob)
+		if strings.HasSuffix(prob.Type, "badNonce") && attempt < maxBadNonceRetries {
+			continue
+		}
+		return nil, nil, fmt.Errorf("POST %s: %d %s", url, resp.StatusCode, bytes.TrimSpace(body))
+	}
+}
+
+// newAccount creates an account with a new key and the contact URLs.
+func (c *loadtestClient) newAccount(contact ...string) (*loadtestAccount, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	thumbprint, err := (&jose.JSONWebKey{Key: key.Public()}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	acct := &loadtestAccount{key: key, thumbprint: base64.RawURLEncoding.EncodeToString(thumbprint)}
+	request := map[string]interface{}{"termsOfServiceAgreed": true}
+	if len(contact) > 0 {
+		request["contact"] = contact
+	}
+	payload, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.post(acct, c.directory["newAccount"], payload, nil)
+	if err != nil {
+		return nil, err
+	}
+	acct.url = resp.Header.Get("Location")
+	if acct.url == "" {
+		return nil, errors.New("no Location in new-account response")
+	}
+	return acct, nil
+}
+
+// poll fetches the object at the URL until its status is no longer one of
+// the waiting statuses and returns the object.
+func (c *loadtestClient) poll(acct *loadtestAccount, url string, interval, timeout time.Duration, waiting ...string) (map[string]interface{}, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		var obj map[string]interface{}
+		if _, err := c.post(acct, url, []byte{}, &obj); err != nil {
+			return nil, err
+		}
+		status, _ := obj["status"].(string)
+		done := true
+		for _, w := range waiting {
+			done = done && status != w
+		}
+		if done {
+			return obj, nil
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s still %s after %s", url, status, timeout)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// loadtestOrder runs one order for a random name under the domain, stopping
+// after creating it unless validate is true.
+type loadtestOrder struct {
+	client    *loadtestClient
+	results   *loadtestResults
+	responder *sync.Map
+	domain    string
+	validate  bool
+	interval  time.Duration
+	timeout   time.Duration
+}
+
+func (o *loadtestOrder) run(acct *loadtestAccount) error {
+	c, r := o.client, o.results
+	orderStart := time.Now()
+	name := fmt.Sprintf("%x.%s", mrand.Int63(), o.domain)
+
+	start := time.Now()
+	var order struct {
+		Status         string
+		Authorizations []string
+		Finalize       string
+		Certificate    string
+	}
+	payload := fmt.Sprintf(`{"identifiers":[{"type":"dns","value":%q}]}`, name)
+	resp, err := c.post(acct, c.directory["newOrder"], []byte(payload), &order)
+	if r.record(opNewOrder, start, err) != nil || !o.validate {
+		return err
+	}
+	orderURL := resp.Header.Get("Location")
+
+	for _, authzURL := range order.Authorizations {
+		start = time.Now()
+		var authz struct {
+			Challenges []acme.Challenge
+		}
+		_, err := c.post(acct, authzURL, []byte{}, &authz)
+		if r.record(opAuthorization, start, err) != nil {
+			return err
+		}
+		var chal *acme.Challenge
+		for i := range authz.Challenges {
+			if authz.Challenges[i].Type == acme.ChallengeHTTP01 {
+				chal = &authz.Challenges[i]
+			}
+		}
+		if chal == nil {
+			return r.record(opAuthorization, start, errors.New("no http-01 challenge offered"))
+		}
+		o.responder.Store(chal.Token, chal.Token+"."+acct.thumbprint)
+
+		start = time.Now()
+		_, err = c.post(acct, chal.URL, []byte("{}"), nil)
+		if r.record(opChallenge, start, err) != nil {
+			return err
+		}
+		result, err := c.poll(acct, authzURL, o.interval, o.timeout,
+			acme.StatusPending, acme.StatusProcessing)
+		o.responder.Delete(chal.Token)
+		if err == nil && result["status"] != acme.StatusValid {
+			err = fmt.Errorf("authorization for %s is %s", name, result["status"])
+		}
+		if r.record(opValidation, start, err) != nil {
+			return err
+		}
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader,
+		&x509.CertificateRequest{DNSNames: []string{name}}, c.certKey)
+	if err != nil {
+		return err
+	}
+	start = time.Now()
+	payload = fmt.Sprintf(`{"csr":%q}`, base64.RawURLEncoding.EncodeToString(csr))
+	_, err = c.post(acct, order.Finalize, []byte(payload), nil)
+	if r.record(opFinalize, start, err) != nil {
+		return err
+	}
+	result, err := c.poll(acct, orderURL, o.interval, o.timeout,
+		acme.StatusReady, acme.StatusProcessing)
+	if err == nil && result["status"] != acme.StatusValid {
+		err = fmt.Errorf("order for %s is %s", name, result["status"])
+	}
+	if r.record(opIssuance, start, err) != nil {
+		return err
+	}
+	certURL, _ := result["certificate"].(string)
+
+	start = time.Now()
+	_, err = c.post(acct, certURL, []byte{}, nil)
+	if r.record(opCertificate, start, err) != nil {
+		return err
+	}
+	return r.record(opOrder, orderStart, nil)
+}
+
+// loadtest implements the loadtest subcommand, which creates accounts and
+// orders against an ACME server at fixed rates and reports the latency of
+// each step.
+func loadtest(args []string) {
+	flags := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	server := flags.String("server", "https://localhost:14000/dir", "Directory URL of the ACME server")
+	caCert := flags.String("ca", "test/certs/pebble.minica.pem", "CA certificate that verifies the ACME server's HTTPS certificate")
+	duration := flags.Duration("duration", time.Minute, "How long to create accounts and orders for")
+	accounts := flags.Int("accounts", 1, "Number of accounts created before orders start")
+	accountRate := flags.Float64("account-rate", 0, "New accounts created per second while the test runs")
+	orderRate := flags.Float64("order-rate", 1, "New orders created per second, each for a random account")
+	validateFraction := flags.Float64("validate", 1, "Fraction of orders that are validated, finalized and downloaded")
+	concurrency := flags.Int("concurrency", 50, "Maximum number of orders in progress; orders due while it is reached are dropped")
+	domain := flags.String("domain", "loadtest.example.com", "Domain under which order names are randomly generated")
+	http01 := flags.String("http01", ":5002", "Address the built-in HTTP-01 challenge responder listens on, empty to disable it")
+	interval := flags.Duration("poll", 250*time.Millisecond, "Interval between polls of authorizations and orders")
+	timeout := flags.Duration("timeout", 2*time.Minute, "Maximum time to wait for an authorization or order to leave its pending states")
+	_ = flags.Parse(args)
+
+	roots := x509.NewCertPool()
+	pemBytes, err := ioutil.ReadFile(*caCert)
+	cmd.FailOnError(err, "Reading CA certificate")
+	roots.AppendCertsFromPEM(pemBytes)
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	cmd.FailOnError(err, "Generating certificate key")
+	client := &loadtestClient{
+		http: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: &http.Transport{
+				TLSClientConfig:     &tls.Config{RootCAs: roots},
+				MaxIdleConnsPerHost: *concurrency,
+			},
+		},
+		certKey: certKey,
+	}
+	client.directory, err = fetchDirectory(client.http, *server)
+	cmd.FailOnError(err, "Fetching directory")
+
+	responder := &sync.Map{}
+	if *http01 != "" {
+		go func() {
+			err := http.ListenAndServe(*http01, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				token := strings.TrimPrefix(r.URL.Path, "/.well-known/acme-challenge/")
+				if keyAuth, ok := responder.Load(token); ok {
+					_, _ = w.Write([]byte(keyAuth.(string)))
+					return
+				}
+				http.NotFound(w, r)
+			}))
+			cmd.FailOnError(err, "HTTP-01 responder")
+		}()
+	}
+
+	results := &loadtestResults{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+	var accountsMu sync.Mutex
+	var accts []*loadtestAccount
+	createAccount := func() {
+		start := time.Now()
+		acct, err := client.newAccount()
+		if results.record(opNewAccount, start, err) == nil {
+			accountsMu.Lock()
+			accts = append(accts, acct)
+			accountsMu.Unlock()
+		}
+	}
+	for i := 0; i < *accounts; i++ {
+		createAccount()
+	}
+	if len(accts) == 0 {
+		results.report(time.Second)
+		cmd.FailOnError(errors.New("no account could be created"), "Starting load test")
+	}
+
+	fmt.Fprintf(os.Stderr, "Load testing %s for %s with %d accounts\n", *server, *duration, len(accts))
+	order := &loadtestOrder{
+		client:    client,
+		results:   results,
+		responder: responder,
+		domain:    *domain,
+		interval:  *interval,
+		timeout:   *timeout,
+	}
+	var wg sync.WaitGroup
+	inFlight := make(chan struct{}, *concurrency)
+	begin := time.Now()
+	end := begin.Add(*duration)
+	var nextAccount, nextOrder time.Time
+	if *accountRate > 0 {
+		nextAccount = begin.Add(time.Duration(float64(time.Second) / *accountRate))
+	}
+	if *orderRate > 0 {
+		nextOrder = begin
+	}
+	for {
+		next := end
+		if !nextAccount.IsZero() && nextAccount.Before(next) {
+			next = nextAccount
+		}
+		if !nextOrder.IsZero() && nextOrder.Before(next) {
+			next = nextOrder
+		}
+		if !next.Before(end) {
+			break
+		}
+		time.Sleep(time.Until(next))
+
+		if next.Equal(nextAccount) {
+			nextAccount = nextAccount.Add(time.Duration(float64(time.Second) / *accountRate))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				createAccount()
+			}()
+			continue
+		}
+		nextOrder = nextOrder.Add(time.Duration(float64(time.Second) / *orderRate))
+		select {
+		case inFlight <- struct{}{}:
+		default:
+			results.drop()
+			continue
+		}
+		accountsMu.Lock()
+		acct := accts[mrand.Intn(len(accts))]
+		accountsMu.Unlock()
+		o := *order
+		o.validate = mrand.Float64() < *validateFraction
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_ = o.run(acct)
+			<-inFlight
+		}()
+	}
+	wg.Wait()
+	results.report(time.Since(begin))
+}
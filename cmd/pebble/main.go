@@ -2,20 +2,46 @@ package main
 
 import (
 	"context"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
 	"flag"
+	"fmt"
+	"io/ioutil"
 	"log"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/audit"
 	"github.com/letsencrypt/pebble/ca"
 	"github.com/letsencrypt/pebble/cmd"
+	"github.com/letsencrypt/pebble/cryptomode"
 	"github.com/letsencrypt/pebble/db"
+	"github.com/letsencrypt/pebble/events"
+	"github.com/letsencrypt/pebble/logging"
+	"github.com/letsencrypt/pebble/publish"
+	"github.com/letsencrypt/pebble/timeouts"
 	"github.com/letsencrypt/pebble/va"
+	"github.com/letsencrypt/pebble/webhook"
 	"github.com/letsencrypt/pebble/wfe"
+	"github.com/lucas-clemente/quic-go/http3"
 )
 
+// version is reported in the runtime info. Release builds set it with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
 type config struct {
 	Pebble struct {
 		ListenAddress string
@@ -23,10 +49,547 @@ type config struct {
 		TLSPort       int
 		Certificate   string
 		PrivateKey    string
+		// ManagementListenAddress is the address the management interface is
+		// served on. The management interface is disabled when it is empty.
+		ManagementListenAddress string
+		// ManagementAuth restricts the management interface to the configured
+		// principals. ClientCAs is a PEM file of CA certificates that verify
+		// client certificates, which are required if RequireClientCert is set.
+		ManagementAuth struct {
+			ClientCAs         string
+			RequireClientCert bool
+			Principals        []wfe.ManagementPrincipal
+		}
+		// Profiling serves pprof profiles on the management interface if
+		// Enabled. HeapStatsInterval is how often, in seconds, the heap
+		// statistics in the metrics are sampled.
+		Profiling struct {
+			Enabled           bool
+			HeapStatsInterval int
+		}
+		// DisableHTTP2 turns off HTTP/2 negotiation on the ACME listener so that
+		// clients are forced to speak HTTP/1.1.
+		DisableHTTP2 bool
+		// EnableHTTP3 additionally serves the ACME API over HTTP/3 (QUIC) on the
+		// UDP port matching ListenAddress and advertises it with Alt-Svc.
+		EnableHTTP3 bool
+		// Crypto selects the random source and cryptographic primitives: Mode
+		// is "fips", "deterministic" with randomness derived from Seed, or ""
+		// for the default.
+		Crypto cryptomode.Config
+		// KeepAlive controls the reuse of connections to the ACME and view
+		// servers. CloseAfterResponse closes every connection after its first
+		// response. MaxConnectionAge, in milliseconds, closes connections
+		// after the response to the first request made once they are that
+		// old. Idle connections are closed after Timeouts.Server.Idle.
+		KeepAlive struct {
+			CloseAfterResponse bool
+			MaxConnectionAge   int
+		}
+		// MaxBodySize is the default maximum POST body size in bytes for ACME
+		// endpoints. MaxBodySizes overrides it for individual endpoints.
+		MaxBodySize  int64
+		MaxBodySizes map[string]int64
+		// Lifetimes configures how long orders and authorizations last, in
+		// seconds. Zero values leave the defaults in place. PollExtension and
+		// MaxPollExtension make polling push expiry back, see
+		// wfe.Config.PollExpiryExtension.
+		Lifetimes struct {
+			Order            int
+			PendingAuthz     int
+			ValidAuthz       int
+			PollExtension    int
+			MaxPollExtension int
+		}
+		// ValidationOutcomes forces the result of validating matching
+		// identifiers. They can be changed at runtime through the management
+		// interface.
+		ValidationOutcomes []va.OutcomeRule
+		// DNS configures the VA's TXT record lookups for dns-01 challenges.
+		// Timeout is in milliseconds. Server defaults to the -dnsserver flag.
+		DNS struct {
+			Server             string
+			Timeout            int
+			Retries            int
+			DisableTCPFallback bool
+			ForceTCP           bool
+			EDNS0BufferSize    int
+		}
+		// ResolverOverrides maps host names, or patterns matching them, to
+		// the IP addresses HTTP-01 and TLS-ALPN-01 validations connect to.
+		ResolverOverrides map[string]string
+		// ValidationNetworks are the networks HTTP-01 and TLS-ALPN-01
+		// validations may and may not connect to.
+		ValidationNetworks va.NetworkPolicy
+		// HTTP01Caching sets whether HTTP-01 validations reuse connections,
+		// send no-cache directives and vary their URL per attempt.
+		HTTP01Caching va.HTTPCaching
+		// ChallengePorts set the ports HTTP-01 and TLS-ALPN-01 validations of
+		// matching identifiers connect to on IPv4 and IPv6 addresses.
+		ChallengePorts []va.PortRule
+		// ProxyProtocol is the version of the PROXY protocol header (1 or 2)
+		// sent on connections to HTTP-01 and TLS-ALPN-01 challenge servers,
+		// or 0 for none.
+		ProxyProtocol int
+		// ValidationRetry retries validations that fail with connection or
+		// dns problems and opens per host circuit breakers. Durations are in
+		// milliseconds.
+		ValidationRetry struct {
+			MaxAttempts      int
+			InitialBackoff   int
+			MaxBackoff       int
+			BackoffFactor    float64
+			BreakerThreshold int
+			BreakerCooldown  int
+		}
+		// LenientKeyAuthorization relaxes the checks of http-01 response
+		// bodies and dns-01 TXT values.
+		LenientKeyAuthorization struct {
+			HTTP01 bool
+			DNS01  bool
+		}
+		// CORS configures the CORS headers sent to browser based ACME clients.
+		CORS struct {
+			AllowedOrigins []string
+			ExposedHeaders []string
+		}
+		// ConcurrencyLimits configures load shedding. Requests over the limits
+		// are rejected with a 503 and a Retry-After header of RetryAfter seconds.
+		// Validations caps the challenges validated at once, queueing up to
+		// ValidationQueue more.
+		ConcurrencyLimits struct {
+			Server          int
+			PerAccount      int
+			RetryAfter      int
+			Validations     int
+			ValidationQueue int
+		}
+		// IPRateLimit throttles the directory and newNonce requests of each
+		// source IP to Rate per second with bursts of Burst, rejecting the
+		// rest with a 429.
+		IPRateLimit struct {
+			Rate  float64
+			Burst int
+		}
+		// NewAccountRateLimit limits the accounts each source IP may create
+		// without an external account binding to Accounts per Window seconds.
+		NewAccountRateLimit struct {
+			Accounts int
+			Window   int
+		}
+		// AlternateRoots is the number of extra root CAs that cross-sign the
+		// issuing intermediate. DefaultChain selects which root's chain is
+		// served by default.
+		AlternateRoots int
+		DefaultChain   int
+		// ConstrainedIntermediates are intermediates with name constraints
+		// that issue the certificates of the DNS names they permit. Orders for
+		// names none of them permits are rejected.
+		ConstrainedIntermediates []ca.ConstrainedIntermediate
+		// Serials configures the serial numbers of issued certificates.
+		Serials ca.SerialConfig
+		// CertificateFixtures makes issuance deterministic for golden
+		// certificates. NotBefore is an RFC 3339 time and Validity is in
+		// seconds.
+		CertificateFixtures struct {
+			Enabled         bool
+			RootKey         string
+			IntermediateKey string
+			NotBefore       string
+			Validity        int
+			Serial          string
+		}
+		// CT configures the SCTs embedded in issued certificates from
+		// simulated logs. StaleDays dates the SCTs that many days before
+		// issuance.
+		CT struct {
+			Logs      []ca.CTLogConfig
+			SCTs      int
+			StaleDays int
+		}
+		// IssuanceDelays delay signing the certificates of orders with an
+		// identifier matching Pattern by Delay milliseconds.
+		IssuanceDelays []struct {
+			Pattern string
+			Delay   int
+		}
+		// Redactions replace the leftmost Labels labels of the DNS names of
+		// issued certificates matching Pattern with Replacement, "?" by
+		// default.
+		Redactions []ca.Redaction
+		// Ed25519 controls whether Ed25519 keys are rejected for accounts and in
+		// CSRs.
+		Ed25519 struct {
+			RejectAccountKeys bool
+			RejectCSRKeys     bool
+		}
+		// LatencyProfiles adds artificial latency to requests, keyed by endpoint
+		// name. They can be changed at runtime through the management interface.
+		LatencyProfiles map[string]wfe.LatencyProfile
+		// MaintenanceWindows put endpoints into maintenance mode. They can be
+		// changed at runtime through the management interface.
+		MaintenanceWindows []wfe.MaintenanceWindow
+		// AccountOverrides change Pebble's behaviour for the accounts with the
+		// given IDs. They can be changed through the management interface.
+		AccountOverrides map[string]wfe.AccountOverride
+		// ExternalAccountMACKeys are the base64url encoded external account
+		// binding MAC keys by key ID. Bindings are verified if there are any.
+		ExternalAccountMACKeys map[string]string
+		// StaticTokens fix the challenge tokens of matching identifiers so
+		// that challenge responses can be provisioned in advance.
+		StaticTokens []wfe.StaticToken
+		// ChallengeTokens sets the length and alphabet of random challenge
+		// tokens, or a "weak" or "oversized" preset.
+		ChallengeTokens wfe.TokenFormat
+		// DisabledChallenges are challenge types not offered for non-wildcard
+		// DNS identifiers. They can be changed through the management
+		// interface.
+		DisabledChallenges []string
+		// ResponseEncoding enables gzip and deflate response encoding. Fault
+		// breaks encoded responses: "mismatch" or "truncated".
+		ResponseEncoding struct {
+			Enabled bool
+			Fault   string
+		}
+		// ProcessingHolds keep matching orders in processing after finalize.
+		ProcessingHolds []wfe.ProcessingHold
+		// EnableDeviceAttest allows orders for permanent identifiers, validated
+		// with the device-attest-01 challenge. Attestation objects are checked
+		// to be well formed but their statements aren't verified.
+		EnableDeviceAttest bool
+		// RequireAccountAttestation rejects new-account requests without an
+		// attestation object, which is only checked to be well formed.
+		RequireAccountAttestation bool
+		// TNAuthList allows orders for a TNAuthList identifier, validated with
+		// the tkauth-01 challenge. TokenAuthority is the token authority URL
+		// advertised in the challenges. If TokenAuthorityCertificates is set
+		// to a PEM file, Authority Token signatures must verify with the key
+		// of one of its certificates.
+		TNAuthList struct {
+			Enabled                    bool
+			TokenAuthority             string
+			TokenAuthorityCertificates string
+		}
+		// EnableOnion allows orders for version 3 .onion names, validated
+		// with the onion-csr-01 challenge.
+		EnableOnion bool
+		// EnableSubdomainAuth allows authorizations for a domain to authorize
+		// its subdomains (RFC 9444).
+		EnableSubdomainAuth bool
+		// EnableDelegation enables the STAR delegation extensions (RFC 9115).
+		// Delegations are added through the management interface.
+		EnableDelegation bool
+		// EnableFinalizeDryRun gives orders a finalizeDryRun URL checking
+		// finalize requests without issuing.
+		EnableFinalizeDryRun bool
+		// SignResponses signs directory and certificate responses with a
+		// detached JWS. Experimental.
+		SignResponses bool
+		// ReuseOrders returns an account's existing pending or ready order
+		// for new-order requests with the same identifiers.
+		ReuseOrders bool
+		// AuthzReuse attaches valid authorizations to new orders for the same
+		// names. Window limits reuse to that many seconds after validation.
+		AuthzReuse struct {
+			Enabled bool
+			Window  int
+		}
+		// RandomizeDirectory serves the ACME endpoints under a random path
+		// prefix and shuffles the directory's fields.
+		RandomizeDirectory struct {
+			Paths  bool
+			Fields bool
+		}
+		// ResponseOrdering sets the order of the identifiers, authorizations
+		// and challenges in responses.
+		ResponseOrdering wfe.ResponseOrdering
+		// GreaseEndpoints are the endpoint names, or patterns matching them,
+		// whose responses get random unknown fields and header name casing.
+		GreaseEndpoints []string
+		// ChainModes change how certificate chains are served:
+		// "include-root", "reversed" and "duplicates", and the PEM quirks
+		// "no-trailing-newline", "explanatory-text", "crlf" and "long-lines".
+		ChainModes []string
+		// MaxIdentifiers limits the identifiers of an order and
+		// MaxCSRExtensionBytes enables copying extensions requested in CSRs
+		// into certificates up to that size.
+		MaxIdentifiers       int
+		MaxCSRExtensionBytes int
+		// CertificateChunkSize serves certificates in chunks of this many
+		// bytes.
+		CertificateChunkSize int
+		// CertificateNotReady gives processing orders a certificate URL that
+		// is answered according to Mode until the certificate is issued:
+		// "order-not-ready", "not-found" or "retry-after" with RetryAfter
+		// seconds.
+		CertificateNotReady struct {
+			Mode       string
+			RetryAfter int
+		}
+		// AccountURLs sets the Format of account URLs: "key-digest",
+		// "integer", "boulder" or "legacy". AcceptLegacyKeyIDs accepts key
+		// IDs in the other formats.
+		AccountURLs struct {
+			Format             string
+			AcceptLegacyKeyIDs bool
+		}
+		// Nonces makes nonces derivable from Key so that instances sharing
+		// it accept each other's nonces. Lifetime is in seconds.
+		Nonces struct {
+			Key      string `secret:"true"`
+			Prefix   string
+			Lifetime int
+		}
+		// JWSReplayWindow is how many seconds JWS signatures are remembered
+		// to reject exact replays.
+		JWSReplayWindow int
+		// DuplicateCertificates rejects orders for a set of identifiers that
+		// already had Limit certificates issued in the last Window seconds.
+		DuplicateCertificates struct {
+			Window int
+			Limit  int
+		}
+		// MaxPendingOrders limits how many pending orders an account may
+		// have at once.
+		MaxPendingOrders int
+		// JWSFeatures maps JWS features ACME doesn't allow, e.g.
+		// "unencoded-payload" or "crit", to how they are rejected: "precise"
+		// or "generic".
+		JWSFeatures map[string]string
+		// ObjectFields omits and adds fields of the "directory", "order" and
+		// "authorization" objects served to clients.
+		ObjectFields map[string]wfe.ObjectFields
+		// UnsupportedIdentifiers and UnsupportedContacts are name patterns of
+		// identifier values and contact URLs rejected with
+		// unsupportedIdentifier and unsupportedContact problems.
+		UnsupportedIdentifiers []string
+		UnsupportedContacts    []string
+		// IdentifierStrictness sets how DNS identifiers of new orders are
+		// normalized and checked: "standard", "lenient" or "strict".
+		IdentifierStrictness string
+		// AutoFinalize finalizes orders as soon as they are ready with a key
+		// Pebble generates, served on the management interface.
+		AutoFinalize bool
+		// AccountSourceBinding binds new accounts to the IPv4 and IPv6
+		// prefixes, by default the single address, they were created from.
+		AccountSourceBinding struct {
+			Enabled          bool
+			IPv4PrefixLength int
+			IPv6PrefixLength int
+		}
+		// Namespaces isolates the accounts of clients that name different
+		// namespaces in the Header of their requests. With APIKeys the header
+		// holds an API key mapped to a namespace.
+		Namespaces struct {
+			Enabled bool
+			Header  string
+			APIKeys map[string]string `secret:"true"`
+		}
+		// SecurityHeaders adds HSTS, X-Content-Type-Options and
+		// Cache-Control: no-store headers to responses, and can omit or
+		// corrupt them on some endpoints.
+		SecurityHeaders *wfe.SecurityHeaders
+		// OrderReports records the requests about each order, keeping
+		// MaxInteractions requests of each with up to MaxBodyBytes of their
+		// bodies, for the /order-report/ management endpoint.
+		OrderReports struct {
+			Enabled         bool
+			MaxBodyBytes    int
+			MaxInteractions int
+		}
+		// DisableConditionalRequests turns off ETag and Last-Modified
+		// handling for the directory and certificates.
+		DisableConditionalRequests bool
+		// FailureRetention is how many seconds invalid orders and
+		// authorizations are kept for the /failures management endpoint.
+		FailureRetention int
+		// Webhooks sends certificate issuance, revocation and expiry events to
+		// HTTP endpoints. Durations are in seconds.
+		Webhooks struct {
+			URLs          []string
+			Events        []string
+			Secret        string `secret:"true"`
+			MaxAttempts   int
+			RetryDelay    int
+			ExpiryWarning int
+			CheckInterval int
+		}
+		// Publish writes every issued certificate to a directory, HTTP
+		// endpoint or S3-compatible bucket. RetryDelay is in seconds.
+		Publish struct {
+			Directory   string
+			URL         string
+			Prefix      string
+			MaxAttempts int
+			RetryDelay  int
+			S3          struct {
+				Endpoint        string
+				Bucket          string
+				Region          string
+				AccessKeyID     string
+				SecretAccessKey string `secret:"true"`
+			}
+		}
+		// CSRReplayPolicy rejects CSRs already used to finalize another order:
+		// "across-accounts", "across-orders" or empty to allow reuse.
+		CSRReplayPolicy string
+		// ContentTypeCheck sets how the Content-Type of POST requests is
+		// checked: "exact", "lenient" or empty to only check it in strict
+		// mode.
+		ContentTypeCheck string
+		// ChallengePolicies set the challenges offered on new authorizations
+		// for matching DNS identifiers.
+		ChallengePolicies []wfe.ChallengePolicy
+		// View customises the directory and policies of the ACME API on
+		// ListenAddress. Each of the AdditionalViews serves the same store
+		// with its own view on its own listen address.
+		View            wfe.View
+		AdditionalViews []struct {
+			ListenAddress string
+			wfe.View
+		}
+		// AccessLog writes a line for every ACME request to Path, or to stdout
+		// if Path is "-". Format is "clf" (the default) or "json".
+		AccessLog struct {
+			Path   string
+			Format string
+		}
+		// AIA sets the CA Issuers and OCSP URLs of issued certificates.
+		// "{issuer}" is replaced with the ID of the issuing certificate.
+		AIA struct {
+			CAIssuers string
+			OCSP      string
+		}
+		// ClockSkew shifts the validity period of issued certificates and
+		// the Date header of responses by a number of seconds, which may be
+		// negative.
+		ClockSkew struct {
+			NotBefore int
+			NotAfter  int
+			Date      int
+		}
+		// Signer selects how the intermediates sign certificates: "memory"
+		// or "remote" with the remote signer's URL. Delay is added to every
+		// signature, in milliseconds.
+		Signer struct {
+			Type  string
+			URL   string
+			Delay int
+		}
+		// Lint lints certificates before they are issued: "fail" refuses to
+		// issue certificates with lint errors and "warn" only logs them.
+		// Inject names lints every certificate deliberately violates.
+		Lint ca.LintConfig
+		// ShutdownGrace is how many seconds Pebble waits on SIGINT or SIGTERM
+		// for requests and validations in progress to finish before
+		// cancelling them.
+		ShutdownGrace int
+		// AuditLog records security relevant events, in memory if Enabled
+		// and also in the file at Path if it is set.
+		AuditLog struct {
+			Enabled bool
+			Path    string
+		}
+		// Problems customises the namespace of problem document types, adds
+		// extension fields to problem documents and replaces their details
+		// with templates.
+		Problems struct {
+			Namespace  string
+			Extensions map[string]map[string]interface{}
+			Details    map[string]map[string]string
+		}
+		// StoreMemoryLimit caps the approximate memory used by the store, in
+		// megabytes, by evicting completed orders and their certificates,
+		// least recently used first. 0 means unlimited.
+		StoreMemoryLimit int
+		// Logging configures the log sinks of each component, keyed by
+		// component name. Components without sinks use those of "pebble",
+		// which logs to stdout by default.
+		Logging map[string][]logging.SinkConfig
+		// Timeouts are in milliseconds. An unset timeout falls back to its
+		// component's Default and then to the top-level Default. Timeouts
+		// that are still unset keep the components' built-in values.
+		Timeouts struct {
+			Default int
+			// Server bounds the connections to the ACME, view and
+			// management servers.
+			Server struct {
+				Default    int
+				ReadHeader int
+				Read       int
+				Write      int
+				Idle       int
+			}
+			WFE struct {
+				Default int
+				Request int
+			}
+			// VA's DNS is overridden by DNS.Timeout.
+			VA struct {
+				Default int
+				Dial    int
+				TLS     int
+				Read    int
+				DNS     int
+			}
+			CA struct {
+				Default      int
+				Signing      int
+				RemoteSigner int
+			}
+			Webhook struct {
+				Default int
+				Request int
+			}
+			Publish struct {
+				Default int
+				Request int
+			}
+		}
+	}
+}
+
+// firstTimeout returns the first of the timeouts in milliseconds that is set,
+// or zero if none are.
+func firstTimeout(timeouts ...int) time.Duration {
+	for _, t := range timeouts {
+		if t > 0 {
+			return time.Duration(t) * time.Millisecond
+		}
 	}
+	return 0
+}
+
+// defaultConfig returns the config that fields missing from the config file
+// keep. Zero values leave the defaults of the Pebble components in place.
+func defaultConfig() config {
+	var c config
+	c.Pebble.ListenAddress = "0.0.0.0:14000"
+	c.Pebble.Certificate = "test/certs/localhost/cert.pem"
+	c.Pebble.PrivateKey = "test/certs/localhost/key.pem"
+	c.Pebble.HTTPPort = 5002
+	c.Pebble.TLSPort = 5001
+	c.Pebble.Lifetimes.Order = int(24 * time.Hour / time.Second)
+	c.Pebble.Lifetimes.PendingAuthz = int(time.Hour / time.Second)
+	c.Pebble.Lifetimes.ValidAuthz = int(time.Hour / time.Second)
+	c.Pebble.AccessLog.Format = wfe.AccessLogFormatCLF
+	return c
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "loadtest" {
+		loadtest(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "conformance" {
+		conformance(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "client" {
+		client(os.Args[2:])
+		return
+	}
+
 	configFile := flag.String(
 		"config",
 		"test/config/pebble-config.json",
@@ -39,7 +602,38 @@ func main() {
 		"dnsserver",
 		"",
 		"Define a custom DNS server address (ex: 192.168.0.56:5053 or 8.8.8.8:53).")
+	seedFile := flag.String(
+		"seed",
+		"",
+		"File path to a JSON seed spec used to pre-populate the store at startup")
+	seedOutput := flag.String(
+		"seed-output",
+		"",
+		"File path to write the seeded accounts (including private keys) to as JSON")
+	strictConfig := flag.Bool(
+		"strict-config",
+		false,
+		"Reject config files with unknown fields instead of warning about them")
+	printDefaultConfig := flag.Bool(
+		"print-default-config",
+		false,
+		"Print the default configuration as JSON and exit")
+	selfCheckFlag := flag.Bool(
+		"selfcheck",
+		false,
+		"Issue and revoke a certificate through the in-process ACME server at startup and exit non-zero if that fails")
+	selfCheckDomain := flag.String(
+		"selfcheck-domain",
+		"selfcheck.pebble.test",
+		"The DNS name of the certificate issued by -selfcheck")
 	flag.Parse()
+
+	if *printDefaultConfig {
+		defaultJSON, err := cmd.MarshalConfig(defaultConfig())
+		cmd.FailOnError(err, "Marshalling default config")
+		fmt.Println(string(defaultJSON))
+		return
+	}
 	if *configFile == "" {
 		flag.Usage()
 		os.Exit(1)
@@ -48,29 +642,624 @@ func main() {
 	// Log to stdout
 	logger := log.New(os.Stdout, "Pebble ", log.LstdFlags)
 
-	var c config
+	c := defaultConfig()
 	err := cmd.ReadConfigFile(*configFile, &c)
 	cmd.FailOnError(err, "Reading JSON config file into config structure")
+	unknown, err := cmd.UnknownConfigFields(*configFile, &c)
+	cmd.FailOnError(err, "Checking config file fields")
+	for _, field := range unknown {
+		logger.Printf("Warning: unknown config field %s\n", field)
+	}
+	if *strictConfig && len(unknown) > 0 {
+		cmd.FailOnError(fmt.Errorf("%d unknown fields", len(unknown)), "Checking config file fields")
+	}
+	overrides, err := cmd.ApplyEnvOverrides(&c)
+	cmd.FailOnError(err, "Applying config overrides from the environment")
+	for _, name := range overrides {
+		logger.Printf("Config overridden by %s\n", name)
+	}
+
+	loggers, err := componentLoggers(c.Pebble.Logging)
+	cmd.FailOnError(err, "Opening log sinks")
+	logger = loggers["pebble"]
 
 	if len(*resolverAddress) > 0 {
 		setupCustomDNSResolver(*resolverAddress)
 	}
 
+	err = acme.SetProblemConfig(acme.ProblemConfig{
+		Namespace:  c.Pebble.Problems.Namespace,
+		Extensions: c.Pebble.Problems.Extensions,
+		Details:    c.Pebble.Problems.Details,
+	})
+	cmd.FailOnError(err, "Invalid problem config")
+
+	err = cryptomode.Configure(c.Pebble.Crypto)
+	cmd.FailOnError(err, "Invalid crypto config")
+	switch cryptomode.Mode() {
+	case cryptomode.ModeFIPS:
+		if c.Pebble.EnableHTTP3 {
+			cmd.FailOnError(fmt.Errorf("HTTP/3 can't be restricted to FIPS approved cipher suites"),
+				"Invalid crypto config")
+		}
+		logger.Printf("Running in FIPS mode (BoringCrypto: %t)\n", cryptomode.BoringCrypto())
+	case cryptomode.ModeDeterministic:
+		logger.Printf("Using deterministic randomness, don't trust anything Pebble signs\n")
+	}
+
 	clk := clock.New()
+	t := c.Pebble.Timeouts
+	timeoutCounter := timeouts.NewCounter()
 	db := db.NewMemoryStore(clk)
-	ca := ca.New(logger, db)
-	va := va.New(logger, clk, c.Pebble.HTTPPort, c.Pebble.TLSPort)
+	db.SetMemoryLimit(c.Pebble.StoreMemoryLimit * 1024 * 1024)
+	db.SetLogger(loggers["store"])
+	notifier := webhook.New(loggers["webhook"], clk, webhook.Config{
+		URLs:          c.Pebble.Webhooks.URLs,
+		Events:        c.Pebble.Webhooks.Events,
+		Secret:        c.Pebble.Webhooks.Secret,
+		MaxAttempts:   c.Pebble.Webhooks.MaxAttempts,
+		RetryDelay:    time.Duration(c.Pebble.Webhooks.RetryDelay) * time.Second,
+		ExpiryWarning: time.Duration(c.Pebble.Webhooks.ExpiryWarning) * time.Second,
+		CheckInterval: time.Duration(c.Pebble.Webhooks.CheckInterval) * time.Second,
+
+		RequestTimeout: firstTimeout(t.Webhook.Request, t.Webhook.Default, t.Default),
+		TimeoutCounter: timeoutCounter,
+	})
+	notifier.WatchExpiry(db.GetCertificates)
+	publisher := publish.New(loggers["publish"], clk, publish.Config{
+		Directory:   c.Pebble.Publish.Directory,
+		URL:         c.Pebble.Publish.URL,
+		Prefix:      c.Pebble.Publish.Prefix,
+		MaxAttempts: c.Pebble.Publish.MaxAttempts,
+		RetryDelay:  time.Duration(c.Pebble.Publish.RetryDelay) * time.Second,
+		S3: publish.S3Config{
+			Endpoint:        c.Pebble.Publish.S3.Endpoint,
+			Bucket:          c.Pebble.Publish.S3.Bucket,
+			Region:          c.Pebble.Publish.S3.Region,
+			AccessKeyID:     c.Pebble.Publish.S3.AccessKeyID,
+			SecretAccessKey: c.Pebble.Publish.S3.SecretAccessKey,
+		},
+
+		RequestTimeout: firstTimeout(t.Publish.Request, t.Publish.Default, t.Default),
+		TimeoutCounter: timeoutCounter,
+	})
+	eventBroker := events.New(clk)
+	var auditLog *audit.Log
+	if c.Pebble.AuditLog.Path != "" {
+		auditLog, err = audit.Open(loggers["audit"], clk, c.Pebble.AuditLog.Path)
+		cmd.FailOnError(err, "Opening audit log")
+	} else if c.Pebble.AuditLog.Enabled {
+		auditLog = audit.New(loggers["audit"], clk)
+	}
+	caConfig := ca.Config{
+		AlternateRoots: c.Pebble.AlternateRoots,
+		DefaultChain:   c.Pebble.DefaultChain,
+		Notifier:       notifier,
+		Publisher:      publisher,
+		Events:         eventBroker,
+		Serials:        c.Pebble.Serials,
+
+		MaxCSRExtensionBytes: c.Pebble.MaxCSRExtensionBytes,
+		NotBeforeSkew:        time.Duration(c.Pebble.ClockSkew.NotBefore) * time.Second,
+		NotAfterSkew:         time.Duration(c.Pebble.ClockSkew.NotAfter) * time.Second,
+		AIA: ca.AIAConfig{
+			IssuerURL: c.Pebble.AIA.CAIssuers,
+			OCSPURL:   c.Pebble.AIA.OCSP,
+		},
+		Signer: ca.SignerConfig{
+			Type:  c.Pebble.Signer.Type,
+			URL:   c.Pebble.Signer.URL,
+			Delay: time.Duration(c.Pebble.Signer.Delay) * time.Millisecond,
+
+			Timeout:  firstTimeout(t.CA.RemoteSigner, t.CA.Default, t.Default),
+			Deadline: firstTimeout(t.CA.Signing, t.CA.Default, t.Default),
+		},
+		Lint:                     c.Pebble.Lint,
+		ConstrainedIntermediates: c.Pebble.ConstrainedIntermediates,
+		CT: ca.CTConfig{
+			Logs:     c.Pebble.CT.Logs,
+			SCTs:     c.Pebble.CT.SCTs,
+			StaleAge: time.Duration(c.Pebble.CT.StaleDays) * 24 * time.Hour,
+		},
+		TimeoutCounter: timeoutCounter,
+	}
+	if fixtures := c.Pebble.CertificateFixtures; fixtures.Enabled {
+		caConfig.Fixtures = &ca.FixtureConfig{
+			RootKeyFile:         fixtures.RootKey,
+			IntermediateKeyFile: fixtures.IntermediateKey,
+			Validity:            time.Duration(fixtures.Validity) * time.Second,
+			Serial:              fixtures.Serial,
+		}
+		if fixtures.NotBefore != "" {
+			notBefore, err := time.Parse(time.RFC3339, fixtures.NotBefore)
+			cmd.FailOnError(err, "Parsing certificate fixtures notBefore")
+			caConfig.Fixtures.NotBefore = notBefore
+		}
+	}
+	for _, d := range c.Pebble.IssuanceDelays {
+		caConfig.IssuanceDelays = append(caConfig.IssuanceDelays, ca.IssuanceDelay{
+			Pattern: d.Pattern,
+			Delay:   time.Duration(d.Delay) * time.Millisecond,
+		})
+	}
+	caConfig.Redactions = c.Pebble.Redactions
+	ca := ca.New(loggers["ca"], db, caConfig)
+	vaConfig := va.Config{
+		ValidAuthzLifetime: time.Duration(c.Pebble.Lifetimes.ValidAuthz) * time.Second,
+		ValidationOutcomes: c.Pebble.ValidationOutcomes,
+		LenientHTTP01:      c.Pebble.LenientKeyAuthorization.HTTP01,
+		LenientDNS01:       c.Pebble.LenientKeyAuthorization.DNS01,
+		Events:             eventBroker,
+		Retry: va.RetryConfig{
+			MaxAttempts:      c.Pebble.ValidationRetry.MaxAttempts,
+			InitialBackoff:   time.Duration(c.Pebble.ValidationRetry.InitialBackoff) * time.Millisecond,
+			MaxBackoff:       time.Duration(c.Pebble.ValidationRetry.MaxBackoff) * time.Millisecond,
+			BackoffFactor:    c.Pebble.ValidationRetry.BackoffFactor,
+			BreakerThreshold: c.Pebble.ValidationRetry.BreakerThreshold,
+			BreakerCooldown:  time.Duration(c.Pebble.ValidationRetry.BreakerCooldown) * time.Millisecond,
+		},
+		DNS: va.DNSConfig{
+			Server:             c.Pebble.DNS.Server,
+			Timeout:            firstTimeout(c.Pebble.DNS.Timeout, t.VA.DNS, t.VA.Default, t.Default),
+			Retries:            c.Pebble.DNS.Retries,
+			DisableTCPFallback: c.Pebble.DNS.DisableTCPFallback,
+			ForceTCP:           c.Pebble.DNS.ForceTCP,
+			EDNS0BufferSize:    c.Pebble.DNS.EDNS0BufferSize,
+		},
+		ResolverOverrides:        c.Pebble.ResolverOverrides,
+		MaxConcurrentValidations: c.Pebble.ConcurrencyLimits.Validations,
+		ValidationQueueSize:      c.Pebble.ConcurrencyLimits.ValidationQueue,
+		NetworkPolicy:            c.Pebble.ValidationNetworks,
+		HTTPCaching:              c.Pebble.HTTP01Caching,
+		PortRules:                c.Pebble.ChallengePorts,
+		ProxyProtocol:            c.Pebble.ProxyProtocol,
+		Timeouts: va.Timeouts{
+			Dial: firstTimeout(t.VA.Dial, t.VA.Default, t.Default),
+			TLS:  firstTimeout(t.VA.TLS, t.VA.Default, t.Default),
+			Read: firstTimeout(t.VA.Read, t.VA.Default, t.Default),
+		},
+		TimeoutCounter: timeoutCounter,
+	}
+	if vaConfig.DNS.Server == "" {
+		vaConfig.DNS.Server = *resolverAddress
+	}
+	if c.Pebble.TNAuthList.TokenAuthorityCertificates != "" {
+		keys, err := loadPublicKeys(c.Pebble.TNAuthList.TokenAuthorityCertificates)
+		cmd.FailOnError(err, "Loading token authority certificates")
+		vaConfig.AuthorityTokenValidators = map[string]va.AuthorityTokenValidator{
+			acme.TKAuthTypeATC: va.ATCValidator{TrustedKeys: keys},
+		}
+	}
+	va := va.New(loggers["va"], clk, c.Pebble.HTTPPort, c.Pebble.TLSPort, vaConfig)
+
+	wfeConfig := wfe.Config{
+		MaxBodySize:          c.Pebble.MaxBodySize,
+		MaxBodySizes:         c.Pebble.MaxBodySizes,
+		OrderLifetime:        time.Duration(c.Pebble.Lifetimes.Order) * time.Second,
+		PendingAuthzLifetime: time.Duration(c.Pebble.Lifetimes.PendingAuthz) * time.Second,
+		CORSAllowedOrigins:   c.Pebble.CORS.AllowedOrigins,
+		CORSExposedHeaders:   c.Pebble.CORS.ExposedHeaders,
+
+		PollExpiryExtension:    time.Duration(c.Pebble.Lifetimes.PollExtension) * time.Second,
+		MaxPollExpiryExtension: time.Duration(c.Pebble.Lifetimes.MaxPollExtension) * time.Second,
+
+		MaxConcurrentRequests:           c.Pebble.ConcurrencyLimits.Server,
+		MaxConcurrentRequestsPerAccount: c.Pebble.ConcurrencyLimits.PerAccount,
+		OverloadRetryAfter:              time.Duration(c.Pebble.ConcurrencyLimits.RetryAfter) * time.Second,
+		IPRateLimit:                     c.Pebble.IPRateLimit.Rate,
+		IPRateBurst:                     c.Pebble.IPRateLimit.Burst,
+		NewAccountLimit:                 c.Pebble.NewAccountRateLimit.Accounts,
+		NewAccountWindow:                time.Duration(c.Pebble.NewAccountRateLimit.Window) * time.Second,
+
+		RejectEd25519AccountKeys: c.Pebble.Ed25519.RejectAccountKeys,
+		RejectEd25519CSRKeys:     c.Pebble.Ed25519.RejectCSRKeys,
+
+		RequireAccountAttestation: c.Pebble.RequireAccountAttestation,
+
+		LatencyProfiles:        c.Pebble.LatencyProfiles,
+		MaintenanceWindows:     c.Pebble.MaintenanceWindows,
+		AccountOverrides:       c.Pebble.AccountOverrides,
+		ExternalAccountMACKeys: c.Pebble.ExternalAccountMACKeys,
+		StaticTokens:           c.Pebble.StaticTokens,
+		TokenFormat:            c.Pebble.ChallengeTokens,
+		DisabledChallenges:     c.Pebble.DisabledChallenges,
+		ProcessingHolds:        c.Pebble.ProcessingHolds,
+		EnableDeviceAttest:     c.Pebble.EnableDeviceAttest,
+		EnableTNAuthList:       c.Pebble.TNAuthList.Enabled,
+		EnableOnion:            c.Pebble.EnableOnion,
+		TokenAuthority:         c.Pebble.TNAuthList.TokenAuthority,
+
+		ResponseEncoding:      c.Pebble.ResponseEncoding.Enabled,
+		ResponseEncodingFault: c.Pebble.ResponseEncoding.Fault,
+
+		CertificateNotReady:   c.Pebble.CertificateNotReady.Mode,
+		CertificateRetryAfter: time.Duration(c.Pebble.CertificateNotReady.RetryAfter) * time.Second,
+
+		AccountURLFormat: c.Pebble.AccountURLs.Format,
+		LegacyKeyIDs:     c.Pebble.AccountURLs.AcceptLegacyKeyIDs,
+
+		EnableSubdomainAuth: c.Pebble.EnableSubdomainAuth,
+		ReuseOrders:         c.Pebble.ReuseOrders,
+		ReuseAuthzs:         c.Pebble.AuthzReuse.Enabled,
+		AuthzReuseWindow:    time.Duration(c.Pebble.AuthzReuse.Window) * time.Second,
+		RandomizePaths:      c.Pebble.RandomizeDirectory.Paths,
+		ShuffleDirectory:    c.Pebble.RandomizeDirectory.Fields,
+		GreaseEndpoints:     c.Pebble.GreaseEndpoints,
+		ResponseOrdering:    c.Pebble.ResponseOrdering,
+		ChainModes:          c.Pebble.ChainModes,
+		MaxIdentifiers:      c.Pebble.MaxIdentifiers,
+		EnableDelegation:    c.Pebble.EnableDelegation,
+		Notifier:            notifier,
+		AccessLogFormat:     c.Pebble.AccessLog.Format,
+		ChallengePolicies:   c.Pebble.ChallengePolicies,
+		CSRReplayPolicy:     c.Pebble.CSRReplayPolicy,
+		ContentTypeCheck:    c.Pebble.ContentTypeCheck,
+		Events:              eventBroker,
+		Audit:               auditLog,
+		RequestTimeout:      firstTimeout(t.WFE.Request, t.WFE.Default, t.Default),
+		TimeoutCounter:      timeoutCounter,
+
+		ManagementPrincipals: c.Pebble.ManagementAuth.Principals,
+		EnableProfiling:      c.Pebble.Profiling.Enabled,
+		HeapStatsInterval:    time.Duration(c.Pebble.Profiling.HeapStatsInterval) * time.Second,
+		CertificateChunkSize: c.Pebble.CertificateChunkSize,
+		EnableFinalizeDryRun: c.Pebble.EnableFinalizeDryRun,
+		SignResponses:        c.Pebble.SignResponses,
+		NonceKey:             c.Pebble.Nonces.Key,
+		NoncePrefix:          c.Pebble.Nonces.Prefix,
+		NonceLifetime:        time.Duration(c.Pebble.Nonces.Lifetime) * time.Second,
+		JWSReplayWindow:      time.Duration(c.Pebble.JWSReplayWindow) * time.Second,
+		DateSkew:             time.Duration(c.Pebble.ClockSkew.Date) * time.Second,
+		JWSFeatureHandling:   c.Pebble.JWSFeatures,
+
+		DisableConditionalRequests: c.Pebble.DisableConditionalRequests,
+		FailureRetention:           time.Duration(c.Pebble.FailureRetention) * time.Second,
+		DuplicateCertificates: wfe.DuplicateCertificateLimit{
+			Window: time.Duration(c.Pebble.DuplicateCertificates.Window) * time.Second,
+			Limit:  c.Pebble.DuplicateCertificates.Limit,
+		},
+		MaxPendingOrders: c.Pebble.MaxPendingOrders,
+
+		ObjectFields:           c.Pebble.ObjectFields,
+		UnsupportedIdentifiers: c.Pebble.UnsupportedIdentifiers,
+		UnsupportedContacts:    c.Pebble.UnsupportedContacts,
+		IdentifierStrictness:   c.Pebble.IdentifierStrictness,
+		AutoFinalize:           c.Pebble.AutoFinalize,
+		SecurityHeaders:        c.Pebble.SecurityHeaders,
+	}
+	if c.Pebble.AccountSourceBinding.Enabled {
+		wfeConfig.AccountSourceBinding = &wfe.AccountSourceBinding{
+			IPv4PrefixLength: c.Pebble.AccountSourceBinding.IPv4PrefixLength,
+			IPv6PrefixLength: c.Pebble.AccountSourceBinding.IPv6PrefixLength,
+		}
+	}
+	if c.Pebble.OrderReports.Enabled {
+		wfeConfig.OrderReports = &wfe.OrderReports{
+			MaxBodyBytes:    c.Pebble.OrderReports.MaxBodyBytes,
+			MaxInteractions: c.Pebble.OrderReports.MaxInteractions,
+		}
+	}
+	if c.Pebble.Namespaces.Enabled {
+		wfeConfig.Namespaces = &wfe.Namespaces{
+			Header:  c.Pebble.Namespaces.Header,
+			APIKeys: c.Pebble.Namespaces.APIKeys,
+		}
+	}
+	switch c.Pebble.AccessLog.Path {
+	case "":
+	case "-":
+		wfeConfig.AccessLog = os.Stdout
+	default:
+		accessLog, err := os.OpenFile(c.Pebble.AccessLog.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+		cmd.FailOnError(err, "Opening access log")
+		defer accessLog.Close()
+		wfeConfig.AccessLog = accessLog
+	}
+	wfe := wfe.New(loggers["wfe"], clk, db, va, ca, *strictMode, wfeConfig)
+	muxHandler := wfe.ViewHandler(c.Pebble.View)
+
+	if *seedFile != "" {
+		seedStore(logger, wfe, *seedFile, *seedOutput)
+	}
+
+	serverTimeouts := func(srv *http.Server) *http.Server {
+		srv.ReadHeaderTimeout = firstTimeout(t.Server.ReadHeader, t.Server.Default, t.Default)
+		srv.ReadTimeout = firstTimeout(t.Server.Read, t.Server.Default, t.Default)
+		srv.WriteTimeout = firstTimeout(t.Server.Write, t.Server.Default, t.Default)
+		srv.IdleTimeout = firstTimeout(t.Server.Idle, t.Server.Default, t.Default)
+		return srv
+	}
+	keepAlive := func(srv *http.Server) *http.Server {
+		k := c.Pebble.KeepAlive
+		if !k.CloseAfterResponse && k.MaxConnectionAge <= 0 {
+			return srv
+		}
+		srv.ConnContext = func(ctx context.Context, _ net.Conn) context.Context {
+			return context.WithValue(ctx, connStartKey{}, time.Now())
+		}
+		srv.Handler = keepAliveHandler(k.CloseAfterResponse,
+			time.Duration(k.MaxConnectionAge)*time.Millisecond, srv.Handler)
+		return srv
+	}
+	srv := keepAlive(serverTimeouts(&http.Server{
+		Addr:      c.Pebble.ListenAddress,
+		Handler:   muxHandler,
+		TLSConfig: cryptomode.RestrictTLS(&tls.Config{}),
+	}))
+	// The listeners are bound before serving so that Pebble is only marked
+	// ready once all of them are.
+	listener, err := net.Listen("tcp", c.Pebble.ListenAddress)
+	cmd.FailOnError(err, "Listening on the ACME address")
+	listeners := map[string]string{"acme": listener.Addr().String()}
+
+	// A non-nil, empty TLSNextProto map stops net/http from configuring HTTP/2
+	// for the server.
+	if c.Pebble.DisableHTTP2 {
+		srv.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+		logger.Printf("HTTP/2 disabled on %s\n", c.Pebble.ListenAddress)
+	}
 
-	wfe := wfe.New(logger, clk, db, va, ca, *strictMode)
-	muxHandler := wfe.Handler()
+	if c.Pebble.EnableHTTP3 {
+		quicSrv := &http3.Server{
+			Server: &http.Server{
+				Addr:    c.Pebble.ListenAddress,
+				Handler: muxHandler,
+			},
+		}
+		srv.Handler = altSvcHandler(quicSrv, srv.Handler)
 
+		go func() {
+			logger.Printf("Pebble serving HTTP/3 on UDP %s\n", c.Pebble.ListenAddress)
+			err := quicSrv.ListenAndServeTLS(c.Pebble.Certificate, c.Pebble.PrivateKey)
+			cmd.FailOnError(err, "Calling HTTP/3 ListenAndServeTLS()")
+		}()
+	}
+
+	for _, v := range c.Pebble.AdditionalViews {
+		listenAddress, viewHandler := v.ListenAddress, wfe.ViewHandler(v.View)
+		viewListener, err := net.Listen("tcp", listenAddress)
+		cmd.FailOnError(err, "Listening on additional view address")
+		listeners["view:"+v.Name] = viewListener.Addr().String()
+		logger.Printf("Serving view %q on %s\n", v.Name, listenAddress)
+		viewSrv := keepAlive(serverTimeouts(&http.Server{
+			Handler:   viewHandler,
+			TLSConfig: cryptomode.RestrictTLS(&tls.Config{}),
+		}))
+		go func() {
+			err := viewSrv.ServeTLS(
+				viewListener,
+				c.Pebble.Certificate,
+				c.Pebble.PrivateKey)
+			cmd.FailOnError(err, "Calling ServeTLS() for additional view")
+		}()
+	}
+
+	if c.Pebble.ManagementListenAddress != "" {
+		managementSrv := serverTimeouts(&http.Server{
+			Addr:      c.Pebble.ManagementListenAddress,
+			Handler:   wfe.ManagementHandler(),
+			TLSConfig: cryptomode.RestrictTLS(&tls.Config{}),
+		})
+		if c.Pebble.ManagementAuth.ClientCAs != "" {
+			pemBytes, err := ioutil.ReadFile(c.Pebble.ManagementAuth.ClientCAs)
+			cmd.FailOnError(err, "Reading management client CAs")
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pemBytes) {
+				cmd.FailOnError(fmt.Errorf("no certificates found in %q",
+					c.Pebble.ManagementAuth.ClientCAs), "Loading management client CAs")
+			}
+			managementSrv.TLSConfig.ClientCAs = pool
+			managementSrv.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+			if c.Pebble.ManagementAuth.RequireClientCert {
+				managementSrv.TLSConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+		}
+		managementListener, err := net.Listen("tcp", c.Pebble.ManagementListenAddress)
+		cmd.FailOnError(err, "Listening on the management address")
+		listeners["management"] = managementListener.Addr().String()
+		go func() {
+			logger.Printf("Management interface listening on: %s\n", c.Pebble.ManagementListenAddress)
+			err := managementSrv.ServeTLS(
+				managementListener,
+				c.Pebble.Certificate,
+				c.Pebble.PrivateKey)
+			cmd.FailOnError(err, "Calling ServeTLS() for management interface")
+		}()
+	}
+
+	shutdown := shutdownOnSignal(logger, srv, va, time.Duration(c.Pebble.ShutdownGrace)*time.Second)
+
+	info := runtimeInfo(c, listeners)
+	wfe.SetRuntimeInfo(info)
+	logBanner(logger, info)
+
+	if *selfCheckFlag {
+		started := time.Now()
+		err := selfCheck(logger.Printf, &wfe, muxHandler, *selfCheckDomain)
+		cmd.FailOnError(err, "Self-check failed")
+		logger.Printf("  self-check:      passed, issued and revoked a certificate for %s in %s\n",
+			*selfCheckDomain, time.Since(started).Round(time.Millisecond))
+	}
+
+	wfe.SetReady()
 	logger.Printf("Pebble running, listening on: %s\n", c.Pebble.ListenAddress)
-	err = http.ListenAndServeTLS(
-		c.Pebble.ListenAddress,
+	err = srv.ServeTLS(
+		listener,
 		c.Pebble.Certificate,
-		c.Pebble.PrivateKey,
-		muxHandler)
-	cmd.FailOnError(err, "Calling ListenAndServeTLS()")
+		c.Pebble.PrivateKey)
+	if err != http.ErrServerClosed {
+		cmd.FailOnError(err, "Calling ServeTLS()")
+	}
+	<-shutdown
+}
+
+// runtimeInfo returns the runtime info served by the management interface
+// for the config and the addresses of the listeners.
+func runtimeInfo(c config, listeners map[string]string) wfe.RuntimeInfo {
+	redacted, err := cmd.RedactedConfig(c)
+	cmd.FailOnError(err, "Redacting config")
+	return wfe.RuntimeInfo{
+		Version:   version,
+		Features:  cmd.EnabledFeatures(c.Pebble),
+		Listeners: listeners,
+		Config:    redacted,
+	}
+}
+
+// logBanner logs the version, listeners and enabled features of Pebble on
+// startup.
+// logComponents are the components whose log sinks can be configured.
+var logComponents = []string{"pebble", "wfe", "va", "ca", "store", "webhook", "publish", "audit"}
+
+// componentLoggers returns the logger of each component, writing to the sinks
+// configured for it or else to those configured for "pebble".
+func componentLoggers(config map[string][]logging.SinkConfig) (map[string]*log.Logger, error) {
+	for name := range config {
+		known := false
+		for _, c := range logComponents {
+			known = known || c == name
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown logging component %q, expected one of %s",
+				name, strings.Join(logComponents, ", "))
+		}
+	}
+
+	sinks := logging.NewSinks()
+	loggers := make(map[string]*log.Logger)
+	for _, name := range logComponents {
+		sinkConfigs := config[name]
+		if len(sinkConfigs) == 0 {
+			sinkConfigs = config["pebble"]
+		}
+		logger, err := sinks.Logger("Pebble ", sinkConfigs)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %s", name, err)
+		}
+		loggers[name] = logger
+	}
+	return loggers, nil
+}
+
+func logBanner(logger *log.Logger, info wfe.RuntimeInfo) {
+	logger.Printf("Pebble %s\n", info.Version)
+	var names []string
+	for name := range info.Listeners {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		logger.Printf("  %-16s %s\n", name+":", info.Listeners[name])
+	}
+	if len(info.Features) > 0 {
+		logger.Printf("  features:        %s\n", strings.Join(info.Features, ", "))
+	}
+}
+
+// shutdownOnSignal shuts the ACME server and the VA down on SIGINT or SIGTERM,
+// giving the requests and validations in progress up to grace to finish
+// before they are cancelled. The returned channel is closed once both are
+// shut down.
+func shutdownOnSignal(logger *log.Logger, srv *http.Server, v *va.VAImpl, grace time.Duration) <-chan struct{} {
+	done := make(chan struct{})
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		logger.Printf("Received %s, shutting down within %s\n", sig, grace)
+		ctx, cancel := context.WithTimeout(context.Background(), grace)
+		defer cancel()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := srv.Shutdown(ctx); err != nil {
+				logger.Printf("Closing requests still in progress: %s\n", err)
+				_ = srv.Close()
+			}
+		}()
+		v.Shutdown(ctx)
+		wg.Wait()
+		close(done)
+	}()
+	return done
+}
+
+// seedStore pre-populates the WFE's store from the seed spec in seedFile and
+// writes the seeded accounts to outputFile, if one is provided.
+func seedStore(logger *log.Logger, w wfe.WebFrontEndImpl, seedFile, outputFile string) {
+	var spec wfe.SeedSpec
+	err := cmd.ReadConfigFile(seedFile, &spec)
+	cmd.FailOnError(err, "Reading JSON seed file into seed spec structure")
+
+	seeded, err := w.Seed(spec)
+	cmd.FailOnError(err, "Seeding the store")
+
+	if outputFile == "" {
+		return
+	}
+	seededJSON, err := json.MarshalIndent(seeded, "", "  ")
+	cmd.FailOnError(err, "Marshalling seeded accounts")
+	err = ioutil.WriteFile(outputFile, seededJSON, 0600)
+	cmd.FailOnError(err, "Writing seeded accounts")
+	logger.Printf("Wrote %d seeded accounts to %s\n", len(seeded), outputFile)
+}
+
+// loadPublicKeys returns the public keys of the PEM encoded certificates in
+// the given file.
+func loadPublicKeys(certFile string) ([]crypto.PublicKey, error) {
+	pemBytes, err := ioutil.ReadFile(certFile)
+	if err != nil {
+		return nil, err
+	}
+	var keys []crypto.PublicKey
+	for {
+		var block *pem.Block
+		block, pemBytes = pem.Decode(pemBytes)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, cert.PublicKey)
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no certificates found in %q", certFile)
+	}
+	return keys, nil
+}
+
+// altSvcHandler wraps the provided handler so that every TCP response
+// advertises the HTTP/3 endpoint with an Alt-Svc header. Clients that support
+// QUIC may then switch protocols for subsequent requests.
+func altSvcHandler(quicSrv *http3.Server, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Errors here only mean the Alt-Svc header is omitted. The TCP response is
+		// still perfectly usable.
+		_ = quicSrv.SetQuicHeaders(w.Header())
+		next.ServeHTTP(w, r)
+	})
+}
+
+// connStartKey is the context key of the time a connection was accepted.
+type connStartKey struct{}
+
+// keepAliveHandler asks net/http to close the connection after the response,
+// with "Connection: close" over HTTP/1.1 and a GOAWAY over HTTP/2, if
+// closeAll is set or the connection is older than maxAge.
+func keepAliveHandler(closeAll bool, maxAge time.Duration, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started, ok := r.Context().Value(connStartKey{}).(time.Time)
+		if closeAll || (maxAge > 0 && ok && time.Since(started) >= maxAge) {
+			w.Header().Set("Connection", "close")
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 func setupCustomDNSResolver(dnsResolverAddress string) {
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/va"
+	"github.com/letsencrypt/pebble/wfe"
+)
+
+const (
+	selfCheckPollInterval = 50 * time.Millisecond
+	selfCheckTimeout      = 30 * time.Second
+)
+
+// handlerTransport is a http.RoundTripper that serves requests with a handler
+// in the same process, without a network connection.
+type handlerTransport struct {
+	handler http.Handler
+}
+
+func (t handlerTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	r := request.Clone(request.Context())
+	r.Host = request.URL.Host
+	r.RequestURI = request.URL.RequestURI()
+	r.RemoteAddr = "127.0.0.1:0"
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	recorder := httptest.NewRecorder()
+	t.handler.ServeHTTP(recorder, r)
+	return recorder.Result(), nil
+}
+
+// selfCheck issues a certificate for the domain through the ACME handler and
+// the in-process CA and VA, from creating an account to revoking the
+// certificate, and deactivates the account. Validation of the account's
+// challenges is forced to succeed with an account override, which is removed
+// again afterwards.
+func selfCheck(logf func(string, ...interface{}), w *wfe.WebFrontEndImpl, handler http.Handler, domain string) error {
+	c := &smokeClient{
+		loadtestClient: &loadtestClient{
+			http: &http.Client{Transport: handlerTransport{handler}},
+		},
+		respond: func(acme.Challenge, string, string) (func(), error) {
+			return func() {}, nil
+		},
+		interval: selfCheckPollInterval,
+		timeout:  selfCheckTimeout,
+		logf: func(format string, args ...interface{}) {
+			logf("Self-check: "+format+"\n", args...)
+		},
+	}
+
+	var err error
+	c.directory, err = fetchDirectory(c.http, "http://selfcheck"+"/dir")
+	if err != nil {
+		return fmt.Errorf("fetching the directory: %s", err)
+	}
+	c.acct, err = c.newAccount()
+	if err != nil {
+		return fmt.Errorf("creating an account: %s", err)
+	}
+	c.step("Created account %s", c.acct.url)
+
+	overrides := w.AccountOverrides()
+	forced := w.AccountOverrides()
+	forced[path.Base(c.acct.url)] = wfe.AccountOverride{ValidationOutcome: va.OutcomeValid}
+	if err := w.SetAccountOverrides(forced); err != nil {
+		return fmt.Errorf("forcing validation: %s", err)
+	}
+	defer func() { _ = w.SetAccountOverrides(overrides) }()
+
+	certKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return err
+	}
+	chain, err := c.issue([]string{domain}, certKey)
+	if err != nil {
+		return fmt.Errorf("issuing a certificate: %s", err)
+	}
+	block, _ := pem.Decode(chain)
+	if block == nil {
+		return errors.New("issuing a certificate: no PEM certificate in the chain")
+	}
+
+	payload := []byte(fmt.Sprintf(`{"certificate":%q}`, base64.RawURLEncoding.EncodeToString(block.Bytes)))
+	if _, err := c.post(c.acct, c.directory["revokeCert"], payload, nil); err != nil {
+		return fmt.Errorf("revoking the certificate: %s", err)
+	}
+	c.step("Revoked the certificate")
+
+	if _, err := c.post(c.acct, c.acct.url, []byte(`{"status":"deactivated"}`), nil); err != nil {
+		return fmt.Errorf("deactivating the account: %s", err)
+	}
+	c.step("Deactivated account %s", c.acct.url)
+	return nil
+}
@@ -0,0 +1,409 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// UnknownConfigFields returns the paths of the fields in the JSON config file
+// that don't correspond to a field of out, which must be a pointer to a
+// struct. Like encoding/json, field names are matched case insensitively.
+// Where a known field has a similar name it is suggested.
+func UnknownConfigFields(filename string, out interface{}) ([]string, error) {
+	data, err := loadConfigData(filename)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, describeJSONError(data, err)
+	}
+	var unknown []string
+	findUnknownFields(decoded, reflect.TypeOf(out), "", &unknown)
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+func findUnknownFields(value interface{}, t reflect.Type, path string, unknown *[]string) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Map:
+			for k, elem := range v {
+				findUnknownFields(elem, t.Elem(), path+"."+k, unknown)
+			}
+		case reflect.Struct:
+			fields := jsonFields(t)
+			for k, elem := range v {
+				field, ok := lookupField(fields, k)
+				if !ok {
+					msg := strings.TrimPrefix(path+"."+k, ".")
+					if suggestion := closestField(fields, k); suggestion != "" {
+						msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+					}
+					*unknown = append(*unknown, msg)
+					continue
+				}
+				findUnknownFields(elem, field.Type, path+"."+k, unknown)
+			}
+		}
+	case []interface{}:
+		if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+			for i, elem := range v {
+				findUnknownFields(elem, t.Elem(), fmt.Sprintf("%s[%d]", path, i), unknown)
+			}
+		}
+	}
+}
+
+// jsonFields returns the fields of a struct type keyed by their JSON name,
+// with the fields of embedded structs promoted as encoding/json does.
+func jsonFields(t reflect.Type) map[string]reflect.StructField {
+	fields := make(map[string]reflect.StructField)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")[0]
+		if tag == "-" {
+			continue
+		}
+		if f.Anonymous && tag == "" && f.Type.Kind() == reflect.Struct {
+			for name, promoted := range jsonFields(f.Type) {
+				if _, shadowed := fields[name]; !shadowed {
+					fields[name] = promoted
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if tag == "" {
+			tag = f.Name
+		}
+		fields[tag] = f
+	}
+	return fields
+}
+
+func lookupField(fields map[string]reflect.StructField, key string) (reflect.StructField, bool) {
+	if f, ok := fields[key]; ok {
+		return f, true
+	}
+	for name, f := range fields {
+		if strings.EqualFold(name, key) {
+			return f, true
+		}
+	}
+	return reflect.StructField{}, false
+}
+
+// closestField returns the config name of the field whose name is within an
+// edit distance of two of the key, or "" if there is none.
+func closestField(fields map[string]reflect.StructField, key string) string {
+	best, bestDistance := "", 3
+	for name := range fields {
+		d := editDistance(strings.ToLower(name), strings.ToLower(key))
+		if d < bestDistance || (d == bestDistance && name < best) {
+			best, bestDistance = name, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return lowerCamel(best)
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// ApplyEnvOverrides sets the fields of out, which must be a pointer to a
+// struct, from environment variables named after the field's path in upper
+// snake case, e.g. PEBBLE_LISTEN_ADDRESS for Pebble.ListenAddress. String
+// fields take the variable's value as-is and every other field takes a JSON
+// value, e.g. PEBBLE_HTTP_PORT=5002 or
+// PEBBLE_CHALLENGE_POLICIES='[{"challenges": ["dns-01"]}]'.
+// The names of the variables applied are returned.
+func ApplyEnvOverrides(out interface{}) ([]string, error) {
+	var applied []string
+	err := applyEnvOverrides(reflect.ValueOf(out).Elem(), "", &applied)
+	return applied, err
+}
+
+func applyEnvOverrides(v reflect.Value, prefix string, applied *[]string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		field := v.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := applyEnvOverrides(field, prefix, applied); err != nil {
+				return err
+			}
+			continue
+		}
+		name := prefix + EnvName(f.Name)
+		if f.Type.Kind() == reflect.Struct {
+			if err := applyEnvOverrides(field, name+"_", applied); err != nil {
+				return err
+			}
+			continue
+		}
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+		if f.Type.Kind() == reflect.String {
+			field.SetString(value)
+		} else if err := json.Unmarshal([]byte(value), field.Addr().Interface()); err != nil {
+			return fmt.Errorf("environment variable %s: %s", name, err)
+		}
+		*applied = append(*applied, name)
+	}
+	return nil
+}
+
+// EnvName returns the upper snake case environment variable name for a Go
+// field name, e.g. HTTP_PORT for HTTPPort.
+func EnvName(fieldName string) string {
+	return strings.ToUpper(strings.Join(nameWords(fieldName), "_"))
+}
+
+// MarshalConfig marshals a config struct as indented JSON with the lower camel
+// case field names used in Pebble's config files.
+func MarshalConfig(config interface{}) ([]byte, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(renameFields(decoded, reflect.TypeOf(config)), "", "  ")
+}
+
+// redactedValue replaces the values of secret config fields in RedactedConfig.
+const redactedValue = "[redacted]"
+
+// RedactedConfig returns a config struct as the decoded JSON value of its
+// config file representation, with the lower camel case field names of
+// MarshalConfig. The values of fields tagged `secret:"true"` are replaced by
+// "[redacted]" if they are set.
+func RedactedConfig(config interface{}) (interface{}, error) {
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return nil, err
+	}
+	t := reflect.TypeOf(config)
+	return renameFields(redactSecrets(decoded, t), t), nil
+}
+
+// redactSecrets replaces the values of the secret fields in a decoded JSON
+// value marshalled from the given type.
+func redactSecrets(value interface{}, t reflect.Type) interface{} {
+	if t == nil {
+		return value
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t)
+			for k, elem := range v {
+				f := fields[k]
+				if f.Tag.Get("secret") == "true" {
+					if elem != nil && elem != "" {
+						v[k] = redactedValue
+					}
+					continue
+				}
+				v[k] = redactSecrets(elem, f.Type)
+			}
+		case reflect.Map:
+			for k, elem := range v {
+				v[k] = redactSecrets(elem, t.Elem())
+			}
+		}
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = redactSecrets(elem, t.Elem())
+		}
+	}
+	return value
+}
+
+// EnabledFeatures returns the sorted dotted config file paths of the boolean
+// fields of a config struct that are set to true. Maps and lists aren't
+// searched, and a trailing ".enabled" is left out of the paths.
+func EnabledFeatures(config interface{}) []string {
+	var features []string
+	enabledFeatures(reflect.ValueOf(config), "", &features)
+	sort.Strings(features)
+	return features
+}
+
+func enabledFeatures(v reflect.Value, prefix string, features *[]string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name := prefix + lowerCamel(f.Name)
+		switch f.Type.Kind() {
+		case reflect.Bool:
+			if v.Field(i).Bool() {
+				*features = append(*features, strings.TrimSuffix(name, ".enabled"))
+			}
+		case reflect.Struct:
+			enabledFeatures(v.Field(i), name+".", features)
+		}
+	}
+}
+
+// renameFields returns the decoded JSON value with the keys of objects that
+// were marshalled from structs in lower camel case. Keys of maps are kept.
+func renameFields(value interface{}, t reflect.Type) interface{} {
+	if t == nil {
+		return value
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		renamed := make(map[string]interface{}, len(v))
+		switch t.Kind() {
+		case reflect.Struct:
+			fields := jsonFields(t)
+			for k, elem := range v {
+				f := fields[k]
+				if f.Tag.Get("json") == "" {
+					k = lowerCamel(k)
+				}
+				renamed[k] = renameFields(elem, f.Type)
+			}
+		case reflect.Map:
+			for k, elem := range v {
+				renamed[k] = renameFields(elem, t.Elem())
+			}
+		default:
+			return value
+		}
+		return renamed
+	case []interface{}:
+		for i, elem := range v {
+			v[i] = renameFields(elem, t.Elem())
+		}
+	}
+	return value
+}
+
+// lowerCamel returns the config file name of a Go field name, e.g. httpPort
+// for HTTPPort.
+func lowerCamel(fieldName string) string {
+	words := nameWords(fieldName)
+	if len(words) == 0 {
+		return fieldName
+	}
+	words[0] = strings.ToLower(words[0])
+	return strings.Join(words, "")
+}
+
+// nameWords splits a Go field name into words. Acronyms are one word, as is
+// an acronym followed by a plural "s" like the CAs of ClientCAs, and digits
+// belong to the word before them.
+func nameWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		if !unicode.IsUpper(cur) {
+			continue
+		}
+		if unicode.IsLower(prev) || unicode.IsDigit(prev) {
+			words = append(words, string(runes[start:i]))
+			start = i
+			continue
+		}
+		// cur starts a new word after an acronym if it is followed by a
+		// lowercase letter, unless that is a plural "s" ending the word
+		if i+1 < len(runes) && unicode.IsLower(runes[i+1]) && !pluralS(runes, i+1) {
+			words = append(words, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(words, string(runes[start:]))
+}
+
+func pluralS(runes []rune, i int) bool {
+	return runes[i] == 's' && (i+1 == len(runes) || !unicode.IsLower(runes[i+1]))
+}
+
+// describeJSONError adds the line and column of syntax and type errors in the
+// JSON data to the error.
+func describeJSONError(data []byte, err error) error {
+	var offset int64
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		offset = e.Offset
+	case *json.UnmarshalTypeError:
+		offset = e.Offset
+		if e.Field != "" {
+			err = fmt.Errorf("field %q: cannot use a JSON %s as a Go %s",
+				e.Field, e.Value, e.Type)
+		}
+	default:
+		return err
+	}
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Errorf("line %d, column %d: %s", line, column, err)
+}
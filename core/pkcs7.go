@@ -0,0 +1,59 @@
+package core
+
+import (
+	"encoding/asn1"
+)
+
+var (
+	oidData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"optional"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []asn1.RawValue `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     asn1.RawValue
+	SignerInfos      []asn1.RawValue `asn1:"set"`
+}
+
+// PKCS7 returns a DER encoded, degenerate (certificates only) PKCS#7
+// SignedData bundle containing the given certificates, as commonly used to
+// distribute trust anchors (RFC 2315).
+func PKCS7(certs []*Certificate) ([]byte, error) {
+	var raw []byte
+	for _, c := range certs {
+		raw = append(raw, c.DER...)
+	}
+
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []asn1.RawValue{},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      raw,
+		},
+		SignerInfos: []asn1.RawValue{},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content: asn1.RawValue{
+			Class:      asn1.ClassContextSpecific,
+			Tag:        0,
+			IsCompound: true,
+			Bytes:      sd,
+		},
+	})
+}
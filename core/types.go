@@ -7,6 +7,7 @@ import (
 	"encoding/base64"
 	"encoding/pem"
 	"fmt"
+	"net"
 	"sync"
 	"time"
 
@@ -21,11 +22,53 @@ type Order struct {
 	ID                   string
 	AccountID            string
 	Names                []string
+	PermanentIDs         []string
 	ParsedCSR            *x509.CertificateRequest
 	ExpiresDate          time.Time
 	AuthorizationObjects []*Authorization
 	BeganProcessing      bool
 	CertificateObject    *Certificate
+
+	// TNAuthList is the DER encoded TNAuthList of the order's TNAuthList
+	// identifier, if it has one.
+	TNAuthList []byte
+
+	// AncestorDomains maps names of the order to the ancestor domain whose
+	// authorization is used for them (RFC 9444).
+	AncestorDomains map[string]string
+
+	// DelegationObject is the delegation the order was created with, if any.
+	DelegationObject *Delegation
+
+	// ReplacesObject is the certificate named by the order's replaces field,
+	// if any.
+	ReplacesObject *Certificate
+
+	// FinalizeAttempts are the most recent CSRs submitted to finalize the
+	// order, including rejected ones, oldest first.
+	FinalizeAttempts []FinalizeAttempt
+
+	// Namespace is the namespace of the account that created the order.
+	Namespace string
+
+	// Interactions are the most recent requests about the order and the
+	// responses to them, oldest first, recorded for order reports.
+	Interactions []acme.Interaction
+
+	// InitialExpiresDate is the expiry the order was created with, set when
+	// polling first extends ExpiresDate.
+	InitialExpiresDate time.Time
+}
+
+// A FinalizeAttempt is a CSR submitted to finalize an order.
+type FinalizeAttempt struct {
+	Time time.Time
+	// CSR is the csr field of the finalize request exactly as it was
+	// received, normally the base64url encoded DER CSR.
+	CSR string
+	// Problem is the reason the CSR was rejected, or nil if the order was
+	// finalized with it.
+	Problem *acme.ProblemDetails
 }
 
 func (o *Order) GetStatus(clk clock.Clock) (string, error) {
@@ -38,6 +81,12 @@ func (o *Order) GetStatus(clk clock.Clock) (string, error) {
 		return acme.StatusInvalid, nil
 	}
 
+	// If the order expired before a certificate was issued for it, the status
+	// is invalid
+	if o.CertificateObject == nil && o.ExpiresDate.Before(clk.Now()) {
+		return acme.StatusInvalid, nil
+	}
+
 	authzStatuses := make(map[string]int)
 
 	for _, authz := range o.AuthorizationObjects {
@@ -112,6 +161,26 @@ type Account struct {
 	acme.Account
 	Key *jose.JSONWebKey `json:"key"`
 	ID  string
+	// SourceNetwork is the network the account is bound to, if any. Requests
+	// for the account from elsewhere are rejected.
+	SourceNetwork *net.IPNet `json:"-"`
+	// Namespace is the namespace the account was created in, or empty for
+	// the default namespace. Requests for the account from other namespaces
+	// are rejected.
+	Namespace string `json:"-"`
+	// AttestationObject is the attestation object submitted with the
+	// new-account request, if any, and AttestationFormat its attestation
+	// statement format.
+	AttestationObject []byte `json:"-"`
+	AttestationFormat string `json:"-"`
+}
+
+// A Delegation is a delegation configuration of an Identifier Owner's account
+// (RFC 9115).
+type Delegation struct {
+	acme.Delegation
+	ID        string
+	AccountID string
 }
 
 type Authorization struct {
@@ -121,6 +190,11 @@ type Authorization struct {
 	URL         string
 	ExpiresDate time.Time
 	Order       *Order
+	// ValidatedDate is when the authorization became valid.
+	ValidatedDate time.Time
+	// InitialExpiresDate is the expiry the authorization was created with,
+	// set when polling first extends ExpiresDate.
+	InitialExpiresDate time.Time
 }
 
 type Challenge struct {
@@ -129,9 +203,24 @@ type Challenge struct {
 	ID            string
 	Authz         *Authorization
 	ValidatedDate time.Time
+	// AttestationObject is the attestation object submitted by the client for
+	// a device-attest-01 challenge.
+	AttestationObject []byte
+	// AuthorityToken is the Authority Token (a JWT) submitted by the client
+	// for a tkauth-01 challenge.
+	AuthorityToken string
+	// OnionCSR is the DER CSR submitted by the client for an onion-csr-01
+	// challenge.
+	OnionCSR []byte
+	// KeyAuthorizationOverride, if set, is the key authorization the VA
+	// expects instead of the one computed from the account key.
+	KeyAuthorizationOverride string
 }
 
 func (ch *Challenge) ExpectedKeyAuthorization(key *jose.JSONWebKey) string {
+	if ch.KeyAuthorizationOverride != "" {
+		return ch.KeyAuthorizationOverride
+	}
 	if key == nil {
 		panic("ExpectedKeyAuthorization called with nil key")
 	}
@@ -150,6 +239,10 @@ type Certificate struct {
 	DER       []byte
 	Issuer    *Certificate
 	AccountID string
+
+	// AlternateIssuers are issuer certificates, other than Issuer, that share
+	// Issuer's subject and key but chain to different roots.
+	AlternateIssuers []*Certificate
 }
 
 func (c Certificate) PEM() []byte {
@@ -167,26 +260,58 @@ func (c Certificate) PEM() []byte {
 	return buf.Bytes()
 }
 
+// Chain returns the PEM encoded certificate followed by its issuer chain,
+// excluding the root.
 func (c Certificate) Chain() []byte {
+	return c.chainWithIssuer(c.Issuer)
+}
+
+// AlternateChain returns the PEM encoded certificate followed by the issuer
+// chain of the alternate issuer with the given index, excluding the root.
+func (c Certificate) AlternateChain(no int) ([]byte, error) {
+	if no < 0 || no >= len(c.AlternateIssuers) {
+		return nil, fmt.Errorf("certificate %q has no alternate chain %d", c.ID, no)
+	}
+	return c.chainWithIssuer(c.AlternateIssuers[no]), nil
+}
+
+// ChainCertificates returns the PEM encoded certificate followed by each
+// certificate of the issuer chain with the given number, leaf first. Zero is
+// the default chain and the alternate chains are numbered from one. The root
+// is only included if withRoot is true.
+func (c Certificate) ChainCertificates(no int, withRoot bool) ([][]byte, error) {
+	issuer := c.Issuer
+	if no > 0 {
+		if no > len(c.AlternateIssuers) {
+			return nil, fmt.Errorf("certificate %q has no alternate chain %d", c.ID, no-1)
+		}
+		issuer = c.AlternateIssuers[no-1]
+	}
+	return c.chainCertificates(issuer, withRoot), nil
+}
+
+func (c Certificate) chainWithIssuer(issuer *Certificate) []byte {
+	// Return the chain, leaf cert first
+	return bytes.Join(c.chainCertificates(issuer, false), nil)
+}
+
+func (c Certificate) chainCertificates(issuer *Certificate, withRoot bool) [][]byte {
 	chain := make([][]byte, 0)
 
 	// Add the leaf certificate
 	chain = append(chain, c.PEM())
 
 	// Add zero or more issuers
-	issuer := c.Issuer
 	for {
 		// if the issuer is nil, or the issuer's issuer is nil then we've reached
 		// the root of the chain and can break
-		if issuer == nil || issuer.Issuer == nil {
+		if issuer == nil || (issuer.Issuer == nil && !withRoot) {
 			break
 		}
 		chain = append(chain, issuer.PEM())
 		issuer = issuer.Issuer
 	}
-
-	// Return the chain, leaf cert first
-	return bytes.Join(chain, nil)
+	return chain
 }
 
 type ValidationRecord struct {
@@ -0,0 +1,13 @@
+//go:build boringcrypto
+// +build boringcrypto
+
+package cryptomode
+
+import (
+	// Restricts crypto/tls to FIPS approved settings
+	_ "crypto/tls/fipsonly"
+)
+
+// boringCrypto is true in builds with the BoringCrypto FIPS module, made with
+// GOEXPERIMENT=boringcrypto.
+const boringCrypto = true
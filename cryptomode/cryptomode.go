@@ -0,0 +1,192 @@
+// Package cryptomode selects the source of randomness and the cryptographic
+// primitives of Pebble's server components, so that Pebble can run in a FIPS
+// mode matching clients validated in FIPS environments, or with deterministic
+// randomness for reproducible tests.
+//
+// The mode is process wide, like crypto/rand, and is configured once at
+// startup before any component is created.
+package cryptomode
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	// ModeDefault uses crypto/rand and every primitive Pebble supports.
+	ModeDefault = ""
+	// ModeFIPS uses crypto/rand and only FIPS 140 approved primitives: Ed25519
+	// keys are rejected and TLS is restricted to approved versions, cipher
+	// suites and curves. Builds with BoringCrypto are always in ModeFIPS.
+	ModeFIPS = "fips"
+	// ModeDeterministic draws randomness from a stream derived from a seed,
+	// so that tokens, nonces and serials are the same every run.
+	ModeDeterministic = "deterministic"
+)
+
+// Config configures the crypto mode.
+type Config struct {
+	// Mode is ModeDefault, ModeFIPS or ModeDeterministic.
+	Mode string
+	// Seed seeds the random source of ModeDeterministic.
+	Seed string
+}
+
+func (c Config) check() error {
+	switch c.Mode {
+	case ModeDefault, ModeFIPS:
+		if c.Seed != "" {
+			return fmt.Errorf("a seed is only used in %s mode", ModeDeterministic)
+		}
+	case ModeDeterministic:
+		if boringCrypto {
+			return fmt.Errorf("%s mode isn't available in BoringCrypto builds", ModeDeterministic)
+		}
+	default:
+		return fmt.Errorf("unknown crypto mode %q", c.Mode)
+	}
+	return nil
+}
+
+// mu guards mode and reader.
+var mu sync.RWMutex
+
+var (
+	mode   = defaultMode()
+	reader = io.Reader(rand.Reader)
+)
+
+func defaultMode() string {
+	if boringCrypto {
+		return ModeFIPS
+	}
+	return ModeDefault
+}
+
+// Configure sets the crypto mode. It returns an error for an invalid config.
+func Configure(c Config) error {
+	if err := c.check(); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	mode = c.Mode
+	reader = rand.Reader
+	if boringCrypto {
+		mode = ModeFIPS
+	}
+	if mode == ModeDeterministic {
+		reader = newSeededReader(c.Seed)
+	}
+	return nil
+}
+
+// Mode returns the crypto mode.
+func Mode() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return mode
+}
+
+// FIPS returns true if only FIPS 140 approved primitives may be used.
+func FIPS() bool {
+	return Mode() == ModeFIPS
+}
+
+// BoringCrypto returns true if Pebble was built with the BoringCrypto FIPS
+// module.
+func BoringCrypto() bool {
+	return boringCrypto
+}
+
+// Reader returns the source of randomness to use in place of crypto/rand's
+// Reader.
+func Reader() io.Reader {
+	mu.RLock()
+	defer mu.RUnlock()
+	return reader
+}
+
+// CheckKey returns an error if the public key's algorithm may not be used in
+// the crypto mode.
+func CheckKey(key crypto.PublicKey) error {
+	if !FIPS() {
+		return nil
+	}
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if k.N.BitLen() < 2048 {
+			return fmt.Errorf("RSA keys must have at least 2048 bits in FIPS mode")
+		}
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() && k.Curve != elliptic.P384() && k.Curve != elliptic.P521() {
+			return fmt.Errorf("ECDSA keys must use P-256, P-384 or P-521 in FIPS mode")
+		}
+	default:
+		return fmt.Errorf("only RSA and ECDSA keys are allowed in FIPS mode")
+	}
+	return nil
+}
+
+// fipsCipherSuites are the FIPS approved TLS 1.2 cipher suites.
+var fipsCipherSuites = []uint16{
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+}
+
+// RestrictTLS restricts a TLS config to FIPS approved versions, cipher
+// suites and curves in FIPS mode, and returns it. In BoringCrypto builds
+// crypto/tls/fipsonly enforces the same restrictions.
+func RestrictTLS(config *tls.Config) *tls.Config {
+	if !FIPS() {
+		return config
+	}
+	config.MinVersion = tls.VersionTLS12
+	config.CipherSuites = fipsCipherSuites
+	config.CurvePreferences = []tls.CurveID{tls.CurveP256, tls.CurveP384}
+	return config
+}
+
+// seededReader is a deterministic stream of bytes: SHA-256 of the seed
+// followed by a block counter, block after block. It is fine for reproducible
+// tests and no good for anything else.
+type seededReader struct {
+	sync.Mutex
+	seed    []byte
+	counter uint64
+	buf     []byte
+}
+
+func newSeededReader(seed string) *seededReader {
+	return &seededReader{seed: []byte(seed)}
+}
+
+func (r *seededReader) Read(p []byte) (int, error) {
+	r.Lock()
+	defer r.Unlock()
+	n := 0
+	for n < len(p) {
+		if len(r.buf) == 0 {
+			var block [8]byte
+			binary.BigEndian.PutUint64(block[:], r.counter)
+			r.counter++
+			sum := sha256.Sum256(append(append([]byte{}, r.seed...), block[:]...))
+			r.buf = sum[:]
+		}
+		c := copy(p[n:], r.buf)
+		r.buf = r.buf[c:]
+		n += c
+	}
+	return n, nil
+}
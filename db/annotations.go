@@ -0,0 +1,144 @@
+package db
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// annotatedCollections are the collections whose objects can be annotated.
+var annotatedCollections = map[string]bool{
+	CollectionAccounts:     true,
+	CollectionOrders:       true,
+	CollectionCertificates: true,
+}
+
+// An AnnotationSelector matches the annotations of objects. Each term is
+// either "key", which matches objects with the annotation whatever its value,
+// or "key=value".
+type AnnotationSelector []string
+
+// ParseAnnotationSelector parses a comma separated list of selector terms,
+// e.g. "scenario=renewal,flaky". Every term must match for the selector to
+// match. The empty string matches every object.
+func ParseAnnotationSelector(s string) (AnnotationSelector, error) {
+	if s == "" {
+		return nil, nil
+	}
+	terms := strings.Split(s, ",")
+	for _, term := range terms {
+		if strings.SplitN(term, "=", 2)[0] == "" {
+			return nil, fmt.Errorf("annotation selector term %q has no key", term)
+		}
+	}
+	return AnnotationSelector(terms), nil
+}
+
+// Matches returns true if the annotations match every term of the selector.
+func (s AnnotationSelector) Matches(annotations map[string]string) bool {
+	for _, term := range s {
+		kv := strings.SplitN(term, "=", 2)
+		value, ok := annotations[kv[0]]
+		if !ok || (len(kv) == 2 && value != kv[1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// CheckAnnotationKey returns an error if the key can't be used in an
+// AnnotationSelector.
+func CheckAnnotationKey(key string) error {
+	if key == "" || strings.ContainsAny(key, "=,") {
+		return fmt.Errorf("annotation key %q must be non-empty without \"=\" or \",\"", key)
+	}
+	return nil
+}
+
+// objectExists returns true if the collection has an object with the ID. The
+// store must be locked.
+func (m *MemoryStore) objectExists(collection, id string) bool {
+	switch collection {
+	case CollectionAccounts:
+		return m.accountsByID[id] != nil
+	case CollectionOrders:
+		return m.ordersByID[id] != nil
+	case CollectionCertificates:
+		return m.certificatesByID[id] != nil
+	}
+	return false
+}
+
+// Annotate merges the annotations into those of the object with the ID in
+// the collection, which must be accounts, orders or certificates. Keys with an
+// empty value are removed. The object's resulting annotations are returned.
+func (m *MemoryStore) Annotate(collection, id string, annotations map[string]string) (map[string]string, error) {
+	m.Lock()
+	defer m.Unlock()
+	if !annotatedCollections[collection] {
+		return nil, fmt.Errorf("%s can't be annotated", collection)
+	}
+	if !m.objectExists(collection, id) {
+		return nil, fmt.Errorf("no object %q in %s", id, collection)
+	}
+
+	existing := m.annotations[collection][id]
+	if existing == nil {
+		existing = make(map[string]string)
+	}
+	for k, v := range annotations {
+		if v == "" {
+			delete(existing, k)
+		} else {
+			existing[k] = v
+		}
+	}
+	if len(existing) == 0 {
+		m.unannotate(collection, id)
+		return map[string]string{}, nil
+	}
+	if m.annotations[collection] == nil {
+		m.annotations[collection] = make(map[string]map[string]string)
+	}
+	m.annotations[collection][id] = existing
+	return copyAnnotations(existing), nil
+}
+
+// GetAnnotations returns the annotations of the object with the ID in the
+// collection, or an error if there is no such object.
+func (m *MemoryStore) GetAnnotations(collection, id string) (map[string]string, error) {
+	m.RLock()
+	defer m.RUnlock()
+	if !m.objectExists(collection, id) {
+		return nil, fmt.Errorf("no object %q in %s", id, collection)
+	}
+	return copyAnnotations(m.annotations[collection][id]), nil
+}
+
+// FindAnnotated returns the sorted IDs of the annotated objects of the
+// collection whose annotations match the selector.
+func (m *MemoryStore) FindAnnotated(collection string, selector AnnotationSelector) []string {
+	m.RLock()
+	defer m.RUnlock()
+	var ids []string
+	for id, annotations := range m.annotations[collection] {
+		if selector.Matches(annotations) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// unannotate removes the annotations of an object. The store must be locked.
+func (m *MemoryStore) unannotate(collection, id string) {
+	delete(m.annotations[collection], id)
+}
+
+func copyAnnotations(annotations map[string]string) map[string]string {
+	c := make(map[string]string, len(annotations))
+	for k, v := range annotations {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"github.com/letsencrypt/pebble/core"
+)
+
+// A Tx makes changes to the store as part of a batch. Readers of the store
+// see either all of a batch's changes or none of them.
+type Tx interface {
+	AddAccount(acct *core.Account) (int, error)
+	AddOrder(order *core.Order) (int, error)
+	AddAuthorization(authz *core.Authorization) (int, error)
+	AddChallenge(chal *core.Challenge) (int, error)
+	AddCertificate(cert *core.Certificate) (int, error)
+	RevokeCertificate(cert *core.Certificate)
+
+	// The getters return objects as they are in the batch, including those
+	// it added. Unlike MemoryStore's GetOrderByID, GetOrderByID doesn't
+	// update the order's status.
+	GetAccountByID(id string) *core.Account
+	GetOrderByID(id string) *core.Order
+	GetAuthorizationByID(id string) *core.Authorization
+	GetChallengeByID(id string) *core.Challenge
+	GetCertificateByID(id string) *core.Certificate
+
+	// OnRollback registers a function undoing a change the batch made to an
+	// object, e.g. setting a field of an order, for when the batch fails.
+	OnRollback(undo func())
+}
+
+// memoryTx is the Tx of a MemoryStore batch. It holds the store's write lock
+// and records how to undo each change.
+type memoryTx struct {
+	m    *MemoryStore
+	undo []func()
+	// adds counts the orders and certificates added, which count towards
+	// the memory limit.
+	adds int
+	done bool
+}
+
+func (tx *memoryTx) check() {
+	if tx.done {
+		panic("db: Tx used after its batch ended")
+	}
+}
+
+func (tx *memoryTx) AddAccount(acct *core.Account) (int, error) {
+	tx.check()
+	count, err := tx.m.addAccount(acct)
+	if err != nil {
+		return 0, err
+	}
+	tx.OnRollback(func() {
+		thumbprint, _ := keyThumbprint(acct.Key)
+		delete(tx.m.accountsByKeyThumbprint, thumbprint)
+		delete(tx.m.accountsByID, acct.ID)
+	})
+	return count, nil
+}
+
+func (tx *memoryTx) AddOrder(order *core.Order) (int, error) {
+	tx.check()
+	count, err := tx.m.addOrder(order)
+	if err != nil {
+		return 0, err
+	}
+	tx.adds++
+	tx.OnRollback(func() {
+		order.RLock()
+		accountID := order.AccountID
+		order.RUnlock()
+		delete(tx.m.ordersByID, order.ID)
+		// The order was appended, and changes are undone in reverse, so it
+		// is the account's last order
+		orders := tx.m.ordersByAccountID[accountID]
+		if len(orders) > 1 {
+			tx.m.ordersByAccountID[accountID] = orders[:len(orders)-1]
+		} else {
+			delete(tx.m.ordersByAccountID, accountID)
+		}
+		tx.m.usage.forget(order.ID)
+	})
+	return count, nil
+}
+
+func (tx *memoryTx) AddAuthorization(authz *core.Authorization) (int, error) {
+	tx.check()
+	count, err := tx.m.addAuthorization(authz)
+	if err != nil {
+		return 0, err
+	}
+	tx.OnRollback(func() {
+		delete(tx.m.authorizationsByID, authz.ID)
+	})
+	return count, nil
+}
+
+func (tx *memoryTx) AddChallenge(chal *core.Challenge) (int, error) {
+	tx.check()
+	count, err := tx.m.addChallenge(chal)
+	if err != nil {
+		return 0, err
+	}
+	tx.OnRollback(func() {
+		delete(tx.m.challengesByID, chal.ID)
+	})
+	return count, nil
+}
+
+func (tx *memoryTx) AddCertificate(cert *core.Certificate) (int, error) {
+	tx.check()
+	// A certificate with the same serial is replaced when serial collisions
+	// are allowed, and has to be put back
+	replaced := tx.m.certificatesByID[cert.ID]
+	count, err := tx.m.addCertificate(cert)
+	if err != nil {
+		return 0, err
+	}
+	tx.adds++
+	tx.OnRollback(func() {
+		tx.m.unindexCertificate(cert)
+		if replaced == nil {
+			delete(tx.m.certificatesByID, cert.ID)
+			tx.m.usage.forget(cert.ID)
+			return
+		}
+		tx.m.certificatesByID[cert.ID] = replaced
+		tx.m.indexCertificate(replaced)
+	})
+	return count, nil
+}
+
+func (tx *memoryTx) RevokeCertificate(cert *core.Certificate) {
+	tx.check()
+	removed := tx.m.certificatesByID[cert.ID]
+	annotations := tx.m.annotations[CollectionCertificates][cert.ID]
+	tx.m.removeCertificate(cert)
+	if removed == nil {
+		return
+	}
+	tx.OnRollback(func() {
+		tx.m.certificatesByID[cert.ID] = removed
+		tx.m.indexCertificate(removed)
+		if annotations != nil {
+			if tx.m.annotations[CollectionCertificates] == nil {
+				tx.m.annotations[CollectionCertificates] = make(map[string]map[string]string)
+			}
+			tx.m.annotations[CollectionCertificates][cert.ID] = annotations
+		}
+		tx.m.touch(cert.ID)
+	})
+}
+
+func (tx *memoryTx) GetAccountByID(id string) *core.Account {
+	tx.check()
+	return tx.m.accountsByID[id]
+}
+
+func (tx *memoryTx) GetOrderByID(id string) *core.Order {
+	tx.check()
+	return tx.m.ordersByID[id]
+}
+
+func (tx *memoryTx) GetAuthorizationByID(id string) *core.Authorization {
+	tx.check()
+	return tx.m.authorizationsByID[id]
+}
+
+func (tx *memoryTx) GetChallengeByID(id string) *core.Challenge {
+	tx.check()
+	return tx.m.challengesByID[id]
+}
+
+func (tx *memoryTx) GetCertificateByID(id string) *core.Certificate {
+	tx.check()
+	return tx.m.certificatesByID[id]
+}
+
+func (tx *memoryTx) OnRollback(undo func()) {
+	tx.check()
+	tx.undo = append(tx.undo, undo)
+}
+
+// rollback undoes the batch's changes, most recent first.
+func (tx *memoryTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+}
+
+// Batch calls fn with a Tx to make several changes to the store atomically,
+// e.g. adding an order along with its authorizations and challenges. The
+// store is locked for writing until fn returns, so concurrent readers never
+// see a batch half applied. If fn returns an error, or panics, every change
+// made through the Tx, and every function registered with OnRollback, is
+// undone and the error is returned.
+//
+// fn must only use the store through the Tx: calling the store's methods
+// from fn deadlocks. Objects should be locked inside fn as usual, but fn
+// mustn't block on anything that may be waiting for the store.
+func (m *MemoryStore) Batch(fn func(tx Tx) error) error {
+	m.Lock()
+	defer m.Unlock()
+
+	tx := &memoryTx{m: m}
+	committed := false
+	defer func() {
+		tx.done = true
+		if !committed {
+			tx.rollback()
+		}
+	}()
+	if err := fn(tx); err != nil {
+		return err
+	}
+	committed = true
+
+	// The memory limit is only checked once the batch is committed, so that
+	// nothing it added is evicted half way through
+	if tx.adds > 0 {
+		m.addsSinceCheck += tx.adds - 1
+		m.enforceMemoryLimit()
+	}
+	return nil
+}
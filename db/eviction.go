@@ -0,0 +1,229 @@
+package db
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/logging"
+)
+
+// memoryCheckInterval is how many orders and certificates are added between
+// checks of the memory limit, since estimating the store's size scans every
+// object.
+const memoryCheckInterval = 100
+
+// usageTracker records the order in which orders and certificates were last
+// used, to evict the least recently used ones first.
+type usageTracker struct {
+	sync.Mutex
+	seq      uint64
+	lastUsed map[string]uint64
+}
+
+func newUsageTracker() *usageTracker {
+	return &usageTracker{lastUsed: make(map[string]uint64)}
+}
+
+func (u *usageTracker) touch(id string) {
+	u.Lock()
+	defer u.Unlock()
+	u.seq++
+	u.lastUsed[id] = u.seq
+}
+
+func (u *usageTracker) forget(id string) {
+	u.Lock()
+	defer u.Unlock()
+	delete(u.lastUsed, id)
+}
+
+// last returns when the most recently used of the objects was used.
+func (u *usageTracker) last(ids ...string) uint64 {
+	u.Lock()
+	defer u.Unlock()
+	var last uint64
+	for _, id := range ids {
+		if u.lastUsed[id] > last {
+			last = u.lastUsed[id]
+		}
+	}
+	return last
+}
+
+// SetMemoryLimit caps the approximate memory used by the store, as estimated
+// by Stats, at maxBytes. When the store grows past the limit completed
+// orders are evicted along with their certificates and the authorizations and
+// challenges no remaining order uses, least recently used first, until it
+// fits again. Pending, ready and processing orders and pending authorizations
+// are never evicted, so the store may stay above the limit. A limit of 0
+// removes the cap.
+func (m *MemoryStore) SetMemoryLimit(maxBytes int) {
+	m.Lock()
+	defer m.Unlock()
+	m.memoryLimit = maxBytes
+}
+
+// SetLogger makes the store log the objects it evicts to the logger.
+func (m *MemoryStore) SetLogger(log logging.Logger) {
+	m.Lock()
+	defer m.Unlock()
+	m.log = log
+}
+
+// Evictions returns the number of objects evicted from each collection to
+// keep the store within its memory limit, keyed by collection name.
+func (m *MemoryStore) Evictions() map[string]int {
+	m.RLock()
+	defer m.RUnlock()
+	evictions := make(map[string]int, len(m.evictions))
+	for name, count := range m.evictions {
+		evictions[name] = count
+	}
+	return evictions
+}
+
+// touch records the use of an order or certificate if the store has a memory
+// limit. The store must be locked, at least for reading.
+func (m *MemoryStore) touch(id string) {
+	if m.memoryLimit > 0 {
+		m.usage.touch(id)
+	}
+}
+
+// enforceMemoryLimit evicts completed orders until the store fits within its
+// memory limit. The store must be locked.
+func (m *MemoryStore) enforceMemoryLimit() {
+	if m.memoryLimit <= 0 {
+		return
+	}
+	m.addsSinceCheck++
+	if m.addsSinceCheck < memoryCheckInterval {
+		return
+	}
+	m.addsSinceCheck = 0
+
+	var size int
+	for _, s := range m.stats() {
+		size += s.ApproxBytes
+	}
+	excess := size - m.memoryLimit
+	if excess <= 0 {
+		return
+	}
+
+	// Count the orders using each authorization, since authorizations may be
+	// reused by later orders.
+	authzUses := make(map[string]int)
+	type candidate struct {
+		order    *core.Order
+		lastUsed uint64
+	}
+	var candidates []candidate
+	for id, order := range m.ordersByID {
+		status, err := order.GetStatus(m.clk)
+		order.RLock()
+		for _, authz := range order.AuthorizationObjects {
+			authzUses[authz.ID]++
+		}
+		ids := []string{id}
+		if order.CertificateObject != nil {
+			ids = append(ids, order.CertificateObject.ID)
+		}
+		order.RUnlock()
+		if err == nil && (status == acme.StatusValid || status == acme.StatusInvalid) {
+			candidates = append(candidates, candidate{order, m.usage.last(ids...)})
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].lastUsed < candidates[j].lastUsed
+	})
+
+	before := make(map[string]int, len(m.evictions))
+	for name, count := range m.evictions {
+		before[name] = count
+	}
+	evictedAuthzs := make(map[*core.Authorization]bool)
+	for _, c := range candidates {
+		if excess <= 0 {
+			break
+		}
+		excess -= m.evictOrder(c.order, authzUses, evictedAuthzs)
+	}
+	if len(evictedAuthzs) > 0 {
+		for id, chal := range m.challengesByID {
+			chal.RLock()
+			evicted := evictedAuthzs[chal.Authz]
+			chal.RUnlock()
+			if evicted {
+				delete(m.challengesByID, id)
+				m.evictions[CollectionChallenges]++
+			}
+		}
+	}
+	if m.log != nil && m.evictions[CollectionOrders] > before[CollectionOrders] {
+		m.log.Printf("Evicted %d orders, %d authorizations, %d challenges and %d certificates "+
+			"to keep the store within %d bytes",
+			m.evictions[CollectionOrders]-before[CollectionOrders],
+			m.evictions[CollectionAuthorizations]-before[CollectionAuthorizations],
+			m.evictions[CollectionChallenges]-before[CollectionChallenges],
+			m.evictions[CollectionCertificates]-before[CollectionCertificates],
+			m.memoryLimit)
+	}
+}
+
+// evictOrder removes a completed order from the store along with its
+// certificate and the authorizations no other order uses, which are added to
+// evictedAuthzs. It returns the approximate number of bytes freed, not
+// counting the challenges of the evicted authorizations. The store must be
+// locked.
+func (m *MemoryStore) evictOrder(
+	order *core.Order,
+	authzUses map[string]int,
+	evictedAuthzs map[*core.Authorization]bool) int {
+	freed := orderSize(order)
+	order.RLock()
+	id := order.ID
+	accountID := order.AccountID
+	authzs := order.AuthorizationObjects
+	cert := order.CertificateObject
+	order.RUnlock()
+
+	delete(m.ordersByID, id)
+	m.unannotate(CollectionOrders, id)
+	orders := m.ordersByAccountID[accountID]
+	for i, o := range orders {
+		if o == order {
+			m.ordersByAccountID[accountID] = append(orders[:i:i], orders[i+1:]...)
+			break
+		}
+	}
+	if len(m.ordersByAccountID[accountID]) == 0 {
+		delete(m.ordersByAccountID, accountID)
+	}
+	m.usage.forget(id)
+	m.evictions[CollectionOrders]++
+
+	for _, authz := range authzs {
+		authzUses[authz.ID]--
+		authz.RLock()
+		pending := authz.Status == acme.StatusPending
+		authz.RUnlock()
+		if authzUses[authz.ID] > 0 || pending || m.authorizationsByID[authz.ID] != authz {
+			continue
+		}
+		freed += authzSize(authz)
+		delete(m.authorizationsByID, authz.ID)
+		evictedAuthzs[authz] = true
+		m.evictions[CollectionAuthorizations]++
+	}
+
+	if cert != nil && m.certificatesByID[cert.ID] == cert {
+		freed += certificateSize(cert)
+		m.removeCertificate(cert)
+		delete(m.replacementsByCertID, cert.ID)
+		m.evictions[CollectionCertificates]++
+	}
+	return freed
+}
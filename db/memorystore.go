@@ -1,12 +1,21 @@
 package db
 
 import (
+	"crypto"
+	"encoding/base64"
 	"fmt"
+	"math/big"
 	"reflect"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/acme"
 	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/logging"
+	"gopkg.in/square/go-jose.v2"
 )
 
 // Pebble keeps all of its various objects (accounts, orders, etc)
@@ -21,24 +30,111 @@ type MemoryStore struct {
 	// key bytes.
 	accountsByID map[string]*core.Account
 
+	// Each account is also indexed by the RFC 7638 JWK thumbprint of its key so
+	// that new-account requests can find an existing account for a key without
+	// scanning every account.
+	accountsByKeyThumbprint map[string]*core.Account
+
 	ordersByID map[string]*core.Order
 
+	// ordersByAccountID indexes orders by the ID of the account that created
+	// them.
+	ordersByAccountID map[string][]*core.Order
+
 	authorizationsByID map[string]*core.Authorization
 
 	challengesByID map[string]*core.Challenge
 
 	certificatesByID map[string]*core.Certificate
+
+	// Certificates are also indexed by lowercased DNS name and by account ID,
+	// each mapping to a set of certificates keyed by ID, for FindCertificates.
+	certificatesByName      map[string]map[string]*core.Certificate
+	certificatesByAccountID map[string]map[string]*core.Certificate
+
+	delegationsByID map[string]*core.Delegation
+
+	// csrsByDigest maps the hex encoded SHA-256 digest of each CSR used to
+	// finalize an order to its first use.
+	csrsByDigest map[string]CSRUse
+
+	// replacementsByCertID maps the ID of each certificate named by the
+	// replaces field of an order to the ID of the newest such order.
+	replacementsByCertID map[string]string
+
+	// annotations maps a collection name and object ID to the annotations set
+	// on the object through the management interface.
+	annotations map[string]map[string]map[string]string
+
+	// allowSerialCollisions lets AddCertificate replace a certificate with
+	// the same serial instead of rejecting the new one.
+	allowSerialCollisions bool
+
+	// memoryLimit is the approximate size in bytes above which completed
+	// orders are evicted, or 0 if the store is unlimited. See SetMemoryLimit.
+	memoryLimit    int
+	addsSinceCheck int
+	// evictions counts the evicted objects of each collection.
+	evictions map[string]int
+	// usage tracks when orders and certificates were last used. It has its
+	// own lock since objects are used while the store is only read locked.
+	usage *usageTracker
+
+	// log is where evictions are logged, if it isn't nil.
+	log logging.Logger
+}
+
+// A CSRUse records the order and account a CSR was first used to finalize an
+// order for.
+type CSRUse struct {
+	OrderID   string
+	AccountID string
 }
 
 func NewMemoryStore(clk clock.Clock) *MemoryStore {
 	return &MemoryStore{
-		clk:                clk,
-		accountsByID:       make(map[string]*core.Account),
-		ordersByID:         make(map[string]*core.Order),
-		authorizationsByID: make(map[string]*core.Authorization),
-		challengesByID:     make(map[string]*core.Challenge),
-		certificatesByID:   make(map[string]*core.Certificate),
+		clk:                     clk,
+		accountsByID:            make(map[string]*core.Account),
+		accountsByKeyThumbprint: make(map[string]*core.Account),
+		ordersByID:              make(map[string]*core.Order),
+		ordersByAccountID:       make(map[string][]*core.Order),
+		authorizationsByID:      make(map[string]*core.Authorization),
+		challengesByID:          make(map[string]*core.Challenge),
+		certificatesByID:        make(map[string]*core.Certificate),
+		certificatesByName:      make(map[string]map[string]*core.Certificate),
+		certificatesByAccountID: make(map[string]map[string]*core.Certificate),
+		delegationsByID:         make(map[string]*core.Delegation),
+		csrsByDigest:            make(map[string]CSRUse),
+		replacementsByCertID:    make(map[string]string),
+		annotations:             make(map[string]map[string]map[string]string),
+		evictions:               make(map[string]int),
+		usage:                   newUsageTracker(),
+	}
+}
+
+// keyThumbprint returns the base64url encoded RFC 7638 SHA256 thumbprint of
+// the provided key.
+func keyThumbprint(key *jose.JSONWebKey) (string, error) {
+	if key == nil {
+		return "", fmt.Errorf("cannot compute thumbprint of nil key")
+	}
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint), nil
+}
+
+// GetAccounts returns every account in the store.
+func (m *MemoryStore) GetAccounts() []*core.Account {
+	m.RLock()
+	defer m.RUnlock()
+
+	accts := make([]*core.Account, 0, len(m.accountsByID))
+	for _, acct := range m.accountsByID {
+		accts = append(accts, acct)
 	}
+	return accts
 }
 
 func (m *MemoryStore) GetAccountByID(id string) *core.Account {
@@ -47,12 +143,41 @@ func (m *MemoryStore) GetAccountByID(id string) *core.Account {
 	return m.accountsByID[id]
 }
 
+// GetAccountByKey returns the account registered with the provided key, or nil
+// if there is no such account.
+func (m *MemoryStore) GetAccountByKey(key *jose.JSONWebKey) (*core.Account, error) {
+	thumbprint, err := keyThumbprint(key)
+	if err != nil {
+		return nil, err
+	}
+
+	m.RLock()
+	defer m.RUnlock()
+	return m.accountsByKeyThumbprint[thumbprint], nil
+}
+
 func (m *MemoryStore) UpdateAccountByID(id string, acct *core.Account) error {
 	m.Lock()
 	defer m.Unlock()
-	if m.accountsByID[id] == nil {
+	existing := m.accountsByID[id]
+	if existing == nil {
 		return fmt.Errorf("account with ID %q does not exist", id)
 	}
+
+	oldThumbprint, err := keyThumbprint(existing.Key)
+	if err != nil {
+		return err
+	}
+	newThumbprint, err := keyThumbprint(acct.Key)
+	if err != nil {
+		return err
+	}
+	if other, present := m.accountsByKeyThumbprint[newThumbprint]; present && other.ID != id {
+		return fmt.Errorf("key is already in use by account %q", other.ID)
+	}
+
+	delete(m.accountsByKeyThumbprint, oldThumbprint)
+	m.accountsByKeyThumbprint[newThumbprint] = acct
 	m.accountsByID[id] = acct
 	return nil
 }
@@ -60,7 +185,11 @@ func (m *MemoryStore) UpdateAccountByID(id string, acct *core.Account) error {
 func (m *MemoryStore) AddAccount(acct *core.Account) (int, error) {
 	m.Lock()
 	defer m.Unlock()
+	return m.addAccount(acct)
+}
 
+// addAccount adds an account to the store, which must be locked.
+func (m *MemoryStore) addAccount(acct *core.Account) (int, error) {
 	acctID := acct.ID
 	if len(acctID) == 0 {
 		return 0, fmt.Errorf("account must have a non-empty ID to add to MemoryStore")
@@ -74,16 +203,36 @@ func (m *MemoryStore) AddAccount(acct *core.Account) (int, error) {
 		return 0, fmt.Errorf("account %q already exists", acctID)
 	}
 
+	thumbprint, err := keyThumbprint(acct.Key)
+	if err != nil {
+		return 0, err
+	}
+	if existing, present := m.accountsByKeyThumbprint[thumbprint]; present {
+		return 0, fmt.Errorf("key is already in use by account %q", existing.ID)
+	}
+
 	m.accountsByID[acctID] = acct
+	m.accountsByKeyThumbprint[thumbprint] = acct
 	return len(m.accountsByID), nil
 }
 
 func (m *MemoryStore) AddOrder(order *core.Order) (int, error) {
 	m.Lock()
 	defer m.Unlock()
+	count, err := m.addOrder(order)
+	if err != nil {
+		return 0, err
+	}
+	m.enforceMemoryLimit()
+	return count, nil
+}
 
+// addOrder adds an order to the store, which must be locked, without
+// enforcing the memory limit.
+func (m *MemoryStore) addOrder(order *core.Order) (int, error) {
 	order.RLock()
 	orderID := order.ID
+	accountID := order.AccountID
 	if len(orderID) == 0 {
 		return 0, fmt.Errorf("order must have a non-empty ID to add to MemoryStore")
 	}
@@ -94,30 +243,103 @@ func (m *MemoryStore) AddOrder(order *core.Order) (int, error) {
 	}
 
 	m.ordersByID[orderID] = order
+	m.ordersByAccountID[accountID] = append(m.ordersByAccountID[accountID], order)
+	m.touch(orderID)
 	return len(m.ordersByID), nil
 }
 
-func (m *MemoryStore) GetOrderByID(id string) *core.Order {
+// GetOrderByID returns the order with the given ID, with its status updated
+// to reflect the current state of its authorizations, or nil if there is no
+// such order. An error is returned if the order's status can't be determined.
+func (m *MemoryStore) GetOrderByID(id string) (*core.Order, error) {
 	m.RLock()
 	defer m.RUnlock()
 
 	if order, ok := m.ordersByID[id]; ok {
 		orderStatus, err := order.GetStatus(m.clk)
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("computing status of order %q: %s", id, err)
 		}
 		order.Lock()
 		defer order.Unlock()
 		order.Status = orderStatus
-		return order
+		m.touch(id)
+		return order, nil
 	}
-	return nil
+	return nil, nil
+}
+
+// GetOrdersByAccountID returns the orders created by the given account, oldest
+// first. Unlike GetOrderByID the statuses of the orders are not updated.
+func (m *MemoryStore) GetOrdersByAccountID(accountID string) []*core.Order {
+	m.RLock()
+	defer m.RUnlock()
+	orders := make([]*core.Order, len(m.ordersByAccountID[accountID]))
+	copy(orders, m.ordersByAccountID[accountID])
+	return orders
+}
+
+// CountPendingOrders returns how many of the account's orders are pending.
+// Only the account's own orders are examined, so it stays cheap for stores
+// holding many orders of other accounts.
+func (m *MemoryStore) CountPendingOrders(accountID string) int {
+	m.RLock()
+	defer m.RUnlock()
+	pending := 0
+	for _, order := range m.ordersByAccountID[accountID] {
+		if status, err := order.GetStatus(m.clk); err == nil && status == acme.StatusPending {
+			pending++
+		}
+	}
+	return pending
+}
+
+// GetAuthorizationsByAccountID returns the authorizations of the orders
+// created by the given account. Authorizations reused by several orders are
+// returned once.
+func (m *MemoryStore) GetAuthorizationsByAccountID(accountID string) []*core.Authorization {
+	var authzs []*core.Authorization
+	seen := make(map[string]bool)
+	for _, order := range m.GetOrdersByAccountID(accountID) {
+		order.RLock()
+		for _, authz := range order.AuthorizationObjects {
+			if !seen[authz.ID] {
+				seen[authz.ID] = true
+				authzs = append(authzs, authz)
+			}
+		}
+		order.RUnlock()
+	}
+	return authzs
+}
+
+// FindOrders returns the orders for which the match function returns true.
+// The match function is called with the order locked for reading. Every order
+// is scanned so this is only suitable for Pebble's small stores.
+func (m *MemoryStore) FindOrders(match func(*core.Order) bool) []*core.Order {
+	m.RLock()
+	defer m.RUnlock()
+
+	var orders []*core.Order
+	for _, order := range m.ordersByID {
+		order.RLock()
+		found := match(order)
+		order.RUnlock()
+		if found {
+			orders = append(orders, order)
+		}
+	}
+	return orders
 }
 
 func (m *MemoryStore) AddAuthorization(authz *core.Authorization) (int, error) {
 	m.Lock()
 	defer m.Unlock()
+	return m.addAuthorization(authz)
+}
 
+// addAuthorization adds an authorization to the store, which must be locked.
+func (m *MemoryStore) addAuthorization(authz *core.Authorization) (int, error) {
 	authz.RLock()
 	authzID := authz.ID
 	if len(authzID) == 0 {
@@ -139,10 +361,33 @@ func (m *MemoryStore) GetAuthorizationByID(id string) *core.Authorization {
 	return m.authorizationsByID[id]
 }
 
+// FindAuthorization returns an authorization for which the match function
+// returns true, or nil if there is none. The match function is called with
+// the authorization locked for reading. Every authorization is scanned so this
+// is only suitable for Pebble's small stores.
+func (m *MemoryStore) FindAuthorization(match func(*core.Authorization) bool) *core.Authorization {
+	m.RLock()
+	defer m.RUnlock()
+
+	for _, authz := range m.authorizationsByID {
+		authz.RLock()
+		found := match(authz)
+		authz.RUnlock()
+		if found {
+			return authz
+		}
+	}
+	return nil
+}
+
 func (m *MemoryStore) AddChallenge(chal *core.Challenge) (int, error) {
 	m.Lock()
 	defer m.Unlock()
+	return m.addChallenge(chal)
+}
 
+// addChallenge adds a challenge to the store, which must be locked.
+func (m *MemoryStore) addChallenge(chal *core.Challenge) (int, error) {
 	chal.RLock()
 	chalID := chal.ID
 	chal.RUnlock()
@@ -167,28 +412,154 @@ func (m *MemoryStore) GetChallengeByID(id string) *core.Challenge {
 func (m *MemoryStore) AddCertificate(cert *core.Certificate) (int, error) {
 	m.Lock()
 	defer m.Unlock()
+	count, err := m.addCertificate(cert)
+	if err != nil {
+		return 0, err
+	}
+	m.enforceMemoryLimit()
+	return count, nil
+}
 
+// addCertificate adds a certificate to the store, which must be locked,
+// without enforcing the memory limit.
+func (m *MemoryStore) addCertificate(cert *core.Certificate) (int, error) {
 	certID := cert.ID
 	if len(certID) == 0 {
 		return 0, fmt.Errorf("cert must have a non-empty ID to add to MemoryStore")
 	}
 
-	if _, present := m.certificatesByID[certID]; present {
+	if _, present := m.certificatesByID[certID]; present && !m.allowSerialCollisions {
 		return 0, fmt.Errorf("cert %q already exists", certID)
 	}
 
 	m.certificatesByID[certID] = cert
+	m.indexCertificate(cert)
+	m.touch(certID)
 	return len(m.certificatesByID), nil
 }
 
+// indexCertificate adds a certificate to the name and account indexes. The
+// store must be locked.
+func (m *MemoryStore) indexCertificate(cert *core.Certificate) {
+	for _, name := range cert.Cert.DNSNames {
+		addToIndex(m.certificatesByName, strings.ToLower(name), cert)
+	}
+	addToIndex(m.certificatesByAccountID, cert.AccountID, cert)
+}
+
+// unindexCertificate removes a certificate from the name and account
+// indexes. The store must be locked.
+func (m *MemoryStore) unindexCertificate(cert *core.Certificate) {
+	for _, name := range cert.Cert.DNSNames {
+		removeFromIndex(m.certificatesByName, strings.ToLower(name), cert)
+	}
+	removeFromIndex(m.certificatesByAccountID, cert.AccountID, cert)
+}
+
+// AllowSerialCollisions makes AddCertificate accept certificates with the
+// serial of an existing certificate, which the new certificate replaces.
+func (m *MemoryStore) AllowSerialCollisions() {
+	m.Lock()
+	defer m.Unlock()
+	m.allowSerialCollisions = true
+}
+
+func addToIndex(index map[string]map[string]*core.Certificate, key string, cert *core.Certificate) {
+	if index[key] == nil {
+		index[key] = make(map[string]*core.Certificate)
+	}
+	index[key][cert.ID] = cert
+}
+
+func removeFromIndex(index map[string]map[string]*core.Certificate, key string, cert *core.Certificate) {
+	delete(index[key], cert.ID)
+	if len(index[key]) == 0 {
+		delete(index, key)
+	}
+}
+
+// CertificateQuery selects the certificates returned by FindCertificates. The
+// zero value of each field matches every certificate.
+type CertificateQuery struct {
+	// Name is a DNS name the certificate must include. Matching is case
+	// insensitive.
+	Name      string
+	AccountID string
+	// SerialMin and SerialMax are the inclusive bounds of the serial number.
+	SerialMin *big.Int
+	SerialMax *big.Int
+	// IssuedAfter and IssuedBefore are the inclusive bounds of the
+	// certificate's NotBefore date.
+	IssuedAfter  time.Time
+	IssuedBefore time.Time
+	// Annotations selects certificates by their annotations.
+	Annotations AnnotationSelector
+}
+
+func (q CertificateQuery) matches(cert *core.Certificate) bool {
+	serial := cert.Cert.SerialNumber
+	notBefore := cert.Cert.NotBefore
+	return (q.AccountID == "" || cert.AccountID == q.AccountID) &&
+		(q.SerialMin == nil || serial.Cmp(q.SerialMin) >= 0) &&
+		(q.SerialMax == nil || serial.Cmp(q.SerialMax) <= 0) &&
+		(q.IssuedAfter.IsZero() || !notBefore.Before(q.IssuedAfter)) &&
+		(q.IssuedBefore.IsZero() || !notBefore.After(q.IssuedBefore))
+}
+
+// FindCertificates returns the unrevoked certificates matching the query,
+// sorted by issuance date. The name and account indexes are used to narrow
+// down the certificates that are checked.
+func (m *MemoryStore) FindCertificates(q CertificateQuery) []*core.Certificate {
+	m.RLock()
+	defer m.RUnlock()
+
+	candidates := m.certificatesByID
+	if q.Name != "" {
+		candidates = m.certificatesByName[strings.ToLower(q.Name)]
+	} else if q.AccountID != "" {
+		candidates = m.certificatesByAccountID[q.AccountID]
+	}
+
+	var certs []*core.Certificate
+	for _, cert := range candidates {
+		if q.matches(cert) && q.Annotations.Matches(m.annotations[CollectionCertificates][cert.ID]) {
+			certs = append(certs, cert)
+		}
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		if certs[i].Cert.NotBefore.Equal(certs[j].Cert.NotBefore) {
+			return certs[i].ID < certs[j].ID
+		}
+		return certs[i].Cert.NotBefore.Before(certs[j].Cert.NotBefore)
+	})
+	return certs
+}
+
 func (m *MemoryStore) GetCertificateByID(id string) *core.Certificate {
 	m.RLock()
 	defer m.RUnlock()
-	return m.certificatesByID[id]
+	cert := m.certificatesByID[id]
+	if cert != nil {
+		m.touch(id)
+	}
+	return cert
 }
 
 // GetCertificateByDER loops over all certificates to find the one that matches the provided DER bytes.
 // This method is linear and it's not optimized to give you a quick response.
+// GetCertificates returns every certificate in the store that hasn't been
+// revoked.
+func (m *MemoryStore) GetCertificates() []*core.Certificate {
+	m.RLock()
+	defer m.RUnlock()
+
+	certs := make([]*core.Certificate, 0, len(m.certificatesByID))
+	for _, c := range m.certificatesByID {
+		certs = append(certs, c)
+	}
+	return certs
+}
+
 func (m *MemoryStore) GetCertificateByDER(der []byte) *core.Certificate {
 	m.RLock()
 	defer m.RUnlock()
@@ -204,5 +575,258 @@ func (m *MemoryStore) GetCertificateByDER(der []byte) *core.Certificate {
 func (m *MemoryStore) RevokeCertificate(cert *core.Certificate) {
 	m.Lock()
 	defer m.Unlock()
+	m.removeCertificate(cert)
+}
+
+// removeCertificate removes a certificate from the store and its indexes. The
+// store must be locked.
+func (m *MemoryStore) removeCertificate(cert *core.Certificate) {
 	delete(m.certificatesByID, cert.ID)
+	m.unannotate(CollectionCertificates, cert.ID)
+	m.unindexCertificate(cert)
+	m.usage.forget(cert.ID)
+}
+
+func (m *MemoryStore) AddDelegation(delegation *core.Delegation) (int, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if len(delegation.ID) == 0 {
+		return 0, fmt.Errorf("delegation must have a non-empty ID to add to MemoryStore")
+	}
+	if _, present := m.delegationsByID[delegation.ID]; present {
+		return 0, fmt.Errorf("delegation %q already exists", delegation.ID)
+	}
+
+	m.delegationsByID[delegation.ID] = delegation
+	return len(m.delegationsByID), nil
+}
+
+func (m *MemoryStore) GetDelegationByID(id string) *core.Delegation {
+	m.RLock()
+	defer m.RUnlock()
+	return m.delegationsByID[id]
+}
+
+// GetDelegationsByAccountID returns the delegations of the account with the
+// given ID, sorted by ID.
+func (m *MemoryStore) GetDelegationsByAccountID(accountID string) []*core.Delegation {
+	m.RLock()
+	defer m.RUnlock()
+
+	var delegations []*core.Delegation
+	for _, d := range m.delegationsByID {
+		if d.AccountID == accountID {
+			delegations = append(delegations, d)
+		}
+	}
+	sort.Slice(delegations, func(i, j int) bool {
+		return delegations[i].ID < delegations[j].ID
+	})
+	return delegations
+}
+
+// RecordCSR records the use of the CSR with the given hex encoded SHA-256
+// digest unless it was used before. It returns the first use of the CSR and
+// true if that is the given use.
+func (m *MemoryStore) RecordCSR(digest string, use CSRUse) (CSRUse, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if first, ok := m.csrsByDigest[digest]; ok {
+		return first, false
+	}
+	m.csrsByDigest[digest] = use
+	return use, true
+}
+
+// GetCSRUse returns the first use of the CSR with the given hex encoded
+// SHA-256 digest and true, or false if it wasn't used.
+func (m *MemoryStore) GetCSRUse(digest string) (CSRUse, bool) {
+	m.RLock()
+	defer m.RUnlock()
+	use, ok := m.csrsByDigest[digest]
+	return use, ok
+}
+
+// ReplaceCertificate records that the order with the given ID replaces the
+// certificate with the given ID. It returns the ID of the order replacing the
+// certificate and true if that is the given order. A certificate can only be
+// replaced again once the order replacing it is invalid.
+func (m *MemoryStore) ReplaceCertificate(certID, orderID string) (string, bool) {
+	m.Lock()
+	defer m.Unlock()
+
+	if replacedBy, ok := m.replacementsByCertID[certID]; ok && replacedBy != orderID {
+		// An order that isn't in the store yet is being created, so it counts
+		// as a replacement too
+		order, present := m.ordersByID[replacedBy]
+		if !present {
+			return replacedBy, false
+		}
+		if status, err := order.GetStatus(m.clk); err != nil || status != acme.StatusInvalid {
+			return replacedBy, false
+		}
+	}
+	m.replacementsByCertID[certID] = orderID
+	return orderID, true
+}
+
+// GetReplacement returns the ID of the newest order replacing the certificate
+// with the given ID, or "" if it hasn't been replaced.
+func (m *MemoryStore) GetReplacement(certID string) string {
+	m.RLock()
+	defer m.RUnlock()
+	return m.replacementsByCertID[certID]
+}
+
+const (
+	// Collection names used by Stats and ClearCollection
+	CollectionAccounts       = "accounts"
+	CollectionOrders         = "orders"
+	CollectionAuthorizations = "authorizations"
+	CollectionChallenges     = "challenges"
+	CollectionCertificates   = "certificates"
+	CollectionDelegations    = "delegations"
+	CollectionCSRs           = "csrs"
+
+	// objectOverhead is a rough guess at the fixed number of bytes each stored
+	// object uses for its struct, locks, pointers and map entry.
+	objectOverhead = 256
+)
+
+// CollectionStats describes the contents of one of the MemoryStore's
+// collections.
+type CollectionStats struct {
+	Count int `json:"count"`
+	// ApproxBytes is a rough estimate of the memory used by the collection's
+	// objects. It is only suitable for spotting trends.
+	ApproxBytes int `json:"approxBytes"`
+}
+
+// Stats returns the number of objects and their approximate memory usage for
+// each of the MemoryStore's collections, keyed by collection name.
+func (m *MemoryStore) Stats() map[string]CollectionStats {
+	m.RLock()
+	defer m.RUnlock()
+	return m.stats()
+}
+
+// stats implements Stats. The store must be locked.
+func (m *MemoryStore) stats() map[string]CollectionStats {
+	stats := make(map[string]CollectionStats)
+
+	var acctBytes int
+	for _, a := range m.accountsByID {
+		acctBytes += objectOverhead + len(a.ID) + len(a.Orders) + 512
+		for _, c := range a.Contact {
+			acctBytes += len(c)
+		}
+	}
+	stats[CollectionAccounts] = CollectionStats{len(m.accountsByID), acctBytes}
+
+	var orderBytes int
+	for _, o := range m.ordersByID {
+		orderBytes += orderSize(o)
+	}
+	stats[CollectionOrders] = CollectionStats{len(m.ordersByID), orderBytes}
+
+	var authzBytes int
+	for _, a := range m.authorizationsByID {
+		authzBytes += authzSize(a)
+	}
+	stats[CollectionAuthorizations] = CollectionStats{len(m.authorizationsByID), authzBytes}
+
+	var chalBytes int
+	for _, c := range m.challengesByID {
+		chalBytes += challengeSize(c)
+	}
+	stats[CollectionChallenges] = CollectionStats{len(m.challengesByID), chalBytes}
+
+	var certBytes int
+	for _, c := range m.certificatesByID {
+		certBytes += certificateSize(c)
+	}
+	stats[CollectionCertificates] = CollectionStats{len(m.certificatesByID), certBytes}
+
+	var delegationBytes int
+	for _, d := range m.delegationsByID {
+		delegationBytes += objectOverhead + len(d.ID) + len(d.AccountID) + 512
+	}
+	stats[CollectionDelegations] = CollectionStats{len(m.delegationsByID), delegationBytes}
+
+	var csrBytes int
+	for digest, use := range m.csrsByDigest {
+		csrBytes += objectOverhead + len(digest) + len(use.OrderID) + len(use.AccountID)
+	}
+	stats[CollectionCSRs] = CollectionStats{len(m.csrsByDigest), csrBytes}
+
+	return stats
+}
+
+func orderSize(o *core.Order) int {
+	o.RLock()
+	defer o.RUnlock()
+	size := objectOverhead + len(o.ID) + len(o.AccountID)
+	for _, n := range o.Names {
+		size += 2 * len(n)
+	}
+	for _, a := range o.Authorizations {
+		size += len(a)
+	}
+	if o.ParsedCSR != nil {
+		size += len(o.ParsedCSR.Raw)
+	}
+	return size
+}
+
+func authzSize(a *core.Authorization) int {
+	a.RLock()
+	defer a.RUnlock()
+	return objectOverhead + len(a.ID) + len(a.URL) + len(a.Identifier.Value)
+}
+
+func challengeSize(c *core.Challenge) int {
+	c.RLock()
+	defer c.RUnlock()
+	return objectOverhead + len(c.ID) + len(c.URL) + len(c.Token)
+}
+
+func certificateSize(c *core.Certificate) int {
+	// The DER bytes are held once as-is and once more in the parsed
+	// certificate's Raw field
+	return objectOverhead + len(c.ID) + 2*len(c.DER)
+}
+
+// ClearCollection removes every object from the named collection. Objects in
+// other collections that refer to the removed objects are left as-is.
+func (m *MemoryStore) ClearCollection(name string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	delete(m.annotations, name)
+	switch name {
+	case CollectionAccounts:
+		m.accountsByID = make(map[string]*core.Account)
+		m.accountsByKeyThumbprint = make(map[string]*core.Account)
+	case CollectionOrders:
+		m.ordersByID = make(map[string]*core.Order)
+		m.ordersByAccountID = make(map[string][]*core.Order)
+		m.replacementsByCertID = make(map[string]string)
+	case CollectionAuthorizations:
+		m.authorizationsByID = make(map[string]*core.Authorization)
+	case CollectionChallenges:
+		m.challengesByID = make(map[string]*core.Challenge)
+	case CollectionCertificates:
+		m.certificatesByID = make(map[string]*core.Certificate)
+		m.certificatesByName = make(map[string]map[string]*core.Certificate)
+		m.certificatesByAccountID = make(map[string]map[string]*core.Certificate)
+	case CollectionDelegations:
+		m.delegationsByID = make(map[string]*core.Delegation)
+	case CollectionCSRs:
+		m.csrsByDigest = make(map[string]CSRUse)
+	default:
+		return fmt.Errorf("unknown collection %q", name)
+	}
+	return nil
 }
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"sort"
+
+	"github.com/letsencrypt/pebble/core"
+)
+
+// NamespaceObjects are the IDs of the accounts of a namespace and of their
+// orders and certificates, each sorted.
+type NamespaceObjects struct {
+	Accounts     []string
+	Orders       []string
+	Certificates []string
+}
+
+func (o *NamespaceObjects) sort() {
+	sort.Strings(o.Accounts)
+	sort.Strings(o.Orders)
+	sort.Strings(o.Certificates)
+}
+
+// Namespaces returns the objects of every namespace that has an account,
+// keyed by namespace name. Accounts in the default namespace are keyed by the
+// empty name.
+func (m *MemoryStore) Namespaces() map[string]NamespaceObjects {
+	m.RLock()
+	defer m.RUnlock()
+
+	namespaces := make(map[string]NamespaceObjects)
+	for id, acct := range m.accountsByID {
+		objects := namespaces[acct.Namespace]
+		objects.Accounts = append(objects.Accounts, id)
+		for _, order := range m.ordersByAccountID[id] {
+			objects.Orders = append(objects.Orders, order.ID)
+		}
+		for certID := range m.certificatesByAccountID[id] {
+			objects.Certificates = append(objects.Certificates, certID)
+		}
+		namespaces[acct.Namespace] = objects
+	}
+	for name, objects := range namespaces {
+		objects.sort()
+		namespaces[name] = objects
+	}
+	return namespaces
+}
+
+// WipeNamespace removes the accounts of the namespace along with their
+// orders, authorizations, challenges, certificates, delegations and CSR uses,
+// and returns the IDs of the removed accounts, orders and certificates.
+func (m *MemoryStore) WipeNamespace(name string) NamespaceObjects {
+	m.Lock()
+	defer m.Unlock()
+
+	var wiped NamespaceObjects
+	accounts := make(map[string]bool)
+	for id, acct := range m.accountsByID {
+		if acct.Namespace != name {
+			continue
+		}
+		accounts[id] = true
+		wiped.Accounts = append(wiped.Accounts, id)
+		delete(m.accountsByID, id)
+		m.unannotate(CollectionAccounts, id)
+		if thumbprint, err := keyThumbprint(acct.Key); err == nil {
+			delete(m.accountsByKeyThumbprint, thumbprint)
+		}
+	}
+
+	authzs := make(map[*core.Authorization]bool)
+	for id := range accounts {
+		for _, order := range m.ordersByAccountID[id] {
+			order.RLock()
+			for _, authz := range order.AuthorizationObjects {
+				authzs[authz] = true
+			}
+			order.RUnlock()
+			delete(m.ordersByID, order.ID)
+			m.unannotate(CollectionOrders, order.ID)
+			m.usage.forget(order.ID)
+			wiped.Orders = append(wiped.Orders, order.ID)
+		}
+		delete(m.ordersByAccountID, id)
+
+		for _, cert := range m.certificatesByAccountID[id] {
+			m.removeCertificate(cert)
+			delete(m.replacementsByCertID, cert.ID)
+			wiped.Certificates = append(wiped.Certificates, cert.ID)
+		}
+	}
+
+	for id, authz := range m.authorizationsByID {
+		if authzs[authz] {
+			delete(m.authorizationsByID, id)
+		}
+	}
+	for id, chal := range m.challengesByID {
+		chal.RLock()
+		wipe := authzs[chal.Authz]
+		chal.RUnlock()
+		if wipe {
+			delete(m.challengesByID, id)
+		}
+	}
+	for id, delegation := range m.delegationsByID {
+		if accounts[delegation.AccountID] {
+			delete(m.delegationsByID, id)
+		}
+	}
+	for digest, use := range m.csrsByDigest {
+		if accounts[use.AccountID] {
+			delete(m.csrsByDigest, digest)
+		}
+	}
+
+	wiped.sort()
+	return wiped
+}
@@ -0,0 +1,124 @@
+// Package events publishes status transitions of authorizations, challenges
+// and orders so that test harnesses can wait on them instead of polling the
+// ACME API.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+)
+
+const (
+	// TypeAuthorization events are published when an authorization changes
+	// status.
+	TypeAuthorization = "authorization"
+	// TypeChallenge events are published when a challenge changes status.
+	TypeChallenge = "challenge"
+	// TypeOrder events are published when an order changes status.
+	TypeOrder = "order"
+
+	// subscriberBuffer is how many events can be queued for a subscriber
+	// before further events are dropped.
+	subscriberBuffer = 256
+)
+
+// Event is a status transition of an object.
+type Event struct {
+	Type       string `json:"type"`
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	Identifier string `json:"identifier,omitempty"`
+	Time       string `json:"time"`
+}
+
+// Broker delivers published events to its subscribers. Only transitions are
+// published: an event with the same status as the last one published for the
+// same object is dropped. A nil *Broker publishes nothing, so publishers don't
+// need to check whether anything is listening.
+type Broker struct {
+	clk clock.Clock
+
+	sync.Mutex
+	subscribers map[chan Event]bool
+	observers   []func(Event)
+	statuses    map[string]string
+}
+
+// New returns a Broker with no subscribers.
+func New(clk clock.Clock) *Broker {
+	return &Broker{
+		clk:         clk,
+		subscribers: make(map[chan Event]bool),
+		statuses:    make(map[string]string),
+	}
+}
+
+// Publish sends an event to every subscriber if the status differs from the
+// last status published for the object. Subscribers that aren't keeping up
+// miss the event rather than blocking the publisher.
+func (b *Broker) Publish(eventType, id, status, identifier string) {
+	if b == nil {
+		return
+	}
+	event := Event{
+		Type:       eventType,
+		ID:         id,
+		Status:     status,
+		Identifier: identifier,
+		Time:       b.clk.Now().UTC().Format(time.RFC3339Nano),
+	}
+
+	b.Lock()
+	key := eventType + "/" + id
+	if b.statuses[key] == status {
+		b.Unlock()
+		return
+	}
+	b.statuses[key] = status
+	for ch := range b.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	observers := b.observers
+	b.Unlock()
+
+	for _, f := range observers {
+		f(event)
+	}
+}
+
+// Observe calls f with every event published from now on. Unlike subscribers
+// observers never miss events: they are called by the publisher, which may
+// hold locks on the object the event is about, so f must be quick and must not
+// lock the object.
+func (b *Broker) Observe(f func(Event)) {
+	if b == nil {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	b.observers = append(b.observers, f)
+}
+
+// Subscribe returns a channel receiving every event published from now on and
+// a function that unsubscribes and closes the channel.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.Lock()
+	b.subscribers[ch] = true
+	b.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.Lock()
+			delete(b.subscribers, ch)
+			b.Unlock()
+			close(ch)
+		})
+	}
+}
@@ -0,0 +1,128 @@
+// Package logging provides the loggers of Pebble's components. Each component
+// logs through a Logger that writes to the sinks configured for it, so that
+// for example validation logs can be kept apart from issuance logs.
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+)
+
+// Logger is the interface Pebble's components log through. *log.Logger
+// implements it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+const (
+	// SinkStdout writes to standard output. It is the sink of components
+	// with none configured.
+	SinkStdout = "stdout"
+	// SinkStderr writes to standard error.
+	SinkStderr = "stderr"
+	// SinkFile writes to a file, which is rotated when it reaches MaxSize.
+	SinkFile = "file"
+	// SinkSyslog writes to the local syslog daemon, with Tag as the tag. It
+	// isn't supported on Windows and Plan 9.
+	SinkSyslog = "syslog"
+
+	// defaultSyslogTag is the tag of syslog sinks without one.
+	defaultSyslogTag = "pebble"
+)
+
+// SinkConfig configures a destination of log lines.
+type SinkConfig struct {
+	// Type is SinkStdout, SinkStderr, SinkFile or SinkSyslog.
+	Type string
+	// Path is the file a file sink appends to.
+	Path string
+	// MaxSize is the size in megabytes a file sink's file is rotated at, or 0
+	// to never rotate it.
+	MaxSize int
+	// MaxBackups is how many rotated files a file sink keeps, at least one.
+	// The newest has the suffix ".1".
+	MaxBackups int
+	// Tag is the tag of a syslog sink's messages, "pebble" by default.
+	Tag string
+}
+
+// key identifies the destination of a sink, so that components configured
+// with the same destination share its writer.
+func (c SinkConfig) key() string {
+	switch c.Type {
+	case SinkFile:
+		return c.Type + ":" + c.Path
+	case SinkSyslog:
+		return c.Type + ":" + c.Tag
+	}
+	return c.Type
+}
+
+// Sinks opens the sinks of the loggers it creates, once for each destination.
+type Sinks struct {
+	sync.Mutex
+	writers map[string]io.Writer
+}
+
+// NewSinks returns an empty set of sinks.
+func NewSinks() *Sinks {
+	return &Sinks{writers: make(map[string]io.Writer)}
+}
+
+// open returns the writer of a sink, opening it if no logger uses it yet.
+func (s *Sinks) open(config SinkConfig) (io.Writer, error) {
+	if config.Type == SinkSyslog && config.Tag == "" {
+		config.Tag = defaultSyslogTag
+	}
+	s.Lock()
+	defer s.Unlock()
+	if w, ok := s.writers[config.key()]; ok {
+		return w, nil
+	}
+
+	var w io.Writer
+	var err error
+	switch config.Type {
+	case SinkStdout, "":
+		w = os.Stdout
+	case SinkStderr:
+		w = os.Stderr
+	case SinkFile:
+		if config.Path == "" {
+			return nil, fmt.Errorf("file log sink has no path")
+		}
+		w, err = openRotatingFile(config.Path, int64(config.MaxSize)*1024*1024, config.MaxBackups)
+	case SinkSyslog:
+		w, err = openSyslog(config.Tag)
+	default:
+		return nil, fmt.Errorf("unknown log sink type %q", config.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+	s.writers[config.key()] = w
+	return w, nil
+}
+
+// Logger returns a logger with the prefix that writes to each of the sinks,
+// or to standard output if there are none.
+func (s *Sinks) Logger(prefix string, configs []SinkConfig) (*log.Logger, error) {
+	if len(configs) == 0 {
+		configs = []SinkConfig{{Type: SinkStdout}}
+	}
+	var writers []io.Writer
+	for _, config := range configs {
+		w, err := s.open(config)
+		if err != nil {
+			return nil, err
+		}
+		writers = append(writers, w)
+	}
+	if len(writers) == 1 {
+		return log.New(writers[0], prefix, log.LstdFlags), nil
+	}
+	return log.New(io.MultiWriter(writers...), prefix, log.LstdFlags), nil
+}
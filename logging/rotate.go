@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingFile is a file that is renamed to a numbered backup, shifting the
+// older backups along, when a write would make it larger than maxBytes.
+type rotatingFile struct {
+	sync.Mutex
+	path       string
+	maxBytes   int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxBytes int64, maxBackups int) (*rotatingFile, error) {
+	if maxBackups < 1 {
+		maxBackups = 1
+	}
+	f := &rotatingFile{path: path, maxBytes: maxBytes, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file = file
+	f.size = info.Size()
+	return nil
+}
+
+// rotate renames the file to the first backup and opens a new file.
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	for i := f.maxBackups - 1; i >= 1; i-- {
+		_ = os.Rename(fmt.Sprintf("%s.%d", f.path, i), fmt.Sprintf("%s.%d", f.path, i+1))
+	}
+	err := os.Rename(f.path, f.path+".1")
+	if openErr := f.open(); openErr != nil {
+		return openErr
+	}
+	return err
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.Lock()
+	defer f.Unlock()
+	if f.maxBytes > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxBytes {
+		if err := f.rotate(); err != nil {
+			return 0, fmt.Errorf("rotating log file %s: %s", f.path, err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+// +build windows plan9
+
+package logging
+
+import (
+	"errors"
+	"io"
+)
+
+func openSyslog(tag string) (io.Writer, error) {
+	return nil, errors.New("syslog log sinks aren't supported on this platform")
+}
@@ -0,0 +1,8 @@
+// Package mgmtclient is a client of Pebble's management interface. It is
+// generated, along with the TypeScript client in management.ts, from the
+// OpenAPI document in openapi.json, which Pebble also serves at
+// /openapi.json. Regenerate them with go generate after changing the
+// management API.
+package mgmtclient
+
+//go:generate go run ../cmd/pebble-mgmt-gen -out .
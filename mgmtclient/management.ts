@@ -0,0 +1,1057 @@
+// Code generated by pebble-mgmt-gen. DO NOT EDIT.
+
+/** The version of the management API the client was generated from. */
+export const API_VERSION = 1;
+
+export interface AccountAttestation {
+  attObj: string;
+  extensions?: { [key: string]: unknown };
+  fmt: string;
+  id: string;
+}
+
+export interface AccountOverride {
+  challengeTypes?: string[];
+  failEndpoints?: string[];
+  latency?: LatencyProfile;
+  requireEAB?: boolean;
+  validationOutcome?: string;
+}
+
+export interface AnnotatedObject {
+  annotations: { [key: string]: string };
+  collection: string;
+  extensions?: { [key: string]: unknown };
+  id: string;
+}
+
+export interface AuthorizationFailure {
+  challenges: ChallengeFailure[];
+  extensions?: { [key: string]: unknown };
+  id: string;
+  identifier: Identifier;
+  status: string;
+}
+
+export interface AuthorizationReport {
+  challenges: ChallengeTiming[];
+  extensions?: { [key: string]: unknown };
+  id: string;
+  identifier: Identifier;
+  status: string;
+}
+
+export interface AutoFinalizedOrder {
+  certificate?: string;
+  extensions?: { [key: string]: unknown };
+  id: string;
+  key: string;
+  status: string;
+}
+
+export interface BulkRevocation {
+  account?: string;
+  reason?: number;
+  san?: string;
+  serials?: string[];
+}
+
+export interface CACertificate {
+  default: boolean;
+  extensions?: { [key: string]: unknown };
+  index: number;
+  issuer: string;
+  notAfter: string;
+  subject: string;
+  url: string;
+}
+
+export interface CSRTemplate {
+  extensions: {
+  subjectAltName: {
+  DNS?: string[];
+};
+};
+  keyTypes?: CSRTemplateKeyType[];
+  subject?: { [key: string]: string };
+}
+
+export interface CSRTemplateKeyType {
+  PublicKeyType: string;
+  namedCurve?: string;
+}
+
+export interface CTLog {
+  description: string;
+  extensions?: { [key: string]: unknown };
+  key: string;
+  logID: string;
+  url: string;
+}
+
+export interface CertificateSummary {
+  accountID: string;
+  extensions?: { [key: string]: unknown };
+  names: string[];
+  notAfter: string;
+  notBefore: string;
+  pem?: string;
+  serial: string;
+  url: string;
+}
+
+export interface ChallengeFailure {
+  attempts?: ValidationAttempt[];
+  error?: ProblemDetails;
+  extensions?: { [key: string]: unknown };
+  status: string;
+  type: string;
+}
+
+export interface ChallengeTiming {
+  attempts: ValidationAttempt[];
+  durationMs?: number;
+  extensions?: { [key: string]: unknown };
+  id: string;
+  status: string;
+  type: string;
+  validationCompleted?: string;
+  validationStarted?: string;
+}
+
+export interface CollectionStats {
+  approxBytes: number;
+  count: number;
+}
+
+export interface CsrExtension {
+  critical?: boolean;
+  id: string;
+  value: string;
+}
+
+export interface Dashboard {
+  accounts: DashboardAccount[];
+  certificates: DashboardCertificate[];
+  extensions?: { [key: string]: unknown };
+  generated: string;
+  orders: DashboardOrder[];
+  validations: DashboardValidation[];
+}
+
+export interface DashboardAccount {
+  contact?: string[];
+  extensions?: { [key: string]: unknown };
+  id: string;
+  namespace?: string;
+  orders: number;
+  status: string;
+}
+
+export interface DashboardCertificate {
+  account: string;
+  extensions?: { [key: string]: unknown };
+  names: string[];
+  notAfter: string;
+  notBefore: string;
+  serial: string;
+}
+
+export interface DashboardOrder {
+  account: string;
+  certificate?: string;
+  error?: ProblemDetails;
+  expires: string;
+  extensions?: { [key: string]: unknown };
+  id: string;
+  identifiers: Identifier[];
+  status: string;
+}
+
+export interface DashboardValidation {
+  attempts: number;
+  challenge: string;
+  completed: string;
+  error?: ProblemDetails;
+  extensions?: { [key: string]: unknown };
+  identifier: Identifier;
+  status: string;
+  type: string;
+}
+
+export interface DelegationCreated {
+  id: string;
+  path: string;
+}
+
+export interface DelegationRequest {
+  account: string;
+  "cname-map"?: { [key: string]: string };
+  "csr-template": CSRTemplate;
+}
+
+export interface Entry {
+  actor?: string;
+  details?: { [key: string]: string };
+  hash: string;
+  prevHash: string;
+  seq: number;
+  time: string;
+  type: string;
+}
+
+export interface ExpectedKeyAuthorization {
+  extensions?: { [key: string]: unknown };
+  id: string;
+  keyAuthorization: string;
+  overridden: boolean;
+  type: string;
+}
+
+export interface ExternalAccountBindings {
+  accounts: { [key: string]: string };
+  extensions?: { [key: string]: unknown };
+  keys: ExternalAccountKey[];
+}
+
+export interface ExternalAccountKey {
+  accounts: string[];
+  extensions?: { [key: string]: unknown };
+  hmacKey: string;
+  keyID: string;
+  status: string;
+}
+
+export interface ExternalAccountKeyRequest {
+  hmacKey?: string;
+  keyID: string;
+}
+
+export interface FailureReport {
+  account?: string;
+  authorizations: AuthorizationFailure[];
+  error?: ProblemDetails;
+  extensions?: { [key: string]: unknown };
+  id: string;
+  identifiers?: Identifier[];
+  order?: string;
+  time: string;
+  type: string;
+}
+
+export interface FinalizeAttempt {
+  csr: string;
+  parseError?: string;
+  parsed?: ParsedCSR;
+  pem?: string;
+  problem?: ProblemDetails;
+  time: string;
+}
+
+export interface ForcedStatus {
+  challenge?: string;
+  error?: ProblemDetails;
+  status: string;
+}
+
+export interface ForcedStatusResult {
+  id: string;
+  status: string;
+}
+
+export interface Identifier {
+  ancestorDomain?: string;
+  type: string;
+  value: string;
+}
+
+export interface Interaction {
+  durationMs: number;
+  endpoint: string;
+  extensions?: { [key: string]: unknown };
+  location?: string;
+  method: string;
+  path: string;
+  problemType?: string;
+  request?: string;
+  response?: string;
+  status: number;
+  time: string;
+  truncated?: boolean;
+}
+
+export interface KeyAuthorizationOverride {
+  keyAuthorization?: string;
+  thumbprint?: string;
+}
+
+export interface LatencyProfile {
+  delay?: number;
+  distribution: string;
+  max?: number;
+  median?: number;
+  min?: number;
+  sigma?: number;
+}
+
+export interface MaintenanceWindow {
+  detail?: string;
+  end?: string;
+  endpoints?: string[];
+  retryAfter?: number;
+  start?: string;
+}
+
+export interface MassRevocation {
+  ends: string;
+  extensions?: { [key: string]: unknown };
+  id: string;
+  reason: number;
+  revoked: number;
+  serials: string[];
+  started: string;
+  status: string;
+}
+
+export interface MassRevocationRequest {
+  account?: string;
+  percent: number;
+  reason?: number;
+  san?: string;
+  window?: number;
+}
+
+export interface Namespace {
+  accounts: string[];
+  certificates: string[];
+  extensions?: { [key: string]: unknown };
+  name: string;
+  orders: string[];
+}
+
+export interface OrderCSRs {
+  attempts: FinalizeAttempt[];
+  order: string;
+}
+
+export interface OrderCancellation {
+  error?: ProblemDetails;
+}
+
+export interface OrderReport {
+  account: string;
+  authorizations: AuthorizationReport[];
+  certificate?: string;
+  error?: ProblemDetails;
+  expires: string;
+  extensions?: { [key: string]: unknown };
+  generated: string;
+  id: string;
+  identifiers: Identifier[];
+  interactions: Interaction[];
+  status: string;
+}
+
+export interface OutcomeRule {
+  attempts?: number;
+  error?: ProblemDetails;
+  outcome: string;
+  pattern: string;
+}
+
+export interface ParsedCSR {
+  dnsNames?: string[];
+  emailAddresses?: string[];
+  extensions?: CsrExtension[];
+  ipAddresses?: string[];
+  keyType?: string;
+  publicKeyAlgorithm: string;
+  signatureAlgorithm: string;
+  signatureValid: boolean;
+  subject: string;
+  uris?: string[];
+}
+
+export interface ProblemDetails {
+  detail?: string;
+  status?: number;
+  type?: string;
+  [key: string]: unknown;
+}
+
+export interface ProcessingHold {
+  duration?: number;
+  order?: string;
+  pattern?: string;
+}
+
+export interface ProcessingHolds {
+  held: string[];
+  holds: ProcessingHold[];
+}
+
+export interface ResponseSigningKey {
+  alg: string;
+  extensions?: { [key: string]: unknown };
+  header: string;
+  jwk: unknown;
+  kid: string;
+}
+
+export interface RevocationResult {
+  extensions?: { [key: string]: unknown };
+  notFound?: string[];
+  revoked: CertificateSummary[];
+}
+
+export interface RootInfo {
+  default: boolean;
+  extensions?: { [key: string]: unknown };
+  index: number;
+  sha256: string;
+  subject: string;
+}
+
+export interface RotateIssuersRequest {
+  root: boolean;
+}
+
+export interface RuntimeInfoResult {
+  config: unknown;
+  features: string[];
+  listeners: { [key: string]: string };
+  roots: RootInfo[];
+  version: string;
+}
+
+export interface SeedSpec {
+  accounts: number;
+  domain: string;
+  host: string;
+  orders: { [key: string]: number };
+}
+
+export interface SeededAccount {
+  certificates?: string[];
+  orders: string[];
+  privateKey: string;
+  url: string;
+}
+
+export interface TestMetaOrder {
+  account: string;
+  extensions?: { [key: string]: unknown };
+  id: string;
+  identifiers: Identifier[];
+  status: string;
+  "test-meta": unknown;
+}
+
+export interface ValidationAttempt {
+  attempt: number;
+  completed?: string;
+  error?: ProblemDetails;
+  started?: string;
+  time: string;
+}
+
+export interface WipeNamespaceRequest {
+  name: string;
+}
+
+/** The query parameters of findAnnotated. */
+export interface FindAnnotatedParams {
+  /** Only find objects of the collection: accounts, orders or certificates. */
+  collection?: string;
+  /** An annotation selector, e.g. "scenario=renewal,flaky". */
+  selector?: string;
+}
+
+/** The query parameters of getChain. */
+export interface GetChainParams {
+  /** The format of the certificates: pem, der or pkcs7. */
+  format?: string;
+}
+
+/** The query parameters of getDashboard. */
+export interface GetDashboardParams {
+  /** How often the page reloads itself in seconds, 5 by default. */
+  refresh?: number;
+}
+
+/** The query parameters of getFailures. */
+export interface GetFailuresParams {
+  /** The ID or URL of the account of the failures. */
+  account?: string;
+  /** An RFC 3339 time the failures happened after. */
+  since?: string;
+}
+
+/** The query parameters of getIntermediate. */
+export interface GetIntermediateParams {
+  /** The format of the certificates: pem, der or pkcs7. */
+  format?: string;
+}
+
+/** The query parameters of getOrderReport. */
+export interface GetOrderReportParams {
+  /** json, the default, or html. */
+  format?: string;
+}
+
+/** The query parameters of getRoot. */
+export interface GetRootParams {
+  /** The format of the certificates: pem, der or pkcs7. */
+  format?: string;
+}
+
+/** The query parameters of getTestMetaOrders. */
+export interface GetTestMetaOrdersParams {
+  /** The test-meta value of the orders. */
+  value?: string;
+}
+
+/** The query parameters of releaseOrders. */
+export interface ReleaseOrdersParams {
+  /** The ID of the order to release. */
+  order?: string;
+}
+
+/** The query parameters of searchCertificates. */
+export interface SearchCertificatesParams {
+  /** A DNS name of the certificates. */
+  san?: string;
+  /** The ID or URL of the account the certificates were issued to. */
+  account?: string;
+  /** The lowest hex serial number. */
+  serialMin?: string;
+  /** The highest hex serial number. */
+  serialMax?: string;
+  /** An RFC 3339 time the certificates' NotBefore is after. */
+  issuedAfter?: string;
+  /** An RFC 3339 time the certificates' NotBefore is before. */
+  issuedBefore?: string;
+  /** Include each certificate in PEM format. */
+  pem?: boolean;
+  /** An annotation selector, e.g. "scenario=renewal,flaky". */
+  selector?: string;
+}
+
+/** The query parameters of streamEvents. */
+export interface StreamEventsParams {
+  /** Comma separated event types to stream. */
+  type?: string;
+}
+
+/** Paginates, filters and sorts the results of list operations. */
+export interface ListOptions {
+  /** The most results returned. Zero or undefined returns every result. */
+  limit?: number;
+  offset?: number;
+  /** The fields the results are sorted by, descending if prefixed with "-". */
+  sort?: string[];
+  /** field:value pairs the results must match. */
+  filter?: string[];
+  /** The fields each result is returned with. */
+  fields?: string[];
+}
+
+/** A page of the results of a list operation. */
+export interface ListResult<T> {
+  items: T[];
+  /** The number of results that passed the list's filters. */
+  total: number;
+}
+
+/** A problem document the management interface responded with. */
+export class ManagementError extends Error {
+  constructor(readonly status: number, readonly problem?: ProblemDetails) {
+    super(`pebble management API: ${status} ${problem?.type ?? ""}: ${problem?.detail ?? ""}`);
+  }
+}
+
+export interface ClientOptions {
+  /** Sent as a bearer token if set. */
+  token?: string;
+  /** Sends the requests. Defaults to the global fetch. */
+  fetch?: typeof fetch;
+}
+
+function addListOptions(query: URLSearchParams, list: ListOptions): void {
+  if (list.limit) query.set("limit", String(list.limit));
+  if (list.offset) query.set("offset", String(list.offset));
+  if (list.sort?.length) query.set("sort", list.sort.join(","));
+  for (const f of list.filter ?? []) query.append("filter", f);
+  if (list.fields?.length) query.set("fields", list.fields.join(","));
+}
+
+/** Calls the management interface of a Pebble instance. */
+export class ManagementClient {
+  /** @param baseURL The URL of the management interface, e.g. "https://localhost:15000". */
+  constructor(readonly baseURL: string, readonly options: ClientOptions = {}) {}
+
+  private async request(method: string, path: string, query: URLSearchParams, body?: unknown): Promise<Response> {
+    const qs = query.toString();
+    const url = this.baseURL.replace(/\/$/, "") + path + (qs ? "?" + qs : "");
+    const headers: Record<string, string> = {};
+    if (body !== undefined) headers["Content-Type"] = "application/json";
+    if (this.options.token) headers["Authorization"] = "Bearer " + this.options.token;
+    const resp = await (this.options.fetch ?? fetch)(url, {
+      method,
+      headers,
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    if (resp.status >= 300) {
+      let problem: ProblemDetails | undefined;
+      try {
+        problem = await resp.json();
+      } catch {
+        problem = undefined;
+      }
+      throw new ManagementError(resp.status, problem);
+    }
+    return resp;
+  }
+
+  /** Add a STAR delegation to an account. */
+  async addDelegation(body: DelegationRequest): Promise<DelegationCreated> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/delegations", query, body)).json();
+  }
+
+  /** Merge annotations into those of an account, removing those with empty values. */
+  async annotateAccount(id: string, body: { [key: string]: string }): Promise<{ [key: string]: string }> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/account-annotations/" + encodeURIComponent(id), query, body)).json();
+  }
+
+  /** Merge annotations into those of a certificate, removing those with empty values. */
+  async annotateCertificate(serial: string, body: { [key: string]: string }): Promise<{ [key: string]: string }> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/certificate-annotations/" + encodeURIComponent(serial), query, body)).json();
+  }
+
+  /** Merge annotations into those of an order, removing those with empty values. */
+  async annotateOrder(id: string, body: { [key: string]: string }): Promise<{ [key: string]: string }> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/order-annotations/" + encodeURIComponent(id), query, body)).json();
+  }
+
+  /** Make an in-flight order invalid. */
+  async cancelOrder(order: string, body?: OrderCancellation): Promise<ForcedStatusResult> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/cancel-order/" + encodeURIComponent(order), query, body)).json();
+  }
+
+  /** Remove the key authorization override of a pending challenge. */
+  async clearChallengeKeyAuthorization(id: string): Promise<ExpectedKeyAuthorization> {
+    const query = new URLSearchParams();
+    return (await this.request("DELETE", "/challenge-key-authorization/" + encodeURIComponent(id), query)).json();
+  }
+
+  /** Remove every object of a store collection. */
+  async clearCollection(collection: string): Promise<void> {
+    const query = new URLSearchParams();
+    await this.request("DELETE", "/store/" + encodeURIComponent(collection), query);
+  }
+
+  /** Find the annotated accounts, orders and certificates. */
+  async findAnnotated(params: FindAnnotatedParams = {}, list: ListOptions = {}): Promise<ListResult<AnnotatedObject>> {
+    const query = new URLSearchParams();
+    if (params.collection !== undefined) query.set("collection", String(params.collection));
+    if (params.selector !== undefined) query.set("selector", String(params.selector));
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/annotated", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** Make an authorization valid, invalid or expired. */
+  async forceAuthzStatus(id: string, body: ForcedStatus): Promise<ForcedStatusResult> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/authz-status/" + encodeURIComponent(id), query, body)).json();
+  }
+
+  /** Make a challenge valid or invalid. */
+  async forceChallengeStatus(id: string, body: ForcedStatus): Promise<ForcedStatusResult> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/challenge-status/" + encodeURIComponent(id), query, body)).json();
+  }
+
+  /** Force the status of an order. */
+  async forceOrderStatus(id: string, body: ForcedStatus): Promise<ForcedStatusResult> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/order-status/" + encodeURIComponent(id), query, body)).json();
+  }
+
+  /** Get the annotations of an account. */
+  async getAccountAnnotations(id: string): Promise<{ [key: string]: string }> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/account-annotations/" + encodeURIComponent(id), query)).json();
+  }
+
+  /** Get the attestation object submitted when an account was created. */
+  async getAccountAttestation(id: string): Promise<AccountAttestation> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/account-attestation/" + encodeURIComponent(id), query)).json();
+  }
+
+  /** Get the account overrides. */
+  async getAccountOverrides(): Promise<{ [key: string]: AccountOverride }> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/account-overrides", query)).json();
+  }
+
+  /** Get the entries of the audit log. */
+  async getAuditLog(list: ListOptions = {}): Promise<ListResult<Entry>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/audit-log", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** Get the key and certificate of an auto-finalized order. */
+  async getAutoFinalized(order: string): Promise<AutoFinalizedOrder> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/auto-finalized/" + encodeURIComponent(order), query)).json();
+  }
+
+  /** Get the annotations of a certificate. */
+  async getCertificateAnnotations(serial: string): Promise<{ [key: string]: string }> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/certificate-annotations/" + encodeURIComponent(serial), query)).json();
+  }
+
+  /** Get the certificates of a chain. */
+  async getChain(index: string, params: GetChainParams = {}): Promise<string> {
+    const query = new URLSearchParams();
+    if (params.format !== undefined) query.set("format", String(params.format));
+    return (await this.request("GET", "/chains/" + encodeURIComponent(index), query)).text();
+  }
+
+  /** Get the key authorization the VA expects for a challenge. */
+  async getChallengeKeyAuthorization(id: string): Promise<ExpectedKeyAuthorization> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/challenge-key-authorization/" + encodeURIComponent(id), query)).json();
+  }
+
+  /** Get when a challenge's validation attempts ran. */
+  async getChallengeTiming(id: string): Promise<ChallengeTiming> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/challenge-timing/" + encodeURIComponent(id), query)).json();
+  }
+
+  /** Get which challenge types are enabled. */
+  async getChallengeTypes(): Promise<{ [key: string]: boolean }> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/challenge-types", query)).json();
+  }
+
+  /** Get the size of a store collection. */
+  async getCollectionStats(collection: string): Promise<CollectionStats> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/store/" + encodeURIComponent(collection), query)).json();
+  }
+
+  /** Get the HTML dashboard of the store's contents. */
+  async getDashboard(params: GetDashboardParams = {}): Promise<string> {
+    const query = new URLSearchParams();
+    if (params.refresh !== undefined) query.set("refresh", String(params.refresh));
+    return (await this.request("GET", "/dashboard", query)).text();
+  }
+
+  /** Get the snapshot of the store shown by the dashboard. */
+  async getDashboardData(): Promise<Dashboard> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/dashboard.json", query)).json();
+  }
+
+  /** Get the external account keys and bound accounts. */
+  async getExternalAccountKeys(): Promise<ExternalAccountBindings> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/external-account-keys", query)).json();
+  }
+
+  /** Get the orders and authorizations that became invalid. */
+  async getFailures(params: GetFailuresParams = {}, list: ListOptions = {}): Promise<ListResult<FailureReport>> {
+    const query = new URLSearchParams();
+    if (params.account !== undefined) query.set("account", String(params.account));
+    if (params.since !== undefined) query.set("since", String(params.since));
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/failures", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** Check that the management interface is up. */
+  async getHealth(): Promise<string> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/healthz", query)).text();
+  }
+
+  /** Get the runtime info and root fingerprints. */
+  async getInfo(): Promise<RuntimeInfoResult> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/info", query)).json();
+  }
+
+  /** Get the intermediate certificate of a chain. */
+  async getIntermediate(index: string, params: GetIntermediateParams = {}): Promise<string> {
+    const query = new URLSearchParams();
+    if (params.format !== undefined) query.set("format", String(params.format));
+    return (await this.request("GET", "/intermediates/" + encodeURIComponent(index), query)).text();
+  }
+
+  /** Get the latency profiles. */
+  async getLatencyProfiles(): Promise<{ [key: string]: LatencyProfile }> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/latency", query)).json();
+  }
+
+  /** Get the maintenance windows. */
+  async getMaintenanceWindows(list: ListOptions = {}): Promise<ListResult<MaintenanceWindow>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/maintenance", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** Get metrics in the Prometheus text exposition format. */
+  async getMetrics(): Promise<string> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/metrics", query)).text();
+  }
+
+  /** Get this OpenAPI document. */
+  async getOpenAPI(): Promise<string> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/openapi.json", query)).json();
+  }
+
+  /** Get the annotations of an order. */
+  async getOrderAnnotations(id: string): Promise<{ [key: string]: string }> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/order-annotations/" + encodeURIComponent(id), query)).json();
+  }
+
+  /** Get the CSRs submitted to finalize an order. */
+  async getOrderCSRs(order: string): Promise<OrderCSRs> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/order-csrs/" + encodeURIComponent(order), query)).json();
+  }
+
+  /** Get the report of every ACME interaction of an order. */
+  async getOrderReport(order: string, params: GetOrderReportParams = {}): Promise<OrderReport> {
+    const query = new URLSearchParams();
+    if (params.format !== undefined) query.set("format", String(params.format));
+    return (await this.request("GET", "/order-report/" + encodeURIComponent(order), query)).json();
+  }
+
+  /** Get the processing holds and the held orders. */
+  async getProcessingHolds(): Promise<ProcessingHolds> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/processing-holds", query)).json();
+  }
+
+  /** Check that Pebble is ready to serve ACME requests. */
+  async getReadiness(): Promise<string> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/readyz", query)).text();
+  }
+
+  /** Get the public key directory and certificate responses are signed with. */
+  async getResponseSigningKey(): Promise<ResponseSigningKey> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/response-signing-key", query)).json();
+  }
+
+  /** Get the root certificate of a chain. */
+  async getRoot(index: string, params: GetRootParams = {}): Promise<string> {
+    const query = new URLSearchParams();
+    if (params.format !== undefined) query.set("format", String(params.format));
+    return (await this.request("GET", "/roots/" + encodeURIComponent(index), query)).text();
+  }
+
+  /** Get the size of every store collection. */
+  async getStoreStats(): Promise<{ [key: string]: CollectionStats }> {
+    const query = new URLSearchParams();
+    return (await this.request("GET", "/store/", query)).json();
+  }
+
+  /** Get the orders created with test metadata. */
+  async getTestMetaOrders(params: GetTestMetaOrdersParams = {}, list: ListOptions = {}): Promise<ListResult<TestMetaOrder>> {
+    const query = new URLSearchParams();
+    if (params.value !== undefined) query.set("value", String(params.value));
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/test-meta", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** Get the validation outcome rules. */
+  async getValidationOutcomes(list: ListOptions = {}): Promise<ListResult<OutcomeRule>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/validation-outcomes", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** List the simulated CT logs. */
+  async listCTLogs(list: ListOptions = {}): Promise<ListResult<CTLog>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/ct-logs", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** List the certificate chains. */
+  async listChains(list: ListOptions = {}): Promise<ListResult<CACertificate>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/chains/", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** List the intermediate certificates of every chain. */
+  async listIntermediates(list: ListOptions = {}): Promise<ListResult<CACertificate>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/intermediates/", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** List the mass revocation incidents started. */
+  async listMassRevocations(list: ListOptions = {}): Promise<ListResult<MassRevocation>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/mass-revocations", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** List the namespaces and their objects. */
+  async listNamespaces(list: ListOptions = {}): Promise<ListResult<Namespace>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/namespaces", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** List the root certificates of every chain. */
+  async listRoots(list: ListOptions = {}): Promise<ListResult<CACertificate>> {
+    const query = new URLSearchParams();
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/roots/", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** Override the key authorization the VA expects for a pending challenge. */
+  async overrideChallengeKeyAuthorization(id: string, body: KeyAuthorizationOverride): Promise<ExpectedKeyAuthorization> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/challenge-key-authorization/" + encodeURIComponent(id), query, body)).json();
+  }
+
+  /** Release a held order, or every held order. */
+  async releaseOrders(params: ReleaseOrdersParams = {}): Promise<string[]> {
+    const query = new URLSearchParams();
+    if (params.order !== undefined) query.set("order", String(params.order));
+    return (await this.request("POST", "/release-orders", query)).json();
+  }
+
+  /** Revoke every certificate matching the request. */
+  async revokeCertificates(body: BulkRevocation): Promise<RevocationResult> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/revoke-certificates", query, body)).json();
+  }
+
+  /** Revoke an external account key. */
+  async revokeExternalAccountKey(body: ExternalAccountKeyRequest): Promise<ExternalAccountKey> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/revoke-external-account-key", query, body)).json();
+  }
+
+  /** Add or rotate an external account key. */
+  async rotateExternalAccountKey(body: ExternalAccountKeyRequest): Promise<ExternalAccountKey> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/external-account-keys", query, body)).json();
+  }
+
+  /** Replace the issuing intermediates, and optionally the roots. */
+  async rotateIssuers(body?: RotateIssuersRequest): Promise<void> {
+    const query = new URLSearchParams();
+    await this.request("POST", "/rotate-issuers", query, body);
+  }
+
+  /** Search the unrevoked issued certificates. */
+  async searchCertificates(params: SearchCertificatesParams = {}, list: ListOptions = {}): Promise<ListResult<CertificateSummary>> {
+    const query = new URLSearchParams();
+    if (params.san !== undefined) query.set("san", String(params.san));
+    if (params.account !== undefined) query.set("account", String(params.account));
+    if (params.serialMin !== undefined) query.set("serialMin", String(params.serialMin));
+    if (params.serialMax !== undefined) query.set("serialMax", String(params.serialMax));
+    if (params.issuedAfter !== undefined) query.set("issuedAfter", String(params.issuedAfter));
+    if (params.issuedBefore !== undefined) query.set("issuedBefore", String(params.issuedBefore));
+    if (params.pem !== undefined) query.set("pem", String(params.pem));
+    if (params.selector !== undefined) query.set("selector", String(params.selector));
+    addListOptions(query, list);
+    const resp = await this.request("GET", "/certificates", query);
+    return { items: await resp.json(), total: Number(resp.headers.get("Pebble-Total-Count") ?? 0) };
+  }
+
+  /** Populate the store with accounts, orders and certificates. */
+  async seedStore(body: SeedSpec): Promise<SeededAccount[]> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/seed", query, body)).json();
+  }
+
+  /** Replace the account overrides. */
+  async setAccountOverrides(body: { [key: string]: AccountOverride }): Promise<{ [key: string]: AccountOverride }> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/account-overrides", query, body)).json();
+  }
+
+  /** Enable or disable challenge types. */
+  async setChallengeTypes(body: { [key: string]: boolean }): Promise<{ [key: string]: boolean }> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/challenge-types", query, body)).json();
+  }
+
+  /** Replace the latency profiles. */
+  async setLatencyProfiles(body: { [key: string]: LatencyProfile }): Promise<{ [key: string]: LatencyProfile }> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/latency", query, body)).json();
+  }
+
+  /** Replace the maintenance windows. */
+  async setMaintenanceWindows(body: MaintenanceWindow[]): Promise<MaintenanceWindow[]> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/maintenance", query, body)).json();
+  }
+
+  /** Replace the processing holds. */
+  async setProcessingHolds(body: ProcessingHold[]): Promise<ProcessingHolds> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/processing-holds", query, body)).json();
+  }
+
+  /** Replace the validation outcome rules. */
+  async setValidationOutcomes(body: OutcomeRule[]): Promise<OutcomeRule[]> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/validation-outcomes", query, body)).json();
+  }
+
+  /** Revoke a percentage of the unrevoked certificates over a time window. */
+  async startMassRevocation(body: MassRevocationRequest): Promise<MassRevocation> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/mass-revocations", query, body)).json();
+  }
+
+  /** Stream status transitions as server-sent events. */
+  async streamEvents(params: StreamEventsParams = {}): Promise<string> {
+    const query = new URLSearchParams();
+    if (params.type !== undefined) query.set("type", String(params.type));
+    return (await this.request("GET", "/events", query)).text();
+  }
+
+  /** Remove the objects of a namespace. */
+  async wipeNamespace(body: WipeNamespaceRequest): Promise<Namespace> {
+    const query = new URLSearchParams();
+    return (await this.request("POST", "/wipe-namespace", query, body)).json();
+  }
+}
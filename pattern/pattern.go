@@ -0,0 +1,88 @@
+// Package pattern matches identifier values, endpoint names and account IDs
+// against the patterns used throughout Pebble's config, so that one entry can
+// apply to a whole family of names, e.g. "*.staging.example.com".
+//
+// A pattern wrapped in slashes, e.g. "/^api-[0-9]+\.example\.com$/", is a
+// regular expression (see regexp/syntax) that must match the whole value.
+// Any other pattern is a shell pattern (see path.Match). Matching is case
+// insensitive.
+package pattern
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// compiled caches the compiled regular expressions of regexp patterns.
+var compiled sync.Map
+
+// isRegexp returns true if the pattern is a regular expression.
+func isRegexp(p string) bool {
+	return len(p) >= 2 && strings.HasPrefix(p, "/") && strings.HasSuffix(p, "/")
+}
+
+func compile(p string) (*regexp.Regexp, error) {
+	if re, ok := compiled.Load(p); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile("(?i)^(?:" + p[1:len(p)-1] + ")$")
+	if err != nil {
+		return nil, err
+	}
+	compiled.Store(p, re)
+	return re, nil
+}
+
+// Check returns an error if the pattern is malformed.
+func Check(p string) error {
+	if isRegexp(p) {
+		if _, err := compile(p); err != nil {
+			return fmt.Errorf("invalid regular expression %q: %s", p, err)
+		}
+		return nil
+	}
+	if _, err := path.Match(p, ""); err != nil {
+		return fmt.Errorf("invalid pattern %q: %s", p, err)
+	}
+	return nil
+}
+
+// Match returns true if the value matches the pattern. Malformed patterns
+// match nothing.
+func Match(p, value string) bool {
+	if isRegexp(p) {
+		re, err := compile(p)
+		return err == nil && re.MatchString(value)
+	}
+	matched, _ := path.Match(strings.ToLower(p), strings.ToLower(value))
+	return matched
+}
+
+// IsLiteral returns true if the pattern only matches itself.
+func IsLiteral(p string) bool {
+	return !isRegexp(p) && !strings.ContainsAny(p, `*?[\`)
+}
+
+// Lookup returns the pattern that best matches the value: one equal to it, or
+// otherwise the longest matching pattern, on the basis that longer patterns
+// are more specific. It returns false if no pattern matches.
+func Lookup(patterns []string, value string) (string, bool) {
+	var best string
+	found := false
+	for _, p := range patterns {
+		if strings.EqualFold(p, value) {
+			return p, true
+		}
+		if !Match(p, value) {
+			continue
+		}
+		if !found || len(p) > len(best) || (len(p) == len(best) && p < best) {
+			best = p
+			found = true
+		}
+	}
+	return best, found
+}
@@ -0,0 +1,218 @@
+// Package publish writes every issued certificate to a drop-point, so that
+// downstream systems watching for new certificates can be integration tested
+// against Pebble. Certificates can be written to a directory, PUT to an HTTP
+// endpoint or uploaded to an S3-compatible bucket.
+package publish
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/logging"
+	"github.com/letsencrypt/pebble/timeouts"
+)
+
+const (
+	// ContentType is the Content-Type of the objects PUT to HTTP endpoints
+	// and S3 buckets.
+	ContentType = "application/pem-certificate-chain"
+
+	defaultMaxAttempts    = 3
+	defaultRetryDelay     = time.Second
+	defaultRequestTimeout = 10 * time.Second
+)
+
+// Config configures where certificates are published. The zero value
+// publishes nothing.
+//
+// Each certificate is published as three objects named after its hex serial
+// number, optionally under Prefix: "<serial>/cert.pem" holds the
+// certificate, "<serial>/chain.pem" its issuer chain and
+// "<serial>/fullchain.pem" both, excluding the root.
+type Config struct {
+	// Directory is the directory the objects are written to. Each is written
+	// to a temporary file first and renamed, so that watchers never see a
+	// partial file.
+	Directory string
+	// URL is the base URL the objects are PUT under.
+	URL string
+	// S3 is the S3-compatible bucket the objects are uploaded to.
+	S3 S3Config
+	// Prefix is prepended to the name of every object, e.g. "pebble/".
+	Prefix string
+	// MaxAttempts is the number of times a PUT or upload is attempted.
+	// Defaults to 3.
+	MaxAttempts int
+	// RetryDelay is the delay between attempts. Defaults to one second.
+	RetryDelay time.Duration
+	// RequestTimeout bounds each attempt. Defaults to ten seconds.
+	RequestTimeout time.Duration
+	// TimeoutCounter counts the attempts that time out. It may be nil.
+	TimeoutCounter *timeouts.Counter
+}
+
+func (c Config) check() error {
+	if c.URL != "" && !strings.HasPrefix(c.URL, "http://") && !strings.HasPrefix(c.URL, "https://") {
+		return fmt.Errorf("URL %q isn't an HTTP URL", c.URL)
+	}
+	if c.S3.Bucket != "" {
+		return c.S3.check()
+	}
+	return nil
+}
+
+// Publisher publishes issued certificates. A nil *Publisher publishes
+// nothing, so callers don't need to check whether publishing is configured.
+type Publisher struct {
+	log    logging.Logger
+	clk    clock.Clock
+	config Config
+	client *http.Client
+}
+
+// New returns a Publisher for the given config, or nil if it has no
+// destination. It panics if the config is invalid.
+func New(log logging.Logger, clk clock.Clock, config Config) *Publisher {
+	if config.Directory == "" && config.URL == "" && config.S3.Bucket == "" {
+		return nil
+	}
+	if err := config.check(); err != nil {
+		panic(fmt.Sprintf("Invalid publish config: %s", err.Error()))
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = defaultRequestTimeout
+	}
+	if config.S3.Region == "" {
+		config.S3.Region = defaultS3Region
+	}
+	return &Publisher{
+		log:    log,
+		clk:    clk,
+		config: config,
+		client: &http.Client{Timeout: config.RequestTimeout},
+	}
+}
+
+// object is a named object published for a certificate.
+type object struct {
+	name string
+	data []byte
+}
+
+func (p *Publisher) objects(cert *core.Certificate) []object {
+	leaf := cert.PEM()
+	fullChain := cert.Chain()
+	name := p.config.Prefix + cert.ID + "/"
+	return []object{
+		{name: name + "cert.pem", data: leaf},
+		{name: name + "chain.pem", data: fullChain[len(leaf):]},
+		{name: name + "fullchain.pem", data: fullChain},
+	}
+}
+
+// Publish publishes the certificate to every configured destination in the
+// background.
+func (p *Publisher) Publish(cert *core.Certificate) {
+	if p == nil {
+		return
+	}
+	objects := p.objects(cert)
+	go func() {
+		for _, obj := range objects {
+			if p.config.Directory != "" {
+				if err := p.write(obj); err != nil {
+					p.log.Printf("publish: error writing %s: %s\n", obj.name, err)
+				}
+			}
+			if p.config.URL != "" {
+				p.retry(obj, "PUT to "+p.config.URL, p.put)
+			}
+			if p.config.S3.Bucket != "" {
+				p.retry(obj, "upload to bucket "+p.config.S3.Bucket, p.upload)
+			}
+		}
+		p.log.Printf("publish: published certificate %s\n", cert.ID)
+	}()
+}
+
+// write writes the object to the directory, through a temporary file that is
+// renamed once it is complete.
+func (p *Publisher) write(obj object) error {
+	filename := filepath.Join(p.config.Directory, filepath.FromSlash(obj.name))
+	if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), ".tmp-"+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(obj.data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// retry attempts to send the object up to MaxAttempts times.
+func (p *Publisher) retry(obj object, description string, send func(object) error) {
+	for attempt := 1; attempt <= p.config.MaxAttempts; attempt++ {
+		err := send(obj)
+		if err == nil {
+			return
+		}
+		p.log.Printf("publish: attempt %d/%d to %s %s failed: %s\n",
+			attempt, p.config.MaxAttempts, description, obj.name, err)
+		if attempt < p.config.MaxAttempts {
+			p.clk.Sleep(p.config.RetryDelay)
+		}
+	}
+}
+
+// put PUTs the object to its URL under the base URL.
+func (p *Publisher) put(obj object) error {
+	url := strings.TrimSuffix(p.config.URL, "/") + "/" + path.Clean(obj.name)
+	req, err := http.NewRequest("PUT", url, bytes.NewReader(obj.data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	return p.do(req)
+}
+
+// do sends the request, failing unless it gets a 2xx response.
+func (p *Publisher) do(req *http.Request) error {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.config.TimeoutCounter.Check(err, "publish", "request")
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
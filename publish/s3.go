@@ -0,0 +1,142 @@
+package publish
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	defaultS3Region = "us-east-1"
+
+	amzDateFormat = "20060102T150405Z"
+)
+
+// S3Config configures an S3-compatible bucket. Objects are uploaded with
+// path-style PUT requests signed with AWS Signature Version 4, so any store
+// that implements the S3 PutObject API, such as MinIO, can be used.
+type S3Config struct {
+	// Endpoint is the base URL of the store, e.g. "http://localhost:9000".
+	Endpoint string
+	// Bucket is the name of the bucket.
+	Bucket string
+	// Region is the region requests are signed for. Defaults to us-east-1.
+	Region string
+	// AccessKeyID and SecretAccessKey are the credentials requests are
+	// signed with.
+	AccessKeyID     string
+	SecretAccessKey string
+}
+
+func (c S3Config) check() error {
+	if !strings.HasPrefix(c.Endpoint, "http://") && !strings.HasPrefix(c.Endpoint, "https://") {
+		return fmt.Errorf("S3 endpoint %q isn't an HTTP URL", c.Endpoint)
+	}
+	if c.AccessKeyID == "" || c.SecretAccessKey == "" {
+		return fmt.Errorf("S3 bucket %q has no credentials", c.Bucket)
+	}
+	return nil
+}
+
+// upload PUTs the object to the bucket.
+func (p *Publisher) upload(obj object) error {
+	s3 := p.config.S3
+	target := strings.TrimSuffix(s3.Endpoint, "/") + "/" + s3.Bucket + "/" + obj.name
+	req, err := http.NewRequest("PUT", target, bytes.NewReader(obj.data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", ContentType)
+	payloadHash := sha256.Sum256(obj.data)
+	signV4(req, hex.EncodeToString(payloadHash[:]), s3, p.clk.Now())
+	return p.do(req)
+}
+
+// signV4 signs the request with AWS Signature Version 4 for the S3 service,
+// using the hex encoded SHA-256 hash of its payload. The host header and every
+// header set on the request are signed.
+func signV4(req *http.Request, payloadHash string, config S3Config, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format(amzDateFormat)
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL),
+		canonicalQuery(req.URL),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + config.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalHash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+config.SecretAccessKey), date)
+	key = hmacSHA256(key, config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		config.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// canonicalURI returns the URI encoded path of the URL. S3 paths aren't
+// normalized and are encoded once.
+func canonicalURI(u *url.URL) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// canonicalQuery returns the query parameters of the URL sorted by name.
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	pairs := make([]string, 0, len(query))
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(name)+"="+url.QueryEscape(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Replace(strings.Join(pairs, "&"), "+", "%20", -1)
+}
@@ -0,0 +1,90 @@
+// Package timeouts counts the operations of Pebble's components that time
+// out, so that the management interface can report how often a strict
+// timeout configuration was hit.
+package timeouts
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+)
+
+// Count is the number of times an operation of a component timed out.
+type Count struct {
+	Component string
+	Operation string
+	Count     int64
+}
+
+type operation struct {
+	component string
+	name      string
+}
+
+// Counter counts timed out operations by component and operation. A nil
+// *Counter counts nothing.
+type Counter struct {
+	sync.Mutex
+	counts map[operation]int64
+}
+
+// NewCounter returns an empty Counter.
+func NewCounter() *Counter {
+	return &Counter{counts: make(map[operation]int64)}
+}
+
+// Record records that the operation of the component timed out.
+func (c *Counter) Record(component, name string) {
+	if c == nil {
+		return
+	}
+	c.Lock()
+	defer c.Unlock()
+	c.counts[operation{component: component, name: name}]++
+}
+
+// Check records that the operation of the component timed out if err is a
+// timeout error, and returns true if it is.
+func (c *Counter) Check(err error, component, name string) bool {
+	if !Is(err) {
+		return false
+	}
+	c.Record(component, name)
+	return true
+}
+
+// Counts returns the counts of the operations that timed out, sorted by
+// component and operation.
+func (c *Counter) Counts() []Count {
+	if c == nil {
+		return nil
+	}
+	c.Lock()
+	defer c.Unlock()
+	counts := make([]Count, 0, len(c.counts))
+	for op, n := range c.counts {
+		counts = append(counts, Count{Component: op.component, Operation: op.name, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Component != counts[j].Component {
+			return counts[i].Component < counts[j].Component
+		}
+		return counts[i].Operation < counts[j].Operation
+	})
+	return counts
+}
+
+// Is returns true if err is, or wraps, context.DeadlineExceeded or a net.Error
+// that timed out.
+func Is(err error) bool {
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
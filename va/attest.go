@@ -0,0 +1,77 @@
+package va
+
+import (
+	"fmt"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+// AttestationVerifier verifies the attestation object submitted by a client
+// for a device-attest-01 challenge.
+type AttestationVerifier interface {
+	// Verify returns an error if attObj doesn't attest that the device with
+	// the given permanent identifier holds the key the challenge's key
+	// authorization was computed for.
+	Verify(identifier, keyAuthorization string, attObj []byte) error
+}
+
+// PermissiveAttestationVerifier accepts any well formed WebAuthn-style
+// attestation object, i.e. a CBOR map with "fmt", "attStmt" and "authData"
+// entries. The attestation statement and its signature aren't checked, so
+// it must only be used for testing.
+type PermissiveAttestationVerifier struct{}
+
+func (PermissiveAttestationVerifier) Verify(identifier, keyAuthorization string, attObj []byte) error {
+	_, err := ParseAttestationObject(attObj)
+	return err
+}
+
+// ParseAttestationObject checks that attObj is a well formed WebAuthn-style
+// attestation object, as PermissiveAttestationVerifier does, and returns its
+// attestation statement format, e.g. "packed" or "tpm".
+func ParseAttestationObject(attObj []byte) (string, error) {
+	decoded, err := decodeCBOR(attObj)
+	if err != nil {
+		return "", fmt.Errorf("attestation object is not valid CBOR: %s", err)
+	}
+	m, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return "", fmt.Errorf("attestation object is not a CBOR map")
+	}
+	format, ok := m["fmt"].(string)
+	if !ok || format == "" {
+		return "", fmt.Errorf("attestation object has no \"fmt\" text string")
+	}
+	if _, ok := m["attStmt"].(map[interface{}]interface{}); !ok {
+		return "", fmt.Errorf("attestation object has no \"attStmt\" map")
+	}
+	if _, ok := m["authData"].([]byte); !ok {
+		return "", fmt.Errorf("attestation object has no \"authData\" byte string")
+	}
+	return format, nil
+}
+
+func (va VAImpl) validateDeviceAttest01(task *vaTask) *core.ValidationRecord {
+	result := &core.ValidationRecord{
+		URL:         task.Identifier,
+		ValidatedAt: va.clk.Now(),
+	}
+
+	task.Challenge.RLock()
+	attObj := task.Challenge.AttestationObject
+	task.Challenge.RUnlock()
+	if len(attObj) == 0 {
+		result.Error = acme.UnauthorizedProblem(fmt.Sprintf(
+			"No attestation object was submitted for the %s challenge", acme.ChallengeDeviceAttest01))
+		return result
+	}
+
+	keyAuth := task.Challenge.ExpectedKeyAuthorization(task.Account.Key)
+	if err := va.attestationVerifier.Verify(task.Identifier, keyAuth, attObj); err != nil {
+		result.Error = acme.UnauthorizedProblem(fmt.Sprintf(
+			"Attestation of %q failed: %s", task.Identifier, err))
+		return result
+	}
+	return result
+}
@@ -0,0 +1,168 @@
+package va
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// maxCBORDepth limits how deeply nested the CBOR items decoded by decodeCBOR
+// can be.
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the single CBOR (RFC 7049) data item in data. It supports
+// the subset of CBOR needed to inspect WebAuthn attestation objects: integers,
+// byte and text strings, arrays, maps, tags, simple values and floats, all of
+// definite length. Unsigned and negative integers are decoded as int64 (or
+// uint64 if they don't fit), byte strings as []byte, text strings as string,
+// arrays as []interface{} and maps as map[interface{}]interface{}. Tags are
+// dropped and their content returned.
+func decodeCBOR(data []byte) (interface{}, error) {
+	item, rest, err := decodeCBORItem(data, 0)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%d bytes of trailing data after CBOR item", len(rest))
+	}
+	return item, nil
+}
+
+var errCBORTruncated = errors.New("truncated CBOR data")
+
+func decodeCBORItem(data []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth {
+		return nil, nil, fmt.Errorf("CBOR data nested more than %d levels deep", maxCBORDepth)
+	}
+	if len(data) == 0 {
+		return nil, nil, errCBORTruncated
+	}
+	major := data[0] >> 5
+	info := data[0] & 0x1f
+	data = data[1:]
+
+	// Major type 7 with additional information 25-27 holds floats, which are
+	// decoded separately from the integer argument of the other types.
+	if major == 7 && info >= 25 && info <= 27 {
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, errCBORTruncated
+		}
+		var f float64
+		switch size {
+		case 2:
+			f = halfToFloat64(binary.BigEndian.Uint16(data))
+		case 4:
+			f = float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+		case 8:
+			f = math.Float64frombits(binary.BigEndian.Uint64(data))
+		}
+		return f, data[size:], nil
+	}
+
+	var arg uint64
+	switch {
+	case info < 24:
+		arg = uint64(info)
+	case info <= 27:
+		size := 1 << (info - 24)
+		if len(data) < size {
+			return nil, nil, errCBORTruncated
+		}
+		for _, b := range data[:size] {
+			arg = arg<<8 | uint64(b)
+		}
+		data = data[size:]
+	default:
+		return nil, nil, fmt.Errorf("unsupported CBOR additional information %d", info)
+	}
+
+	switch major {
+	case 0:
+		if arg > math.MaxInt64 {
+			return arg, data, nil
+		}
+		return int64(arg), data, nil
+	case 1:
+		if arg > math.MaxInt64 {
+			return nil, nil, fmt.Errorf("CBOR negative integer out of range")
+		}
+		return -1 - int64(arg), data, nil
+	case 2, 3:
+		if uint64(len(data)) < arg {
+			return nil, nil, errCBORTruncated
+		}
+		if major == 2 {
+			return append([]byte(nil), data[:arg]...), data[arg:], nil
+		}
+		return string(data[:arg]), data[arg:], nil
+	case 4:
+		if uint64(len(data)) < arg {
+			return nil, nil, errCBORTruncated
+		}
+		items := make([]interface{}, 0, arg)
+		for i := uint64(0); i < arg; i++ {
+			item, rest, err := decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+			data = rest
+		}
+		return items, data, nil
+	case 5:
+		if uint64(len(data))/2 < arg {
+			return nil, nil, errCBORTruncated
+		}
+		m := make(map[interface{}]interface{}, arg)
+		for i := uint64(0); i < arg; i++ {
+			key, rest, err := decodeCBORItem(data, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, uint64, string:
+			default:
+				return nil, nil, fmt.Errorf("unsupported CBOR map key type %T", key)
+			}
+			value, rest, err := decodeCBORItem(rest, depth+1)
+			if err != nil {
+				return nil, nil, err
+			}
+			m[key] = value
+			data = rest
+		}
+		return m, data, nil
+	case 6:
+		return decodeCBORItem(data, depth+1)
+	default:
+		switch arg {
+		case 20:
+			return false, data, nil
+		case 21:
+			return true, data, nil
+		}
+		return nil, data, nil
+	}
+}
+
+// halfToFloat64 converts an IEEE 754 half precision float to a float64.
+func halfToFloat64(h uint16) float64 {
+	sign := 1.0
+	if h&0x8000 != 0 {
+		sign = -1.0
+	}
+	exp := int(h>>10) & 0x1f
+	frac := float64(h & 0x3ff)
+	switch exp {
+	case 0:
+		return sign * math.Ldexp(frac, -24)
+	case 0x1f:
+		if frac == 0 {
+			return math.Inf(int(sign))
+		}
+		return math.NaN()
+	}
+	return sign * math.Ldexp(frac+1024, exp-25)
+}
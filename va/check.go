@@ -0,0 +1,134 @@
+package va
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/logging"
+)
+
+// The ports Pebble's VA validates HTTP-01 and TLS-ALPN-01 challenges on by
+// default, which the checks use unless CheckOptions sets others.
+const (
+	DefaultHTTPPort = 5002
+	DefaultTLSPort  = 5001
+)
+
+// CheckOptions configure the pre-flight checks of CheckHTTP01, CheckTLSALPN01
+// and CheckDNS01. The fields mirror the VA's Config, and the zero value checks
+// challenges as a VA with Pebble's default configuration validates them.
+type CheckOptions struct {
+	// Context bounds the check. context.Background() is used if it is nil.
+	Context context.Context
+	// HTTPPort and TLSPort are the ports connected to for HTTP-01 and
+	// TLS-ALPN-01 checks, defaulting to DefaultHTTPPort and DefaultTLSPort.
+	// ACME CAs other than Pebble use 80 and 443.
+	HTTPPort int
+	TLSPort  int
+	// LenientHTTP01 and LenientDNS01 accept the same variations of key
+	// authorizations as the VA's Config options of the same names.
+	LenientHTTP01 bool
+	LenientDNS01  bool
+	// DNS configures the TXT record lookups of DNS-01 checks.
+	DNS DNSConfig
+	// ResolverOverrides maps host names, or patterns matching them, to the
+	// IP addresses connected to instead of resolving the names.
+	ResolverOverrides map[string]string
+	// NetworkPolicy restricts the addresses connected to.
+	NetworkPolicy NetworkPolicy
+	// Log receives the log lines the VA would write. They are discarded if it
+	// is nil.
+	Log logging.Logger
+}
+
+// CheckHTTP01 checks that the identifier's HTTP server serves the key
+// authorization (the challenge token and account key thumbprint joined by a
+// period) as Pebble's VA requires to validate an HTTP-01 challenge. It
+// returns nil if the challenge would be validated, or the problem the
+// challenge would fail with.
+func CheckHTTP01(identifier, keyAuthorization string, opts CheckOptions) *acme.ProblemDetails {
+	return check(opts, func(va VAImpl, ctx context.Context) *core.ValidationRecord {
+		return va.checkHTTP01(ctx, identifier, keyAuthorization)
+	})
+}
+
+// CheckTLSALPN01 checks that the identifier's TLS server presents a
+// certificate for the key authorization as Pebble's VA requires to validate
+// a TLS-ALPN-01 challenge. It returns nil if the challenge would be
+// validated, or the problem the challenge would fail with.
+func CheckTLSALPN01(identifier, keyAuthorization string, opts CheckOptions) *acme.ProblemDetails {
+	return check(opts, func(va VAImpl, ctx context.Context) *core.ValidationRecord {
+		return va.checkTLSALPN01(ctx, identifier, keyAuthorization)
+	})
+}
+
+// CheckDNS01 checks that the TXT records of the identifier's _acme-challenge
+// name include the digest of the key authorization as Pebble's VA requires to
+// validate a DNS-01 challenge. It returns nil if the challenge would be
+// validated, or the problem the challenge would fail with.
+func CheckDNS01(identifier, keyAuthorization string, opts CheckOptions) *acme.ProblemDetails {
+	return check(opts, func(va VAImpl, ctx context.Context) *core.ValidationRecord {
+		return va.checkDNS01(ctx, identifier, keyAuthorization)
+	})
+}
+
+// check runs a check with a VA configured by the options. Invalid options
+// are returned as malformed problems.
+func check(
+	opts CheckOptions,
+	f func(va VAImpl, ctx context.Context) *core.ValidationRecord) *acme.ProblemDetails {
+	va, err := newCheckVA(opts)
+	if err != nil {
+		return acme.MalformedProblem(err.Error())
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return f(va, ctx).Error
+}
+
+// newCheckVA returns a VA for running checks. Unlike one returned by New it
+// has no task queue or workers.
+func newCheckVA(opts CheckOptions) (VAImpl, error) {
+	va := VAImpl{
+		log:           opts.Log,
+		clk:           clock.New(),
+		httpPort:      opts.HTTPPort,
+		tlsPort:       opts.TLSPort,
+		lenientHTTP01: opts.LenientHTTP01,
+		lenientDNS01:  opts.LenientDNS01,
+		resolver:      newResolver(opts.DNS, nil),
+		dnsConfig:     opts.DNS,
+	}
+	if va.log == nil {
+		va.log = log.New(ioutil.Discard, "", 0)
+	}
+	if va.httpPort == 0 {
+		va.httpPort = DefaultHTTPPort
+	}
+	if va.tlsPort == 0 {
+		va.tlsPort = DefaultTLSPort
+	}
+	if err := opts.DNS.check(); err != nil {
+		return va, err
+	}
+	if va.dnsConfig.Timeout <= 0 {
+		va.dnsConfig.Timeout = defaultDNSTimeout
+	}
+	if va.dnsConfig.Retries < 0 {
+		va.dnsConfig.Retries = 0
+	}
+	var err error
+	if va.hostOverrides, err = newHostOverrides(opts.ResolverOverrides); err != nil {
+		return va, err
+	}
+	if va.networkPolicy, err = newNetworkPolicy(opts.NetworkPolicy); err != nil {
+		return va, err
+	}
+	return va, nil
+}
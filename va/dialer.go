@@ -0,0 +1,56 @@
+package va
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+// A Dialer makes the network connections of validations: those to the
+// addresses of identifiers for HTTP-01 and TLS-ALPN-01 challenges and those
+// to the DNS server for dns-01 challenges. *net.Dialer is a Dialer. Tests can
+// provide one connecting to in-memory servers, e.g. with net.Pipe, to run
+// Pebble and an ACME client in a single process without sockets.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// checkedDialer applies the VA's dial timeout and network policy to a Dialer
+// that isn't a net.Dialer. The policy can only be checked before dialing, so
+// it applies to IP addresses but not to host names the Dialer resolves
+// itself.
+type checkedDialer struct {
+	Dialer
+	timeout time.Duration
+	policy  *networkPolicy
+}
+
+func (d checkedDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		if ip := net.ParseIP(host); ip != nil {
+			if err := d.policy.check(ip); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if d.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, d.timeout)
+		defer cancel()
+	}
+	return d.Dialer.DialContext(ctx, network, address)
+}
+
+// netDialer returns the Dialer of the VA's validation connections: the
+// configured Dialer or a net.Dialer, with the dial timeout and network
+// policy.
+func (va VAImpl) netDialer() Dialer {
+	if va.dialer != nil {
+		return checkedDialer{Dialer: va.dialer, timeout: va.timeouts.Dial, policy: va.networkPolicy}
+	}
+	dialer := &net.Dialer{Timeout: va.timeouts.Dial}
+	if va.networkPolicy != nil {
+		dialer.Control = va.networkPolicy.control
+	}
+	return dialer
+}
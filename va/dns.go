@@ -0,0 +1,226 @@
+package va
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// defaultDNSTimeout is how long a DNS lookup attempt may take by default.
+const defaultDNSTimeout = 5 * time.Second
+
+const (
+	// dnsHeaderSize is the size of the header of a DNS message.
+	dnsHeaderSize = 12
+	// dnsTypeOPT is the type of EDNS0 OPT pseudo-records (RFC 6891).
+	dnsTypeOPT = 41
+	// dnsFlagTC is the truncation flag in the second byte of a DNS message.
+	dnsFlagTC = 0x02
+	// minEDNS0BufferSize is the smallest UDP payload size EDNS0 allows.
+	minEDNS0BufferSize = 512
+	// maxUDPSize is the largest UDP payload.
+	maxUDPSize = 65535
+)
+
+// DNSConfig configures the DNS lookups the VA makes for dns-01 challenges.
+// The zero value uses the system's resolver configuration with a five second
+// timeout and no retries.
+type DNSConfig struct {
+	// Server is the address of the DNS server to query, e.g. "127.0.0.1:8053".
+	// If empty the system's resolver configuration is used.
+	Server string
+	// Timeout is how long each lookup attempt may take.
+	Timeout time.Duration
+	// Retries is the number of times a lookup that times out or fails
+	// temporarily is retried.
+	Retries int
+	// DisableTCPFallback stops lookups with truncated UDP responses from being
+	// retried over TCP.
+	DisableTCPFallback bool
+	// ForceTCP makes every lookup over TCP, without trying UDP first.
+	ForceTCP bool
+	// EDNS0BufferSize is the UDP payload size advertised in the EDNS0 OPT
+	// record of queries. UDP responses larger than it are treated as
+	// truncated. Zero leaves queries as the Go resolver makes them.
+	EDNS0BufferSize int
+}
+
+func (c DNSConfig) check() error {
+	if c.EDNS0BufferSize != 0 &&
+		(c.EDNS0BufferSize < minEDNS0BufferSize || c.EDNS0BufferSize > maxUDPSize) {
+		return fmt.Errorf("EDNS0 buffer size must be between %d and %d",
+			minEDNS0BufferSize, maxUDPSize)
+	}
+	if c.ForceTCP && c.DisableTCPFallback {
+		return fmt.Errorf("TCP can't be both forced and disabled")
+	}
+	return nil
+}
+
+// newResolver returns a resolver that behaves as configured, connecting to
+// the DNS server with the dialer or, if it is nil, a net.Dialer. Connections
+// that aren't net.PacketConns are framed as over TCP by the resolver.
+func newResolver(config DNSConfig, dialer Dialer) *net.Resolver {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if config.Server != "" {
+				address = config.Server
+			}
+			if config.DisableTCPFallback {
+				network = "udp"
+			}
+			if config.ForceTCP {
+				network = "tcp"
+			}
+			conn, err := dialer.DialContext(ctx, network, address)
+			if err != nil {
+				return nil, err
+			}
+			if udpConn, ok := conn.(*net.UDPConn); ok && config.EDNS0BufferSize > 0 {
+				return &ednsConn{UDPConn: udpConn, bufferSize: config.EDNS0BufferSize}, nil
+			}
+			return conn, nil
+		},
+	}
+}
+
+// ednsConn advertises a UDP payload size in the EDNS0 OPT record of the DNS
+// queries written to it, and truncates responses larger than that size or
+// the reader's buffer, setting their TC flag so that the resolver retries
+// them over TCP. It is still a net.PacketConn, so the resolver doesn't frame
+// messages as over TCP.
+type ednsConn struct {
+	*net.UDPConn
+	bufferSize int
+}
+
+func (c *ednsConn) Write(query []byte) (int, error) {
+	msg, err := setEDNS0BufferSize(query, uint16(c.bufferSize))
+	if err != nil {
+		return 0, err
+	}
+	if _, err := c.UDPConn.Write(msg); err != nil {
+		return 0, err
+	}
+	return len(query), nil
+}
+
+func (c *ednsConn) Read(p []byte) (int, error) {
+	buf := make([]byte, maxUDPSize)
+	n, err := c.UDPConn.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	limit := c.bufferSize
+	if len(p) < limit {
+		limit = len(p)
+	}
+	if n <= limit {
+		return copy(p, buf[:n]), nil
+	}
+	n = copy(p, buf[:limit])
+	if n > 2 {
+		p[2] |= dnsFlagTC
+	}
+	return n, nil
+}
+
+// setEDNS0BufferSize returns a copy of the DNS message with the UDP payload
+// size of its OPT record set to size, adding an OPT record if it has none.
+func setEDNS0BufferSize(msg []byte, size uint16) ([]byte, error) {
+	if len(msg) < dnsHeaderSize {
+		return nil, fmt.Errorf("DNS message is too short")
+	}
+	msg = append([]byte(nil), msg...)
+	offset := dnsHeaderSize
+	questions := int(binary.BigEndian.Uint16(msg[4:]))
+	for i := 0; i < questions; i++ {
+		var err error
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, err
+		}
+		offset += 4
+	}
+	records := int(binary.BigEndian.Uint16(msg[6:])) +
+		int(binary.BigEndian.Uint16(msg[8:])) +
+		int(binary.BigEndian.Uint16(msg[10:]))
+	for i := 0; i < records; i++ {
+		var err error
+		if offset, err = skipDNSName(msg, offset); err != nil {
+			return nil, err
+		}
+		if offset+10 > len(msg) {
+			return nil, fmt.Errorf("DNS record is truncated")
+		}
+		if binary.BigEndian.Uint16(msg[offset:]) == dnsTypeOPT {
+			// The class of OPT records is the UDP payload size
+			binary.BigEndian.PutUint16(msg[offset+2:], size)
+			return msg, nil
+		}
+		offset += 10 + int(binary.BigEndian.Uint16(msg[offset+8:]))
+	}
+
+	// An OPT record for the root name with no extended flags or options
+	opt := []byte{0, 0, dnsTypeOPT, 0, 0, 0, 0, 0, 0, 0, 0}
+	binary.BigEndian.PutUint16(opt[3:], size)
+	binary.BigEndian.PutUint16(msg[10:], binary.BigEndian.Uint16(msg[10:])+1)
+	return append(msg, opt...), nil
+}
+
+// skipDNSName returns the offset of the end of the DNS name at offset.
+func skipDNSName(msg []byte, offset int) (int, error) {
+	for offset < len(msg) {
+		length := int(msg[offset])
+		switch {
+		case length == 0:
+			return offset + 1, nil
+		case length&0xC0 == 0xC0:
+			// A compression pointer ends the name
+			return offset + 2, nil
+		}
+		offset += 1 + length
+	}
+	return 0, fmt.Errorf("DNS name is truncated")
+}
+
+// lookupTXT looks up the TXT records of name, retrying lookups that time out
+// or fail temporarily. Lookups that still time out or fail temporarily are
+// returned as dns problems.
+func (va VAImpl) lookupTXT(ctx context.Context, name string) ([]string, *acme.ProblemDetails) {
+	attempts := va.dnsConfig.Retries + 1
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var txts []string
+		lookupCtx, cancel := context.WithTimeout(ctx, va.dnsConfig.Timeout)
+		txts, err = va.resolver.LookupTXT(lookupCtx, name)
+		if err != nil && lookupCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+			va.timeoutCounter.Record("va", "dns")
+		}
+		cancel()
+		if err == nil {
+			return txts, nil
+		}
+
+		dnsErr, ok := err.(*net.DNSError)
+		if !ok || !(dnsErr.IsTimeout || dnsErr.IsTemporary) {
+			return nil, acme.UnauthorizedProblem(fmt.Sprintf(
+				"Error retrieving TXT records for DNS challenge: %s", name))
+		}
+		va.log.Printf("Attempt %d/%d to look up TXT %s failed: %s\n", attempt, attempts, name, err)
+	}
+
+	if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsTimeout {
+		return nil, acme.DNSProblem(fmt.Sprintf(
+			"DNS query for TXT %s timed out after %d attempt(s)", name, attempts))
+	}
+	return nil, acme.DNSProblem(fmt.Sprintf(
+		"DNS query for TXT %s failed after %d attempt(s): %s", name, attempts, err))
+}
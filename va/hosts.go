@@ -0,0 +1,80 @@
+package va
+
+import (
+	"context"
+	"fmt"
+	"net"
+
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// hostOverrides maps host names, or patterns (see the pattern package)
+// matching them, to the IP addresses the VA connects to for HTTP-01 and
+// TLS-ALPN-01 validations instead of resolving the names.
+type hostOverrides struct {
+	ips   map[string]net.IP
+	names []string
+}
+
+func newHostOverrides(overrides map[string]string) (*hostOverrides, error) {
+	o := &hostOverrides{ips: make(map[string]net.IP, len(overrides))}
+	for name, addr := range overrides {
+		if err := pattern.Check(name); err != nil {
+			return nil, fmt.Errorf("resolver override: %s", err)
+		}
+		ip := net.ParseIP(addr)
+		if ip == nil {
+			return nil, fmt.Errorf("resolver override for %q has invalid IP address %q", name, addr)
+		}
+		o.ips[name] = ip
+		o.names = append(o.names, name)
+	}
+	return o, nil
+}
+
+// lookup returns the IP address that overrides the host name, if there is
+// one. A name takes precedence over patterns, of which the longest matching
+// one is used.
+func (o *hostOverrides) lookup(host string) (net.IP, bool) {
+	if o == nil {
+		return nil, false
+	}
+	name, ok := pattern.Lookup(o.names, host)
+	if !ok {
+		return nil, false
+	}
+	return o.ips[name], true
+}
+
+// dialContext connects to the address, replacing its host with the IP
+// address that overrides it, if any, and its port with the port for the
+// address family if a port rule applies, unless the network policy blocks the
+// address. The configured PROXY protocol header, if any, is sent first.
+func (va VAImpl) dialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	conn, err := va.dialAddress(ctx, network, address)
+	// Timeouts of ctx are counted by the operation that set them.
+	if err != nil && ctx.Err() == nil {
+		va.timeoutCounter.Check(err, "va", "dial")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return va.sendProxyProtocolHeader(conn)
+}
+
+func (va VAImpl) dialAddress(ctx context.Context, network, address string) (net.Conn, error) {
+	dialer := va.netDialer()
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return dialer.DialContext(ctx, network, address)
+	}
+	var ips []net.IP
+	if ip, ok := va.hostOverrides.lookup(host); ok {
+		address = net.JoinHostPort(ip.String(), port)
+		ips = []net.IP{ip}
+	}
+	if p, ok := ctx.Value(challengePortsKey{}).(challengePorts); ok && p.host == host && p.port == port {
+		return p.dial(ctx, dialer, network, ips)
+	}
+	return dialer.DialContext(ctx, network, address)
+}
@@ -0,0 +1,60 @@
+package va
+
+import (
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// attemptParameter is the query parameter added to HTTP-01 requests by
+// HTTPCaching.VaryPerAttempt.
+const attemptParameter = "pebble-attempt"
+
+// HTTPCaching controls how HTTP-01 validation requests interact with caches
+// and connection reuse, to reproduce challenge responses served through a CDN
+// or caching proxy. The zero value opens a new connection for every request
+// and sends no cache directives.
+type HTTPCaching struct {
+	// ReuseConnections keeps connections to challenge servers open and
+	// reuses them for later validations, instead of closing each connection
+	// after its request.
+	ReuseConnections bool
+	// NoCache sends "Cache-Control: no-cache" and "Pragma: no-cache" so that
+	// caches revalidate the response with the origin server.
+	NoCache bool
+	// VaryPerAttempt adds a pebble-attempt query parameter with a random
+	// value to every request, so that no two requests share a cache entry.
+	// RFC 8555 defines the challenge URL without a query, so challenge
+	// servers that match the whole URL will not find the response.
+	VaryPerAttempt bool
+}
+
+// httpTransport returns the transport for an HTTP-01 request: the shared
+// transport if connections are reused, or a new transport that closes its
+// connection after the request.
+func (va VAImpl) httpTransport() *http.Transport {
+	if va.sharedTransport != nil {
+		return va.sharedTransport
+	}
+	return &http.Transport{
+		// We don't expect to make multiple requests to a client, so close
+		// connection immediately.
+		DisableKeepAlives: true,
+		DialContext:       va.dialContext,
+	}
+}
+
+// applyHTTPCaching adds the configured cache directives and attempt parameter
+// to an HTTP-01 request URL and its headers.
+func (va VAImpl) applyHTTPCaching(u *url.URL, header http.Header) {
+	if va.httpCaching.NoCache {
+		header.Set("Cache-Control", "no-cache")
+		header.Set("Pragma", "no-cache")
+	}
+	if va.httpCaching.VaryPerAttempt {
+		u.RawQuery = url.Values{
+			attemptParameter: {strconv.FormatUint(rand.Uint64(), 36)},
+		}.Encode()
+	}
+}
@@ -0,0 +1,109 @@
+package va
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// checkHTTP01Body returns an error describing how the body of an http-01
+// challenge response differs from the expected key authorization. Only
+// trailing whitespace is permitted after the key authorization unless lenient
+// is true, in which case leading whitespace and trailing spaces are ignored as
+// well.
+func checkHTTP01Body(body, expected string, lenient bool) error {
+	payload := strings.TrimRight(body, whitespaceCutset)
+	if lenient {
+		payload = strings.TrimSpace(body)
+	}
+	if payload == expected {
+		return nil
+	}
+
+	switch {
+	case payload == "":
+		return fmt.Errorf("the response body is empty")
+	case strings.TrimLeftFunc(payload, unicode.IsSpace) == expected:
+		return fmt.Errorf("the key authorization has leading whitespace")
+	case strings.TrimRightFunc(payload, unicode.IsSpace) == expected:
+		return fmt.Errorf("the key authorization is followed by whitespace other than %q", whitespaceCutset)
+	case strings.HasPrefix(payload, expected):
+		return fmt.Errorf("the key authorization is followed by %q", payload[len(expected):])
+	}
+
+	parts := strings.SplitN(payload, ".", 2)
+	expectedParts := strings.SplitN(expected, ".", 2)
+	switch {
+	case len(parts) != 2:
+		return fmt.Errorf("the body %q is not of the form <token>.<thumbprint>", payload)
+	case parts[0] != expectedParts[0]:
+		return fmt.Errorf("the token %q does not match the challenge token %q",
+			parts[0], expectedParts[0])
+	case parts[1] != expectedParts[1]:
+		return fmt.Errorf("the thumbprint %q does not match the account key thumbprint %q",
+			parts[1], expectedParts[1])
+	}
+	return fmt.Errorf("%q != %q", expected, payload)
+}
+
+// checkDNS01TXT returns an error describing how a dns-01 TXT record value
+// differs from the expected base64url encoded SHA-256 digest of the key
+// authorization. The value must be unpadded base64url unless lenient is true,
+// in which case padding, the standard base64 alphabet and surrounding
+// whitespace are accepted.
+func checkDNS01TXT(value, keyAuthorization string, lenient bool) error {
+	digest := sha256.Sum256([]byte(keyAuthorization))
+	expected := base64.RawURLEncoding.EncodeToString(digest[:])
+	if subtle.ConstantTimeCompare([]byte(value), []byte(expected)) == 1 {
+		return nil
+	}
+
+	if value == keyAuthorization {
+		return fmt.Errorf("the value is the key authorization rather than its SHA-256 digest")
+	}
+	trimmed := strings.TrimSpace(value)
+	if trimmed != value && trimmed == expected {
+		if lenient {
+			return nil
+		}
+		return fmt.Errorf("the value has surrounding whitespace")
+	}
+
+	if decoded, err := hex.DecodeString(trimmed); err == nil && bytes.Equal(decoded, digest[:]) {
+		return fmt.Errorf("the digest is hex encoded rather than base64url encoded")
+	}
+
+	encodings := []struct {
+		desc     string
+		encoding *base64.Encoding
+	}{
+		{"padded base64url", base64.URLEncoding},
+		{"padded standard base64", base64.StdEncoding},
+		{"unpadded standard base64", base64.RawStdEncoding},
+	}
+	for _, e := range encodings {
+		decoded, err := e.encoding.DecodeString(trimmed)
+		if err != nil || !bytes.Equal(decoded, digest[:]) {
+			continue
+		}
+		if lenient {
+			return nil
+		}
+		return fmt.Errorf("the digest is %s encoded rather than unpadded base64url encoded", e.desc)
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(trimmed)
+	switch {
+	case err != nil:
+		return fmt.Errorf("the value is not valid unpadded base64url")
+	case len(decoded) != sha256.Size:
+		return fmt.Errorf("the value decodes to %d bytes, expected a %d byte SHA-256 digest",
+			len(decoded), sha256.Size)
+	}
+	return fmt.Errorf("the digest does not match the key authorization")
+}
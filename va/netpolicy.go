@@ -0,0 +1,137 @@
+package va
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"syscall"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// NetworkPolicy restricts the addresses the VA connects to for HTTP-01 and
+// TLS-ALPN-01 validations. Networks are given as CIDRs, IP addresses or one
+// of the named networks "private", "loopback" and "link-local".
+type NetworkPolicy struct {
+	// Allow, if not empty, are the only networks connected to.
+	Allow []string
+	// Deny are networks never connected to, even if they are allowed.
+	Deny []string
+}
+
+// namedNetworks are the networks that can be referred to by name in a
+// NetworkPolicy.
+var namedNetworks = map[string][]string{
+	"private":    {"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "fc00::/7"},
+	"loopback":   {"127.0.0.0/8", "::1/128"},
+	"link-local": {"169.254.0.0/16", "fe80::/10"},
+}
+
+// networkPolicy is a parsed NetworkPolicy.
+type networkPolicy struct {
+	allow []*net.IPNet
+	deny  []*net.IPNet
+}
+
+func newNetworkPolicy(config NetworkPolicy) (*networkPolicy, error) {
+	if len(config.Allow) == 0 && len(config.Deny) == 0 {
+		return nil, nil
+	}
+	allow, err := parseNetworks(config.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := parseNetworks(config.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &networkPolicy{allow: allow, deny: deny}, nil
+}
+
+func parseNetworks(networks []string) ([]*net.IPNet, error) {
+	var parsed []*net.IPNet
+	for _, network := range networks {
+		network = strings.TrimSpace(network)
+		if named, ok := namedNetworks[network]; ok {
+			for _, cidr := range named {
+				_, ipNet, _ := net.ParseCIDR(cidr)
+				parsed = append(parsed, ipNet)
+			}
+			continue
+		}
+		if ip := net.ParseIP(network); ip != nil {
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			parsed = append(parsed, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(network)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network %q", network)
+		}
+		parsed = append(parsed, ipNet)
+	}
+	return parsed, nil
+}
+
+// blockedAddressError is the error of connections the network policy blocks.
+type blockedAddressError struct {
+	ip     net.IP
+	reason string
+}
+
+func (e *blockedAddressError) Error() string {
+	return fmt.Sprintf("connecting to %s is blocked by the VA's network policy: %s", e.ip, e.reason)
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) (*net.IPNet, bool) {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return network, true
+		}
+	}
+	return nil, false
+}
+
+// check returns a *blockedAddressError if the IP address may not be
+// connected to.
+func (p *networkPolicy) check(ip net.IP) error {
+	if p == nil {
+		return nil
+	}
+	if network, denied := containsIP(p.deny, ip); denied {
+		return &blockedAddressError{ip: ip, reason: fmt.Sprintf("it is in denied network %s", network)}
+	}
+	if _, allowed := containsIP(p.allow, ip); len(p.allow) > 0 && !allowed {
+		return &blockedAddressError{ip: ip, reason: "it isn't in an allowed network"}
+	}
+	return nil
+}
+
+// control is a net.Dialer Control function checking the address connected
+// to, after its host name was resolved.
+func (p *networkPolicy) control(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("unexpected address %q", address)
+	}
+	return p.check(ip)
+}
+
+// blockedProblem returns an unauthorized problem describing the blocked
+// connection if err is caused by the network policy, or nil otherwise.
+func blockedProblem(err error, challengeType string) *acme.ProblemDetails {
+	var blocked *blockedAddressError
+	if !errors.As(err, &blocked) {
+		return nil
+	}
+	return acme.UnauthorizedProblem(fmt.Sprintf(
+		"Validation of the %s challenge failed: %s", challengeType, blocked))
+}
@@ -0,0 +1,190 @@
+package va
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base32"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+const (
+	// onionAddressLength is the length of a version 3 onion address label.
+	onionAddressLength = 56
+	onionVersion       = 3
+	// minApplicantNonceBytes is the least entropy the applicantSigningNonce of
+	// an onion-csr-01 CSR must carry, 64 bits.
+	minApplicantNonceBytes = 8
+)
+
+var (
+	// oidCASigningNonce and oidApplicantSigningNonce are the CSR attributes of
+	// the CA/Browser Forum Baseline Requirements that an onion-csr-01 CSR
+	// carries.
+	oidCASigningNonce        = asn1.ObjectIdentifier{2, 23, 140, 41}
+	oidApplicantSigningNonce = asn1.ObjectIdentifier{2, 23, 140, 42}
+
+	onionEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+)
+
+// IsOnion returns true if the DNS name is under the .onion special-use
+// domain.
+func IsOnion(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".onion")
+}
+
+// OnionPublicKey returns the Ed25519 public key of the Tor hidden service a
+// name under .onion belongs to, taken from its version 3 onion address, the
+// label before "onion". Subdomains and wildcards of the address are allowed.
+// An error is returned if the address isn't a valid version 3 address.
+func OnionPublicKey(name string) (ed25519.PublicKey, error) {
+	if !IsOnion(name) {
+		return nil, fmt.Errorf("%q is not a .onion name", name)
+	}
+	labels := strings.Split(strings.ToLower(name), ".")
+	address := labels[len(labels)-2]
+	if len(address) != onionAddressLength {
+		return nil, fmt.Errorf("onion address %q is not a %d character version %d address",
+			address, onionAddressLength, onionVersion)
+	}
+	decoded, err := onionEncoding.DecodeString(address)
+	if err != nil {
+		return nil, fmt.Errorf("onion address %q is not base32 encoded", address)
+	}
+	// A version 3 address is the public key, a two byte checksum and the
+	// version byte
+	pubKey := decoded[:ed25519.PublicKeySize]
+	checksum := decoded[ed25519.PublicKeySize : ed25519.PublicKeySize+2]
+	version := decoded[ed25519.PublicKeySize+2]
+	if version != onionVersion {
+		return nil, fmt.Errorf("onion address %q has version %d, only version %d is supported",
+			address, version, onionVersion)
+	}
+	digest := sha3Sum256(append(append([]byte(".onion checksum"), pubKey...), version))
+	if !bytes.Equal(checksum, digest[:2]) {
+		return nil, fmt.Errorf("onion address %q has a bad checksum", address)
+	}
+	return ed25519.PublicKey(pubKey), nil
+}
+
+// csrAttribute is an attribute of a PKCS #10 CSR.
+type csrAttribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+// csrAttributes returns the values of the CSR's attributes, keyed by the
+// string form of their OID. crypto/x509 only parses attributes holding
+// extension requests.
+func csrAttributes(csr *x509.CertificateRequest) (map[string][]asn1.RawValue, error) {
+	var tbs struct {
+		Version       int
+		Subject       asn1.RawValue
+		PublicKey     asn1.RawValue
+		RawAttributes []asn1.RawValue `asn1:"tag:0"`
+	}
+	if _, err := asn1.Unmarshal(csr.RawTBSCertificateRequest, &tbs); err != nil {
+		return nil, err
+	}
+	attributes := make(map[string][]asn1.RawValue)
+	for _, raw := range tbs.RawAttributes {
+		var attr csrAttribute
+		if rest, err := asn1.Unmarshal(raw.FullBytes, &attr); err != nil || len(rest) != 0 {
+			return nil, errors.New("malformed attribute")
+		}
+		attributes[attr.Type.String()] = append(attributes[attr.Type.String()], attr.Values...)
+	}
+	return attributes, nil
+}
+
+// csrNonce returns the octet string value of a nonce attribute of the CSR.
+func csrNonce(attributes map[string][]asn1.RawValue, oid asn1.ObjectIdentifier) ([]byte, error) {
+	values := attributes[oid.String()]
+	if len(values) != 1 {
+		return nil, fmt.Errorf("CSR must have a single %s attribute value", oid)
+	}
+	var nonce []byte
+	if rest, err := asn1.Unmarshal(values[0].FullBytes, &nonce); err != nil || len(rest) != 0 {
+		return nil, fmt.Errorf("%s attribute is not an octet string", oid)
+	}
+	return nonce, nil
+}
+
+// checkOnionCSR returns an error if the CSR doesn't prove control of the
+// hidden service with the given public key for a challenge with the nonce:
+// it must be signed by the hidden service key and carry the challenge nonce
+// as its caSigningNonce and at least 64 bits as its applicantSigningNonce.
+// The caSigningNonce may hold either the decoded nonce or its base64url
+// encoding.
+func checkOnionCSR(der []byte, pubKey ed25519.PublicKey, nonce string) error {
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return fmt.Errorf("CSR is invalid: %s", err)
+	}
+	csrKey, ok := csr.PublicKey.(ed25519.PublicKey)
+	if !ok || !bytes.Equal(csrKey, pubKey) {
+		return errors.New("CSR public key is not the hidden service key of the onion address")
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return fmt.Errorf("CSR signature is invalid: %s", err)
+	}
+	attributes, err := csrAttributes(csr)
+	if err != nil {
+		return fmt.Errorf("CSR attributes are invalid: %s", err)
+	}
+	caNonce, err := csrNonce(attributes, oidCASigningNonce)
+	if err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(nonce)
+	if !bytes.Equal(caNonce, []byte(nonce)) && (err != nil || !bytes.Equal(caNonce, decoded)) {
+		return errors.New("caSigningNonce attribute doesn't match the challenge nonce")
+	}
+	applicantNonce, err := csrNonce(attributes, oidApplicantSigningNonce)
+	if err != nil {
+		return err
+	}
+	if len(applicantNonce) < minApplicantNonceBytes {
+		return fmt.Errorf("applicantSigningNonce attribute must have at least %d bytes",
+			minApplicantNonceBytes)
+	}
+	return nil
+}
+
+// validateOnionCSR01 checks the CSR submitted for an onion-csr-01 challenge
+// (RFC 9799) against the onion address of the identifier.
+func (va VAImpl) validateOnionCSR01(task *vaTask) *core.ValidationRecord {
+	result := &core.ValidationRecord{
+		URL:         task.Identifier,
+		ValidatedAt: va.clk.Now(),
+	}
+
+	task.Challenge.RLock()
+	der := task.Challenge.OnionCSR
+	nonce := task.Challenge.Nonce
+	task.Challenge.RUnlock()
+	if len(der) == 0 {
+		result.Error = acme.UnauthorizedProblem(fmt.Sprintf(
+			"No CSR was submitted for the %s challenge", acme.ChallengeOnionCSR01))
+		return result
+	}
+
+	pubKey, err := OnionPublicKey(task.Identifier)
+	if err != nil {
+		result.Error = acme.MalformedProblem(err.Error())
+		return result
+	}
+	if err := checkOnionCSR(der, pubKey, nonce); err != nil {
+		result.Error = acme.UnauthorizedProblem(fmt.Sprintf(
+			"Invalid CSR for %s challenge: %s", acme.ChallengeOnionCSR01, err))
+		return result
+	}
+	return result
+}
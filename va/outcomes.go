@@ -0,0 +1,191 @@
+package va
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+const (
+	// OutcomeValid forces every validation of a matching identifier to succeed.
+	OutcomeValid = "valid"
+	// OutcomeInvalid forces every validation of a matching identifier to fail.
+	OutcomeInvalid = "invalid"
+	// OutcomeNthAttempt forces validations of a matching identifier to fail
+	// until the rule's Attempts-th attempt, which succeeds.
+	OutcomeNthAttempt = "nth-attempt"
+)
+
+// OutcomeRule maps an identifier to a forced validation outcome. When a rule
+// matches a challenge's identifier the VA doesn't make any validation requests
+// and instead applies the outcome directly.
+type OutcomeRule struct {
+	// Pattern is a pattern (see the pattern package) matched against the
+	// identifier value, e.g. "*.staging.example.com".
+	Pattern string `json:"pattern"`
+	// Outcome is one of OutcomeValid, OutcomeInvalid or OutcomeNthAttempt.
+	Outcome string `json:"outcome"`
+	// Error is the problem used for failed validations. If nil an unauthorized
+	// problem is used.
+	Error *acme.ProblemDetails `json:"error,omitempty"`
+	// Attempts is the attempt number that succeeds for OutcomeNthAttempt rules.
+	Attempts int `json:"attempts,omitempty"`
+}
+
+func (r OutcomeRule) check() error {
+	if r.Pattern == "" {
+		return fmt.Errorf("validation outcome rule has an empty pattern")
+	}
+	if err := pattern.Check(r.Pattern); err != nil {
+		return fmt.Errorf("validation outcome rule: %s", err)
+	}
+	switch r.Outcome {
+	case OutcomeValid, OutcomeInvalid:
+	case OutcomeNthAttempt:
+		if r.Attempts < 1 {
+			return fmt.Errorf("validation outcome rule for %q must have attempts >= 1", r.Pattern)
+		}
+	default:
+		return fmt.Errorf("validation outcome rule for %q has unknown outcome %q", r.Pattern, r.Outcome)
+	}
+	return nil
+}
+
+func (r OutcomeRule) matches(identifier string) bool {
+	return pattern.Match(r.Pattern, identifier)
+}
+
+func (r OutcomeRule) problem(identifier string) *acme.ProblemDetails {
+	if r.Error != nil {
+		prob := *r.Error
+		return &prob
+	}
+	return acme.UnauthorizedProblem(fmt.Sprintf(
+		"Validation of %q forced to fail by validation outcome rule %q", identifier, r.Pattern))
+}
+
+// outcomeTable holds the configured outcome rules along with the number of
+// validation attempts seen for each identifier matched by an
+// OutcomeNthAttempt rule, and the outcomes forced for the challenges of
+// specific accounts, keyed by account ID or by a pattern matching account IDs.
+type outcomeTable struct {
+	sync.Mutex
+	rules    []OutcomeRule
+	attempts map[string]int
+	accounts map[string]string
+	acctIDs  []string
+}
+
+func newOutcomeTable() *outcomeTable {
+	return &outcomeTable{
+		attempts: make(map[string]int),
+		accounts: make(map[string]string),
+	}
+}
+
+// set replaces all of the rules in the table and resets attempt counts.
+func (t *outcomeTable) set(rules []OutcomeRule) error {
+	for _, r := range rules {
+		if err := r.check(); err != nil {
+			return err
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.rules = append([]OutcomeRule(nil), rules...)
+	t.attempts = make(map[string]int)
+	return nil
+}
+
+func (t *outcomeTable) get() []OutcomeRule {
+	t.Lock()
+	defer t.Unlock()
+	return append([]OutcomeRule(nil), t.rules...)
+}
+
+// setAccounts replaces the outcomes forced for the challenges of accounts,
+// keyed by account ID.
+func (t *outcomeTable) setAccounts(outcomes map[string]string) error {
+	for acctID, outcome := range outcomes {
+		if outcome != OutcomeValid && outcome != OutcomeInvalid {
+			return fmt.Errorf("account %q has unknown validation outcome %q", acctID, outcome)
+		}
+		if err := pattern.Check(acctID); err != nil {
+			return fmt.Errorf("account validation outcome: %s", err)
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.accounts = make(map[string]string, len(outcomes))
+	t.acctIDs = make([]string, 0, len(outcomes))
+	for acctID, outcome := range outcomes {
+		t.accounts[acctID] = outcome
+		t.acctIDs = append(t.acctIDs, acctID)
+	}
+	return nil
+}
+
+// forcedOutcome returns whether the account's outcome or a rule matched the
+// identifier and, if so, the problem to fail the validation with. A nil
+// problem with a true result means the validation is forced to succeed. An
+// account's outcome, or failing that the given outcome, takes precedence over
+// the rules, and the first matching rule wins.
+func (t *outcomeTable) forcedOutcome(acctID, outcome, identifier string) (*acme.ProblemDetails, bool) {
+	t.Lock()
+	defer t.Unlock()
+
+	if key, ok := pattern.Lookup(t.acctIDs, acctID); ok && acctID != "" {
+		outcome = t.accounts[key]
+	}
+	switch outcome {
+	case OutcomeValid:
+		return nil, true
+	case OutcomeInvalid:
+		return acme.UnauthorizedProblem(fmt.Sprintf(
+			"Validation of %q forced to fail by the override of account %q", identifier, acctID)), true
+	}
+
+	for _, r := range t.rules {
+		if !r.matches(identifier) {
+			continue
+		}
+		switch r.Outcome {
+		case OutcomeValid:
+			return nil, true
+		case OutcomeInvalid:
+			return r.problem(identifier), true
+		case OutcomeNthAttempt:
+			key := strings.ToLower(identifier)
+			t.attempts[key]++
+			if t.attempts[key] >= r.Attempts {
+				return nil, true
+			}
+			return r.problem(identifier), true
+		}
+	}
+	return nil, false
+}
+
+// ValidationOutcomes returns the currently configured validation outcome
+// rules.
+func (va VAImpl) ValidationOutcomes() []OutcomeRule {
+	return va.outcomes.get()
+}
+
+// SetValidationOutcomes replaces the validation outcome rules. Attempt counts
+// for OutcomeNthAttempt rules are reset.
+func (va VAImpl) SetValidationOutcomes(rules []OutcomeRule) error {
+	return va.outcomes.set(rules)
+}
+
+// SetAccountOutcomes replaces the outcomes, OutcomeValid or OutcomeInvalid,
+// forced for every validation of the challenges of the accounts with the
+// given IDs.
+func (va VAImpl) SetAccountOutcomes(outcomes map[string]string) error {
+	return va.outcomes.setAccounts(outcomes)
+}
@@ -0,0 +1,54 @@
+package va
+
+import "sync/atomic"
+
+// defaultValidationQueueSize is how many validations can wait for a worker
+// when Config.MaxConcurrentValidations is set and Config.ValidationQueueSize
+// isn't.
+const defaultValidationQueueSize = 1000
+
+// QueueStats describes the VA's validation queue.
+type QueueStats struct {
+	// Queued is the number of validations waiting for a worker.
+	Queued int
+	// Active is the number of validations in progress.
+	Active int
+	// Workers is the maximum number of concurrent validations, or zero if
+	// there is no maximum.
+	Workers int
+}
+
+// startWorkers starts the configured number of workers validating tasks one
+// at a time, or a goroutine starting a validation for each task as soon as
+// it is submitted if there is no maximum.
+func (va VAImpl) startWorkers() {
+	if va.workers <= 0 {
+		go va.processTasks()
+		return
+	}
+	for i := 0; i < va.workers; i++ {
+		go func() {
+			for task := range va.tasks {
+				va.processCounted(task)
+			}
+		}()
+	}
+}
+
+// processCounted processes a task, counting it as active meanwhile and as
+// no longer in flight once it is done.
+func (va VAImpl) processCounted(task *vaTask) {
+	defer va.inflight.Done()
+	atomic.AddInt64(va.active, 1)
+	defer atomic.AddInt64(va.active, -1)
+	va.process(task)
+}
+
+// QueueStats returns the current state of the validation queue.
+func (va VAImpl) QueueStats() QueueStats {
+	return QueueStats{
+		Queued:  len(va.tasks),
+		Active:  int(atomic.LoadInt64(va.active)),
+		Workers: va.workers,
+	}
+}
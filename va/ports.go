@@ -0,0 +1,124 @@
+package va
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// FamilyPorts are the ports to connect to on IPv4 and IPv6 addresses. Zero
+// uses the VA's HTTP-01 or TLS-ALPN-01 port.
+type FamilyPorts struct {
+	IPv4 int `json:"ipv4,omitempty"`
+	IPv6 int `json:"ipv6,omitempty"`
+}
+
+func (p FamilyPorts) check() error {
+	if p.IPv4 < 0 || p.IPv4 > 65535 || p.IPv6 < 0 || p.IPv6 > 65535 {
+		return fmt.Errorf("ports must be between 0 and 65535")
+	}
+	return nil
+}
+
+// port returns the port to connect to on the IP address, or defaultPort.
+func (p FamilyPorts) port(ip net.IP, defaultPort string) string {
+	if ip.To4() != nil && p.IPv4 != 0 {
+		return strconv.Itoa(p.IPv4)
+	}
+	if ip.To4() == nil && p.IPv6 != 0 {
+		return strconv.Itoa(p.IPv6)
+	}
+	return defaultPort
+}
+
+// A PortRule sets the ports HTTP-01 and TLS-ALPN-01 validations of matching
+// identifiers connect to, per address family, for test environments that
+// can't bind the same port on IPv4 and IPv6. The first matching rule applies.
+type PortRule struct {
+	// Pattern is a pattern (see the pattern package) matched against the
+	// identifier value. Empty matches every identifier.
+	Pattern string      `json:"pattern,omitempty"`
+	HTTP    FamilyPorts `json:"http,omitempty"`
+	TLS     FamilyPorts `json:"tls,omitempty"`
+}
+
+func (r PortRule) check() error {
+	if err := pattern.Check(r.Pattern); err != nil {
+		return fmt.Errorf("challenge port rule: %s", err)
+	}
+	if err := r.HTTP.check(); err != nil {
+		return fmt.Errorf("challenge port rule for %q: %s", r.Pattern, err)
+	}
+	if err := r.TLS.check(); err != nil {
+		return fmt.Errorf("challenge port rule for %q: %s", r.Pattern, err)
+	}
+	return nil
+}
+
+func (r PortRule) matches(identifier string) bool {
+	return r.Pattern == "" || pattern.Match(r.Pattern, identifier)
+}
+
+// challengePortsKey is the context key of the challengePorts of a
+// validation.
+type challengePortsKey struct{}
+
+// challengePorts are the ports a validation connects to on the addresses of
+// its identifier when it dials the identifier at the challenge type's port.
+type challengePorts struct {
+	host  string
+	port  string
+	ports FamilyPorts
+}
+
+// withChallengePorts returns a context carrying the ports of the first port
+// rule matching the identifier for the challenge type, if any.
+func (va VAImpl) withChallengePorts(ctx context.Context, identifier, chalType string) context.Context {
+	for _, r := range va.portRules {
+		if !r.matches(identifier) {
+			continue
+		}
+		p := challengePorts{host: identifier, port: strconv.Itoa(va.httpPort), ports: r.HTTP}
+		if chalType == acme.ChallengeTLSALPN01 {
+			p.port, p.ports = strconv.Itoa(va.tlsPort), r.TLS
+		}
+		if p.ports == (FamilyPorts{}) {
+			return ctx
+		}
+		return context.WithValue(ctx, challengePortsKey{}, p)
+	}
+	return ctx
+}
+
+// dial connects to the first of the IP addresses, or of the host's addresses
+// if there are none, that accepts a connection, on the port for its address
+// family.
+func (p challengePorts) dial(ctx context.Context, dialer Dialer, network string, ips []net.IP) (net.Conn, error) {
+	if len(ips) == 0 {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, p.host)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ips = append(ips, addr.IP)
+		}
+	}
+	var firstErr error
+	for _, ip := range ips {
+		conn, err := dialer.DialContext(ctx, network, net.JoinHostPort(ip.String(), p.ports.port(ip, p.port)))
+		if err == nil {
+			return conn, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr == nil {
+		firstErr = fmt.Errorf("no addresses found for %s", p.host)
+	}
+	return nil, firstErr
+}
@@ -0,0 +1,77 @@
+package va
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+)
+
+// proxyProtocolV2Signature starts every PROXY protocol version 2 header.
+var proxyProtocolV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// checkProxyProtocol returns an error if version isn't a PROXY protocol
+// version, or 0 for none.
+func checkProxyProtocol(version int) error {
+	if version < 0 || version > 2 {
+		return fmt.Errorf("PROXY protocol version %d must be 1, 2 or 0 for none", version)
+	}
+	return nil
+}
+
+// proxyProtocolHeader returns the PROXY protocol header of the given version
+// describing a TCP connection from src to dst, as a load balancer would send
+// it ahead of the connection's data. Connections that aren't over TCP are
+// described as unknown (version 1) or local (version 2).
+func proxyProtocolHeader(version int, src, dst net.Addr) []byte {
+	srcTCP, srcOK := src.(*net.TCPAddr)
+	dstTCP, dstOK := dst.(*net.TCPAddr)
+	tcp4 := srcOK && dstOK && srcTCP.IP.To4() != nil && dstTCP.IP.To4() != nil
+	tcp6 := srcOK && dstOK && !tcp4 && srcTCP.IP.To16() != nil && dstTCP.IP.To16() != nil
+
+	if version == 1 {
+		switch {
+		case tcp4:
+			return []byte(fmt.Sprintf("PROXY TCP4 %s %s %d %d\r\n",
+				srcTCP.IP.To4(), dstTCP.IP.To4(), srcTCP.Port, dstTCP.Port))
+		case tcp6:
+			return []byte(fmt.Sprintf("PROXY TCP6 %s %s %d %d\r\n",
+				srcTCP.IP.To16(), dstTCP.IP.To16(), srcTCP.Port, dstTCP.Port))
+		}
+		return []byte("PROXY UNKNOWN\r\n")
+	}
+
+	header := append([]byte{}, proxyProtocolV2Signature...)
+	var addrs []byte
+	switch {
+	case tcp4:
+		// Version 2, PROXY command, TCP over IPv4
+		header = append(header, 0x21, 0x11)
+		addrs = append(append(addrs, srcTCP.IP.To4()...), dstTCP.IP.To4()...)
+	case tcp6:
+		// Version 2, PROXY command, TCP over IPv6
+		header = append(header, 0x21, 0x21)
+		addrs = append(append(addrs, srcTCP.IP.To16()...), dstTCP.IP.To16()...)
+	default:
+		// Version 2, LOCAL command, unspecified protocol
+		return append(header, 0x20, 0x00, 0x00, 0x00)
+	}
+	addrs = append(addrs, byte(srcTCP.Port>>8), byte(srcTCP.Port), byte(dstTCP.Port>>8), byte(dstTCP.Port))
+	var length [2]byte
+	binary.BigEndian.PutUint16(length[:], uint16(len(addrs)))
+	return append(append(header, length[:]...), addrs...)
+}
+
+// sendProxyProtocolHeader writes the configured PROXY protocol header, if
+// any, to a new connection to a challenge server, closing the connection if
+// that fails. The VA's end of the connection is the source.
+func (va VAImpl) sendProxyProtocolHeader(conn net.Conn) (net.Conn, error) {
+	if va.proxyProtocol == 0 {
+		return conn, nil
+	}
+	header := proxyProtocolHeader(va.proxyProtocol, conn.LocalAddr(), conn.RemoteAddr())
+	if _, err := conn.Write(header); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,143 @@
+package va
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+const (
+	defaultInitialBackoff  = time.Second
+	defaultMaxBackoff      = 30 * time.Second
+	defaultBackoffFactor   = 2.0
+	defaultBreakerCooldown = time.Minute
+)
+
+// transientProblemTypes are the problem types of validation failures that are
+// retried and counted by circuit breakers.
+var transientProblemTypes = map[string]bool{
+	acme.ConnectionProblem("").Type: true,
+	acme.DNSProblem("").Type:        true,
+}
+
+// RetryConfig configures retries of validations that fail transiently, with
+// a connection or dns problem, and circuit breakers that stop validations
+// against hosts that keep failing transiently. The zero value makes one
+// validation attempt and has no circuit breakers.
+type RetryConfig struct {
+	// MaxAttempts is the number of validation attempts made for a challenge.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. Defaults to one
+	// second.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries. Defaults to 30 seconds.
+	MaxBackoff time.Duration
+	// BackoffFactor multiplies the delay after each retry. Defaults to 2.
+	BackoffFactor float64
+	// BreakerThreshold is the number of consecutive transient failures after
+	// which a host's circuit breaker opens. Zero disables circuit breakers.
+	BreakerThreshold int
+	// BreakerCooldown is how long an open circuit breaker fails validations
+	// without making any requests. Defaults to one minute.
+	BreakerCooldown time.Duration
+}
+
+func (c RetryConfig) withDefaults() RetryConfig {
+	if c.MaxAttempts < 1 {
+		c.MaxAttempts = 1
+	}
+	if c.InitialBackoff <= 0 {
+		c.InitialBackoff = defaultInitialBackoff
+	}
+	if c.MaxBackoff <= 0 {
+		c.MaxBackoff = defaultMaxBackoff
+	}
+	if c.BackoffFactor < 1 {
+		c.BackoffFactor = defaultBackoffFactor
+	}
+	if c.BreakerCooldown <= 0 {
+		c.BreakerCooldown = defaultBreakerCooldown
+	}
+	return c
+}
+
+// backoff returns the delay before the retry following the given attempt.
+func (c RetryConfig) backoff(attempt int) time.Duration {
+	delay := float64(c.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		delay *= c.BackoffFactor
+		if delay >= float64(c.MaxBackoff) {
+			return c.MaxBackoff
+		}
+	}
+	return time.Duration(delay)
+}
+
+// breakerState is the circuit breaker state of one host.
+type breakerState struct {
+	failures  int
+	openUntil time.Time
+}
+
+// circuitBreakers tracks consecutive transient validation failures per host.
+// A host's breaker opens after the threshold is reached and fails validations
+// until the cooldown has passed. The next validation is then attempted and
+// reopens the breaker if it fails transiently.
+type circuitBreakers struct {
+	sync.Mutex
+	clk       clock.Clock
+	threshold int
+	cooldown  time.Duration
+	hosts     map[string]*breakerState
+}
+
+func newCircuitBreakers(clk clock.Clock, config RetryConfig) *circuitBreakers {
+	return &circuitBreakers{
+		clk:       clk,
+		threshold: config.BreakerThreshold,
+		cooldown:  config.BreakerCooldown,
+		hosts:     make(map[string]*breakerState),
+	}
+}
+
+// check returns a problem if the host's circuit breaker is open.
+func (b *circuitBreakers) check(host string) *acme.ProblemDetails {
+	if b.threshold <= 0 {
+		return nil
+	}
+	b.Lock()
+	defer b.Unlock()
+	state, ok := b.hosts[host]
+	if !ok || !b.clk.Now().Before(state.openUntil) {
+		return nil
+	}
+	return acme.ConnectionProblem(fmt.Sprintf(
+		"Circuit breaker for %s is open until %s after %d consecutive failures",
+		host, state.openUntil.UTC().Format(time.RFC3339), state.failures))
+}
+
+// record updates the host's circuit breaker with the result of a validation.
+func (b *circuitBreakers) record(host string, prob *acme.ProblemDetails) {
+	if b.threshold <= 0 {
+		return
+	}
+	b.Lock()
+	defer b.Unlock()
+	if prob == nil || !transientProblemTypes[prob.Type] {
+		delete(b.hosts, host)
+		return
+	}
+	state, ok := b.hosts[host]
+	if !ok {
+		state = &breakerState{}
+		b.hosts[host] = state
+	}
+	state.failures++
+	if state.failures >= b.threshold {
+		state.openUntil = b.clk.Now().Add(b.cooldown)
+	}
+}
@@ -0,0 +1,84 @@
+package va
+
+import "encoding/binary"
+
+// keccakRoundConstants are the round constants of Keccak-f[1600].
+var keccakRoundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+// keccakRotations are the rotation offsets of the rho step, indexed by lane.
+var keccakRotations = [25]uint{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+func rotl64(x uint64, n uint) uint64 {
+	return x<<n | x>>(64-n)
+}
+
+// keccakF1600 applies the Keccak-f[1600] permutation to the state, whose lane
+// (x, y) is a[x+5*y].
+func keccakF1600(a *[25]uint64) {
+	var b [25]uint64
+	var c, d [5]uint64
+	for round := 0; round < 24; round++ {
+		for x := 0; x < 5; x++ {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := 0; x < 5; x++ {
+			d[x] = c[(x+4)%5] ^ rotl64(c[(x+1)%5], 1)
+		}
+		for i := range a {
+			a[i] ^= d[i%5]
+		}
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				b[y+5*((2*x+3*y)%5)] = rotl64(a[x+5*y], keccakRotations[x+5*y])
+			}
+		}
+		for x := 0; x < 5; x++ {
+			for y := 0; y < 5; y++ {
+				a[x+5*y] = b[x+5*y] ^ (^b[(x+1)%5+5*y] & b[(x+2)%5+5*y])
+			}
+		}
+		a[0] ^= keccakRoundConstants[round]
+	}
+}
+
+// sha3Sum256 returns the SHA3-256 digest of the data (FIPS 202), which the
+// checksum of onion addresses uses. The standard library of the Go versions
+// Pebble supports has no SHA-3.
+func sha3Sum256(data []byte) [32]byte {
+	const rate = 136
+	var state [25]uint64
+	absorb := func(block []byte) {
+		for i := 0; i < rate/8; i++ {
+			state[i] ^= binary.LittleEndian.Uint64(block[8*i:])
+		}
+		keccakF1600(&state)
+	}
+	for len(data) >= rate {
+		absorb(data[:rate])
+		data = data[rate:]
+	}
+	last := make([]byte, rate)
+	copy(last, data)
+	last[len(data)] ^= 0x06
+	last[rate-1] ^= 0x80
+	absorb(last)
+
+	var digest [32]byte
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(digest[8*i:], state[i])
+	}
+	return digest
+}
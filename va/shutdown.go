@@ -0,0 +1,42 @@
+package va
+
+import (
+	"context"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// Shutdown waits for the validations in progress or queued to finish until
+// ctx is done, then cancels those that are left. Cancelled validations stop
+// their outstanding requests and fail their challenges. Challenges submitted
+// after Shutdown fail immediately.
+func (va VAImpl) Shutdown(ctx context.Context) {
+	finished := make(chan struct{})
+	go func() {
+		va.inflight.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		va.log.Printf("All validations finished")
+	case <-ctx.Done():
+		va.log.Printf("Cancelling unfinished validations")
+	}
+	va.cancel()
+}
+
+// sleepContext sleeps for d, returning early with ctx's error if it is
+// cancelled first.
+func (va VAImpl) sleepContext(ctx context.Context, d time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-va.clk.After(d):
+		return nil
+	}
+}
+
+func cancelledProblem() *acme.ProblemDetails {
+	return acme.InternalErrorProblem("Validation was cancelled because Pebble is shutting down")
+}
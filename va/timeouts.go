@@ -0,0 +1,45 @@
+package va
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultValidationTimeout bounds TLS-ALPN-01 handshakes and HTTP-01
+// requests by default.
+const defaultValidationTimeout = 5 * time.Second
+
+// Timeouts bound the network operations of HTTP-01 and TLS-ALPN-01
+// validations. The timeouts of dns-01 lookups are set by DNSConfig.
+type Timeouts struct {
+	// Dial bounds connecting to an address of the identifier. Zero leaves
+	// connecting bounded only by TLS or Read.
+	Dial time.Duration
+	// TLS bounds connecting to the identifier and completing the TLS
+	// handshake of a TLS-ALPN-01 validation. Zero means five seconds.
+	TLS time.Duration
+	// Read bounds an HTTP-01 validation request, from connecting to reading
+	// the end of the response, including redirects. Zero means five seconds.
+	Read time.Duration
+}
+
+func (t Timeouts) check() error {
+	if t.Dial < 0 || t.TLS < 0 || t.Read < 0 {
+		return fmt.Errorf("timeouts must be >= 0")
+	}
+	return nil
+}
+
+func (t Timeouts) tls() time.Duration {
+	if t.TLS > 0 {
+		return t.TLS
+	}
+	return defaultValidationTimeout
+}
+
+func (t Timeouts) read() time.Duration {
+	if t.Read > 0 {
+		return t.Read
+	}
+	return defaultValidationTimeout
+}
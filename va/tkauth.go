@@ -0,0 +1,135 @@
+package va
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+// AuthorityTokenValidator validates the Authority Tokens (RFC 9447) submitted
+// for tkauth-01 challenges of one tkauth-type.
+type AuthorityTokenValidator interface {
+	// Validate returns an error if the token doesn't authorize the account to
+	// get a certificate for the identifier at the given time.
+	Validate(token, identifier string, acct *core.Account, now time.Time) error
+}
+
+// authorityTokenClaims are the claims of an Authority Token for a TNAuthList
+// identifier (RFC 9448).
+type authorityTokenClaims struct {
+	Expires int64 `json:"exp"`
+	ATC     struct {
+		TKType      string `json:"tktype"`
+		TKValue     string `json:"tkvalue"`
+		CA          bool   `json:"ca"`
+		Fingerprint string `json:"fingerprint"`
+	} `json:"atc"`
+}
+
+// ATCValidator validates "atc" Authority Tokens for TNAuthList identifiers.
+// The token's claims must match the identifier and the account. If
+// TrustedKeys is empty the token's signature isn't verified, otherwise it must
+// verify with one of the keys.
+type ATCValidator struct {
+	TrustedKeys []crypto.PublicKey
+}
+
+func (v ATCValidator) Validate(token, identifier string, acct *core.Account, now time.Time) error {
+	jws, err := jose.ParseSigned(token)
+	if err != nil {
+		return fmt.Errorf("token is not a JWT: %s", err)
+	}
+
+	var payload []byte
+	if len(v.TrustedKeys) == 0 {
+		payload = jws.UnsafePayloadWithoutVerification()
+	} else {
+		for _, key := range v.TrustedKeys {
+			if payload, err = jws.Verify(key); err == nil {
+				break
+			}
+		}
+		if payload == nil {
+			return fmt.Errorf("signature isn't from a trusted token authority")
+		}
+	}
+
+	var claims authorityTokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return fmt.Errorf("malformed payload: %s", err)
+	}
+
+	if claims.Expires != 0 && time.Unix(claims.Expires, 0).Before(now) {
+		return fmt.Errorf("token expired")
+	}
+	if claims.ATC.TKType != acme.IdentifierTNAuthList {
+		return fmt.Errorf("tktype is %q, expected %q", claims.ATC.TKType, acme.IdentifierTNAuthList)
+	}
+	if claims.ATC.TKValue != identifier {
+		return fmt.Errorf("tkvalue doesn't match the identifier")
+	}
+	if claims.ATC.CA {
+		return fmt.Errorf("Pebble doesn't issue CA certificates")
+	}
+	fingerprint, err := accountFingerprint(acct)
+	if err != nil {
+		return fmt.Errorf("unable to compute account fingerprint: %s", err)
+	}
+	if !strings.EqualFold(claims.ATC.Fingerprint, fingerprint) {
+		return fmt.Errorf("fingerprint %q doesn't match the account key fingerprint %q",
+			claims.ATC.Fingerprint, fingerprint)
+	}
+	return nil
+}
+
+// accountFingerprint returns the fingerprint an Authority Token must carry for
+// the given account: the SHA-256 JWK thumbprint of the account key as colon
+// separated hex pairs prefixed with "SHA256 ".
+func accountFingerprint(acct *core.Account) (string, error) {
+	thumbprint, err := acct.Key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+	hexPairs := make([]string, len(thumbprint))
+	for i, b := range thumbprint {
+		hexPairs[i] = fmt.Sprintf("%02X", b)
+	}
+	return "SHA256 " + strings.Join(hexPairs, ":"), nil
+}
+
+// validateTKAuth01 checks the Authority Token submitted for a tkauth-01
+// challenge with the validator for the challenge's tkauth-type.
+func (va VAImpl) validateTKAuth01(task *vaTask) *core.ValidationRecord {
+	task.Challenge.RLock()
+	token := task.Challenge.AuthorityToken
+	tkAuthType := task.Challenge.TKAuthType
+	tokenAuthority := task.Challenge.TokenAuthority
+	task.Challenge.RUnlock()
+
+	result := &core.ValidationRecord{
+		URL:         tokenAuthority,
+		ValidatedAt: va.clk.Now(),
+	}
+
+	validator, ok := va.tokenValidators[tkAuthType]
+	if !ok {
+		result.Error = acme.UnauthorizedProblem(fmt.Sprintf(
+			"No Authority Token validator for tkauth-type %q", tkAuthType))
+		return result
+	}
+
+	err := validator.Validate(token, task.Identifier, task.Account, va.clk.Now())
+	if err != nil {
+		result.Error = acme.UnauthorizedProblem(fmt.Sprintf(
+			"Invalid Authority Token for %s challenge: %s", acme.ChallengeTKAuth01, err))
+		return result
+	}
+	return result
+}
@@ -1,15 +1,14 @@
 package va
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/subtle"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/asn1"
-	"encoding/base64"
 	"fmt"
 	"io/ioutil"
-	"log"
 	"math/rand"
 	"net"
 	"net/http"
@@ -18,18 +17,24 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jmhodges/clock"
 	"github.com/letsencrypt/pebble/acme"
 	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/cryptomode"
+	"github.com/letsencrypt/pebble/events"
+	"github.com/letsencrypt/pebble/logging"
+	"github.com/letsencrypt/pebble/timeouts"
 )
 
 const (
 	whitespaceCutset = "\n\r\t"
 	userAgentBase    = "LetsEncrypt-Pebble-VA"
 
-	// How long do valid authorizations last before expiring?
+	// How long do valid authorizations last before expiring? Can be overridden
+	// with Config.ValidAuthzLifetime.
 	validAuthzExpire = time.Hour
 
 	// How many vaTasks can be in the channel before the WFE blocks on adding
@@ -88,31 +93,232 @@ type vaTask struct {
 	Identifier string
 	Challenge  *core.Challenge
 	Account    *core.Account
+	Outcome    string
+
+	// ctx is cancelled when the VA shuts down to abort the validation's
+	// outstanding requests.
+	ctx context.Context
+}
+
+// Config holds the optional VA behaviours that can be tuned from the Pebble
+// configuration file. The zero value is a VA with default behaviour.
+type Config struct {
+	// ValidAuthzLifetime is how long an authorization remains valid after its
+	// challenge is successfully validated. Zero means the default of one hour.
+	ValidAuthzLifetime time.Duration
+	// ValidationOutcomes forces the result of validating matching identifiers
+	// without making any validation requests.
+	ValidationOutcomes []OutcomeRule
+	// AttestationVerifier verifies device-attest-01 attestation objects. If
+	// nil a PermissiveAttestationVerifier is used.
+	AttestationVerifier AttestationVerifier
+	// AuthorityTokenValidators validate tkauth-01 Authority Tokens, keyed by
+	// tkauth-type. If there is no validator for "atc" an ATCValidator that
+	// doesn't verify token signatures is used.
+	AuthorityTokenValidators map[string]AuthorityTokenValidator
+	// LenientHTTP01 accepts http-01 response bodies with leading whitespace
+	// or trailing spaces around the key authorization.
+	LenientHTTP01 bool
+	// LenientDNS01 accepts dns-01 TXT values that are padded or use the
+	// standard base64 alphabet, or have surrounding whitespace.
+	LenientDNS01 bool
+	// Events receives the status transitions of validated challenges and
+	// their authorizations and orders. It may be nil.
+	Events *events.Broker
+	// Retry configures retries of transiently failing validations and per
+	// host circuit breakers.
+	Retry RetryConfig
+	// DNS configures the timeouts, retries, transport and EDNS0 buffer size
+	// of the TXT record lookups for dns-01 challenges.
+	DNS DNSConfig
+	// ResolverOverrides maps host names, or patterns matching them, to the
+	// IP addresses connected to for HTTP-01 and TLS-ALPN-01 validations
+	// instead of resolving the names with DNS.
+	ResolverOverrides map[string]string
+	// MaxConcurrentValidations caps the number of challenges validated at
+	// once. Further challenges wait in a queue of ValidationQueueSize, which
+	// defaults to 1000, and challenge requests block while it is full. Zero
+	// validates every challenge as soon as it is submitted.
+	MaxConcurrentValidations int
+	ValidationQueueSize      int
+	// NetworkPolicy restricts the addresses connected to for HTTP-01 and
+	// TLS-ALPN-01 validations.
+	NetworkPolicy NetworkPolicy
+	// HTTPCaching controls connection reuse and cache directives of HTTP-01
+	// validation requests.
+	HTTPCaching HTTPCaching
+	// PortRules set the ports HTTP-01 and TLS-ALPN-01 validations of matching
+	// identifiers connect to on IPv4 and IPv6 addresses.
+	PortRules []PortRule
+	// ProxyProtocol is the version of the PROXY protocol header, 1 or 2,
+	// sent at the start of every connection to an HTTP-01 or TLS-ALPN-01
+	// challenge server, as a load balancer using the PROXY protocol would.
+	// Zero sends none.
+	ProxyProtocol int
+	// Timeouts bound connecting to identifiers, TLS-ALPN-01 handshakes and
+	// HTTP-01 requests.
+	Timeouts Timeouts
+	// TimeoutCounter counts the validation operations that time out. It may
+	// be nil.
+	TimeoutCounter *timeouts.Counter
+	// Dialer makes the connections of validations, including those to the
+	// DNS server. Host names are passed to it unresolved, unless a port rule
+	// applies to them. If nil a net.Dialer is used.
+	Dialer Dialer
 }
 
 type VAImpl struct {
-	log         *log.Logger
-	clk         clock.Clock
-	httpPort    int
-	tlsPort     int
-	tasks       chan *vaTask
-	sleep       bool
-	sleepTime   int
-	alwaysValid bool
+	log                logging.Logger
+	clk                clock.Clock
+	httpPort           int
+	tlsPort            int
+	tasks              chan *vaTask
+	sleep              bool
+	sleepTime          int
+	alwaysValid        bool
+	validAuthzLifetime time.Duration
+	outcomes           *outcomeTable
+
+	attestationVerifier AttestationVerifier
+	tokenValidators     map[string]AuthorityTokenValidator
+	lenientHTTP01       bool
+	lenientDNS01        bool
+	resolver            *net.Resolver
+	dnsConfig           DNSConfig
+	hostOverrides       *hostOverrides
+	networkPolicy       *networkPolicy
+	httpCaching         HTTPCaching
+	portRules           []PortRule
+	proxyProtocol       int
+	timeouts            Timeouts
+	timeoutCounter      *timeouts.Counter
+	dialer              Dialer
+	events              *events.Broker
+	retry               RetryConfig
+	breakers            *circuitBreakers
+
+	// sharedTransport is used for every HTTP-01 request if
+	// HTTPCaching.ReuseConnections is set.
+	sharedTransport *http.Transport
+
+	// workers is Config.MaxConcurrentValidations and active counts the
+	// validations in progress. active is a pointer because the VA is used by
+	// value.
+	workers int
+	active  *int64
+
+	// ctx is the context of every validation, cancelled by Shutdown.
+	// inflight counts the validations that are queued or in progress.
+	ctx      context.Context
+	cancel   context.CancelFunc
+	inflight *sync.WaitGroup
 }
 
 func New(
-	log *log.Logger,
+	log logging.Logger,
 	clk clock.Clock,
-	httpPort, tlsPort int) *VAImpl {
+	httpPort, tlsPort int,
+	config Config) *VAImpl {
 	va := &VAImpl{
-		log:       log,
-		clk:       clk,
-		httpPort:  httpPort,
-		tlsPort:   tlsPort,
-		tasks:     make(chan *vaTask, taskQueueSize),
-		sleep:     true,
-		sleepTime: defaultSleepTime,
+		log:                log,
+		clk:                clk,
+		httpPort:           httpPort,
+		tlsPort:            tlsPort,
+		tasks:              make(chan *vaTask, taskQueueSize),
+		sleep:              true,
+		sleepTime:          defaultSleepTime,
+		validAuthzLifetime: validAuthzExpire,
+		outcomes:           newOutcomeTable(),
+
+		attestationVerifier: config.AttestationVerifier,
+		lenientHTTP01:       config.LenientHTTP01,
+		lenientDNS01:        config.LenientDNS01,
+		resolver:            newResolver(config.DNS, config.Dialer),
+		dnsConfig:           config.DNS,
+		httpCaching:         config.HTTPCaching,
+		portRules:           config.PortRules,
+		proxyProtocol:       config.ProxyProtocol,
+		timeouts:            config.Timeouts,
+		timeoutCounter:      config.TimeoutCounter,
+		dialer:              config.Dialer,
+		events:              config.Events,
+		retry:               config.Retry.withDefaults(),
+	}
+	va.breakers = newCircuitBreakers(clk, va.retry)
+	va.active = new(int64)
+	va.ctx, va.cancel = context.WithCancel(context.Background())
+	va.inflight = new(sync.WaitGroup)
+	if config.MaxConcurrentValidations > 0 {
+		queueSize := config.ValidationQueueSize
+		if queueSize <= 0 {
+			queueSize = defaultValidationQueueSize
+		}
+		va.workers = config.MaxConcurrentValidations
+		va.tasks = make(chan *vaTask, queueSize)
+		va.log.Printf("Limiting validations to %d at once with a queue of %d",
+			va.workers, queueSize)
+	}
+	if err := config.DNS.check(); err != nil {
+		panic(fmt.Sprintf("Invalid DNS config: %s", err.Error()))
+	}
+	overrides, err := newHostOverrides(config.ResolverOverrides)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid resolver overrides: %s", err.Error()))
+	}
+	va.hostOverrides = overrides
+	policy, err := newNetworkPolicy(config.NetworkPolicy)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid network policy: %s", err.Error()))
+	}
+	va.networkPolicy = policy
+	for _, r := range config.PortRules {
+		if err := r.check(); err != nil {
+			panic(fmt.Sprintf("Invalid challenge port rules: %s", err.Error()))
+		}
+	}
+	if err := config.Timeouts.check(); err != nil {
+		panic(fmt.Sprintf("Invalid VA timeouts: %s", err.Error()))
+	}
+	if err := checkProxyProtocol(config.ProxyProtocol); err != nil {
+		panic(fmt.Sprintf("Invalid VA config: %s", err.Error()))
+	}
+	if config.ProxyProtocol != 0 {
+		va.log.Printf("Sending PROXY protocol v%d headers to challenge servers", config.ProxyProtocol)
+	}
+	if config.HTTPCaching.ReuseConnections {
+		va.sharedTransport = &http.Transport{DialContext: va.dialContext}
+	}
+	if len(config.ResolverOverrides) > 0 {
+		va.log.Printf("Overriding the addresses of %d host names for validations",
+			len(config.ResolverOverrides))
+	}
+	if va.dnsConfig.Timeout <= 0 {
+		va.dnsConfig.Timeout = defaultDNSTimeout
+	}
+	if va.dnsConfig.Retries < 0 {
+		va.dnsConfig.Retries = 0
+	}
+	if va.attestationVerifier == nil {
+		va.attestationVerifier = PermissiveAttestationVerifier{}
+	}
+	va.tokenValidators = make(map[string]AuthorityTokenValidator)
+	for tkAuthType, validator := range config.AuthorityTokenValidators {
+		va.tokenValidators[tkAuthType] = validator
+	}
+	if _, ok := va.tokenValidators[acme.TKAuthTypeATC]; !ok {
+		va.tokenValidators[acme.TKAuthTypeATC] = ATCValidator{}
+	}
+
+	if config.ValidAuthzLifetime > 0 {
+		va.validAuthzLifetime = config.ValidAuthzLifetime
+		va.log.Printf("Setting valid authorization lifetime to %s", va.validAuthzLifetime)
+	}
+
+	if err := va.outcomes.set(config.ValidationOutcomes); err != nil {
+		panic(fmt.Sprintf("Error configuring validation outcomes: %s", err.Error()))
+	}
+	if len(config.ValidationOutcomes) > 0 {
+		va.log.Printf("Configured %d validation outcome rules", len(config.ValidationOutcomes))
 	}
 
 	// Read the PEBBLE_VA_NOSLEEP environment variable string
@@ -138,23 +344,29 @@ func New(
 		va.log.Printf("Disabling VA challenge requests. VA always returns valid")
 	}
 
-	go va.processTasks()
+	va.startWorkers()
 	return va
 }
 
-func (va VAImpl) ValidateChallenge(ident string, chal *core.Challenge, acct *core.Account) {
+// ValidateChallenge queues the validation of the challenge. A non-empty
+// outcome (OutcomeValid or OutcomeInvalid) forces the validation's outcome
+// when the account has no outcome set with SetAccountOutcomes.
+func (va VAImpl) ValidateChallenge(ident string, chal *core.Challenge, acct *core.Account, outcome string) {
 	task := &vaTask{
 		Identifier: ident,
 		Challenge:  chal,
 		Account:    acct,
+		Outcome:    outcome,
+		ctx:        va.ctx,
 	}
 	// Submit the task for validation
+	va.inflight.Add(1)
 	va.tasks <- task
 }
 
 func (va VAImpl) processTasks() {
 	for task := range va.tasks {
-		go va.process(task)
+		go va.processCounted(task)
 	}
 }
 
@@ -170,14 +382,15 @@ func (va VAImpl) firstError(results chan *core.ValidationRecord) *acme.ProblemDe
 
 // setAuthzValid updates an authorization and an associated challenge to be
 // status valid. The authorization expiry is updated to now plus the configured
-// `validAuthzExpire` duration.
+// valid authorization lifetime.
 func (va VAImpl) setAuthzValid(authz *core.Authorization, chal *core.Challenge) {
 	authz.Lock()
 	defer authz.Unlock()
 	// Update the authz expiry for the new validity period
 	now := va.clk.Now().UTC()
-	authz.ExpiresDate = now.Add(validAuthzExpire)
+	authz.ExpiresDate = now.Add(va.validAuthzLifetime)
 	authz.Expires = authz.ExpiresDate.Format(time.RFC3339)
+	authz.ValidatedDate = now
 	// Update the authz status
 	authz.Status = acme.StatusValid
 
@@ -185,6 +398,7 @@ func (va VAImpl) setAuthzValid(authz *core.Authorization, chal *core.Challenge)
 	defer chal.Unlock()
 	// Update the challenge status
 	chal.Status = acme.StatusValid
+	chal.ValidationCompleted = now.Format(time.RFC3339Nano)
 }
 
 // setOrderError updates an order with an error from an authorization
@@ -214,6 +428,7 @@ func (va VAImpl) setAuthzInvalid(
 	chal.Error = err
 	// Update the challenge status
 	chal.Status = acme.StatusInvalid
+	chal.ValidationCompleted = va.clk.Now().UTC().Format(time.RFC3339Nano)
 }
 
 func (va VAImpl) process(task *vaTask) {
@@ -226,29 +441,124 @@ func (va VAImpl) process(task *vaTask) {
 	now := va.clk.Now().UTC()
 	chal.ValidatedDate = now
 	chal.Validated = chal.ValidatedDate.Format(time.RFC3339)
+	chal.ValidationStarted = now.Format(time.RFC3339Nano)
+	chal.ValidationCompleted = ""
 	authz := chal.Authz
 	chal.Unlock()
 
-	results := make(chan *core.ValidationRecord, concurrentValidations)
+	// If the account has a forced outcome or a validation outcome rule matches
+	// the identifier then apply the outcome without performing any
+	// validations.
+	if prob, forced := va.outcomes.forcedOutcome(task.Account.ID, task.Outcome, task.Identifier); forced {
+		if prob != nil {
+			va.setAuthzInvalid(authz, chal, prob)
+			va.log.Printf("authz %s set INVALID by forced validation outcome for %s", authz.ID, task.Identifier)
+			va.setOrderError(authz.Order, prob)
+			va.log.Printf("order %s set INVALID by invalid authz %s", authz.Order.ID, authz.ID)
+			va.publishTransition(authz, chal)
+			return
+		}
+		va.setAuthzValid(authz, chal)
+		va.log.Printf("authz %s set VALID by forced validation outcome for %s", authz.ID, task.Identifier)
+		va.publishTransition(authz, chal)
+		return
+	}
 
-	// Start a number of go routines to perform concurrent validations
-	for i := 0; i < concurrentValidations; i++ {
-		go va.performValidation(task, results)
+	var err *acme.ProblemDetails
+	for attempt := 1; ; attempt++ {
+		started := va.clk.Now().UTC()
+		err = va.attemptValidation(task)
+		va.recordAttempt(chal, attempt, started, err)
+		if err == nil || !transientProblemTypes[err.Type] || attempt >= va.retry.MaxAttempts {
+			break
+		}
+
+		// Mark the challenge as processing while it waits to be retried
+		chal.Lock()
+		chal.Status = acme.StatusProcessing
+		chal.Unlock()
+		va.events.Publish(events.TypeChallenge, chal.ID, acme.StatusProcessing, task.Identifier)
+		delay := va.retry.backoff(attempt)
+		va.log.Printf("Validation attempt %d/%d of challenge %s failed transiently, retrying in %s",
+			attempt, va.retry.MaxAttempts, chal.ID, delay)
+		if va.sleepContext(task.ctx, delay) != nil {
+			break
+		}
+	}
+	if task.ctx.Err() != nil {
+		err = cancelledProblem()
 	}
 
-	err := va.firstError(results)
 	// If one of the results was an error, the challenge fails
 	if err != nil {
 		va.setAuthzInvalid(authz, chal, err)
 		va.log.Printf("authz %s set INVALID by completed challenge %s", authz.ID, chal.ID)
 		va.setOrderError(authz.Order, err)
 		va.log.Printf("order %s set INVALID by invalid authz %s", authz.Order.ID, authz.ID)
+		va.publishTransition(authz, chal)
 		return
 	}
 
 	// If there was no error, then the challenge succeeded and the authz is valid
 	va.setAuthzValid(authz, chal)
 	va.log.Printf("authz %s set VALID by completed challenge %s", authz.ID, chal.ID)
+	va.publishTransition(authz, chal)
+}
+
+// publishTransition publishes the status of a validated challenge, its
+// authorization and the authorization's order.
+func (va VAImpl) publishTransition(authz *core.Authorization, chal *core.Challenge) {
+	chal.RLock()
+	va.events.Publish(events.TypeChallenge, chal.ID, chal.Status, authz.Identifier.Value)
+	chal.RUnlock()
+	authz.RLock()
+	va.events.Publish(events.TypeAuthorization, authz.ID, authz.Status, authz.Identifier.Value)
+	order := authz.Order
+	authz.RUnlock()
+	if order == nil {
+		return
+	}
+	if status, err := order.GetStatus(va.clk); err == nil {
+		va.events.Publish(events.TypeOrder, order.ID, status, "")
+	}
+}
+
+// attemptValidation makes one attempt to validate the task's challenge,
+// unless the circuit breaker of the identifier's host is open.
+func (va VAImpl) attemptValidation(task *vaTask) *acme.ProblemDetails {
+	if prob := va.breakers.check(task.Identifier); prob != nil {
+		return prob
+	}
+
+	results := make(chan *core.ValidationRecord, concurrentValidations)
+
+	// Start a number of go routines to perform concurrent validations
+	for i := 0; i < concurrentValidations; i++ {
+		go va.performValidation(task, results)
+	}
+
+	err := va.firstError(results)
+	va.breakers.record(task.Identifier, err)
+	return err
+}
+
+// recordAttempt adds the result of a validation attempt to the challenge's
+// attempt history.
+func (va VAImpl) recordAttempt(
+	chal *core.Challenge,
+	attempt int,
+	started time.Time,
+	err *acme.ProblemDetails) {
+	completed := va.clk.Now().UTC()
+	chal.Lock()
+	defer chal.Unlock()
+	chal.Attempts = append(chal.Attempts, acme.ValidationAttempt{
+		Attempt:   attempt,
+		Time:      completed.Format(time.RFC3339),
+		Started:   started.Format(time.RFC3339Nano),
+		Completed: completed.Format(time.RFC3339Nano),
+		Error:     err,
+	})
 }
 
 func (va VAImpl) performValidation(task *vaTask, results chan<- *core.ValidationRecord) {
@@ -256,7 +566,14 @@ func (va VAImpl) performValidation(task *vaTask, results chan<- *core.Validation
 		// Sleep for a random amount of time between 0 and va.sleepTime seconds
 		len := time.Duration(rand.Intn(va.sleepTime))
 		va.log.Printf("Sleeping for %s seconds before validating", time.Second*len)
-		va.clk.Sleep(time.Second * len)
+		if va.sleepContext(task.ctx, time.Second*len) != nil {
+			results <- &core.ValidationRecord{
+				URL:         task.Identifier,
+				ValidatedAt: va.clk.Now(),
+				Error:       cancelledProblem(),
+			}
+			return
+		}
 	}
 
 	// If `alwaysValid` is true then return a validation record immediately
@@ -283,23 +600,41 @@ func (va VAImpl) performValidation(task *vaTask, results chan<- *core.Validation
 		results <- va.validateTLSALPN01(task)
 	case acme.ChallengeDNS01:
 		results <- va.validateDNS01(task)
+	case acme.ChallengeDeviceAttest01:
+		results <- va.validateDeviceAttest01(task)
+	case acme.ChallengeTKAuth01:
+		results <- va.validateTKAuth01(task)
+	case acme.ChallengeOnionCSR01:
+		results <- va.validateOnionCSR01(task)
 	default:
 		va.log.Printf("Error: performValidation(): Invalid challenge type: %q", task.Challenge.Type)
 	}
 }
 
 func (va VAImpl) validateDNS01(task *vaTask) *core.ValidationRecord {
+	task.Challenge.RLock()
+	expectedKeyAuthorization := task.Challenge.ExpectedKeyAuthorization(task.Account.Key)
+	task.Challenge.RUnlock()
+	return va.checkDNS01(task.ctx, task.Identifier, expectedKeyAuthorization)
+}
+
+// checkDNS01 checks that a TXT record of the identifier's _acme-challenge
+// name has the digest of the expected key authorization.
+func (va VAImpl) checkDNS01(
+	ctx context.Context,
+	identifier string,
+	expectedKeyAuthorization string) *core.ValidationRecord {
 	const dns01Prefix = "_acme-challenge"
-	challengeSubdomain := fmt.Sprintf("%s.%s", dns01Prefix, task.Identifier)
+	challengeSubdomain := fmt.Sprintf("%s.%s", dns01Prefix, identifier)
 
 	result := &core.ValidationRecord{
 		URL:         challengeSubdomain,
 		ValidatedAt: va.clk.Now(),
 	}
 
-	txts, err := net.LookupTXT(challengeSubdomain)
-	if err != nil {
-		result.Error = acme.UnauthorizedProblem("Error retrieving TXT records for DNS challenge")
+	txts, prob := va.lookupTXT(ctx, challengeSubdomain)
+	if prob != nil {
+		result.Error = prob
 		return result
 	}
 
@@ -309,37 +644,47 @@ func (va VAImpl) validateDNS01(task *vaTask) *core.ValidationRecord {
 		return result
 	}
 
-	task.Challenge.RLock()
-	expectedKeyAuthorization := task.Challenge.ExpectedKeyAuthorization(task.Account.Key)
-	h := sha256.Sum256([]byte(expectedKeyAuthorization))
-	task.Challenge.RUnlock()
-	authorizedKeysDigest := base64.RawURLEncoding.EncodeToString(h[:])
-
+	var mismatches []string
 	for _, element := range txts {
-		if subtle.ConstantTimeCompare([]byte(element), []byte(authorizedKeysDigest)) == 1 {
+		err := checkDNS01TXT(element, expectedKeyAuthorization, va.lenientDNS01)
+		if err == nil {
 			return result
 		}
+		mismatches = append(mismatches, fmt.Sprintf("%q: %s", element, err))
 	}
 
-	msg := fmt.Sprintf("Correct value not found for DNS challenge")
+	msg := fmt.Sprintf("Correct value not found for DNS challenge: %s",
+		strings.Join(mismatches, "; "))
 	result.Error = acme.UnauthorizedProblem(msg)
 	return result
 }
 
 func (va VAImpl) validateTLSALPN01(task *vaTask) *core.ValidationRecord {
+	expectedKeyAuthorization := task.Challenge.ExpectedKeyAuthorization(task.Account.Key)
+	return va.checkTLSALPN01(task.ctx, task.Identifier, expectedKeyAuthorization)
+}
+
+// checkTLSALPN01 checks that the identifier's TLS server negotiates the
+// acme-tls/1 protocol and presents a certificate for the identifier with the
+// digest of the expected key authorization.
+func (va VAImpl) checkTLSALPN01(
+	ctx context.Context,
+	identifier string,
+	expectedKeyAuthorization string) *core.ValidationRecord {
 	portString := strconv.Itoa(va.tlsPort)
-	hostPort := net.JoinHostPort(task.Identifier, portString)
+	hostPort := net.JoinHostPort(identifier, portString)
+	ctx = va.withChallengePorts(ctx, identifier, acme.ChallengeTLSALPN01)
 
 	result := &core.ValidationRecord{
 		URL:         hostPort,
 		ValidatedAt: va.clk.Now(),
 	}
 
-	cs, problem := va.fetchConnectionState(hostPort, &tls.Config{
-		ServerName:         task.Identifier,
+	cs, problem := va.fetchConnectionState(ctx, hostPort, cryptomode.RestrictTLS(&tls.Config{
+		ServerName:         identifier,
 		NextProtos:         []string{acme.ACMETLS1Protocol},
 		InsecureSkipVerify: true,
-	})
+	}))
 	if problem != nil {
 		result.Error = problem
 		return result
@@ -362,19 +707,18 @@ func (va VAImpl) validateTLSALPN01(task *vaTask) *core.ValidationRecord {
 	leafCert := certs[0]
 
 	// Verify SNI - certificate returned must be issued only for the domain we are verifying.
-	if len(leafCert.DNSNames) != 1 || !strings.EqualFold(leafCert.DNSNames[0], task.Identifier) {
+	if len(leafCert.DNSNames) != 1 || !strings.EqualFold(leafCert.DNSNames[0], identifier) {
 		names := certNames(leafCert)
 		errText := fmt.Sprintf(
 			"Incorrect validation certificate for %s challenge. "+
 				"Requested %s from %s. Received %d certificate(s), "+
 				"first certificate had names %q",
-			acme.ChallengeTLSALPN01, task.Identifier, hostPort, len(certs), names)
+			acme.ChallengeTLSALPN01, identifier, hostPort, len(certs), names)
 		result.Error = acme.UnauthorizedProblem(errText)
 		return result
 	}
 
 	// Verify key authorization in acmeValidation extension
-	expectedKeyAuthorization := task.Challenge.ExpectedKeyAuthorization(task.Account.Key)
 	h := sha256.Sum256([]byte(expectedKeyAuthorization))
 	for _, ext := range leafCert.Extensions {
 		if IdPeAcmeIdentifierV1.Equal(ext.Id) && ext.Critical {
@@ -403,10 +747,21 @@ func (va VAImpl) validateTLSALPN01(task *vaTask) *core.ValidationRecord {
 	return result
 }
 
-func (va VAImpl) fetchConnectionState(hostPort string, config *tls.Config) (*tls.ConnectionState, *acme.ProblemDetails) {
-	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: time.Second * 5}, "tcp", hostPort, config)
+func (va VAImpl) fetchConnectionState(
+	ctx context.Context,
+	hostPort string,
+	config *tls.Config) (*tls.ConnectionState, *acme.ProblemDetails) {
+	ctx, cancel := context.WithTimeout(ctx, va.timeouts.tls())
+	defer cancel()
+	conn, err := va.tlsDialContext(ctx, hostPort, config)
 
 	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			va.timeoutCounter.Record("va", "tls")
+		}
+		if prob := blockedProblem(err, acme.ChallengeTLSALPN01); prob != nil {
+			return nil, prob
+		}
 		// TODO(@cpu): Return better err - see parseHTTPConnError from boulder
 		return nil, acme.UnauthorizedProblem(
 			fmt.Sprintf("Failed to connect to %s for the %s challenge", hostPort, acme.ChallengeTLSALPN01))
@@ -421,8 +776,45 @@ func (va VAImpl) fetchConnectionState(hostPort string, config *tls.Config) (*tls
 	return &cs, nil
 }
 
+// tlsDialContext is tls.DialWithDialer with a context, which the tls package
+// only accepts from Go 1.15.
+func (va VAImpl) tlsDialContext(ctx context.Context, hostPort string, config *tls.Config) (*tls.Conn, error) {
+	rawConn, err := va.dialContext(ctx, "tcp", hostPort)
+	if err != nil {
+		return nil, err
+	}
+	conn := tls.Client(rawConn, config)
+
+	// Closing the connection aborts the handshake if ctx is done first
+	handshakeDone := make(chan struct{})
+	defer close(handshakeDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			_ = rawConn.Close()
+		case <-handshakeDone:
+		}
+	}()
+	if err := conn.Handshake(); err != nil {
+		_ = rawConn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
 func (va VAImpl) validateHTTP01(task *vaTask) *core.ValidationRecord {
-	body, url, err := va.fetchHTTP(task.Identifier, task.Challenge.Token)
+	expectedKeyAuthorization := task.Challenge.ExpectedKeyAuthorization(task.Account.Key)
+	return va.checkHTTP01(task.ctx, task.Identifier, expectedKeyAuthorization)
+}
+
+// checkHTTP01 checks that the identifier's HTTP server serves the expected key
+// authorization at the challenge path of its token.
+func (va VAImpl) checkHTTP01(
+	ctx context.Context,
+	identifier string,
+	expectedKeyAuthorization string) *core.ValidationRecord {
+	token := strings.SplitN(expectedKeyAuthorization, ".", 2)[0]
+	body, url, err := va.fetchHTTP(ctx, identifier, token)
 
 	result := &core.ValidationRecord{
 		URL:         url,
@@ -433,13 +825,10 @@ func (va VAImpl) validateHTTP01(task *vaTask) *core.ValidationRecord {
 		return result
 	}
 
-	expectedKeyAuthorization := task.Challenge.ExpectedKeyAuthorization(task.Account.Key)
 	// The server SHOULD ignore whitespace characters at the end of the body
-	payload := strings.TrimRight(string(body), whitespaceCutset)
-	if payload != expectedKeyAuthorization {
+	if mismatch := checkHTTP01Body(string(body), expectedKeyAuthorization, va.lenientHTTP01); mismatch != nil {
 		result.Error = acme.UnauthorizedProblem(
-			fmt.Sprintf("The key authorization file from the server did not match this challenge %q != %q",
-				expectedKeyAuthorization, payload))
+			fmt.Sprintf("The key authorization file from the server did not match this challenge: %s", mismatch))
 	}
 
 	return result
@@ -448,7 +837,7 @@ func (va VAImpl) validateHTTP01(task *vaTask) *core.ValidationRecord {
 // NOTE(@cpu): fetchHTTP only fetches the ACME HTTP-01 challenge path for
 // a given challenge & identifier domain. It is not a challenge agnostic general
 // purpose HTTP function
-func (va VAImpl) fetchHTTP(identifier string, token string) ([]byte, string, *acme.ProblemDetails) {
+func (va VAImpl) fetchHTTP(ctx context.Context, identifier string, token string) ([]byte, string, *acme.ProblemDetails) {
 	path := fmt.Sprintf("%s%s", acme.HTTP01BaseURL, token)
 
 	url := &url.URL{
@@ -457,28 +846,35 @@ func (va VAImpl) fetchHTTP(identifier string, token string) ([]byte, string, *ac
 		Path:   path,
 	}
 
+	header := http.Header{}
+	va.applyHTTPCaching(url, header)
 	va.log.Printf("Attempting to validate w/ HTTP: %s\n", url)
 	httpRequest, err := http.NewRequest("GET", url.String(), nil)
 	if err != nil {
 		return nil, url.String(), acme.MalformedProblem(
 			fmt.Sprintf("Invalid URL %q\n", url.String()))
 	}
+	// The request's context bounds reading the response as well as making
+	// the request, and tells a timeout from one of the dialer's.
+	ctx, cancel := context.WithTimeout(ctx, va.timeouts.read())
+	defer cancel()
+	httpRequest = httpRequest.WithContext(va.withChallengePorts(ctx, identifier, acme.ChallengeHTTP01))
+	httpRequest.Header = header
 	httpRequest.Header.Set("User-Agent", userAgent())
 	httpRequest.Header.Set("Accept", "*/*")
 
-	transport := &http.Transport{
-		// We don't expect to make multiple requests to a client, so close
-		// connection immediately.
-		DisableKeepAlives: true,
-	}
-
 	client := &http.Client{
-		Transport: transport,
-		Timeout:   time.Second * 5,
+		Transport: va.httpTransport(),
 	}
 
 	resp, err := client.Do(httpRequest)
 	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			va.timeoutCounter.Record("va", "read")
+		}
+		if prob := blockedProblem(err, acme.ChallengeHTTP01); prob != nil {
+			return nil, url.String(), prob
+		}
 		return nil, url.String(), acme.ConnectionProblem(err.Error())
 	}
 
@@ -487,6 +883,11 @@ func (va VAImpl) fetchHTTP(identifier string, token string) ([]byte, string, *ac
 	// use Pebble anywhere that isn't a testing rig!!!
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			va.timeoutCounter.Record("va", "read")
+			_ = resp.Body.Close()
+			return nil, url.String(), acme.ConnectionProblem(err.Error())
+		}
 		return nil, url.String(), acme.InternalErrorProblem(err.Error())
 	}
 	err = resp.Body.Close()
@@ -0,0 +1,226 @@
+// Package webhook sends notifications of certificate events to HTTP endpoints
+// so that test harnesses don't have to poll Pebble for them.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/logging"
+	"github.com/letsencrypt/pebble/timeouts"
+)
+
+const (
+	// EventIssued is sent when a certificate is issued.
+	EventIssued = "issued"
+	// EventRevoked is sent when a certificate is revoked.
+	EventRevoked = "revoked"
+	// EventExpiring is sent once for each certificate that is within the
+	// configured expiry warning period of its expiry.
+	EventExpiring = "expiring"
+
+	// SignatureHeader is the request header holding the hex encoded HMAC-SHA256
+	// of the request body, keyed with the configured secret.
+	SignatureHeader = "X-Pebble-Signature"
+
+	defaultMaxAttempts    = 3
+	defaultRetryDelay     = time.Second
+	defaultCheckInterval  = time.Minute
+	defaultRequestTimeout = 10 * time.Second
+)
+
+// Config configures the webhooks. The zero value sends no notifications.
+type Config struct {
+	// URLs are the endpoints every event is POSTed to.
+	URLs []string
+	// Events are the event types to send. Empty means every event type.
+	Events []string
+	// Secret is the HMAC-SHA256 key used to sign request bodies. Requests
+	// aren't signed if it is empty.
+	Secret string
+	// MaxAttempts is the number of times delivery of an event to a URL is
+	// attempted. Defaults to 3.
+	MaxAttempts int
+	// RetryDelay is the delay between delivery attempts. Defaults to one
+	// second.
+	RetryDelay time.Duration
+	// ExpiryWarning is how long before a certificate expires the expiring
+	// event is sent. Zero disables expiring events.
+	ExpiryWarning time.Duration
+	// CheckInterval is how often certificates are checked for approaching
+	// expiry. Defaults to one minute.
+	CheckInterval time.Duration
+	// RequestTimeout bounds each delivery attempt. Defaults to ten seconds.
+	RequestTimeout time.Duration
+	// TimeoutCounter counts the delivery attempts that time out. It may be
+	// nil.
+	TimeoutCounter *timeouts.Counter
+}
+
+// Event is the JSON body sent to webhook URLs.
+type Event struct {
+	Type      string   `json:"type"`
+	Time      string   `json:"time"`
+	Serial    string   `json:"serial"`
+	AccountID string   `json:"accountID,omitempty"`
+	Names     []string `json:"names,omitempty"`
+	NotAfter  string   `json:"notAfter"`
+}
+
+// Notifier sends events to the configured webhook URLs. A nil *Notifier sends
+// nothing, so callers don't need to check whether webhooks are configured.
+type Notifier struct {
+	log    logging.Logger
+	clk    clock.Clock
+	config Config
+	client *http.Client
+
+	// expiringSent holds the serials of certificates an expiring event has
+	// been sent for.
+	sync.Mutex
+	expiringSent map[string]bool
+}
+
+// New returns a Notifier for the given config, or nil if no URLs are
+// configured.
+func New(log logging.Logger, clk clock.Clock, config Config) *Notifier {
+	if len(config.URLs) == 0 {
+		return nil
+	}
+	if config.MaxAttempts <= 0 {
+		config.MaxAttempts = defaultMaxAttempts
+	}
+	if config.RetryDelay <= 0 {
+		config.RetryDelay = defaultRetryDelay
+	}
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = defaultCheckInterval
+	}
+	if config.RequestTimeout <= 0 {
+		config.RequestTimeout = defaultRequestTimeout
+	}
+	log.Printf("Sending webhooks to %q", config.URLs)
+	return &Notifier{
+		log:          log,
+		clk:          clk,
+		config:       config,
+		client:       &http.Client{Timeout: config.RequestTimeout},
+		expiringSent: make(map[string]bool),
+	}
+}
+
+// Notify sends an event of the given type for the certificate to every URL in
+// the background.
+func (n *Notifier) Notify(eventType string, cert *core.Certificate) {
+	if n == nil || !n.wants(eventType) {
+		return
+	}
+	event := Event{
+		Type:      eventType,
+		Time:      n.clk.Now().UTC().Format(time.RFC3339),
+		Serial:    cert.ID,
+		AccountID: cert.AccountID,
+		Names:     cert.Cert.DNSNames,
+		NotAfter:  cert.Cert.NotAfter.UTC().Format(time.RFC3339),
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		n.log.Printf("webhook: error marshalling %s event: %s\n", eventType, err)
+		return
+	}
+	for _, url := range n.config.URLs {
+		go n.deliver(url, eventType, body)
+	}
+}
+
+func (n *Notifier) wants(eventType string) bool {
+	if len(n.config.Events) == 0 {
+		return true
+	}
+	for _, e := range n.config.Events {
+		if e == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// deliver POSTs the body to the URL, retrying failed attempts.
+func (n *Notifier) deliver(url, eventType string, body []byte) {
+	for attempt := 1; attempt <= n.config.MaxAttempts; attempt++ {
+		err := n.post(url, body)
+		if err == nil {
+			n.log.Printf("webhook: sent %s event to %s\n", eventType, url)
+			return
+		}
+		n.log.Printf("webhook: attempt %d/%d to send %s event to %s failed: %s\n",
+			attempt, n.config.MaxAttempts, eventType, url, err)
+		if attempt < n.config.MaxAttempts {
+			n.clk.Sleep(n.config.RetryDelay)
+		}
+	}
+}
+
+func (n *Notifier) post(url string, body []byte) error {
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if n.config.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(n.config.Secret))
+		_, _ = mac.Write(body)
+		req.Header.Set(SignatureHeader, "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		n.config.TimeoutCounter.Check(err, "webhook", "request")
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// WatchExpiry periodically sends an expiring event for each certificate
+// returned by getCerts that expires within the expiry warning period. It
+// returns immediately if expiring events are disabled.
+func (n *Notifier) WatchExpiry(getCerts func() []*core.Certificate) {
+	if n == nil || n.config.ExpiryWarning <= 0 || !n.wants(EventExpiring) {
+		return
+	}
+	go func() {
+		for {
+			n.clk.Sleep(n.config.CheckInterval)
+			n.checkExpiry(getCerts())
+		}
+	}()
+}
+
+func (n *Notifier) checkExpiry(certs []*core.Certificate) {
+	deadline := n.clk.Now().Add(n.config.ExpiryWarning)
+	for _, cert := range certs {
+		if cert.Cert.NotAfter.After(deadline) {
+			continue
+		}
+		n.Lock()
+		sent := n.expiringSent[cert.ID]
+		n.expiringSent[cert.ID] = true
+		n.Unlock()
+		if !sent {
+			n.Notify(EventExpiring, cert)
+		}
+	}
+}
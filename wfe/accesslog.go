@@ -0,0 +1,160 @@
+package wfe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The formats of the access log.
+const (
+	AccessLogFormatCLF  = "clf"
+	AccessLogFormatJSON = "json"
+)
+
+// clfTimeFormat is the timestamp format of the Common Log Format.
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogEntry is one request in the access log. It is written as a JSON
+// object in the JSON format.
+type accessLogEntry struct {
+	Time        string `json:"time"`
+	ClientAddr  string `json:"clientAddr"`
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Proto       string `json:"proto"`
+	AccountID   string `json:"accountID,omitempty"`
+	Status      int    `json:"status"`
+	Bytes       int    `json:"bytes"`
+	ProblemType string `json:"problemType,omitempty"`
+	UserAgent   string `json:"userAgent,omitempty"`
+}
+
+// accessLogger writes an entry for every ACME request to an io.Writer.
+type accessLogger struct {
+	sync.Mutex
+	w      io.Writer
+	format string
+}
+
+// newAccessLogger returns an accessLogger writing to w in the given format, or
+// nil if w is nil. The format defaults to CLF.
+func newAccessLogger(w io.Writer, format string) (*accessLogger, error) {
+	if w == nil {
+		return nil, nil
+	}
+	switch format {
+	case "":
+		format = AccessLogFormatCLF
+	case AccessLogFormatCLF, AccessLogFormatJSON:
+	default:
+		return nil, fmt.Errorf("unknown access log format %q", format)
+	}
+	return &accessLogger{w: w, format: format}, nil
+}
+
+// log writes the entry. Write errors are ignored since there is nowhere
+// better to report them.
+func (l *accessLogger) log(entry accessLogEntry) {
+	var line []byte
+	if l.format == AccessLogFormatJSON {
+		line, _ = json.Marshal(entry)
+	} else {
+		line = []byte(fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %d \"%s\" \"%s\"",
+			entry.ClientAddr, clfField(entry.AccountID), entry.Time,
+			entry.Method, entry.Path, entry.Proto, entry.Status, entry.Bytes,
+			clfField(entry.ProblemType), clfField(entry.UserAgent)))
+	}
+	line = append(line, '\n')
+
+	l.Lock()
+	defer l.Unlock()
+	_, _ = l.w.Write(line)
+}
+
+// clfField returns "-" for empty fields as the Common Log Format does.
+func clfField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// accessLogWriter records the status, size and problem type of a response for
+// the access log.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	// problemType is set by sendError to the type of the problem document
+	// sent, without any namespace customisation so log assertions don't
+	// depend on the problem config.
+	problemType string
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+// logAccess writes the access log entry for a request once its response has
+// been written. Requests without a key ID, like new account requests, are
+// logged with the account ID from the response's Location header if it is an
+// account URL.
+func (wfe *WebFrontEndImpl) logAccess(
+	start time.Time,
+	logEvent *requestEvent,
+	request *http.Request,
+	response *accessLogWriter,
+	accountID string) {
+
+	status := response.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	if accountID == "" {
+		location := response.Header().Get("Location")
+		for _, path := range accountPaths {
+			if strings.Contains(location, path) {
+				accountID = wfe.parseAccountURL(location)
+				break
+			}
+		}
+	}
+	clientAddr := logEvent.ClientAddr
+	if host, _, err := net.SplitHostPort(clientAddr); err == nil {
+		clientAddr = host
+	}
+	timestamp := start.Format(time.RFC3339)
+	if wfe.accessLog.format == AccessLogFormatCLF {
+		timestamp = start.Format(clfTimeFormat)
+	}
+	wfe.accessLog.log(accessLogEntry{
+		Time:        timestamp,
+		ClientAddr:  clientAddr,
+		Method:      request.Method,
+		Path:        logEvent.Endpoint,
+		Proto:       request.Proto,
+		AccountID:   accountID,
+		Status:      status,
+		Bytes:       response.bytes,
+		ProblemType: response.problemType,
+		UserAgent:   logEvent.UserAgent,
+	})
+}
@@ -0,0 +1,36 @@
+package wfe
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// AccountAttestation serves the attestation object submitted with the
+// new-account request of the account with the ID at the end of the request
+// path.
+func (wfe *WebFrontEndImpl) AccountAttestation(response http.ResponseWriter, request *http.Request) {
+	acctID := strings.TrimPrefix(request.URL.Path, accountAttestationPath)
+	acct := wfe.db.GetAccountByID(acctID)
+	if acct == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No account %q found", acctID)), response)
+		return
+	}
+	if len(acct.AttestationObject) == 0 {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf(
+			"Account %q was created without an attestation object", acctID)), response)
+		return
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, acme.AccountAttestation{
+		ID:     acctID,
+		Format: acct.AttestationFormat,
+		AttObj: base64.RawURLEncoding.EncodeToString(acct.AttestationObject),
+	})
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling account attestation"), response)
+	}
+}
@@ -0,0 +1,141 @@
+package wfe
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// The formats of account URLs.
+const (
+	// AccountURLKeyDigest URLs are acctPath followed by the account ID, the
+	// hex encoded SHA-256 digest of the account's key. It is the default.
+	AccountURLKeyDigest = "key-digest"
+	// AccountURLInteger URLs are acctPath followed by a sequence number
+	// assigned to the account.
+	AccountURLInteger = "integer"
+	// AccountURLBoulder URLs are boulderAcctPath followed by a sequence
+	// number, like the account URLs of Boulder.
+	AccountURLBoulder = "boulder"
+	// AccountURLLegacy URLs are legacyAcctPath followed by a sequence number,
+	// like the registration URLs of ACME v1.
+	AccountURLLegacy = "legacy"
+
+	boulderAcctPath = "/acme/acct/"
+	legacyAcctPath  = "/acme/reg/"
+)
+
+// accountPaths are the path prefixes of every account URL format.
+var accountPaths = []string{acctPath, boulderAcctPath, legacyAcctPath}
+
+// accountNumbers assigns sequence numbers to accounts for the account URL
+// formats that use them.
+type accountNumbers struct {
+	sync.Mutex
+	byID map[string]int
+	// ids holds the ID of the account numbered n at index n-1.
+	ids []string
+}
+
+func newAccountNumbers() *accountNumbers {
+	return &accountNumbers{byID: make(map[string]int)}
+}
+
+// number returns the account's number, assigning the next one if it hasn't
+// got one yet.
+func (n *accountNumbers) number(acctID string) int {
+	n.Lock()
+	defer n.Unlock()
+	if num, ok := n.byID[acctID]; ok {
+		return num
+	}
+	n.ids = append(n.ids, acctID)
+	n.byID[acctID] = len(n.ids)
+	return len(n.ids)
+}
+
+// id returns the ID of the account with the given number, or "" if no account
+// has it.
+func (n *accountNumbers) id(num int) string {
+	n.Lock()
+	defer n.Unlock()
+	if num < 1 || num > len(n.ids) {
+		return ""
+	}
+	return n.ids[num-1]
+}
+
+// numberedAccounts returns true if account URLs end in a sequence number
+// instead of the account ID.
+func (wfe *WebFrontEndImpl) numberedAccounts() bool {
+	return wfe.config.AccountURLFormat != "" && wfe.config.AccountURLFormat != AccountURLKeyDigest
+}
+
+// accountURLFormat returns the name of the account URL format.
+func (wfe *WebFrontEndImpl) accountURLFormat() string {
+	if wfe.config.AccountURLFormat == "" {
+		return AccountURLKeyDigest
+	}
+	return wfe.config.AccountURLFormat
+}
+
+// accountPath returns the path prefix of account URLs.
+func (wfe *WebFrontEndImpl) accountPath() string {
+	switch wfe.config.AccountURLFormat {
+	case AccountURLBoulder:
+		return boulderAcctPath
+	case AccountURLLegacy:
+		return legacyAcctPath
+	}
+	return acctPath
+}
+
+// accountURL returns the URL of the account with the given ID.
+func (wfe *WebFrontEndImpl) accountURL(request *http.Request, acctID string) string {
+	suffix := acctID
+	if wfe.numberedAccounts() {
+		suffix = strconv.Itoa(wfe.acctNumbers.number(acctID))
+	}
+	return wfe.relativeEndpoint(request, wfe.accountPath()+suffix)
+}
+
+// keyIDAccount returns the ID of the account whose URL is the key ID, or ""
+// if it isn't an account URL in the configured format.
+func (wfe *WebFrontEndImpl) keyIDAccount(request *http.Request, keyID string) string {
+	prefix := wfe.relativeEndpoint(request, wfe.accountPath())
+	if !strings.HasPrefix(keyID, prefix) {
+		return ""
+	}
+	suffix := strings.TrimPrefix(keyID, prefix)
+	if !wfe.numberedAccounts() {
+		return suffix
+	}
+	num, err := strconv.Atoi(suffix)
+	if err != nil {
+		return ""
+	}
+	return wfe.acctNumbers.id(num)
+}
+
+// parseAccountURL returns the account ID of an account URL in any format and
+// for any host. A value without a path is taken to be an account ID or
+// number.
+func (wfe *WebFrontEndImpl) parseAccountURL(accountURL string) string {
+	suffix := ""
+	for _, path := range accountPaths {
+		if i := strings.LastIndex(accountURL, path); i >= 0 {
+			suffix = accountURL[i+len(path):]
+			break
+		}
+	}
+	if suffix == "" && !strings.Contains(accountURL, "/") {
+		suffix = accountURL
+	}
+	if num, err := strconv.Atoi(suffix); err == nil {
+		if acctID := wfe.acctNumbers.id(num); acctID != "" {
+			return acctID
+		}
+	}
+	return suffix
+}
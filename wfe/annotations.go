@@ -0,0 +1,135 @@
+package wfe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/db"
+)
+
+// annotatedCollections are the store collections whose objects can be
+// annotated, in the order Annotated lists them.
+var annotatedCollections = []string{
+	db.CollectionAccounts,
+	db.CollectionOrders,
+	db.CollectionCertificates,
+}
+
+// annotations returns the annotations of the object of the collection whose
+// ID is the rest of the request path after prefix for a GET request. A POST
+// request merges the JSON object of annotations in its body into them first,
+// removing those with an empty value.
+func (wfe *WebFrontEndImpl) annotations(
+	collection, prefix string,
+	response http.ResponseWriter,
+	request *http.Request) {
+	id := strings.TrimPrefix(request.URL.Path, prefix)
+
+	var annotations map[string]string
+	var err error
+	if request.Method == "POST" {
+		body, readErr := ioutil.ReadAll(request.Body)
+		if readErr != nil {
+			wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+			return
+		}
+		var update map[string]string
+		if err := json.Unmarshal(body, &update); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling annotations: %s", err.Error())), response)
+			return
+		}
+		for key := range update {
+			if err := db.CheckAnnotationKey(key); err != nil {
+				wfe.sendError(acme.MalformedProblem(err.Error()), response)
+				return
+			}
+		}
+		annotations, err = wfe.db.Annotate(collection, id, update)
+		if err == nil {
+			wfe.log.Printf("management: annotated %s %s with %d annotations\n",
+				collection, id, len(update))
+		}
+	} else {
+		annotations, err = wfe.db.GetAnnotations(collection, id)
+	}
+	if err != nil {
+		wfe.sendError(acme.NotFoundProblem(err.Error()), response)
+		return
+	}
+
+	err = wfe.writeJsonResponse(response, http.StatusOK, annotations)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling annotations"), response)
+	}
+}
+
+// AccountAnnotations gets or sets the annotations of an account. See
+// annotations.
+func (wfe *WebFrontEndImpl) AccountAnnotations(response http.ResponseWriter, request *http.Request) {
+	wfe.annotations(db.CollectionAccounts, accountAnnotationsPath, response, request)
+}
+
+// OrderAnnotations gets or sets the annotations of an order. See
+// annotations.
+func (wfe *WebFrontEndImpl) OrderAnnotations(response http.ResponseWriter, request *http.Request) {
+	wfe.annotations(db.CollectionOrders, orderAnnotationsPath, response, request)
+}
+
+// CertificateAnnotations gets or sets the annotations of a certificate. See
+// annotations.
+func (wfe *WebFrontEndImpl) CertificateAnnotations(response http.ResponseWriter, request *http.Request) {
+	wfe.annotations(db.CollectionCertificates, certificateAnnotationsPath, response, request)
+}
+
+// Annotated returns the annotated objects, optionally only those of the
+// collection given by the "collection" query parameter and those matching the
+// annotation selector given by the "selector" query parameter.
+func (wfe *WebFrontEndImpl) Annotated(response http.ResponseWriter, request *http.Request) {
+	params := request.URL.Query()
+	selector, err := db.ParseAnnotationSelector(params.Get("selector"))
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem(err.Error()), response)
+		return
+	}
+	collections := annotatedCollections
+	if c := params.Get("collection"); c != "" {
+		collections = nil
+		for _, name := range annotatedCollections {
+			if name == c {
+				collections = []string{c}
+			}
+		}
+		if collections == nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Invalid collection %q, must be one of %s", c,
+				strings.Join(annotatedCollections, ", "))), response)
+			return
+		}
+	}
+
+	result := []acme.AnnotatedObject{}
+	for _, collection := range collections {
+		for _, id := range wfe.db.FindAnnotated(collection, selector) {
+			annotations, err := wfe.db.GetAnnotations(collection, id)
+			if err != nil {
+				// The object was removed since it was found
+				continue
+			}
+			result = append(result, acme.AnnotatedObject{
+				Collection:  collection,
+				ID:          id,
+				Annotations: annotations,
+			})
+		}
+	}
+
+	err = wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling annotated objects"), response)
+	}
+}
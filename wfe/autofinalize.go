@@ -0,0 +1,155 @@
+package wfe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/cryptomode"
+	"github.com/letsencrypt/pebble/db"
+	"github.com/letsencrypt/pebble/events"
+	"github.com/letsencrypt/pebble/logging"
+)
+
+// autoFinalizer finalizes orders as soon as they become ready, with a CSR
+// for a key it generates, and keeps the keys so that the certificates can be
+// used by tooling that doesn't finalize orders itself.
+type autoFinalizer struct {
+	sync.Mutex
+	log    logging.Logger
+	clk    clock.Clock
+	db     *db.MemoryStore
+	events *events.Broker
+	// complete asks the CA to complete a finalized order.
+	complete func(*core.Order)
+	keys     map[string]*ecdsa.PrivateKey
+}
+
+func newAutoFinalizer(
+	log logging.Logger,
+	clk clock.Clock,
+	db *db.MemoryStore,
+	events *events.Broker) *autoFinalizer {
+	return &autoFinalizer{
+		log:    log,
+		clk:    clk,
+		db:     db,
+		events: events,
+		keys:   make(map[string]*ecdsa.PrivateKey),
+	}
+}
+
+// observe finalizes orders that may have become ready. New orders are
+// published as pending even if their authorizations were reused and they are
+// already ready, so their status is checked too. The orders are finalized
+// separately since the publisher may hold their locks.
+func (a *autoFinalizer) observe(e events.Event) {
+	if e.Type != events.TypeOrder ||
+		(e.Status != acme.StatusReady && e.Status != acme.StatusPending) {
+		return
+	}
+	go a.finalize(e.ID)
+}
+
+// finalize finalizes the order with the given ID if it is ready.
+func (a *autoFinalizer) finalize(orderID string) {
+	order, err := a.db.GetOrderByID(orderID)
+	if err != nil || order == nil {
+		return
+	}
+	if status, err := order.GetStatus(a.clk); err != nil || status != acme.StatusReady {
+		return
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptomode.Reader())
+	if err != nil {
+		a.log.Printf("Error generating key to auto-finalize order %s: %s", orderID, err)
+		return
+	}
+
+	// BeganProcessing is checked under the same lock it is set with, so that
+	// an order finalized by its client isn't finalized again
+	order.Lock()
+	if order.BeganProcessing {
+		order.Unlock()
+		return
+	}
+	order.ParsedCSR = &x509.CertificateRequest{
+		DNSNames:  order.Names,
+		PublicKey: key.Public(),
+	}
+	order.BeganProcessing = true
+	order.Status = acme.StatusProcessing
+	order.Unlock()
+
+	a.Lock()
+	a.keys[orderID] = key
+	a.Unlock()
+	a.log.Printf("Auto-finalizing order %s", orderID)
+	a.events.Publish(events.TypeOrder, orderID, acme.StatusProcessing, "")
+	a.complete(order)
+}
+
+// key returns the key an order was auto-finalized with.
+func (a *autoFinalizer) key(orderID string) *ecdsa.PrivateKey {
+	a.Lock()
+	defer a.Unlock()
+	return a.keys[orderID]
+}
+
+// AutoFinalized returns the key and, once it is issued, the certificate chain
+// of the auto-finalized order with the ID at the end of the request path.
+func (wfe *WebFrontEndImpl) AutoFinalized(response http.ResponseWriter, request *http.Request) {
+	if wfe.autoFinalizer == nil {
+		wfe.sendError(acme.NotFoundProblem(
+			"Orders aren't auto-finalized, enable auto-finalization"), response)
+		return
+	}
+	orderID := strings.TrimPrefix(request.URL.Path, autoFinalizedPath)
+	key := wfe.autoFinalizer.key(orderID)
+	order, err := wfe.db.GetOrderByID(orderID)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	if key == nil || order == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf(
+			"No auto-finalized order %q found", orderID)), response)
+		return
+	}
+
+	status, err := order.GetStatus(wfe.clk)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	result := acme.AutoFinalizedOrder{
+		ID:     orderID,
+		Status: status,
+		Key:    string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+	}
+	order.RLock()
+	cert := order.CertificateObject
+	order.RUnlock()
+	if cert != nil {
+		result.Certificate = string(cert.Chain())
+	}
+
+	err = wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling auto-finalized order"), response)
+		return
+	}
+}
@@ -0,0 +1,115 @@
+package wfe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/db"
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// bulkRevocation selects the certificates revoked by RevokeCertificates. A
+// certificate is revoked if it matches every criterion given, and at least
+// one must be given.
+type bulkRevocation struct {
+	// Account is the ID or URL of the account the certificates were issued
+	// to.
+	Account string `json:"account,omitempty"`
+	// SAN is a pattern (see the pattern package) matched against the DNS
+	// names of the certificates. A certificate matches if any name does.
+	SAN string `json:"san,omitempty"`
+	// Serials are hex serial numbers of certificates.
+	Serials []string `json:"serials,omitempty"`
+	// Reason is the revocation reason recorded in the audit log.
+	Reason *uint `json:"reason,omitempty"`
+}
+
+// matchesSAN returns true if any of the certificate's DNS names matches the
+// pattern.
+func matchesSAN(cert *core.Certificate, san string) bool {
+	for _, name := range cert.Cert.DNSNames {
+		if pattern.Match(san, strings.ToLower(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// RevokeCertificates revokes every unrevoked certificate matching the JSON
+// bulkRevocation in the body of a POST request and returns an
+// acme.RevocationResult. Revoked certificates are forgotten like those revoked
+// through the ACME API, so they don't appear in a CRL or OCSP response.
+func (wfe *WebFrontEndImpl) RevokeCertificates(response http.ResponseWriter, request *http.Request) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return
+	}
+	var req bulkRevocation
+	if err := json.Unmarshal(body, &req); err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error unmarshaling bulk revocation: %s", err.Error())), response)
+		return
+	}
+	if req.Account == "" && req.SAN == "" && len(req.Serials) == 0 {
+		wfe.sendError(acme.MalformedProblem(
+			"Bulk revocation must select certificates by account, san or serials"), response)
+		return
+	}
+	if err := pattern.Check(req.SAN); err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf("Invalid san: %s", err)), response)
+		return
+	}
+	if prob := validRevocationReason(req.Reason); prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+	query := db.CertificateQuery{AccountID: req.Account}
+	if query.AccountID != "" {
+		query.AccountID = wfe.parseAccountURL(query.AccountID)
+	}
+
+	var candidates []*core.Certificate
+	result := acme.RevocationResult{Revoked: []acme.CertificateSummary{}}
+	if len(req.Serials) == 0 {
+		candidates = wfe.db.FindCertificates(query)
+	}
+	for _, s := range req.Serials {
+		serial, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+		if !ok {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"serial %q is not a hex serial number", s)), response)
+			return
+		}
+		query.SerialMin, query.SerialMax = serial, serial
+		certs := wfe.db.FindCertificates(query)
+		if len(certs) == 0 {
+			result.NotFound = append(result.NotFound, s)
+		}
+		candidates = append(candidates, certs...)
+	}
+
+	principal := wfe.managementPrincipal(request)
+	revoked := make(map[*core.Certificate]bool)
+	for _, cert := range candidates {
+		if revoked[cert] || (req.SAN != "" && !matchesSAN(cert, req.SAN)) {
+			continue
+		}
+		revoked[cert] = true
+		wfe.revoke(cert, principal, req.Reason)
+		result.Revoked = append(result.Revoked, summarizeCertificate(cert))
+	}
+	wfe.log.Printf("management: revoked %d certificates\n", len(result.Revoked))
+
+	err = wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling bulk revocation result"), response)
+		return
+	}
+}
@@ -0,0 +1,81 @@
+package wfe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// orderCancellation is the body of a request to cancel an order.
+type orderCancellation struct {
+	// Error is the problem the cancelled order is given. It defaults to an
+	// unauthorized problem.
+	Error *acme.ProblemDetails `json:"error,omitempty"`
+}
+
+// problem returns the error of the cancellation, or a default problem.
+func (c orderCancellation) problem() *acme.ProblemDetails {
+	if c.Error != nil && c.Error.Type != "" {
+		return c.Error
+	}
+	return acme.UnauthorizedProblem("Order cancelled by the CA")
+}
+
+// CancelOrder makes the in-flight order with the ID at the end of the request
+// path invalid with the problem in the request body, as a CA administrator
+// rejecting it would. Orders that are processing are released from any
+// processing hold and never get a certificate. Orders that are already valid
+// or invalid can't be cancelled.
+func (wfe *WebFrontEndImpl) CancelOrder(response http.ResponseWriter, request *http.Request) {
+	orderID := strings.TrimPrefix(request.URL.Path, cancelOrderPath)
+	order, err := wfe.db.GetOrderByID(orderID)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	if order == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No order %q found", orderID)), response)
+		return
+	}
+
+	var cancellation orderCancellation
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &cancellation); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling order cancellation: %s", err.Error())), response)
+			return
+		}
+	}
+
+	// The order's certificate and error are checked again under the lock
+	// its error is set with, in case it changed since its status was found.
+	status, err := order.GetStatus(wfe.clk)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	order.Lock()
+	if status == acme.StatusValid || status == acme.StatusInvalid ||
+		order.CertificateObject != nil || order.Error != nil {
+		order.Unlock()
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Order %q is %s and can't be cancelled", orderID, status)), response)
+		return
+	}
+	order.Error = cancellation.problem()
+	order.Unlock()
+	wfe.holds.release(orderID)
+	wfe.log.Printf("management: cancelled %s order %s\n", status, orderID)
+	wfe.publishOrderStatus(order)
+
+	wfe.writeForcedStatus(response, orderID, acme.StatusInvalid)
+}
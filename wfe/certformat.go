@@ -0,0 +1,104 @@
+package wfe
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+const (
+	// pemChainContentType is the default certificate format of RFC 8555
+	// Section 7.4.2, the PEM encoded chain.
+	pemChainContentType = "application/pem-certificate-chain"
+	// derContentType is the DER encoded end-entity certificate alone
+	// (RFC 2585).
+	derContentType = "application/pkix-cert"
+)
+
+// The ways of answering requests for the certificate of an order that is
+// still processing, see Config.CertificateNotReady.
+const (
+	// CertNotReadyOrderNotReady answers with a 403 orderNotReady problem.
+	CertNotReadyOrderNotReady = "order-not-ready"
+	// CertNotReadyNotFound answers with a 404.
+	CertNotReadyNotFound = "not-found"
+	// CertNotReadyRetryAfter answers with a 503 and a Retry-After header.
+	CertNotReadyRetryAfter = "retry-after"
+)
+
+// orderCertificate returns the certificate of the order with the given ID
+// and true if the order exists, for the early certificate URLs of processing
+// orders.
+func (wfe *WebFrontEndImpl) orderCertificate(orderID string) (*core.Certificate, bool) {
+	order, err := wfe.db.GetOrderByID(orderID)
+	if err != nil || order == nil {
+		return nil, false
+	}
+	order.RLock()
+	defer order.RUnlock()
+	return order.CertificateObject, true
+}
+
+// sendCertificateNotReady answers a request for the certificate of an order
+// that is still processing as configured by Config.CertificateNotReady.
+func (wfe *WebFrontEndImpl) sendCertificateNotReady(response http.ResponseWriter) {
+	switch wfe.config.CertificateNotReady {
+	case CertNotReadyOrderNotReady:
+		wfe.sendError(acme.OrderNotReadyProblem(
+			"The order's certificate has not been issued yet"), response)
+	case CertNotReadyRetryAfter:
+		response.Header().Set("Retry-After",
+			strconv.Itoa(int(wfe.config.CertificateRetryAfter/time.Second)))
+		wfe.sendError(acme.ServiceUnavailableProblem(
+			"The order's certificate has not been issued yet, try again later"), response)
+	default:
+		response.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// certificateContentType returns the certificate format preferred by a
+// request's Accept header: derContentType if it weighs application/pkix-cert
+// above the PEM chain, and pemChainContentType otherwise, including when the
+// header accepts neither.
+func certificateContentType(accept string) string {
+	weights := qValues(accept)
+	pemQ, ok := weights[pemChainContentType]
+	if !ok {
+		pemQ = -1
+	}
+	if derQ := weights[derContentType]; derQ > 0 && derQ > pemQ {
+		return derContentType
+	}
+	return pemChainContentType
+}
+
+// writeCertificateBody writes a certificate response body. With a positive
+// chunk size the body is written and flushed in chunks of that many bytes
+// without a Content-Length, so HTTP/1.1 responses use chunked transfer
+// encoding. Otherwise the body is written at once with its Content-Length.
+func writeCertificateBody(response http.ResponseWriter, body []byte, chunkSize int) {
+	if chunkSize <= 0 {
+		response.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		response.WriteHeader(http.StatusOK)
+		_, _ = response.Write(body)
+		return
+	}
+	flusher, _ := response.(http.Flusher)
+	response.WriteHeader(http.StatusOK)
+	for len(body) > 0 {
+		n := chunkSize
+		if n > len(body) {
+			n = len(body)
+		}
+		if _, err := response.Write(body[:n]); err != nil {
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		body = body[n:]
+	}
+}
@@ -0,0 +1,122 @@
+package wfe
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// The chain modes change how certificate chains are served to exercise
+// clients' chain normalization.
+const (
+	// ChainIncludeRoot appends the root to the chain, which is omitted by
+	// default.
+	ChainIncludeRoot = "include-root"
+	// ChainReversed serves the chain in reverse order, leaf last.
+	ChainReversed = "reversed"
+	// ChainDuplicates serves every issuer certificate twice, or the leaf if
+	// there are none.
+	ChainDuplicates = "duplicates"
+)
+
+// The PEM quirk chain modes change how the PEM encoding of chains is
+// formatted to exercise clients' PEM parsers, in ways seen in the wild.
+const (
+	// ChainNoTrailingNewline omits the line ending after the last PEM block.
+	ChainNoTrailingNewline = "no-trailing-newline"
+	// ChainExplanatoryText precedes every PEM block with a line of
+	// explanatory text, which RFC 7468 allows parsers to ignore.
+	ChainExplanatoryText = "explanatory-text"
+	// ChainCRLF ends lines with CRLF instead of LF.
+	ChainCRLF = "crlf"
+	// ChainLongLines puts the base64 encoded body of every PEM block on one
+	// line instead of wrapping it at 64 characters.
+	ChainLongLines = "long-lines"
+)
+
+// chainModeHeader is the request header that sets the chain modes of a single
+// certificate request as a comma separated list, overriding Config.ChainModes.
+// "none" serves the chain normally.
+const chainModeHeader = "Pebble-Chain-Mode"
+
+// chainModes is a set of chain modes.
+type chainModes map[string]bool
+
+func parseChainModes(modes []string) (chainModes, error) {
+	parsed := make(chainModes)
+	for _, mode := range modes {
+		mode = strings.TrimSpace(mode)
+		switch mode {
+		case ChainIncludeRoot, ChainReversed, ChainDuplicates,
+			ChainNoTrailingNewline, ChainExplanatoryText, ChainCRLF, ChainLongLines:
+			parsed[mode] = true
+		case "", "none":
+		default:
+			return nil, fmt.Errorf("unknown chain mode %q", mode)
+		}
+	}
+	return parsed, nil
+}
+
+// apply returns the PEM encoded chain, leaf first, rearranged and formatted
+// according to the modes. The root must only be included in the chain if
+// ChainIncludeRoot is set.
+func (modes chainModes) apply(chain [][]byte) []byte {
+	if modes[ChainDuplicates] {
+		var duplicated [][]byte
+		for i, cert := range chain {
+			duplicated = append(duplicated, cert)
+			if i > 0 || len(chain) == 1 {
+				duplicated = append(duplicated, cert)
+			}
+		}
+		chain = duplicated
+	}
+	if modes[ChainReversed] {
+		reversed := make([][]byte, 0, len(chain))
+		for i := len(chain) - 1; i >= 0; i-- {
+			reversed = append(reversed, chain[i])
+		}
+		chain = reversed
+	}
+	var joined []byte
+	for i, cert := range chain {
+		if modes[ChainExplanatoryText] {
+			joined = append(joined, explanatoryText(i, len(chain), cert)...)
+		}
+		if modes[ChainLongLines] {
+			cert = unwrapPEM(cert)
+		}
+		joined = append(joined, cert...)
+	}
+	if modes[ChainCRLF] {
+		joined = bytes.Replace(joined, []byte("\n"), []byte("\r\n"), -1)
+	}
+	if modes[ChainNoTrailingNewline] {
+		joined = bytes.TrimRight(joined, "\r\n")
+	}
+	return joined
+}
+
+// explanatoryText returns a line of text describing the PEM encoded
+// certificate at index i of a chain of n certificates.
+func explanatoryText(i, n int, cert []byte) []byte {
+	description := fmt.Sprintf("Certificate %d of %d", i+1, n)
+	if block, _ := pem.Decode(cert); block != nil {
+		description = fmt.Sprintf("%s (%d bytes of DER)", description, len(block.Bytes))
+	}
+	return []byte(description + "\n")
+}
+
+// unwrapPEM returns the PEM block with its base64 encoded body on one line.
+// Blocks that can't be decoded are returned unchanged.
+func unwrapPEM(cert []byte) []byte {
+	block, _ := pem.Decode(cert)
+	if block == nil || len(block.Headers) > 0 {
+		return cert
+	}
+	return []byte(fmt.Sprintf("-----BEGIN %s-----\n%s\n-----END %s-----\n",
+		block.Type, base64.StdEncoding.EncodeToString(block.Bytes), block.Type))
+}
@@ -0,0 +1,156 @@
+package wfe
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// A ChallengePolicy sets the challenges offered on new authorizations for
+// matching DNS identifiers. Permanent identifier, TNAuthList and .onion
+// authorizations always get their one challenge type.
+type ChallengePolicy struct {
+	// Pattern is a pattern (see the pattern package) matched against the
+	// identifier value, e.g. "*.internal.example.com". Empty matches every
+	// identifier.
+	Pattern string `json:"pattern,omitempty"`
+	// Wildcard makes the policy apply to wildcard identifiers instead of
+	// non-wildcard identifiers. The pattern is then matched against the
+	// identifier value without its "*." prefix.
+	Wildcard bool `json:"wildcard,omitempty"`
+	// Challenges are the challenge types offered, in order.
+	Challenges []string `json:"challenges"`
+}
+
+func (p ChallengePolicy) check() error {
+	if err := pattern.Check(p.Pattern); err != nil {
+		return fmt.Errorf("challenge policy: %s", err)
+	}
+	if len(p.Challenges) == 0 {
+		return fmt.Errorf("challenge policy for %q has no challenges", p.Pattern)
+	}
+	for _, chalType := range p.Challenges {
+		switch chalType {
+		case acme.ChallengeDNS01:
+		case acme.ChallengeHTTP01, acme.ChallengeTLSALPN01:
+			if p.Wildcard {
+				return fmt.Errorf("challenge policy for wildcard %q can only offer %s",
+					p.Pattern, acme.ChallengeDNS01)
+			}
+		default:
+			return fmt.Errorf("challenge policy for %q has unsupported challenge type %q",
+				p.Pattern, chalType)
+		}
+	}
+	return nil
+}
+
+func (p ChallengePolicy) matches(value string, wildcard bool) bool {
+	if p.Wildcard != wildcard {
+		return false
+	}
+	if p.Pattern == "" {
+		return true
+	}
+	return pattern.Match(p.Pattern, value)
+}
+
+// challengeTypes returns the challenge types to offer for a DNS identifier:
+// those of the first matching challenge policy, or by default every challenge
+// type for non-wildcard identifiers and dns-01 for wildcard identifiers.
+func (wfe *WebFrontEndImpl) challengeTypes(value string) []string {
+	wildcard := strings.HasPrefix(value, "*.")
+	if wildcard {
+		value = strings.TrimPrefix(value, "*.")
+	}
+	for _, p := range wfe.config.ChallengePolicies {
+		if p.matches(value, wildcard) {
+			return p.Challenges
+		}
+	}
+	if wildcard {
+		// Match Boulder/Let's Encrypt wildcard issuance policy
+		return []string{acme.ChallengeDNS01}
+	}
+	return []string{acme.ChallengeHTTP01, acme.ChallengeTLSALPN01, acme.ChallengeDNS01}
+}
+
+// challengeToggles holds the challenge types that are currently disabled.
+type challengeToggles struct {
+	sync.RWMutex
+	disabled map[string]bool
+}
+
+// newChallengeToggles returns toggles with the given challenge types
+// disabled.
+func newChallengeToggles(disabled []string) (*challengeToggles, error) {
+	t := &challengeToggles{disabled: make(map[string]bool)}
+	enabled := make(map[string]bool, len(disabled))
+	for _, chalType := range disabled {
+		enabled[chalType] = false
+	}
+	return t, t.update(enabled)
+}
+
+// update enables or disables the challenge types in the map. Types missing
+// from the map keep their state. At least one of http-01, tls-alpn-01 and
+// dns-01 must stay enabled.
+func (t *challengeToggles) update(enabled map[string]bool) error {
+	t.Lock()
+	defer t.Unlock()
+
+	disabled := make(map[string]bool, len(t.disabled))
+	for chalType := range t.disabled {
+		disabled[chalType] = true
+	}
+	for chalType, on := range enabled {
+		switch chalType {
+		case acme.ChallengeHTTP01, acme.ChallengeTLSALPN01, acme.ChallengeDNS01:
+		default:
+			return fmt.Errorf("unsupported challenge type %q", chalType)
+		}
+		if on {
+			delete(disabled, chalType)
+		} else {
+			disabled[chalType] = true
+		}
+	}
+	if len(disabled) == 3 {
+		return fmt.Errorf("at least one challenge type must be enabled")
+	}
+	t.disabled = disabled
+	return nil
+}
+
+// get returns whether each challenge type is enabled, keyed by challenge type.
+func (t *challengeToggles) get() map[string]bool {
+	t.RLock()
+	defer t.RUnlock()
+	return map[string]bool{
+		acme.ChallengeHTTP01:    !t.disabled[acme.ChallengeHTTP01],
+		acme.ChallengeTLSALPN01: !t.disabled[acme.ChallengeTLSALPN01],
+		acme.ChallengeDNS01:     !t.disabled[acme.ChallengeDNS01],
+	}
+}
+
+func (t *challengeToggles) enabled(chalType string) bool {
+	t.RLock()
+	defer t.RUnlock()
+	return !t.disabled[chalType]
+}
+
+// ChallengeTypes returns whether each of the http-01, tls-alpn-01 and dns-01
+// challenge types is offered in new authorizations.
+func (wfe *WebFrontEndImpl) ChallengeTypes() map[string]bool {
+	return wfe.challenges.get()
+}
+
+// SetChallengeTypes enables or disables the challenge types in the map for new
+// authorizations of non-wildcard DNS identifiers. Types missing from the map
+// keep their state.
+func (wfe *WebFrontEndImpl) SetChallengeTypes(enabled map[string]bool) error {
+	return wfe.challenges.update(enabled)
+}
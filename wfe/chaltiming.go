@@ -0,0 +1,31 @@
+package wfe
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// ChallengeTiming returns when validation of the challenge with the ID at the
+// end of the request path started and completed, and when each of its
+// validation attempts started and completed.
+func (wfe *WebFrontEndImpl) ChallengeTiming(response http.ResponseWriter, request *http.Request) {
+	chalID := strings.TrimPrefix(request.URL.Path, challengeTimingPath)
+	chal := wfe.db.GetChallengeByID(chalID)
+	if chal == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No challenge %q found", chalID)), response)
+		return
+	}
+
+	chal.RLock()
+	timing := describeChallengeTiming(chal.ID, &chal.Challenge)
+	chal.RUnlock()
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, timing)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling challenge timing"), response)
+		return
+	}
+}
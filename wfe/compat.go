@@ -0,0 +1,104 @@
+package wfe
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// The objects whose fields can be changed with ObjectFields.
+const (
+	ObjectDirectory     = "directory"
+	ObjectOrder         = "order"
+	ObjectAuthorization = "authorization"
+)
+
+// ObjectFields changes the fields of an object served to clients, to check
+// that clients tolerate fields they don't know and missing optional fields.
+type ObjectFields struct {
+	// Omit names fields removed from the object.
+	Omit []string
+	// Add holds fields added to the object. Fields the object already has
+	// are replaced.
+	Add map[string]interface{}
+}
+
+func checkObjectFields(fields map[string]ObjectFields) error {
+	for object := range fields {
+		if object != ObjectDirectory && object != ObjectOrder && object != ObjectAuthorization {
+			return fmt.Errorf("unknown object %q, expected %s, %s or %s",
+				object, ObjectDirectory, ObjectOrder, ObjectAuthorization)
+		}
+	}
+	return nil
+}
+
+func checkPatterns(patterns []string) error {
+	for _, p := range patterns {
+		if err := pattern.Check(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// objectName returns the name of the object in a response body, or "" if it
+// isn't one whose fields can be changed.
+func objectName(v interface{}) string {
+	switch v.(type) {
+	case acme.Order, *acme.Order:
+		return ObjectOrder
+	case acme.Authorization, *acme.Authorization:
+		return ObjectAuthorization
+	}
+	return ""
+}
+
+// changeFields returns the object with the fields configured for it omitted
+// and added. Objects without configured fields are returned as-is.
+func (wfe *WebFrontEndImpl) changeFields(object string, v interface{}) (interface{}, error) {
+	fields, ok := wfe.config.ObjectFields[object]
+	if !ok {
+		return v, nil
+	}
+	fieldsMap, ok := v.(map[string]interface{})
+	if !ok {
+		encoded, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(encoded, &fieldsMap); err != nil {
+			return nil, err
+		}
+	}
+	for _, name := range fields.Omit {
+		delete(fieldsMap, name)
+	}
+	for name, value := range fields.Add {
+		fieldsMap[name] = value
+	}
+	return fieldsMap, nil
+}
+
+// unsupportedIdentifier returns an unsupportedIdentifier problem if the
+// identifier's value matches one of the configured UnsupportedIdentifiers.
+func (wfe *WebFrontEndImpl) unsupportedIdentifier(ident acme.Identifier) *acme.ProblemDetails {
+	if p, ok := pattern.Lookup(wfe.config.UnsupportedIdentifiers, ident.Value); ok {
+		return acme.UnsupportedIdentifierProblem(fmt.Sprintf(
+			"Identifier %s %q is not supported (matches %q)", ident.Type, ident.Value, p))
+	}
+	return nil
+}
+
+// unsupportedContact returns an unsupportedContact problem if the contact URL
+// matches one of the configured UnsupportedContacts.
+func (wfe *WebFrontEndImpl) unsupportedContact(contact string) *acme.ProblemDetails {
+	if p, ok := pattern.Lookup(wfe.config.UnsupportedContacts, strings.TrimSpace(contact)); ok {
+		return acme.UnsupportedContactProblem(fmt.Sprintf(
+			"contact %q is not supported (matches %q)", contact, p))
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package wfe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// entityTag returns the strong entity tag of a response body.
+func entityTag(body []byte) string {
+	digest := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(digest[:16]) + `"`
+}
+
+// etagMatches returns true if the value of an If-None-Match header matches the
+// entity tag, using the weak comparison RFC 7232 Section 3.2 requires.
+func etagMatches(header, tag string) bool {
+	for _, t := range strings.Split(header, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified sets the ETag and, unless modified is zero, the Last-Modified
+// header of a response with the given body. If the request's If-None-Match
+// or If-Modified-Since header shows the client already has the body it writes
+// a 304 Not Modified response and returns true. If-Modified-Since is ignored
+// when If-None-Match is present. It does nothing if conditional requests are
+// disabled.
+func (wfe *WebFrontEndImpl) notModified(
+	response http.ResponseWriter,
+	request *http.Request,
+	body []byte,
+	modified time.Time) bool {
+	if wfe.config.DisableConditionalRequests {
+		return false
+	}
+
+	tag := entityTag(body)
+	response.Header().Set("ETag", tag)
+	if !modified.IsZero() {
+		response.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+
+	match := false
+	if header := request.Header.Get("If-None-Match"); header != "" {
+		match = etagMatches(header, tag)
+	} else if header := request.Header.Get("If-Modified-Since"); header != "" && !modified.IsZero() {
+		since, err := http.ParseTime(header)
+		match = err == nil && !modified.Truncate(time.Second).After(since)
+	}
+	if !match {
+		return false
+	}
+	response.Header().Del("Content-Type")
+	response.WriteHeader(http.StatusNotModified)
+	return true
+}
@@ -0,0 +1,73 @@
+package wfe
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/letsencrypt/pebble/va"
+)
+
+// contactDirectiveDomain is the domain of the mailto contacts that are
+// directives changing how Pebble behaves for the account, like an account
+// override, rather than email addresses.
+const contactDirectiveDomain = "test"
+
+// contactOverride returns the account override given by the directive
+// contacts, "mailto:<directive>@test", among the contacts. pass-validation and
+// fail-validation force the validation outcome, only-<challenge type> limits
+// the challenge types offered, slow-<milliseconds> adds a fixed latency and
+// fail-<endpoint> fails the requests to an endpoint. An error is returned for
+// unknown directives.
+func contactOverride(contacts []string) (AccountOverride, error) {
+	var o AccountOverride
+	for _, contact := range contacts {
+		parsed, err := url.Parse(contact)
+		if err != nil || parsed.Scheme != "mailto" {
+			continue
+		}
+		at := strings.LastIndex(parsed.Opaque, "@")
+		if at < 0 || !strings.EqualFold(parsed.Opaque[at+1:], contactDirectiveDomain) {
+			continue
+		}
+		directive := strings.ToLower(parsed.Opaque[:at])
+
+		switch {
+		case directive == "pass-validation" || directive == "fail-validation":
+			outcome := va.OutcomeValid
+			if directive == "fail-validation" {
+				outcome = va.OutcomeInvalid
+			}
+			if o.ValidationOutcome != "" && o.ValidationOutcome != outcome {
+				return o, fmt.Errorf("contact directives pass-validation and fail-validation conflict")
+			}
+			o.ValidationOutcome = outcome
+		case strings.HasPrefix(directive, "only-"):
+			o.ChallengeTypes = append(o.ChallengeTypes, strings.TrimPrefix(directive, "only-"))
+		case strings.HasPrefix(directive, "slow-"):
+			delay, err := strconv.Atoi(strings.TrimPrefix(directive, "slow-"))
+			if err != nil || delay < 0 {
+				return o, fmt.Errorf("contact directive %q must be slow-<milliseconds>", directive)
+			}
+			o.Latency = &LatencyProfile{Distribution: LatencyFixed, Delay: delay}
+		case strings.HasPrefix(directive, "fail-"):
+			endpoint := strings.TrimPrefix(directive, "fail-")
+			// Endpoint names are camel case but email addresses are
+			// compared case-insensitively
+			for _, name := range endpointNames {
+				if strings.EqualFold(name, endpoint) {
+					endpoint = name
+					break
+				}
+			}
+			o.FailEndpoints = append(o.FailEndpoints, endpoint)
+		default:
+			return o, fmt.Errorf("unknown contact directive %q", contact)
+		}
+	}
+	if err := o.check(); err != nil {
+		return o, fmt.Errorf("contact directive: %s", err)
+	}
+	return o, nil
+}
@@ -0,0 +1,264 @@
+package wfe
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+const (
+	// dashboardMaxRows is how many of the most recent orders, validations and
+	// certificates the dashboard shows.
+	dashboardMaxRows = 50
+
+	defaultDashboardRefresh = 5
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+{{if .Refresh}}<meta http-equiv="refresh" content="{{.Refresh}}">{{end}}
+<title>Pebble</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 1em; }
+td, th { border: 1px solid #ccc; padding: 4px; text-align: left; vertical-align: top; }
+.invalid, .deactivated, .revoked { color: #b00; }
+.valid { color: #070; }
+</style>
+</head>
+<body>
+<h1>Pebble</h1>
+<p>Generated {{.Generated}}.{{if .Refresh}} Refreshed every {{.Refresh}} seconds.{{end}}
+JSON: <a href="/dashboard.json">dashboard</a>, <a href="/store/">store</a>,
+<a href="/metrics">metrics</a>, <a href="/info">info</a>,
+<a href="/openapi.json">OpenAPI document</a>.</p>
+<h2>Accounts ({{len .Accounts}})</h2>
+<table>
+<tr><th>ID</th><th>Status</th><th>Contact</th><th>Namespace</th><th>Orders</th><th>JSON</th></tr>
+{{range .Accounts}}<tr>
+<td>{{.ID}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{range .Contact}}{{.}}<br>{{end}}</td><td>{{.Namespace}}</td><td>{{.Orders}}</td>
+<td><a href="/certificates?account={{.ID}}">certificates</a>{{if $.Failures}} <a href="/failures?account={{.ID}}">failures</a>{{end}}</td>
+</tr>{{end}}
+</table>
+<h2>Orders</h2>
+<table>
+<tr><th>ID</th><th>Account</th><th>Status</th><th>Identifiers</th><th>Expires</th><th>Error</th><th>Certificate</th>{{if .OrderReports}}<th>Report</th>{{end}}</tr>
+{{range .Orders}}<tr>
+<td>{{.ID}}</td><td>{{.Account}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{range .Identifiers}}{{.Type}}: {{.Value}}<br>{{end}}</td><td>{{.Expires}}</td>
+<td>{{with .Error}}{{.Type}}: {{.Detail}}{{end}}</td><td>{{.Certificate}}</td>
+{{if $.OrderReports}}<td><a href="/order-report/{{.ID}}">JSON</a> <a href="/order-report/{{.ID}}?format=html">HTML</a></td>{{end}}
+</tr>{{end}}
+</table>
+<h2>Validations</h2>
+<table>
+<tr><th>Completed</th><th>Identifier</th><th>Challenge</th><th>Status</th><th>Attempts</th><th>Error</th><th>JSON</th></tr>
+{{range .Validations}}<tr>
+<td>{{.Completed}}</td><td>{{.Identifier.Type}}: {{.Identifier.Value}}</td><td>{{.Type}}</td><td class="{{.Status}}">{{.Status}}</td><td>{{.Attempts}}</td>
+<td>{{with .Error}}{{.Type}}: {{.Detail}}{{end}}</td><td><a href="/challenge-timing/{{.Challenge}}">timing</a></td>
+</tr>{{end}}
+</table>
+<h2>Certificates</h2>
+<table>
+<tr><th>Serial</th><th>Account</th><th>Names</th><th>Not before</th><th>Not after</th><th>JSON</th></tr>
+{{range .Certificates}}<tr>
+<td>{{.Serial}}</td><td>{{.Account}}</td><td>{{range .Names}}{{.}}<br>{{end}}</td><td>{{.NotBefore}}</td><td>{{.NotAfter}}</td>
+<td><a href="/certificates?serialMin={{.Serial}}&serialMax={{.Serial}}">search</a></td>
+</tr>{{end}}
+</table>
+</body>
+</html>
+`))
+
+// dashboardPage is what the dashboard template is executed with.
+type dashboardPage struct {
+	acme.Dashboard
+	// Refresh is how often the page reloads itself in seconds, or zero.
+	Refresh int
+	// OrderReports and Failures are set if order reports and failures are
+	// recorded, so that the dashboard can link to them.
+	OrderReports bool
+	Failures     bool
+}
+
+// dashboard returns a snapshot of the store for the dashboard.
+func (wfe *WebFrontEndImpl) dashboard() acme.Dashboard {
+	d := acme.Dashboard{
+		Generated:    wfe.clk.Now().UTC().Format(time.RFC3339),
+		Accounts:     []acme.DashboardAccount{},
+		Orders:       []acme.DashboardOrder{},
+		Validations:  []acme.DashboardValidation{},
+		Certificates: []acme.DashboardCertificate{},
+	}
+
+	for _, acct := range wfe.db.GetAccounts() {
+		d.Accounts = append(d.Accounts, acme.DashboardAccount{
+			ID:        acct.ID,
+			Status:    acct.Status,
+			Contact:   acct.Contact,
+			Namespace: acct.Namespace,
+			Orders:    len(wfe.db.GetOrdersByAccountID(acct.ID)),
+		})
+	}
+	sort.Slice(d.Accounts, func(i, j int) bool {
+		return d.Accounts[i].ID < d.Accounts[j].ID
+	})
+
+	// Orders are sorted by expiry since they all have the same lifetime and
+	// don't record when they were created
+	orders := wfe.db.FindOrders(func(*core.Order) bool { return true })
+	expires := make(map[*core.Order]time.Time, len(orders))
+	for _, order := range orders {
+		order.RLock()
+		expires[order] = order.ExpiresDate
+		order.RUnlock()
+	}
+	sort.Slice(orders, func(i, j int) bool {
+		return expires[orders[i]].After(expires[orders[j]])
+	})
+	if len(orders) > dashboardMaxRows {
+		orders = orders[:dashboardMaxRows]
+	}
+	var authzs []*core.Authorization
+	seen := make(map[*core.Authorization]bool)
+	for _, order := range orders {
+		status, _ := order.GetStatus(wfe.clk)
+		order.RLock()
+		o := acme.DashboardOrder{
+			ID:          order.ID,
+			Account:     order.AccountID,
+			Status:      status,
+			Identifiers: order.Identifiers,
+			Expires:     order.Expires,
+			Error:       order.Error,
+		}
+		if order.CertificateObject != nil {
+			o.Certificate = order.CertificateObject.ID
+		}
+		for _, authz := range order.AuthorizationObjects {
+			if !seen[authz] {
+				seen[authz] = true
+				authzs = append(authzs, authz)
+			}
+		}
+		order.RUnlock()
+		d.Orders = append(d.Orders, o)
+	}
+
+	type validation struct {
+		acme.DashboardValidation
+		completed time.Time
+	}
+	var validations []validation
+	for _, authz := range authzs {
+		authz.RLock()
+		ident, chals := authz.Identifier, authz.Challenges
+		authz.RUnlock()
+		for _, c := range chals {
+			chal := wfe.db.GetChallengeByID(path.Base(c.URL))
+			if chal == nil {
+				continue
+			}
+			chal.RLock()
+			if chal.ValidationCompleted != "" {
+				v := validation{DashboardValidation: acme.DashboardValidation{
+					Challenge:  chal.ID,
+					Type:       chal.Type,
+					Identifier: ident,
+					Status:     chal.Status,
+					Completed:  chal.ValidationCompleted,
+					Attempts:   len(chal.Attempts),
+					Error:      chal.Error,
+				}}
+				v.completed, _ = time.Parse(time.RFC3339Nano, chal.ValidationCompleted)
+				validations = append(validations, v)
+			}
+			chal.RUnlock()
+		}
+	}
+	sort.Slice(validations, func(i, j int) bool {
+		return validations[i].completed.After(validations[j].completed)
+	})
+	if len(validations) > dashboardMaxRows {
+		validations = validations[:dashboardMaxRows]
+	}
+	for _, v := range validations {
+		d.Validations = append(d.Validations, v.DashboardValidation)
+	}
+
+	// The store also holds the issuers' certificates, which have no account
+	var certs []*core.Certificate
+	for _, cert := range wfe.db.GetCertificates() {
+		if cert.AccountID != "" {
+			certs = append(certs, cert)
+		}
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].Cert.NotBefore.After(certs[j].Cert.NotBefore)
+	})
+	if len(certs) > dashboardMaxRows {
+		certs = certs[:dashboardMaxRows]
+	}
+	for _, cert := range certs {
+		names := append([]string{}, cert.Cert.DNSNames...)
+		for _, ip := range cert.Cert.IPAddresses {
+			names = append(names, ip.String())
+		}
+		d.Certificates = append(d.Certificates, acme.DashboardCertificate{
+			Serial:    cert.ID,
+			Account:   cert.AccountID,
+			Names:     names,
+			NotBefore: cert.Cert.NotBefore.UTC().Format(time.RFC3339),
+			NotAfter:  cert.Cert.NotAfter.UTC().Format(time.RFC3339),
+		})
+	}
+	return d
+}
+
+// Dashboard serves an HTML page showing the accounts and the most recent
+// orders, validations and certificates of the store, with links to the JSON
+// of the management endpoints describing them. The page reloads itself every
+// refresh seconds, 5 by default.
+func (wfe *WebFrontEndImpl) Dashboard(response http.ResponseWriter, request *http.Request) {
+	page := dashboardPage{
+		Dashboard:    wfe.dashboard(),
+		Refresh:      defaultDashboardRefresh,
+		OrderReports: wfe.config.OrderReports != nil,
+		Failures:     wfe.failures != nil,
+	}
+	if r := request.URL.Query().Get("refresh"); r != "" {
+		refresh, err := strconv.Atoi(r)
+		if err != nil || refresh < 0 {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Invalid refresh %q, must be a number of seconds", r)), response)
+			return
+		}
+		page.Refresh = refresh
+	}
+
+	var buf bytes.Buffer
+	if err := dashboardTemplate.Execute(&buf, page); err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error rendering dashboard"), response)
+		return
+	}
+	response.Header().Set("Content-Type", "text/html; charset=utf-8")
+	response.WriteHeader(http.StatusOK)
+	_, _ = response.Write(buf.Bytes())
+}
+
+// DashboardJSON returns the snapshot of the store shown by the dashboard.
+func (wfe *WebFrontEndImpl) DashboardJSON(response http.ResponseWriter, request *http.Request) {
+	err := wfe.writeJsonResponse(response, http.StatusOK, wfe.dashboard())
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling dashboard"), response)
+	}
+}
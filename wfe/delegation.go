@@ -0,0 +1,215 @@
+package wfe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+// csrTemplateAny is the CSR template value that allows any value chosen by the
+// client.
+const csrTemplateAny = "**"
+
+// Delegations serves the list of delegation URLs of the account with the ID
+// given in the request path (RFC 9115 Section 2.3.1.3).
+func (wfe *WebFrontEndImpl) Delegations(
+	ctx context.Context,
+	logEvent *requestEvent,
+	response http.ResponseWriter,
+	request *http.Request) {
+
+	acctID := strings.TrimPrefix(request.URL.Path, delegationsPath)
+	if wfe.db.GetAccountByID(acctID) == nil {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	list := struct {
+		Delegations []string `json:"delegations"`
+	}{Delegations: []string{}}
+	for _, d := range wfe.db.GetDelegationsByAccountID(acctID) {
+		list.Delegations = append(list.Delegations, wfe.relativeEndpoint(request, delegationPath+d.ID))
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, list)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling delegations"), response)
+		return
+	}
+}
+
+// Delegation serves the delegation object with the ID given in the request
+// path.
+func (wfe *WebFrontEndImpl) Delegation(
+	ctx context.Context,
+	logEvent *requestEvent,
+	response http.ResponseWriter,
+	request *http.Request) {
+
+	delegation := wfe.db.GetDelegationByID(strings.TrimPrefix(request.URL.Path, delegationPath))
+	if delegation == nil {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, delegation.Delegation)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling delegation"), response)
+		return
+	}
+}
+
+// AddDelegation stores a delegation for an account. It is used by the
+// management interface since delegations are configured out of band.
+func (wfe *WebFrontEndImpl) AddDelegation(accountID string, d acme.Delegation) (*core.Delegation, error) {
+	if !wfe.config.EnableDelegation {
+		return nil, fmt.Errorf("delegation is not enabled")
+	}
+	if wfe.db.GetAccountByID(accountID) == nil {
+		return nil, fmt.Errorf("no account with ID %q", accountID)
+	}
+	if d.CSRTemplate == nil {
+		return nil, fmt.Errorf("delegation must have a csr-template")
+	}
+
+	delegation := &core.Delegation{
+		Delegation: d,
+		ID:         newToken(),
+		AccountID:  accountID,
+	}
+	if _, err := wfe.db.AddDelegation(delegation); err != nil {
+		return nil, err
+	}
+	wfe.log.Printf("Added delegation %q for account %q\n", delegation.ID, accountID)
+	return delegation, nil
+}
+
+// applyDelegation checks and applies the RFC 9115 fields of a new order
+// request to the order.
+func (wfe *WebFrontEndImpl) applyDelegation(order *core.Order, newOrder acme.Order) *acme.ProblemDetails {
+	if !wfe.config.EnableDelegation {
+		return acme.MalformedProblem("Delegation and allow-certificate-get are not enabled")
+	}
+	order.AllowCertificateGet = newOrder.AllowCertificateGet
+	if newOrder.Delegation == "" {
+		return nil
+	}
+
+	i := strings.LastIndex(newOrder.Delegation, delegationPath)
+	if i < 0 {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order delegation %q is not a delegation URL", newOrder.Delegation))
+	}
+	delegation := wfe.db.GetDelegationByID(newOrder.Delegation[i+len(delegationPath):])
+	if delegation == nil || delegation.AccountID != order.AccountID {
+		return acme.UnauthorizedProblem(fmt.Sprintf(
+			"Order delegation %q doesn't exist or belongs to another account", newOrder.Delegation))
+	}
+
+	for _, ident := range order.Identifiers {
+		if ident.Type != acme.IdentifierDNS ||
+			!matchCSRTemplateValues(delegation.CSRTemplate.Extensions.SubjectAltName.DNSName, ident.Value) {
+			return acme.UnauthorizedProblem(fmt.Sprintf(
+				"Identifier %q isn't allowed by the delegation's CSR template", ident.Value))
+		}
+	}
+
+	order.Delegation = newOrder.Delegation
+	order.DelegationObject = delegation
+	return nil
+}
+
+// matchCSRTemplateValues returns true if the value is allowed by one of the
+// CSR template values.
+func matchCSRTemplateValues(templateValues []string, value string) bool {
+	for _, t := range templateValues {
+		if t == csrTemplateAny || strings.EqualFold(t, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCSRTemplate returns an error if the CSR's key type, subject or DNS
+// names aren't allowed by the CSR template.
+func checkCSRTemplate(template *acme.CSRTemplate, csr *x509.CertificateRequest) error {
+	if len(template.KeyTypes) > 0 {
+		var allowed bool
+		for _, kt := range template.KeyTypes {
+			if matchCSRTemplateKeyType(kt, csr.PublicKey) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("key type isn't allowed")
+		}
+	}
+
+	for attr, want := range template.Subject {
+		got, err := subjectAttribute(csr.Subject, attr)
+		if err != nil {
+			return err
+		}
+		if want != csrTemplateAny && got != want {
+			return fmt.Errorf("subject %s is %q, expected %q", attr, got, want)
+		}
+	}
+
+	for _, name := range csr.DNSNames {
+		if !matchCSRTemplateValues(template.Extensions.SubjectAltName.DNSName, name) {
+			return fmt.Errorf("DNS name %q isn't allowed", name)
+		}
+	}
+	return nil
+}
+
+func matchCSRTemplateKeyType(kt acme.CSRTemplateKeyType, key interface{}) bool {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return kt.PublicKeyType == "rsaEncryption"
+	case *ecdsa.PublicKey:
+		curves := map[string]string{
+			"secp256r1": "P-256",
+			"secp384r1": "P-384",
+			"secp521r1": "P-521",
+		}
+		return kt.PublicKeyType == "id-ecPublicKey" &&
+			(kt.NamedCurve == "" || curves[kt.NamedCurve] == k.Curve.Params().Name)
+	case ed25519.PublicKey:
+		return kt.PublicKeyType == "id-Ed25519"
+	}
+	return false
+}
+
+// subjectAttribute returns the value of the named attribute of a subject,
+// using the attribute names of RFC 9115 CSR templates.
+func subjectAttribute(subject pkix.Name, attr string) (string, error) {
+	var values []string
+	switch attr {
+	case "commonName":
+		return subject.CommonName, nil
+	case "country":
+		values = subject.Country
+	case "stateOrProvince":
+		values = subject.Province
+	case "locality":
+		values = subject.Locality
+	case "organization":
+		values = subject.Organization
+	case "organizationalUnit":
+		values = subject.OrganizationalUnit
+	default:
+		return "", fmt.Errorf("unsupported CSR template subject attribute %q", attr)
+	}
+	return strings.Join(values, ", "), nil
+}
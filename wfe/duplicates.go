@@ -0,0 +1,144 @@
+package wfe
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+// DuplicateCertificateLimit limits how many certificates are issued for the
+// same exact set of identifiers within a window, like Let's Encrypt's
+// duplicate certificate limit.
+type DuplicateCertificateLimit struct {
+	// Window is how long issued certificates count towards the limit. Zero
+	// disables the limit.
+	Window time.Duration
+	// Limit is the number of certificates allowed for a set of identifiers
+	// within the window. Zero means one, rejecting any re-issuance.
+	Limit int
+}
+
+// duplicateCertificates remembers when certificates were issued for each set
+// of identifiers, to reject new orders and finalizations for a set that
+// reached the limit within the window.
+type duplicateCertificates struct {
+	sync.Mutex
+	window time.Duration
+	limit  int
+	clk    clock.Clock
+
+	// issued maps the keys of identifier sets to the times certificates were
+	// issued for them, oldest first.
+	issued    map[string][]time.Time
+	nextPrune time.Time
+}
+
+func newDuplicateCertificates(config DuplicateCertificateLimit, clk clock.Clock) *duplicateCertificates {
+	if config.Window <= 0 {
+		return nil
+	}
+	limit := config.Limit
+	if limit <= 0 {
+		limit = 1
+	}
+	return &duplicateCertificates{
+		window: config.Window,
+		limit:  limit,
+		clk:    clk,
+		issued: make(map[string][]time.Time),
+	}
+}
+
+// recent returns the issuance times of the key within the window, pruning
+// the times outside of it. The caller must hold the lock.
+func (d *duplicateCertificates) recent(key string, now time.Time) []time.Time {
+	if now.After(d.nextPrune) {
+		for k, times := range d.issued {
+			if len(times) == 0 || now.Sub(times[len(times)-1]) > d.window {
+				delete(d.issued, k)
+			}
+		}
+		d.nextPrune = now.Add(d.window)
+	}
+	times := d.issued[key]
+	for len(times) > 0 && now.Sub(times[0]) > d.window {
+		times = times[1:]
+	}
+	if len(times) == 0 {
+		delete(d.issued, key)
+	} else {
+		d.issued[key] = times
+	}
+	return times
+}
+
+// record records that a certificate was issued for the identifiers in the
+// namespace. It does nothing for a nil duplicateCertificates.
+func (d *duplicateCertificates) record(namespace string, idents []acme.Identifier) {
+	if d == nil {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+	now := d.clk.Now()
+	key := namespaced(namespace, identifierSetKey(idents))
+	d.issued[key] = append(d.recent(key, now), now)
+}
+
+// check returns when another certificate can be issued for the identifiers in
+// the namespace, or the zero time if one can be issued now. It always returns
+// the zero time for a nil duplicateCertificates.
+func (d *duplicateCertificates) check(namespace string, idents []acme.Identifier) time.Time {
+	if d == nil {
+		return time.Time{}
+	}
+	d.Lock()
+	defer d.Unlock()
+	times := d.recent(namespaced(namespace, identifierSetKey(idents)), d.clk.Now())
+	if len(times) < d.limit {
+		return time.Time{}
+	}
+	return times[len(times)-d.limit].Add(d.window)
+}
+
+// checkDuplicateCertificates returns a rateLimited problem, and sets the
+// Retry-After header, if the duplicate certificate limit doesn't allow
+// another certificate for the order's identifiers. Orders replacing a
+// certificate are exempt, as ARI renewals are at Let's Encrypt.
+func (wfe *WebFrontEndImpl) checkDuplicateCertificates(
+	order *core.Order,
+	response http.ResponseWriter) *acme.ProblemDetails {
+	prob, retryAfter := wfe.duplicateCertificatesProblem(order)
+	if prob != nil {
+		wait := retryAfter.Sub(wfe.clk.Now())
+		response.Header().Set("Retry-After", strconv.Itoa(int((wait+time.Second-1)/time.Second)))
+	}
+	return prob
+}
+
+// duplicateCertificatesProblem returns the problem checkDuplicateCertificates
+// would return for the order and when another certificate can be issued.
+func (wfe *WebFrontEndImpl) duplicateCertificatesProblem(order *core.Order) (*acme.ProblemDetails, time.Time) {
+	order.RLock()
+	idents := order.Identifiers
+	replaces := order.ReplacesObject
+	namespace := order.Namespace
+	order.RUnlock()
+	if replaces != nil {
+		return nil, time.Time{}
+	}
+	retryAfter := wfe.duplicates.check(namespace, idents)
+	if retryAfter.IsZero() {
+		return nil, time.Time{}
+	}
+	return acme.RateLimitedProblem(fmt.Sprintf(
+		"Too many certificates (%d) already issued for this exact set of identifiers in the last %s, retry after %s",
+		wfe.duplicates.limit, wfe.duplicates.window, retryAfter.UTC().Format(time.RFC3339))), retryAfter
+}
@@ -0,0 +1,327 @@
+package wfe
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/cryptomode"
+)
+
+// eabKeyStatusRevoked is the status of a revoked external account binding
+// key. Other keys are acme.StatusValid.
+const eabKeyStatusRevoked = "revoked"
+
+// eabKey is an external account binding MAC key and the accounts created
+// with it.
+type eabKey struct {
+	hmacKey  []byte
+	revoked  bool
+	accounts []string
+}
+
+// eabTable holds the external account binding MAC keys, keyed by key ID, and
+// the key ID each account was created with.
+type eabTable struct {
+	sync.RWMutex
+	keys     map[string]*eabKey
+	accounts map[string]string
+}
+
+// newEABTable returns a table with the MAC keys, given as base64url encoded
+// keys by key ID.
+func newEABTable(keys map[string]string) (*eabTable, error) {
+	t := &eabTable{
+		keys:     make(map[string]*eabKey, len(keys)),
+		accounts: make(map[string]string),
+	}
+	for keyID, encoded := range keys {
+		if _, err := t.rotate(keyID, encoded); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// decodeEABKey decodes a base64url encoded MAC key, with or without padding.
+func decodeEABKey(encoded string) ([]byte, error) {
+	hmacKey, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, fmt.Errorf("MAC key isn't base64url encoded: %s", err)
+	}
+	if len(hmacKey) == 0 {
+		return nil, fmt.Errorf("MAC key is empty")
+	}
+	return hmacKey, nil
+}
+
+// rotate adds the key ID with the base64url encoded MAC key or, if the key ID
+// exists, replaces its MAC key and reinstates it if it was revoked. An empty
+// MAC key is replaced with a random 256-bit key. The accounts created with
+// the key ID are kept.
+func (t *eabTable) rotate(keyID, encoded string) (acme.ExternalAccountKey, error) {
+	if keyID == "" {
+		return acme.ExternalAccountKey{}, fmt.Errorf("external account key with an empty key ID")
+	}
+	var hmacKey []byte
+	if encoded == "" {
+		hmacKey = make([]byte, 32)
+		if _, err := io.ReadFull(cryptomode.Reader(), hmacKey); err != nil {
+			return acme.ExternalAccountKey{}, err
+		}
+	} else {
+		var err error
+		if hmacKey, err = decodeEABKey(encoded); err != nil {
+			return acme.ExternalAccountKey{}, fmt.Errorf("external account key %q: %s", keyID, err)
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	k, ok := t.keys[keyID]
+	if !ok {
+		k = &eabKey{}
+		t.keys[keyID] = k
+	}
+	k.hmacKey = hmacKey
+	k.revoked = false
+	return k.summary(keyID), nil
+}
+
+// revoke revokes the key ID, so that new accounts can't be created with it.
+// Accounts already created with it are unaffected.
+func (t *eabTable) revoke(keyID string) (acme.ExternalAccountKey, error) {
+	t.Lock()
+	defer t.Unlock()
+	k, ok := t.keys[keyID]
+	if !ok {
+		return acme.ExternalAccountKey{}, fmt.Errorf("unknown external account key %q", keyID)
+	}
+	k.revoked = true
+	return k.summary(keyID), nil
+}
+
+func (k *eabKey) summary(keyID string) acme.ExternalAccountKey {
+	status := acme.StatusValid
+	if k.revoked {
+		status = eabKeyStatusRevoked
+	}
+	return acme.ExternalAccountKey{
+		KeyID:    keyID,
+		HMACKey:  base64.RawURLEncoding.EncodeToString(k.hmacKey),
+		Status:   status,
+		Accounts: append([]string{}, k.accounts...),
+	}
+}
+
+// lookup returns the MAC key of the key ID, or a problem if there is no such
+// key or it has been revoked.
+func (t *eabTable) lookup(keyID string) ([]byte, *acme.ProblemDetails) {
+	t.RLock()
+	defer t.RUnlock()
+	k, ok := t.keys[keyID]
+	if !ok {
+		return nil, acme.UnauthorizedProblem(fmt.Sprintf(
+			"Unknown external account binding key ID %q", keyID))
+	}
+	if k.revoked {
+		return nil, acme.UnauthorizedProblem(fmt.Sprintf(
+			"External account binding key ID %q has been revoked", keyID))
+	}
+	return k.hmacKey, nil
+}
+
+// empty returns true if there are no keys, in which case bindings aren't
+// verified.
+func (t *eabTable) empty() bool {
+	t.RLock()
+	defer t.RUnlock()
+	return len(t.keys) == 0
+}
+
+// bind records that the account was created with the key ID.
+func (t *eabTable) bind(keyID, acctID string) {
+	t.Lock()
+	defer t.Unlock()
+	t.accounts[acctID] = keyID
+	if k, ok := t.keys[keyID]; ok {
+		k.accounts = append(k.accounts, acctID)
+	}
+}
+
+func (t *eabTable) bindings() acme.ExternalAccountBindings {
+	t.RLock()
+	defer t.RUnlock()
+	result := acme.ExternalAccountBindings{
+		Keys:     make([]acme.ExternalAccountKey, 0, len(t.keys)),
+		Accounts: make(map[string]string, len(t.accounts)),
+	}
+	for keyID, k := range t.keys {
+		result.Keys = append(result.Keys, k.summary(keyID))
+	}
+	sort.Slice(result.Keys, func(i, j int) bool {
+		return result.Keys[i].KeyID < result.Keys[j].KeyID
+	})
+	for acctID, keyID := range t.accounts {
+		result.Accounts[acctID] = keyID
+	}
+	return result
+}
+
+// verifyExternalAccountBinding checks the externalAccountBinding of a
+// new-account request as described in RFC 8555 Section 7.3.4 and returns its
+// key ID. If there are no MAC keys the binding isn't verified, but the key ID
+// in its protected header is still returned if it can be parsed.
+func (wfe *WebFrontEndImpl) verifyExternalAccountBinding(
+	request *http.Request,
+	binding json.RawMessage,
+	acctKey *jose.JSONWebKey) (string, *acme.ProblemDetails) {
+	eabJWS, err := wfe.parseJWS(string(binding))
+	if wfe.eab.empty() {
+		if err != nil {
+			return "", nil
+		}
+		return eabJWS.Signatures[0].Header.KeyID, nil
+	}
+	if err != nil {
+		return "", acme.MalformedProblem("External account binding: " + err.Error())
+	}
+	header := eabJWS.Signatures[0].Header
+
+	switch jose.SignatureAlgorithm(header.Algorithm) {
+	case jose.HS256, jose.HS384, jose.HS512:
+	default:
+		return "", acme.MalformedProblem(fmt.Sprintf(
+			"External account binding must be signed with a MAC algorithm, not %q", header.Algorithm))
+	}
+	if header.KeyID == "" {
+		return "", acme.MalformedProblem("External account binding has no key ID")
+	}
+	if header.Nonce != "" {
+		return "", acme.MalformedProblem("External account binding must not have a nonce")
+	}
+	eabURL, _ := header.ExtraHeaders[jose.HeaderKey("url")].(string)
+	if expectedURL := wfe.expectedJWSURL(request); eabURL != expectedURL {
+		return "", acme.MalformedProblem(fmt.Sprintf(
+			"External account binding header parameter 'url' incorrect. Expected %q, got %q",
+			expectedURL, eabURL))
+	}
+
+	hmacKey, prob := wfe.eab.lookup(header.KeyID)
+	if prob != nil {
+		return "", prob
+	}
+	payload, err := eabJWS.Verify(hmacKey)
+	if err != nil {
+		return "", acme.UnauthorizedProblem("External account binding signature is invalid")
+	}
+	var boundKey jose.JSONWebKey
+	if err := json.Unmarshal(payload, &boundKey); err != nil {
+		return "", acme.MalformedProblem("External account binding payload isn't a JWK")
+	}
+	if !keyDigestEquals(&boundKey, acctKey) {
+		return "", acme.MalformedProblem(
+			"External account binding payload doesn't match the account key")
+	}
+	return header.KeyID, nil
+}
+
+// ExternalAccountBindings returns the external account binding keys and the
+// key ID each account was created with.
+func (wfe *WebFrontEndImpl) ExternalAccountBindings() acme.ExternalAccountBindings {
+	return wfe.eab.bindings()
+}
+
+// RotateExternalAccountKey adds or replaces the MAC key of the key ID. An
+// empty MAC key is replaced with a random one.
+func (wfe *WebFrontEndImpl) RotateExternalAccountKey(keyID, hmacKey string) (acme.ExternalAccountKey, error) {
+	return wfe.eab.rotate(keyID, hmacKey)
+}
+
+// RevokeExternalAccountKey revokes the key ID, so that creating an account
+// with it fails.
+func (wfe *WebFrontEndImpl) RevokeExternalAccountKey(keyID string) (acme.ExternalAccountKey, error) {
+	return wfe.eab.revoke(keyID)
+}
+
+// externalAccountKeyRequest is the body of a management request to rotate or
+// revoke an external account binding key.
+type externalAccountKeyRequest struct {
+	KeyID   string `json:"keyID"`
+	HMACKey string `json:"hmacKey,omitempty"`
+}
+
+func (wfe *WebFrontEndImpl) readExternalAccountKeyRequest(
+	response http.ResponseWriter,
+	request *http.Request) (externalAccountKeyRequest, bool) {
+	var req externalAccountKeyRequest
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return req, false
+	}
+	if err := json.Unmarshal(body, &req); err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error unmarshaling external account key request: %s", err.Error())), response)
+		return req, false
+	}
+	return req, true
+}
+
+// ExternalAccountKeys returns the acme.ExternalAccountBindings for a GET
+// request. A POST request adds or rotates the key in the JSON
+// externalAccountKeyRequest in its body and returns the key's
+// acme.ExternalAccountKey.
+func (wfe *WebFrontEndImpl) ExternalAccountKeys(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "POST" {
+		err := wfe.writeJsonResponse(response, http.StatusOK, wfe.ExternalAccountBindings())
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("Error marshalling external account keys"), response)
+		}
+		return
+	}
+
+	req, ok := wfe.readExternalAccountKeyRequest(response, request)
+	if !ok {
+		return
+	}
+	key, err := wfe.RotateExternalAccountKey(req.KeyID, req.HMACKey)
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem(err.Error()), response)
+		return
+	}
+	wfe.log.Printf("management: rotated external account key %q\n", req.KeyID)
+	err = wfe.writeJsonResponse(response, http.StatusOK, key)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling external account key"), response)
+	}
+}
+
+// RevokeExternalAccountKeyHandler revokes the key in the JSON
+// externalAccountKeyRequest in the body of a POST request and returns its
+// acme.ExternalAccountKey.
+func (wfe *WebFrontEndImpl) RevokeExternalAccountKeyHandler(response http.ResponseWriter, request *http.Request) {
+	req, ok := wfe.readExternalAccountKeyRequest(response, request)
+	if !ok {
+		return
+	}
+	key, err := wfe.RevokeExternalAccountKey(req.KeyID)
+	if err != nil {
+		wfe.sendError(acme.NotFoundProblem(err.Error()), response)
+		return
+	}
+	wfe.log.Printf("management: revoked external account key %q\n", req.KeyID)
+	err = wfe.writeJsonResponse(response, http.StatusOK, key)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling external account key"), response)
+	}
+}
@@ -0,0 +1,146 @@
+package wfe
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	// EncodingFaultMismatch labels response bodies with the negotiated
+	// Content-Encoding without encoding them.
+	EncodingFaultMismatch = "mismatch"
+	// EncodingFaultTruncated leaves the end of the encoded stream out of
+	// response bodies, so decoding them fails with an unexpected EOF.
+	EncodingFaultTruncated = "truncated"
+)
+
+// responseEncodings are the supported content codings, most preferred first.
+// "deflate" is the zlib format (RFC 9110 Section 8.4.1.2).
+var responseEncodings = []string{"gzip", "deflate"}
+
+// qValues returns the weight of each value of an Accept style header, keyed
+// by the lowercased value without its parameters. Values without a q
+// parameter weigh 1.
+func qValues(header string) map[string]float64 {
+	weights := make(map[string]float64)
+	for _, accepted := range strings.Split(header, ",") {
+		params := strings.Split(accepted, ";")
+		value := strings.ToLower(strings.TrimSpace(params[0]))
+		if value == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if parsed, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if prev, ok := weights[value]; !ok || q > prev {
+			weights[value] = q
+		}
+	}
+	return weights
+}
+
+// responseEncoding returns the content coding preferred by a request's
+// Accept-Encoding header, or "" if it accepts none of responseEncodings.
+func responseEncoding(acceptEncoding string) string {
+	weights := qValues(acceptEncoding)
+	var best string
+	bestQ := 0.0
+	for _, encoding := range responseEncodings {
+		q, ok := weights[encoding]
+		if !ok {
+			q = weights["*"]
+		}
+		if q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// encoder is the interface shared by gzip.Writer and zlib.Writer.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// encodingWriter encodes the body of a response with a content coding,
+// optionally breaking the encoding as configured by fault.
+type encodingWriter struct {
+	http.ResponseWriter
+	encoding    string
+	fault       string
+	encoder     encoder
+	wroteHeader bool
+}
+
+func (w *encodingWriter) WriteHeader(status int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if status != http.StatusNoContent && status != http.StatusNotModified {
+		// The encoded length isn't known in advance
+		w.Header().Del("Content-Length")
+		w.Header().Set("Content-Encoding", w.encoding)
+	} else {
+		w.encoding = ""
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *encodingWriter) Write(body []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if w.encoding == "" || w.fault == EncodingFaultMismatch {
+		return w.ResponseWriter.Write(body)
+	}
+	w.startEncoder()
+	return w.encoder.Write(body)
+}
+
+func (w *encodingWriter) startEncoder() {
+	if w.encoder != nil {
+		return
+	}
+	if w.encoding == "gzip" {
+		w.encoder = gzip.NewWriter(w.ResponseWriter)
+	} else {
+		w.encoder = zlib.NewWriter(w.ResponseWriter)
+	}
+}
+
+func (w *encodingWriter) Flush() {
+	if w.encoder != nil {
+		_ = w.encoder.Flush()
+	}
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close finishes the encoded body. With the truncated fault the buffered data
+// is written without the end of the stream.
+func (w *encodingWriter) Close() {
+	if !w.wroteHeader || w.encoding == "" || w.fault == EncodingFaultMismatch {
+		return
+	}
+	// An empty body still needs an encoded stream to match its
+	// Content-Encoding
+	w.startEncoder()
+	if w.fault == EncodingFaultTruncated {
+		_ = w.encoder.Flush()
+		return
+	}
+	_ = w.encoder.Close()
+}
@@ -0,0 +1,207 @@
+package wfe
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/db"
+	"github.com/letsencrypt/pebble/events"
+)
+
+// failure is an order or authorization that became invalid. It references
+// the object itself, so that it is kept along with its errors and validation
+// attempts even if it is removed from the store.
+type failure struct {
+	time  time.Time
+	kind  string
+	id    string
+	order *core.Order
+	authz *core.Authorization
+}
+
+// failureArchive keeps the orders and authorizations that became invalid for
+// a retention period, for post-mortems of test runs.
+type failureArchive struct {
+	sync.Mutex
+	clk       clock.Clock
+	db        *db.MemoryStore
+	retention time.Duration
+	failures  []*failure
+}
+
+func newFailureArchive(clk clock.Clock, db *db.MemoryStore, retention time.Duration) *failureArchive {
+	return &failureArchive{clk: clk, db: db, retention: retention}
+}
+
+// observe records invalid order and authorization events. The objects are
+// looked up separately since the publisher may hold their locks.
+func (a *failureArchive) observe(e events.Event) {
+	if e.Status != acme.StatusInvalid ||
+		(e.Type != events.TypeOrder && e.Type != events.TypeAuthorization) {
+		return
+	}
+	f := &failure{time: a.clk.Now(), kind: e.Type, id: e.ID}
+	a.Lock()
+	a.prune()
+	a.failures = append(a.failures, f)
+	a.Unlock()
+	go a.resolve(f)
+}
+
+// resolve looks up the object of a failure.
+func (a *failureArchive) resolve(f *failure) {
+	var order *core.Order
+	var authz *core.Authorization
+	if f.kind == events.TypeOrder {
+		order, _ = a.db.GetOrderByID(f.id)
+	} else {
+		authz = a.db.GetAuthorizationByID(f.id)
+	}
+	a.Lock()
+	defer a.Unlock()
+	f.order, f.authz = order, authz
+}
+
+// prune forgets the failures older than the retention period. The archive
+// must be locked.
+func (a *failureArchive) prune() {
+	cutoff := a.clk.Now().Add(-a.retention)
+	i := 0
+	for i < len(a.failures) && a.failures[i].time.Before(cutoff) {
+		i++
+	}
+	a.failures = a.failures[i:]
+}
+
+// find returns the failures since the given time of the account's objects, or
+// of every account's if acctID is empty.
+func (a *failureArchive) find(acctID string, since time.Time) []failure {
+	a.Lock()
+	a.prune()
+	var found []failure
+	for _, f := range a.failures {
+		if !f.time.Before(since) {
+			found = append(found, *f)
+		}
+	}
+	a.Unlock()
+
+	if acctID == "" {
+		return found
+	}
+	matching := found[:0]
+	for _, f := range found {
+		if failureAccount(f) == acctID {
+			matching = append(matching, f)
+		}
+	}
+	return matching
+}
+
+// failureAccount returns the ID of the account of a failure's object.
+func failureAccount(f failure) string {
+	order := f.order
+	if f.authz != nil {
+		f.authz.RLock()
+		order = f.authz.Order
+		f.authz.RUnlock()
+	}
+	if order == nil {
+		return ""
+	}
+	order.RLock()
+	defer order.RUnlock()
+	return order.AccountID
+}
+
+func describeAuthzFailure(authz *core.Authorization) acme.AuthorizationFailure {
+	authz.RLock()
+	defer authz.RUnlock()
+	result := acme.AuthorizationFailure{
+		ID:         authz.ID,
+		Identifier: authz.Identifier,
+		Status:     authz.Status,
+		Challenges: []acme.ChallengeFailure{},
+	}
+	for _, chal := range authz.Challenges {
+		result.Challenges = append(result.Challenges, acme.ChallengeFailure{
+			Type:     chal.Type,
+			Status:   chal.Status,
+			Error:    chal.Error,
+			Attempts: chal.Attempts,
+		})
+	}
+	return result
+}
+
+func describeFailure(f failure) acme.FailureReport {
+	report := acme.FailureReport{
+		Time:           f.time,
+		Type:           f.kind,
+		ID:             f.id,
+		Account:        failureAccount(f),
+		Authorizations: []acme.AuthorizationFailure{},
+	}
+	if f.authz != nil {
+		report.Authorizations = append(report.Authorizations, describeAuthzFailure(f.authz))
+		f.authz.RLock()
+		if f.authz.Order != nil {
+			report.Order = f.authz.Order.ID
+		}
+		f.authz.RUnlock()
+	}
+	if f.order != nil {
+		f.order.RLock()
+		report.Error = f.order.Error
+		report.Identifiers = f.order.Identifiers
+		authzs := f.order.AuthorizationObjects
+		f.order.RUnlock()
+		for _, authz := range authzs {
+			report.Authorizations = append(report.Authorizations, describeAuthzFailure(authz))
+		}
+	}
+	return report
+}
+
+// Failures returns the orders and authorizations that became invalid, with
+// their errors and the validation attempts of their challenges. The optional
+// "account" query parameter (an account ID or URL) limits them to one
+// account's, and "since" (an RFC 3339 time) to those that failed since then.
+func (wfe *WebFrontEndImpl) Failures(response http.ResponseWriter, request *http.Request) {
+	if wfe.failures == nil {
+		wfe.sendError(acme.NotFoundProblem(
+			"Failures aren't retained, set a failure retention period"), response)
+		return
+	}
+
+	params := request.URL.Query()
+	acctID := params.Get("account")
+	if acctID != "" {
+		acctID = wfe.parseAccountURL(acctID)
+	}
+	var since time.Time
+	if s := params.Get("since"); s != "" {
+		var err error
+		since, err = time.Parse(time.RFC3339, s)
+		if err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Invalid since time %q: %s", s, err)), response)
+			return
+		}
+	}
+
+	reports := []acme.FailureReport{}
+	for _, f := range wfe.failures.find(acctID, since) {
+		reports = append(reports, describeFailure(f))
+	}
+	err := wfe.writeJsonResponse(response, http.StatusOK, reports)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling failures"), response)
+		return
+	}
+}
@@ -0,0 +1,142 @@
+package wfe
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+// maxFinalizeAttempts is how many of the CSRs submitted to finalize an order
+// are kept for inspection.
+const maxFinalizeAttempts = 10
+
+// recordFinalizeAttempt keeps the csr field of a finalize request for the
+// order along with the problem it was rejected with, if any.
+func (wfe *WebFrontEndImpl) recordFinalizeAttempt(order *core.Order, csr string, prob *acme.ProblemDetails) {
+	order.Lock()
+	defer order.Unlock()
+	order.FinalizeAttempts = append(order.FinalizeAttempts, core.FinalizeAttempt{
+		Time:    wfe.clk.Now(),
+		CSR:     csr,
+		Problem: prob,
+	})
+	if len(order.FinalizeAttempts) > maxFinalizeAttempts {
+		order.FinalizeAttempts = order.FinalizeAttempts[len(order.FinalizeAttempts)-maxFinalizeAttempts:]
+	}
+}
+
+// csrExtension describes an extension requested in a CSR.
+type csrExtension struct {
+	ID       string `json:"id"`
+	Critical bool   `json:"critical,omitempty"`
+	Value    string `json:"value"`
+}
+
+// parsedCSR describes the contents of a CSR.
+type parsedCSR struct {
+	Subject            string         `json:"subject"`
+	DNSNames           []string       `json:"dnsNames,omitempty"`
+	IPAddresses        []string       `json:"ipAddresses,omitempty"`
+	EmailAddresses     []string       `json:"emailAddresses,omitempty"`
+	URIs               []string       `json:"uris,omitempty"`
+	PublicKeyAlgorithm string         `json:"publicKeyAlgorithm"`
+	KeyType            string         `json:"keyType,omitempty"`
+	SignatureAlgorithm string         `json:"signatureAlgorithm"`
+	SignatureValid     bool           `json:"signatureValid"`
+	Extensions         []csrExtension `json:"extensions,omitempty"`
+}
+
+// finalizeAttempt describes a CSR submitted to finalize an order.
+type finalizeAttempt struct {
+	Time    time.Time            `json:"time"`
+	CSR     string               `json:"csr"`
+	PEM     string               `json:"pem,omitempty"`
+	Parsed  *parsedCSR           `json:"parsed,omitempty"`
+	Error   string               `json:"parseError,omitempty"`
+	Problem *acme.ProblemDetails `json:"problem,omitempty"`
+}
+
+// describeFinalizeAttempt decodes and parses the CSR of a finalize attempt as
+// far as possible.
+func describeFinalizeAttempt(a core.FinalizeAttempt) finalizeAttempt {
+	result := finalizeAttempt{Time: a.Time, CSR: a.CSR, Problem: a.Problem}
+	der, err := base64.RawURLEncoding.DecodeString(a.CSR)
+	if err != nil {
+		result.Error = fmt.Sprintf("decoding base64url: %s", err)
+		return result
+	}
+	result.PEM = string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}))
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		result.Error = fmt.Sprintf("parsing CSR: %s", err)
+		return result
+	}
+
+	parsed := &parsedCSR{
+		Subject:            csr.Subject.String(),
+		DNSNames:           csr.DNSNames,
+		EmailAddresses:     csr.EmailAddresses,
+		PublicKeyAlgorithm: csr.PublicKeyAlgorithm.String(),
+		KeyType:            keyType(csr.PublicKey),
+		SignatureAlgorithm: csr.SignatureAlgorithm.String(),
+		SignatureValid:     csr.CheckSignature() == nil,
+	}
+	for _, ip := range csr.IPAddresses {
+		parsed.IPAddresses = append(parsed.IPAddresses, ip.String())
+	}
+	for _, uri := range csr.URIs {
+		parsed.URIs = append(parsed.URIs, uri.String())
+	}
+	for _, ext := range csr.Extensions {
+		parsed.Extensions = append(parsed.Extensions, csrExtension{
+			ID:       ext.Id.String(),
+			Critical: ext.Critical,
+			Value:    base64.StdEncoding.EncodeToString(ext.Value),
+		})
+	}
+	result.Parsed = parsed
+	return result
+}
+
+// orderCSRs is the response of the order CSRs endpoint.
+type orderCSRs struct {
+	Order    string            `json:"order"`
+	Attempts []finalizeAttempt `json:"attempts"`
+}
+
+// OrderCSRs returns the CSRs most recently submitted to finalize an order,
+// including rejected ones, with their parsed contents and the problems they
+// were rejected with.
+func (wfe *WebFrontEndImpl) OrderCSRs(response http.ResponseWriter, request *http.Request) {
+	orderID := strings.TrimPrefix(request.URL.Path, orderCSRsPath)
+	order, err := wfe.db.GetOrderByID(orderID)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	if order == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No order %q found", orderID)), response)
+		return
+	}
+
+	order.RLock()
+	attempts := make([]finalizeAttempt, 0, len(order.FinalizeAttempts))
+	for _, a := range order.FinalizeAttempts {
+		attempts = append(attempts, describeFinalizeAttempt(a))
+	}
+	order.RUnlock()
+
+	result := orderCSRs{orderID, attempts}
+	err = wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling finalize attempts"), response)
+		return
+	}
+}
@@ -0,0 +1,129 @@
+package wfe
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// FinalizeDryRun makes the checks FinalizeOrder and the CA would make for a
+// finalize request, without recording the CSR or issuing a certificate, and
+// responds with an acme.FinalizeDryRunResult listing the problems found.
+// Unlike FinalizeOrder it carries on checking after the first problem where
+// it can. Problems with the request itself, like a bad JWS or an unknown
+// order, are sent as errors as usual.
+func (wfe *WebFrontEndImpl) FinalizeDryRun(
+	ctx context.Context,
+	logEvent *requestEvent,
+	response http.ResponseWriter,
+	request *http.Request) {
+
+	body, key, prob := wfe.verifyPOST(ctx, logEvent, request, wfe.lookupJWK)
+	if prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+
+	existingAcct, prob := wfe.getAcctByKey(key)
+	if prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+
+	orderID := strings.TrimPrefix(request.URL.Path, finalizeDryRunPath)
+	order, err := wfe.db.GetOrderByID(orderID)
+	if err != nil {
+		wfe.log.Printf("Error getting order %q: %s\n", orderID, err)
+		wfe.sendError(acme.InternalErrorProblem("Error retrieving order"), response)
+		return
+	}
+	// Orders of other accounts are treated as though they don't exist
+	var orderAccountID string
+	if order != nil {
+		order.RLock()
+		orderAccountID = order.AccountID
+		order.RUnlock()
+	}
+	if order == nil || orderAccountID != existingAcct.ID {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf(
+			"No order %q found for account ID %q", orderID, existingAcct.ID)), response)
+		return
+	}
+
+	var finalizeMessage struct {
+		CSR string
+	}
+	err = json.Unmarshal(body, &finalizeMessage)
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error unmarshaling finalize order request body: %s", err.Error())), response)
+		return
+	}
+
+	result := acme.FinalizeDryRunResult{Problems: []*acme.ProblemDetails{}}
+	addProblem := func(prob *acme.ProblemDetails) {
+		result.Problems = append(result.Problems, prob)
+	}
+
+	order.RLock()
+	status := order.Status
+	expires := order.ExpiresDate
+	permanentIDs := order.PermanentIDs
+	tnAuthList := order.TNAuthList
+	order.RUnlock()
+	if status != acme.StatusReady {
+		addProblem(acme.MalformedProblem(fmt.Sprintf(
+			"Order's status (%q) was not %s", status, acme.StatusReady)))
+	}
+	if expires.Before(wfe.clk.Now()) {
+		addProblem(acme.NotFoundProblem(fmt.Sprintf(
+			"Order %q expired %s", orderID, expires)))
+	}
+	if prob, _ := wfe.duplicateCertificatesProblem(order); prob != nil {
+		addProblem(prob)
+	}
+
+	var csr *x509.CertificateRequest
+	csrBytes, err := base64.RawURLEncoding.DecodeString(finalizeMessage.CSR)
+	if err != nil {
+		addProblem(acme.MalformedProblem("Error decoding Base64url-encoded CSR: " + err.Error()))
+	} else if csr, err = x509.ParseCertificateRequest(csrBytes); err != nil {
+		addProblem(acme.MalformedProblem("Error parsing Base64url-encoded CSR: " + err.Error()))
+	}
+
+	if csr != nil {
+		for _, prob := range wfe.csrProblems(order, csr, request) {
+			addProblem(prob)
+		}
+
+		if wfe.config.CSRReplayPolicy != CSRReplayAllow {
+			digest := sha256.Sum256(csrBytes)
+			first, used := wfe.db.GetCSRUse(hex.EncodeToString(digest[:]))
+			if used && !wfe.csrReplayAllowed(first, orderID, existingAcct.ID) {
+				addProblem(acme.CSRReplayedProblem("CSR was already used to finalize another order"))
+			}
+		}
+
+		// Issuance failures make the order invalid with an internal error
+		warnings, err := wfe.ca.PreflightCertificate(csr, permanentIDs, tnAuthList)
+		if err != nil {
+			addProblem(acme.InternalErrorProblem(err.Error()))
+		}
+		result.Warnings = warnings
+	}
+
+	result.Valid = len(result.Problems) == 0
+	wfe.log.Printf("Finalize dry run for order %s found %d problems\n", orderID, len(result.Problems))
+	err = wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling finalize dry run result"), response)
+	}
+}
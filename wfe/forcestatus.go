@@ -0,0 +1,371 @@
+package wfe
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/cryptomode"
+	"github.com/letsencrypt/pebble/events"
+)
+
+// statusExpired is the status that expires an authorization or order when
+// it is forced. Orders have no expired status, expired orders are invalid.
+const statusExpired = "expired"
+
+// forcedStatus is the body of a request to force the status of a challenge,
+// authorization or order.
+type forcedStatus struct {
+	Status string `json:"status"`
+	// Error is the problem of objects forced to be invalid. It defaults to an
+	// unauthorized problem.
+	Error *acme.ProblemDetails `json:"error,omitempty"`
+	// Challenge is the type of the challenge that becomes valid or invalid
+	// with an authorization forced to be valid or invalid. It defaults to the
+	// authorization's first challenge.
+	Challenge string `json:"challenge,omitempty"`
+}
+
+// forcedStatusResult is the response to a request forcing the status of a
+// challenge, authorization or order.
+type forcedStatusResult struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// problem returns the error of the forced status, or a default problem.
+func (f forcedStatus) problem() *acme.ProblemDetails {
+	if f.Error != nil && f.Error.Type != "" {
+		return f.Error
+	}
+	return acme.UnauthorizedProblem("Status forced to invalid by the management interface")
+}
+
+// forceChallengeValid makes a challenge and its authorization valid.
+func (wfe *WebFrontEndImpl) forceChallengeValid(chal *core.Challenge) {
+	now := wfe.clk.Now().UTC()
+	chal.Lock()
+	chal.Status = acme.StatusValid
+	chal.Error = nil
+	chal.ValidatedDate = now
+	chal.Validated = now.Format(time.RFC3339)
+	authz := chal.Authz
+	chal.Unlock()
+
+	authz.Lock()
+	authz.Status = acme.StatusValid
+	authz.ValidatedDate = now
+	authz.Unlock()
+}
+
+// forceChallengeInvalid makes a challenge, its authorization and the
+// authorization's order invalid.
+func (wfe *WebFrontEndImpl) forceChallengeInvalid(chal *core.Challenge, prob *acme.ProblemDetails) {
+	now := wfe.clk.Now().UTC()
+	chal.Lock()
+	chal.Status = acme.StatusInvalid
+	chal.Error = prob
+	chal.ValidatedDate = now
+	chal.Validated = now.Format(time.RFC3339)
+	authz := chal.Authz
+	chal.Unlock()
+
+	authz.Lock()
+	authz.Status = acme.StatusInvalid
+	order := authz.Order
+	authz.Unlock()
+
+	if order != nil {
+		order.Lock()
+		order.Error = prob
+		order.Unlock()
+	}
+}
+
+// authzChallenge returns the challenge of the authorization with the given
+// type, or its first challenge if the type is empty.
+func (wfe *WebFrontEndImpl) authzChallenge(authz *core.Authorization, chalType string) *core.Challenge {
+	authz.RLock()
+	defer authz.RUnlock()
+	for _, c := range authz.Challenges {
+		if chalType == "" || c.Type == chalType {
+			chalID := c.URL
+			if i := strings.LastIndex(chalID, challengePath); i >= 0 {
+				chalID = chalID[i+len(challengePath):]
+			}
+			return wfe.db.GetChallengeByID(chalID)
+		}
+	}
+	return nil
+}
+
+// forceAuthzStatus moves an authorization to the forced status.
+func (wfe *WebFrontEndImpl) forceAuthzStatus(authz *core.Authorization, forced forcedStatus) error {
+	switch forced.Status {
+	case acme.StatusValid, acme.StatusInvalid:
+		chal := wfe.authzChallenge(authz, forced.Challenge)
+		if chal == nil {
+			return fmt.Errorf("authorization has no %q challenge", forced.Challenge)
+		}
+		if forced.Status == acme.StatusValid {
+			wfe.forceChallengeValid(chal)
+		} else {
+			wfe.forceChallengeInvalid(chal, forced.problem())
+		}
+	case acme.StatusDeactivated:
+		authz.Lock()
+		authz.Status = acme.StatusDeactivated
+		authz.Unlock()
+	case statusExpired:
+		expires := wfe.clk.Now().Add(-time.Second)
+		authz.Lock()
+		authz.ExpiresDate = expires
+		authz.Expires = expires.UTC().Format(time.RFC3339)
+		authz.Unlock()
+	default:
+		return fmt.Errorf("authorization status can't be forced to %q", forced.Status)
+	}
+	return nil
+}
+
+// forceOrderStatus moves an order to the forced status, moving its
+// authorizations to valid first for the ready, processing and valid statuses.
+// Orders forced to be processing or valid without a CSR get one for a new key.
+func (wfe *WebFrontEndImpl) forceOrderStatus(order *core.Order, forced forcedStatus) error {
+	switch forced.Status {
+	case acme.StatusInvalid:
+		order.Lock()
+		order.Error = forced.problem()
+		order.Unlock()
+		return nil
+	case statusExpired:
+		expires := wfe.clk.Now().Add(-time.Second)
+		order.Lock()
+		order.ExpiresDate = expires
+		order.Expires = expires.UTC().Format(time.RFC3339)
+		order.Unlock()
+		return nil
+	case acme.StatusReady, acme.StatusProcessing, acme.StatusValid:
+	default:
+		return fmt.Errorf("order status can't be forced to %q", forced.Status)
+	}
+
+	order.RLock()
+	beganProcessing := order.BeganProcessing
+	authzs := order.AuthorizationObjects
+	order.RUnlock()
+	if beganProcessing && forced.Status == acme.StatusReady {
+		return fmt.Errorf("order has already begun processing")
+	}
+
+	for _, authz := range authzs {
+		authz.RLock()
+		authzStatus := authz.Status
+		authz.RUnlock()
+		if authzStatus == acme.StatusValid {
+			continue
+		}
+		chal := wfe.authzChallenge(authz, "")
+		if chal == nil {
+			return fmt.Errorf("authorization %q has no challenges", authz.ID)
+		}
+		wfe.forceChallengeValid(chal)
+		wfe.publishForcedStatus(authz, chal)
+	}
+	order.Lock()
+	order.Error = nil
+	order.Unlock()
+	if forced.Status == acme.StatusReady {
+		return nil
+	}
+
+	if !beganProcessing {
+		certKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptomode.Reader())
+		if err != nil {
+			return err
+		}
+		order.Lock()
+		order.ParsedCSR = &x509.CertificateRequest{
+			DNSNames:  order.Names,
+			PublicKey: certKey.Public(),
+		}
+		order.BeganProcessing = true
+		order.Unlock()
+	}
+
+	if forced.Status == acme.StatusValid {
+		order.RLock()
+		issued := order.CertificateObject != nil
+		order.RUnlock()
+		if !issued {
+			wfe.ca.CompleteOrder(order)
+		}
+		order.RLock()
+		defer order.RUnlock()
+		if order.CertificateObject == nil {
+			return fmt.Errorf("failed to issue certificate for order %q", order.ID)
+		}
+	}
+	return nil
+}
+
+// publishForcedStatus publishes the statuses of an authorization and
+// optionally one of its challenges whose status was forced.
+func (wfe *WebFrontEndImpl) publishForcedStatus(authz *core.Authorization, chal *core.Challenge) {
+	authz.RLock()
+	identifier := authz.Identifier.Value
+	authzStatus := authz.Status
+	authz.RUnlock()
+	if chal != nil {
+		chal.RLock()
+		wfe.config.Events.Publish(events.TypeChallenge, chal.ID, chal.Status, identifier)
+		chal.RUnlock()
+	}
+	wfe.config.Events.Publish(events.TypeAuthorization, authz.ID, authzStatus, identifier)
+}
+
+func (wfe *WebFrontEndImpl) publishOrderStatus(order *core.Order) {
+	if order == nil {
+		return
+	}
+	if status, err := order.GetStatus(wfe.clk); err == nil {
+		wfe.config.Events.Publish(events.TypeOrder, order.ID, status, "")
+	}
+}
+
+// readForcedStatus reads the forced status from the body of a management
+// request, sending a problem and returning false if it can't be read.
+func (wfe *WebFrontEndImpl) readForcedStatus(response http.ResponseWriter, request *http.Request) (forcedStatus, bool) {
+	var forced forcedStatus
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return forced, false
+	}
+	if err := json.Unmarshal(body, &forced); err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error unmarshaling forced status: %s", err.Error())), response)
+		return forced, false
+	}
+	return forced, true
+}
+
+// writeForcedStatus writes the ID and resulting status of an object whose
+// status was forced.
+func (wfe *WebFrontEndImpl) writeForcedStatus(response http.ResponseWriter, id, status string) {
+	err := wfe.writeJsonResponse(response, http.StatusOK, forcedStatusResult{id, status})
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling forced status"), response)
+	}
+}
+
+// ForceChallengeStatus makes the challenge with the ID at the end of the
+// request path valid or invalid, along with its authorization, without
+// validating it.
+func (wfe *WebFrontEndImpl) ForceChallengeStatus(response http.ResponseWriter, request *http.Request) {
+	chalID := strings.TrimPrefix(request.URL.Path, challengeStatusPath)
+	chal := wfe.db.GetChallengeByID(chalID)
+	if chal == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No challenge %q found", chalID)), response)
+		return
+	}
+	forced, ok := wfe.readForcedStatus(response, request)
+	if !ok {
+		return
+	}
+	switch forced.Status {
+	case acme.StatusValid:
+		wfe.forceChallengeValid(chal)
+	case acme.StatusInvalid:
+		wfe.forceChallengeInvalid(chal, forced.problem())
+	default:
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"challenge status can't be forced to %q", forced.Status)), response)
+		return
+	}
+	wfe.log.Printf("management: forced challenge %s to %s\n", chalID, forced.Status)
+	wfe.publishForcedStatus(chal.Authz, chal)
+	wfe.publishOrderStatus(chal.Authz.Order)
+
+	chal.RLock()
+	status := chal.Status
+	chal.RUnlock()
+	wfe.writeForcedStatus(response, chalID, status)
+}
+
+// ForceAuthzStatus moves the authorization with the ID at the end of the
+// request path to a valid, invalid, deactivated or expired status without
+// validating it.
+func (wfe *WebFrontEndImpl) ForceAuthzStatus(response http.ResponseWriter, request *http.Request) {
+	authzID := strings.TrimPrefix(request.URL.Path, authzStatusPath)
+	authz := wfe.db.GetAuthorizationByID(authzID)
+	if authz == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No authorization %q found", authzID)), response)
+		return
+	}
+	forced, ok := wfe.readForcedStatus(response, request)
+	if !ok {
+		return
+	}
+	if err := wfe.forceAuthzStatus(authz, forced); err != nil {
+		wfe.sendError(acme.MalformedProblem(err.Error()), response)
+		return
+	}
+	wfe.log.Printf("management: forced authorization %s to %s\n", authzID, forced.Status)
+	var chal *core.Challenge
+	if forced.Status == acme.StatusValid || forced.Status == acme.StatusInvalid {
+		chal = wfe.authzChallenge(authz, forced.Challenge)
+	}
+	wfe.publishForcedStatus(authz, chal)
+	wfe.publishOrderStatus(authz.Order)
+
+	authz.RLock()
+	status := authz.Status
+	if (status == acme.StatusPending || status == acme.StatusValid) &&
+		authz.ExpiresDate.Before(wfe.clk.Now()) {
+		status = acme.StatusExpired
+	}
+	authz.RUnlock()
+	wfe.writeForcedStatus(response, authzID, status)
+}
+
+// ForceOrderStatus moves the order with the ID at the end of the request path
+// to a ready, processing, valid, invalid or expired status, without
+// validating its authorizations.
+func (wfe *WebFrontEndImpl) ForceOrderStatus(response http.ResponseWriter, request *http.Request) {
+	orderID := strings.TrimPrefix(request.URL.Path, orderStatusPath)
+	order, err := wfe.db.GetOrderByID(orderID)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	if order == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No order %q found", orderID)), response)
+		return
+	}
+	forced, ok := wfe.readForcedStatus(response, request)
+	if !ok {
+		return
+	}
+	if err := wfe.forceOrderStatus(order, forced); err != nil {
+		wfe.sendError(acme.MalformedProblem(err.Error()), response)
+		return
+	}
+	wfe.log.Printf("management: forced order %s to %s\n", orderID, forced.Status)
+	wfe.publishOrderStatus(order)
+
+	status, err := order.GetStatus(wfe.clk)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	wfe.writeForcedStatus(response, orderID, status)
+}
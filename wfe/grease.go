@@ -0,0 +1,140 @@
+package wfe
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// ungreasedHeaders are the response headers whose names aren't randomly cased
+// when greasing, because net/http looks them up by their canonical names and
+// would add them a second time.
+var ungreasedHeaders = map[string]bool{
+	"Content-Type":      true,
+	"Content-Length":    true,
+	"Date":              true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+}
+
+// greases returns true if responses of the named endpoint are greased.
+func (wfe *WebFrontEndImpl) greases(endpoint string) bool {
+	if len(wfe.config.GreaseEndpoints) == 0 {
+		return false
+	}
+	_, ok := pattern.Lookup(wfe.config.GreaseEndpoints, endpoint)
+	return ok
+}
+
+// greaseWriter buffers a response and, when it is closed, writes it with
+// unknown fields added to its JSON object and its header names randomly
+// cased, the way Boulder greases responses to find brittle client parsers.
+type greaseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *greaseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *greaseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// Close greases and writes the buffered response.
+func (w *greaseWriter) Close() {
+	body := w.body.Bytes()
+	mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if mediaType == "application/json" || strings.HasSuffix(mediaType, "+json") {
+		body = greaseJSON(body)
+	}
+	greaseHeaders(w.Header())
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(body)
+}
+
+// greaseJSON adds unknown fields to the end of a JSON object. Other JSON
+// values are returned unchanged.
+func greaseJSON(body []byte) []byte {
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(body, &object); err != nil {
+		return body
+	}
+	var buf bytes.Buffer
+	trimmed := bytes.TrimRight(body, " \t\r\n")
+	buf.Write(trimmed[:len(trimmed)-1])
+	separate := len(object) > 0
+	for name, value := range unknownFields() {
+		key, _ := json.Marshal(name)
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return body
+		}
+		if separate {
+			buf.WriteByte(',')
+		}
+		separate = true
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(encoded)
+	}
+	buf.WriteByte('}')
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "   "); err != nil {
+		return body
+	}
+	return indented.Bytes()
+}
+
+// greaseHeaders randomly cases the header names and adds an unknown header.
+// Header names are only sent as they are cased over HTTP/1.1, HTTP/2
+// lowercases them.
+func greaseHeaders(header http.Header) {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	for _, name := range names {
+		if ungreasedHeaders[name] {
+			continue
+		}
+		values := header[name]
+		delete(header, name)
+		header[randomCase(name)] = values
+	}
+	header[randomCase("X-Pebble-Grease-"+randomString(3))] = []string{randomString(9)}
+}
+
+// randomCase returns the string with each letter randomly upper or lower
+// cased.
+func randomCase(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if rand.Intn(2) == 0 {
+			continue
+		}
+		switch {
+		case 'a' <= c && c <= 'z':
+			b[i] = c - 'a' + 'A'
+		case 'A' <= c && c <= 'Z':
+			b[i] = c - 'A' + 'a'
+		}
+	}
+	return string(b)
+}
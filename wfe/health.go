@@ -0,0 +1,41 @@
+package wfe
+
+import (
+	"net/http"
+	"sync/atomic"
+)
+
+// SetReady marks Pebble as ready to serve requests. It is called once the CA
+// has generated its keys and every listener is bound.
+func (wfe *WebFrontEndImpl) SetReady() {
+	atomic.StoreInt32(wfe.ready, 1)
+}
+
+// Healthz reports that the management interface is up. It doesn't require
+// management authentication so that orchestration can probe it.
+func (wfe *WebFrontEndImpl) Healthz(response http.ResponseWriter, request *http.Request) {
+	wfe.sendHealth(response, request, true)
+}
+
+// Readyz reports whether Pebble is ready to serve ACME requests with a 200 OK
+// or 503 Service Unavailable status. It doesn't require management
+// authentication so that orchestration can probe it.
+func (wfe *WebFrontEndImpl) Readyz(response http.ResponseWriter, request *http.Request) {
+	wfe.sendHealth(response, request, atomic.LoadInt32(wfe.ready) == 1)
+}
+
+func (wfe *WebFrontEndImpl) sendHealth(response http.ResponseWriter, request *http.Request, ok bool) {
+	if request.Method != http.MethodGet && request.Method != http.MethodHead {
+		response.Header().Set("Allow", "GET, HEAD")
+		response.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	response.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	response.Header().Set("Cache-Control", "no-store")
+	if !ok {
+		response.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = response.Write([]byte("not ready\n"))
+		return
+	}
+	_, _ = response.Write([]byte("ok\n"))
+}
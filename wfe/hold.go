@@ -0,0 +1,170 @@
+package wfe
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// A ProcessingHold keeps finalized orders in the processing state instead of
+// issuing their certificate straight away, so that clients' finalize timeouts
+// can be tested. An order is held by the first hold matching it.
+type ProcessingHold struct {
+	// Pattern is a pattern (see the pattern package) matched against the
+	// values of the order's identifiers. The order is held if any of them
+	// match. Empty matches every order unless Order is set.
+	Pattern string `json:"pattern,omitempty"`
+	// Order is the ID of a single order to hold.
+	Order string `json:"order,omitempty"`
+	// Duration is how long the order is held for in milliseconds. Zero holds
+	// it until it is released through the management interface.
+	Duration int `json:"duration,omitempty"`
+}
+
+func (h ProcessingHold) check() error {
+	if err := pattern.Check(h.Pattern); err != nil {
+		return fmt.Errorf("processing hold: %s", err)
+	}
+	if h.Duration < 0 {
+		return fmt.Errorf("processing hold for %q must have duration >= 0", h.Pattern)
+	}
+	return nil
+}
+
+func (h ProcessingHold) matches(order *core.Order) bool {
+	if h.Order != "" && h.Order != order.ID {
+		return false
+	}
+	if h.Pattern == "" {
+		return true
+	}
+	for _, ident := range order.Identifiers {
+		if pattern.Match(h.Pattern, ident.Value) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdTable holds the processing holds and the orders currently held.
+type holdTable struct {
+	sync.Mutex
+	holds []ProcessingHold
+	// held maps the ID of each held order to a channel that is closed to
+	// release it.
+	held map[string]chan struct{}
+}
+
+func newHoldTable() *holdTable {
+	return &holdTable{held: make(map[string]chan struct{})}
+}
+
+// set replaces all of the holds in the table. Orders already held stay held.
+func (t *holdTable) set(holds []ProcessingHold) error {
+	for _, h := range holds {
+		if err := h.check(); err != nil {
+			return err
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.holds = append([]ProcessingHold(nil), holds...)
+	return nil
+}
+
+func (t *holdTable) get() []ProcessingHold {
+	t.Lock()
+	defer t.Unlock()
+	return append([]ProcessingHold{}, t.holds...)
+}
+
+// hold returns a channel that is closed when the order is released, and the
+// duration it is held for, if a hold matches the order. The order must be
+// locked for reading.
+func (t *holdTable) hold(order *core.Order) (<-chan struct{}, time.Duration, bool) {
+	t.Lock()
+	defer t.Unlock()
+	for _, h := range t.holds {
+		if h.matches(order) {
+			release := make(chan struct{})
+			t.held[order.ID] = release
+			return release, time.Duration(h.Duration) * time.Millisecond, true
+		}
+	}
+	return nil, 0, false
+}
+
+// release releases the held order with the given ID, or every held order if
+// the ID is empty, and returns the IDs of the released orders.
+func (t *holdTable) release(orderID string) []string {
+	t.Lock()
+	defer t.Unlock()
+	released := []string{}
+	for id, release := range t.held {
+		if orderID == "" || id == orderID {
+			close(release)
+			delete(t.held, id)
+			released = append(released, id)
+		}
+	}
+	sort.Strings(released)
+	return released
+}
+
+// heldOrders returns the IDs of the orders currently held.
+func (t *holdTable) heldOrders() []string {
+	t.Lock()
+	defer t.Unlock()
+	ids := []string{}
+	for id := range t.held {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// completeOrder asks the CA to complete the finalized order once any
+// processing hold matching it has passed or been released.
+func (wfe *WebFrontEndImpl) completeOrder(order *core.Order) {
+	order.RLock()
+	release, duration, held := wfe.holds.hold(order)
+	order.RUnlock()
+	if held {
+		wfe.log.Printf("Holding order %s in processing", order.ID)
+		var timeout <-chan time.Time
+		if duration > 0 {
+			timeout = wfe.clk.After(duration)
+		}
+		select {
+		case <-release:
+		case <-timeout:
+			wfe.holds.release(order.ID)
+		}
+		wfe.log.Printf("Released order %s from processing hold", order.ID)
+	}
+	wfe.ca.CompleteOrder(order)
+
+	order.RLock()
+	issued := order.CertificateObject != nil
+	idents := order.Identifiers
+	namespace := order.Namespace
+	order.RUnlock()
+	if issued {
+		wfe.duplicates.record(namespace, idents)
+	}
+}
+
+// ProcessingHolds returns the configured processing holds.
+func (wfe *WebFrontEndImpl) ProcessingHolds() []ProcessingHold {
+	return wfe.holds.get()
+}
+
+// SetProcessingHolds replaces the processing holds.
+func (wfe *WebFrontEndImpl) SetProcessingHolds(holds []ProcessingHold) error {
+	return wfe.holds.set(holds)
+}
@@ -0,0 +1,228 @@
+package wfe
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/va"
+)
+
+// An IdentifierType describes how the WFE handles the identifiers of one
+// type in new orders: when they are accepted, how their syntax is checked and
+// which challenges their authorizations get. New types can be added with
+// RegisterIdentifierType without changing the WFE's order handling, though
+// the VA and CA must also know how to validate them and put them in
+// certificates.
+type IdentifierType struct {
+	// Enabled returns true if identifiers of the type are accepted with the
+	// WFE's config. Nil accepts them with any config.
+	Enabled func(config Config) bool
+	// MaxPerOrder is the most identifiers of the type an order may include.
+	// Zero allows any number.
+	MaxPerOrder int
+	// Validate returns a problem if the identifier is malformed or isn't
+	// allowed. It may be nil.
+	Validate func(wfe *WebFrontEndImpl, ident acme.Identifier) *acme.ProblemDetails
+	// Challenges returns the types of the challenges offered by a new
+	// authorization for an identifier of the type.
+	Challenges func(wfe *WebFrontEndImpl, authz *core.Authorization, request *http.Request) []string
+	// PrepareChallenge, if set, sets the type specific fields of a new
+	// challenge of the authorization.
+	PrepareChallenge func(wfe *WebFrontEndImpl, chal *core.Challenge)
+}
+
+// identifierTypes are the registered identifier types, keyed by type.
+var identifierTypes = struct {
+	sync.RWMutex
+	types map[string]IdentifierType
+}{types: make(map[string]IdentifierType)}
+
+// RegisterIdentifierType adds an identifier type to those the WFE accepts in
+// new orders. It panics if the type is already registered or has no
+// Challenges function, so it is meant to be called from init functions.
+func RegisterIdentifierType(name string, t IdentifierType) {
+	if t.Challenges == nil {
+		panic(fmt.Sprintf("identifier type %q has no challenges", name))
+	}
+	identifierTypes.Lock()
+	defer identifierTypes.Unlock()
+	if _, exists := identifierTypes.types[name]; exists {
+		panic(fmt.Sprintf("identifier type %q is already registered", name))
+	}
+	identifierTypes.types[name] = t
+}
+
+// identifierType returns the registered identifier type with the given name
+// if it is enabled with the WFE's config.
+func (wfe *WebFrontEndImpl) identifierType(name string) (IdentifierType, bool) {
+	identifierTypes.RLock()
+	t, ok := identifierTypes.types[name]
+	identifierTypes.RUnlock()
+	if !ok || (t.Enabled != nil && !t.Enabled(wfe.config)) {
+		return IdentifierType{}, false
+	}
+	return t, true
+}
+
+// builtinIdentifierTypes are the identifier types whose authorizations are
+// made from the order's Names, PermanentIDs and TNAuthList. Authorizations
+// of the other types are made from the order's identifiers as they are.
+var builtinIdentifierTypes = map[string]bool{
+	acme.IdentifierDNS:         true,
+	acme.IdentifierPermanentID: true,
+	acme.IdentifierTNAuthList:  true,
+}
+
+func init() {
+	RegisterIdentifierType(acme.IdentifierDNS, IdentifierType{
+		Validate:         validateDNSIdentifier,
+		Challenges:       dnsChallengeTypes,
+		PrepareChallenge: prepareDNSChallenge,
+	})
+	// Authorizations for a permanent identifier can only be validated by
+	// device attestation
+	RegisterIdentifierType(acme.IdentifierPermanentID, IdentifierType{
+		Enabled: func(config Config) bool { return config.EnableDeviceAttest },
+		Validate: func(wfe *WebFrontEndImpl, ident acme.Identifier) *acme.ProblemDetails {
+			if ident.Value == "" {
+				return acme.MalformedProblem(
+					"Order included permanent-identifier identifier with empty value")
+			}
+			return nil
+		},
+		Challenges: func(wfe *WebFrontEndImpl, authz *core.Authorization, request *http.Request) []string {
+			return []string{acme.ChallengeDeviceAttest01}
+		},
+	})
+	// Authorizations for a TNAuthList are validated with an Authority Token
+	RegisterIdentifierType(acme.IdentifierTNAuthList, IdentifierType{
+		Enabled:     func(config Config) bool { return config.EnableTNAuthList },
+		MaxPerOrder: 1,
+		Validate: func(wfe *WebFrontEndImpl, ident acme.Identifier) *acme.ProblemDetails {
+			if _, err := parseTNAuthList(ident.Value); err != nil {
+				return acme.MalformedProblem(fmt.Sprintf(
+					"Order included invalid TNAuthList identifier: %s", err))
+			}
+			return nil
+		},
+		Challenges: func(wfe *WebFrontEndImpl, authz *core.Authorization, request *http.Request) []string {
+			return []string{acme.ChallengeTKAuth01}
+		},
+		PrepareChallenge: func(wfe *WebFrontEndImpl, chal *core.Challenge) {
+			chal.TKAuthType = acme.TKAuthTypeATC
+			chal.TokenAuthority = wfe.config.TokenAuthority
+		},
+	})
+}
+
+// validateDNSIdentifier checks the syntax of a DNS identifier and its
+// ancestor domain.
+func validateDNSIdentifier(wfe *WebFrontEndImpl, ident acme.Identifier) *acme.ProblemDetails {
+	rawDomain := ident.Value
+	if rawDomain == "" {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included DNS identifier with empty value"))
+	}
+
+	if ident.AncestorDomain != "" {
+		if prob := wfe.verifyAncestorDomain(ident); prob != nil {
+			return prob
+		}
+	}
+
+	// Names under .onion can only be validated with onion-csr-01, so they
+	// are only accepted when it is enabled
+	if va.IsOnion(rawDomain) {
+		if !wfe.config.EnableOnion {
+			return acme.UnsupportedIdentifierProblem(fmt.Sprintf(
+				"Order included .onion name %q but onion names are not enabled", rawDomain))
+		}
+		if _, err := va.OnionPublicKey(rawDomain); err != nil {
+			return acme.MalformedProblem(fmt.Sprintf(
+				"Order included invalid .onion name: %s", err))
+		}
+	}
+
+	if prob := checkNonASCII(rawDomain); prob != nil {
+		return prob
+	}
+	for _, ch := range []byte(rawDomain) {
+		if !isDNSCharacter(ch) {
+			return acme.MalformedProblem(fmt.Sprintf(
+				"Order included DNS identifier with a value containing an illegal character: %q",
+				ch))
+		}
+	}
+
+	if len(rawDomain) > maxDNSIdentifierLength {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included DNS identifier that was longer than %d characters",
+			maxDNSIdentifierLength))
+	}
+
+	if ip := net.ParseIP(rawDomain); ip != nil {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included a DNS identifier with an IP address value: %q\n",
+			rawDomain))
+	}
+
+	if strings.HasSuffix(rawDomain, ".") {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included a DNS identifier with a value ending in a period: %q\n",
+			rawDomain))
+	}
+
+	// If there is a wildcard character in the ident value there should be only
+	// *one* instance
+	if strings.Count(rawDomain, "*") > 1 {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included DNS type identifier with illegal wildcard value: "+
+				"too many wildcards %q",
+			rawDomain))
+	} else if strings.Count(rawDomain, "*") == 1 {
+		// If there is one wildcard character it should be the only character in
+		// the leftmost label.
+		if !strings.HasPrefix(rawDomain, "*.") {
+			return acme.MalformedProblem(fmt.Sprintf(
+				"Order included DNS type identifier with illegal wildcard value: "+
+					"wildcard isn't leftmost prefix %q",
+				rawDomain))
+		}
+	}
+
+	return wfe.checkIDN(rawDomain)
+}
+
+// dnsChallengeTypes returns the challenge types of a DNS authorization.
+// Authorizations for .onion names are validated with a CSR signed by the
+// hidden service key, since Pebble can't reach hidden services. Other names
+// get the challenge types of the matching challenge policy that are enabled
+// and that the view and the account's override allow.
+func dnsChallengeTypes(wfe *WebFrontEndImpl, authz *core.Authorization, request *http.Request) []string {
+	if va.IsOnion(authz.Identifier.Value) {
+		return []string{acme.ChallengeOnionCSR01}
+	}
+	view := requestView(request)
+	override := wfe.accountOverride(authz.Order.AccountID)
+	wildcard := strings.HasPrefix(authz.Identifier.Value, "*.")
+	var types []string
+	for _, chalType := range wfe.challengeTypes(authz.Identifier.Value) {
+		if !wildcard && (!view.allowsChallenge(chalType) || !override.allowsChallenge(chalType) ||
+			!wfe.challenges.enabled(chalType)) {
+			continue
+		}
+		types = append(types, chalType)
+	}
+	return types
+}
+
+func prepareDNSChallenge(wfe *WebFrontEndImpl, chal *core.Challenge) {
+	if chal.Type == acme.ChallengeOnionCSR01 {
+		chal.Nonce = randomString(16)
+	}
+}
@@ -0,0 +1,235 @@
+package wfe
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// The identifier strictness levels set how the DNS identifiers of new orders
+// are normalized and how the internationalized domain names among them are
+// checked. Identifiers with non-ASCII characters are always rejected, IDNs
+// must be given as punycode.
+const (
+	// IdentifierStandard, the default, takes identifiers as submitted and
+	// requires their punycode labels (starting "xn--") to be valid punycode
+	// of a label with non-ASCII characters.
+	IdentifierStandard = "standard"
+	// IdentifierLenient lowercases identifiers, strips a trailing dot from
+	// them and accepts any punycode label.
+	IdentifierLenient = "lenient"
+	// IdentifierStrict also rejects identifiers with uppercase characters,
+	// labels with hyphens in the third and fourth positions other than
+	// punycode labels, and punycode labels that decode to uppercase
+	// characters or to characters other than letters, marks, digits and
+	// hyphens, approximating the IDNA2008 rules.
+	IdentifierStrict = "strict"
+)
+
+// idnPrefix is the ACE prefix of punycode labels.
+const idnPrefix = "xn--"
+
+func checkIdentifierStrictness(strictness string) error {
+	switch strictness {
+	case "", IdentifierStandard, IdentifierLenient, IdentifierStrict:
+		return nil
+	}
+	return fmt.Errorf("unknown identifier strictness %q", strictness)
+}
+
+// normalizeIdentifiers returns the identifiers with the DNS identifiers and
+// their ancestor domains lowercased and stripped of a trailing dot, if
+// identifiers are normalized.
+func (wfe *WebFrontEndImpl) normalizeIdentifiers(idents []acme.Identifier) []acme.Identifier {
+	if wfe.config.IdentifierStrictness != IdentifierLenient {
+		return idents
+	}
+	normalized := make([]acme.Identifier, len(idents))
+	for i, ident := range idents {
+		if ident.Type == acme.IdentifierDNS {
+			ident.Value = normalizeDNSName(ident.Value)
+			if ident.AncestorDomain != "" {
+				ident.AncestorDomain = normalizeDNSName(ident.AncestorDomain)
+			}
+		}
+		normalized[i] = ident
+	}
+	return normalized
+}
+
+func normalizeDNSName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
+
+// checkNonASCII returns a problem if a DNS identifier has non-ASCII
+// characters, which must be encoded as punycode.
+func checkNonASCII(name string) *acme.ProblemDetails {
+	if isASCII(name) {
+		return nil
+	}
+	return acme.MalformedProblem(fmt.Sprintf(
+		"Order included DNS identifier %q with non-ASCII characters, "+
+			"internationalized domain names must be encoded as punycode (%q labels)",
+		name, idnPrefix))
+}
+
+// checkIDN returns a problem if a DNS identifier isn't acceptable at the
+// configured strictness.
+func (wfe *WebFrontEndImpl) checkIDN(name string) *acme.ProblemDetails {
+	strictness := wfe.config.IdentifierStrictness
+	if strictness == IdentifierLenient {
+		return nil
+	}
+	if strictness == IdentifierStrict && strings.ToLower(name) != name {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included DNS identifier %q with uppercase characters", name))
+	}
+	for _, label := range strings.Split(name, ".") {
+		lower := strings.ToLower(label)
+		if !strings.HasPrefix(lower, idnPrefix) {
+			if strictness == IdentifierStrict && len(label) >= 4 && label[2:4] == "--" {
+				return acme.MalformedProblem(fmt.Sprintf(
+					"Order included DNS identifier %q with reserved label %q", name, label))
+			}
+			continue
+		}
+		if err := checkPunycodeLabel(lower[len(idnPrefix):], strictness == IdentifierStrict); err != nil {
+			return acme.MalformedProblem(fmt.Sprintf(
+				"Order included DNS identifier %q with invalid punycode label %q: %s",
+				name, label, err))
+		}
+	}
+	return nil
+}
+
+// checkPunycodeLabel checks that the punycode encoded part of a label decodes
+// to a label with non-ASCII characters, and if strict that the label only has
+// lowercase letters, marks, digits and hyphens.
+func checkPunycodeLabel(encoded string, strict bool) error {
+	decoded, err := decodePunycode(encoded)
+	if err != nil {
+		return err
+	}
+	if isASCII(decoded) {
+		return errors.New("it decodes to an ASCII label")
+	}
+	if !strict {
+		return nil
+	}
+	for _, r := range decoded {
+		switch {
+		case unicode.IsUpper(r):
+			return fmt.Errorf("it decodes to uppercase character %q", r)
+		case !unicode.IsLetter(r) && !unicode.IsMark(r) && !unicode.IsDigit(r) && r != '-':
+			return fmt.Errorf("it decodes to disallowed character %q", r)
+		}
+	}
+	return nil
+}
+
+// The punycode parameters of RFC 3492 Section 5.
+const (
+	punyBase        = 36
+	punyTMin        = 1
+	punyTMax        = 26
+	punySkew        = 38
+	punyDamp        = 700
+	punyInitialBias = 72
+	punyInitialN    = 128
+)
+
+// decodePunycode decodes a punycode string, without its ACE prefix, as
+// described in RFC 3492 Section 6.2.
+func decodePunycode(encoded string) (string, error) {
+	var output []rune
+	pos := 0
+	if i := strings.LastIndex(encoded, "-"); i >= 0 {
+		for _, r := range encoded[:i] {
+			if r >= 0x80 {
+				return "", errors.New("its basic code points aren't ASCII")
+			}
+			output = append(output, r)
+		}
+		pos = i + 1
+	}
+
+	n, bias, i := punyInitialN, punyInitialBias, 0
+	for pos < len(encoded) {
+		oldI, w := i, 1
+		for k := punyBase; ; k += punyBase {
+			if pos >= len(encoded) {
+				return "", errors.New("it is truncated")
+			}
+			digit, ok := punycodeDigit(encoded[pos])
+			pos++
+			if !ok {
+				return "", fmt.Errorf("it has invalid digit %q", encoded[pos-1])
+			}
+			if digit > (math.MaxInt32-i)/w {
+				return "", errors.New("it overflows")
+			}
+			i += digit * w
+			t := k - bias
+			if t < punyTMin {
+				t = punyTMin
+			} else if t > punyTMax {
+				t = punyTMax
+			}
+			if digit < t {
+				break
+			}
+			if w > math.MaxInt32/(punyBase-t) {
+				return "", errors.New("it overflows")
+			}
+			w *= punyBase - t
+		}
+		points := len(output) + 1
+		bias = punycodeAdapt(i-oldI, points, oldI == 0)
+		if i/points > math.MaxInt32-n {
+			return "", errors.New("it overflows")
+		}
+		n += i / points
+		i %= points
+		if n > unicode.MaxRune || (n >= 0xD800 && n <= 0xDFFF) {
+			return "", fmt.Errorf("it decodes to invalid code point %#x", n)
+		}
+		output = append(output, 0)
+		copy(output[i+1:], output[i:])
+		output[i] = rune(n)
+		i++
+	}
+	return string(output), nil
+}
+
+// punycodeDigit returns the value of a punycode digit.
+func punycodeDigit(c byte) (int, bool) {
+	switch {
+	case 'a' <= c && c <= 'z':
+		return int(c - 'a'), true
+	case 'A' <= c && c <= 'Z':
+		return int(c - 'A'), true
+	case '0' <= c && c <= '9':
+		return int(c-'0') + 26, true
+	}
+	return 0, false
+}
+
+// punycodeAdapt is the bias adaptation function of RFC 3492 Section 6.1.
+func punycodeAdapt(delta, points int, first bool) int {
+	if first {
+		delta /= punyDamp
+	} else {
+		delta /= 2
+	}
+	delta += delta / points
+	k := 0
+	for delta > ((punyBase-punyTMin)*punyTMax)/2 {
+		delta /= punyBase - punyTMin
+		k += punyBase
+	}
+	return k + (punyBase-punyTMin+1)*delta/(delta+punySkew)
+}
@@ -0,0 +1,60 @@
+package wfe
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// RuntimeInfo describes how a Pebble instance is configured, so that test
+// orchestration can discover it from the management interface.
+type RuntimeInfo struct {
+	Version string `json:"version"`
+	// Features are the config fields that are switched on.
+	Features []string `json:"features"`
+	// Listeners are the addresses Pebble listens on, keyed by what is served
+	// there.
+	Listeners map[string]string `json:"listeners"`
+	// Config is the effective config with secrets redacted.
+	Config interface{} `json:"config"`
+}
+
+// SetRuntimeInfo sets the runtime info served by the management interface.
+func (wfe *WebFrontEndImpl) SetRuntimeInfo(info RuntimeInfo) {
+	wfe.info.Store(info)
+}
+
+// runtimeInfoResult is the response of the info endpoint.
+type runtimeInfoResult struct {
+	RuntimeInfo
+	Roots []acme.RootInfo `json:"roots"`
+}
+
+// Info returns the runtime info along with the fingerprints of the current
+// root certificates.
+func (wfe *WebFrontEndImpl) Info(response http.ResponseWriter, request *http.Request) {
+	info, _ := wfe.info.Load().(RuntimeInfo)
+	result := runtimeInfoResult{RuntimeInfo: info, Roots: []acme.RootInfo{}}
+
+	for i := 0; i < wfe.ca.NumberOfChains(); i++ {
+		root := wfe.ca.GetRootCert(i)
+		if root == nil {
+			continue
+		}
+		fingerprint := sha256.Sum256(root.DER)
+		result.Roots = append(result.Roots, acme.RootInfo{
+			Index:   i,
+			Default: i == wfe.ca.DefaultChain(),
+			Subject: root.Cert.Subject.String(),
+			SHA256:  hex.EncodeToString(fingerprint[:]),
+		})
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling runtime info"), response)
+		return
+	}
+}
@@ -3,6 +3,7 @@ package wfe
 import (
 	"crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/rsa"
 	"crypto/sha256"
 	"crypto/x509"
@@ -25,6 +26,8 @@ func algorithmForKey(key *jose.JSONWebKey) (string, error) {
 		case "P-521":
 			return string(jose.ES512), nil
 		}
+	case ed25519.PublicKey:
+		return string(jose.EdDSA), nil
 	}
 	return "", fmt.Errorf("no signature algorithms suitable for given key type")
 }
@@ -49,7 +52,7 @@ func checkAlgorithm(key *jose.JSONWebKey, parsedJws *jose.JSONWebSignature) (str
 	if jwsAlgorithm != algorithm {
 		return invalidJWSAlgorithm,
 			fmt.Errorf(
-				"signature type '%s' in JWS header is not supported, expected one of RS256, ES256, ES384 or ES512",
+				"signature type '%s' in JWS header is not supported, expected one of RS256, ES256, ES384, ES512 or EdDSA",
 				jwsAlgorithm)
 	}
 	if key.Algorithm != "" && key.Algorithm != algorithm {
@@ -93,3 +96,16 @@ func keyDigestEquals(j, k crypto.PublicKey) bool {
 	}
 	return digestJ == digestK
 }
+
+// isEd25519Key returns true if the provided public key is an Ed25519 key.
+func isEd25519Key(key crypto.PublicKey) bool {
+	switch k := key.(type) {
+	case *jose.JSONWebKey:
+		return k != nil && isEd25519Key(k.Key)
+	case jose.JSONWebKey:
+		return isEd25519Key(k.Key)
+	case ed25519.PublicKey:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package wfe
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// The JWS features that ACME doesn't allow but clients sometimes use. Each is
+// detected before the JWS is parsed and rejected with a problem describing it.
+const (
+	// JWSCompactSerialization is a JWS in the compact serialization instead
+	// of the flattened JSON serialization.
+	JWSCompactSerialization = "compact-serialization"
+	// JWSUnprotectedHeader is a JWS with an unprotected "header".
+	JWSUnprotectedHeader = "unprotected-header"
+	// JWSMultipleSignatures is a JWS using the "signatures" array of the
+	// general JSON serialization.
+	JWSMultipleSignatures = "multiple-signatures"
+	// JWSUnencodedPayload is a JWS with an unencoded payload, signalled by a
+	// "b64" protected header parameter (RFC 7797).
+	JWSUnencodedPayload = "unencoded-payload"
+	// JWSCritHeader is a JWS with a "crit" protected header parameter.
+	JWSCritHeader = "crit"
+
+	// JWSRejectPrecise rejects a JWS feature with a problem describing it. It
+	// is the default.
+	JWSRejectPrecise = "precise"
+	// JWSRejectGeneric rejects a JWS feature with the same problem as any
+	// other unparseable JWS, like a server that doesn't detect it.
+	JWSRejectGeneric = "generic"
+
+	// jwsParseError is the problem detail of JWS that can't be parsed.
+	jwsParseError = "Parse error reading JWS"
+)
+
+var jwsFeatures = []string{JWSCompactSerialization, JWSUnprotectedHeader,
+	JWSMultipleSignatures, JWSUnencodedPayload, JWSCritHeader}
+
+func checkJWSFeatureHandling(handling map[string]string) error {
+	for feature, mode := range handling {
+		known := false
+		for _, f := range jwsFeatures {
+			known = known || f == feature
+		}
+		if !known {
+			return fmt.Errorf("unknown JWS feature %q", feature)
+		}
+		if mode != JWSRejectPrecise && mode != JWSRejectGeneric {
+			return fmt.Errorf("unknown handling %q of JWS feature %q", mode, feature)
+		}
+	}
+	return nil
+}
+
+// jwsFeatureError returns the error for a JWS that uses a feature, with the
+// precise detail unless the feature is configured to be rejected generically.
+func (wfe *WebFrontEndImpl) jwsFeatureError(feature, detail string) error {
+	if wfe.config.JWSFeatureHandling[feature] == JWSRejectGeneric {
+		detail = jwsParseError
+	}
+	return &jwsError{category: malformedFeature, detail: detail}
+}
+
+// checkJWSFeatures returns an error if the JWS in the body uses a feature ACME
+// doesn't allow. Malformed JWS are left for jose.ParseSigned to reject.
+func (wfe *WebFrontEndImpl) checkJWSFeatures(body string) error {
+	trimmed := strings.TrimSpace(body)
+	if !strings.HasPrefix(trimmed, "{") && strings.Count(trimmed, ".") == 2 {
+		return wfe.jwsFeatureError(JWSCompactSerialization,
+			"JWS uses the compact serialization. ACME requires the flattened JSON serialization")
+	}
+
+	var raw struct {
+		Protected  string
+		Header     json.RawMessage
+		Signatures []json.RawMessage
+	}
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		return diagnoseJWS(body)
+	}
+
+	// ACME v2 never uses values from the unprotected JWS header. Reject JWS that
+	// include unprotected headers.
+	if len(raw.Header) > 0 && string(raw.Header) != "null" {
+		return wfe.jwsFeatureError(JWSUnprotectedHeader,
+			"JWS \"header\" field not allowed. All headers must be in \"protected\" field")
+	}
+
+	// ACME v2 never uses the "signatures" array of JSON serialized JWS, just the
+	// mandatory "signature" field. Reject JWS that include the "signatures" array.
+	if len(raw.Signatures) > 1 {
+		return wfe.jwsFeatureError(JWSMultipleSignatures, fmt.Sprintf(
+			"JWS has %d signatures. ACME requires exactly one, in the \"signature\" field",
+			len(raw.Signatures)))
+	}
+	if len(raw.Signatures) > 0 {
+		return wfe.jwsFeatureError(JWSMultipleSignatures,
+			"JWS \"signatures\" field not allowed. Only the \"signature\" field should contain a signature")
+	}
+
+	headerJSON, err := base64.RawURLEncoding.DecodeString(raw.Protected)
+	if err != nil {
+		return nil
+	}
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil
+	}
+	if b64, present := header["b64"]; present {
+		return wfe.jwsFeatureError(JWSUnencodedPayload, fmt.Sprintf(
+			"JWS protected header has \"b64\": %s. ACME doesn't allow unencoded payloads (RFC 7797)",
+			b64))
+	}
+	if crit, present := header["crit"]; present {
+		var names []string
+		_ = json.Unmarshal(crit, &names)
+		return wfe.jwsFeatureError(JWSCritHeader, fmt.Sprintf(
+			"JWS protected header has a \"crit\" parameter listing [%s]. ACME doesn't support critical extensions",
+			strings.Join(names, ", ")))
+	}
+	return nil
+}
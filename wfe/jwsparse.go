@@ -0,0 +1,178 @@
+package wfe
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// The categories of malformed JWS counted by malformedJWSCounter. Each names
+// the part of the JWS, or the step of its verification, that failed.
+const (
+	malformedBody           = "body"
+	malformedProtected      = "protected"
+	malformedHeaderField    = "header-field"
+	malformedJWK            = "jwk"
+	malformedPayload        = "payload"
+	malformedSignature      = "signature"
+	malformedFeature        = "feature"
+	malformedSignatureCount = "signature-count"
+	malformedVerification   = "verification"
+	malformedURL            = "url"
+	malformedOther          = "other"
+)
+
+// jwsError is an error parsing or verifying a JWS, with the category of the
+// failure counted in the metrics.
+type jwsError struct {
+	category string
+	detail   string
+}
+
+func (e *jwsError) Error() string {
+	return e.detail
+}
+
+// malformedJWSCounter counts the malformed JWS received by category.
+type malformedJWSCounter struct {
+	sync.Mutex
+	counts map[string]int64
+}
+
+func newMalformedJWSCounter() *malformedJWSCounter {
+	return &malformedJWSCounter{counts: make(map[string]int64)}
+}
+
+func (c *malformedJWSCounter) record(category string) {
+	c.Lock()
+	defer c.Unlock()
+	c.counts[category]++
+}
+
+// categories returns the categories that were counted, sorted, and their
+// counts.
+func (c *malformedJWSCounter) categories() ([]string, map[string]int64) {
+	c.Lock()
+	defer c.Unlock()
+	categories := make([]string, 0, len(c.counts))
+	counts := make(map[string]int64, len(c.counts))
+	for category, n := range c.counts {
+		categories = append(categories, category)
+		counts[category] = n
+	}
+	sort.Strings(categories)
+	return categories, counts
+}
+
+// malformedJWS counts the JWS error and returns it. Errors that aren't
+// *jwsError are counted as malformedOther.
+func (wfe *WebFrontEndImpl) malformedJWS(err error) error {
+	category := malformedOther
+	var jwsErr *jwsError
+	if errors.As(err, &jwsErr) {
+		category = jwsErr.category
+	}
+	wfe.malformed.record(category)
+	return err
+}
+
+// parseError returns a jwsError with the generic JWS parse error detail
+// followed by the given reason.
+func parseError(category, format string, a ...interface{}) *jwsError {
+	return &jwsError{
+		category: category,
+		detail:   jwsParseError + ": " + fmt.Sprintf(format, a...),
+	}
+}
+
+// decodeJWSField decodes a required base64url encoded field of a flattened
+// JWS. Like go-jose, trailing padding is tolerated.
+func decodeJWSField(raw map[string]json.RawMessage, name, category string) ([]byte, *jwsError) {
+	value, present := raw[name]
+	if !present {
+		return nil, parseError(category, "the %q field is missing", name)
+	}
+	var encoded string
+	if err := json.Unmarshal(value, &encoded); err != nil {
+		return nil, parseError(category, "the %q field must be a base64url encoded string", name)
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		var corrupt base64.CorruptInputError
+		if errors.As(err, &corrupt) {
+			return nil, parseError(category,
+				"the %q field isn't base64url encoded: illegal character at offset %d", name, int64(corrupt))
+		}
+		return nil, parseError(category, "the %q field isn't base64url encoded", name)
+	}
+	return decoded, nil
+}
+
+// stringHeaderFields are the protected header parameters ACME uses that must
+// be strings.
+var stringHeaderFields = []string{"alg", "kid", "nonce", "url"}
+
+// diagnoseJWS returns the reason a flattened JSON JWS that go-jose couldn't
+// parse is malformed, naming the field and the decoding step that failed. It
+// returns a generic parse error if it can't tell.
+func diagnoseJWS(body string) *jwsError {
+	if strings.TrimSpace(body) == "" {
+		return parseError(malformedBody, "the body is empty")
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return parseError(malformedBody, "the body isn't valid JSON: %s at offset %d",
+				syntaxErr.Error(), syntaxErr.Offset)
+		}
+		return parseError(malformedBody, "the body must be a JSON object")
+	}
+
+	headerJSON, jwsErr := decodeJWSField(raw, "protected", malformedProtected)
+	if jwsErr != nil {
+		return jwsErr
+	}
+	var header map[string]json.RawMessage
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return parseError(malformedProtected, "the decoded \"protected\" field isn't a JSON object")
+	}
+	for _, name := range stringHeaderFields {
+		value, present := header[name]
+		if !present {
+			continue
+		}
+		var s string
+		if err := json.Unmarshal(value, &s); err != nil {
+			return parseError(malformedHeaderField,
+				"the %q protected header parameter must be a string, not %s", name, value)
+		}
+	}
+	if _, present := header["alg"]; !present {
+		return parseError(malformedHeaderField, "the \"alg\" protected header parameter is missing")
+	}
+	if jwk, present := header["jwk"]; present {
+		var key jose.JSONWebKey
+		if err := json.Unmarshal(jwk, &key); err != nil {
+			return parseError(malformedJWK, "the \"jwk\" protected header parameter is invalid: %s", err)
+		}
+	}
+
+	if _, jwsErr := decodeJWSField(raw, "payload", malformedPayload); jwsErr != nil {
+		return jwsErr
+	}
+	signature, jwsErr := decodeJWSField(raw, "signature", malformedSignature)
+	if jwsErr != nil {
+		return jwsErr
+	}
+	if len(signature) == 0 {
+		return parseError(malformedSignature, "the \"signature\" field is empty")
+	}
+	return &jwsError{category: malformedOther, detail: jwsParseError}
+}
@@ -0,0 +1,122 @@
+package wfe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// keyAuthorizationOverride is the body of a request overriding the key
+// authorization the VA expects for a challenge. Either the whole key
+// authorization or only the account key thumbprint following the
+// challenge's token is given.
+type keyAuthorizationOverride struct {
+	KeyAuthorization string `json:"keyAuthorization,omitempty"`
+	Thumbprint       string `json:"thumbprint,omitempty"`
+}
+
+// usesKeyAuthorization returns true if the VA checks a key authorization to
+// validate challenges of the type.
+func usesKeyAuthorization(chalType string) bool {
+	switch chalType {
+	case acme.ChallengeTKAuth01, acme.ChallengeOnionCSR01:
+		return false
+	}
+	return true
+}
+
+// ChallengeKeyAuthorization returns the key authorization the VA expects for
+// the challenge with the ID at the end of the request path for a GET request.
+// A POST request overrides it, so that the VA rejects the value a client
+// computes from its account key, and a DELETE request removes the override.
+// Only pending challenges can be overridden.
+func (wfe *WebFrontEndImpl) ChallengeKeyAuthorization(response http.ResponseWriter, request *http.Request) {
+	chalID := strings.TrimPrefix(request.URL.Path, keyAuthorizationPath)
+	chal := wfe.db.GetChallengeByID(chalID)
+	if chal == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No challenge %q found", chalID)), response)
+		return
+	}
+
+	chal.RLock()
+	chalType := chal.Type
+	token := chal.Token
+	status := chal.Status
+	authz := chal.Authz
+	chal.RUnlock()
+	if !usesKeyAuthorization(chalType) {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"%s challenges don't use a key authorization", chalType)), response)
+		return
+	}
+
+	if request.Method != "GET" {
+		if status != acme.StatusPending {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Challenge %q is %s, only pending challenges can be overridden", chalID, status)), response)
+			return
+		}
+		var override string
+		if request.Method == "POST" {
+			body, err := ioutil.ReadAll(request.Body)
+			if err != nil {
+				wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+				return
+			}
+			var o keyAuthorizationOverride
+			if err := json.Unmarshal(body, &o); err != nil {
+				wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+					"Error unmarshaling key authorization override: %s", err.Error())), response)
+				return
+			}
+			if (o.KeyAuthorization == "") == (o.Thumbprint == "") {
+				wfe.sendError(acme.MalformedProblem(
+					"Exactly one of keyAuthorization and thumbprint must be given"), response)
+				return
+			}
+			override = o.KeyAuthorization
+			if o.Thumbprint != "" {
+				override = token + "." + o.Thumbprint
+			}
+		}
+		chal.Lock()
+		chal.KeyAuthorizationOverride = override
+		chal.Unlock()
+		if override == "" {
+			wfe.log.Printf("management: removed key authorization override of challenge %s\n", chalID)
+		} else {
+			wfe.log.Printf("management: overrode key authorization of challenge %s\n", chalID)
+		}
+	}
+
+	result := acme.ExpectedKeyAuthorization{ID: chalID, Type: chalType}
+	chal.RLock()
+	result.KeyAuthorization = chal.KeyAuthorizationOverride
+	chal.RUnlock()
+	result.Overridden = result.KeyAuthorization != ""
+	if !result.Overridden {
+		authz.RLock()
+		order := authz.Order
+		authz.RUnlock()
+		order.RLock()
+		acctID := order.AccountID
+		order.RUnlock()
+		acct := wfe.db.GetAccountByID(acctID)
+		if acct == nil {
+			wfe.sendError(acme.InternalErrorProblem("Error finding the challenge's account"), response)
+			return
+		}
+		chal.RLock()
+		result.KeyAuthorization = chal.ExpectedKeyAuthorization(acct.Key)
+		chal.RUnlock()
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling key authorization"), response)
+	}
+}
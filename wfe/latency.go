@@ -0,0 +1,165 @@
+package wfe
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+const (
+	// LatencyFixed delays every request by Delay milliseconds.
+	LatencyFixed = "fixed"
+	// LatencyUniform delays requests by between Min and Max milliseconds.
+	LatencyUniform = "uniform"
+	// LatencyLogNormal delays requests by a log-normally distributed amount
+	// with the given Median (in milliseconds) and Sigma. Max, if set, caps the
+	// delay.
+	LatencyLogNormal = "lognormal"
+
+	// allEndpoints is the endpoint name of the latency profile applied to
+	// endpoints that don't have a profile of their own.
+	allEndpoints = "*"
+)
+
+// LatencyProfile describes the artificial latency added to the requests of an
+// endpoint. All durations are in milliseconds.
+type LatencyProfile struct {
+	Distribution string  `json:"distribution"`
+	Delay        int     `json:"delay,omitempty"`
+	Min          int     `json:"min,omitempty"`
+	Max          int     `json:"max,omitempty"`
+	Median       int     `json:"median,omitempty"`
+	Sigma        float64 `json:"sigma,omitempty"`
+}
+
+func (p LatencyProfile) check() error {
+	switch p.Distribution {
+	case LatencyFixed:
+		if p.Delay < 0 {
+			return fmt.Errorf("fixed latency profile must have delay >= 0")
+		}
+	case LatencyUniform:
+		if p.Min < 0 || p.Max < p.Min {
+			return fmt.Errorf("uniform latency profile must have 0 <= min <= max")
+		}
+	case LatencyLogNormal:
+		if p.Median <= 0 || p.Sigma < 0 || p.Max < 0 {
+			return fmt.Errorf("lognormal latency profile must have median > 0, sigma >= 0 and max >= 0")
+		}
+	default:
+		return fmt.Errorf("unknown latency distribution %q", p.Distribution)
+	}
+	return nil
+}
+
+// delay returns a random delay drawn from the profile's distribution.
+func (p LatencyProfile) delay() time.Duration {
+	var ms float64
+	switch p.Distribution {
+	case LatencyFixed:
+		ms = float64(p.Delay)
+	case LatencyUniform:
+		ms = float64(p.Min) + rand.Float64()*float64(p.Max-p.Min)
+	case LatencyLogNormal:
+		ms = math.Exp(math.Log(float64(p.Median)) + p.Sigma*rand.NormFloat64())
+		if p.Max > 0 && ms > float64(p.Max) {
+			ms = float64(p.Max)
+		}
+	}
+	return time.Duration(ms * float64(time.Millisecond))
+}
+
+// latencyTable holds the latency profiles keyed by endpoint name or by a
+// pattern (see the pattern package) matching endpoint names.
+type latencyTable struct {
+	sync.RWMutex
+	profiles map[string]LatencyProfile
+	names    []string
+}
+
+func newLatencyTable() *latencyTable {
+	return &latencyTable{profiles: make(map[string]LatencyProfile)}
+}
+
+// set replaces all of the profiles in the table.
+func (t *latencyTable) set(profiles map[string]LatencyProfile) error {
+	for name, p := range profiles {
+		if err := checkEndpointPattern(name); err != nil {
+			return fmt.Errorf("latency profile: %s", err)
+		}
+		if err := p.check(); err != nil {
+			return fmt.Errorf("latency profile for %q: %s", name, err)
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	t.profiles = make(map[string]LatencyProfile, len(profiles))
+	t.names = make([]string, 0, len(profiles))
+	for name, p := range profiles {
+		t.profiles[name] = p
+		t.names = append(t.names, name)
+	}
+	return nil
+}
+
+// checkEndpointPattern returns an error unless the name is an endpoint name or
+// a pattern matching at least one.
+func checkEndpointPattern(name string) error {
+	if knownEndpointName(name) {
+		return nil
+	}
+	if pattern.IsLiteral(name) {
+		return fmt.Errorf("unknown endpoint %q", name)
+	}
+	if err := pattern.Check(name); err != nil {
+		return err
+	}
+	for _, n := range endpointNames {
+		if pattern.Match(name, n) {
+			return nil
+		}
+	}
+	return fmt.Errorf("pattern %q matches no endpoint", name)
+}
+
+func (t *latencyTable) get() map[string]LatencyProfile {
+	t.RLock()
+	defer t.RUnlock()
+	profiles := make(map[string]LatencyProfile, len(t.profiles))
+	for name, p := range t.profiles {
+		profiles[name] = p
+	}
+	return profiles
+}
+
+// delay returns the artificial latency to add to a request for the named
+// endpoint, or zero if no profile applies. The profile for the endpoint's
+// name is used, or else that of the longest matching pattern, so "*" applies
+// last.
+func (t *latencyTable) delay(endpoint string) time.Duration {
+	t.RLock()
+	defer t.RUnlock()
+	name, ok := pattern.Lookup(t.names, endpoint)
+	if !ok {
+		return 0
+	}
+	return t.profiles[name].delay()
+}
+
+// LatencyProfiles returns the configured latency profiles keyed by endpoint
+// name.
+func (wfe *WebFrontEndImpl) LatencyProfiles() map[string]LatencyProfile {
+	return wfe.latency.get()
+}
+
+// SetLatencyProfiles replaces the latency profiles. Profiles can be keyed by
+// a pattern matching endpoint names, e.g. "*" for endpoints without a profile
+// of their own.
+func (wfe *WebFrontEndImpl) SetLatencyProfiles(profiles map[string]LatencyProfile) error {
+	return wfe.latency.set(profiles)
+}
@@ -0,0 +1,107 @@
+package wfe
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// concurrencyLimiter tracks the number of in-flight requests, both server-wide
+// and per account, so that the WFE can shed load once the configured limits
+// are exceeded. A limit of zero means no limit.
+type concurrencyLimiter struct {
+	sync.Mutex
+	max           int
+	maxPerAccount int
+	inFlight      int
+	perAccount    map[string]int
+}
+
+func newConcurrencyLimiter(max, maxPerAccount int) *concurrencyLimiter {
+	return &concurrencyLimiter{
+		max:           max,
+		maxPerAccount: maxPerAccount,
+		perAccount:    make(map[string]int),
+	}
+}
+
+func (l *concurrencyLimiter) enabled() bool {
+	return l.max > 0 || l.maxPerAccount > 0
+}
+
+// acquire reserves a slot for a request from the given account (which may be
+// empty for unauthenticated requests). It returns false if doing so would
+// exceed a limit, in which case no slot is reserved.
+func (l *concurrencyLimiter) acquire(acctID string) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.max > 0 && l.inFlight >= l.max {
+		return false
+	}
+	if acctID != "" && l.maxPerAccount > 0 && l.perAccount[acctID] >= l.maxPerAccount {
+		return false
+	}
+
+	l.inFlight++
+	if acctID != "" {
+		l.perAccount[acctID]++
+	}
+	return true
+}
+
+// release frees a slot previously reserved with acquire.
+func (l *concurrencyLimiter) release(acctID string) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.inFlight--
+	if acctID != "" {
+		l.perAccount[acctID]--
+		if l.perAccount[acctID] <= 0 {
+			delete(l.perAccount, acctID)
+		}
+	}
+}
+
+// requestAccountID makes a best effort attempt to find the ID of the account
+// that signed a POST request by looking at the "kid" in the JWS protected
+// header. The request body is restored so that it can be read again by the
+// handler. No signature verification is done: the result must only be used
+// for load shedding decisions.
+func (wfe *WebFrontEndImpl) requestAccountID(request *http.Request) string {
+	if request.Method != "POST" || request.Body == nil {
+		return ""
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return ""
+	}
+
+	var jws struct {
+		Protected string `json:"protected"`
+	}
+	if err := json.Unmarshal(body, &jws); err != nil {
+		return ""
+	}
+	headerBytes, err := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if err != nil {
+		return ""
+	}
+	var header struct {
+		KeyID string `json:"kid"`
+	}
+	if err := json.Unmarshal(headerBytes, &header); err != nil {
+		return ""
+	}
+
+	if header.KeyID == "" {
+		return ""
+	}
+	return wfe.parseAccountURL(header.KeyID)
+}
@@ -0,0 +1,351 @@
+package wfe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// The query parameters every management list endpoint accepts on top of its
+// own.
+const (
+	listLimitParam  = "limit"
+	listOffsetParam = "offset"
+	listSortParam   = "sort"
+	listFilterParam = "filter"
+	listFieldsParam = "fields"
+)
+
+// maxListLimit is the largest page size a list request may ask for.
+const maxListLimit = 1000
+
+// A listQuery selects a page of the items of a management list response,
+// after filtering and sorting them, and optionally only some of their fields.
+// Fields are the top-level JSON fields of the items.
+type listQuery struct {
+	// limit is the most items returned. Zero returns every item from offset.
+	limit   int
+	offset  int
+	sort    []listSortKey
+	filters []listFilter
+	fields  []string
+}
+
+type listSortKey struct {
+	field string
+	desc  bool
+}
+
+// A listFilter keeps the items whose field has the value, or, for an array
+// field, includes the value.
+type listFilter struct {
+	field string
+	value string
+}
+
+// parseListQuery parses the list query parameters. If fields isn't nil the
+// fields named by the parameters must be in it.
+func parseListQuery(params url.Values, fields map[string]bool) (listQuery, error) {
+	var q listQuery
+	checkField := func(param, name string) error {
+		if name == "" {
+			return fmt.Errorf("%s has an empty field name", param)
+		}
+		if fields != nil && !fields[name] {
+			return fmt.Errorf("%s names unknown field %q", param, name)
+		}
+		return nil
+	}
+
+	for _, p := range []struct {
+		name  string
+		value *int
+		max   int
+	}{{listLimitParam, &q.limit, maxListLimit}, {listOffsetParam, &q.offset, -1}} {
+		v := params.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || (p.max > 0 && n > p.max) {
+			if p.max > 0 {
+				return q, fmt.Errorf("%s %q is not a number between 0 and %d", p.name, v, p.max)
+			}
+			return q, fmt.Errorf("%s %q is not a non-negative number", p.name, v)
+		}
+		*p.value = n
+	}
+
+	for _, v := range params[listSortParam] {
+		for _, name := range strings.Split(v, ",") {
+			key := listSortKey{field: strings.TrimPrefix(name, "-"), desc: strings.HasPrefix(name, "-")}
+			if err := checkField(listSortParam, key.field); err != nil {
+				return q, err
+			}
+			q.sort = append(q.sort, key)
+		}
+	}
+
+	for _, v := range params[listFilterParam] {
+		i := strings.Index(v, ":")
+		if i < 0 {
+			return q, fmt.Errorf("%s %q is not of the form field:value", listFilterParam, v)
+		}
+		f := listFilter{field: v[:i], value: v[i+1:]}
+		if err := checkField(listFilterParam, f.field); err != nil {
+			return q, err
+		}
+		q.filters = append(q.filters, f)
+	}
+
+	for _, v := range params[listFieldsParam] {
+		for _, name := range strings.Split(v, ",") {
+			if err := checkField(listFieldsParam, name); err != nil {
+				return q, err
+			}
+			q.fields = append(q.fields, name)
+		}
+	}
+	return q, nil
+}
+
+// reshapes returns true if the query changes the items of a response, rather
+// than only counting them.
+func (q listQuery) reshapes() bool {
+	return q.limit > 0 || q.offset > 0 || len(q.sort) > 0 || len(q.filters) > 0 || len(q.fields) > 0
+}
+
+// apply filters, sorts and pages the items, returning the page and the
+// number of items that passed the filters.
+func (q listQuery) apply(items []map[string]json.RawMessage) ([]map[string]json.RawMessage, int) {
+	var kept []map[string]json.RawMessage
+	for _, item := range items {
+		if q.matches(item) {
+			kept = append(kept, item)
+		}
+	}
+
+	if len(q.sort) > 0 {
+		sort.SliceStable(kept, func(i, j int) bool {
+			for _, key := range q.sort {
+				c := compareListValues(kept[i][key.field], kept[j][key.field])
+				if c == 0 {
+					continue
+				}
+				if key.desc {
+					return c > 0
+				}
+				return c < 0
+			}
+			return false
+		})
+	}
+
+	total := len(kept)
+	if q.offset >= total {
+		kept = nil
+	} else {
+		kept = kept[q.offset:]
+	}
+	if q.limit > 0 && len(kept) > q.limit {
+		kept = kept[:q.limit]
+	}
+
+	page := make([]map[string]json.RawMessage, 0, len(kept))
+	for _, item := range kept {
+		if len(q.fields) > 0 {
+			selected := make(map[string]json.RawMessage, len(q.fields))
+			for _, name := range q.fields {
+				if v, ok := item[name]; ok {
+					selected[name] = v
+				}
+			}
+			item = selected
+		}
+		page = append(page, item)
+	}
+	return page, total
+}
+
+func (q listQuery) matches(item map[string]json.RawMessage) bool {
+	for _, f := range q.filters {
+		var value interface{}
+		if raw, ok := item[f.field]; ok {
+			_ = json.Unmarshal(raw, &value)
+		}
+		if !listValueMatches(value, f.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// listValueMatches returns true if the JSON value is the filter value, or is
+// an array including it.
+func listValueMatches(value interface{}, filter string) bool {
+	switch v := value.(type) {
+	case nil:
+		return filter == ""
+	case string:
+		return v == filter
+	case []interface{}:
+		for _, elem := range v {
+			if listValueMatches(elem, filter) {
+				return true
+			}
+		}
+		return false
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded) == filter
+	}
+}
+
+// compareListValues orders two JSON values for sorting. Missing values sort
+// first, numbers numerically, booleans false first and everything else by its
+// JSON text.
+func compareListValues(a, b json.RawMessage) int {
+	var va, vb interface{}
+	_ = json.Unmarshal(a, &va)
+	_ = json.Unmarshal(b, &vb)
+	switch {
+	case va == nil && vb == nil:
+		return 0
+	case va == nil:
+		return -1
+	case vb == nil:
+		return 1
+	}
+
+	switch x := va.(type) {
+	case float64:
+		if y, ok := vb.(float64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	case bool:
+		if y, ok := vb.(bool); ok {
+			switch {
+			case x == y:
+				return 0
+			case !x:
+				return -1
+			}
+			return 1
+		}
+	case string:
+		if y, ok := vb.(string); ok {
+			return strings.Compare(x, y)
+		}
+	}
+	return bytes.Compare(a, b)
+}
+
+// listFieldNames returns the JSON names of the fields of the items of a
+// list response of the given type, or nil if they aren't known.
+func listFieldNames(response interface{}) map[string]bool {
+	t := reflect.TypeOf(response)
+	if t == nil || t.Kind() != reflect.Slice {
+		return nil
+	}
+	t = t.Elem()
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	names := make(map[string]bool)
+	for _, f := range schemaFields(t) {
+		names[f.name] = true
+	}
+	return names
+}
+
+// listResponseWriter buffers the response of a list endpoint so that the
+// list query can be applied to it.
+type listResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *listResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *listResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *listResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// serveList calls the handler of a list endpoint and applies the request's
+// list query to the JSON array it responds with. The number of items that
+// passed the filters is sent in the acme.ManagementTotalCountHeader header,
+// and a Link header with relation "next" links to the next page if there is
+// one. Responses that aren't JSON arrays of objects, such as errors, are sent
+// as they are.
+func (wfe *WebFrontEndImpl) serveList(
+	response http.ResponseWriter,
+	request *http.Request,
+	handler http.HandlerFunc,
+	fields map[string]bool) {
+	query, err := parseListQuery(request.URL.Query(), fields)
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem(err.Error()), response)
+		return
+	}
+
+	buffered := &listResponseWriter{header: response.Header()}
+	handler(buffered, request)
+	if buffered.status == 0 {
+		buffered.status = http.StatusOK
+	}
+
+	body := buffered.body.Bytes()
+	var items []map[string]json.RawMessage
+	if buffered.status != http.StatusOK || json.Unmarshal(body, &items) != nil || items == nil {
+		response.WriteHeader(buffered.status)
+		_, _ = response.Write(body)
+		return
+	}
+
+	total := len(items)
+	if query.reshapes() {
+		var page []map[string]json.RawMessage
+		page, total = query.apply(items)
+		if body, err = marshalIndent(page); err != nil {
+			wfe.sendError(acme.InternalErrorProblem("Error marshalling list"), response)
+			return
+		}
+		if query.limit > 0 && query.offset+query.limit < total {
+			next := request.URL.Query()
+			next.Set(listOffsetParam, strconv.Itoa(query.offset+query.limit))
+			response.Header().Add("Link", link(request.URL.Path+"?"+next.Encode(), "next"))
+		}
+	}
+	response.Header().Set(acme.ManagementTotalCountHeader, strconv.Itoa(total))
+	response.WriteHeader(buffered.status)
+	_, _ = response.Write(body)
+}
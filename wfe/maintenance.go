@@ -0,0 +1,126 @@
+package wfe
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// defaultMaintenanceRetryAfter is the Retry-After of responses from endpoints
+// in a maintenance window that has no end or Retry-After of its own.
+const defaultMaintenanceRetryAfter = 60 * time.Second
+
+// A MaintenanceWindow puts ACME endpoints into maintenance mode, so that they
+// answer every request with a 503 maintenance problem and a Retry-After
+// header, for testing how clients handle partial outages.
+type MaintenanceWindow struct {
+	// Endpoints are the names of the endpoints in maintenance (e.g.
+	// "newOrder"), or patterns (see the pattern package) matching endpoint
+	// names. Empty puts every endpoint in maintenance.
+	Endpoints []string `json:"endpoints,omitempty"`
+	// Start and End bound the window. It starts straight away if Start is
+	// nil and lasts until it is removed if End is nil.
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+	// RetryAfter is the Retry-After sent in seconds. Zero sends the time
+	// left until the window ends, or 60 seconds if it has no end.
+	RetryAfter int `json:"retryAfter,omitempty"`
+	// Detail is the detail of the problem sent.
+	Detail string `json:"detail,omitempty"`
+}
+
+func (w MaintenanceWindow) check() error {
+	for _, name := range w.Endpoints {
+		if err := checkEndpointPattern(name); err != nil {
+			return fmt.Errorf("maintenance window: %s", err)
+		}
+	}
+	if w.Start != nil && w.End != nil && !w.End.After(*w.Start) {
+		return fmt.Errorf("maintenance window must end after it starts")
+	}
+	if w.RetryAfter < 0 {
+		return fmt.Errorf("maintenance window must have retryAfter >= 0")
+	}
+	return nil
+}
+
+// active returns true if the window covers the endpoint at the given time.
+func (w MaintenanceWindow) active(endpoint string, now time.Time) bool {
+	if (w.Start != nil && now.Before(*w.Start)) || (w.End != nil && !now.Before(*w.End)) {
+		return false
+	}
+	if len(w.Endpoints) == 0 {
+		return true
+	}
+	_, ok := pattern.Lookup(w.Endpoints, endpoint)
+	return ok
+}
+
+// retryAfter returns the Retry-After of responses sent at the given time.
+func (w MaintenanceWindow) retryAfter(now time.Time) time.Duration {
+	if w.RetryAfter > 0 {
+		return time.Duration(w.RetryAfter) * time.Second
+	}
+	if w.End != nil {
+		return w.End.Sub(now)
+	}
+	return defaultMaintenanceRetryAfter
+}
+
+// maintenanceTable holds the maintenance windows.
+type maintenanceTable struct {
+	sync.RWMutex
+	windows []MaintenanceWindow
+}
+
+// set replaces all of the windows in the table.
+func (t *maintenanceTable) set(windows []MaintenanceWindow) error {
+	for _, w := range windows {
+		if err := w.check(); err != nil {
+			return err
+		}
+	}
+	t.Lock()
+	defer t.Unlock()
+	t.windows = append([]MaintenanceWindow(nil), windows...)
+	return nil
+}
+
+func (t *maintenanceTable) get() []MaintenanceWindow {
+	t.RLock()
+	defer t.RUnlock()
+	return append([]MaintenanceWindow{}, t.windows...)
+}
+
+// check returns the first window that puts the named endpoint in maintenance
+// at the given time, or nil if it isn't in maintenance.
+func (t *maintenanceTable) check(endpoint string, now time.Time) *MaintenanceWindow {
+	t.RLock()
+	defer t.RUnlock()
+	for i := range t.windows {
+		if t.windows[i].active(endpoint, now) {
+			w := t.windows[i]
+			return &w
+		}
+	}
+	return nil
+}
+
+// maintenanceRetryAfter formats a Retry-After duration in whole seconds,
+// rounding up so that clients don't retry before the window ends.
+func maintenanceRetryAfter(d time.Duration) string {
+	return fmt.Sprintf("%d", int(math.Ceil(d.Seconds())))
+}
+
+// MaintenanceWindows returns the maintenance windows.
+func (wfe *WebFrontEndImpl) MaintenanceWindows() []MaintenanceWindow {
+	return wfe.maintenance.get()
+}
+
+// SetMaintenanceWindows replaces the maintenance windows.
+func (wfe *WebFrontEndImpl) SetMaintenanceWindows(windows []MaintenanceWindow) error {
+	return wfe.maintenance.set(windows)
+}
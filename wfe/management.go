@@ -0,0 +1,1232 @@
+package wfe
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/audit"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/db"
+	"github.com/letsencrypt/pebble/va"
+)
+
+const (
+	// The management interface is served on a separate listener from the ACME
+	// API and is intended for test harnesses to control Pebble's behaviour. It
+	// is not an ACME API and doesn't use JWS or nonces.
+	validationOutcomesPath       = "/validation-outcomes"
+	rootsPath                    = "/roots/"
+	intermediatesPath            = "/intermediates/"
+	chainsPath                   = "/chains/"
+	rotateIssuersPath            = "/rotate-issuers"
+	storePath                    = "/store/"
+	metricsPath                  = "/metrics"
+	seedPath                     = "/seed"
+	latencyPath                  = "/latency"
+	maintenancePath              = "/maintenance"
+	addDelegationPath            = "/delegations"
+	certificatesPath             = "/certificates"
+	eventsPath                   = "/events"
+	ctLogsPath                   = "/ct-logs"
+	processingHoldsPath          = "/processing-holds"
+	releaseOrdersPath            = "/release-orders"
+	healthzPath                  = "/healthz"
+	readyzPath                   = "/readyz"
+	auditLogPath                 = "/audit-log"
+	accountOverridesPath         = "/account-overrides"
+	challengeTypesPath           = "/challenge-types"
+	infoPath                     = "/info"
+	challengeStatusPath          = "/challenge-status/"
+	authzStatusPath              = "/authz-status/"
+	orderStatusPath              = "/order-status/"
+	orderCSRsPath                = "/order-csrs/"
+	failuresPath                 = "/failures"
+	challengeTimingPath          = "/challenge-timing/"
+	cancelOrderPath              = "/cancel-order/"
+	autoFinalizedPath            = "/auto-finalized/"
+	testMetaPath                 = "/test-meta"
+	revokeCertificatesPath       = "/revoke-certificates"
+	externalAccountKeysPath      = "/external-account-keys"
+	revokeExternalAccountKeyPath = "/revoke-external-account-key"
+	namespacesPath               = "/namespaces"
+	wipeNamespacePath            = "/wipe-namespace"
+	orderReportPath              = "/order-report/"
+	openAPIPath                  = "/openapi.json"
+	massRevocationsPath          = "/mass-revocations"
+	dashboardPath                = "/dashboard"
+	dashboardJSONPath            = "/dashboard.json"
+	accountAnnotationsPath       = "/account-annotations/"
+	orderAnnotationsPath         = "/order-annotations/"
+	certificateAnnotationsPath   = "/certificate-annotations/"
+	annotatedPath                = "/annotated"
+	keyAuthorizationPath         = "/challenge-key-authorization/"
+	responseSigningKeyPath       = "/response-signing-key"
+	accountAttestationPath       = "/account-attestation/"
+)
+
+// eventsKeepAlive is how often a comment is sent on an idle event stream so
+// that clients and proxies don't time it out.
+const eventsKeepAlive = 15 * time.Second
+
+// The formats CA certificates can be served in, selected with the "format"
+// query parameter.
+const (
+	caCertFormatPEM   = "pem"
+	caCertFormatDER   = "der"
+	caCertFormatPKCS7 = "pkcs7"
+)
+
+func summarizeCertificate(cert *core.Certificate) acme.CertificateSummary {
+	return acme.CertificateSummary{
+		Serial:    cert.ID,
+		AccountID: cert.AccountID,
+		Names:     cert.Cert.DNSNames,
+		NotBefore: cert.Cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:  cert.Cert.NotAfter.UTC().Format(time.RFC3339),
+		URL:       certPath + cert.ID,
+	}
+}
+
+// A managementEndpoint is a path of the management interface with the
+// handler serving it and a description of its operations, from which the
+// OpenAPI document and clients of the management API are generated.
+type managementEndpoint struct {
+	path    string
+	handler func(wfe *WebFrontEndImpl, response http.ResponseWriter, request *http.Request)
+	// public endpoints don't require management authentication.
+	public     bool
+	operations []managementOperation
+}
+
+// A managementOperation is a method of a management endpoint.
+type managementOperation struct {
+	method string
+	// name identifies the operation in the OpenAPI document and names its
+	// method in the generated clients.
+	name    string
+	summary string
+	// pathParam names the parameter following the path of an endpoint ending
+	// in a slash, if the operation takes one.
+	pathParam string
+	query     []managementParam
+	// request and response are values of the types of the JSON request and
+	// response bodies, or nil if there are none.
+	request         interface{}
+	optionalRequest bool
+	response        interface{}
+	// contentType is the type of responses that aren't JSON.
+	contentType string
+	// status is the status of successful responses, defaulting to 200 OK.
+	status int
+	// list operations respond with JSON arrays that can be paginated,
+	// filtered and sorted with the list query parameters.
+	list bool
+}
+
+// A managementParam is a query parameter of a management operation.
+type managementParam struct {
+	name        string
+	description string
+	// kind is the parameter's OpenAPI type, defaulting to "string".
+	kind string
+}
+
+var formatParam = managementParam{"format", "The format of the certificates: pem, der or pkcs7.", ""}
+
+// managementEndpoints are the endpoints of the management interface. They are
+// set by init rather than a variable initializer since the OpenAPI endpoint's
+// handler refers to the document generated from them.
+var managementEndpoints []managementEndpoint
+
+// managementOpenAPIDocument is the JSON OpenAPI document describing the
+// management endpoints.
+var managementOpenAPIDocument []byte
+
+func init() {
+	managementEndpoints = []managementEndpoint{
+		{validationOutcomesPath, (*WebFrontEndImpl).ValidationOutcomes, false, []managementOperation{
+			{method: "GET", name: "getValidationOutcomes", summary: "Get the validation outcome rules.",
+				response: []va.OutcomeRule{}, list: true},
+			{method: "POST", name: "setValidationOutcomes", summary: "Replace the validation outcome rules.",
+				request: []va.OutcomeRule{}, response: []va.OutcomeRule{}},
+		}},
+		{rootsPath, (*WebFrontEndImpl).Root, false, []managementOperation{
+			{method: "GET", name: "listRoots", summary: "List the root certificates of every chain.",
+				response: []acme.CACertificate{}, list: true},
+			{method: "GET", name: "getRoot", summary: "Get the root certificate of a chain.",
+				pathParam: "index", query: []managementParam{formatParam},
+				contentType: "application/pem-certificate-chain"},
+		}},
+		{intermediatesPath, (*WebFrontEndImpl).Intermediate, false, []managementOperation{
+			{method: "GET", name: "listIntermediates", summary: "List the intermediate certificates of every chain.",
+				response: []acme.CACertificate{}, list: true},
+			{method: "GET", name: "getIntermediate", summary: "Get the intermediate certificate of a chain.",
+				pathParam: "index", query: []managementParam{formatParam},
+				contentType: "application/pem-certificate-chain"},
+		}},
+		{chainsPath, (*WebFrontEndImpl).Chain, false, []managementOperation{
+			{method: "GET", name: "listChains", summary: "List the certificate chains.",
+				response: []acme.CACertificate{}, list: true},
+			{method: "GET", name: "getChain", summary: "Get the certificates of a chain.",
+				pathParam: "index", query: []managementParam{formatParam},
+				contentType: "application/pem-certificate-chain"},
+		}},
+		{rotateIssuersPath, (*WebFrontEndImpl).RotateIssuers, false, []managementOperation{
+			{method: "POST", name: "rotateIssuers", summary: "Replace the issuing intermediates, and optionally the roots.",
+				request: rotateIssuersRequest{}, optionalRequest: true, status: http.StatusNoContent},
+		}},
+		{storePath, (*WebFrontEndImpl).Store, false, []managementOperation{
+			{method: "GET", name: "getStoreStats", summary: "Get the size of every store collection.",
+				response: map[string]db.CollectionStats{}},
+			{method: "GET", name: "getCollectionStats", summary: "Get the size of a store collection.",
+				pathParam: "collection", response: db.CollectionStats{}},
+			{method: "DELETE", name: "clearCollection", summary: "Remove every object of a store collection.",
+				pathParam: "collection", status: http.StatusNoContent},
+		}},
+		{metricsPath, (*WebFrontEndImpl).Metrics, false, []managementOperation{
+			{method: "GET", name: "getMetrics", summary: "Get metrics in the Prometheus text exposition format.",
+				contentType: "text/plain"},
+		}},
+		{seedPath, (*WebFrontEndImpl).SeedStore, false, []managementOperation{
+			{method: "POST", name: "seedStore", summary: "Populate the store with accounts, orders and certificates.",
+				request: SeedSpec{}, response: []SeededAccount{}, status: http.StatusCreated},
+		}},
+		{latencyPath, (*WebFrontEndImpl).Latency, false, []managementOperation{
+			{method: "GET", name: "getLatencyProfiles", summary: "Get the latency profiles.",
+				response: map[string]LatencyProfile{}},
+			{method: "POST", name: "setLatencyProfiles", summary: "Replace the latency profiles.",
+				request: map[string]LatencyProfile{}, response: map[string]LatencyProfile{}},
+		}},
+		{maintenancePath, (*WebFrontEndImpl).Maintenance, false, []managementOperation{
+			{method: "GET", name: "getMaintenanceWindows", summary: "Get the maintenance windows.",
+				response: []MaintenanceWindow{}, list: true},
+			{method: "POST", name: "setMaintenanceWindows", summary: "Replace the maintenance windows.",
+				request: []MaintenanceWindow{}, response: []MaintenanceWindow{}},
+		}},
+		{addDelegationPath, (*WebFrontEndImpl).NewDelegation, false, []managementOperation{
+			{method: "POST", name: "addDelegation", summary: "Add a STAR delegation to an account.",
+				request: delegationRequest{}, response: delegationCreated{}, status: http.StatusCreated},
+		}},
+		{certificatesPath, (*WebFrontEndImpl).SearchCertificates, false, []managementOperation{
+			{method: "GET", name: "searchCertificates", summary: "Search the unrevoked issued certificates.",
+				query: []managementParam{
+					{"san", "A DNS name of the certificates.", ""},
+					{"account", "The ID or URL of the account the certificates were issued to.", ""},
+					{"serialMin", "The lowest hex serial number.", ""},
+					{"serialMax", "The highest hex serial number.", ""},
+					{"issuedAfter", "An RFC 3339 time the certificates' NotBefore is after.", ""},
+					{"issuedBefore", "An RFC 3339 time the certificates' NotBefore is before.", ""},
+					{"pem", "Include each certificate in PEM format.", "boolean"},
+					{"selector", "An annotation selector, e.g. \"scenario=renewal,flaky\".", ""},
+				},
+				response: []acme.CertificateSummary{}, list: true},
+		}},
+		{eventsPath, (*WebFrontEndImpl).StreamEvents, false, []managementOperation{
+			{method: "GET", name: "streamEvents", summary: "Stream status transitions as server-sent events.",
+				query:       []managementParam{{"type", "Comma separated event types to stream.", ""}},
+				contentType: "text/event-stream"},
+		}},
+		{ctLogsPath, (*WebFrontEndImpl).CTLogs, false, []managementOperation{
+			{method: "GET", name: "listCTLogs", summary: "List the simulated CT logs.",
+				response: []acme.CTLog{}, list: true},
+		}},
+		{processingHoldsPath, (*WebFrontEndImpl).ProcessingHoldsHandler, false, []managementOperation{
+			{method: "GET", name: "getProcessingHolds", summary: "Get the processing holds and the held orders.",
+				response: processingHolds{}},
+			{method: "POST", name: "setProcessingHolds", summary: "Replace the processing holds.",
+				request: []ProcessingHold{}, response: processingHolds{}},
+		}},
+		{releaseOrdersPath, (*WebFrontEndImpl).ReleaseOrders, false, []managementOperation{
+			{method: "POST", name: "releaseOrders", summary: "Release a held order, or every held order.",
+				query:    []managementParam{{"order", "The ID of the order to release.", ""}},
+				response: []string{}},
+		}},
+		{auditLogPath, (*WebFrontEndImpl).AuditLog, false, []managementOperation{
+			{method: "GET", name: "getAuditLog", summary: "Get the entries of the audit log.",
+				response: []audit.Entry{}, list: true},
+		}},
+		{accountOverridesPath, (*WebFrontEndImpl).AccountOverridesHandler, false, []managementOperation{
+			{method: "GET", name: "getAccountOverrides", summary: "Get the account overrides.",
+				response: map[string]AccountOverride{}},
+			{method: "POST", name: "setAccountOverrides", summary: "Replace the account overrides.",
+				request: map[string]AccountOverride{}, response: map[string]AccountOverride{}},
+		}},
+		{challengeTypesPath, (*WebFrontEndImpl).ChallengeTypesHandler, false, []managementOperation{
+			{method: "GET", name: "getChallengeTypes", summary: "Get which challenge types are enabled.",
+				response: map[string]bool{}},
+			{method: "POST", name: "setChallengeTypes", summary: "Enable or disable challenge types.",
+				request: map[string]bool{}, response: map[string]bool{}},
+		}},
+		{infoPath, (*WebFrontEndImpl).Info, false, []managementOperation{
+			{method: "GET", name: "getInfo", summary: "Get the runtime info and root fingerprints.",
+				response: runtimeInfoResult{}},
+		}},
+		{challengeStatusPath, (*WebFrontEndImpl).ForceChallengeStatus, false, []managementOperation{
+			{method: "POST", name: "forceChallengeStatus", summary: "Make a challenge valid or invalid.",
+				pathParam: "id", request: forcedStatus{}, response: forcedStatusResult{}},
+		}},
+		{authzStatusPath, (*WebFrontEndImpl).ForceAuthzStatus, false, []managementOperation{
+			{method: "POST", name: "forceAuthzStatus", summary: "Make an authorization valid, invalid or expired.",
+				pathParam: "id", request: forcedStatus{}, response: forcedStatusResult{}},
+		}},
+		{orderStatusPath, (*WebFrontEndImpl).ForceOrderStatus, false, []managementOperation{
+			{method: "POST", name: "forceOrderStatus", summary: "Force the status of an order.",
+				pathParam: "id", request: forcedStatus{}, response: forcedStatusResult{}},
+		}},
+		{orderCSRsPath, (*WebFrontEndImpl).OrderCSRs, false, []managementOperation{
+			{method: "GET", name: "getOrderCSRs", summary: "Get the CSRs submitted to finalize an order.",
+				pathParam: "order", response: orderCSRs{}},
+		}},
+		{failuresPath, (*WebFrontEndImpl).Failures, false, []managementOperation{
+			{method: "GET", name: "getFailures", summary: "Get the orders and authorizations that became invalid.",
+				query: []managementParam{
+					{"account", "The ID or URL of the account of the failures.", ""},
+					{"since", "An RFC 3339 time the failures happened after.", ""},
+				},
+				response: []acme.FailureReport{}, list: true},
+		}},
+		{challengeTimingPath, (*WebFrontEndImpl).ChallengeTiming, false, []managementOperation{
+			{method: "GET", name: "getChallengeTiming", summary: "Get when a challenge's validation attempts ran.",
+				pathParam: "id", response: acme.ChallengeTiming{}},
+		}},
+		{keyAuthorizationPath, (*WebFrontEndImpl).ChallengeKeyAuthorization, false, []managementOperation{
+			{method: "GET", name: "getChallengeKeyAuthorization",
+				summary:   "Get the key authorization the VA expects for a challenge.",
+				pathParam: "id", response: acme.ExpectedKeyAuthorization{}},
+			{method: "POST", name: "overrideChallengeKeyAuthorization",
+				summary:   "Override the key authorization the VA expects for a pending challenge.",
+				pathParam: "id", request: keyAuthorizationOverride{}, response: acme.ExpectedKeyAuthorization{}},
+			{method: "DELETE", name: "clearChallengeKeyAuthorization",
+				summary:   "Remove the key authorization override of a pending challenge.",
+				pathParam: "id", response: acme.ExpectedKeyAuthorization{}},
+		}},
+		{responseSigningKeyPath, (*WebFrontEndImpl).ResponseSigningKey, false, []managementOperation{
+			{method: "GET", name: "getResponseSigningKey",
+				summary:  "Get the public key directory and certificate responses are signed with.",
+				response: acme.ResponseSigningKey{}},
+		}},
+		{orderReportPath, (*WebFrontEndImpl).OrderReport, false, []managementOperation{
+			{method: "GET", name: "getOrderReport", summary: "Get the report of every ACME interaction of an order.",
+				pathParam: "order",
+				query:     []managementParam{{"format", "json, the default, or html.", ""}},
+				response:  acme.OrderReport{}},
+		}},
+		{cancelOrderPath, (*WebFrontEndImpl).CancelOrder, false, []managementOperation{
+			{method: "POST", name: "cancelOrder", summary: "Make an in-flight order invalid.",
+				pathParam: "order", request: orderCancellation{}, optionalRequest: true,
+				response: forcedStatusResult{}},
+		}},
+		{autoFinalizedPath, (*WebFrontEndImpl).AutoFinalized, false, []managementOperation{
+			{method: "GET", name: "getAutoFinalized", summary: "Get the key and certificate of an auto-finalized order.",
+				pathParam: "order", response: acme.AutoFinalizedOrder{}},
+		}},
+		{testMetaPath, (*WebFrontEndImpl).TestMetaOrders, false, []managementOperation{
+			{method: "GET", name: "getTestMetaOrders", summary: "Get the orders created with test metadata.",
+				query:    []managementParam{{"value", "The test-meta value of the orders.", ""}},
+				response: []acme.TestMetaOrder{}, list: true},
+		}},
+		{revokeCertificatesPath, (*WebFrontEndImpl).RevokeCertificates, false, []managementOperation{
+			{method: "POST", name: "revokeCertificates", summary: "Revoke every certificate matching the request.",
+				request: bulkRevocation{}, response: acme.RevocationResult{}},
+		}},
+		{massRevocationsPath, (*WebFrontEndImpl).MassRevocations, false, []managementOperation{
+			{method: "GET", name: "listMassRevocations", summary: "List the mass revocation incidents started.",
+				response: []acme.MassRevocation{}, list: true},
+			{method: "POST", name: "startMassRevocation",
+				summary: "Revoke a percentage of the unrevoked certificates over a time window.",
+				request: massRevocationRequest{}, response: acme.MassRevocation{}, status: http.StatusAccepted},
+		}},
+		{externalAccountKeysPath, (*WebFrontEndImpl).ExternalAccountKeys, false, []managementOperation{
+			{method: "GET", name: "getExternalAccountKeys", summary: "Get the external account keys and bound accounts.",
+				response: acme.ExternalAccountBindings{}},
+			{method: "POST", name: "rotateExternalAccountKey", summary: "Add or rotate an external account key.",
+				request: externalAccountKeyRequest{}, response: acme.ExternalAccountKey{}},
+		}},
+		{revokeExternalAccountKeyPath, (*WebFrontEndImpl).RevokeExternalAccountKeyHandler, false, []managementOperation{
+			{method: "POST", name: "revokeExternalAccountKey", summary: "Revoke an external account key.",
+				request: externalAccountKeyRequest{}, response: acme.ExternalAccountKey{}},
+		}},
+		{namespacesPath, (*WebFrontEndImpl).Namespaces, false, []managementOperation{
+			{method: "GET", name: "listNamespaces", summary: "List the namespaces and their objects.",
+				response: []acme.Namespace{}, list: true},
+		}},
+		{wipeNamespacePath, (*WebFrontEndImpl).WipeNamespace, false, []managementOperation{
+			{method: "POST", name: "wipeNamespace", summary: "Remove the objects of a namespace.",
+				request: wipeNamespaceRequest{}, response: acme.Namespace{}},
+		}},
+		{dashboardPath, (*WebFrontEndImpl).Dashboard, false, []managementOperation{
+			{method: "GET", name: "getDashboard", summary: "Get the HTML dashboard of the store's contents.",
+				query:       []managementParam{{"refresh", "How often the page reloads itself in seconds, 5 by default.", "integer"}},
+				contentType: "text/html"},
+		}},
+		{dashboardJSONPath, (*WebFrontEndImpl).DashboardJSON, false, []managementOperation{
+			{method: "GET", name: "getDashboardData", summary: "Get the snapshot of the store shown by the dashboard.",
+				response: acme.Dashboard{}},
+		}},
+		{accountAttestationPath, (*WebFrontEndImpl).AccountAttestation, false, []managementOperation{
+			{method: "GET", name: "getAccountAttestation",
+				summary:   "Get the attestation object submitted when an account was created.",
+				pathParam: "id", response: acme.AccountAttestation{}},
+		}},
+		{accountAnnotationsPath, (*WebFrontEndImpl).AccountAnnotations, false, []managementOperation{
+			{method: "GET", name: "getAccountAnnotations", summary: "Get the annotations of an account.",
+				pathParam: "id", response: map[string]string{}},
+			{method: "POST", name: "annotateAccount",
+				summary:   "Merge annotations into those of an account, removing those with empty values.",
+				pathParam: "id", request: map[string]string{}, response: map[string]string{}},
+		}},
+		{orderAnnotationsPath, (*WebFrontEndImpl).OrderAnnotations, false, []managementOperation{
+			{method: "GET", name: "getOrderAnnotations", summary: "Get the annotations of an order.",
+				pathParam: "id", response: map[string]string{}},
+			{method: "POST", name: "annotateOrder",
+				summary:   "Merge annotations into those of an order, removing those with empty values.",
+				pathParam: "id", request: map[string]string{}, response: map[string]string{}},
+		}},
+		{certificateAnnotationsPath, (*WebFrontEndImpl).CertificateAnnotations, false, []managementOperation{
+			{method: "GET", name: "getCertificateAnnotations", summary: "Get the annotations of a certificate.",
+				pathParam: "serial", response: map[string]string{}},
+			{method: "POST", name: "annotateCertificate",
+				summary:   "Merge annotations into those of a certificate, removing those with empty values.",
+				pathParam: "serial", request: map[string]string{}, response: map[string]string{}},
+		}},
+		{annotatedPath, (*WebFrontEndImpl).Annotated, false, []managementOperation{
+			{method: "GET", name: "findAnnotated", summary: "Find the annotated accounts, orders and certificates.",
+				query: []managementParam{
+					{"collection", "Only find objects of the collection: accounts, orders or certificates.", ""},
+					{"selector", "An annotation selector, e.g. \"scenario=renewal,flaky\".", ""},
+				},
+				response: []acme.AnnotatedObject{}, list: true},
+		}},
+		{openAPIPath, (*WebFrontEndImpl).OpenAPI, false, []managementOperation{
+			{method: "GET", name: "getOpenAPI", summary: "Get this OpenAPI document.",
+				contentType: "application/json"},
+		}},
+		{healthzPath, (*WebFrontEndImpl).Healthz, true, []managementOperation{
+			{method: "GET", name: "getHealth", summary: "Check that the management interface is up.",
+				contentType: "text/plain"},
+		}},
+		{readyzPath, (*WebFrontEndImpl).Readyz, true, []managementOperation{
+			{method: "GET", name: "getReadiness", summary: "Check that Pebble is ready to serve ACME requests.",
+				contentType: "text/plain"},
+		}},
+	}
+
+	var err error
+	managementOpenAPIDocument, err = json.MarshalIndent(newManagementOpenAPI(managementEndpoints), "", "  ")
+	if err != nil {
+		panic(fmt.Sprintf("marshalling the management OpenAPI document: %s", err))
+	}
+}
+
+// ManagementHandler returns a http.Handler for Pebble's management interface.
+func (wfe *WebFrontEndImpl) ManagementHandler() http.Handler {
+	m := http.NewServeMux()
+	for _, e := range managementEndpoints {
+		e := e
+		handler := func(response http.ResponseWriter, request *http.Request) {
+			e.handler(wfe, response, request)
+		}
+		if e.public {
+			m.HandleFunc(e.path, handler)
+			continue
+		}
+		m.HandleFunc(e.path, wfe.managementHandler(wfe.listHandler(e, handler), e.methods()...))
+	}
+	if wfe.config.EnableProfiling {
+		wfe.handleProfiling(m)
+	}
+	return m
+}
+
+// methods returns the methods of the endpoint's operations.
+func (e managementEndpoint) methods() []string {
+	var methods []string
+	seen := make(map[string]bool)
+	for _, op := range e.operations {
+		if !seen[op.method] {
+			seen[op.method] = true
+			methods = append(methods, op.method)
+		}
+	}
+	return methods
+}
+
+// listHandler wraps the handler of an endpoint with a GET list operation so
+// that the list query parameters are applied to its GET responses.
+func (wfe *WebFrontEndImpl) listHandler(e managementEndpoint, handler http.HandlerFunc) http.HandlerFunc {
+	for _, op := range e.operations {
+		if op.method != "GET" || !op.list {
+			continue
+		}
+		fields := listFieldNames(op.response)
+		return func(response http.ResponseWriter, request *http.Request) {
+			if request.Method != "GET" {
+				handler(response, request)
+				return
+			}
+			wfe.serveList(response, request, handler, fields)
+		}
+	}
+	return handler
+}
+
+// managementHandler wraps a management endpoint handler so that only the given
+// methods are allowed, and only for authorized principals if any are
+// configured.
+func (wfe *WebFrontEndImpl) managementHandler(
+	handler http.HandlerFunc,
+	methods ...string) http.HandlerFunc {
+	return func(response http.ResponseWriter, request *http.Request) {
+		response.Header().Set(acme.ManagementVersionHeader, strconv.Itoa(acme.ManagementAPIVersion))
+		if prob := wfe.authorizeManagement(request); prob != nil {
+			if prob.HTTPStatus == http.StatusUnauthorized {
+				response.Header().Set("WWW-Authenticate", `Bearer realm="pebble-management"`)
+			}
+			wfe.sendError(prob, response)
+			return
+		}
+		for _, m := range methods {
+			if request.Method == m {
+				wfe.log.Printf("management: %s %s\n", request.Method, request.URL.Path)
+				if request.Method != "GET" {
+					wfe.config.Audit.Record(audit.TypeManagement, wfe.managementPrincipal(request),
+						map[string]string{
+							"method": request.Method,
+							"path":   request.URL.Path,
+							"query":  request.URL.RawQuery,
+						})
+				}
+				handler(response, request)
+				return
+			}
+		}
+		wfe.sendError(acme.MethodNotAllowed(), response)
+	}
+}
+
+// ValidationOutcomes returns the VA's validation outcome rules for a GET
+// request, and replaces them with the JSON array of rules in the body of a
+// POST request.
+func (wfe *WebFrontEndImpl) ValidationOutcomes(response http.ResponseWriter, request *http.Request) {
+	if request.Method == "POST" {
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+			return
+		}
+		var rules []va.OutcomeRule
+		if err := json.Unmarshal(body, &rules); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling validation outcome rules: %s", err.Error())), response)
+			return
+		}
+		if err := wfe.va.SetValidationOutcomes(rules); err != nil {
+			wfe.sendError(acme.MalformedProblem(err.Error()), response)
+			return
+		}
+		wfe.log.Printf("management: set %d validation outcome rules\n", len(rules))
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, wfe.va.ValidationOutcomes())
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling validation outcomes"), response)
+		return
+	}
+}
+
+// Latency returns the latency profiles for a GET request, and replaces them
+// with the JSON object of profiles keyed by endpoint name in the body of a POST
+// request.
+func (wfe *WebFrontEndImpl) Latency(response http.ResponseWriter, request *http.Request) {
+	if request.Method == "POST" {
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+			return
+		}
+		var profiles map[string]LatencyProfile
+		if err := json.Unmarshal(body, &profiles); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling latency profiles: %s", err.Error())), response)
+			return
+		}
+		if err := wfe.SetLatencyProfiles(profiles); err != nil {
+			wfe.sendError(acme.MalformedProblem(err.Error()), response)
+			return
+		}
+		wfe.log.Printf("management: set %d latency profiles\n", len(profiles))
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, wfe.LatencyProfiles())
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling latency profiles"), response)
+		return
+	}
+}
+
+// Maintenance returns the maintenance windows for a GET request, and replaces
+// them with the JSON array of windows in the body of a POST request. An empty
+// array ends maintenance.
+func (wfe *WebFrontEndImpl) Maintenance(response http.ResponseWriter, request *http.Request) {
+	if request.Method == "POST" {
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+			return
+		}
+		var windows []MaintenanceWindow
+		if err := json.Unmarshal(body, &windows); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling maintenance windows: %s", err.Error())), response)
+			return
+		}
+		if err := wfe.SetMaintenanceWindows(windows); err != nil {
+			wfe.sendError(acme.MalformedProblem(err.Error()), response)
+			return
+		}
+		wfe.log.Printf("management: set %d maintenance windows\n", len(windows))
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, wfe.MaintenanceWindows())
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling maintenance windows"), response)
+		return
+	}
+}
+
+// AccountOverridesHandler returns the account overrides for a GET request,
+// and replaces them with the JSON object of overrides keyed by account ID in
+// the body of a POST request.
+func (wfe *WebFrontEndImpl) AccountOverridesHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method == "POST" {
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+			return
+		}
+		var overrides map[string]AccountOverride
+		if err := json.Unmarshal(body, &overrides); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling account overrides: %s", err.Error())), response)
+			return
+		}
+		if err := wfe.SetAccountOverrides(overrides); err != nil {
+			wfe.sendError(acme.MalformedProblem(err.Error()), response)
+			return
+		}
+		wfe.log.Printf("management: set %d account overrides\n", len(overrides))
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, wfe.AccountOverrides())
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling account overrides"), response)
+		return
+	}
+}
+
+// ChallengeTypesHandler returns whether each challenge type is enabled for a
+// GET request, and enables or disables the challenge types in the JSON object
+// of booleans keyed by challenge type in the body of a POST request.
+func (wfe *WebFrontEndImpl) ChallengeTypesHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method == "POST" {
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+			return
+		}
+		var enabled map[string]bool
+		if err := json.Unmarshal(body, &enabled); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling challenge types: %s", err.Error())), response)
+			return
+		}
+		if err := wfe.SetChallengeTypes(enabled); err != nil {
+			wfe.sendError(acme.MalformedProblem(err.Error()), response)
+			return
+		}
+		wfe.log.Printf("management: challenge types are now %v\n", wfe.ChallengeTypes())
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, wfe.ChallengeTypes())
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling challenge types"), response)
+		return
+	}
+}
+
+// processingHolds is the response of the processing holds endpoint.
+type processingHolds struct {
+	Holds []ProcessingHold `json:"holds"`
+	Held  []string         `json:"held"`
+}
+
+// ProcessingHoldsHandler returns the processing holds and the IDs of the
+// orders currently held for a GET request, and replaces the holds with the
+// JSON array of holds in the body of a POST request.
+func (wfe *WebFrontEndImpl) ProcessingHoldsHandler(response http.ResponseWriter, request *http.Request) {
+	if request.Method == "POST" {
+		body, err := ioutil.ReadAll(request.Body)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+			return
+		}
+		var holds []ProcessingHold
+		if err := json.Unmarshal(body, &holds); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling processing holds: %s", err.Error())), response)
+			return
+		}
+		if err := wfe.SetProcessingHolds(holds); err != nil {
+			wfe.sendError(acme.MalformedProblem(err.Error()), response)
+			return
+		}
+		wfe.log.Printf("management: set %d processing holds\n", len(holds))
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, processingHolds{
+		Holds: wfe.ProcessingHolds(),
+		Held:  wfe.holds.heldOrders(),
+	})
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling processing holds"), response)
+		return
+	}
+}
+
+// ReleaseOrders releases the held order given by the "order" query parameter,
+// or every held order if there is none, and returns the IDs of the released
+// orders.
+func (wfe *WebFrontEndImpl) ReleaseOrders(response http.ResponseWriter, request *http.Request) {
+	released := wfe.holds.release(request.URL.Query().Get("order"))
+	wfe.log.Printf("management: released %d held orders\n", len(released))
+	err := wfe.writeJsonResponse(response, http.StatusOK, released)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling released orders"), response)
+	}
+}
+
+// delegationRequest is the body of a request to add a delegation.
+type delegationRequest struct {
+	Account string `json:"account"`
+	acme.Delegation
+}
+
+// delegationCreated is the response to a request adding a delegation.
+type delegationCreated struct {
+	ID   string `json:"id"`
+	Path string `json:"path"`
+}
+
+// NewDelegation adds the delegation in the JSON request body to the account
+// given by its "account" field, which may be an account ID or URL. The
+// delegation's ID and path on the ACME API are returned.
+func (wfe *WebFrontEndImpl) NewDelegation(response http.ResponseWriter, request *http.Request) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return
+	}
+	var delegationReq delegationRequest
+	if err := json.Unmarshal(body, &delegationReq); err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error unmarshaling delegation: %s", err.Error())), response)
+		return
+	}
+
+	acctID := wfe.parseAccountURL(delegationReq.Account)
+	delegation, err := wfe.AddDelegation(acctID, delegationReq.Delegation)
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error adding delegation: %s", err.Error())), response)
+		return
+	}
+
+	result := delegationCreated{delegation.ID, delegationPath + delegation.ID}
+	err = wfe.writeJsonResponse(response, http.StatusCreated, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling delegation"), response)
+		return
+	}
+}
+
+// SearchCertificates lists the unrevoked issued certificates matching the
+// query parameters: "san" (a DNS name), "account" (an account ID or URL),
+// "serialMin" and "serialMax" (hex serial numbers), and "issuedAfter" and
+// "issuedBefore" (RFC 3339 times compared with the NotBefore date). Every
+// parameter is optional. If "pem" is "true" each summary includes the
+// certificate in PEM format.
+func (wfe *WebFrontEndImpl) SearchCertificates(response http.ResponseWriter, request *http.Request) {
+	params := request.URL.Query()
+	query := db.CertificateQuery{
+		Name:      params.Get("san"),
+		AccountID: params.Get("account"),
+	}
+	if query.AccountID != "" {
+		query.AccountID = wfe.parseAccountURL(query.AccountID)
+	}
+
+	for _, p := range []struct {
+		name  string
+		value **big.Int
+	}{{"serialMin", &query.SerialMin}, {"serialMax", &query.SerialMax}} {
+		if v := params.Get(p.name); v != "" {
+			serial, ok := new(big.Int).SetString(v, 16)
+			if !ok {
+				wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+					"%s %q is not a hex serial number", p.name, v)), response)
+				return
+			}
+			*p.value = serial
+		}
+	}
+
+	for _, p := range []struct {
+		name  string
+		value *time.Time
+	}{{"issuedAfter", &query.IssuedAfter}, {"issuedBefore", &query.IssuedBefore}} {
+		if v := params.Get(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+					"%s %q is not an RFC 3339 time", p.name, v)), response)
+				return
+			}
+			*p.value = t
+		}
+	}
+	includePEM := params.Get("pem") == "true"
+	selector, err := db.ParseAnnotationSelector(params.Get("selector"))
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem(err.Error()), response)
+		return
+	}
+	query.Annotations = selector
+
+	results := []acme.CertificateSummary{}
+	for _, cert := range wfe.db.FindCertificates(query) {
+		summary := summarizeCertificate(cert)
+		if includePEM {
+			summary.PEM = string(cert.PEM())
+		}
+		results = append(results, summary)
+	}
+
+	err = wfe.writeJsonResponse(response, http.StatusOK, results)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling certificates"), response)
+		return
+	}
+}
+
+// StreamEvents streams status transitions of authorizations, challenges and
+// orders as server-sent events until the client disconnects. The "type" query
+// parameter optionally restricts the stream to a comma separated list of
+// event types.
+func (wfe *WebFrontEndImpl) StreamEvents(response http.ResponseWriter, request *http.Request) {
+	flusher, ok := response.(http.Flusher)
+	if !ok || wfe.config.Events == nil {
+		wfe.sendError(acme.InternalErrorProblem("Event streaming is not supported"), response)
+		return
+	}
+	var types map[string]bool
+	if t := request.URL.Query().Get("type"); t != "" {
+		types = make(map[string]bool)
+		for _, eventType := range strings.Split(t, ",") {
+			types[eventType] = true
+		}
+	}
+
+	stream, unsubscribe := wfe.config.Events.Subscribe()
+	defer unsubscribe()
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+
+	response.Header().Set("Content-Type", "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-request.Context().Done():
+			return
+		case <-keepAlive.C:
+			_, _ = fmt.Fprint(response, ": keep-alive\n\n")
+		case event := <-stream:
+			if types != nil && !types[event.Type] {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			_, _ = fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event.Type, data)
+		}
+		flusher.Flush()
+	}
+}
+
+// Root serves the root certificate of the chain with the index given in the
+// request path, e.g. /roots/0. A request for /roots/ lists all of the roots, or
+// serves them as a bundle if a format is requested.
+func (wfe *WebFrontEndImpl) Root(response http.ResponseWriter, request *http.Request) {
+	wfe.sendCACerts(response, request, rootsPath, func(no int) []*core.Certificate {
+		if cert := wfe.ca.GetRootCert(no); cert != nil {
+			return []*core.Certificate{cert}
+		}
+		return nil
+	})
+}
+
+// Intermediate serves the intermediate certificate of the chain with the index
+// given in the request path, e.g. /intermediates/0. A request for
+// /intermediates/ lists all of the intermediates, or serves them as a bundle if
+// a format is requested.
+func (wfe *WebFrontEndImpl) Intermediate(response http.ResponseWriter, request *http.Request) {
+	wfe.sendCACerts(response, request, intermediatesPath, func(no int) []*core.Certificate {
+		if cert := wfe.ca.GetIntermediateCert(no); cert != nil {
+			return []*core.Certificate{cert}
+		}
+		return nil
+	})
+}
+
+// Chain serves the intermediate followed by the root of the chain with the
+// index given in the request path, e.g. /chains/1 for the first alternate
+// chain when the default chain is 0. A request for /chains/ lists all of the
+// chains, or serves every chain as a bundle if a format is requested.
+func (wfe *WebFrontEndImpl) Chain(response http.ResponseWriter, request *http.Request) {
+	wfe.sendCACerts(response, request, chainsPath, wfe.ca.GetChain)
+}
+
+// sendCACerts serves the certificates returned by getCerts for the chain index
+// in the request path, in the format given by the "format" query parameter
+// (PEM by default). Without an index a JSON listing of every chain's
+// certificates is returned, or all of them are bundled together if a format is
+// given.
+func (wfe *WebFrontEndImpl) sendCACerts(
+	response http.ResponseWriter,
+	request *http.Request,
+	prefix string,
+	getCerts func(int) []*core.Certificate) {
+	format := request.URL.Query().Get("format")
+	index := strings.TrimPrefix(request.URL.Path, prefix)
+
+	if index == "" {
+		numChains := wfe.ca.NumberOfChains()
+		if format == "" {
+			list := []acme.CACertificate{}
+			for i := 0; i < numChains; i++ {
+				certs := getCerts(i)
+				if len(certs) == 0 {
+					continue
+				}
+				list = append(list, acme.CACertificate{
+					Index:    i,
+					Default:  i == wfe.ca.DefaultChain(),
+					Subject:  certs[0].Cert.Subject.String(),
+					Issuer:   certs[0].Cert.Issuer.String(),
+					NotAfter: certs[0].Cert.NotAfter.UTC().Format(time.RFC3339),
+					URL:      wfe.relativeEndpoint(request, prefix+strconv.Itoa(i)),
+				})
+			}
+			err := wfe.writeJsonResponse(response, http.StatusOK, list)
+			if err != nil {
+				wfe.sendError(acme.InternalErrorProblem("Error marshalling certificate list"), response)
+			}
+			return
+		}
+
+		var certs []*core.Certificate
+		for i := 0; i < numChains; i++ {
+			certs = append(certs, getCerts(i)...)
+		}
+		wfe.writeCACerts(response, format, certs)
+		return
+	}
+
+	no, err := strconv.Atoi(index)
+	if err != nil {
+		wfe.sendError(acme.NotFoundProblem("Certificate index must be a number"), response)
+		return
+	}
+	certs := getCerts(no)
+	if len(certs) == 0 {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No certificate with index %d", no)), response)
+		return
+	}
+	if format == "" {
+		format = caCertFormatPEM
+	}
+	wfe.writeCACerts(response, format, certs)
+}
+
+func (wfe *WebFrontEndImpl) writeCACerts(
+	response http.ResponseWriter,
+	format string,
+	certs []*core.Certificate) {
+	var body []byte
+	switch format {
+	case caCertFormatPEM:
+		for _, cert := range certs {
+			body = append(body, cert.PEM()...)
+		}
+		response.Header().Set("Content-Type", "application/pem-certificate-chain; charset=utf-8")
+	case caCertFormatDER:
+		if len(certs) != 1 {
+			wfe.sendError(acme.MalformedProblem(
+				"The der format can only be used for a single certificate, use pem or pkcs7"), response)
+			return
+		}
+		body = certs[0].DER
+		response.Header().Set("Content-Type", "application/pkix-cert")
+	case caCertFormatPKCS7:
+		var err error
+		body, err = core.PKCS7(certs)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem(fmt.Sprintf(
+				"Error encoding PKCS#7 bundle: %s", err.Error())), response)
+			return
+		}
+		response.Header().Set("Content-Type", "application/pkcs7-mime")
+	default:
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Unknown certificate format %q, use pem, der or pkcs7", format)), response)
+		return
+	}
+
+	response.WriteHeader(http.StatusOK)
+	_, _ = response.Write(body)
+}
+
+// rotateIssuersRequest is the optional body of a request to rotate issuers.
+type rotateIssuersRequest struct {
+	Root bool `json:"root"`
+}
+
+// RotateIssuers replaces the CA's issuing intermediate with a new one. If the
+// JSON request body has "root" set to true the roots are replaced as well.
+func (wfe *WebFrontEndImpl) RotateIssuers(response http.ResponseWriter, request *http.Request) {
+	var rotateReq rotateIssuersRequest
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return
+	}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &rotateReq); err != nil {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Error unmarshaling rotate issuers request: %s", err.Error())), response)
+			return
+		}
+	}
+
+	if err := wfe.ca.RotateIssuers(rotateReq.Root); err != nil {
+		wfe.sendError(acme.InternalErrorProblem(fmt.Sprintf(
+			"Error rotating issuers: %s", err.Error())), response)
+		return
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+// Store returns the number of objects and approximate memory usage of each of
+// the store's collections for a GET request to /store/. A DELETE request to
+// /store/<collection> removes every object in the collection.
+func (wfe *WebFrontEndImpl) Store(response http.ResponseWriter, request *http.Request) {
+	collection := strings.TrimPrefix(request.URL.Path, storePath)
+
+	if request.Method == "DELETE" {
+		if err := wfe.db.ClearCollection(collection); err != nil {
+			wfe.sendError(acme.NotFoundProblem(err.Error()), response)
+			return
+		}
+		wfe.log.Printf("management: cleared %s from the store\n", collection)
+		response.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	stats := wfe.db.Stats()
+	var result interface{} = stats
+	if collection != "" {
+		collStats, ok := stats[collection]
+		if !ok {
+			wfe.sendError(acme.NotFoundProblem(fmt.Sprintf(
+				"unknown collection %q", collection)), response)
+			return
+		}
+		result = collStats
+	}
+
+	err := wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling store stats"), response)
+		return
+	}
+}
+
+// Metrics serves the store statistics in the Prometheus text exposition
+// format.
+func (wfe *WebFrontEndImpl) Metrics(response http.ResponseWriter, request *http.Request) {
+	stats := wfe.db.Stats()
+	var names []string
+	for name := range stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteString("# HELP pebble_store_objects Number of objects in each store collection.\n")
+	sb.WriteString("# TYPE pebble_store_objects gauge\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "pebble_store_objects{collection=%q} %d\n", name, stats[name].Count)
+	}
+	sb.WriteString("# HELP pebble_store_approx_bytes Approximate memory used by each store collection.\n")
+	sb.WriteString("# TYPE pebble_store_approx_bytes gauge\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "pebble_store_approx_bytes{collection=%q} %d\n", name, stats[name].ApproxBytes)
+	}
+	evictions := wfe.db.Evictions()
+	sb.WriteString("# HELP pebble_store_evictions_total Number of objects evicted from each store collection to stay within the memory limit.\n")
+	sb.WriteString("# TYPE pebble_store_evictions_total counter\n")
+	for _, name := range names {
+		fmt.Fprintf(&sb, "pebble_store_evictions_total{collection=%q} %d\n", name, evictions[name])
+	}
+	queue := wfe.va.QueueStats()
+	sb.WriteString("# HELP pebble_va_queued_validations Number of validations waiting for a VA worker.\n")
+	sb.WriteString("# TYPE pebble_va_queued_validations gauge\n")
+	fmt.Fprintf(&sb, "pebble_va_queued_validations %d\n", queue.Queued)
+	sb.WriteString("# HELP pebble_va_active_validations Number of validations in progress.\n")
+	sb.WriteString("# TYPE pebble_va_active_validations gauge\n")
+	fmt.Fprintf(&sb, "pebble_va_active_validations %d\n", queue.Active)
+	sb.WriteString("# HELP pebble_va_max_concurrent_validations Maximum number of validations in progress, 0 if unlimited.\n")
+	sb.WriteString("# TYPE pebble_va_max_concurrent_validations gauge\n")
+	fmt.Fprintf(&sb, "pebble_va_max_concurrent_validations %d\n", queue.Workers)
+	signer := wfe.ca.SignerStats()
+	sb.WriteString("# HELP pebble_ca_signing_seconds Time taken by the intermediates' key to sign certificates.\n")
+	sb.WriteString("# TYPE pebble_ca_signing_seconds summary\n")
+	fmt.Fprintf(&sb, "pebble_ca_signing_seconds_sum %g\n", signer.TotalSeconds)
+	fmt.Fprintf(&sb, "pebble_ca_signing_seconds_count %d\n", signer.Signatures)
+	sb.WriteString("# HELP pebble_ca_signing_seconds_max Longest time taken by the intermediates' key to sign a certificate.\n")
+	sb.WriteString("# TYPE pebble_ca_signing_seconds_max gauge\n")
+	fmt.Fprintf(&sb, "pebble_ca_signing_seconds_max %g\n", signer.MaxSeconds)
+	sb.WriteString("# HELP pebble_ca_signing_errors_total Number of failed signatures by the intermediates' key.\n")
+	sb.WriteString("# TYPE pebble_ca_signing_errors_total counter\n")
+	fmt.Fprintf(&sb, "pebble_ca_signing_errors_total %d\n", signer.Errors)
+	sb.WriteString("# HELP pebble_timeouts_total Number of operations that timed out, by component and operation.\n")
+	sb.WriteString("# TYPE pebble_timeouts_total counter\n")
+	for _, c := range wfe.config.TimeoutCounter.Counts() {
+		fmt.Fprintf(&sb, "pebble_timeouts_total{component=%q,operation=%q} %d\n", c.Component, c.Operation, c.Count)
+	}
+	sb.WriteString("# HELP pebble_pending_order_rejections_total Number of new orders rejected because the account had too many pending orders.\n")
+	sb.WriteString("# TYPE pebble_pending_order_rejections_total counter\n")
+	fmt.Fprintf(&sb, "pebble_pending_order_rejections_total %d\n", wfe.pendingOrders.rejections())
+	sb.WriteString("# HELP pebble_malformed_jws_total Number of malformed JWS received, by the part or verification step that failed.\n")
+	sb.WriteString("# TYPE pebble_malformed_jws_total counter\n")
+	categories, counts := wfe.malformed.categories()
+	for _, category := range categories {
+		fmt.Fprintf(&sb, "pebble_malformed_jws_total{category=%q} %d\n", category, counts[category])
+	}
+	heap, peak, sampled := wfe.heapStats.snapshot()
+	sb.WriteString("# HELP pebble_heap_alloc_bytes Bytes of allocated heap objects, as of the last heap statistics sample.\n")
+	sb.WriteString("# TYPE pebble_heap_alloc_bytes gauge\n")
+	fmt.Fprintf(&sb, "pebble_heap_alloc_bytes %d\n", heap.HeapAlloc)
+	sb.WriteString("# HELP pebble_heap_alloc_peak_bytes Largest pebble_heap_alloc_bytes sampled since startup.\n")
+	sb.WriteString("# TYPE pebble_heap_alloc_peak_bytes gauge\n")
+	fmt.Fprintf(&sb, "pebble_heap_alloc_peak_bytes %d\n", peak)
+	sb.WriteString("# HELP pebble_heap_inuse_bytes Bytes in in-use heap spans, as of the last heap statistics sample.\n")
+	sb.WriteString("# TYPE pebble_heap_inuse_bytes gauge\n")
+	fmt.Fprintf(&sb, "pebble_heap_inuse_bytes %d\n", heap.HeapInuse)
+	sb.WriteString("# HELP pebble_heap_sys_bytes Bytes of heap memory obtained from the OS, as of the last heap statistics sample.\n")
+	sb.WriteString("# TYPE pebble_heap_sys_bytes gauge\n")
+	fmt.Fprintf(&sb, "pebble_heap_sys_bytes %d\n", heap.HeapSys)
+	sb.WriteString("# HELP pebble_heap_objects Number of allocated heap objects, as of the last heap statistics sample.\n")
+	sb.WriteString("# TYPE pebble_heap_objects gauge\n")
+	fmt.Fprintf(&sb, "pebble_heap_objects %d\n", heap.HeapObjects)
+	sb.WriteString("# HELP pebble_gc_cycles_total Number of completed GC cycles, as of the last heap statistics sample.\n")
+	sb.WriteString("# TYPE pebble_gc_cycles_total counter\n")
+	fmt.Fprintf(&sb, "pebble_gc_cycles_total %d\n", heap.NumGC)
+	sb.WriteString("# HELP pebble_heap_stats_sampled_timestamp_seconds When the heap statistics were last sampled.\n")
+	sb.WriteString("# TYPE pebble_heap_stats_sampled_timestamp_seconds gauge\n")
+	fmt.Fprintf(&sb, "pebble_heap_stats_sampled_timestamp_seconds %d\n", sampled.Unix())
+	sb.WriteString("# HELP pebble_goroutines Number of goroutines.\n")
+	sb.WriteString("# TYPE pebble_goroutines gauge\n")
+	fmt.Fprintf(&sb, "pebble_goroutines %d\n", runtime.NumGoroutine())
+
+	response.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	response.WriteHeader(http.StatusOK)
+	_, _ = response.Write([]byte(sb.String()))
+}
+
+// SeedStore pre-populates the store according to the SeedSpec in the JSON
+// request body and returns the seeded accounts.
+func (wfe *WebFrontEndImpl) SeedStore(response http.ResponseWriter, request *http.Request) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return
+	}
+	var spec SeedSpec
+	if err := json.Unmarshal(body, &spec); err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error unmarshaling seed spec: %s", err.Error())), response)
+		return
+	}
+
+	seeded, err := wfe.Seed(spec)
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error seeding store: %s", err.Error())), response)
+		return
+	}
+
+	err = wfe.writeJsonResponse(response, http.StatusCreated, seeded)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling seeded accounts"), response)
+		return
+	}
+}
+
+// CTLogs lists the simulated CT logs with their base64 encoded log IDs and
+// public keys so that clients can verify embedded SCTs.
+func (wfe *WebFrontEndImpl) CTLogs(response http.ResponseWriter, request *http.Request) {
+	list := []acme.CTLog{}
+	for _, log := range wfe.ca.CTLogs() {
+		list = append(list, acme.CTLog{
+			Description: log.Description,
+			URL:         log.URL,
+			LogID:       base64.StdEncoding.EncodeToString(log.ID[:]),
+			Key:         base64.StdEncoding.EncodeToString(log.PublicKey()),
+		})
+	}
+	err := wfe.writeJsonResponse(response, http.StatusOK, list)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling CT logs"), response)
+	}
+}
+
+// AuditLog returns the entries of the audit log as a JSON array, or a 404 if
+// auditing isn't enabled.
+func (wfe *WebFrontEndImpl) AuditLog(response http.ResponseWriter, request *http.Request) {
+	if wfe.config.Audit == nil {
+		wfe.sendError(acme.NotFoundProblem("Audit log is not enabled"), response)
+		return
+	}
+	entries := wfe.config.Audit.Entries()
+	if entries == nil {
+		entries = []audit.Entry{}
+	}
+	err := wfe.writeJsonResponse(response, http.StatusOK, entries)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling audit log"), response)
+	}
+}
@@ -0,0 +1,200 @@
+package wfe
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/db"
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// cessationOfOperationRevocationReason is the RFC 5280 reason code mass
+// revocations use by default.
+const cessationOfOperationRevocationReason = 5
+
+// massRevocationRequest starts a simulated mass revocation incident, like
+// those CAs have had to carry out after finding a compliance problem
+// affecting many certificates.
+type massRevocationRequest struct {
+	// Percent is the percentage of the unrevoked certificates matching
+	// Account and SAN that are revoked, rounded up to a whole certificate.
+	Percent float64 `json:"percent"`
+	// Window is how long in milliseconds the revocations are spread over.
+	// Zero revokes every selected certificate at once.
+	Window int `json:"window,omitempty"`
+	// Reason is the revocation reason, defaulting to cessationOfOperation.
+	Reason *uint `json:"reason,omitempty"`
+	// Account is the ID or URL of the account whose certificates are
+	// selected from. Empty selects from every account's.
+	Account string `json:"account,omitempty"`
+	// SAN is a pattern (see the pattern package) restricting the selection to
+	// certificates with a matching DNS name.
+	SAN string `json:"san,omitempty"`
+}
+
+func (r massRevocationRequest) check() *acme.ProblemDetails {
+	if r.Percent <= 0 || r.Percent > 100 {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Mass revocation percent %g must be greater than 0 and at most 100", r.Percent))
+	}
+	if r.Window < 0 {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Mass revocation window %d must not be negative", r.Window))
+	}
+	if err := pattern.Check(r.SAN); err != nil {
+		return acme.MalformedProblem(fmt.Sprintf("Invalid san: %s", err))
+	}
+	return validRevocationReason(r.Reason)
+}
+
+// massRevocations are the mass revocation incidents that were started.
+type massRevocations struct {
+	sync.Mutex
+	incidents []*acme.MassRevocation
+}
+
+// add numbers and adds an incident, returning a copy of it.
+func (m *massRevocations) add(incident *acme.MassRevocation) acme.MassRevocation {
+	m.Lock()
+	defer m.Unlock()
+	incident.ID = strconv.Itoa(len(m.incidents) + 1)
+	m.incidents = append(m.incidents, incident)
+	return *incident
+}
+
+// revoked counts a certificate of the incident as revoked.
+func (m *massRevocations) revoked(incident *acme.MassRevocation) {
+	m.Lock()
+	defer m.Unlock()
+	incident.Revoked++
+	if incident.Revoked == len(incident.Serials) {
+		incident.Status = acme.MassRevocationComplete
+	}
+}
+
+// list returns copies of the incidents.
+func (m *massRevocations) list() []acme.MassRevocation {
+	m.Lock()
+	defer m.Unlock()
+	list := make([]acme.MassRevocation, 0, len(m.incidents))
+	for _, incident := range m.incidents {
+		list = append(list, *incident)
+	}
+	return list
+}
+
+// startMassRevocation selects the certificates the request revokes and
+// revokes them in a random order, evenly spaced over its window, in the
+// background. Each revocation fires the revoked webhook and is audited like
+// one through the ACME API.
+func (wfe *WebFrontEndImpl) startMassRevocation(
+	req massRevocationRequest,
+	actor string) acme.MassRevocation {
+	reason := uint(cessationOfOperationRevocationReason)
+	if req.Reason != nil {
+		reason = *req.Reason
+	}
+	query := db.CertificateQuery{AccountID: req.Account}
+	if query.AccountID != "" {
+		query.AccountID = wfe.parseAccountURL(query.AccountID)
+	}
+
+	// The store also holds the issuers' certificates, which have no account
+	// and are never selected
+	var candidates []*core.Certificate
+	for _, cert := range wfe.db.FindCertificates(query) {
+		if cert.AccountID != "" && (req.SAN == "" || matchesSAN(cert, req.SAN)) {
+			candidates = append(candidates, cert)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	selected := candidates[:int(math.Ceil(float64(len(candidates))*req.Percent/100))]
+
+	window := time.Duration(req.Window) * time.Millisecond
+	now := wfe.clk.Now().UTC()
+	incident := &acme.MassRevocation{
+		Status:  acme.MassRevocationInProgress,
+		Reason:  reason,
+		Started: now.Format(time.RFC3339),
+		Ends:    now.Add(window).Format(time.RFC3339),
+		Serials: make([]string, 0, len(selected)),
+	}
+	for _, cert := range selected {
+		incident.Serials = append(incident.Serials, cert.ID)
+	}
+	if len(selected) == 0 {
+		incident.Status = acme.MassRevocationComplete
+	}
+	result := wfe.massRevocations.add(incident)
+
+	go func() {
+		var interval time.Duration
+		if len(selected) > 0 {
+			interval = window / time.Duration(len(selected))
+		}
+		for i, cert := range selected {
+			if i > 0 && interval > 0 {
+				<-wfe.clk.After(interval)
+			}
+			// Certificates revoked since they were selected are skipped
+			if wfe.db.GetCertificateByID(cert.ID) != nil {
+				wfe.revoke(cert, actor, &reason)
+			}
+			wfe.massRevocations.revoked(incident)
+		}
+		wfe.log.Printf("management: mass revocation %s revoked %d certificates\n",
+			incident.ID, len(selected))
+	}()
+	return result
+}
+
+// MassRevocations starts a mass revocation incident with the
+// massRevocationRequest in the body of a POST request and returns its
+// acme.MassRevocation. A GET request returns every incident started. Pebble
+// has no CRL or OCSP responder, so like other revocations the certificates
+// are forgotten rather than listed as revoked.
+func (wfe *WebFrontEndImpl) MassRevocations(response http.ResponseWriter, request *http.Request) {
+	if request.Method != "POST" {
+		err := wfe.writeJsonResponse(response, http.StatusOK, wfe.massRevocations.list())
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("Error marshalling mass revocations"), response)
+		}
+		return
+	}
+
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return
+	}
+	var req massRevocationRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error unmarshaling mass revocation: %s", err.Error())), response)
+		return
+	}
+	if prob := req.check(); prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+
+	result := wfe.startMassRevocation(req, wfe.managementPrincipal(request))
+	wfe.log.Printf("management: started mass revocation %s of %d certificates over %dms\n",
+		result.ID, len(result.Serials), req.Window)
+
+	err = wfe.writeJsonResponse(response, http.StatusAccepted, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling mass revocation"), response)
+	}
+}
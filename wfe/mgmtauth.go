@@ -0,0 +1,97 @@
+package wfe
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// A ManagementPrincipal is a client of the management interface and the
+// routes it may use. It is identified by a bearer token, a verified client
+// certificate or both.
+type ManagementPrincipal struct {
+	// Name identifies the principal in log messages.
+	Name string
+	// Token is sent by the principal in an "Authorization: Bearer" header.
+	Token string `secret:"true"`
+	// ClientCertCommonName is the subject common name of the principal's
+	// client certificate. The certificate must be verified by the management
+	// listener's client CAs.
+	ClientCertCommonName string
+	// Routes are the management paths the principal may use. A route ending
+	// in "/" also covers every path below it, and "*" covers every path.
+	Routes []string
+	// ReadOnly restricts the principal to GET requests.
+	ReadOnly bool
+}
+
+// authenticates returns true if the request carries the principal's
+// credentials. When both a token and a client certificate common name are
+// configured both must be presented.
+func (p ManagementPrincipal) authenticates(request *http.Request) bool {
+	if p.Token == "" && p.ClientCertCommonName == "" {
+		return false
+	}
+	if p.Token != "" {
+		token := strings.TrimPrefix(request.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(p.Token)) != 1 {
+			return false
+		}
+	}
+	if p.ClientCertCommonName != "" {
+		if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 ||
+			request.TLS.VerifiedChains[0][0].Subject.CommonName != p.ClientCertCommonName {
+			return false
+		}
+	}
+	return true
+}
+
+// allows returns true if the principal may make the request.
+func (p ManagementPrincipal) allows(request *http.Request) bool {
+	if p.ReadOnly && request.Method != "GET" {
+		return false
+	}
+	for _, route := range p.Routes {
+		if route == "*" || route == request.URL.Path ||
+			(strings.HasSuffix(route, "/") && strings.HasPrefix(request.URL.Path, route)) {
+			return true
+		}
+	}
+	return false
+}
+
+// managementPrincipal returns the name of the management principal a request
+// authenticates as, or "" if there is none.
+func (wfe *WebFrontEndImpl) managementPrincipal(request *http.Request) string {
+	for _, p := range wfe.config.ManagementPrincipals {
+		if p.authenticates(request) {
+			return p.Name
+		}
+	}
+	return ""
+}
+
+// authorizeManagement returns a problem if management principals are
+// configured and the request doesn't authenticate as one that may make it.
+func (wfe *WebFrontEndImpl) authorizeManagement(request *http.Request) *acme.ProblemDetails {
+	if len(wfe.config.ManagementPrincipals) == 0 {
+		return nil
+	}
+	for _, p := range wfe.config.ManagementPrincipals {
+		if !p.authenticates(request) {
+			continue
+		}
+		if !p.allows(request) {
+			wfe.log.Printf("management: %s is not allowed to %s %s\n",
+				p.Name, request.Method, request.URL.Path)
+			return acme.UnauthorizedProblem("Not allowed to use this management route")
+		}
+		return nil
+	}
+	prob := acme.UnauthorizedProblem("Management requests must be authenticated")
+	prob.HTTPStatus = http.StatusUnauthorized
+	return prob
+}
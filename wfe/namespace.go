@@ -0,0 +1,178 @@
+package wfe
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/db"
+)
+
+// defaultNamespaceHeader is the request header naming the namespace of a
+// request if Namespaces.Header is empty.
+const defaultNamespaceHeader = "X-Pebble-Namespace"
+
+// namespaceNameRegexp matches the allowed namespace names.
+var namespaceNameRegexp = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// Namespaces isolates the clients of a shared Pebble instance, such as
+// concurrent CI jobs, from each other. Every request is in the namespace
+// named by a request header, and accounts are tagged with the namespace they
+// were created in. Requests for an account from another namespace are
+// rejected, and the duplicate certificate and per-IP rate limits are counted
+// per namespace. Requests without the header are in the default namespace.
+type Namespaces struct {
+	// Header is the request header naming the namespace. Defaults to
+	// X-Pebble-Namespace.
+	Header string
+	// APIKeys maps API keys to the namespaces they select. If it is set the
+	// header holds an API key rather than a namespace name, and requests with
+	// an unknown API key are rejected.
+	APIKeys map[string]string
+}
+
+func (n *Namespaces) check() error {
+	if n == nil {
+		return nil
+	}
+	for _, name := range n.APIKeys {
+		if !namespaceNameRegexp.MatchString(name) {
+			return fmt.Errorf("invalid namespace name %q", name)
+		}
+	}
+	return nil
+}
+
+// namespace returns the namespace of the request, or a problem if the
+// request's header doesn't select a valid namespace.
+func (n *Namespaces) namespace(request *http.Request) (string, *acme.ProblemDetails) {
+	if n == nil {
+		return "", nil
+	}
+	header := n.Header
+	if header == "" {
+		header = defaultNamespaceHeader
+	}
+	value := request.Header.Get(header)
+	if value == "" {
+		return "", nil
+	}
+	if len(n.APIKeys) > 0 {
+		name, ok := n.APIKeys[value]
+		if !ok {
+			return "", acme.UnauthorizedProblem(fmt.Sprintf(
+				"Unknown API key in the %s header", header))
+		}
+		return name, nil
+	}
+	if !namespaceNameRegexp.MatchString(value) {
+		return "", acme.MalformedProblem(fmt.Sprintf(
+			"Invalid namespace %q in the %s header", value, header))
+	}
+	return value, nil
+}
+
+// namespaceContextKey is the request context key holding the namespace of a
+// request.
+type namespaceContextKey struct{}
+
+// withNamespace returns the request with its namespace set.
+func withNamespace(request *http.Request, namespace string) *http.Request {
+	ctx := context.WithValue(request.Context(), namespaceContextKey{}, namespace)
+	return request.WithContext(ctx)
+}
+
+// requestNamespace returns the namespace of the request, which is empty for
+// the default namespace.
+func requestNamespace(request *http.Request) string {
+	namespace, _ := request.Context().Value(namespaceContextKey{}).(string)
+	return namespace
+}
+
+// namespaced prefixes the rate limit key with the namespace, so that clients
+// in different namespaces are limited separately.
+func namespaced(namespace, key string) string {
+	if namespace == "" {
+		return key
+	}
+	return namespace + "|" + key
+}
+
+func namespaceForDisplay(name string, objects db.NamespaceObjects) acme.Namespace {
+	return acme.Namespace{
+		Name:         name,
+		Accounts:     append([]string{}, objects.Accounts...),
+		Orders:       append([]string{}, objects.Orders...),
+		Certificates: append([]string{}, objects.Certificates...),
+	}
+}
+
+// Namespaces returns the accounts, orders and certificates of every
+// namespace for a GET request, sorted by name. The name query parameter
+// selects a single namespace.
+func (wfe *WebFrontEndImpl) Namespaces(response http.ResponseWriter, request *http.Request) {
+	namespaces := wfe.db.Namespaces()
+	if name := request.URL.Query().Get("name"); name != "" {
+		objects, ok := namespaces[name]
+		if !ok {
+			wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No namespace %q", name)), response)
+			return
+		}
+		err := wfe.writeJsonResponse(response, http.StatusOK, namespaceForDisplay(name, objects))
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("Error marshalling namespace"), response)
+		}
+		return
+	}
+
+	result := make([]acme.Namespace, 0, len(namespaces))
+	for name, objects := range namespaces {
+		result = append(result, namespaceForDisplay(name, objects))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	err := wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling namespaces"), response)
+	}
+}
+
+// wipeNamespaceRequest is the body of a request to wipe a namespace.
+type wipeNamespaceRequest struct {
+	Name string `json:"name"`
+}
+
+// WipeNamespace removes the accounts of the namespace named in the JSON body
+// of a POST request, with their orders, authorizations, challenges and
+// certificates, and returns what was removed.
+func (wfe *WebFrontEndImpl) WipeNamespace(response http.ResponseWriter, request *http.Request) {
+	body, err := ioutil.ReadAll(request.Body)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("unable to read request body"), response)
+		return
+	}
+	var req wipeNamespaceRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Error unmarshaling wipe namespace request: %s", err.Error())), response)
+		return
+	}
+	if req.Name == "" {
+		wfe.sendError(acme.MalformedProblem("The default namespace can't be wiped"), response)
+		return
+	}
+
+	wiped := wfe.db.WipeNamespace(req.Name)
+	wfe.log.Printf("management: wiped namespace %q: %d accounts, %d orders and %d certificates\n",
+		req.Name, len(wiped.Accounts), len(wiped.Orders), len(wiped.Certificates))
+	err = wfe.writeJsonResponse(response, http.StatusOK, namespaceForDisplay(req.Name, wiped))
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling namespace"), response)
+	}
+}
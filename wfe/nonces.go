@@ -1,11 +1,19 @@
 package wfe
 
 import (
-	"crypto/rand"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"fmt"
 	"io"
+	"strings"
 	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+
+	"github.com/letsencrypt/pebble/cryptomode"
 )
 
 /*
@@ -17,6 +25,8 @@ import (
 const nonceLen = 16
 
 /*
+ * nonceMap is the default nonceService.
+ *
  * Note: We place no upper bound on the number of nonces we issue. We obtain
  * a lock for both issuing nonces and checking them. This is *not* a performant
  * or safe strategy for a production server. Consider the NonceServer
@@ -38,9 +48,9 @@ func (n *nonceMap) createNonce() string {
 	n.Lock()
 	defer n.Unlock()
 
-	// Read `nonceLen` random bytes from rand.Reader
+	// Read `nonceLen` random bytes from cryptomode.Reader()
 	b := make([]byte, nonceLen)
-	_, err := io.ReadFull(rand.Reader, b)
+	_, err := io.ReadFull(cryptomode.Reader(), b)
 	if err != nil {
 		panic(fmt.Sprintf("Error reading random bytes: %s", err))
 	}
@@ -66,3 +76,115 @@ func (n *nonceMap) validNonce(nonce string) bool {
 
 	return false
 }
+
+// nonceService creates nonces and checks them. A nonce is valid once.
+type nonceService interface {
+	createNonce() string
+	validNonce(nonce string) bool
+}
+
+// derivedNonces is a nonceService, like Boulder's, whose nonces are
+// authenticated with an HMAC rather than recorded when they are created. Any
+// instance configured with the same key validates them, including after a
+// restart. Each nonce is its instance's prefix followed by the base64 URL
+// encoding of its creation time, random bytes and the HMAC of the prefix and
+// both. Nonces are valid until they are older than the lifetime, and used
+// nonces are remembered for that long to reject their reuse. Used nonces
+// aren't shared, so a nonce can be used once at each instance.
+type derivedNonces struct {
+	sync.Mutex
+	key      []byte
+	prefix   string
+	lifetime time.Duration
+	clk      clock.Clock
+
+	used      map[string]time.Time
+	nextPrune time.Time
+}
+
+const (
+	derivedNonceTimeLen = 8
+	derivedNonceMACLen  = 16
+)
+
+func newDerivedNonces(key, prefix string, lifetime time.Duration, clk clock.Clock) (*derivedNonces, error) {
+	for _, c := range prefix {
+		if !strings.ContainsRune(base64URLAlphabet, c) {
+			return nil, fmt.Errorf("nonce prefix %q must only use base64 URL characters", prefix)
+		}
+	}
+	if lifetime <= 0 {
+		return nil, fmt.Errorf("nonce lifetime must be positive")
+	}
+	hashedKey := sha256.Sum256([]byte(key))
+	return &derivedNonces{
+		key:      hashedKey[:],
+		prefix:   prefix,
+		lifetime: lifetime,
+		clk:      clk,
+		used:     make(map[string]time.Time),
+	}, nil
+}
+
+// base64URLAlphabet holds the characters of unpadded base64url encoded values.
+const base64URLAlphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+func (n *derivedNonces) mac(prefix string, payload []byte) []byte {
+	h := hmac.New(sha256.New, n.key)
+	_, _ = h.Write([]byte(prefix))
+	_, _ = h.Write(payload)
+	return h.Sum(nil)[:derivedNonceMACLen]
+}
+
+func (n *derivedNonces) createNonce() string {
+	payload := make([]byte, derivedNonceTimeLen+nonceLen)
+	binary.BigEndian.PutUint64(payload, uint64(n.clk.Now().UnixNano()))
+	_, err := io.ReadFull(cryptomode.Reader(), payload[derivedNonceTimeLen:])
+	if err != nil {
+		panic(fmt.Sprintf("Error reading random bytes: %s", err))
+	}
+	payload = append(payload, n.mac(n.prefix, payload)...)
+	return n.prefix + base64.RawURLEncoding.EncodeToString(payload)
+}
+
+func (n *derivedNonces) validNonce(nonce string) bool {
+	// Nonces are validated whichever instance's prefix they have, which is
+	// covered by the HMAC. The encoded payload has a fixed length, so the
+	// prefix is whatever precedes it.
+	encodedLen := base64.RawURLEncoding.EncodedLen(
+		derivedNonceTimeLen + nonceLen + derivedNonceMACLen)
+	if len(nonce) < encodedLen {
+		return false
+	}
+	prefix := nonce[:len(nonce)-encodedLen]
+	payload, err := base64.RawURLEncoding.DecodeString(nonce[len(prefix):])
+	if err != nil {
+		return false
+	}
+	signed, mac := payload[:derivedNonceTimeLen+nonceLen], payload[derivedNonceTimeLen+nonceLen:]
+	if !hmac.Equal(mac, n.mac(prefix, signed)) {
+		return false
+	}
+	created := time.Unix(0, int64(binary.BigEndian.Uint64(signed)))
+	now := n.clk.Now()
+	expires := created.Add(n.lifetime)
+	if now.After(expires) {
+		return false
+	}
+
+	n.Lock()
+	defer n.Unlock()
+	if now.After(n.nextPrune) {
+		for used, usedExpires := range n.used {
+			if now.After(usedExpires) {
+				delete(n.used, used)
+			}
+		}
+		n.nextPrune = now.Add(n.lifetime)
+	}
+	if _, present := n.used[nonce]; present {
+		return false
+	}
+	n.used[nonce] = expires
+	return true
+}
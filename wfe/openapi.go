@@ -0,0 +1,385 @@
+package wfe
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// openAPIVersion is the version of the OpenAPI specification the management
+// interface's document follows.
+const openAPIVersion = "3.0.3"
+
+// The OpenAPI objects below are the subset of the specification used to
+// describe the management interface.
+type openAPIDocument struct {
+	OpenAPI    string                                  `json:"openapi"`
+	Info       openAPIInfo                             `json:"info"`
+	Paths      map[string]map[string]*openAPIOperation `json:"paths"`
+	Components openAPIComponents                       `json:"components"`
+	Security   []map[string][]string                   `json:"security"`
+}
+
+type openAPIInfo struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Version     string `json:"version"`
+}
+
+type openAPIComponents struct {
+	Schemas         map[string]*openAPISchema         `json:"schemas"`
+	SecuritySchemes map[string]map[string]interface{} `json:"securitySchemes"`
+}
+
+type openAPIOperation struct {
+	OperationID string                      `json:"operationId"`
+	Summary     string                      `json:"summary"`
+	Parameters  []openAPIParameter          `json:"parameters,omitempty"`
+	RequestBody *openAPIBody                `json:"requestBody,omitempty"`
+	Responses   map[string]*openAPIResponse `json:"responses"`
+	Security    *[]map[string][]string      `json:"security,omitempty"`
+	// List marks the operations accepting the list query parameters.
+	List bool `json:"x-pebble-list,omitempty"`
+}
+
+type openAPIParameter struct {
+	Name        string         `json:"name"`
+	In          string         `json:"in"`
+	Description string         `json:"description,omitempty"`
+	Required    bool           `json:"required,omitempty"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIBody struct {
+	Required bool                    `json:"required,omitempty"`
+	Content  map[string]openAPIMedia `json:"content"`
+}
+
+type openAPIResponse struct {
+	Description string                   `json:"description"`
+	Headers     map[string]openAPIHeader `json:"headers,omitempty"`
+	Content     map[string]openAPIMedia  `json:"content,omitempty"`
+}
+
+type openAPIHeader struct {
+	Description string         `json:"description"`
+	Schema      *openAPISchema `json:"schema"`
+}
+
+type openAPIMedia struct {
+	Schema *openAPISchema `json:"schema"`
+}
+
+// An openAPISchema with no fields set matches any JSON value.
+type openAPISchema struct {
+	Ref                  string                    `json:"$ref,omitempty"`
+	Type                 string                    `json:"type,omitempty"`
+	Format               string                    `json:"format,omitempty"`
+	Items                *openAPISchema            `json:"items,omitempty"`
+	Properties           map[string]*openAPISchema `json:"properties,omitempty"`
+	Required             []string                  `json:"required,omitempty"`
+	AdditionalProperties *openAPISchema            `json:"additionalProperties,omitempty"`
+}
+
+// listParams are the query parameters of every list operation.
+var listParams = []managementParam{
+	{listLimitParam, "The most items returned, up to " + strconv.Itoa(maxListLimit) + ". Zero or absent returns every item.", "integer"},
+	{listOffsetParam, "How many of the filtered and sorted items are skipped.", "integer"},
+	{listSortParam, "Comma separated fields the items are sorted by, descending if prefixed with \"-\".", ""},
+	{listFilterParam, "A field:value pair. Only items whose field has the value, or is an array including it, are returned. May be repeated.", ""},
+	{listFieldsParam, "Comma separated fields each item is returned with. Defaults to every field.", ""},
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+	marshalerType  = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// A schemaField is a field of a struct as encoding/json sees it.
+type schemaField struct {
+	name      string
+	typ       reflect.Type
+	omitempty bool
+}
+
+// schemaFields returns the JSON fields of a struct type in order, with the
+// fields of embedded structs promoted as encoding/json does.
+func schemaFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	seen := make(map[string]bool)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		if tag[0] == "-" {
+			continue
+		}
+		if f.Anonymous && tag[0] == "" && f.Type.Kind() == reflect.Struct {
+			for _, promoted := range schemaFields(f.Type) {
+				if !seen[promoted.name] {
+					seen[promoted.name] = true
+					fields = append(fields, promoted)
+				}
+			}
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		field := schemaField{name: tag[0], typ: f.Type}
+		if field.name == "" {
+			field.name = f.Name
+		}
+		for _, opt := range tag[1:] {
+			if opt == "omitempty" {
+				field.omitempty = true
+			}
+		}
+		if !seen[field.name] {
+			seen[field.name] = true
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// schemaBuilder builds the schemas of Go types, adding a component schema for
+// each named struct type.
+type schemaBuilder struct {
+	schemas map[string]*openAPISchema
+	names   map[reflect.Type]string
+}
+
+func (b *schemaBuilder) schema(t reflect.Type) *openAPISchema {
+	switch t {
+	case timeType:
+		return &openAPISchema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &openAPISchema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.schema(t.Elem())
+	case reflect.String:
+		return &openAPISchema{Type: "string"}
+	case reflect.Bool:
+		return &openAPISchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &openAPISchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &openAPISchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &openAPISchema{Type: "string", Format: "byte"}
+		}
+		return &openAPISchema{Type: "array", Items: b.schema(t.Elem())}
+	case reflect.Map:
+		return &openAPISchema{Type: "object", AdditionalProperties: b.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.object(t)
+		}
+		return &openAPISchema{Ref: "#/components/schemas/" + b.component(t)}
+	}
+	return &openAPISchema{}
+}
+
+// component returns the name of the component schema of a named struct
+// type, adding it if needed. Types are named as they are in Go, prefixed
+// with their package if the name is taken.
+func (b *schemaBuilder) component(t reflect.Type) string {
+	if name, ok := b.names[t]; ok {
+		return name
+	}
+	name := exportedName(t.Name())
+	if _, taken := b.schemas[name]; taken {
+		pkg := t.PkgPath()
+		name = exportedName(pkg[strings.LastIndex(pkg, "/")+1:]) + name
+	}
+	b.names[t] = name
+	// The schema is added before its fields are described so that recursive
+	// types refer to it
+	s := &openAPISchema{}
+	b.schemas[name] = s
+	*s = *b.object(t)
+	return name
+}
+
+func (b *schemaBuilder) object(t reflect.Type) *openAPISchema {
+	s := &openAPISchema{Type: "object", Properties: make(map[string]*openAPISchema)}
+	for _, f := range schemaFields(t) {
+		s.Properties[f.name] = b.schema(f.typ)
+		if !f.omitempty {
+			s.Required = append(s.Required, f.name)
+		}
+	}
+	// Types marshalling themselves, like problem documents with configured
+	// extension fields, may have more fields than they declare
+	if t.Implements(marshalerType) || reflect.PtrTo(t).Implements(marshalerType) {
+		s.AdditionalProperties = &openAPISchema{}
+	}
+	return s
+}
+
+func exportedName(name string) string {
+	if name == "" {
+		return name
+	}
+	r := []rune(name)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// typeSchema returns the schema of the type of v, or nil if v is nil.
+func (b *schemaBuilder) typeSchema(v interface{}) *openAPISchema {
+	if v == nil {
+		return nil
+	}
+	return b.schema(reflect.TypeOf(v))
+}
+
+func paramSchema(kind string) *openAPISchema {
+	if kind == "" {
+		kind = "string"
+	}
+	return &openAPISchema{Type: kind}
+}
+
+// newManagementOpenAPI describes the management endpoints in an OpenAPI
+// document.
+func newManagementOpenAPI(endpoints []managementEndpoint) *openAPIDocument {
+	b := &schemaBuilder{
+		schemas: make(map[string]*openAPISchema),
+		names:   make(map[reflect.Type]string),
+	}
+	problem := &openAPIResponse{
+		Description: "A problem document describing why the request failed.",
+		Content: map[string]openAPIMedia{
+			"application/problem+json": {b.typeSchema(acme.ProblemDetails{})},
+		},
+	}
+	versionHeader := openAPIHeader{
+		Description: "The version of the management API's types.",
+		Schema:      paramSchema("integer"),
+	}
+
+	doc := &openAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: openAPIInfo{
+			Title: "Pebble management API",
+			Description: "The management interface of the Pebble ACME test server. " +
+				"It is not an ACME API: requests are plain HTTP requests with JSON bodies.",
+			Version: strconv.Itoa(acme.ManagementAPIVersion),
+		},
+		Paths: make(map[string]map[string]*openAPIOperation),
+		Components: openAPIComponents{
+			Schemas: b.schemas,
+			SecuritySchemes: map[string]map[string]interface{}{
+				"bearer": {"type": "http", "scheme": "bearer"},
+			},
+		},
+		// Management authentication is optional, the empty requirement allows
+		// requests without a token
+		Security: []map[string][]string{{"bearer": {}}, {}},
+	}
+
+	for _, e := range endpoints {
+		for _, op := range e.operations {
+			path := e.path
+			o := &openAPIOperation{
+				OperationID: op.name,
+				Summary:     op.summary,
+				Responses:   map[string]*openAPIResponse{"default": problem},
+				List:        op.list,
+			}
+			if e.public {
+				o.Security = &[]map[string][]string{}
+			}
+
+			if op.pathParam != "" {
+				path += "{" + op.pathParam + "}"
+				o.Parameters = append(o.Parameters, openAPIParameter{
+					Name:     op.pathParam,
+					In:       "path",
+					Required: true,
+					Schema:   paramSchema(""),
+				})
+			}
+			query := op.query
+			if op.list {
+				query = append(append([]managementParam{}, query...), listParams...)
+			}
+			for _, p := range query {
+				o.Parameters = append(o.Parameters, openAPIParameter{
+					Name:        p.name,
+					In:          "query",
+					Description: p.description,
+					Schema:      paramSchema(p.kind),
+				})
+			}
+
+			if op.request != nil {
+				o.RequestBody = &openAPIBody{
+					Required: !op.optionalRequest,
+					Content: map[string]openAPIMedia{
+						"application/json": {b.typeSchema(op.request)},
+					},
+				}
+			}
+
+			status := op.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			resp := &openAPIResponse{
+				Description: http.StatusText(status),
+				Headers:     map[string]openAPIHeader{acme.ManagementVersionHeader: versionHeader},
+			}
+			switch {
+			case op.contentType != "":
+				resp.Content = map[string]openAPIMedia{op.contentType: {&openAPISchema{Type: "string"}}}
+			case op.response != nil:
+				resp.Content = map[string]openAPIMedia{"application/json": {b.typeSchema(op.response)}}
+			}
+			if op.list {
+				resp.Headers[acme.ManagementTotalCountHeader] = openAPIHeader{
+					Description: "The number of items that passed the filters.",
+					Schema:      paramSchema("integer"),
+				}
+				resp.Headers["Link"] = openAPIHeader{
+					Description: "Links to the next page with relation \"next\" if there is one.",
+					Schema:      paramSchema(""),
+				}
+			}
+			o.Responses[strconv.Itoa(status)] = resp
+
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*openAPIOperation)
+			}
+			doc.Paths[path][strings.ToLower(op.method)] = o
+		}
+	}
+	return doc
+}
+
+// ManagementOpenAPI returns the OpenAPI document describing the management
+// interface, as served at /openapi.json. Management API clients are generated
+// from it.
+func ManagementOpenAPI() []byte {
+	return append([]byte(nil), managementOpenAPIDocument...)
+}
+
+// OpenAPI serves the OpenAPI document describing the management interface.
+func (wfe *WebFrontEndImpl) OpenAPI(response http.ResponseWriter, request *http.Request) {
+	response.Header().Set("Content-Type", "application/json; charset=utf-8")
+	response.WriteHeader(http.StatusOK)
+	_, _ = response.Write(managementOpenAPIDocument)
+}
@@ -0,0 +1,86 @@
+package wfe
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+const (
+	// OrderingRandom shuffles the lists on every response. It is the default.
+	OrderingRandom = "random"
+	// OrderingSeeded shuffles the lists with a permutation derived from the
+	// seed and the list's length, so that runs with the same seed reproduce
+	// the same orderings.
+	OrderingSeeded = "seeded"
+	// OrderingCreation keeps the lists in the order their elements were
+	// created, which clients must not rely on.
+	OrderingCreation = "creation"
+	// OrderingReverse reverses the order the elements were created in.
+	OrderingReverse = "reverse"
+)
+
+// ResponseOrdering sets the order of the identifiers and authorizations of
+// orders and of the challenges of authorizations in responses. RFC 8555 says
+// clients must not rely on the order of these lists.
+type ResponseOrdering struct {
+	// Mode is OrderingRandom, OrderingSeeded, OrderingCreation or
+	// OrderingReverse. Empty is OrderingRandom.
+	Mode string `json:"mode,omitempty"`
+	// Seed is the seed of OrderingSeeded.
+	Seed int64 `json:"seed,omitempty"`
+	// Challenges are challenge types listed first, in the given order, in
+	// every authorization. Other challenges follow in the order of Mode.
+	Challenges []string `json:"challenges,omitempty"`
+}
+
+func (o ResponseOrdering) check() error {
+	switch o.Mode {
+	case "", OrderingRandom, OrderingSeeded, OrderingCreation, OrderingReverse:
+	default:
+		return fmt.Errorf("unknown response ordering mode %q", o.Mode)
+	}
+	return nil
+}
+
+// permute reorders the n elements of the named list with swap.
+func (o ResponseOrdering) permute(list string, n int, swap func(i, j int)) {
+	switch o.Mode {
+	case OrderingCreation:
+	case OrderingReverse:
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	case OrderingSeeded:
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(list))
+		rand.New(rand.NewSource(o.Seed^int64(h.Sum64()))).Shuffle(n, swap)
+	default:
+		rand.Shuffle(n, swap)
+	}
+}
+
+// orderChallenges reorders the challenges according to Mode and moves the
+// types in Challenges to the front.
+func (o ResponseOrdering) orderChallenges(chals []*acme.Challenge) {
+	o.permute("challenges", len(chals), func(i, j int) {
+		chals[i], chals[j] = chals[j], chals[i]
+	})
+	if len(o.Challenges) == 0 {
+		return
+	}
+	rank := func(chalType string) int {
+		for i, t := range o.Challenges {
+			if t == chalType {
+				return i
+			}
+		}
+		return len(o.Challenges)
+	}
+	sort.SliceStable(chals, func(i, j int) bool {
+		return rank(chals[i].Type) < rank(chals[j].Type)
+	})
+}
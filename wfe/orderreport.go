@@ -0,0 +1,413 @@
+package wfe
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+const (
+	defaultReportMaxBodyBytes    = 4096
+	defaultReportMaxInteractions = 100
+
+	// reportCaptureBytes is how much of each request and response body is
+	// captured to decode JWS payloads and problem documents before they are
+	// truncated to MaxBodyBytes.
+	reportCaptureBytes = 1 << 20
+)
+
+// reportedEndpoints are the endpoints whose requests are recorded for order
+// reports.
+var reportedEndpoints = map[string]bool{
+	"newOrder":    true,
+	"order":       true,
+	"finalize":    true,
+	"authz":       true,
+	"challenge":   true,
+	"certificate": true,
+}
+
+// OrderReports records the requests about each order, and the responses to
+// them, so that a report of everything that happened to an order can be
+// exported from the management interface, e.g. as an artifact of a failed CI
+// run.
+type OrderReports struct {
+	// MaxBodyBytes is how much of each request and response body is kept.
+	// Defaults to 4096.
+	MaxBodyBytes int
+	// MaxInteractions is how many requests are kept for each order, the
+	// oldest being dropped first. Defaults to 100.
+	MaxInteractions int
+}
+
+func (r *OrderReports) maxBodyBytes() int {
+	if r.MaxBodyBytes <= 0 {
+		return defaultReportMaxBodyBytes
+	}
+	return r.MaxBodyBytes
+}
+
+func (r *OrderReports) maxInteractions() int {
+	if r.MaxInteractions <= 0 {
+		return defaultReportMaxInteractions
+	}
+	return r.MaxInteractions
+}
+
+// limitedBuffer keeps the first max bytes written to it and discards the
+// rest, remembering that it did.
+type limitedBuffer struct {
+	bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
+
+// teeReadCloser copies what is read from a request body to a buffer.
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// interactionRecorder records a request and its response for order reports.
+type interactionRecorder struct {
+	http.ResponseWriter
+	start        time.Time
+	maxBodyBytes int
+	status       int
+	request      limitedBuffer
+	response     limitedBuffer
+}
+
+func newInteractionRecorder(
+	response http.ResponseWriter,
+	request *http.Request,
+	maxBodyBytes int,
+	start time.Time) *interactionRecorder {
+	r := &interactionRecorder{
+		ResponseWriter: response,
+		start:          start,
+		maxBodyBytes:   maxBodyBytes,
+		request:        limitedBuffer{max: reportCaptureBytes},
+		response:       limitedBuffer{max: reportCaptureBytes},
+	}
+	if request.Body != nil {
+		request.Body = teeReadCloser{io.TeeReader(request.Body, &r.request), request.Body}
+	}
+	return r
+}
+
+func (r *interactionRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *interactionRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	_, _ = r.response.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *interactionRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// requestPayload returns the decoded payload of a flattened JWS request body,
+// or the body itself if it isn't one.
+func requestPayload(body []byte) string {
+	var jws struct {
+		Protected string  `json:"protected"`
+		Payload   *string `json:"payload"`
+	}
+	if err := json.Unmarshal(body, &jws); err != nil || jws.Protected == "" || jws.Payload == nil {
+		return string(body)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(*jws.Payload, "="))
+	if err != nil {
+		return string(body)
+	}
+	return string(payload)
+}
+
+// truncate returns the body cut to the recorder's MaxBodyBytes, and whether it
+// was cut or had already been cut while capturing it.
+func (r *interactionRecorder) truncate(body string, captureTruncated bool) (string, bool) {
+	if len(body) > r.maxBodyBytes {
+		return body[:r.maxBodyBytes], true
+	}
+	return body, captureTruncated
+}
+
+// interaction returns the recorded request and response.
+func (r *interactionRecorder) interaction(
+	endpoint string,
+	logEvent *requestEvent,
+	request *http.Request,
+	now time.Time) acme.Interaction {
+	status := r.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	result := acme.Interaction{
+		Time:       r.start.UTC().Format(time.RFC3339Nano),
+		Method:     request.Method,
+		Endpoint:   endpoint,
+		Path:       logEvent.Endpoint,
+		Status:     status,
+		DurationMs: float64(now.Sub(r.start)) / float64(time.Millisecond),
+		Location:   r.Header().Get("Location"),
+	}
+	var requestTruncated, responseTruncated bool
+	result.Request, requestTruncated = r.truncate(requestPayload(r.request.Bytes()), r.request.truncated)
+	result.Response, responseTruncated = r.truncate(r.response.String(), r.response.truncated)
+	result.Truncated = requestTruncated || responseTruncated
+	if strings.HasPrefix(r.Header().Get("Content-Type"), "application/problem+json") {
+		var prob acme.ProblemDetails
+		if err := json.Unmarshal(r.response.Bytes(), &prob); err == nil {
+			result.ProblemType = prob.Type
+		}
+	}
+	return result
+}
+
+// interactionOrders returns the orders a request to the endpoint with the
+// given ID, the request path after the endpoint's prefix, is about.
+// Authorizations and challenges are about the order the authorization was
+// created for.
+func (wfe *WebFrontEndImpl) interactionOrders(endpoint, id string, r *interactionRecorder) []*core.Order {
+	switch endpoint {
+	case "newOrder":
+		location := r.Header().Get("Location")
+		i := strings.LastIndex(location, orderPath)
+		if i < 0 {
+			return nil
+		}
+		id = location[i+len(orderPath):]
+	case "authz", "challenge":
+		var authz *core.Authorization
+		if endpoint == "authz" {
+			authz = wfe.db.GetAuthorizationByID(id)
+		} else if chal := wfe.db.GetChallengeByID(id); chal != nil {
+			chal.RLock()
+			authz = chal.Authz
+			chal.RUnlock()
+		}
+		if authz == nil {
+			return nil
+		}
+		authz.RLock()
+		defer authz.RUnlock()
+		if authz.Order == nil {
+			return nil
+		}
+		return []*core.Order{authz.Order}
+	case "certificate":
+		// Certificates are served under their serial, or under the order ID
+		// while the certificate isn't ready
+		id = strings.SplitN(id, "/", 2)[0]
+		if wfe.db.GetCertificateByID(id) != nil {
+			return wfe.db.FindOrders(func(o *core.Order) bool {
+				return o.CertificateObject != nil && o.CertificateObject.ID == id
+			})
+		}
+	}
+
+	order, err := wfe.db.GetOrderByID(id)
+	if err != nil || order == nil {
+		return nil
+	}
+	return []*core.Order{order}
+}
+
+// recordInteraction adds the recorded request and response to the orders it
+// is about.
+func (wfe *WebFrontEndImpl) recordInteraction(
+	pattern string,
+	logEvent *requestEvent,
+	request *http.Request,
+	r *interactionRecorder) {
+	endpoint := endpointNames[pattern]
+	interaction := r.interaction(endpoint, logEvent, request, wfe.clk.Now())
+	max := wfe.config.OrderReports.maxInteractions()
+	for _, order := range wfe.interactionOrders(endpoint, request.URL.Path, r) {
+		order.Lock()
+		order.Interactions = append(order.Interactions, interaction)
+		if len(order.Interactions) > max {
+			order.Interactions = order.Interactions[len(order.Interactions)-max:]
+		}
+		order.Unlock()
+	}
+}
+
+// describeChallengeTiming returns when validation of the challenge with the
+// given ID started and completed and its validation attempts. The caller must
+// hold the challenge's lock.
+func describeChallengeTiming(id string, chal *acme.Challenge) acme.ChallengeTiming {
+	timing := acme.ChallengeTiming{
+		ID:                  id,
+		Type:                chal.Type,
+		Status:              chal.Status,
+		ValidationStarted:   chal.ValidationStarted,
+		ValidationCompleted: chal.ValidationCompleted,
+		Attempts:            append([]acme.ValidationAttempt{}, chal.Attempts...),
+	}
+	started, err := time.Parse(time.RFC3339Nano, timing.ValidationStarted)
+	if err == nil {
+		if completed, err := time.Parse(time.RFC3339Nano, timing.ValidationCompleted); err == nil {
+			timing.DurationMs = float64(completed.Sub(started)) / float64(time.Millisecond)
+		}
+	}
+	return timing
+}
+
+func (wfe *WebFrontEndImpl) orderReport(order *core.Order) acme.OrderReport {
+	order.RLock()
+	report := acme.OrderReport{
+		Generated:      wfe.clk.Now().UTC().Format(time.RFC3339),
+		ID:             order.ID,
+		Account:        order.AccountID,
+		Status:         order.Status,
+		Identifiers:    order.Identifiers,
+		Expires:        order.Expires,
+		Error:          order.Error,
+		Interactions:   append([]acme.Interaction{}, order.Interactions...),
+		Authorizations: []acme.AuthorizationReport{},
+	}
+	authzs := order.AuthorizationObjects
+	cert := order.CertificateObject
+	order.RUnlock()
+
+	for _, authz := range authzs {
+		authz.RLock()
+		authzReport := acme.AuthorizationReport{
+			ID:         authz.ID,
+			Identifier: authz.Identifier,
+			Status:     authz.Status,
+			Challenges: []acme.ChallengeTiming{},
+		}
+		chals := authz.Challenges
+		authz.RUnlock()
+		for _, c := range chals {
+			chal := wfe.db.GetChallengeByID(path.Base(c.URL))
+			if chal == nil {
+				continue
+			}
+			chal.RLock()
+			authzReport.Challenges = append(authzReport.Challenges, describeChallengeTiming(chal.ID, &chal.Challenge))
+			chal.RUnlock()
+		}
+		report.Authorizations = append(report.Authorizations, authzReport)
+	}
+	if cert != nil {
+		report.Certificate = string(cert.Chain())
+	}
+	return report
+}
+
+var orderReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Order {{.ID}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; }
+td, th { border: 1px solid #ccc; padding: 4px; text-align: left; vertical-align: top; }
+pre { margin: 0; white-space: pre-wrap; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>Order {{.ID}}</h1>
+<p>Account {{.Account}}, status <b>{{.Status}}</b>, expires {{.Expires}}. Generated {{.Generated}}.</p>
+<ul>{{range .Identifiers}}<li>{{.Type}}: {{.Value}}</li>{{end}}</ul>
+{{with .Error}}<h2>Error</h2><p>{{.Type}}: {{.Detail}}</p>{{end}}
+<h2>Requests</h2>
+<table>
+<tr><th>Time</th><th>Request</th><th>Status</th><th>Duration (ms)</th><th>Request payload</th><th>Response</th></tr>
+{{range .Interactions}}<tr>
+<td>{{.Time}}</td><td>{{.Method}} {{.Path}}</td><td>{{.Status}} {{.ProblemType}}</td><td>{{printf "%.1f" .DurationMs}}</td>
+<td><pre>{{.Request}}</pre></td><td>{{with .Location}}Location: {{.}}<br>{{end}}<pre>{{.Response}}</pre>{{if .Truncated}}<i>truncated</i>{{end}}</td>
+</tr>{{end}}
+</table>
+<h2>Authorizations</h2>
+{{range .Authorizations}}<h3>{{.Identifier.Type}} {{.Identifier.Value}}: {{.Status}}</h3>
+<table>
+<tr><th>Challenge</th><th>Status</th><th>Validation started</th><th>Completed</th><th>Duration (ms)</th><th>Attempts</th></tr>
+{{range .Challenges}}<tr>
+<td>{{.Type}}</td><td>{{.Status}}</td><td>{{.ValidationStarted}}</td><td>{{.ValidationCompleted}}</td><td>{{printf "%.1f" .DurationMs}}</td>
+<td>{{range .Attempts}}#{{.Attempt}} {{.Started}} - {{.Completed}}{{with .Error}}: {{.Type}}: {{.Detail}}{{end}}<br>{{end}}</td>
+</tr>{{end}}
+</table>
+{{end}}
+{{with .Certificate}}<h2>Certificate</h2><pre>{{.}}</pre>{{end}}
+</body>
+</html>
+`))
+
+// OrderReport returns a report of the order with the ID at the end of the
+// request path: every recorded request about it and the response, the
+// validation of its authorizations and its certificate. The report is JSON
+// unless the format query parameter is "html".
+func (wfe *WebFrontEndImpl) OrderReport(response http.ResponseWriter, request *http.Request) {
+	if wfe.config.OrderReports == nil {
+		wfe.sendError(acme.NotFoundProblem(
+			"Order reports aren't recorded, enable orderReports"), response)
+		return
+	}
+	orderID := strings.TrimPrefix(request.URL.Path, orderReportPath)
+	order, err := wfe.db.GetOrderByID(orderID)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+		return
+	}
+	if order == nil {
+		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf("No order %q found", orderID)), response)
+		return
+	}
+	report := wfe.orderReport(order)
+
+	switch format := request.URL.Query().Get("format"); format {
+	case "", "json":
+		err = wfe.writeJsonResponse(response, http.StatusOK, report)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("Error marshalling order report"), response)
+		}
+	case "html":
+		var buf bytes.Buffer
+		if err := orderReportTemplate.Execute(&buf, report); err != nil {
+			wfe.sendError(acme.InternalErrorProblem("Error rendering order report"), response)
+			return
+		}
+		response.Header().Set("Content-Type", "text/html; charset=utf-8")
+		response.WriteHeader(http.StatusOK)
+		_, _ = response.Write(buf.Bytes())
+	default:
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf("Unknown report format %q", format)), response)
+	}
+}
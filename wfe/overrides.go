@@ -0,0 +1,208 @@
+package wfe
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/pattern"
+	"github.com/letsencrypt/pebble/va"
+)
+
+// An AccountOverride changes how Pebble behaves for one account, so that
+// tests sharing a Pebble instance can each exercise a different scenario.
+type AccountOverride struct {
+	// ValidationOutcome forces every validation of the account's challenges
+	// to succeed (va.OutcomeValid) or fail (va.OutcomeInvalid) without making
+	// any validation requests.
+	ValidationOutcome string `json:"validationOutcome,omitempty"`
+	// Latency is added to the latency of every request signed with the
+	// account's key ID.
+	Latency *LatencyProfile `json:"latency,omitempty"`
+	// RequireEAB rejects new-account requests for the account's key without an
+	// externalAccountBinding, as if the view required one.
+	RequireEAB bool `json:"requireEAB,omitempty"`
+	// ChallengeTypes are the only challenge types offered in the account's new
+	// authorizations for non-wildcard DNS identifiers. Empty means every
+	// challenge type the challenge policies and view offer.
+	ChallengeTypes []string `json:"challengeTypes,omitempty"`
+	// FailEndpoints are the names of ACME endpoints, as in the maxBodySizes
+	// config, whose requests signed with the account's key ID fail with a
+	// serverInternal problem.
+	FailEndpoints []string `json:"failEndpoints,omitempty"`
+}
+
+func (o AccountOverride) check() error {
+	switch o.ValidationOutcome {
+	case "", va.OutcomeValid, va.OutcomeInvalid:
+	default:
+		return fmt.Errorf("unknown validation outcome %q", o.ValidationOutcome)
+	}
+	if o.Latency != nil {
+		if err := o.Latency.check(); err != nil {
+			return err
+		}
+	}
+	for _, chalType := range o.ChallengeTypes {
+		switch chalType {
+		case acme.ChallengeHTTP01, acme.ChallengeTLSALPN01, acme.ChallengeDNS01:
+		default:
+			return fmt.Errorf("unsupported challenge type %q", chalType)
+		}
+	}
+	for _, endpoint := range o.FailEndpoints {
+		if !knownEndpointName(endpoint) {
+			return fmt.Errorf("unknown endpoint %q", endpoint)
+		}
+	}
+	return nil
+}
+
+// allowsChallenge returns true if the override doesn't prevent the challenge
+// type being offered.
+func (o AccountOverride) allowsChallenge(chalType string) bool {
+	if len(o.ChallengeTypes) == 0 {
+		return true
+	}
+	for _, t := range o.ChallengeTypes {
+		if t == chalType {
+			return true
+		}
+	}
+	return false
+}
+
+// fails returns true if the override makes requests to the endpoint fail.
+func (o AccountOverride) fails(endpoint string) bool {
+	for _, e := range o.FailEndpoints {
+		if e == endpoint {
+			return true
+		}
+	}
+	return false
+}
+
+// delay returns the artificial latency to add to a request signed by the
+// account, or zero if the override has no latency profile.
+func (o AccountOverride) delay() time.Duration {
+	if o.Latency == nil {
+		return 0
+	}
+	return o.Latency.delay()
+}
+
+// merge returns the override with the fields it leaves unset taken from
+// other.
+func (o AccountOverride) merge(other AccountOverride) AccountOverride {
+	if o.ValidationOutcome == "" {
+		o.ValidationOutcome = other.ValidationOutcome
+	}
+	if o.Latency == nil {
+		o.Latency = other.Latency
+	}
+	o.RequireEAB = o.RequireEAB || other.RequireEAB
+	if len(o.ChallengeTypes) == 0 {
+		o.ChallengeTypes = other.ChallengeTypes
+	}
+	if len(o.FailEndpoints) == 0 {
+		o.FailEndpoints = other.FailEndpoints
+	}
+	return o
+}
+
+// overrideTable holds the account overrides keyed by account ID or by a
+// pattern (see the pattern package) matching account IDs.
+type overrideTable struct {
+	sync.RWMutex
+	overrides map[string]AccountOverride
+	acctIDs   []string
+}
+
+func newOverrideTable() *overrideTable {
+	return &overrideTable{overrides: make(map[string]AccountOverride)}
+}
+
+// set replaces all of the overrides in the table. The validation outcomes of
+// the overrides are passed on to the VA.
+func (t *overrideTable) set(overrides map[string]AccountOverride, v *va.VAImpl) error {
+	outcomes := make(map[string]string)
+	for acctID, o := range overrides {
+		if acctID == "" {
+			return fmt.Errorf("account override with an empty account ID")
+		}
+		if err := pattern.Check(acctID); err != nil {
+			return fmt.Errorf("account override: %s", err)
+		}
+		if err := o.check(); err != nil {
+			return fmt.Errorf("account override for %q: %s", acctID, err)
+		}
+		if o.ValidationOutcome != "" {
+			outcomes[acctID] = o.ValidationOutcome
+		}
+	}
+
+	t.Lock()
+	defer t.Unlock()
+	if err := v.SetAccountOutcomes(outcomes); err != nil {
+		return err
+	}
+	t.overrides = make(map[string]AccountOverride, len(overrides))
+	t.acctIDs = make([]string, 0, len(overrides))
+	for acctID, o := range overrides {
+		t.overrides[acctID] = o
+		t.acctIDs = append(t.acctIDs, acctID)
+	}
+	return nil
+}
+
+func (t *overrideTable) getAll() map[string]AccountOverride {
+	t.RLock()
+	defer t.RUnlock()
+	overrides := make(map[string]AccountOverride, len(t.overrides))
+	for acctID, o := range t.overrides {
+		overrides[acctID] = o
+	}
+	return overrides
+}
+
+// get returns the override for the account, or the zero AccountOverride if it
+// has none. An override keyed by the account ID takes precedence over those
+// keyed by patterns, of which the longest matching one is used.
+func (t *overrideTable) get(acctID string) AccountOverride {
+	t.RLock()
+	defer t.RUnlock()
+	key, ok := pattern.Lookup(t.acctIDs, acctID)
+	if !ok || acctID == "" {
+		return AccountOverride{}
+	}
+	return t.overrides[key]
+}
+
+// accountOverride returns the override of the account: the one set in the
+// table, with the fields it leaves unset taken from the account's contact
+// directives.
+func (wfe *WebFrontEndImpl) accountOverride(acctID string) AccountOverride {
+	if acctID == "" {
+		return AccountOverride{}
+	}
+	override := wfe.overrides.get(acctID)
+	if acct := wfe.db.GetAccountByID(acctID); acct != nil {
+		// Directives were checked when the contacts were set
+		directives, _ := contactOverride(acct.Contact)
+		override = override.merge(directives)
+	}
+	return override
+}
+
+// AccountOverrides returns the account overrides keyed by account ID.
+func (wfe *WebFrontEndImpl) AccountOverrides() map[string]AccountOverride {
+	return wfe.overrides.getAll()
+}
+
+// SetAccountOverrides replaces the account overrides. Overrides can be set for
+// accounts that don't exist yet, e.g. to require an external account binding
+// when they are created.
+func (wfe *WebFrontEndImpl) SetAccountOverrides(overrides map[string]AccountOverride) error {
+	return wfe.overrides.set(overrides, wfe.va)
+}
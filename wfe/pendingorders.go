@@ -0,0 +1,82 @@
+package wfe
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// pendingOrderLimit limits how many pending orders an account may have at
+// once. Orders being created count towards the limit until they are stored,
+// so that concurrent new-order requests can't exceed it.
+type pendingOrderLimit struct {
+	sync.Mutex
+	max int
+
+	// creating counts the orders of each account that passed the check but
+	// aren't stored yet.
+	creating map[string]int
+	rejected int64
+}
+
+func newPendingOrderLimit(max int) *pendingOrderLimit {
+	if max <= 0 {
+		return nil
+	}
+	return &pendingOrderLimit{max: max, creating: make(map[string]int)}
+}
+
+// reserve counts an order being created for the account and returns a
+// function releasing it, to be called once the order is stored or abandoned.
+// It returns false, counting a rejection, if the account's pending orders,
+// as returned by count, and the orders being created reach the limit. A nil
+// pendingOrderLimit allows every order.
+func (l *pendingOrderLimit) reserve(acctID string, count func() int) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	l.Lock()
+	defer l.Unlock()
+	if count()+l.creating[acctID] >= l.max {
+		l.rejected++
+		return nil, false
+	}
+	l.creating[acctID]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.Lock()
+			defer l.Unlock()
+			l.creating[acctID]--
+			if l.creating[acctID] == 0 {
+				delete(l.creating, acctID)
+			}
+		})
+	}, true
+}
+
+// rejections returns how many orders were rejected for exceeding the limit.
+func (l *pendingOrderLimit) rejections() int64 {
+	if l == nil {
+		return 0
+	}
+	l.Lock()
+	defer l.Unlock()
+	return l.rejected
+}
+
+// reservePendingOrder reserves a pending order for the account, returning a
+// rateLimited problem if it already has the most pending orders allowed.
+func (wfe *WebFrontEndImpl) reservePendingOrder(acctID string) (func(), *acme.ProblemDetails) {
+	release, ok := wfe.pendingOrders.reserve(acctID, func() int {
+		return wfe.db.CountPendingOrders(acctID)
+	})
+	if !ok {
+		return nil, acme.RateLimitedProblem(fmt.Sprintf(
+			"Too many pending orders (%d) for this account, complete some or wait for them to expire",
+			wfe.pendingOrders.max))
+	}
+	return release, nil
+}
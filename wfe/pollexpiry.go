@@ -0,0 +1,73 @@
+package wfe
+
+import (
+	"time"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+// extendedExpiry returns the expiry of an object polled now, if polling
+// extends it, and whether it changed. initial is the expiry the object was
+// created with.
+func (wfe *WebFrontEndImpl) extendedExpiry(expires, initial time.Time) (time.Time, bool) {
+	now := wfe.clk.Now()
+	if wfe.config.PollExpiryExtension <= 0 || !expires.After(now) {
+		return expires, false
+	}
+	extended := now.Add(wfe.config.PollExpiryExtension)
+	if max := wfe.config.MaxPollExpiryExtension; max > 0 && extended.After(initial.Add(max)) {
+		extended = initial.Add(max)
+	}
+	if !extended.After(expires) {
+		return expires, false
+	}
+	return extended, true
+}
+
+// extendOrderExpiry pushes back the expiry of a polled pending or ready order
+// if Config.PollExpiryExtension is set.
+func (wfe *WebFrontEndImpl) extendOrderExpiry(order *core.Order) {
+	if wfe.config.PollExpiryExtension <= 0 {
+		return
+	}
+	status, _ := order.GetStatus(wfe.clk)
+	if status != acme.StatusPending && status != acme.StatusReady {
+		return
+	}
+
+	order.Lock()
+	defer order.Unlock()
+	initial := order.InitialExpiresDate
+	if initial.IsZero() {
+		initial = order.ExpiresDate
+	}
+	if expires, ok := wfe.extendedExpiry(order.ExpiresDate, initial); ok {
+		order.InitialExpiresDate = initial
+		order.ExpiresDate = expires
+		order.Expires = expires.UTC().Format(time.RFC3339)
+	}
+}
+
+// extendAuthzExpiry pushes back the expiry of a polled pending authorization
+// if Config.PollExpiryExtension is set.
+func (wfe *WebFrontEndImpl) extendAuthzExpiry(authz *core.Authorization) {
+	if wfe.config.PollExpiryExtension <= 0 {
+		return
+	}
+
+	authz.Lock()
+	defer authz.Unlock()
+	if authz.Status != acme.StatusPending {
+		return
+	}
+	initial := authz.InitialExpiresDate
+	if initial.IsZero() {
+		initial = authz.ExpiresDate
+	}
+	if expires, ok := wfe.extendedExpiry(authz.ExpiresDate, initial); ok {
+		authz.InitialExpiresDate = initial
+		authz.ExpiresDate = expires
+		authz.Expires = expires.UTC().Format(time.RFC3339)
+	}
+}
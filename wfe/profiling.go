@@ -0,0 +1,76 @@
+package wfe
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+)
+
+// pprofPath serves the runtime profiles when profiling is enabled. It can't
+// be changed since pprof.Index serves the profile named after it.
+//
+// Importing net/http/pprof also registers its handlers on
+// http.DefaultServeMux, which none of Pebble's servers use.
+const pprofPath = "/debug/pprof/"
+
+// DefaultHeapStatsInterval is how often heap statistics are sampled for the
+// metrics endpoint by default.
+const DefaultHeapStatsInterval = 15 * time.Second
+
+// handleProfiling adds the pprof endpoints to the management mux, behind the
+// management authentication.
+func (wfe *WebFrontEndImpl) handleProfiling(m *http.ServeMux) {
+	m.HandleFunc(pprofPath, wfe.managementHandler(pprof.Index, "GET"))
+	m.HandleFunc(pprofPath+"cmdline", wfe.managementHandler(pprof.Cmdline, "GET"))
+	m.HandleFunc(pprofPath+"profile", wfe.managementHandler(pprof.Profile, "GET"))
+	m.HandleFunc(pprofPath+"symbol", wfe.managementHandler(pprof.Symbol, "GET", "POST"))
+	m.HandleFunc(pprofPath+"trace", wfe.managementHandler(pprof.Trace, "GET"))
+}
+
+// heapStats holds the runtime's memory statistics, sampled periodically
+// rather than on every metrics request since runtime.ReadMemStats stops the
+// world.
+type heapStats struct {
+	sync.Mutex
+	stats   runtime.MemStats
+	peak    uint64
+	sampled time.Time
+}
+
+// newHeapStats samples the memory statistics every interval until the
+// process exits.
+func newHeapStats(clk clock.Clock, interval time.Duration) *heapStats {
+	h := &heapStats{}
+	h.sample(clk.Now())
+	go func() {
+		for {
+			<-clk.After(interval)
+			h.sample(clk.Now())
+		}
+	}()
+	return h
+}
+
+func (h *heapStats) sample(now time.Time) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	h.Lock()
+	defer h.Unlock()
+	h.stats = stats
+	if stats.HeapAlloc > h.peak {
+		h.peak = stats.HeapAlloc
+	}
+	h.sampled = now
+}
+
+// snapshot returns the last sample, the largest heap allocation sampled and
+// when the last sample was taken.
+func (h *heapStats) snapshot() (runtime.MemStats, uint64, time.Time) {
+	h.Lock()
+	defer h.Unlock()
+	return h.stats, h.peak, h.sampled
+}
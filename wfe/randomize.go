@@ -0,0 +1,96 @@
+package wfe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+)
+
+// acmePath returns the path an ACME endpoint is served under. If paths are
+// randomized every endpoint except the directory is served under the random
+// per-boot path prefix. Other paths, like those of the management interface,
+// are returned as-is.
+func (wfe *WebFrontEndImpl) acmePath(endpoint string) string {
+	if wfe.pathPrefix == "" || endpoint == directoryPath {
+		return endpoint
+	}
+	for p := range endpointNames {
+		if endpoint == p || (strings.HasSuffix(p, "/") && strings.HasPrefix(endpoint, p)) {
+			return wfe.pathPrefix + endpoint
+		}
+	}
+	return endpoint
+}
+
+// prefixHandler serves the directory from its usual path and every other
+// endpoint only under the random path prefix, so clients that don't discover
+// the endpoints from the directory get 404 errors. Issuer certificates are
+// served from their usual path too since they are found through issued
+// certificates' CA Issuers URLs.
+func (wfe *WebFrontEndImpl) prefixHandler(handler http.Handler) http.Handler {
+	m := http.NewServeMux()
+	m.Handle(directoryPath, handler)
+	m.Handle(issuerCertPath, handler)
+	m.Handle(wfe.pathPrefix+"/", http.StripPrefix(wfe.pathPrefix, handler))
+	return m
+}
+
+// unknownFields returns one to three fields named "x-pebble-..." with random
+// string or object values.
+func unknownFields() map[string]interface{} {
+	fields := make(map[string]interface{}, 3)
+	for i := rand.Intn(3) + 1; i > 0; i-- {
+		name := fmt.Sprintf("x-pebble-%s", randomString(6))
+		if rand.Intn(2) == 0 {
+			fields[name] = randomString(12)
+		} else {
+			fields[name] = map[string]interface{}{"ignore": rand.Intn(1000)}
+		}
+	}
+	return fields
+}
+
+// shuffledJSON marshals the object with its fields in a random order and a
+// few unknown fields added, which clients are required to ignore (RFC 8555
+// Section 7.1).
+func shuffledJSON(object map[string]interface{}) ([]byte, error) {
+	fields := unknownFields()
+	for k, v := range object {
+		fields[k] = v
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(fields[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "   "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
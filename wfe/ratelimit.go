@@ -0,0 +1,129 @@
+package wfe
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// maxIdleBuckets is how many token buckets the rate limiter keeps before it
+// forgets the buckets that have refilled completely.
+const maxIdleBuckets = 1024
+
+// tokenBucket holds the tokens left for one source IP as of last.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ipRateLimiter throttles requests with a token bucket per source IP. Every
+// bucket holds up to burst tokens and refills at rate tokens per second.
+type ipRateLimiter struct {
+	sync.Mutex
+	clk     clock.Clock
+	rate    float64
+	burst   int
+	buckets map[string]*tokenBucket
+}
+
+func newIPRateLimiter(clk clock.Clock, rate float64, burst int) *ipRateLimiter {
+	if burst < 1 {
+		burst = int(math.Max(1, math.Ceil(rate)))
+	}
+	return &ipRateLimiter{
+		clk:     clk,
+		rate:    rate,
+		burst:   burst,
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *ipRateLimiter) enabled() bool {
+	return l.rate > 0
+}
+
+// refill adds the tokens earned since the bucket was last used.
+func (l *ipRateLimiter) refill(b *tokenBucket, now time.Time) {
+	b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+}
+
+// allow takes a token from the bucket of the source IP. If the bucket is
+// empty it returns false and the number of seconds until a token is
+// available.
+func (l *ipRateLimiter) allow(ip string) (bool, int) {
+	l.Lock()
+	defer l.Unlock()
+
+	now := l.clk.Now()
+	b, ok := l.buckets[ip]
+	if !ok {
+		if len(l.buckets) >= maxIdleBuckets {
+			l.forgetFullBuckets(now)
+		}
+		b = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[ip] = b
+	}
+	l.refill(b, now)
+
+	if b.tokens < 1 {
+		return false, int(math.Ceil((1 - b.tokens) / l.rate))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// forgetFullBuckets removes the buckets that have refilled completely, since
+// they are the same as new buckets.
+func (l *ipRateLimiter) forgetFullBuckets(now time.Time) {
+	for ip, b := range l.buckets {
+		l.refill(b, now)
+		if b.tokens >= float64(l.burst) {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// DefaultNewAccountWindow is the period over which Config.NewAccountLimit
+// counts new accounts when Config.NewAccountWindow isn't set, matching the
+// window Let's Encrypt uses for its accounts per IP address limit.
+const DefaultNewAccountWindow = 3 * time.Hour
+
+// checkNewAccountLimit returns a rateLimited problem, after setting the
+// Retry-After header, if the source IP of a newAccount request has already
+// created Config.NewAccountLimit accounts in the last Config.NewAccountWindow.
+// Requests with an external account binding aren't checked.
+func (wfe *WebFrontEndImpl) checkNewAccountLimit(
+	response http.ResponseWriter,
+	request *http.Request) *acme.ProblemDetails {
+	if !wfe.acctLimiter.enabled() {
+		return nil
+	}
+	ip := sourceIP(request)
+	ok, retryAfter := wfe.acctLimiter.allow(namespaced(requestNamespace(request), ip))
+	if ok {
+		return nil
+	}
+	response.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	return acme.RateLimitedProblem(fmt.Sprintf(
+		"Too many new accounts (%d) from IP address %s in the last %s, retry after %s "+
+			"or create the account with an external account binding",
+		wfe.config.NewAccountLimit, ip, wfe.config.NewAccountWindow,
+		wfe.clk.Now().Add(time.Duration(retryAfter)*time.Second).UTC().Format(time.RFC3339)))
+}
+
+// sourceIP returns the IP address a request was received from.
+func sourceIP(request *http.Request) string {
+	host, _, err := net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return request.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,95 @@
+package wfe
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/db"
+)
+
+// parseARICertID parses an ARI certificate identifier, the base64url encoded
+// authority key identifier and serial number of a certificate separated by a
+// period (RFC 9773 Section 4.1).
+func parseARICertID(id string) ([]byte, *big.Int, error) {
+	parts := strings.Split(id, ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, nil, fmt.Errorf("expected two base64url encoded values separated by a period")
+	}
+	aki, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, nil, fmt.Errorf("authority key identifier: %s", err)
+	}
+	serial, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("serial number: %s", err)
+	}
+	return aki, new(big.Int).SetBytes(serial), nil
+}
+
+// applyReplaces sets the certificate replaced by the order from the replaces
+// field of the new-order request. The certificate must have been issued to
+// the order's account and share an identifier with the order.
+func (wfe *WebFrontEndImpl) applyReplaces(order *core.Order, replaces string) *acme.ProblemDetails {
+	aki, serial, err := parseARICertID(replaces)
+	if err != nil {
+		return acme.MalformedProblem(fmt.Sprintf("Invalid replaces value %q: %s", replaces, err))
+	}
+
+	var replaced *core.Certificate
+	for _, cert := range wfe.db.FindCertificates(db.CertificateQuery{
+		SerialMin: serial,
+		SerialMax: serial,
+	}) {
+		if bytes.Equal(cert.Cert.AuthorityKeyId, aki) {
+			replaced = cert
+			break
+		}
+	}
+	if replaced == nil {
+		return acme.MalformedProblem(fmt.Sprintf("No certificate found for replaces value %q", replaces))
+	}
+	if replaced.AccountID != order.AccountID {
+		return acme.UnauthorizedProblem("The certificate to replace was issued to a different account")
+	}
+
+	certIdents := make(map[string]bool)
+	for _, name := range replaced.Cert.DNSNames {
+		certIdents[strings.ToLower(name)] = true
+	}
+	for _, ip := range replaced.Cert.IPAddresses {
+		certIdents[ip.String()] = true
+	}
+	shared := false
+	for _, ident := range order.Identifiers {
+		if certIdents[strings.ToLower(ident.Value)] {
+			shared = true
+			break
+		}
+	}
+	if !shared {
+		return acme.MalformedProblem("The order shares no identifiers with the certificate it replaces")
+	}
+
+	order.Replaces = replaces
+	order.ReplacesObject = replaced
+	return nil
+}
+
+// linkReplacement records the order as the replacement of its ReplacesObject
+// certificate in the store, unless another order already replaces it.
+func (wfe *WebFrontEndImpl) linkReplacement(order *core.Order) *acme.ProblemDetails {
+	if order.ReplacesObject == nil {
+		return nil
+	}
+	if replacedBy, ok := wfe.db.ReplaceCertificate(order.ReplacesObject.ID, order.ID); !ok {
+		return acme.AlreadyReplacedProblem(fmt.Sprintf(
+			"Certificate %q is already replaced by order %q", order.Replaces, replacedBy))
+	}
+	wfe.log.Printf("Order %q replaces certificate %q\n", order.ID, order.ReplacesObject.ID)
+	return nil
+}
@@ -0,0 +1,60 @@
+package wfe
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/jmhodges/clock"
+)
+
+// jwsReplays remembers the signatures of the JWS requests received within a
+// window to detect exact replays of them.
+type jwsReplays struct {
+	sync.Mutex
+	window time.Duration
+	clk    clock.Clock
+
+	// seen maps the SHA-256 hashes of signatures to when they were first
+	// seen.
+	seen      map[[32]byte]time.Time
+	nextPrune time.Time
+}
+
+func newJWSReplays(window time.Duration, clk clock.Clock) *jwsReplays {
+	if window <= 0 {
+		return nil
+	}
+	return &jwsReplays{
+		window: window,
+		clk:    clk,
+		seen:   make(map[[32]byte]time.Time),
+	}
+}
+
+// replayed records the signature and returns true if it was already seen
+// within the window. It always returns false for a nil jwsReplays.
+func (r *jwsReplays) replayed(signature []byte) bool {
+	if r == nil {
+		return false
+	}
+	r.Lock()
+	defer r.Unlock()
+
+	now := r.clk.Now()
+	if now.After(r.nextPrune) {
+		for hash, seen := range r.seen {
+			if now.Sub(seen) > r.window {
+				delete(r.seen, hash)
+			}
+		}
+		r.nextPrune = now.Add(r.window)
+	}
+
+	hash := sha256.Sum256(signature)
+	if seen, present := r.seen[hash]; present && now.Sub(seen) <= r.window {
+		return true
+	}
+	r.seen[hash] = now
+	return false
+}
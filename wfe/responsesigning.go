@@ -0,0 +1,97 @@
+package wfe
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/cryptomode"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// responseSignatureHeader carries the detached JWS (RFC 7515 Appendix F) over
+// the body of a signed response.
+const responseSignatureHeader = "Pebble-Response-Signature"
+
+// responseSigner signs directory and certificate responses with a key
+// generated at startup, whose public half is published through the
+// management interface.
+type responseSigner struct {
+	signer jose.Signer
+	public jose.JSONWebKey
+}
+
+func newResponseSigner() (*responseSigner, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), cryptomode.Reader())
+	if err != nil {
+		return nil, err
+	}
+	public := jose.JSONWebKey{Key: key.Public(), Algorithm: string(jose.ES256), Use: "sig"}
+	thumbprint, err := public.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	public.KeyID = base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.ES256,
+		Key:       &jose.JSONWebKey{Key: key, KeyID: public.KeyID},
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &responseSigner{signer: signer, public: public}, nil
+}
+
+// sign returns the detached compact serialization of a JWS over the body.
+func (s *responseSigner) sign(body []byte) (string, error) {
+	jws, err := s.signer.Sign(body)
+	if err != nil {
+		return "", err
+	}
+	return jws.DetachedCompactSerialize()
+}
+
+// signResponse sets the signature header of a response with the body, if
+// response signing is enabled. It must be called before the body is written.
+// The signature covers the body before any content coding.
+func (wfe *WebFrontEndImpl) signResponse(response http.ResponseWriter, body []byte) {
+	if wfe.responseSigner == nil {
+		return
+	}
+	signature, err := wfe.responseSigner.sign(body)
+	if err != nil {
+		wfe.log.Printf("Error signing response: %s\n", err)
+		return
+	}
+	response.Header().Set(responseSignatureHeader, signature)
+}
+
+// ResponseSigningKey serves the public key directory and certificate
+// responses are signed with.
+func (wfe *WebFrontEndImpl) ResponseSigningKey(response http.ResponseWriter, request *http.Request) {
+	if wfe.responseSigner == nil {
+		wfe.sendError(acme.NotFoundProblem("Response signing isn't enabled"), response)
+		return
+	}
+	jwk, err := json.Marshal(wfe.responseSigner.public)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem(fmt.Sprintf(
+			"Error marshalling response signing key: %s", err)), response)
+		return
+	}
+	err = wfe.writeJsonResponse(response, http.StatusOK, acme.ResponseSigningKey{
+		Algorithm: string(jose.ES256),
+		KeyID:     wfe.responseSigner.public.KeyID,
+		Key:       jwk,
+		Header:    responseSignatureHeader,
+	})
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling response signing key"), response)
+	}
+}
@@ -0,0 +1,149 @@
+package wfe
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// The security headers SecurityHeaders can omit or corrupt.
+const (
+	headerHSTS               = "Strict-Transport-Security"
+	headerContentTypeOptions = "X-Content-Type-Options"
+	headerCacheControl       = "Cache-Control"
+)
+
+// defaultNoStoreEndpoints are the endpoints whose responses carry account
+// details, orders or certificates and so get Cache-Control: no-store if
+// SecurityHeaders.NoStoreEndpoints is empty.
+var defaultNoStoreEndpoints = []string{
+	"newAccount", "account", "keyChange", "newOrder", "order", "finalize",
+	"certificate", "revokeCert",
+}
+
+// corruptHeaderValues are the malformed values of the security headers named
+// in SecurityHeaders.Corrupt.
+var corruptHeaderValues = map[string]string{
+	headerHSTS:               "max-age=-1;; includeSubDomains=yes",
+	headerContentTypeOptions: "nosnif",
+	headerCacheControl:       "no-store=, max-age=abc, public, private",
+}
+
+// SecurityHeaders adds security headers to the responses of the ACME
+// endpoints. For negative tests of the proxies and load balancers deployed in
+// front of ACME servers, headers can be omitted or sent with malformed values
+// on some endpoints.
+type SecurityHeaders struct {
+	// HSTSMaxAge is the max-age, in seconds, of the Strict-Transport-Security
+	// header. Zero doesn't send the header.
+	HSTSMaxAge            int
+	HSTSIncludeSubdomains bool
+	// NoSniff sends X-Content-Type-Options: nosniff.
+	NoSniff bool
+	// NoStoreEndpoints are the names of endpoints, or patterns matching them,
+	// whose responses get Cache-Control: no-store rather than no-cache.
+	// Defaults to the endpoints serving accounts, orders and certificates.
+	NoStoreEndpoints []string
+	// Omit names the headers, among Strict-Transport-Security,
+	// X-Content-Type-Options and Cache-Control, that aren't sent.
+	Omit []string
+	// Corrupt names the headers that are sent with malformed values.
+	Corrupt []string
+	// FaultEndpoints are the names of endpoints, or patterns matching them,
+	// that Omit and Corrupt apply to. Empty applies them to every endpoint.
+	FaultEndpoints []string
+}
+
+func (h *SecurityHeaders) check() error {
+	if h == nil {
+		return nil
+	}
+	if h.HSTSMaxAge < 0 {
+		return fmt.Errorf("negative HSTS max-age %d", h.HSTSMaxAge)
+	}
+	for _, list := range [][]string{h.Omit, h.Corrupt} {
+		for _, name := range list {
+			if _, ok := corruptHeaderValues[http.CanonicalHeaderKey(name)]; !ok {
+				return fmt.Errorf("unknown security header %q", name)
+			}
+		}
+	}
+	for _, list := range [][]string{h.NoStoreEndpoints, h.FaultEndpoints} {
+		for _, name := range list {
+			if err := checkEndpointPattern(name); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// faulted returns true if the named header is in the list and the Omit and
+// Corrupt faults apply to the endpoint.
+func (h *SecurityHeaders) faulted(list []string, header, endpoint string) bool {
+	found := false
+	for _, name := range list {
+		if http.CanonicalHeaderKey(name) == header {
+			found = true
+		}
+	}
+	if !found {
+		return false
+	}
+	if len(h.FaultEndpoints) == 0 {
+		return true
+	}
+	_, ok := pattern.Lookup(h.FaultEndpoints, endpoint)
+	return ok
+}
+
+// values returns the security headers of a response of the named endpoint.
+// Headers that are omitted have an empty value.
+func (h *SecurityHeaders) values(endpoint string) map[string]string {
+	values := map[string]string{
+		headerCacheControl: "public, max-age=0, no-cache",
+	}
+	if h == nil {
+		return values
+	}
+
+	if h.HSTSMaxAge > 0 {
+		hsts := "max-age=" + strconv.Itoa(h.HSTSMaxAge)
+		if h.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		values[headerHSTS] = hsts
+	}
+	if h.NoSniff {
+		values[headerContentTypeOptions] = "nosniff"
+	}
+	noStore := h.NoStoreEndpoints
+	if len(noStore) == 0 {
+		noStore = defaultNoStoreEndpoints
+	}
+	if _, ok := pattern.Lookup(noStore, endpoint); ok {
+		values[headerCacheControl] = "no-store"
+	}
+
+	for header, corrupt := range corruptHeaderValues {
+		if h.faulted(h.Corrupt, header, endpoint) {
+			values[header] = corrupt
+		}
+		if h.faulted(h.Omit, header, endpoint) {
+			values[header] = ""
+		}
+	}
+	return values
+}
+
+// addSecurityHeaders adds the security headers of the named endpoint to the
+// response.
+func (wfe *WebFrontEndImpl) addSecurityHeaders(response http.ResponseWriter, endpoint string) {
+	for header, value := range wfe.config.SecurityHeaders.values(endpoint) {
+		if value != "" {
+			response.Header().Set(header, value)
+		}
+	}
+}
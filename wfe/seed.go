@@ -0,0 +1,229 @@
+package wfe
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/cryptomode"
+)
+
+const (
+	// defaultSeedHost is the host used to build the URLs of seeded objects when
+	// the SeedSpec doesn't provide one.
+	defaultSeedHost = "localhost:14000"
+
+	// defaultSeedDomain is the domain seeded order identifiers are created
+	// under when the SeedSpec doesn't provide one.
+	defaultSeedDomain = "seed.example.com"
+)
+
+// SeedSpec describes the objects to pre-populate the store with.
+type SeedSpec struct {
+	// Host is the host (and port) of the ACME API used to build the URLs of
+	// seeded objects.
+	Host string `json:"host"`
+	// Domain is the domain under which seeded order identifiers are created.
+	Domain string `json:"domain"`
+	// Accounts is the number of accounts to create.
+	Accounts int `json:"accounts"`
+	// Orders is the number of orders to create for each account, keyed by the
+	// order status: pending, ready, processing, valid or invalid. Valid orders
+	// have a certificate issued for them.
+	Orders map[string]int `json:"orders"`
+}
+
+// SeededAccount describes an account created by Seed, including the private
+// key needed to use it.
+type SeededAccount struct {
+	URL          string   `json:"url"`
+	PrivateKey   string   `json:"privateKey"`
+	Orders       []string `json:"orders"`
+	Certificates []string `json:"certificates,omitempty"`
+}
+
+// Seed pre-populates the store with accounts, orders and certificates as
+// described by the provided spec. The seeded accounts are returned so that
+// clients can make use of them.
+func (wfe *WebFrontEndImpl) Seed(spec SeedSpec) ([]SeededAccount, error) {
+	if spec.Host == "" {
+		spec.Host = defaultSeedHost
+	}
+	if spec.Domain == "" {
+		spec.Domain = defaultSeedDomain
+	}
+	for status := range spec.Orders {
+		switch status {
+		case acme.StatusPending, acme.StatusReady, acme.StatusProcessing,
+			acme.StatusValid, acme.StatusInvalid:
+		default:
+			return nil, fmt.Errorf("cannot seed orders with status %q", status)
+		}
+	}
+
+	// The URLs of ACME objects are built from the request that created them, so
+	// seeded objects are made with a synthetic request for the seed host.
+	request := &http.Request{Host: spec.Host, Header: make(http.Header)}
+	request.Header.Set("X-Forwarded-Proto", "https")
+
+	var seeded []SeededAccount
+	for i := 0; i < spec.Accounts; i++ {
+		acct, key, err := wfe.seedAccount(i, spec.Domain)
+		if err != nil {
+			return nil, err
+		}
+		result := SeededAccount{
+			URL:        wfe.accountURL(request, acct.ID),
+			PrivateKey: string(key),
+		}
+
+		for _, status := range []string{acme.StatusPending, acme.StatusReady,
+			acme.StatusProcessing, acme.StatusValid, acme.StatusInvalid} {
+			for j := 0; j < spec.Orders[status]; j++ {
+				name := fmt.Sprintf("%s-%d-%d.%s", status, i, j, spec.Domain)
+				order, err := wfe.seedOrder(acct, name, status, request)
+				if err != nil {
+					return nil, err
+				}
+				result.Orders = append(result.Orders,
+					wfe.relativeEndpoint(request, orderPath+order.ID))
+				if order.CertificateObject != nil {
+					result.Certificates = append(result.Certificates,
+						wfe.relativeEndpoint(request, certPath+order.CertificateObject.ID))
+				}
+			}
+		}
+		seeded = append(seeded, result)
+	}
+
+	wfe.log.Printf("Seeded the store with %d accounts\n", len(seeded))
+	return seeded, nil
+}
+
+// seedAccount creates and stores a new account with an ECDSA P-256 key. The
+// PEM encoded private key is returned with the account.
+func (wfe *WebFrontEndImpl) seedAccount(no int, domain string) (*core.Account, []byte, error) {
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptomode.Reader())
+	if err != nil {
+		return nil, nil, err
+	}
+	key := &jose.JSONWebKey{Key: privKey.Public()}
+	keyID, err := keyToID(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	acct := &core.Account{
+		Account: acme.Account{
+			Contact: []string{fmt.Sprintf("mailto:seed-%d@%s", no, domain)},
+			Status:  acme.StatusValid,
+		},
+		Key: key,
+		ID:  keyID,
+	}
+	if _, err := wfe.db.AddAccount(acct); err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.MarshalECPrivateKey(privKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	var buf bytes.Buffer
+	if err := pem.Encode(&buf, &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}); err != nil {
+		return nil, nil, err
+	}
+	return acct, buf.Bytes(), nil
+}
+
+// seedOrder creates and stores a new order for a single name with its
+// authorizations and challenges moved into the states needed for the order to
+// have the given status.
+func (wfe *WebFrontEndImpl) seedOrder(
+	acct *core.Account,
+	name string,
+	status string,
+	request *http.Request) (*core.Order, error) {
+	expires := wfe.clk.Now().Add(wfe.config.OrderLifetime)
+	order := &core.Order{
+		ID:        newToken(),
+		AccountID: acct.ID,
+		Order: acme.Order{
+			Status:      acme.StatusPending,
+			Expires:     expires.UTC().Format(time.RFC3339),
+			Identifiers: []acme.Identifier{{Type: acme.IdentifierDNS, Value: name}},
+		},
+		ExpiresDate: expires,
+		Names:       []string{name},
+	}
+	objects, err := wfe.makeAuthorizations(order, request)
+	if err != nil {
+		return nil, err
+	}
+
+	if status != acme.StatusPending {
+		for _, authz := range order.AuthorizationObjects {
+			wfe.seedAuthzStatus(authz, status)
+		}
+	}
+
+	if status == acme.StatusInvalid {
+		order.Error = acme.UnauthorizedProblem("Seeded invalid order")
+	}
+
+	if status == acme.StatusProcessing || status == acme.StatusValid {
+		certKey, err := ecdsa.GenerateKey(elliptic.P256(), cryptomode.Reader())
+		if err != nil {
+			return nil, err
+		}
+		order.ParsedCSR = &x509.CertificateRequest{
+			DNSNames:  order.Names,
+			PublicKey: certKey.Public(),
+		}
+		order.BeganProcessing = true
+	}
+
+	if _, err := wfe.addOrder(order, objects); err != nil {
+		return nil, err
+	}
+
+	if status == acme.StatusValid {
+		wfe.ca.CompleteOrder(order)
+		if order.CertificateObject == nil {
+			return nil, fmt.Errorf("failed to issue certificate for seeded order %q", order.ID)
+		}
+	}
+	return order, nil
+}
+
+// seedAuthzStatus moves an authorization and one of its challenges to the
+// state matching the given order status.
+func (wfe *WebFrontEndImpl) seedAuthzStatus(authz *core.Authorization, orderStatus string) {
+	chalStatus := acme.StatusValid
+	if orderStatus == acme.StatusInvalid {
+		chalStatus = acme.StatusInvalid
+	}
+
+	authz.Lock()
+	defer authz.Unlock()
+	authz.Status = chalStatus
+	if len(authz.Challenges) == 0 {
+		return
+	}
+
+	chal := authz.Challenges[0]
+	chal.Status = chalStatus
+	chal.Validated = wfe.clk.Now().UTC().Format(time.RFC3339)
+	if chalStatus == acme.StatusInvalid {
+		chal.Error = acme.UnauthorizedProblem("Seeded invalid challenge")
+	}
+}
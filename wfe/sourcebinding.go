@@ -0,0 +1,75 @@
+package wfe
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+// AccountSourceBinding binds new accounts to the network they were created
+// from, rejecting requests for them from elsewhere with an unauthorized
+// problem.
+type AccountSourceBinding struct {
+	// IPv4PrefixLength and IPv6PrefixLength are the lengths of the prefixes
+	// accounts created from IPv4 and IPv6 addresses are bound to. They
+	// default to 32 and 128, binding accounts to a single address.
+	IPv4PrefixLength int
+	IPv6PrefixLength int
+}
+
+func (b *AccountSourceBinding) check() error {
+	if b == nil {
+		return nil
+	}
+	if b.IPv4PrefixLength < 0 || b.IPv4PrefixLength > 32 {
+		return fmt.Errorf("IPv4 prefix length must be between 0 and 32")
+	}
+	if b.IPv6PrefixLength < 0 || b.IPv6PrefixLength > 128 {
+		return fmt.Errorf("IPv6 prefix length must be between 0 and 128")
+	}
+	return nil
+}
+
+// network returns the network a new account created by the request is bound
+// to, or nil if accounts aren't bound or the request's source isn't an IP
+// address.
+func (b *AccountSourceBinding) network(request *http.Request) *net.IPNet {
+	if b == nil {
+		return nil
+	}
+	ip := net.ParseIP(sourceIP(request))
+	if ip == nil {
+		return nil
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		prefix := b.IPv4PrefixLength
+		if prefix == 0 {
+			prefix = 32
+		}
+		mask := net.CIDRMask(prefix, 32)
+		return &net.IPNet{IP: ip4.Mask(mask), Mask: mask}
+	}
+	prefix := b.IPv6PrefixLength
+	if prefix == 0 {
+		prefix = 128
+	}
+	mask := net.CIDRMask(prefix, 128)
+	return &net.IPNet{IP: ip.Mask(mask), Mask: mask}
+}
+
+// checkAccountSource returns an unauthorized problem if the account is bound
+// to a network the request wasn't received from.
+func checkAccountSource(account *core.Account, request *http.Request) *acme.ProblemDetails {
+	if account.SourceNetwork == nil {
+		return nil
+	}
+	source := sourceIP(request)
+	if ip := net.ParseIP(source); ip != nil && account.SourceNetwork.Contains(ip) {
+		return nil
+	}
+	return acme.UnauthorizedProblem(fmt.Sprintf(
+		"Account is bound to requests from %s, not %s", account.SourceNetwork, source))
+}
@@ -0,0 +1,66 @@
+package wfe
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/pattern"
+)
+
+// A StaticToken fixes the token of the challenges created for matching
+// identifiers, so that challenge responses can be provisioned before Pebble
+// is started.
+type StaticToken struct {
+	// Pattern is a pattern (see the pattern package) matched against the
+	// identifier value, e.g. "*.lab.example.com".
+	Pattern string `json:"pattern"`
+	// ChallengeType limits the token to challenges of one type. Empty matches
+	// every challenge type.
+	ChallengeType string `json:"challengeType,omitempty"`
+	// Token is the challenge token. It must be base64url encoded.
+	Token string `json:"token"`
+}
+
+func (t StaticToken) check() error {
+	if t.Pattern == "" {
+		return fmt.Errorf("static token has an empty pattern")
+	}
+	if err := pattern.Check(t.Pattern); err != nil {
+		return fmt.Errorf("static token: %s", err)
+	}
+	switch t.ChallengeType {
+	case "", acme.ChallengeHTTP01, acme.ChallengeTLSALPN01, acme.ChallengeDNS01:
+	default:
+		return fmt.Errorf("static token for %q has unsupported challenge type %q",
+			t.Pattern, t.ChallengeType)
+	}
+	if t.Token == "" {
+		return fmt.Errorf("static token for %q is empty", t.Pattern)
+	}
+	for _, c := range t.Token {
+		if !strings.ContainsRune(base64URLAlphabet, c) {
+			return fmt.Errorf("static token for %q isn't base64url encoded", t.Pattern)
+		}
+	}
+	return nil
+}
+
+func (t StaticToken) matches(chalType, value string) bool {
+	if t.ChallengeType != "" && t.ChallengeType != chalType {
+		return false
+	}
+	return pattern.Match(t.Pattern, value)
+}
+
+// challengeToken returns the token for a new challenge of the given type for
+// the identifier value: the token of the first matching static token, or a
+// random token in the configured format if none match.
+func (wfe *WebFrontEndImpl) challengeToken(chalType, value string) string {
+	for _, t := range wfe.config.StaticTokens {
+		if t.matches(chalType, value) {
+			return t.Token
+		}
+	}
+	return wfe.config.TokenFormat.newToken()
+}
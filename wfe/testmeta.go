@@ -0,0 +1,75 @@
+package wfe
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/core"
+)
+
+// maxTestMetaBytes is the largest test-meta value, once compacted, a new order
+// may have.
+const maxTestMetaBytes = 4096
+
+// checkTestMeta returns the compacted test-meta value of a new order, or nil
+// if it has none.
+func checkTestMeta(meta json.RawMessage) (json.RawMessage, *acme.ProblemDetails) {
+	if len(meta) == 0 || string(meta) == "null" {
+		return nil, nil
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, meta); err != nil {
+		return nil, acme.MalformedProblem(fmt.Sprintf("Invalid test-meta: %s", err))
+	}
+	if compacted.Len() > maxTestMetaBytes {
+		return nil, acme.MalformedProblem(fmt.Sprintf(
+			"test-meta is %d bytes, more than the maximum of %d", compacted.Len(), maxTestMetaBytes))
+	}
+	return compacted.Bytes(), nil
+}
+
+// testMetaMatches returns true if the test-meta value is the given value,
+// either its compact JSON or, for a JSON string, the string itself.
+func testMetaMatches(meta json.RawMessage, value string) bool {
+	if string(meta) == value {
+		return true
+	}
+	var s string
+	return json.Unmarshal(meta, &s) == nil && s == value
+}
+
+// TestMetaOrders returns the orders created with a test-meta value, or with
+// the value given by the optional "value" query parameter, either as compact
+// JSON or as the contents of a JSON string.
+func (wfe *WebFrontEndImpl) TestMetaOrders(response http.ResponseWriter, request *http.Request) {
+	value, filter := request.URL.Query()["value"]
+	orders := wfe.db.FindOrders(func(order *core.Order) bool {
+		return len(order.TestMeta) > 0 && (!filter || testMetaMatches(order.TestMeta, value[0]))
+	})
+
+	result := []acme.TestMetaOrder{}
+	for _, order := range orders {
+		status, err := order.GetStatus(wfe.clk)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem(err.Error()), response)
+			return
+		}
+		order.RLock()
+		result = append(result, acme.TestMetaOrder{
+			ID:          order.ID,
+			Account:     order.AccountID,
+			Status:      status,
+			Identifiers: order.Identifiers,
+			TestMeta:    order.TestMeta,
+		})
+		order.RUnlock()
+	}
+	err := wfe.writeJsonResponse(response, http.StatusOK, result)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling orders"), response)
+		return
+	}
+}
@@ -1,17 +1,19 @@
 package wfe
 
 import (
-	"crypto/rand"
 	"encoding/base64"
 	"fmt"
 	"io"
+	"strings"
+
+	"github.com/letsencrypt/pebble/cryptomode"
 )
 
 // randomString and newToken come from Boulder core/util.go
 // randomString returns a randomly generated string of the requested length.
 func randomString(byteLength int) string {
 	b := make([]byte, byteLength)
-	_, err := io.ReadFull(rand.Reader, b)
+	_, err := io.ReadFull(cryptomode.Reader(), b)
 	if err != nil {
 		panic(fmt.Sprintf("Error reading random bytes: %s", err))
 	}
@@ -22,3 +24,104 @@ func randomString(byteLength int) string {
 func newToken() string {
 	return randomString(32)
 }
+
+const (
+	// TokenFormatWeak makes challenge tokens 8 random digits, far below the
+	// 128 bits of entropy RFC 8555 requires, so that tokens of different
+	// challenges can repeat.
+	TokenFormatWeak = "weak"
+	// TokenFormatOversized makes challenge tokens 1024 characters long, longer
+	// than file names and DNS labels may be.
+	TokenFormatOversized = "oversized"
+
+	// maxTokenLength is the longest token a TokenFormat may ask for.
+	maxTokenLength = 65536
+)
+
+// A TokenFormat sets the length and alphabet of random challenge tokens, so
+// that clients' handling of unusual tokens can be tested. The zero value gives
+// the default tokens of 32 random bytes, base64url encoded.
+type TokenFormat struct {
+	// Format is a preset: empty for the default format, TokenFormatWeak or
+	// TokenFormatOversized. Length and Alphabet override the preset's.
+	Format string `json:"format,omitempty"`
+	// Length is the number of characters of a token.
+	Length int `json:"length,omitempty"`
+	// Alphabet holds the characters tokens are drawn from. They must be
+	// base64url characters, since RFC 8555 requires tokens to be base64url
+	// encoded.
+	Alphabet string `json:"alphabet,omitempty"`
+}
+
+// resolve returns the token length and alphabet of the format, or a length of
+// 0 for default tokens.
+func (f TokenFormat) resolve() (int, string) {
+	length, alphabet := f.Length, f.Alphabet
+	switch f.Format {
+	case TokenFormatWeak:
+		if length == 0 {
+			length = 8
+		}
+		if alphabet == "" {
+			alphabet = "0123456789"
+		}
+	case TokenFormatOversized:
+		if length == 0 {
+			length = 1024
+		}
+	}
+	if length == 0 && alphabet == "" {
+		return 0, ""
+	}
+	if length == 0 {
+		length = 43
+	}
+	if alphabet == "" {
+		alphabet = base64URLAlphabet
+	}
+	return length, alphabet
+}
+
+func (f TokenFormat) check() error {
+	switch f.Format {
+	case "", TokenFormatWeak, TokenFormatOversized:
+	default:
+		return fmt.Errorf("unknown token format %q", f.Format)
+	}
+	if f.Length < 0 || f.Length > maxTokenLength {
+		return fmt.Errorf("token length %d is negative or longer than %d", f.Length, maxTokenLength)
+	}
+	for i, c := range f.Alphabet {
+		if !strings.ContainsRune(base64URLAlphabet, c) {
+			return fmt.Errorf("token alphabet has the non-base64url character %q", c)
+		}
+		if strings.ContainsRune(f.Alphabet[i+1:], c) {
+			return fmt.Errorf("token alphabet has %q more than once", c)
+		}
+	}
+	return nil
+}
+
+// newToken returns a random token in the format.
+func (f TokenFormat) newToken() string {
+	length, alphabet := f.resolve()
+	if length == 0 {
+		return newToken()
+	}
+	// Bytes at or above limit are discarded so that every character of the
+	// alphabet is equally likely.
+	limit := 256 - 256%len(alphabet)
+	token := make([]byte, 0, length)
+	b := make([]byte, length)
+	for len(token) < length {
+		if _, err := io.ReadFull(cryptomode.Reader(), b); err != nil {
+			panic(fmt.Sprintf("Error reading random bytes: %s", err))
+		}
+		for _, c := range b {
+			if int(c) < limit && len(token) < length {
+				token = append(token, alphabet[int(c)%len(alphabet)])
+			}
+		}
+	}
+	return string(token)
+}
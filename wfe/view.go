@@ -0,0 +1,139 @@
+package wfe
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+	"net/http"
+
+	"github.com/letsencrypt/pebble/acme"
+)
+
+// A View customises the directory and account and challenge policies of the
+// ACME API served on one listener. Serving several views of one WFE models a
+// split-horizon CA whose internal and external clients share one store.
+type View struct {
+	// Name identifies the view in log messages.
+	Name string
+	// Meta holds fields added to the directory's meta object. They override
+	// the fields Pebble sets itself.
+	Meta map[string]interface{}
+	// ExternalAccountRequired advertises externalAccountRequired in the
+	// directory and rejects new account requests without an
+	// externalAccountBinding. The binding is only verified if there are
+	// external account MAC keys.
+	ExternalAccountRequired bool
+	// ChallengeTypes are the challenge types offered in authorizations for
+	// non-wildcard DNS identifiers created through the view. Empty means every
+	// enabled challenge type.
+	ChallengeTypes []string
+	// KeyTypes are the subscriber key types accepted in CSRs finalized through
+	// the view, e.g. KeyTypeRSA2048 or KeyTypeP256. Empty means every key type
+	// the CA supports.
+	KeyTypes []string
+}
+
+// The subscriber key types a View can accept.
+const (
+	KeyTypeRSA2048 = "rsa2048"
+	KeyTypeRSA3072 = "rsa3072"
+	KeyTypeRSA4096 = "rsa4096"
+	KeyTypeP256    = "p256"
+	KeyTypeP384    = "p384"
+	KeyTypeEd25519 = "ed25519"
+)
+
+// viewContextKey is the request context key holding the *View a request was
+// received through.
+type viewContextKey struct{}
+
+// ViewHandler returns a http.Handler for the ACME API as seen through the
+// given view. Handler serves the default view.
+func (wfe *WebFrontEndImpl) ViewHandler(view View) http.Handler {
+	for _, chalType := range view.ChallengeTypes {
+		switch chalType {
+		case acme.ChallengeHTTP01, acme.ChallengeTLSALPN01, acme.ChallengeDNS01:
+		default:
+			panic(fmt.Sprintf("View %q has unsupported challenge type %q", view.Name, chalType))
+		}
+	}
+	for _, keyType := range view.KeyTypes {
+		switch keyType {
+		case KeyTypeRSA2048, KeyTypeRSA3072, KeyTypeRSA4096,
+			KeyTypeP256, KeyTypeP384, KeyTypeEd25519:
+		default:
+			panic(fmt.Sprintf("View %q has unsupported key type %q", view.Name, keyType))
+		}
+	}
+
+	handler := wfe.Handler()
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		ctx := context.WithValue(request.Context(), viewContextKey{}, &view)
+		handler.ServeHTTP(response, request.WithContext(ctx))
+	})
+}
+
+// requestView returns the view the request was received through, or the zero
+// View for the default view.
+func requestView(request *http.Request) *View {
+	if view, ok := request.Context().Value(viewContextKey{}).(*View); ok {
+		return view
+	}
+	return &View{}
+}
+
+// allowsChallenge returns true if the view offers the challenge type.
+func (v *View) allowsChallenge(chalType string) bool {
+	if len(v.ChallengeTypes) == 0 {
+		return true
+	}
+	for _, t := range v.ChallengeTypes {
+		if t == chalType {
+			return true
+		}
+	}
+	return false
+}
+
+// keyType returns the name of a subscriber public key's type, or "" if it
+// isn't one of the key types a View can accept.
+func keyType(key interface{}) string {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		switch k.N.BitLen() {
+		case 2048:
+			return KeyTypeRSA2048
+		case 3072:
+			return KeyTypeRSA3072
+		case 4096:
+			return KeyTypeRSA4096
+		}
+	case *ecdsa.PublicKey:
+		switch k.Curve.Params().Name {
+		case "P-256":
+			return KeyTypeP256
+		case "P-384":
+			return KeyTypeP384
+		}
+	case ed25519.PublicKey:
+		return KeyTypeEd25519
+	}
+	return ""
+}
+
+// allowsKeyType returns true if the view accepts CSRs for the subscriber
+// public key.
+func (v *View) allowsKeyType(key interface{}) bool {
+	if len(v.KeyTypes) == 0 {
+		return true
+	}
+	kt := keyType(key)
+	for _, t := range v.KeyTypes {
+		if t == kt {
+			return true
+		}
+	}
+	return false
+}
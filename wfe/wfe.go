@@ -5,15 +5,14 @@ import (
 	"crypto"
 	"crypto/sha256"
 	"crypto/x509"
+	"encoding/asn1"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
-	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
-	"log"
 	"math/rand"
-	"net"
 	"net/http"
 	"net/mail"
 	"net/url"
@@ -22,6 +21,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 	"unicode"
 
@@ -29,10 +29,16 @@ import (
 
 	"github.com/jmhodges/clock"
 	"github.com/letsencrypt/pebble/acme"
+	"github.com/letsencrypt/pebble/audit"
 	"github.com/letsencrypt/pebble/ca"
 	"github.com/letsencrypt/pebble/core"
+	"github.com/letsencrypt/pebble/cryptomode"
 	"github.com/letsencrypt/pebble/db"
+	"github.com/letsencrypt/pebble/events"
+	"github.com/letsencrypt/pebble/logging"
+	"github.com/letsencrypt/pebble/timeouts"
 	"github.com/letsencrypt/pebble/va"
+	"github.com/letsencrypt/pebble/webhook"
 )
 
 const (
@@ -49,10 +55,27 @@ const (
 	challengePath     = "/chalZ/"
 	certPath          = "/certZ/"
 	revokeCertPath    = "/revoke-cert"
+	keyRolloverPath   = "/rollover-account-key"
+	delegationsPath   = "/delegations/"
+	delegationPath    = "/delegation/"
 
-	// How long do pending authorizations last before expiring?
+	// finalizeDryRunPath checks finalize requests without issuing. It is a
+	// Pebble extension advertised in orders rather than the directory.
+	finalizeDryRunPath = "/finalize-dry-run/"
+
+	// issuerCertPath serves issuer certificates for the CA Issuers URLs of
+	// issued certificates. It isn't an ACME endpoint, so it isn't in the
+	// directory.
+	issuerCertPath = "/issuer-cert/"
+
+	// How long do pending authorizations last before expiring? Can be
+	// overridden with Config.PendingAuthzLifetime.
 	pendingAuthzExpire = time.Hour
 
+	// How long do orders last before expiring? Can be overridden with
+	// Config.OrderLifetime.
+	orderExpire = 24 * time.Hour
+
 	// How many contacts is an account allowed to have?
 	maxContactsPerAcct = 2
 
@@ -69,6 +92,14 @@ const (
 	// nonces are rejected?
 	defaultNonceReject = 15
 
+	// defaultNonceLifetime is how long derivable nonces are valid for when
+	// Config.NonceLifetime isn't set.
+	defaultNonceLifetime = time.Hour
+
+	// defaultRequestTimeout is the deadline of the context ACME requests are
+	// handled with when Config.RequestTimeout isn't set.
+	defaultRequestTimeout = time.Minute
+
 	// POST requests with a JWS body must have the following Content-Type header
 	expectedJWSContentType = "application/jose+json"
 
@@ -95,8 +126,7 @@ type requestEvent struct {
 type wfeHandlerFunc func(context.Context, *requestEvent, http.ResponseWriter, *http.Request)
 
 func (f wfeHandlerFunc) ServeHTTP(e *requestEvent, w http.ResponseWriter, r *http.Request) {
-	ctx := context.TODO()
-	f(ctx, e, w, r)
+	f(r.Context(), e, w, r)
 }
 
 type wfeHandler interface {
@@ -118,26 +148,371 @@ func (th *topHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	th.wfe.ServeHTTP(rEvent, w, r)
 }
 
+// Config holds the optional WFE behaviours that can be tuned from the Pebble
+// configuration file. The zero value is a WFE with default behaviour.
+type Config struct {
+	// MaxBodySize is the maximum size in bytes of a POST body accepted by any
+	// endpoint that doesn't have an entry in MaxBodySizes. Zero means no limit.
+	MaxBodySize int64
+	// MaxBodySizes maps endpoint names (e.g. "newAccount", "finalize") to the
+	// maximum size in bytes of a POST body that endpoint will accept.
+	MaxBodySizes map[string]int64
+	// OrderLifetime is how long a new order remains usable before it expires.
+	// Zero means the default of one day.
+	OrderLifetime time.Duration
+	// PendingAuthzLifetime is how long a new authorization remains pending
+	// before it expires. Zero means the default of one hour.
+	PendingAuthzLifetime time.Duration
+	// PollExpiryExtension, if positive, makes each poll of a pending or ready
+	// order, or of a pending authorization, push its expiry back to that long
+	// from then. Zero leaves expiry unaffected by polling.
+	PollExpiryExtension time.Duration
+	// MaxPollExpiryExtension caps how far past the expiry an order or
+	// authorization was created with polling can push it back. Zero means no
+	// limit.
+	MaxPollExpiryExtension time.Duration
+	// CORSAllowedOrigins lists the origins that browser based clients may call
+	// the ACME API from. "*" allows any origin. CORS headers are only sent when
+	// this is non-empty.
+	CORSAllowedOrigins []string
+	// CORSExposedHeaders lists the response headers exposed to browser based
+	// clients. Defaults to defaultCORSExposedHeaders when empty.
+	CORSExposedHeaders []string
+	// MaxConcurrentRequests is the maximum number of ACME requests handled at
+	// once. Zero means no limit.
+	MaxConcurrentRequests int
+	// MaxConcurrentRequestsPerAccount is the maximum number of ACME requests
+	// handled at once for a single account. Zero means no limit.
+	MaxConcurrentRequestsPerAccount int
+	// OverloadRetryAfter is the Retry-After value sent with 503 responses when
+	// a concurrency limit is exceeded. Defaults to one second.
+	OverloadRetryAfter time.Duration
+	// IPRateLimit is the rate in requests per second at which each source IP
+	// may call the directory and newNonce endpoints, with bursts of up to
+	// IPRateBurst requests. Zero means no limit.
+	IPRateLimit float64
+	IPRateBurst int
+	// NewAccountLimit is how many accounts each source IP may create per
+	// NewAccountWindow, which defaults to DefaultNewAccountWindow. Requests
+	// with an external account binding are exempt. Zero means no limit.
+	NewAccountLimit  int
+	NewAccountWindow time.Duration
+	// RejectEd25519AccountKeys rejects new accounts with Ed25519 keys.
+	RejectEd25519AccountKeys bool
+	// RejectEd25519CSRKeys rejects finalization requests with CSRs for Ed25519
+	// subscriber keys.
+	RejectEd25519CSRKeys bool
+	// RequireAccountAttestation rejects new-account requests without an
+	// attestation object. Attestation objects are optional otherwise, and
+	// either way are only checked to be well formed.
+	RequireAccountAttestation bool
+	// LatencyProfiles adds artificial latency to requests, keyed by endpoint
+	// name or "*" for every endpoint without a profile of its own. They can be
+	// changed at runtime through the management interface.
+	LatencyProfiles map[string]LatencyProfile
+	// MaintenanceWindows put endpoints into maintenance mode, answering with
+	// 503 responses. They can be changed at runtime through the management
+	// interface.
+	MaintenanceWindows []MaintenanceWindow
+	// AccountOverrides change Pebble's behaviour for the accounts with the
+	// given IDs. They can be changed at runtime through the management
+	// interface.
+	AccountOverrides map[string]AccountOverride
+	// ExternalAccountMACKeys are the base64url encoded MAC keys of external
+	// account bindings, keyed by key ID. If there are any, the bindings of
+	// new-account requests are verified. Keys can be added, rotated and
+	// revoked at runtime through the management interface.
+	ExternalAccountMACKeys map[string]string
+	// StaticTokens fix the tokens of the challenges created for matching
+	// identifiers instead of using random tokens.
+	StaticTokens []StaticToken
+	// TokenFormat sets the length and alphabet of random challenge tokens.
+	TokenFormat TokenFormat
+	// DisabledChallenges are challenge types that aren't offered in new
+	// authorizations for non-wildcard DNS identifiers. They can be changed at
+	// runtime through the management interface.
+	DisabledChallenges []string
+	// ResponseEncoding encodes response bodies with the gzip or deflate
+	// content coding preferred by the request's Accept-Encoding header.
+	ResponseEncoding bool
+	// ResponseEncodingFault breaks the encoding of encoded response bodies:
+	// EncodingFaultMismatch or EncodingFaultTruncated. Empty leaves them
+	// intact.
+	ResponseEncodingFault string
+	// ProcessingHolds keep matching orders in processing after they are
+	// finalized. They can be changed at runtime through the management
+	// interface.
+	ProcessingHolds []ProcessingHold
+	// EnableDeviceAttest allows orders for permanent-identifier identifiers,
+	// which are validated with the device-attest-01 challenge.
+	EnableDeviceAttest bool
+	// EnableTNAuthList allows orders for a TNAuthList identifier, which is
+	// validated with the tkauth-01 challenge.
+	EnableTNAuthList bool
+	// TokenAuthority is the token authority URL advertised in tkauth-01
+	// challenges.
+	TokenAuthority string
+	// EnableOnion allows orders for names under .onion, which are validated
+	// with the onion-csr-01 challenge. Otherwise they are rejected.
+	EnableOnion bool
+	// EnableSubdomainAuth allows authorizations for a domain to authorize its
+	// subdomains (RFC 9444).
+	EnableSubdomainAuth bool
+	// EnableDelegation enables the STAR delegation extensions (RFC 9115):
+	// account delegations, delegated orders checked against CSR templates and
+	// the allow-certificate-get order field.
+	EnableDelegation bool
+	// EnableFinalizeDryRun gives orders a finalizeDryRun URL to which a
+	// finalize request can be posted to check the CSR without issuing.
+	EnableFinalizeDryRun bool
+	// SignResponses signs directory and certificate responses with a key
+	// generated at startup, sending a detached JWS over the body in the
+	// Pebble-Response-Signature header. The management interface serves the
+	// public key. It is experimental, for prototyping authenticated ACME
+	// bootstrapping.
+	SignResponses bool
+	// ReuseOrders makes new-order requests return the account's existing
+	// pending or ready order for the same identifiers instead of creating a
+	// new order.
+	ReuseOrders bool
+	// ReuseAuthzs attaches the account's valid, unexpired authorizations for
+	// a name to new orders for it instead of creating new authorizations.
+	ReuseAuthzs bool
+	// AuthzReuseWindow limits the reuse of valid authorizations, including
+	// those of ancestor domains (RFC 9444), to this long after they were
+	// validated. Zero allows reuse until the authorizations expire.
+	AuthzReuseWindow time.Duration
+	// RandomizePaths serves every ACME endpoint except the directory under a
+	// random path prefix chosen at startup, so that clients must discover the
+	// endpoints from the directory (RFC 8555 Section 7.1.1).
+	RandomizePaths bool
+	// ShuffleDirectory serves the directory's fields in a random order with
+	// some unknown fields added.
+	ShuffleDirectory bool
+	// ResponseOrdering sets the order of the identifiers and authorizations of
+	// orders and the challenges of authorizations in responses. They are
+	// shuffled by default.
+	ResponseOrdering ResponseOrdering
+	// GreaseEndpoints are the names of endpoints, or patterns matching them,
+	// whose responses get random unknown fields and randomly cased header
+	// names on every request.
+	GreaseEndpoints []string
+	// ChainModes change how certificate chains are served, see
+	// ChainIncludeRoot, ChainReversed, ChainDuplicates and the PEM quirk
+	// modes. Requests can override them with the Pebble-Chain-Mode header.
+	ChainModes []string
+	// MaxIdentifiers is the most identifiers an order may have. Zero allows
+	// any number.
+	MaxIdentifiers int
+	// CertificateChunkSize serves certificates in chunks of this many bytes
+	// using chunked transfer encoding. Zero serves them whole.
+	CertificateChunkSize int
+	// CertificateNotReady gives processing orders a certificate URL before
+	// their certificate is issued, which is answered with
+	// CertNotReadyOrderNotReady, CertNotReadyNotFound or
+	// CertNotReadyRetryAfter until it is. Empty leaves the certificate URL
+	// out of orders until they are valid. CertificateRetryAfter is the
+	// Retry-After value of CertNotReadyRetryAfter, defaulting to one second.
+	CertificateNotReady   string
+	CertificateRetryAfter time.Duration
+	// AccountURLFormat is the format of account URLs: AccountURLKeyDigest,
+	// the default, AccountURLInteger, AccountURLBoulder or AccountURLLegacy.
+	AccountURLFormat string
+	// LegacyKeyIDs accepts JWS key IDs and key rollover account URLs in any
+	// of the account URL formats, for any host, or with just the account ID
+	// or number. Otherwise only the account's URL in the configured format
+	// is accepted.
+	LegacyKeyIDs bool
+	// NonceKey, when set, makes nonces derivable: they are authenticated
+	// with an HMAC keyed by it, so that Pebble instances sharing the key
+	// accept each other's nonces, including across restarts. NoncePrefix
+	// tags the nonces of this instance and NonceLifetime is how long they
+	// are valid for, defaulting to an hour.
+	NonceKey      string
+	NoncePrefix   string
+	NonceLifetime time.Duration
+	// DateSkew is added to the time in the Date header of ACME responses to
+	// simulate clock drift between the CA and its clients. It may be
+	// negative.
+	DateSkew time.Duration
+	// JWSReplayWindow is how long the signatures of JWS requests are
+	// remembered to reject exact replays of them with a jwsReplayed problem.
+	// Zero disables replay detection.
+	JWSReplayWindow time.Duration
+	// DuplicateCertificates rejects new orders and finalizations for a set of
+	// identifiers that already had Limit certificates issued within Window.
+	DuplicateCertificates DuplicateCertificateLimit
+	// MaxPendingOrders is the most pending orders an account may have at
+	// once. New orders beyond it are rejected with a rateLimited problem.
+	// Zero allows any number.
+	MaxPendingOrders int
+	// FailureRetention is how long orders and authorizations that became
+	// invalid are kept, with their errors and validation attempts, for the
+	// Failures management endpoint. Zero doesn't keep them.
+	FailureRetention time.Duration
+	// DisableConditionalRequests turns off the ETag and Last-Modified headers
+	// of the directory and certificates, and 304 Not Modified responses to
+	// their conditional requests.
+	DisableConditionalRequests bool
+	// JWSFeatureHandling maps JWS features ACME doesn't allow, like
+	// JWSUnencodedPayload, to how requests using them are rejected:
+	// JWSRejectPrecise, the default, or JWSRejectGeneric.
+	JWSFeatureHandling map[string]string
+	// ObjectFields omits and adds fields of the directory, orders and
+	// authorizations, keyed by ObjectDirectory, ObjectOrder or
+	// ObjectAuthorization.
+	ObjectFields map[string]ObjectFields
+	// UnsupportedIdentifiers are name patterns of identifier values that new
+	// orders are rejected for with an unsupportedIdentifier problem.
+	UnsupportedIdentifiers []string
+	// UnsupportedContacts are name patterns of contact URLs that accounts are
+	// rejected for with an unsupportedContact problem.
+	UnsupportedContacts []string
+	// IdentifierStrictness sets how DNS identifiers of new orders are
+	// normalized and checked: IdentifierStandard (the default),
+	// IdentifierLenient or IdentifierStrict.
+	IdentifierStrictness string
+	// AutoFinalize finalizes orders as soon as they become ready with a CSR
+	// for a key Pebble generates. The key and certificate are served by the
+	// AutoFinalized management endpoint. It requires Events.
+	AutoFinalize bool
+	// AccountSourceBinding, if set, binds new accounts to the network they
+	// were created from.
+	AccountSourceBinding *AccountSourceBinding
+	// Namespaces, if set, isolates the accounts of clients that name
+	// different namespaces in their requests.
+	Namespaces *Namespaces
+	// SecurityHeaders, if set, adds HSTS, X-Content-Type-Options and
+	// Cache-Control: no-store headers to responses.
+	SecurityHeaders *SecurityHeaders
+	// OrderReports, if set, records the requests about each order for the
+	// OrderReport management endpoint.
+	OrderReports *OrderReports
+	// Notifier is sent an event for every revoked certificate. It may be nil.
+	Notifier *webhook.Notifier
+	// Audit records security relevant events. It may be nil.
+	Audit *audit.Log
+	// RequestTimeout is the deadline of the context ACME requests are
+	// handled with. Zero means one minute.
+	RequestTimeout time.Duration
+	// TimeoutCounter counts the requests that miss RequestTimeout. The
+	// management interface's metrics report the timeouts it has counted,
+	// including those of other components. It may be nil.
+	TimeoutCounter *timeouts.Counter
+	// Events receives the status transitions of new orders and
+	// authorizations and of orders being finalized. It may be nil.
+	Events *events.Broker
+	// CSRReplayPolicy rejects CSRs that were already used to finalize another
+	// order. It is one of CSRReplayAllow (the default),
+	// CSRReplayAcrossAccounts or CSRReplayAcrossOrders.
+	CSRReplayPolicy string
+	// ContentTypeCheck sets how the Content-Type of POST requests is checked.
+	// It is one of ContentTypeCheckDefault, ContentTypeCheckExact or
+	// ContentTypeCheckLenient.
+	ContentTypeCheck string
+	// ManagementPrincipals are the clients allowed to use the management
+	// interface. If empty every request is allowed.
+	ManagementPrincipals []ManagementPrincipal
+	// EnableProfiling serves the net/http/pprof profiles under /debug/pprof/
+	// on the management interface.
+	EnableProfiling bool
+	// HeapStatsInterval is how often the heap statistics served by the
+	// metrics endpoint are sampled. It defaults to DefaultHeapStatsInterval.
+	HeapStatsInterval time.Duration
+	// ChallengePolicies set the challenges offered for matching DNS
+	// identifiers. The first matching policy applies.
+	ChallengePolicies []ChallengePolicy
+	// AccessLog receives a line for every ACME request with the method, path,
+	// account ID, response status and problem type. It may be nil.
+	// AccessLogFormat is either "clf" (the default) or "json".
+	AccessLog       io.Writer
+	AccessLogFormat string
+}
+
+// The CSR replay policies.
+const (
+	// CSRReplayAllow allows CSRs to be reused.
+	CSRReplayAllow = ""
+	// CSRReplayAcrossAccounts rejects CSRs already used by another account.
+	CSRReplayAcrossAccounts = "across-accounts"
+	// CSRReplayAcrossOrders rejects CSRs already used for another order.
+	CSRReplayAcrossOrders = "across-orders"
+)
+
+// The Content-Type checks of POST requests.
+const (
+	// ContentTypeCheckDefault rejects POST requests without the
+	// application/jose+json Content-Type with an unsupportedMediaType problem
+	// in strict mode, and accepts any Content-Type otherwise.
+	ContentTypeCheckDefault = ""
+	// ContentTypeCheckExact rejects POST requests whose Content-Type isn't
+	// exactly application/jose+json with a malformed problem, even outside
+	// strict mode.
+	ContentTypeCheckExact = "exact"
+	// ContentTypeCheckLenient accepts any Content-Type, even in strict mode.
+	ContentTypeCheckLenient = "lenient"
+)
+
+// defaultCORSExposedHeaders are the response headers an in-browser ACME client
+// needs to be able to read.
+var defaultCORSExposedHeaders = []string{"Replay-Nonce", "Location", "Link", "Retry-After"}
+
 type WebFrontEndImpl struct {
-	log             *log.Logger
+	log             logging.Logger
 	db              *db.MemoryStore
-	nonce           *nonceMap
+	nonce           nonceService
 	nonceErrPercent int
 	clk             clock.Clock
 	va              *va.VAImpl
 	ca              *ca.CAImpl
 	strict          bool
+	config          Config
+	limiter         *concurrencyLimiter
+	ipLimiter       *ipRateLimiter
+	acctLimiter     *ipRateLimiter
+	responseSigner  *responseSigner
+	heapStats       *heapStats
+	latency         *latencyTable
+	maintenance     *maintenanceTable
+	holds           *holdTable
+	overrides       *overrideTable
+	eab             *eabTable
+	challenges      *challengeToggles
+	accessLog       *accessLogger
+	jwsReplays      *jwsReplays
+	duplicates      *duplicateCertificates
+	pendingOrders   *pendingOrderLimit
+	malformed       *malformedJWSCounter
+	acctNumbers     *accountNumbers
+	failures        *failureArchive
+	autoFinalizer   *autoFinalizer
+	massRevocations *massRevocations
+	// started is when the WFE was created, the Last-Modified time of the
+	// directory.
+	started time.Time
+
+	// pathPrefix is the random path prefix of the ACME endpoints if
+	// RandomizePaths is set.
+	pathPrefix string
+	// ready is set to 1 by SetReady. It is a pointer because the WFE is
+	// passed around by value.
+	ready *int32
+	// chainModes are the parsed Config.ChainModes.
+	chainModes chainModes
+	// info holds the RuntimeInfo set by SetRuntimeInfo.
+	info *atomic.Value
 }
 
 const ToSURL = "data:text/plain,Do%20what%20thou%20wilt"
 
 func New(
-	log *log.Logger,
+	log logging.Logger,
 	clk clock.Clock,
 	db *db.MemoryStore,
 	va *va.VAImpl,
 	ca *ca.CAImpl,
-	strict bool) WebFrontEndImpl {
+	strict bool,
+	config Config) WebFrontEndImpl {
 
 	// Read the % of good nonces that should be rejected as bad nonces from the
 	// environment
@@ -161,16 +536,322 @@ func New(
 	}
 	log.Printf("Configured to reject %d%% of good nonces", nonceErrPercent)
 
-	return WebFrontEndImpl{
+	if config.OrderLifetime <= 0 {
+		config.OrderLifetime = orderExpire
+	}
+	if config.PendingAuthzLifetime <= 0 {
+		config.PendingAuthzLifetime = pendingAuthzExpire
+	}
+	log.Printf("Configured order lifetime %s and pending authz lifetime %s",
+		config.OrderLifetime, config.PendingAuthzLifetime)
+	if config.PollExpiryExtension > 0 {
+		log.Printf("Polling extends order and pending authz expiry to %s from the poll, "+
+			"by at most %s", config.PollExpiryExtension, config.MaxPollExpiryExtension)
+	}
+
+	if len(config.CORSAllowedOrigins) > 0 {
+		if len(config.CORSExposedHeaders) == 0 {
+			config.CORSExposedHeaders = defaultCORSExposedHeaders
+		}
+		log.Printf("Allowing CORS requests from origins %q", config.CORSAllowedOrigins)
+	}
+
+	if config.OverloadRetryAfter <= 0 {
+		config.OverloadRetryAfter = time.Second
+	}
+	switch config.CertificateNotReady {
+	case "", CertNotReadyOrderNotReady, CertNotReadyNotFound, CertNotReadyRetryAfter:
+	default:
+		panic(fmt.Sprintf("Unknown certificate not ready mode %q", config.CertificateNotReady))
+	}
+	if config.CertificateRetryAfter <= 0 {
+		config.CertificateRetryAfter = time.Second
+	}
+	if err := checkJWSFeatureHandling(config.JWSFeatureHandling); err != nil {
+		panic(err.Error())
+	}
+	switch config.AccountURLFormat {
+	case "", AccountURLKeyDigest, AccountURLInteger, AccountURLBoulder, AccountURLLegacy:
+	default:
+		panic(fmt.Sprintf("Unknown account URL format %q", config.AccountURLFormat))
+	}
+	limiter := newConcurrencyLimiter(
+		config.MaxConcurrentRequests, config.MaxConcurrentRequestsPerAccount)
+	if limiter.enabled() {
+		log.Printf("Limiting concurrent requests to %d (%d per account)",
+			config.MaxConcurrentRequests, config.MaxConcurrentRequestsPerAccount)
+	}
+	if config.IPRateLimit < 0 || config.IPRateBurst < 0 {
+		panic("IP rate limit and burst must be >= 0")
+	}
+	ipLimiter := newIPRateLimiter(clk, config.IPRateLimit, config.IPRateBurst)
+	if ipLimiter.enabled() {
+		log.Printf("Limiting directory and newNonce requests to %g per second per IP (burst %d)",
+			config.IPRateLimit, ipLimiter.burst)
+	}
+	if config.NewAccountLimit < 0 || config.NewAccountWindow < 0 {
+		panic("new account limit and window must be >= 0")
+	}
+	if config.NewAccountWindow == 0 {
+		config.NewAccountWindow = DefaultNewAccountWindow
+	}
+	acctLimiter := newIPRateLimiter(clk,
+		float64(config.NewAccountLimit)/config.NewAccountWindow.Seconds(), config.NewAccountLimit)
+	if acctLimiter.enabled() {
+		log.Printf("Limiting new accounts to %d per %s per IP, except with external account bindings",
+			config.NewAccountLimit, config.NewAccountWindow)
+	}
+
+	if config.HeapStatsInterval < 0 {
+		panic("heap statistics interval must be >= 0")
+	}
+	if config.HeapStatsInterval == 0 {
+		config.HeapStatsInterval = DefaultHeapStatsInterval
+	}
+	if config.EnableProfiling {
+		log.Printf("Serving profiles at %s on the management interface", pprofPath)
+	}
+
+	var signer *responseSigner
+	if config.SignResponses {
+		var err error
+		signer, err = newResponseSigner()
+		if err != nil {
+			panic(fmt.Sprintf("Unable to create the response signing key: %s", err.Error()))
+		}
+		log.Printf("Signing directory and certificate responses with key %s", signer.public.KeyID)
+	}
+
+	for name := range config.MaxBodySizes {
+		if !knownEndpointName(name) {
+			log.Printf("Warning: ignoring body size limit for unknown endpoint %q", name)
+		}
+	}
+
+	latency := newLatencyTable()
+	if err := latency.set(config.LatencyProfiles); err != nil {
+		panic(fmt.Sprintf("Invalid latency profiles: %s", err.Error()))
+	}
+
+	maintenance := &maintenanceTable{}
+	if err := maintenance.set(config.MaintenanceWindows); err != nil {
+		panic(fmt.Sprintf("Invalid maintenance windows: %s", err.Error()))
+	}
+
+	if err := config.ResponseOrdering.check(); err != nil {
+		panic(fmt.Sprintf("Invalid response ordering: %s", err.Error()))
+	}
+
+	for _, name := range config.GreaseEndpoints {
+		if err := checkEndpointPattern(name); err != nil {
+			panic(fmt.Sprintf("Invalid grease endpoints: %s", err.Error()))
+		}
+	}
+
+	holds := newHoldTable()
+	if err := holds.set(config.ProcessingHolds); err != nil {
+		panic(fmt.Sprintf("Invalid processing holds: %s", err.Error()))
+	}
+
+	overrides := newOverrideTable()
+	if err := overrides.set(config.AccountOverrides, va); err != nil {
+		panic(fmt.Sprintf("Invalid account overrides: %s", err.Error()))
+	}
+
+	eab, err := newEABTable(config.ExternalAccountMACKeys)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid external account MAC keys: %s", err.Error()))
+	}
+
+	for _, p := range config.ChallengePolicies {
+		if err := p.check(); err != nil {
+			panic(fmt.Sprintf("Invalid challenge policy: %s", err.Error()))
+		}
+	}
+
+	switch config.ResponseEncodingFault {
+	case "", EncodingFaultMismatch, EncodingFaultTruncated:
+	default:
+		panic(fmt.Sprintf("Unknown response encoding fault %q", config.ResponseEncodingFault))
+	}
+
+	challenges, err := newChallengeToggles(config.DisabledChallenges)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid disabled challenges: %s", err.Error()))
+	}
+
+	for _, t := range config.StaticTokens {
+		if err := t.check(); err != nil {
+			panic(fmt.Sprintf("Invalid static token: %s", err.Error()))
+		}
+	}
+	if err := config.TokenFormat.check(); err != nil {
+		panic(fmt.Sprintf("Invalid challenge token format: %s", err.Error()))
+	}
+
+	switch config.CSRReplayPolicy {
+	case CSRReplayAllow, CSRReplayAcrossAccounts, CSRReplayAcrossOrders:
+	default:
+		panic(fmt.Sprintf("Unknown CSR replay policy %q", config.CSRReplayPolicy))
+	}
+	switch config.ContentTypeCheck {
+	case ContentTypeCheckDefault, ContentTypeCheckExact, ContentTypeCheckLenient:
+	default:
+		panic(fmt.Sprintf("Unknown Content-Type check %q", config.ContentTypeCheck))
+	}
+
+	for _, p := range config.ManagementPrincipals {
+		if p.Token == "" && p.ClientCertCommonName == "" {
+			panic(fmt.Sprintf("Management principal %q has no token or client certificate", p.Name))
+		}
+	}
+
+	accessLog, err := newAccessLogger(config.AccessLog, config.AccessLogFormat)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid access log config: %s", err.Error()))
+	}
+
+	chainModes, err := parseChainModes(config.ChainModes)
+	if err != nil {
+		panic(fmt.Sprintf("Invalid chain modes: %s", err.Error()))
+	}
+
+	if err := checkObjectFields(config.ObjectFields); err != nil {
+		panic(fmt.Sprintf("Invalid object fields: %s", err.Error()))
+	}
+	if err := checkPatterns(config.UnsupportedIdentifiers); err != nil {
+		panic(fmt.Sprintf("Invalid unsupported identifiers: %s", err.Error()))
+	}
+	if err := checkPatterns(config.UnsupportedContacts); err != nil {
+		panic(fmt.Sprintf("Invalid unsupported contacts: %s", err.Error()))
+	}
+	if config.AutoFinalize && config.Events == nil {
+		panic("Auto-finalization requires an event broker")
+	}
+	if err := checkIdentifierStrictness(config.IdentifierStrictness); err != nil {
+		panic(fmt.Sprintf("Invalid identifier strictness: %s", err.Error()))
+	}
+	if err := config.AccountSourceBinding.check(); err != nil {
+		panic(fmt.Sprintf("Invalid account source binding: %s", err.Error()))
+	}
+	if err := config.Namespaces.check(); err != nil {
+		panic(fmt.Sprintf("Invalid namespaces: %s", err.Error()))
+	}
+	if err := config.SecurityHeaders.check(); err != nil {
+		panic(fmt.Sprintf("Invalid security headers: %s", err.Error()))
+	}
+
+	var nonces nonceService = newNonceMap()
+	if config.NonceKey != "" {
+		lifetime := config.NonceLifetime
+		if lifetime == 0 {
+			lifetime = defaultNonceLifetime
+		}
+		nonces, err = newDerivedNonces(config.NonceKey, config.NoncePrefix, lifetime, clk)
+		if err != nil {
+			panic(fmt.Sprintf("Invalid nonce config: %s", err.Error()))
+		}
+	}
+
+	var pathPrefix string
+	if config.RandomizePaths {
+		pathPrefix = "/" + randomString(12)
+		log.Printf("Serving ACME endpoints under %s", pathPrefix)
+	}
+
+	var failures *failureArchive
+	if config.FailureRetention > 0 {
+		failures = newFailureArchive(clk, db, config.FailureRetention)
+		config.Events.Observe(failures.observe)
+	}
+
+	var autoFinalize *autoFinalizer
+	if config.AutoFinalize {
+		autoFinalize = newAutoFinalizer(log, clk, db, config.Events)
+		config.Events.Observe(autoFinalize.observe)
+	}
+
+	wfe := WebFrontEndImpl{
 		log:             log,
 		db:              db,
-		nonce:           newNonceMap(),
+		nonce:           nonces,
+		jwsReplays:      newJWSReplays(config.JWSReplayWindow, clk),
+		duplicates:      newDuplicateCertificates(config.DuplicateCertificates, clk),
+		pendingOrders:   newPendingOrderLimit(config.MaxPendingOrders),
+		malformed:       newMalformedJWSCounter(),
 		nonceErrPercent: nonceErrPercent,
 		clk:             clk,
 		va:              va,
 		ca:              ca,
 		strict:          strict,
+		config:          config,
+		limiter:         limiter,
+		ipLimiter:       ipLimiter,
+		acctLimiter:     acctLimiter,
+		responseSigner:  signer,
+		heapStats:       newHeapStats(clk, config.HeapStatsInterval),
+		latency:         latency,
+		maintenance:     maintenance,
+		holds:           holds,
+		overrides:       overrides,
+		eab:             eab,
+		challenges:      challenges,
+		pathPrefix:      pathPrefix,
+		ready:           new(int32),
+		info:            new(atomic.Value),
+		chainModes:      chainModes,
+		accessLog:       accessLog,
+		acctNumbers:     newAccountNumbers(),
+		failures:        failures,
+		autoFinalizer:   autoFinalize,
+		massRevocations: &massRevocations{},
+		started:         clk.Now(),
+	}
+	if autoFinalize != nil {
+		autoFinalize.complete = wfe.completeOrder
+	}
+	return wfe
+}
+
+// endpointNames maps the path of each ACME endpoint to the name used to refer
+// to it in the Pebble configuration.
+var endpointNames = map[string]string{
+	directoryPath:     "directory",
+	noncePath:         "newNonce",
+	newAccountPath:    "newAccount",
+	acctPath:          "account",
+	boulderAcctPath:   "account",
+	legacyAcctPath:    "account",
+	newOrderPath:      "newOrder",
+	orderPath:         "order",
+	orderFinalizePath: "finalize",
+	authzPath:         "authz",
+	challengePath:     "challenge",
+	certPath:          "certificate",
+	revokeCertPath:    "revokeCert",
+	keyRolloverPath:   "keyChange",
+	delegationsPath:   "delegations",
+	delegationPath:    "delegation",
+
+	finalizeDryRunPath: "finalizeDryRun",
+}
+
+func knownEndpointName(name string) bool {
+	for _, n := range endpointNames {
+		if n == name {
+			return true
+		}
 	}
+	return false
+}
+
+// maxBodySize returns the maximum POST body size for the endpoint registered
+// with the given pattern, or zero if there is no limit.
+func (wfe *WebFrontEndImpl) maxBodySize(pattern string) int64 {
+	if limit, ok := wfe.config.MaxBodySizes[endpointNames[pattern]]; ok {
+		return limit
+	}
+	return wfe.config.MaxBodySize
 }
 
 func (wfe *WebFrontEndImpl) HandleFunc(
@@ -191,17 +872,72 @@ func (wfe *WebFrontEndImpl) HandleFunc(
 	}
 
 	methodsStr := strings.Join(methods, ", ")
+	maxBodySize := wfe.maxBodySize(pattern)
 	defaultHandler := http.StripPrefix(pattern,
 		&topHandler{
 			wfe: wfeHandlerFunc(func(ctx context.Context, logEvent *requestEvent, response http.ResponseWriter, request *http.Request) {
 				response.Header().Set("Replay-Nonce", wfe.nonce.createNonce())
+				if wfe.config.DateSkew != 0 {
+					// net/http only adds a Date header if the handler didn't set one
+					response.Header().Set("Date",
+						wfe.clk.Now().Add(wfe.config.DateSkew).UTC().Format(http.TimeFormat))
+				}
+				if wfe.config.ResponseEncoding {
+					response.Header().Add("Vary", "Accept-Encoding")
+					encoding := responseEncoding(request.Header.Get("Accept-Encoding"))
+					if encoding != "" && request.Method != "HEAD" {
+						encodingWriter := &encodingWriter{
+							ResponseWriter: response,
+							encoding:       encoding,
+							fault:          wfe.config.ResponseEncodingFault,
+						}
+						response = encodingWriter
+						defer encodingWriter.Close()
+					}
+				}
+				if wfe.greases(endpointNames[pattern]) {
+					greaser := &greaseWriter{ResponseWriter: response}
+					response = greaser
+					defer greaser.Close()
+				}
+				if wfe.config.OrderReports != nil && reportedEndpoints[endpointNames[pattern]] {
+					recorder := newInteractionRecorder(response, request,
+						wfe.config.OrderReports.maxBodyBytes(), wfe.clk.Now())
+					response = recorder
+					defer func() {
+						wfe.recordInteraction(pattern, logEvent, request, recorder)
+					}()
+				}
+				response = &acceptLanguageWriter{
+					ResponseWriter: response,
+					acceptLanguage: request.Header.Get("Accept-Language"),
+				}
 
 				logEvent.Endpoint = pattern
 				if request.URL != nil {
 					logEvent.Endpoint = path.Join(logEvent.Endpoint, request.URL.Path)
 				}
 
-				addNoCacheHeader(response)
+				var acctID string
+				if wfe.accessLog != nil {
+					logWriter := &accessLogWriter{ResponseWriter: response}
+					response = logWriter
+					start := wfe.clk.Now()
+					defer func() {
+						wfe.logAccess(start, logEvent, request, logWriter, acctID)
+					}()
+				}
+
+				wfe.addSecurityHeaders(response, endpointNames[pattern])
+
+				if wfe.addCORSHeaders(response, request) && request.Method == "OPTIONS" {
+					// Respond to CORS preflight requests for allowed origins
+					response.Header().Set("Access-Control-Allow-Methods", methodsStr)
+					response.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+					response.Header().Set("Access-Control-Max-Age", "86400")
+					response.WriteHeader(http.StatusNoContent)
+					return
+				}
 
 				if !methodsMap[request.Method] {
 					response.Header().Set("Allow", methodsStr)
@@ -209,24 +945,151 @@ func (wfe *WebFrontEndImpl) HandleFunc(
 					return
 				}
 
+				namespace, prob := wfe.config.Namespaces.namespace(request)
+				if prob != nil {
+					wfe.sendError(prob, response)
+					return
+				}
+				request = withNamespace(request, namespace)
+
+				if w := wfe.maintenance.check(endpointNames[pattern], wfe.clk.Now()); w != nil {
+					response.Header().Set("Retry-After", maintenanceRetryAfter(w.retryAfter(wfe.clk.Now())))
+					detail := w.Detail
+					if detail == "" {
+						detail = fmt.Sprintf("The %s endpoint is down for maintenance, try again later",
+							endpointNames[pattern])
+					}
+					wfe.sendError(acme.MaintenanceProblem(detail), response)
+					return
+				}
+
+				if wfe.ipLimiter.enabled() && (pattern == directoryPath || pattern == noncePath) {
+					if ok, retryAfter := wfe.ipLimiter.allow(namespaced(namespace, sourceIP(request))); !ok {
+						response.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+						wfe.sendError(acme.RateLimitedProblem(fmt.Sprintf(
+							"Too many %s requests from this IP, try again later",
+							endpointNames[pattern])), response)
+						return
+					}
+				}
+
+				// POST requests must carry a Content-Length header (see validPOST) and
+				// net/http never reads past it, so it is enough to check the declared
+				// length against the limit.
+				if maxBodySize > 0 && request.Method == "POST" {
+					if request.ContentLength > maxBodySize {
+						wfe.sendError(acme.PayloadTooLargeProblem(fmt.Sprintf(
+							"POST body of %d bytes exceeds the %d byte limit for %s",
+							request.ContentLength, maxBodySize, endpointNames[pattern])), response)
+						return
+					}
+				}
+
+				// The account is needed for its override, which may come from
+				// its contact directives, so it is always looked up
+				acctID = wfe.requestAccountID(request)
+
+				if wfe.limiter.enabled() {
+					if !wfe.limiter.acquire(acctID) {
+						response.Header().Set("Retry-After",
+							strconv.Itoa(int(wfe.config.OverloadRetryAfter/time.Second)))
+						wfe.sendError(acme.ServiceUnavailableProblem(
+							"Too many concurrent requests, try again later"), response)
+						return
+					}
+					defer wfe.limiter.release(acctID)
+				}
+
+				override := wfe.accountOverride(acctID)
+				delay := wfe.latency.delay(endpointNames[pattern]) + override.delay()
+				if delay > 0 {
+					select {
+					case <-wfe.clk.After(delay):
+					case <-request.Context().Done():
+						return
+					}
+				}
+
+				if override.fails(endpointNames[pattern]) {
+					wfe.sendError(acme.InternalErrorProblem(fmt.Sprintf(
+						"%s request forced to fail by the override of account %q",
+						endpointNames[pattern], acctID)), response)
+					return
+				}
+
 				wfe.log.Printf("%s %s -> calling handler()\n", request.Method, logEvent.Endpoint)
 
-				// TODO(@cpu): Configurable request timeout
-				timeout := 1 * time.Minute
+				timeout := wfe.config.RequestTimeout
+				if timeout <= 0 {
+					timeout = defaultRequestTimeout
+				}
 				ctx, cancel := context.WithTimeout(ctx, timeout)
 				handler(ctx, logEvent, response, request)
+				if ctx.Err() == context.DeadlineExceeded {
+					wfe.config.TimeoutCounter.Record("wfe", "request")
+				}
 				cancel()
 			},
 			)})
 	mux.Handle(pattern, defaultHandler)
 }
 
+// addCORSHeaders adds CORS response headers if the request's Origin is one of
+// the configured allowed origins. It returns true if the headers were added.
+func (wfe *WebFrontEndImpl) addCORSHeaders(response http.ResponseWriter, request *http.Request) bool {
+	origin := request.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+
+	for _, allowed := range wfe.config.CORSAllowedOrigins {
+		if allowed == "*" || allowed == origin {
+			response.Header().Set("Access-Control-Allow-Origin", origin)
+			response.Header().Add("Vary", "Origin")
+			response.Header().Set("Access-Control-Expose-Headers",
+				strings.Join(wfe.config.CORSExposedHeaders, ", "))
+			return true
+		}
+	}
+	return false
+}
+
+// acceptLanguageWriter carries the Accept-Language header of a request to
+// sendError so that problem details can be localized.
+type acceptLanguageWriter struct {
+	http.ResponseWriter
+	acceptLanguage string
+}
+
+func (w *acceptLanguageWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 func (wfe *WebFrontEndImpl) sendError(prob *acme.ProblemDetails, response http.ResponseWriter) {
+	logWriter, _ := response.(*accessLogWriter)
+	inner := response
+	if logWriter != nil {
+		inner = logWriter.ResponseWriter
+	}
+	if languageWriter, ok := inner.(*acceptLanguageWriter); ok {
+		var language string
+		prob, language = acme.LocalizeProblem(prob, languageWriter.acceptLanguage)
+		if language != "" {
+			response.Header().Set("Content-Language", language)
+		}
+	}
+
 	problemDoc, err := marshalIndent(prob)
 	if err != nil {
 		problemDoc = []byte("{\"detail\": \"Problem marshalling error message.\"}")
 	}
 
+	if logWriter != nil {
+		logWriter.problemType = prob.Type
+	}
+
 	response.Header().Set("Content-Type", "application/problem+json; charset=utf-8")
 	response.WriteHeader(prob.HTTPStatus)
 	response.Write(problemDoc)
@@ -244,9 +1107,21 @@ func (wfe *WebFrontEndImpl) Handler() http.Handler {
 	wfe.HandleFunc(m, authzPath, wfe.Authz, "GET")
 	wfe.HandleFunc(m, challengePath, wfe.Challenge, "GET", "POST")
 	wfe.HandleFunc(m, certPath, wfe.Certificate, "GET")
-	wfe.HandleFunc(m, acctPath, wfe.UpdateAccount, "POST")
+	wfe.HandleFunc(m, wfe.accountPath(), wfe.UpdateAccount, "POST")
 	wfe.HandleFunc(m, revokeCertPath, wfe.RevokeCert, "POST")
+	wfe.HandleFunc(m, keyRolloverPath, wfe.KeyRollover, "POST")
+	wfe.HandleFunc(m, issuerCertPath, wfe.IssuerCert, "GET")
+	if wfe.config.EnableDelegation {
+		wfe.HandleFunc(m, delegationsPath, wfe.Delegations, "GET")
+		wfe.HandleFunc(m, delegationPath, wfe.Delegation, "GET")
+	}
+	if wfe.config.EnableFinalizeDryRun {
+		wfe.HandleFunc(m, finalizeDryRunPath, wfe.FinalizeDryRun, "POST")
+	}
 
+	if wfe.pathPrefix != "" {
+		return wfe.prefixHandler(m)
+	}
 	return m
 }
 
@@ -261,6 +1136,7 @@ func (wfe *WebFrontEndImpl) Directory(
 		"newAccount": newAccountPath,
 		"newOrder":   newOrderPath,
 		"revokeCert": revokeCertPath,
+		"keyChange":  keyRolloverPath,
 	}
 
 	response.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -271,6 +1147,10 @@ func (wfe *WebFrontEndImpl) Directory(
 		return
 	}
 
+	if wfe.notModified(response, request, relDir, wfe.started) {
+		return
+	}
+	wfe.signResponse(response, relDir)
 	response.Write(relDir)
 }
 
@@ -282,10 +1162,37 @@ func (wfe *WebFrontEndImpl) relativeDirectory(request *http.Request, directory m
 	for k, v := range directory {
 		relativeDir[k] = wfe.relativeEndpoint(request, v)
 	}
-	relativeDir["meta"] = map[string]string{
+	view := requestView(request)
+	meta := map[string]interface{}{
 		"termsOfService": ToSURL,
 	}
+	if view.ExternalAccountRequired {
+		meta["externalAccountRequired"] = true
+	}
+	if wfe.config.RequireAccountAttestation {
+		meta["attestationRequired"] = true
+	}
+	if wfe.config.EnableSubdomainAuth {
+		meta["subdomainAuthAllowed"] = true
+	}
+	if wfe.config.EnableDelegation {
+		meta["delegation-enabled"] = true
+		meta["allow-certificate-get"] = true
+	}
+	for k, v := range view.Meta {
+		meta[k] = v
+	}
+	relativeDir["meta"] = meta
 
+	changed, err := wfe.changeFields(ObjectDirectory, relativeDir)
+	if err != nil {
+		return nil, err
+	}
+	relativeDir = changed.(map[string]interface{})
+
+	if wfe.config.ShuffleDirectory {
+		return shuffledJSON(relativeDir)
+	}
 	directoryJSON, err := marshalIndent(relativeDir)
 	// This should never happen since we are just marshalling known strings
 	if err != nil {
@@ -315,7 +1222,7 @@ func (wfe *WebFrontEndImpl) relativeEndpoint(request *http.Request, endpoint str
 		host = "localhost"
 	}
 
-	resultUrl := url.URL{Scheme: proto, Host: host, Path: endpoint}
+	resultUrl := url.URL{Scheme: proto, Host: host, Path: wfe.acmePath(endpoint)}
 	return resultUrl.String()
 }
 
@@ -354,45 +1261,32 @@ func keyToID(key crypto.PublicKey) (string, error) {
 }
 
 func (wfe *WebFrontEndImpl) parseJWS(body string) (*jose.JSONWebSignature, error) {
-	// Parse the raw JWS JSON to check that:
-	// * the unprotected Header field is not being used.
-	// * the "signatures" member isn't present, just "signature".
-	//
-	// This must be done prior to `jose.parseSigned` since it will strip away
-	// these headers.
-	var unprotected struct {
-		Header     map[string]string
-		Signatures []interface{}
-	}
-	if err := json.Unmarshal([]byte(body), &unprotected); err != nil {
-		return nil, errors.New("Parse error reading JWS")
-	}
-
-	// ACME v2 never uses values from the unprotected JWS header. Reject JWS that
-	// include unprotected headers.
-	if unprotected.Header != nil {
-		return nil, errors.New(
-			"JWS \"header\" field not allowed. All headers must be in \"protected\" field")
-	}
-
-	// ACME v2 never uses the "signatures" array of JSON serialized JWS, just the
-	// mandatory "signature" field. Reject JWS that include the "signatures" array.
-	if len(unprotected.Signatures) > 0 {
-		return nil, errors.New(
-			"JWS \"signatures\" field not allowed. Only the \"signature\" field should contain a signature")
+	// Check the raw JWS for features ACME doesn't allow, like unprotected
+	// headers and the "signatures" array. This must be done prior to
+	// `jose.parseSigned` since it will strip away these headers.
+	if err := wfe.checkJWSFeatures(body); err != nil {
+		return nil, wfe.malformedJWS(err)
 	}
 
+	// go-jose only reports that a JWS couldn't be parsed, so the reason is
+	// diagnosed separately to tell client developers what is wrong with it
 	parsedJWS, err := jose.ParseSigned(body)
 	if err != nil {
-		return nil, errors.New("Parse error reading JWS")
+		return nil, wfe.malformedJWS(diagnoseJWS(body))
 	}
 
 	if len(parsedJWS.Signatures) > 1 {
-		return nil, errors.New("Too many signatures in POST body")
+		return nil, wfe.malformedJWS(&jwsError{
+			category: malformedSignatureCount,
+			detail:   "Too many signatures in POST body",
+		})
 	}
 
 	if len(parsedJWS.Signatures) == 0 {
-		return nil, errors.New("POST JWS not signed")
+		return nil, wfe.malformedJWS(&jwsError{
+			category: malformedSignatureCount,
+			detail:   "POST JWS not signed",
+		})
 	}
 	return parsedJWS, nil
 }
@@ -448,19 +1342,35 @@ func (wfe *WebFrontEndImpl) extractJWK(_ *http.Request, jws *jose.JSONWebSignatu
 func (wfe *WebFrontEndImpl) lookupJWK(request *http.Request, jws *jose.JSONWebSignature) (*jose.JSONWebKey, *acme.ProblemDetails) {
 	header := jws.Signatures[0].Header
 	accountURL := header.KeyID
-	prefix := wfe.relativeEndpoint(request, acctPath)
-	if !strings.HasPrefix(accountURL, prefix) {
-		return nil, acme.MalformedProblem("Key ID (kid) in JWS header missing expected URL prefix")
+	accountID := wfe.keyIDAccount(request, accountURL)
+	if accountID == "" && wfe.config.LegacyKeyIDs {
+		accountID = wfe.parseAccountURL(accountURL)
 	}
-	accountID := strings.TrimPrefix(accountURL, prefix)
 	if accountID == "" {
-		return nil, acme.MalformedProblem("No key ID (kid) in JWS header")
+		if wfe.parseAccountURL(accountURL) != "" {
+			return nil, acme.MalformedProblem(fmt.Sprintf(
+				"Key ID (kid) %q in JWS header isn't an account URL in the %s format",
+				accountURL, wfe.accountURLFormat()))
+		}
+		return nil, acme.MalformedProblem("Key ID (kid) in JWS header missing expected URL prefix")
 	}
 	account := wfe.db.GetAccountByID(accountID)
 	if account == nil {
 		return nil, acme.AccountDoesNotExistProblem(fmt.Sprintf(
 			"Account %s not found.", accountURL))
 	}
+	// RFC 8555 Section 7.3.6: no further requests signed by a deactivated
+	// account's key are accepted
+	if account.Status == acme.StatusDeactivated {
+		return nil, acme.UnauthorizedProblem("Account has been deactivated")
+	}
+	if prob := checkAccountSource(account, request); prob != nil {
+		return nil, prob
+	}
+	if account.Namespace != requestNamespace(request) {
+		return nil, acme.AccountDoesNotExistProblem(fmt.Sprintf(
+			"Account %s not found.", accountURL))
+	}
 	if header.JSONWebKey != nil {
 		return nil, acme.MalformedProblem("jwk and kid header fields are mutually exclusive.")
 	}
@@ -468,7 +1378,14 @@ func (wfe *WebFrontEndImpl) lookupJWK(request *http.Request, jws *jose.JSONWebSi
 }
 
 func (wfe *WebFrontEndImpl) validPOST(request *http.Request) *acme.ProblemDetails {
-	if wfe.strict {
+	switch {
+	case wfe.config.ContentTypeCheck == ContentTypeCheckExact:
+		if contentType := request.Header.Get("Content-Type"); contentType != expectedJWSContentType {
+			return acme.MalformedProblem(fmt.Sprintf(
+				`Invalid Content-Type header %q on POST. `+
+					`Content-Type must be exactly "application/jose+json"`, contentType))
+		}
+	case wfe.config.ContentTypeCheck == ContentTypeCheckDefault && wfe.strict:
 		// Section 6.2 says to reject JWS requests without the expected Content-Type
 		// using a status code of http.UnsupportedMediaType
 		if _, present := request.Header["Content-Type"]; !present {
@@ -545,7 +1462,18 @@ func (wfe *WebFrontEndImpl) verifyJWS(
 
 	payload, err := parsedJWS.Verify(pubKey)
 	if err != nil {
-		return nil, nil, acme.MalformedProblem("JWS verification error")
+		wfe.malformed.record(malformedVerification)
+		reason := "the signature doesn't match the key"
+		if _, err := checkAlgorithm(pubKey, parsedJWS); err != nil {
+			reason = err.Error()
+		}
+		return nil, nil, acme.MalformedProblem("JWS verification error: " + reason)
+	}
+
+	// An exact replay would fail the nonce check below since its nonce was
+	// already used, so it's checked first to report it distinctly
+	if wfe.jwsReplays.replayed(parsedJWS.Signatures[0].Signature) {
+		return nil, nil, acme.JWSReplayedProblem("JWS is a replay of an earlier request")
 	}
 
 	nonce := parsedJWS.Signatures[0].Header.Nonce
@@ -564,8 +1492,23 @@ func (wfe *WebFrontEndImpl) verifyJWS(
 
 	headerURL, ok := parsedJWS.Signatures[0].Header.ExtraHeaders[jose.HeaderKey("url")].(string)
 	if !ok || len(headerURL) == 0 {
+		wfe.malformed.record(malformedURL)
 		return nil, nil, acme.MalformedProblem("JWS header parameter 'url' required.")
 	}
+	expectedURL := wfe.expectedJWSURL(request)
+	if expectedURL != headerURL {
+		wfe.malformed.record(malformedURL)
+		return nil, nil, acme.MalformedProblem(fmt.Sprintf(
+			"JWS header parameter 'url' incorrect. Expected %q, got %q",
+			expectedURL, headerURL))
+	}
+
+	return []byte(payload), pubKey, nil
+}
+
+// expectedJWSURL returns the value the "url" JWS header parameter must have
+// for the given request.
+func (wfe *WebFrontEndImpl) expectedJWSURL(request *http.Request) string {
 	expectedURL := url.URL{
 		// NOTE(@cpu): ACME **REQUIRES** HTTPS and Pebble is hardcoded to offer the
 		// API over HTTPS.
@@ -573,13 +1516,7 @@ func (wfe *WebFrontEndImpl) verifyJWS(
 		Host:   request.Host,
 		Path:   request.RequestURI,
 	}
-	if expectedURL.String() != headerURL {
-		return nil, nil, acme.MalformedProblem(fmt.Sprintf(
-			"JWS header parameter 'url' incorrect. Expected %q, got %q",
-			expectedURL.String(), headerURL))
-	}
-
-	return []byte(payload), pubKey, nil
+	return expectedURL.String()
 }
 
 // isASCII determines if every character in a string is encoded in
@@ -607,6 +1544,9 @@ func (wfe *WebFrontEndImpl) verifyContacts(acct acme.Account) *acme.ProblemDetai
 	}
 
 	for _, c := range contacts {
+		if prob := wfe.unsupportedContact(c); prob != nil {
+			return prob
+		}
 		parsed, err := url.Parse(c)
 		if err != nil {
 			return acme.InvalidContactProblem(fmt.Sprintf("contact %q is invalid", c))
@@ -635,6 +1575,9 @@ func (wfe *WebFrontEndImpl) verifyContacts(acct acme.Account) *acme.ProblemDetai
 		}
 	}
 
+	if _, err := contactOverride(contacts); err != nil {
+		return acme.InvalidContactProblem(err.Error())
+	}
 	return nil
 }
 
@@ -684,9 +1627,13 @@ func (wfe *WebFrontEndImpl) UpdateAccount(
 			Contact: existingAcct.Contact,
 			Status:  existingAcct.Status,
 			Orders:  existingAcct.Orders,
+
+			Delegations: existingAcct.Delegations,
 		},
-		Key: existingAcct.Key,
-		ID:  existingAcct.ID,
+		Key:           existingAcct.Key,
+		ID:            existingAcct.ID,
+		SourceNetwork: existingAcct.SourceNetwork,
+		Namespace:     existingAcct.Namespace,
 	}
 
 	switch {
@@ -713,6 +1660,170 @@ func (wfe *WebFrontEndImpl) UpdateAccount(
 			acme.MalformedProblem("Error storing updated account"), response)
 		return
 	}
+	if newAcct.Status == acme.StatusDeactivated {
+		wfe.cancelAccountObjects(newAcct.ID)
+		wfe.config.Audit.Record(audit.TypeAccountDeactivated, newAcct.ID, nil)
+	}
+
+	err = wfe.writeJsonResponse(response, http.StatusOK, newAcct)
+	if err != nil {
+		wfe.sendError(acme.InternalErrorProblem("Error marshalling account"), response)
+		return
+	}
+}
+
+// cancelAccountObjects deactivates the pending authorizations and invalidates
+// the unfinalized orders of a deactivated account, as RFC 8555 Section 7.3.6
+// suggests. Orders already being processed are left to finish.
+func (wfe *WebFrontEndImpl) cancelAccountObjects(accountID string) {
+	for _, authz := range wfe.db.GetAuthorizationsByAccountID(accountID) {
+		authz.Lock()
+		deactivated := authz.Status == acme.StatusPending
+		if deactivated {
+			authz.Status = acme.StatusDeactivated
+		}
+		authz.Unlock()
+		if deactivated {
+			wfe.config.Events.Publish(events.TypeAuthorization, authz.ID,
+				acme.StatusDeactivated, authz.Identifier.Value)
+		}
+	}
+
+	var invalidated int
+	for _, order := range wfe.db.GetOrdersByAccountID(accountID) {
+		status, err := order.GetStatus(wfe.clk)
+		if err != nil || (status != acme.StatusPending &&
+			status != acme.StatusReady && status != acme.StatusDeactivated) {
+			continue
+		}
+		order.Lock()
+		if order.BeganProcessing {
+			order.Unlock()
+			continue
+		}
+		order.Error = acme.UnauthorizedProblem("Account has been deactivated")
+		order.Status = acme.StatusInvalid
+		order.Unlock()
+		invalidated++
+		wfe.config.Events.Publish(events.TypeOrder, order.ID, acme.StatusInvalid, "")
+	}
+	wfe.log.Printf("Deactivated account %q, invalidated %d orders\n", accountID, invalidated)
+}
+
+// KeyRollover changes the key of an existing account as described in RFC 8555
+// Section 7.3.5. The outer JWS is signed by the account's current key and its
+// payload is an inner JWS signed by the new key.
+func (wfe *WebFrontEndImpl) KeyRollover(
+	ctx context.Context,
+	logEvent *requestEvent,
+	response http.ResponseWriter,
+	request *http.Request) {
+
+	// Verify the outer JWS using the existing account key
+	body, outerKey, prob := wfe.verifyPOST(ctx, logEvent, request, wfe.lookupJWK)
+	if prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+
+	existingAcct, prob := wfe.getAcctByKey(outerKey)
+	if prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+
+	// Parse and verify the inner JWS using the new key it embeds
+	innerJWS, err := wfe.parseJWS(string(body))
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem("Inner JWS: "+err.Error()), response)
+		return
+	}
+	newKey, prob := wfe.extractJWK(request, innerJWS)
+	if prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+	innerPayload, err := innerJWS.Verify(newKey)
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem("Inner JWS verification error"), response)
+		return
+	}
+
+	innerHeader := innerJWS.Signatures[0].Header
+	if innerHeader.Nonce != "" {
+		wfe.sendError(acme.MalformedProblem("Inner JWS must not have a nonce"), response)
+		return
+	}
+	innerURL, _ := innerHeader.ExtraHeaders[jose.HeaderKey("url")].(string)
+	outerURL := wfe.expectedJWSURL(request)
+	if innerURL != outerURL {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Inner JWS header parameter 'url' incorrect. Expected %q, got %q",
+			outerURL, innerURL)), response)
+		return
+	}
+
+	var rolloverReq struct {
+		Account string
+		OldKey  *jose.JSONWebKey
+	}
+	if err := json.Unmarshal(innerPayload, &rolloverReq); err != nil {
+		wfe.sendError(acme.MalformedProblem("Error unmarshaling key rollover request"), response)
+		return
+	}
+
+	acctURL := wfe.accountURL(request, existingAcct.ID)
+	if rolloverReq.Account != acctURL &&
+		!(wfe.config.LegacyKeyIDs && wfe.parseAccountURL(rolloverReq.Account) == existingAcct.ID) {
+		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+			"Key rollover account %q doesn't match the account %q that signed the request",
+			rolloverReq.Account, acctURL)), response)
+		return
+	}
+	if rolloverReq.OldKey == nil || !keyDigestEquals(rolloverReq.OldKey, existingAcct.Key) {
+		wfe.sendError(acme.MalformedProblem(
+			"Key rollover oldKey doesn't match the account's current key"), response)
+		return
+	}
+
+	// The new key must not already be in use by another account. If it is
+	// return a conflict with the Location of that account.
+	conflictAcct, err := wfe.db.GetAccountByKey(newKey)
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem("Error computing key thumbprint"), response)
+		return
+	}
+	if conflictAcct != nil {
+		response.Header().Set("Location",
+			wfe.accountURL(request, conflictAcct.ID))
+		wfe.sendError(acme.Conflict("New key is already in use by another account"), response)
+		return
+	}
+
+	newAcct := &core.Account{
+		Account: acme.Account{
+			Contact: existingAcct.Contact,
+			Status:  existingAcct.Status,
+			Orders:  existingAcct.Orders,
+
+			Delegations: existingAcct.Delegations,
+		},
+		Key:           newKey,
+		ID:            existingAcct.ID,
+		SourceNetwork: existingAcct.SourceNetwork,
+		Namespace:     existingAcct.Namespace,
+	}
+	if err := wfe.db.UpdateAccountByID(existingAcct.ID, newAcct); err != nil {
+		wfe.sendError(acme.Conflict(err.Error()), response)
+		return
+	}
+	wfe.log.Printf("Rolled over key for account %s\n", existingAcct.ID)
+	oldDigest, _ := keyDigest(existingAcct.Key)
+	newDigest, _ := keyDigest(newKey)
+	wfe.config.Audit.Record(audit.TypeAccountKeyChanged, existingAcct.ID, map[string]string{
+		"oldKey": oldDigest,
+		"newKey": newDigest,
+	})
 
 	err = wfe.writeJsonResponse(response, http.StatusOK, newAcct)
 	if err != nil {
@@ -736,11 +1847,26 @@ func (wfe *WebFrontEndImpl) NewAccount(
 		return
 	}
 
+	if wfe.config.RejectEd25519AccountKeys && isEd25519Key(key) {
+		wfe.sendError(acme.BadPublicKeyProblem(
+			"Ed25519 account keys are not supported, use an RSA or ECDSA key"), response)
+		return
+	}
+	if err := cryptomode.CheckKey(key.Key); err != nil {
+		wfe.sendError(acme.BadPublicKeyProblem(err.Error()), response)
+		return
+	}
+
 	// newAcctReq is the ACME account information submitted by the client
 	var newAcctReq struct {
 		Contact            []string `json:"contact"`
 		ToSAgreed          bool     `json:"termsOfServiceAgreed"`
 		OnlyReturnExisting bool     `json:"onlyReturnExisting"`
+
+		ExternalAccountBinding json.RawMessage `json:"externalAccountBinding"`
+		// AttObj is a base64url encoded WebAuthn-style attestation object,
+		// e.g. of the "packed" or "tpm" format.
+		AttObj string `json:"attObj"`
 	}
 	err := json.Unmarshal(body, &newAcctReq)
 	if err != nil {
@@ -758,13 +1884,34 @@ func (wfe *WebFrontEndImpl) NewAccount(
 	// Lookup existing account to exit early if it exists
 	// NOTE: We don't use wfe.getAccountByKey here because we want to treat a
 	//       "missing" account as a non-error
-	existingAcct := wfe.db.GetAccountByID(keyID)
+	existingAcct, err := wfe.db.GetAccountByKey(key)
+	if err != nil {
+		wfe.sendError(acme.MalformedProblem("Error computing key thumbprint"), response)
+		return
+	}
+	if existingAcct != nil && existingAcct.Status == acme.StatusDeactivated {
+		wfe.sendError(acme.UnauthorizedProblem("Account has been deactivated"), response)
+		return
+	}
 	if existingAcct != nil {
+		if prob := checkAccountSource(existingAcct, request); prob != nil {
+			wfe.sendError(prob, response)
+			return
+		}
+		if existingAcct.Namespace != requestNamespace(request) {
+			wfe.sendError(acme.UnauthorizedProblem(
+				"Account key is already used by an account in another namespace"), response)
+			return
+		}
 		// If there is an existing account then return a Location header pointing to
-		// the account and a 200 OK response
-		acctURL := wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", acctPath, existingAcct.ID))
+		// the account, the existing account object and a 200 OK response per RFC
+		// 8555 Section 7.3.1
+		acctURL := wfe.accountURL(request, existingAcct.ID)
 		response.Header().Set("Location", acctURL)
-		_ = wfe.writeJsonResponse(response, http.StatusOK, nil)
+		err = wfe.writeJsonResponse(response, http.StatusOK, existingAcct)
+		if err != nil {
+			wfe.sendError(acme.InternalErrorProblem("Error marshalling account"), response)
+		}
 		return
 	} else if existingAcct == nil && newAcctReq.OnlyReturnExisting {
 		// If there *isn't* an existing account and the created account request
@@ -784,6 +1931,40 @@ func (wfe *WebFrontEndImpl) NewAccount(
 		return
 	}
 
+	eabRequired := requestView(request).ExternalAccountRequired || wfe.overrides.get(keyID).RequireEAB
+	if eabRequired && len(newAcctReq.ExternalAccountBinding) == 0 {
+		wfe.sendError(acme.ExternalAccountRequiredProblem(
+			"An external account binding is required to create an account"), response)
+		return
+	}
+	var eabKeyID string
+	if len(newAcctReq.ExternalAccountBinding) > 0 {
+		eabKeyID, prob = wfe.verifyExternalAccountBinding(request, newAcctReq.ExternalAccountBinding, key)
+		if prob != nil {
+			wfe.sendError(prob, response)
+			return
+		}
+	}
+
+	var attObj []byte
+	var attFormat string
+	if newAcctReq.AttObj != "" {
+		attObj, err = base64.RawURLEncoding.DecodeString(newAcctReq.AttObj)
+		if err != nil {
+			wfe.sendError(acme.MalformedProblem("attObj must be base64url encoded"), response)
+			return
+		}
+		attFormat, err = va.ParseAttestationObject(attObj)
+		if err != nil {
+			wfe.sendError(acme.BadAttestationStatementProblem(err.Error()), response)
+			return
+		}
+	} else if wfe.config.RequireAccountAttestation {
+		wfe.sendError(acme.BadAttestationStatementProblem(
+			"An attestation object is required to create an account"), response)
+		return
+	}
+
 	// Create a new account object with the provided contact
 	newAcct := core.Account{
 		Account: acme.Account{
@@ -791,8 +1972,16 @@ func (wfe *WebFrontEndImpl) NewAccount(
 			// New accounts are valid to start.
 			Status: acme.StatusValid,
 		},
-		Key: key,
-		ID:  keyID,
+		Key:           key,
+		ID:            keyID,
+		SourceNetwork: wfe.config.AccountSourceBinding.network(request),
+		Namespace:     requestNamespace(request),
+
+		AttestationObject: attObj,
+		AttestationFormat: attFormat,
+	}
+	if wfe.config.EnableDelegation {
+		newAcct.Delegations = wfe.relativeEndpoint(request, delegationsPath+keyID)
 	}
 
 	// Verify that the contact information provided is supported & valid
@@ -802,14 +1991,34 @@ func (wfe *WebFrontEndImpl) NewAccount(
 		return
 	}
 
+	if eabKeyID == "" {
+		prob = wfe.checkNewAccountLimit(response, request)
+		if prob != nil {
+			wfe.sendError(prob, response)
+			return
+		}
+	}
+
 	count, err := wfe.db.AddAccount(&newAcct)
 	if err != nil {
 		wfe.sendError(acme.InternalErrorProblem("Error saving account"), response)
 		return
 	}
 	wfe.log.Printf("There are now %d accounts in memory\n", count)
+	keyDigest, _ := keyDigest(newAcct.Key)
+	details := map[string]string{
+		"key": keyDigest,
+	}
+	if eabKeyID != "" {
+		wfe.eab.bind(eabKeyID, newAcct.ID)
+		details["externalAccountKeyID"] = eabKeyID
+	}
+	if attFormat != "" {
+		details["attestationFormat"] = attFormat
+	}
+	wfe.config.Audit.Record(audit.TypeAccountCreated, newAcct.ID, details)
 
-	acctURL := wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", acctPath, newAcct.ID))
+	acctURL := wfe.accountURL(request, newAcct.ID)
 
 	response.Header().Add("Location", acctURL)
 	err = wfe.writeJsonResponse(response, http.StatusCreated, newAcct)
@@ -849,168 +2058,331 @@ func (wfe *WebFrontEndImpl) verifyOrder(order *core.Order) *acme.ProblemDetails
 	if len(idents) == 0 {
 		return acme.MalformedProblem("Order did not specify any identifiers")
 	}
-	// Check that all of the identifiers in the new-order are DNS type
+	if wfe.config.MaxIdentifiers > 0 && len(idents) > wfe.config.MaxIdentifiers {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included %d identifiers, more than the maximum of %d",
+			len(idents), wfe.config.MaxIdentifiers))
+	}
+	// Check that all of the identifiers in the new-order are of a registered
+	// type that is enabled, and that their values are valid for the type
+	perType := make(map[string]int)
 	for _, ident := range idents {
-		if ident.Type != acme.IdentifierDNS {
+		if prob := wfe.unsupportedIdentifier(ident); prob != nil {
+			return prob
+		}
+		identType, ok := wfe.identifierType(ident.Type)
+		if !ok {
 			return acme.MalformedProblem(fmt.Sprintf(
 				"Order included non-DNS type identifier: type %q, value %q",
 				ident.Type, ident.Value))
 		}
-
-		rawDomain := ident.Value
-		if rawDomain == "" {
+		perType[ident.Type]++
+		if identType.MaxPerOrder > 0 && perType[ident.Type] > identType.MaxPerOrder {
 			return acme.MalformedProblem(fmt.Sprintf(
-				"Order included DNS identifier with empty value"))
+				"Order included more than %d %s identifiers", identType.MaxPerOrder, ident.Type))
 		}
+		if identType.Validate != nil {
+			if prob := identType.Validate(wfe, ident); prob != nil {
+				return prob
+			}
+		}
+	}
+	var names []string
+	for _, ident := range idents {
+		if ident.Type == acme.IdentifierDNS {
+			names = append(names, ident.Value)
+		}
+	}
+	if err := wfe.ca.CheckConstrainedNames(names); err != nil {
+		return acme.RejectedIdentifierProblem(fmt.Sprintf("Order rejected: %s", err))
+	}
+	return nil
+}
 
-		for _, ch := range []byte(rawDomain) {
-			if !isDNSCharacter(ch) {
-				return acme.MalformedProblem(fmt.Sprintf(
-					"Order included DNS identifier with a value containing an illegal character: %q",
-					ch))
+// newOrderObjects are the authorizations and challenges made for a new order,
+// which are stored along with it by addOrder.
+type newOrderObjects struct {
+	authzs []*core.Authorization
+	chals  []*core.Challenge
+}
+
+// makeAuthorizations populates an order with new authz's, which aren't stored
+// until the order is added with addOrder. The request parameter is required to
+// make the authz URL's absolute based on the request host
+func (wfe *WebFrontEndImpl) makeAuthorizations(order *core.Order, request *http.Request) (newOrderObjects, error) {
+	var auths []string
+	var authObs []*core.Authorization
+	var objects newOrderObjects
+
+	// Lock the order for reading
+	order.RLock()
+	// Create one authz for each name in the order's parsed CSR, and for each
+	// permanent identifier. Names can instead be authorized by an existing
+	// valid authz, or a new one, for an ancestor domain (RFC 9444).
+	var idents []acme.Identifier
+	subdomainAuth := make(map[acme.Identifier]bool)
+	for _, name := range order.Names {
+		if authz := wfe.validAuthz(order.AccountID, name); authz != nil {
+			wfe.log.Printf("Reusing authz %s for %q\n", authz.ID, name)
+			auths = append(auths, authz.URL)
+			authObs = append(authObs, authz)
+			continue
+		}
+		if authz := wfe.reusableAuthz(order.AccountID, name); authz != nil {
+			wfe.log.Printf("Reusing authz %s for subdomain %q\n", authz.ID, name)
+			auths = append(auths, authz.URL)
+			authObs = append(authObs, authz)
+			continue
+		}
+		ident := acme.Identifier{Type: acme.IdentifierDNS, Value: name}
+		if ancestor, ok := order.AncestorDomains[name]; ok {
+			ident.Value = ancestor
+			if subdomainAuth[ident] {
+				// Another name already has an authz for this ancestor
+				continue
 			}
+			subdomainAuth[ident] = true
 		}
+		idents = append(idents, ident)
+	}
+	for _, id := range order.PermanentIDs {
+		idents = append(idents, acme.Identifier{Type: acme.IdentifierPermanentID, Value: id})
+	}
+	if order.TNAuthList != nil {
+		idents = append(idents, acme.Identifier{
+			Type:  acme.IdentifierTNAuthList,
+			Value: base64.RawURLEncoding.EncodeToString(order.TNAuthList),
+		})
+	}
+	seen := make(map[acme.Identifier]bool)
+	for _, ident := range order.Identifiers {
+		ident = acme.Identifier{Type: ident.Type, Value: ident.Value}
+		if !builtinIdentifierTypes[ident.Type] && !seen[ident] {
+			seen[ident] = true
+			idents = append(idents, ident)
+		}
+	}
+	for _, ident := range idents {
+		now := wfe.clk.Now().UTC()
+		expires := now.Add(wfe.config.PendingAuthzLifetime)
+		authz := &core.Authorization{
+			ID:          newToken(),
+			ExpiresDate: expires,
+			Order:       order,
+			Authorization: acme.Authorization{
+				Status:     acme.StatusPending,
+				Identifier: ident,
+				Expires:    expires.UTC().Format(time.RFC3339),
 
-		if len(rawDomain) > maxDNSIdentifierLength {
-			return acme.MalformedProblem(fmt.Sprintf(
-				"Order included DNS identifier that was longer than %d characters",
-				maxDNSIdentifierLength))
+				SubdomainAuthAllowed: subdomainAuth[ident],
+			},
 		}
+		authz.URL = wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", authzPath, authz.ID))
+		// Create the challenges for this authz
+		chals, err := wfe.makeChallenges(authz, request)
+		if err != nil {
+			order.RUnlock()
+			return newOrderObjects{}, err
+		}
+		objects.authzs = append(objects.authzs, authz)
+		objects.chals = append(objects.chals, chals...)
+		authzURL := wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", authzPath, authz.ID))
+		auths = append(auths, authzURL)
+		authObs = append(authObs, authz)
+	}
+	// Unlock the order from reading
+	order.RUnlock()
 
-		if ip := net.ParseIP(rawDomain); ip != nil {
+	// Lock the order for writing & update the order's authorizations
+	order.Lock()
+	order.Authorizations = auths
+	order.AuthorizationObjects = authObs
+	order.Unlock()
+	return objects, nil
+}
+
+// addOrder stores a new order along with the authorizations and challenges
+// made for it in one batch, so that none of them are seen without the others,
+// and returns the number of orders in the db.
+func (wfe *WebFrontEndImpl) addOrder(order *core.Order, objects newOrderObjects) (int, error) {
+	var count int
+	err := wfe.db.Batch(func(tx db.Tx) error {
+		for _, chal := range objects.chals {
+			if _, err := tx.AddChallenge(chal); err != nil {
+				return err
+			}
+		}
+		for _, authz := range objects.authzs {
+			if _, err := tx.AddAuthorization(authz); err != nil {
+				return err
+			}
+		}
+		var err error
+		count, err = tx.AddOrder(order)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, authz := range objects.authzs {
+		authz.RLock()
+		status := authz.Status
+		authz.RUnlock()
+		wfe.config.Events.Publish(events.TypeAuthorization, authz.ID, status, authz.Identifier.Value)
+	}
+	return count, nil
+}
+
+// verifyAncestorDomain checks the ancestorDomain of a DNS identifier is a
+// strict ancestor of the identifier's value (RFC 9444). Unlike Boulder, Pebble
+// doesn't check the ancestor domain against the Public Suffix List.
+func (wfe *WebFrontEndImpl) verifyAncestorDomain(ident acme.Identifier) *acme.ProblemDetails {
+	if !wfe.config.EnableSubdomainAuth {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included identifier %q with an ancestorDomain but subdomain "+
+				"authorization is disabled", ident.Value))
+	}
+	ancestor := strings.ToLower(ident.AncestorDomain)
+	if strings.HasPrefix(ancestor, "*.") || !isSubdomain(strings.ToLower(ident.Value), ancestor) {
+		return acme.MalformedProblem(fmt.Sprintf(
+			"Order included identifier %q with ancestorDomain %q that isn't one of its ancestors",
+			ident.Value, ident.AncestorDomain))
+	}
+	for _, ch := range []byte(ancestor) {
+		if !isDNSCharacter(ch) {
 			return acme.MalformedProblem(fmt.Sprintf(
-				"Order included a DNS identifier with an IP address value: %q\n",
-				rawDomain))
+				"Order included ancestorDomain containing an illegal character: %q", ch))
 		}
+	}
+	return nil
+}
+
+// isSubdomain returns true if name is a subdomain of ancestor. Both must be
+// lowercase.
+func isSubdomain(name, ancestor string) bool {
+	return ancestor != "" && strings.HasSuffix(name, "."+ancestor)
+}
+
+// validAuthz returns a valid, unexpired authz of the account for the given
+// name that is still within the reuse window, or nil if there is none or
+// authz reuse is disabled.
+func (wfe *WebFrontEndImpl) validAuthz(accountID, name string) *core.Authorization {
+	if !wfe.config.ReuseAuthzs {
+		return nil
+	}
+	now := wfe.clk.Now()
+	return wfe.db.FindAuthorization(func(authz *core.Authorization) bool {
+		return authz.Status == acme.StatusValid &&
+			authz.ExpiresDate.After(now) &&
+			wfe.inReuseWindow(authz, now) &&
+			authz.Identifier.Type == acme.IdentifierDNS &&
+			authz.Identifier.Value == name &&
+			authz.Order != nil && authz.Order.AccountID == accountID
+	})
+}
+
+// inReuseWindow returns true if the valid authz was validated recently enough
+// to be reused at the given time.
+func (wfe *WebFrontEndImpl) inReuseWindow(authz *core.Authorization, now time.Time) bool {
+	return wfe.config.AuthzReuseWindow == 0 ||
+		authz.ValidatedDate.Add(wfe.config.AuthzReuseWindow).After(now)
+}
+
+// reusableAuthz returns a valid, unexpired authz of the account for an
+// ancestor domain of the given name that allows subdomain authorization, or
+// nil if there is none or subdomain authorization is disabled.
+func (wfe *WebFrontEndImpl) reusableAuthz(accountID, name string) *core.Authorization {
+	if !wfe.config.EnableSubdomainAuth || strings.HasPrefix(name, "*.") {
+		return nil
+	}
+	now := wfe.clk.Now()
+	return wfe.db.FindAuthorization(func(authz *core.Authorization) bool {
+		// The order's AccountID never changes after creation so it is read
+		// without locking the order.
+		return authz.Status == acme.StatusValid &&
+			authz.SubdomainAuthAllowed &&
+			authz.ExpiresDate.After(now) &&
+			wfe.inReuseWindow(authz, now) &&
+			authz.Identifier.Type == acme.IdentifierDNS &&
+			isSubdomain(name, authz.Identifier.Value) &&
+			authz.Order != nil && authz.Order.AccountID == accountID
+	})
+}
 
-		if strings.HasSuffix(rawDomain, ".") {
-			return acme.MalformedProblem(fmt.Sprintf(
-				"Order included a DNS identifier with a value ending in a period: %q\n",
-				rawDomain))
+// reusableOrder returns an existing order of the new order's account with the
+// same identifiers, validity period, delegation and replaced certificate that
+// hasn't been finalized yet, or nil if there is none.
+func (wfe *WebFrontEndImpl) reusableOrder(order *core.Order) *core.Order {
+	key := identifierSetKey(order.Identifiers)
+	orders := wfe.db.GetOrdersByAccountID(order.AccountID)
+	// Prefer the newest order
+	for i := len(orders) - 1; i >= 0; i-- {
+		existing, err := wfe.db.GetOrderByID(orders[i].ID)
+		if err != nil || existing == nil {
+			continue
 		}
-
-		// If there is a wildcard character in the ident value there should be only
-		// *one* instance
-		if strings.Count(rawDomain, "*") > 1 {
-			return acme.MalformedProblem(fmt.Sprintf(
-				"Order included DNS type identifier with illegal wildcard value: "+
-					"too many wildcards %q",
-				rawDomain))
-		} else if strings.Count(rawDomain, "*") == 1 {
-			// If there is one wildcard character it should be the only character in
-			// the leftmost label.
-			if !strings.HasPrefix(rawDomain, "*.") {
-				return acme.MalformedProblem(fmt.Sprintf(
-					"Order included DNS type identifier with illegal wildcard value: "+
-						"wildcard isn't leftmost prefix %q",
-					rawDomain))
-			}
+		existing.RLock()
+		reusable := (existing.Status == acme.StatusPending || existing.Status == acme.StatusReady) &&
+			!existing.BeganProcessing &&
+			existing.NotBefore == order.NotBefore &&
+			existing.NotAfter == order.NotAfter &&
+			existing.DelegationObject == order.DelegationObject &&
+			existing.AllowCertificateGet == order.AllowCertificateGet &&
+			existing.Replaces == order.Replaces &&
+			identifierSetKey(existing.Identifiers) == key
+		existing.RUnlock()
+		if reusable {
+			return existing
 		}
 	}
 	return nil
 }
 
-// makeAuthorizations populates an order with new authz's. The request parameter
-// is required to make the authz URL's absolute based on the request host
-func (wfe *WebFrontEndImpl) makeAuthorizations(order *core.Order, request *http.Request) error {
-	var auths []string
-	var authObs []*core.Authorization
-
-	// Lock the order for reading
-	order.RLock()
-	// Create one authz for each name in the order's parsed CSR
-	for _, name := range order.Names {
-		now := wfe.clk.Now().UTC()
-		expires := now.Add(pendingAuthzExpire)
-		ident := acme.Identifier{
-			Type:  acme.IdentifierDNS,
-			Value: name,
-		}
-		authz := &core.Authorization{
-			ID:          newToken(),
-			ExpiresDate: expires,
-			Order:       order,
-			Authorization: acme.Authorization{
-				Status:     acme.StatusPending,
-				Identifier: ident,
-				Expires:    expires.UTC().Format(time.RFC3339),
-			},
-		}
-		authz.URL = wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", authzPath, authz.ID))
-		// Create the challenges for this authz
-		err := wfe.makeChallenges(authz, request)
-		if err != nil {
-			return err
-		}
-		// Save the authorization in memory
-		count, err := wfe.db.AddAuthorization(authz)
-		if err != nil {
-			return err
-		}
-		wfe.log.Printf("There are now %d authorizations in the db\n", count)
-		authzURL := wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", authzPath, authz.ID))
-		auths = append(auths, authzURL)
-		authObs = append(authObs, authz)
+// identifierSetKey returns a key that is the same for identifier lists
+// containing the same identifiers in any order and letter case.
+func identifierSetKey(idents []acme.Identifier) string {
+	var keys []string
+	for _, ident := range idents {
+		keys = append(keys, strings.ToLower(fmt.Sprintf("%s:%s:%s",
+			ident.Type, ident.Value, ident.AncestorDomain)))
 	}
-	// Unlock the order from reading
-	order.RUnlock()
-
-	// Lock the order for writing & update the order's authorizations
-	order.Lock()
-	order.Authorizations = auths
-	order.AuthorizationObjects = authObs
-	order.Unlock()
-	return nil
+	return strings.Join(uniqueStrings(keys), ",")
 }
 
 func (wfe *WebFrontEndImpl) makeChallenge(
 	chalType string,
 	authz *core.Authorization,
-	request *http.Request) (*core.Challenge, error) {
+	request *http.Request) *core.Challenge {
 	// Create a new challenge of the requested type
 	id := newToken()
 	chal := &core.Challenge{
 		ID: id,
 		Challenge: acme.Challenge{
 			Type:   chalType,
-			Token:  newToken(),
+			Token:  wfe.challengeToken(chalType, authz.Identifier.Value),
 			URL:    wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", challengePath, id)),
 			Status: acme.StatusPending,
 		},
 		Authz: authz,
 	}
-
-	// Add it to the in-memory database
-	_, err := wfe.db.AddChallenge(chal)
-	if err != nil {
-		return nil, err
-	}
-	return chal, nil
+	return chal
 }
 
-// makeChallenges populates an authz with new challenges. The request parameter
-// is required to make the challenge URL's absolute based on the request host
-func (wfe *WebFrontEndImpl) makeChallenges(authz *core.Authorization, request *http.Request) error {
+// makeChallenges populates an authz with new challenges, and returns them. The
+// request parameter is required to make the challenge URL's absolute based on
+// the request host
+func (wfe *WebFrontEndImpl) makeChallenges(authz *core.Authorization, request *http.Request) ([]*core.Challenge, error) {
+	identType, ok := wfe.identifierType(authz.Identifier.Type)
+	if !ok {
+		return nil, fmt.Errorf("unsupported identifier type %q", authz.Identifier.Type)
+	}
 	var chals []*core.Challenge
-
-	// Authorizations for a wildcard identifier only get a DNS-01 challenges to
-	// match Boulder/Let's Encrypt wildcard issuance policy
-	if strings.HasPrefix(authz.Identifier.Value, "*.") {
-		chal, err := wfe.makeChallenge(acme.ChallengeDNS01, authz, request)
-		if err != nil {
-			return err
-		}
-		chals = []*core.Challenge{chal}
-	} else {
-		// Non-wildcard authorizations get all of the enabled challenge types
-		enabledChallenges := []string{acme.ChallengeHTTP01, acme.ChallengeTLSALPN01, acme.ChallengeDNS01}
-		for _, chalType := range enabledChallenges {
-			chal, err := wfe.makeChallenge(chalType, authz, request)
-			if err != nil {
-				return err
-			}
-			chals = append(chals, chal)
+	for _, chalType := range identType.Challenges(wfe, authz, request) {
+		chal := wfe.makeChallenge(chalType, authz, request)
+		if identType.PrepareChallenge != nil {
+			identType.PrepareChallenge(wfe, chal)
 		}
+		chals = append(chals, chal)
 	}
 
 	// Lock the authorization for writing to update the challenges
@@ -1020,7 +2392,7 @@ func (wfe *WebFrontEndImpl) makeChallenges(authz *core.Authorization, request *h
 		authz.Challenges = append(authz.Challenges, &c.Challenge)
 	}
 	authz.Unlock()
-	return nil
+	return chals, nil
 }
 
 // NewOrder creates a new Order request and populates its authorizations
@@ -1051,7 +2423,7 @@ func (wfe *WebFrontEndImpl) NewOrder(
 		return
 	}
 
-	expires := time.Now().AddDate(0, 0, 1)
+	expires := wfe.clk.Now().Add(wfe.config.OrderLifetime)
 	order := &core.Order{
 		ID:        newToken(),
 		AccountID: existingReg.ID,
@@ -1060,38 +2432,117 @@ func (wfe *WebFrontEndImpl) NewOrder(
 			Expires: expires.UTC().Format(time.RFC3339),
 			// Only the Identifiers, NotBefore and NotAfter from the submitted order
 			// are carried forward
-			Identifiers: newOrder.Identifiers,
+			Identifiers: wfe.normalizeIdentifiers(newOrder.Identifiers),
 			NotBefore:   newOrder.NotBefore,
 			NotAfter:    newOrder.NotAfter,
 		},
 		ExpiresDate: expires,
+		Namespace:   existingReg.Namespace,
 	}
 
 	// Verify the details of the order before creating authorizations
 	if err := wfe.verifyOrder(order); err != nil {
+		wfe.config.Audit.Record(audit.TypePolicyRejection, existingReg.ID, map[string]string{
+			"endpoint": newOrderPath,
+			"problem":  err.Error(),
+		})
 		wfe.sendError(err, response)
 		return
 	}
 
-	// Collect all of the DNS identifier values up into a []string
-	var orderNames []string
+	if newOrder.Delegation != "" || newOrder.AllowCertificateGet {
+		if prob := wfe.applyDelegation(order, newOrder); prob != nil {
+			wfe.sendError(prob, response)
+			return
+		}
+	}
+
+	if newOrder.Replaces != "" {
+		if prob := wfe.applyReplaces(order, newOrder.Replaces); prob != nil {
+			wfe.sendError(prob, response)
+			return
+		}
+	}
+
+	order.TestMeta, prob = checkTestMeta(newOrder.TestMeta)
+	if prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+
+	// Collect all of the DNS identifier values up into a []string, and the
+	// permanent identifier values into another
+	var orderNames, permanentIDs []string
 	for _, ident := range order.Identifiers {
+		if ident.Type == acme.IdentifierPermanentID {
+			permanentIDs = append(permanentIDs, ident.Value)
+			continue
+		}
+		if ident.Type == acme.IdentifierTNAuthList {
+			// verifyOrder already checked the value can be parsed
+			order.TNAuthList, _ = parseTNAuthList(ident.Value)
+			continue
+		}
 		orderNames = append(orderNames, ident.Value)
 	}
 
 	// Store the unique lower version of the names on the order object
 	order.Names = uniqueLowerNames(orderNames)
+	order.PermanentIDs = uniqueStrings(permanentIDs)
+
+	// Remember which names are to be authorized by an ancestor domain
+	if wfe.config.EnableSubdomainAuth {
+		for _, ident := range order.Identifiers {
+			if ident.Type == acme.IdentifierDNS && ident.AncestorDomain != "" {
+				if order.AncestorDomains == nil {
+					order.AncestorDomains = make(map[string]string)
+				}
+				order.AncestorDomains[strings.ToLower(ident.Value)] = strings.ToLower(ident.AncestorDomain)
+			}
+		}
+	}
+
+	if wfe.config.ReuseOrders {
+		if existing := wfe.reusableOrder(order); existing != nil {
+			wfe.log.Printf("Reusing order %q\n", existing.ID)
+			orderURL := wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", orderPath, existing.ID))
+			response.Header().Add("Location", orderURL)
+			err = wfe.writeJsonResponse(response, http.StatusCreated, wfe.orderForDisplay(existing, request))
+			if err != nil {
+				wfe.sendError(acme.InternalErrorProblem("Error marshalling order"), response)
+			}
+			return
+		}
+	}
+
+	if prob := wfe.checkDuplicateCertificates(order, response); prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+
+	release, prob := wfe.reservePendingOrder(existingReg.ID)
+	if prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+	defer release()
+
+	if prob := wfe.linkReplacement(order); prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
 
 	// Create the authorizations for the order
-	err = wfe.makeAuthorizations(order, request)
+	objects, err := wfe.makeAuthorizations(order, request)
 	if err != nil {
 		wfe.sendError(
 			acme.InternalErrorProblem("Error creating authorizations for order"), response)
 		return
 	}
 
-	// Add the order to the in-memory DB
-	count, err := wfe.db.AddOrder(order)
+	// Add the order and its authorizations to the in-memory DB
+	count, err := wfe.addOrder(order, objects)
+	release()
 	if err != nil {
 		wfe.sendError(
 			acme.InternalErrorProblem("Error saving order"), response)
@@ -1099,10 +2550,20 @@ func (wfe *WebFrontEndImpl) NewOrder(
 	}
 	wfe.log.Printf("Added order %q to the db\n", order.ID)
 	wfe.log.Printf("There are now %d orders in the db\n", count)
+	wfe.config.Events.Publish(events.TypeOrder, order.ID, acme.StatusPending, "")
 
 	// Get the stored order back from the DB. The memorystore will set the order's
 	// status for us.
-	storedOrder := wfe.db.GetOrderByID(order.ID)
+	storedOrder, err := wfe.db.GetOrderByID(order.ID)
+	if err != nil {
+		wfe.log.Printf("Error getting order %q: %s\n", order.ID, err)
+		wfe.sendError(acme.InternalErrorProblem("Error retrieving order"), response)
+		return
+	}
+	if storedOrder == nil {
+		wfe.sendError(acme.InternalErrorProblem("Error retrieving saved order"), response)
+		return
+	}
 
 	orderURL := wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", orderPath, storedOrder.ID))
 	response.Header().Add("Location", orderURL)
@@ -1126,25 +2587,31 @@ func (wfe *WebFrontEndImpl) orderForDisplay(
 	defer order.RUnlock()
 
 	// Copy the initial OrderRequest from the internal order object to mutate and
-	// use as the result.
+	// use as the result. The slices are copied too so that shuffling them below
+	// doesn't modify the stored order while only holding a read lock.
 	result := order.Order
+	result.Authorizations = append([]string(nil), order.Authorizations...)
+	result.Identifiers = append([]acme.Identifier(nil), order.Identifiers...)
 
 	// Randomize the order of the order authorization URLs as well as the order's
-	// identifiers. ACME draft Section 7.4 "Applying for Certificate Issuance"
-	// says:
+	// identifiers, unless another ordering is configured. ACME draft Section 7.4
+	// "Applying for Certificate Issuance" says:
 	//   Clients SHOULD NOT make any assumptions about the sort order of
 	//   "identifiers" or "authorizations" elements in the returned order
 	//   object.
-	rand.Shuffle(len(result.Authorizations), func(i, j int) {
+	wfe.config.ResponseOrdering.permute("authorizations", len(result.Authorizations), func(i, j int) {
 		result.Authorizations[i], result.Authorizations[j] = result.Authorizations[j], result.Authorizations[i]
 	})
-	rand.Shuffle(len(result.Identifiers), func(i, j int) {
+	wfe.config.ResponseOrdering.permute("identifiers", len(result.Identifiers), func(i, j int) {
 		result.Identifiers[i], result.Identifiers[j] = result.Identifiers[j], result.Identifiers[i]
 	})
 
 	// Populate a finalization URL for this order
 	result.Finalize = wfe.relativeEndpoint(request,
 		fmt.Sprintf("%s%s", orderFinalizePath, order.ID))
+	if wfe.config.EnableFinalizeDryRun {
+		result.FinalizeDryRun = wfe.relativeEndpoint(request, finalizeDryRunPath+order.ID)
+	}
 
 	// If the order has a cert ID then set the certificate URL by constructing
 	// a relative path based on the HTTP request & the cert ID
@@ -1152,6 +2619,10 @@ func (wfe *WebFrontEndImpl) orderForDisplay(
 		result.Certificate = wfe.relativeEndpoint(
 			request,
 			certPath+order.CertificateObject.ID)
+	} else if wfe.config.CertificateNotReady != "" && order.BeganProcessing && order.Error == nil {
+		// Processing orders get a certificate URL made from the order ID that
+		// keeps working once the certificate is issued
+		result.Certificate = wfe.relativeEndpoint(request, certPath+order.ID)
 	}
 
 	return result
@@ -1165,15 +2636,21 @@ func (wfe *WebFrontEndImpl) Order(
 	request *http.Request) {
 
 	orderID := strings.TrimPrefix(request.URL.Path, orderPath)
-	order := wfe.db.GetOrderByID(orderID)
+	order, err := wfe.db.GetOrderByID(orderID)
+	if err != nil {
+		wfe.log.Printf("Error getting order %q: %s\n", orderID, err)
+		wfe.sendError(acme.InternalErrorProblem("Error retrieving order"), response)
+		return
+	}
 	if order == nil {
 		response.WriteHeader(http.StatusNotFound)
 		return
 	}
+	wfe.extendOrderExpiry(order)
 
 	// Prepare the order for display as JSON
 	orderReq := wfe.orderForDisplay(order, request)
-	err := wfe.writeJsonResponse(response, http.StatusOK, orderReq)
+	err = wfe.writeJsonResponse(response, http.StatusOK, orderReq)
 	if err != nil {
 		wfe.sendError(acme.InternalErrorProblem("Error marshalling order"), response)
 		return
@@ -1202,7 +2679,12 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(
 
 	// Find the order specified by the order ID
 	orderID := strings.TrimPrefix(request.URL.Path, orderFinalizePath)
-	existingOrder := wfe.db.GetOrderByID(orderID)
+	existingOrder, err := wfe.db.GetOrderByID(orderID)
+	if err != nil {
+		wfe.log.Printf("Error getting order %q: %s\n", orderID, err)
+		wfe.sendError(acme.InternalErrorProblem("Error retrieving order"), response)
+		return
+	}
 	if existingOrder == nil {
 		response.WriteHeader(http.StatusNotFound)
 		wfe.sendError(acme.NotFoundProblem(fmt.Sprintf(
@@ -1215,7 +2697,6 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(
 	orderAccountID := existingOrder.AccountID
 	orderStatus := existingOrder.Status
 	orderExpires := existingOrder.ExpiresDate
-	orderNames := existingOrder.Names
 	// And then immediately unlock it again - we don't defer() here because
 	// `maybeIssue` will also acquire a read lock and we call that before
 	// returning
@@ -1230,6 +2711,14 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(
 		return
 	}
 
+	// Finalization is idempotent: if the order has already begun processing,
+	// e.g. because a client retried a finalize request, then the order is
+	// returned as-is rather than being finalized again.
+	if orderStatus == acme.StatusProcessing || orderStatus == acme.StatusValid {
+		wfe.writeFinalizedOrder(existingOrder, request, response)
+		return
+	}
+
 	// The existing order must be in a ready status to finalize it
 	if orderStatus != acme.StatusReady {
 		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
@@ -1244,68 +2733,181 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(
 		return
 	}
 
+	if prob := wfe.checkDuplicateCertificates(existingOrder, response); prob != nil {
+		wfe.sendError(prob, response)
+		return
+	}
+
 	// The finalize POST body is expected to be the bytes from a base64 raw url
 	// encoded CSR
 	var finalizeMessage struct {
 		CSR string
 	}
-	err := json.Unmarshal(body, &finalizeMessage)
+	err = json.Unmarshal(body, &finalizeMessage)
 	if err != nil {
 		wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
 			"Error unmarshaling finalize order request body: %s", err.Error())), response)
 		return
 	}
 
+	// Every CSR submitted is recorded so that rejected CSRs can be inspected
+	// through the management interface.
+	rejectCSR := func(prob *acme.ProblemDetails) {
+		wfe.recordFinalizeAttempt(existingOrder, finalizeMessage.CSR, prob)
+		wfe.sendError(prob, response)
+	}
+
 	csrBytes, err := base64.RawURLEncoding.DecodeString(finalizeMessage.CSR)
 	if err != nil {
-		wfe.sendError(
-			acme.MalformedProblem("Error decoding Base64url-encoded CSR: "+err.Error()), response)
+		rejectCSR(acme.MalformedProblem("Error decoding Base64url-encoded CSR: " + err.Error()))
 		return
 	}
 
 	parsedCSR, err := x509.ParseCertificateRequest(csrBytes)
 	if err != nil {
-		wfe.sendError(
-			acme.MalformedProblem("Error parsing Base64url-encoded CSR: "+err.Error()), response)
+		rejectCSR(acme.MalformedProblem("Error parsing Base64url-encoded CSR: " + err.Error()))
 		return
 	}
 
-	// Check that the CSR has the same number of names as the initial order contained
-	csrNames := uniqueLowerNames(parsedCSR.DNSNames)
-	if len(csrNames) != len(orderNames) {
-		wfe.sendError(acme.UnauthorizedProblem(
-			"Order includes different number of names than CSR specifieds"), response)
+	if probs := wfe.csrProblems(existingOrder, parsedCSR, request); len(probs) > 0 {
+		rejectCSR(probs[0])
 		return
 	}
 
-	// Check that the CSR's names match the order names exactly
-	for i, name := range orderNames {
-		if name != csrNames[i] {
-			wfe.sendError(acme.UnauthorizedProblem(
-				fmt.Sprintf("CSR is missing Order domain %q", name)), response)
-			return
-		}
+	if prob := wfe.checkCSRReplay(csrBytes, orderID, existingAcct.ID); prob != nil {
+		wfe.config.Audit.Record(audit.TypePolicyRejection, existingAcct.ID, map[string]string{
+			"endpoint": orderFinalizePath,
+			"order":    orderID,
+			"problem":  prob.Error(),
+		})
+		rejectCSR(prob)
+		return
 	}
 
 	// Lock and update the order with the parsed CSR and the began processing
-	// state.
+	// state. Checking BeganProcessing under the same write lock ensures only
+	// one of several concurrent finalize requests for the order triggers
+	// issuance. The others are given the order that is already processing.
 	existingOrder.Lock()
+	if existingOrder.BeganProcessing {
+		existingOrder.Unlock()
+		wfe.log.Printf("Order %s is already being finalized", orderID)
+		wfe.writeFinalizedOrder(existingOrder, request, response)
+		return
+	}
 	existingOrder.ParsedCSR = parsedCSR
 	existingOrder.BeganProcessing = true
+	// Set the existingOrder to processing before displaying to the user
+	existingOrder.Status = acme.StatusProcessing
 	existingOrder.Unlock()
+	wfe.recordFinalizeAttempt(existingOrder, finalizeMessage.CSR, nil)
+	wfe.config.Events.Publish(events.TypeOrder, orderID, acme.StatusProcessing, "")
 
 	// Ask the CA to complete the order in a separate goroutine.
 	wfe.log.Printf("Order %s is fully authorized. Processing finalization", orderID)
-	go wfe.ca.CompleteOrder(existingOrder)
+	go wfe.completeOrder(existingOrder)
 
-	// Set the existingOrder to processing before displaying to the user
-	existingOrder.Status = acme.StatusProcessing
+	wfe.writeFinalizedOrder(existingOrder, request, response)
+}
+
+// csrProblems returns the problems with a CSR submitted to finalize the order,
+// in the order FinalizeOrder checks for them.
+func (wfe *WebFrontEndImpl) csrProblems(
+	order *core.Order,
+	csr *x509.CertificateRequest,
+	request *http.Request) []*acme.ProblemDetails {
+	var probs []*acme.ProblemDetails
+
+	if wfe.config.RejectEd25519CSRKeys && isEd25519Key(csr.PublicKey) {
+		probs = append(probs, acme.BadCSRProblem(
+			"CSRs for Ed25519 subscriber keys are not supported, use an RSA or ECDSA key"))
+	}
+	if err := cryptomode.CheckKey(csr.PublicKey); err != nil {
+		probs = append(probs, acme.BadCSRProblem(err.Error()))
+	}
+
+	if view := requestView(request); !view.allowsKeyType(csr.PublicKey) {
+		kt := keyType(csr.PublicKey)
+		if kt == "" {
+			kt = "unknown"
+		}
+		probs = append(probs, acme.BadPublicKeyProblem(fmt.Sprintf(
+			"CSR key type %q is not allowed, use one of %s",
+			kt, strings.Join(view.KeyTypes, ", "))))
+	}
+
+	order.RLock()
+	orderNames := order.Names
+	delegation := order.DelegationObject
+	order.RUnlock()
+
+	// Check that the CSR has the same number of names as the initial order
+	// contained, and then that they match the order names exactly
+	csrNames := uniqueLowerNames(csr.DNSNames)
+	if len(csrNames) != len(orderNames) {
+		probs = append(probs, acme.UnauthorizedProblem(
+			"Order includes different number of names than CSR specifieds"))
+	} else {
+		for i, name := range orderNames {
+			if name != csrNames[i] {
+				probs = append(probs, acme.UnauthorizedProblem(
+					fmt.Sprintf("CSR is missing Order domain %q", name)))
+				break
+			}
+		}
+	}
+
+	if _, err := wfe.ca.CSRExtensions(csr); err != nil {
+		probs = append(probs, acme.BadCSRProblem(err.Error()))
+	}
+
+	if delegation != nil {
+		if err := checkCSRTemplate(delegation.CSRTemplate, csr); err != nil {
+			probs = append(probs, acme.BadCSRProblem(fmt.Sprintf(
+				"CSR doesn't match the delegation's CSR template: %s", err)))
+		}
+	}
+	return probs
+}
+
+// csrReplayAllowed returns true if the CSR replay policy allows a CSR first
+// used as given to finalize the order of the account.
+func (wfe *WebFrontEndImpl) csrReplayAllowed(first db.CSRUse, orderID, accountID string) bool {
+	if wfe.config.CSRReplayPolicy == CSRReplayAllow || first.OrderID == orderID {
+		return true
+	}
+	return wfe.config.CSRReplayPolicy == CSRReplayAcrossAccounts && first.AccountID == accountID
+}
+
+// checkCSRReplay records the CSR's use for the order and returns a problem if
+// the CSR replay policy doesn't allow it because the CSR was already used for
+// another order.
+func (wfe *WebFrontEndImpl) checkCSRReplay(csr []byte, orderID, accountID string) *acme.ProblemDetails {
+	if wfe.config.CSRReplayPolicy == CSRReplayAllow {
+		return nil
+	}
+	digest := sha256.Sum256(csr)
+	first, ok := wfe.db.RecordCSR(hex.EncodeToString(digest[:]),
+		db.CSRUse{OrderID: orderID, AccountID: accountID})
+	if ok || wfe.csrReplayAllowed(first, orderID, accountID) {
+		return nil
+	}
+	wfe.log.Printf("Rejecting CSR for order %s already used for order %s of account %s\n",
+		orderID, first.OrderID, first.AccountID)
+	return acme.CSRReplayedProblem("CSR was already used to finalize another order")
+}
 
+// writeFinalizedOrder writes the response to a finalize request for an order
+// that has begun processing.
+func (wfe *WebFrontEndImpl) writeFinalizedOrder(
+	order *core.Order,
+	request *http.Request,
+	response http.ResponseWriter) {
 	// Prepare the order for display as JSON
-	orderReq := wfe.orderForDisplay(existingOrder, request)
-	orderURL := wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", orderPath, existingOrder.ID))
+	orderReq := wfe.orderForDisplay(order, request)
+	orderURL := wfe.relativeEndpoint(request, fmt.Sprintf("%s%s", orderPath, order.ID))
 	response.Header().Add("Location", orderURL)
-	err = wfe.writeJsonResponse(response, http.StatusOK, orderReq)
+	err := wfe.writeJsonResponse(response, http.StatusOK, orderReq)
 	if err != nil {
 		wfe.sendError(acme.InternalErrorProblem("Error marshalling order"), response)
 		return
@@ -1314,7 +2916,7 @@ func (wfe *WebFrontEndImpl) FinalizeOrder(
 
 // prepAuthorizationForDisplay prepares the provided acme.Authorization for
 // display to an ACME client.
-func prepAuthorizationForDisplay(authz acme.Authorization) acme.Authorization {
+func (wfe *WebFrontEndImpl) prepAuthorizationForDisplay(authz acme.Authorization) acme.Authorization {
 	// Copy the authz to mutate and return
 	result := authz
 
@@ -1341,11 +2943,12 @@ func prepAuthorizationForDisplay(authz acme.Authorization) acme.Authorization {
 		result.Challenges = chals
 	}
 
-	// Randomize the order of the challenges in the returned authorization.
-	// Clients should not make any assumptions about the sort order.
-	rand.Shuffle(len(result.Challenges), func(i, j int) {
-		result.Challenges[i], result.Challenges[j] = result.Challenges[j], result.Challenges[i]
-	})
+	// Randomize the order of the challenges in the returned authorization,
+	// unless another ordering is configured. Clients should not make any
+	// assumptions about the sort order. The slice is copied so that the
+	// stored authorization's challenges aren't reordered.
+	result.Challenges = append([]*acme.Challenge(nil), result.Challenges...)
+	wfe.config.ResponseOrdering.orderChallenges(result.Challenges)
 
 	return result
 }
@@ -1362,11 +2965,23 @@ func (wfe *WebFrontEndImpl) Authz(
 		response.WriteHeader(http.StatusNotFound)
 		return
 	}
+	wfe.extendAuthzExpiry(authz)
+
+	// Lock the authz for reading in order to prepare it for display
+	authz.RLock()
+	displayAuthz := authz.Authorization
+	// Pending and valid authorizations that have passed their expiry date are
+	// shown as expired.
+	if (displayAuthz.Status == acme.StatusPending || displayAuthz.Status == acme.StatusValid) &&
+		authz.ExpiresDate.Before(wfe.clk.Now()) {
+		displayAuthz.Status = acme.StatusExpired
+	}
+	authz.RUnlock()
 
 	err := wfe.writeJsonResponse(
 		response,
 		http.StatusOK,
-		prepAuthorizationForDisplay(authz.Authorization))
+		wfe.prepAuthorizationForDisplay(displayAuthz))
 	if err != nil {
 		wfe.sendError(acme.InternalErrorProblem("Error marshalling authz"), response)
 		return
@@ -1413,17 +3028,14 @@ func (wfe *WebFrontEndImpl) getChallenge(
 
 // getAcctByKey finds a account by key or returns a problem pointer if an
 // existing account can't be found or the key is invalid.
-func (wfe *WebFrontEndImpl) getAcctByKey(key crypto.PublicKey) (*core.Account, *acme.ProblemDetails) {
-	// Compute the account ID for the signer's key
-	regID, err := keyToID(key)
+func (wfe *WebFrontEndImpl) getAcctByKey(key *jose.JSONWebKey) (*core.Account, *acme.ProblemDetails) {
+	// Find the existing account object for the signer's key
+	existingAcct, err := wfe.db.GetAccountByKey(key)
 	if err != nil {
-		wfe.log.Printf("keyToID err: %s\n", err.Error())
-		return nil, acme.MalformedProblem("Error computing key digest")
+		wfe.log.Printf("GetAccountByKey err: %s\n", err.Error())
+		return nil, acme.MalformedProblem("Error computing key thumbprint")
 	}
-
-	// Find the existing account object for that key ID
-	var existingAcct *core.Account
-	if existingAcct = wfe.db.GetAccountByID(regID); existingAcct == nil {
+	if existingAcct == nil {
 		return nil, acme.AccountDoesNotExistProblem(
 			"URL in JWS 'kid' field does not correspond to an account")
 	}
@@ -1463,7 +3075,9 @@ func (wfe *WebFrontEndImpl) validateAuthzForChallenge(authz *core.Authorization)
 	defer authz.RUnlock()
 
 	ident := authz.Identifier
-	if ident.Type != acme.IdentifierDNS {
+	if ident.Type != acme.IdentifierDNS &&
+		!(ident.Type == acme.IdentifierPermanentID && wfe.config.EnableDeviceAttest) &&
+		!(ident.Type == acme.IdentifierTNAuthList && wfe.config.EnableTNAuthList) {
 		return nil, acme.MalformedProblem(
 			fmt.Sprintf("Authorization identifier was type %s, only %s is supported",
 				ident.Type, acme.IdentifierDNS))
@@ -1504,6 +3118,14 @@ func (wfe *WebFrontEndImpl) updateChallenge(
 
 	var chalResp struct {
 		KeyAuthorization *string
+		// AttObj is the base64url encoded attestation object sent in response
+		// to a device-attest-01 challenge.
+		AttObj string `json:"attObj"`
+		// ATC is the Authority Token sent in response to a tkauth-01 challenge.
+		ATC string `json:"atc"`
+		// CSR is the base64url encoded DER CSR sent in response to an
+		// onion-csr-01 challenge.
+		CSR string `json:"csr"`
 	}
 	err := json.Unmarshal(body, &chalResp)
 	if err != nil {
@@ -1561,6 +3183,47 @@ func (wfe *WebFrontEndImpl) updateChallenge(
 	}
 	existingOrder.RUnlock()
 
+	// A device-attest-01 challenge response carries the attestation object the
+	// VA verifies
+	existingChal.RLock()
+	chalType := existingChal.Type
+	existingChal.RUnlock()
+	if chalType == acme.ChallengeDeviceAttest01 {
+		attObj, err := base64.RawURLEncoding.DecodeString(chalResp.AttObj)
+		if err != nil || len(attObj) == 0 {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Response to a %s challenge must contain a base64url encoded attObj",
+				acme.ChallengeDeviceAttest01)), response)
+			return
+		}
+		existingChal.Lock()
+		existingChal.AttestationObject = attObj
+		existingChal.Unlock()
+	}
+	if chalType == acme.ChallengeTKAuth01 {
+		if chalResp.ATC == "" {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Response to a %s challenge must contain an atc Authority Token",
+				acme.ChallengeTKAuth01)), response)
+			return
+		}
+		existingChal.Lock()
+		existingChal.AuthorityToken = chalResp.ATC
+		existingChal.Unlock()
+	}
+	if chalType == acme.ChallengeOnionCSR01 {
+		csr, err := base64.RawURLEncoding.DecodeString(chalResp.CSR)
+		if err != nil || len(csr) == 0 {
+			wfe.sendError(acme.MalformedProblem(fmt.Sprintf(
+				"Response to a %s challenge must contain a base64url encoded csr",
+				acme.ChallengeOnionCSR01)), response)
+			return
+		}
+		existingChal.Lock()
+		existingChal.OnionCSR = csr
+		existingChal.Unlock()
+	}
+
 	// Lock the authorization to get the identifier value
 	authz.RLock()
 	ident := authz.Identifier.Value
@@ -1574,7 +3237,8 @@ func (wfe *WebFrontEndImpl) updateChallenge(
 	}
 
 	// Submit a validation job to the VA, this will be processed asynchronously
-	wfe.va.ValidateChallenge(ident, existingChal, existingAcct)
+	wfe.va.ValidateChallenge(ident, existingChal, existingAcct,
+		wfe.accountOverride(existingAcct.ID).ValidationOutcome)
 
 	// Lock the challenge for reading in order to write the response
 	existingChal.RLock()
@@ -1593,19 +3257,104 @@ func (wfe *WebFrontEndImpl) Certificate(
 	response http.ResponseWriter,
 	request *http.Request) {
 
+	// The certificate path is either the serial alone, for the default chain,
+	// or the serial followed by the number of an alternate chain.
 	serial := strings.TrimPrefix(request.URL.Path, certPath)
+	chainNo := 0
+	if parts := strings.SplitN(serial, "/", 2); len(parts) == 2 {
+		no, err := strconv.Atoi(parts[1])
+		if err != nil || no < 1 {
+			response.WriteHeader(http.StatusNotFound)
+			return
+		}
+		serial, chainNo = parts[0], no
+	}
+
 	cert := wfe.db.GetCertificateByID(serial)
+	if cert == nil && wfe.config.CertificateNotReady != "" {
+		var isOrder bool
+		if cert, isOrder = wfe.orderCertificate(serial); isOrder && cert == nil {
+			wfe.sendCertificateNotReady(response)
+			return
+		}
+	}
 	if cert == nil {
 		response.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	response.Header().Set("Content-Type", "application/pem-certificate-chain; charset=utf-8")
+	modes := wfe.chainModes
+	if header := request.Header.Get(chainModeHeader); header != "" {
+		var err error
+		modes, err = parseChainModes(strings.Split(header, ","))
+		if err != nil {
+			wfe.sendError(acme.MalformedProblem(err.Error()), response)
+			return
+		}
+	}
+
+	chainCerts, err := cert.ChainCertificates(chainNo, modes[ChainIncludeRoot])
+	if err != nil {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+	chain := modes.apply(chainCerts)
+
+	// Link to every chain other than the one being served as an alternate
+	for i := 0; i <= len(cert.AlternateIssuers); i++ {
+		if i == chainNo {
+			continue
+		}
+		chainPath := certPath + serial
+		if i > 0 {
+			chainPath = fmt.Sprintf("%s/%d", chainPath, i)
+		}
+		response.Header().Add("Link", link(wfe.relativeEndpoint(request, chainPath), "alternate"))
+	}
+
+	response.Header().Add("Vary", "Accept")
+	if certificateContentType(request.Header.Get("Accept")) == derContentType {
+		if wfe.notModified(response, request, cert.DER, cert.Cert.NotBefore) {
+			return
+		}
+		response.Header().Set("Content-Type", derContentType)
+		wfe.signResponse(response, cert.DER)
+		writeCertificateBody(response, cert.DER, wfe.config.CertificateChunkSize)
+		return
+	}
+	if wfe.notModified(response, request, chain, cert.Cert.NotBefore) {
+		return
+	}
+	response.Header().Set("Content-Type", pemChainContentType+"; charset=utf-8")
+	wfe.signResponse(response, chain)
+	writeCertificateBody(response, chain, wfe.config.CertificateChunkSize)
+}
+
+// IssuerCert serves the DER encoded certificate of the issuer with the ID in
+// the request path, as RFC 5280 Section 4.2.2.1 requires of CA Issuers URLs.
+func (wfe *WebFrontEndImpl) IssuerCert(
+	ctx context.Context,
+	logEvent *requestEvent,
+	response http.ResponseWriter,
+	request *http.Request) {
+
+	cert := wfe.ca.IssuerCertificate(strings.TrimPrefix(request.URL.Path, issuerCertPath))
+	if cert == nil {
+		response.WriteHeader(http.StatusNotFound)
+		return
+	}
+	response.Header().Set("Content-Type", derContentType)
 	response.WriteHeader(http.StatusOK)
-	_, _ = response.Write(cert.Chain())
+	_, _ = response.Write(cert.DER)
 }
 
 func (wfe *WebFrontEndImpl) writeJsonResponse(response http.ResponseWriter, status int, v interface{}) error {
+	if object := objectName(v); object != "" {
+		var err error
+		if v, err = wfe.changeFields(object, v); err != nil {
+			return err
+		}
+	}
 	jsonReply, err := marshalIndent(v)
 	if err != nil {
 		return err // All callers are responsible for handling this error
@@ -1620,10 +3369,6 @@ func (wfe *WebFrontEndImpl) writeJsonResponse(response http.ResponseWriter, stat
 	return nil
 }
 
-func addNoCacheHeader(response http.ResponseWriter) {
-	response.Header().Add("Cache-Control", "public, max-age=0, no-cache")
-}
-
 func marshalIndent(v interface{}) ([]byte, error) {
 	return json.MarshalIndent(v, "", "   ")
 }
@@ -1632,6 +3377,41 @@ func link(url, relation string) string {
 	return fmt.Sprintf("<%s>;rel=\"%s\"", url, relation)
 }
 
+// parseTNAuthList decodes the value of a TNAuthList identifier: a base64url
+// encoded, DER encoded TNAuthorizationList (RFC 8226). Only the outer
+// SEQUENCE is checked.
+func parseTNAuthList(value string) ([]byte, error) {
+	der, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("value is not base64url encoded")
+	}
+	var list asn1.RawValue
+	rest, err := asn1.Unmarshal(der, &list)
+	if err != nil {
+		return nil, fmt.Errorf("value is not DER encoded: %s", err)
+	}
+	if len(rest) != 0 || list.Class != asn1.ClassUniversal ||
+		list.Tag != asn1.TagSequence || len(list.Bytes) == 0 {
+		return nil, fmt.Errorf("value is not a non-empty TNAuthorizationList sequence")
+	}
+	return der, nil
+}
+
+// uniqueStrings returns the set of all unique strings in the input, sorted
+// alphabetically. Unlike uniqueLowerNames the case of the strings is kept.
+func uniqueStrings(values []string) []string {
+	valueMap := make(map[string]bool, len(values))
+	for _, v := range values {
+		valueMap[v] = true
+	}
+	unique := make([]string, 0, len(valueMap))
+	for v := range valueMap {
+		unique = append(unique, v)
+	}
+	sort.Strings(unique)
+	return unique
+}
+
 // uniqueLowerNames returns the set of all unique names in the input after all
 // of them are lowercased. The returned names will be in their lowercased form
 // and sorted alphabetically. See Boulder `core/util.go UniqueLowerNames`.
@@ -1728,14 +3508,13 @@ func (wfe *WebFrontEndImpl) revokeCertByKeyID(
 		return prob
 	}
 
-	keyID, err := keyToID(key)
+	existingAcct, err := wfe.db.GetAccountByKey(key)
 	if err != nil {
 		return acme.MalformedProblem(err.Error())
 	}
-
-	existingAcct := wfe.db.GetAccountByID(keyID)
 	if existingAcct == nil {
-		return acme.UnauthorizedProblem(fmt.Sprintf("Account with keyID %q does not exist", keyID))
+		return acme.UnauthorizedProblem(fmt.Sprintf(
+			"Account with key ID %q does not exist", jws.Signatures[0].Header.KeyID))
 	}
 
 	// An account is only authorized to revoke its own certificates presently.
@@ -1751,7 +3530,7 @@ func (wfe *WebFrontEndImpl) revokeCertByKeyID(
 				"The certificate being revoked is not associated with account %q",
 				existingAcct.ID))
 	}
-	return wfe.processRevocation(ctx, body, authorizedToRevoke, request, logEvent)
+	return wfe.processRevocation(ctx, body, authorizedToRevoke, existingAcct.ID, request, logEvent)
 }
 
 func (wfe *WebFrontEndImpl) revokeCertByJWK(
@@ -1781,7 +3560,7 @@ func (wfe *WebFrontEndImpl) revokeCertByJWK(
 		return acme.UnauthorizedProblem(
 			"JWK embedded in revocation request must be the same public key as the cert to be revoked")
 	}
-	return wfe.processRevocation(ctx, body, authorizedToRevoke, request, logEvent)
+	return wfe.processRevocation(ctx, body, authorizedToRevoke, "", request, logEvent)
 }
 
 // authorizedToRevokeCert is a callback function that can be used to validate if
@@ -1792,10 +3571,14 @@ func (wfe *WebFrontEndImpl) revokeCertByJWK(
 // decision.
 type authorizedToRevokeCert func(*core.Certificate) *acme.ProblemDetails
 
+// processRevocation revokes the certificate of a revocation request. accountID
+// is the ID of the requesting account, or empty if the request is signed by
+// the certificate's key.
 func (wfe *WebFrontEndImpl) processRevocation(
 	ctx context.Context,
 	jwsBody []byte,
 	authorizedToRevoke authorizedToRevokeCert,
+	accountID string,
 	request *http.Request,
 	logEvent *requestEvent) *acme.ProblemDetails {
 
@@ -1809,11 +3592,8 @@ func (wfe *WebFrontEndImpl) processRevocation(
 		return acme.MalformedProblem("Error unmarshaling certificate revocation JSON body")
 	}
 
-	if revokeCertReq.Reason != nil {
-		r := *revokeCertReq.Reason
-		if r == unusedRevocationReason || r > aACompromiseRevocationReason {
-			return acme.BadRevocationReasonProblem(fmt.Sprintf("Invalid revocation reason: %d", r))
-		}
+	if prob := validRevocationReason(revokeCertReq.Reason); prob != nil {
+		return prob
 	}
 
 	derBytes, err := base64.RawURLEncoding.DecodeString(revokeCertReq.Certificate)
@@ -1831,6 +3611,30 @@ func (wfe *WebFrontEndImpl) processRevocation(
 		return prob
 	}
 
+	wfe.revoke(cert, accountID, revokeCertReq.Reason)
+	return nil
+}
+
+// revoke forgets the certificate, notifying webhooks and recording the
+// revocation by the actor in the audit log.
+func (wfe *WebFrontEndImpl) revoke(cert *core.Certificate, actor string, reason *uint) {
 	wfe.db.RevokeCertificate(cert)
+	wfe.config.Notifier.Notify(webhook.EventRevoked, cert)
+	details := map[string]string{
+		"serial":    cert.ID,
+		"accountID": cert.AccountID,
+	}
+	if reason != nil {
+		details["reason"] = strconv.Itoa(int(*reason))
+	}
+	wfe.config.Audit.Record(audit.TypeCertificateRevoked, actor, details)
+}
+
+// validRevocationReason returns a badRevocationReason problem if the reason
+// isn't one of the RFC 5280 reason codes certificates can be revoked with.
+func validRevocationReason(reason *uint) *acme.ProblemDetails {
+	if reason != nil && (*reason == unusedRevocationReason || *reason > aACompromiseRevocationReason) {
+		return acme.BadRevocationReasonProblem(fmt.Sprintf("Invalid revocation reason: %d", *reason))
+	}
 	return nil
 }